@@ -2,9 +2,19 @@ module github.com/johnayoung/finlib
 
 go 1.22.3
 
-require github.com/shopspring/decimal v1.4.0
+require (
+	github.com/shopspring/decimal v1.4.0
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.2
+)
 
-require github.com/stretchr/objx v0.5.2 // indirect
+require (
+	github.com/stretchr/objx v0.5.2 // indirect
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -1,9 +1,24 @@
 package account
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAccountTypes(t *testing.T) {
@@ -95,3 +110,585 @@ func TestBalance(t *testing.T) {
 		assert.Equal(t, "tx789", balance.LastTransactionID)
 	})
 }
+
+// chartRepo serves a fixed chart of accounts to the hierarchy manager
+type chartRepo struct {
+	Repository
+	accounts []*Account
+}
+
+func (r *chartRepo) Create(ctx context.Context, entity interface{}) error {
+	r.accounts = append(r.accounts, entity.(*Account))
+	return nil
+}
+
+func (r *chartRepo) Query(ctx context.Context, query interface{}, results interface{}) error {
+	*(results.(*[]*Account)) = r.accounts
+	return nil
+}
+
+func TestHierarchy(t *testing.T) {
+	ctx := context.Background()
+	id := func(s string) *string { return &s }
+	usd := func(amount int64) *money.Money {
+		return &money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	repo := &chartRepo{accounts: []*Account{
+		{ID: "1000", Code: "1000", Name: "Assets", Type: Asset},
+		{ID: "1100", Code: "1100", Name: "Cash", Type: Asset, ParentID: id("1000"), Balance: usd(100)},
+		{ID: "1110", Code: "1110", Name: "Petty Cash", Type: Asset, ParentID: id("1100"), Balance: usd(5)},
+		{ID: "1200", Code: "1200", Name: "Receivables", Type: Asset, ParentID: id("1000"), Balance: usd(250)},
+		{ID: "2000", Code: "2000", Name: "Liabilities", Type: Liability, Balance: usd(40)},
+	}}
+	manager := NewHierarchyManager(repo, nil)
+
+	codes := func(accounts []*Account) []string {
+		out := make([]string, len(accounts))
+		for i, acc := range accounts {
+			out[i] = acc.Code
+		}
+		return out
+	}
+
+	children, err := manager.GetChildren(ctx, "1000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1100", "1200"}, codes(children))
+
+	descendants, err := manager.GetDescendants(ctx, "1000")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1100", "1110", "1200"}, codes(descendants))
+
+	ancestors, err := manager.GetAncestors(ctx, "1110")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1100", "1000"}, codes(ancestors))
+
+	rollup, err := manager.GetRollup(ctx, "1000")
+	require.NoError(t, err)
+	assert.True(t, rollup.Total.Amount.Equal(decimal.NewFromInt(355)))
+	assert.Equal(t, "USD", rollup.Total.Currency)
+	assert.True(t, rollup.Children[0].Total.Amount.Equal(decimal.NewFromInt(105)))
+
+	_, err = manager.GetChildren(ctx, "9999")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	t.Run("Mixed Currencies", func(t *testing.T) {
+		repo.accounts[2].Balance = &money.Money{Amount: decimal.NewFromInt(5), Currency: "EUR"}
+		defer func() { repo.accounts[2].Balance = usd(5) }()
+		_, err := manager.GetRollup(ctx, "1000")
+		assert.ErrorIs(t, err, money.ErrMismatchedCurrencies)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, err := NewHierarchy([]*Account{
+			{ID: "A", Code: "A", ParentID: id("B")},
+			{ID: "B", Code: "B", ParentID: id("A")},
+		})
+		assert.ErrorIs(t, err, ErrInvalidOperation)
+	})
+}
+
+func TestChartOfAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range Templates() {
+		chart, err := Template(name)
+		require.NoError(t, err)
+		assert.NoError(t, chart.Validate(), name)
+	}
+	_, err := Template("unknown")
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+
+	t.Run("CSV Round Trip", func(t *testing.T) {
+		chart, err := Template(TemplateNonprofit)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, chart.ExportCSV(&buf))
+		imported, err := ImportCSV(TemplateNonprofit, &buf)
+		require.NoError(t, err)
+		assert.Equal(t, chart, imported)
+	})
+
+	t.Run("JSON Round Trip", func(t *testing.T) {
+		chart, err := Template(TemplateIFRS)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, chart.ExportJSON(&buf))
+		imported, err := ImportJSON(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, chart, imported)
+	})
+
+	t.Run("Install", func(t *testing.T) {
+		chart, err := ImportCSV("custom", strings.NewReader(
+			"code,name,type,parent_code\n1100,Cash,asset,1000\n1000,Assets,asset,\n4000,Sales,revenue,\n"))
+		require.NoError(t, err)
+
+		repo := &chartRepo{}
+		installed, err := chart.Install(ctx, repo)
+		require.NoError(t, err)
+		require.Len(t, repo.accounts, 3)
+		assert.Equal(t, "1000", repo.accounts[0].ID)
+		assert.Equal(t, "1100", repo.accounts[1].ID)
+		assert.Equal(t, "1000", *repo.accounts[1].ParentID)
+		assert.Equal(t, Active, installed[2].Status)
+
+		exported := ChartFromAccounts("custom", repo.accounts)
+		assert.Equal(t, "1000", exported.Accounts[1].ParentCode)
+	})
+
+	t.Run("Invalid Charts", func(t *testing.T) {
+		_, err := ImportCSV("bad", strings.NewReader("code,name\n1000,Assets\n"))
+		assert.ErrorIs(t, err, ErrInvalidChart)
+		_, err = ImportCSV("bad", strings.NewReader("code,name,type,parent_code\n1000,Assets,ASSET,9000\n"))
+		assert.ErrorIs(t, err, ErrInvalidChart)
+		_, err = ImportCSV("bad", strings.NewReader("code,name,type\n1000,Assets,ASSET\n1000,Cash,ASSET\n"))
+		assert.ErrorIs(t, err, ErrInvalidChart)
+		_, err = ImportCSV("bad", strings.NewReader("code,name,type\n1000,Assets,CASH\n"))
+		assert.ErrorIs(t, err, ErrInvalidChart)
+	})
+}
+
+// mapRepo is an in-memory account Repository supporting equality filters
+type mapRepo struct {
+	accounts map[string]Account
+	// Audit reason of the last update
+	reason string
+}
+
+func (r *mapRepo) Create(ctx context.Context, entity interface{}) error {
+	acc := entity.(*Account)
+	r.accounts[acc.ID] = *acc
+	return nil
+}
+
+func (r *mapRepo) Read(ctx context.Context, id string, entity interface{}) error {
+	acc, ok := r.accounts[id]
+	if !ok {
+		return fmt.Errorf("not found")
+	}
+	*(entity.(*Account)) = acc
+	return nil
+}
+
+func (r *mapRepo) Update(ctx context.Context, entity interface{}) error {
+	r.reason = audit.CallerFromContext(ctx).Reason
+	return r.Create(ctx, entity)
+}
+
+func (r *mapRepo) Delete(ctx context.Context, id string) error {
+	delete(r.accounts, id)
+	return nil
+}
+
+func (r *mapRepo) Query(ctx context.Context, query interface{}, results interface{}) error {
+	var out []*Account
+	for _, acc := range r.accounts {
+		acc := acc
+		match := true
+		for _, f := range query.(storage.Query).Filters {
+			switch f.Field {
+			case "code":
+				match = match && acc.Code == f.Value
+			case "parent_id":
+				match = match && acc.ParentID != nil && *acc.ParentID == f.Value
+			}
+		}
+		if match {
+			out = append(out, &acc)
+		}
+	}
+	*(results.(*[]*Account)) = out
+	return nil
+}
+
+// recorder collects published event types
+type recorder struct {
+	types []string
+}
+
+func (r *recorder) Publish(ctx context.Context, e event.Event) error {
+	r.types = append(r.types, e.Type)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	bus := &recorder{}
+	manager := NewManager(&mapRepo{accounts: make(map[string]Account)}, nil, bus)
+	parent := "1000"
+
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "1000", Name: "Assets", Type: Asset}))
+	cash := &Account{Code: "1100", Name: "Cash", Type: Asset, ParentID: &parent}
+	require.NoError(t, manager.CreateAccount(ctx, cash))
+	assert.Equal(t, "1100", cash.ID)
+	assert.Equal(t, Active, cash.Status)
+
+	t.Run("Validation", func(t *testing.T) {
+		err := manager.CreateAccount(ctx, &Account{ID: "other", Code: "1100", Name: "Cash", Type: Asset})
+		assert.ErrorIs(t, err, ErrDuplicateAccountCode)
+
+		err = manager.CreateAccount(ctx, &Account{Code: "4000", Name: "Sales", Type: Revenue, ParentID: &parent})
+		assert.ErrorIs(t, err, ErrInvalidParent)
+
+		missing := "9999"
+		err = manager.CreateAccount(ctx, &Account{Code: "1200", Name: "AR", Type: Asset, ParentID: &missing})
+		assert.ErrorIs(t, err, ErrInvalidParent)
+
+		err = manager.CreateAccount(ctx, &Account{Code: "1300", Name: "Stock", Type: "STOCK"})
+		assert.ErrorIs(t, err, ErrInvalidAccountType)
+
+		// An account cannot become the child of its own descendant
+		root, err := manager.GetAccount(ctx, "1000")
+		require.NoError(t, err)
+		child := "1100"
+		root.ParentID = &child
+		assert.ErrorIs(t, manager.UpdateAccount(ctx, root), ErrInvalidParent)
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		// Closing bypasses the balance and child checks unless done by
+		// CloseAccount
+		root, err := manager.GetAccount(ctx, "1000")
+		require.NoError(t, err)
+		root.Status = Closed
+		assert.ErrorIs(t, manager.UpdateAccount(ctx, root), ErrInvalidOperation)
+
+		require.NoError(t, manager.SetAccountStatus(ctx, "1100", &Status{Locked: true, StatusReason: "audit"}))
+		status, err := manager.GetAccountStatus(ctx, "1100")
+		require.NoError(t, err)
+		assert.True(t, status.Locked)
+		assert.Equal(t, "audit", status.StatusReason)
+
+		assert.ErrorIs(t, manager.DeleteAccount(ctx, "1000"), ErrInvalidOperation)
+		require.NoError(t, manager.DeleteAccount(ctx, "1100"))
+		require.NoError(t, manager.DeleteAccount(ctx, "1000"))
+
+		closed, err := manager.GetAccount(ctx, "1100")
+		require.NoError(t, err)
+		assert.Equal(t, Closed, closed.Status)
+		closed.Status = Active
+		assert.ErrorIs(t, manager.UpdateAccount(ctx, closed), ErrAccountLocked)
+		assert.ErrorIs(t, manager.SetAccountStatus(ctx, "1100", &Status{Active: true}), ErrInvalidTransition)
+	})
+
+	assert.Equal(t, []string{
+		event.AccountCreated, event.AccountCreated,
+		event.AccountStatusChanged, event.AccountClosed, event.AccountClosed,
+	}, bus.types)
+}
+
+func TestCloseAccount(t *testing.T) {
+	ctx := context.Background()
+	repo := &mapRepo{accounts: make(map[string]Account)}
+	manager := NewManager(repo, nil, nil)
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+	usd := func(amount int64) *money.Money {
+		return &money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "1110", Name: "Old Bank", Type: Asset, Balance: usd(250)}))
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "1120", Name: "New Bank", Type: Asset, Balance: usd(1000)}))
+
+	err := manager.CloseAccount(ctx, "1110", CloseOptions{})
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	err = manager.CloseAccount(ctx, "1110", CloseOptions{TransferTo: "1120"})
+	assert.ErrorIs(t, err, ErrInvalidOperation, "a processor is required to transfer")
+
+	manager.SetTransactionProcessor(store, processor)
+	require.NoError(t, manager.CloseAccount(ctx, "1110", CloseOptions{TransferTo: "1120", Reason: "bank switch"}))
+
+	var txs []*transaction.Transaction
+	require.NoError(t, store.Query(ctx, storage.Query{}, &txs))
+	require.Len(t, txs, 1)
+	assert.Equal(t, transaction.Posted, txs[0].Status)
+	entries := txs[0].Entries
+	assert.Equal(t, transaction.Credit, entries[0].Type)
+	assert.Equal(t, "1120", entries[1].AccountID)
+	assert.Equal(t, transaction.Debit, entries[1].Type)
+	assert.True(t, entries[1].Amount.Amount.Equal(decimal.NewFromInt(250)))
+
+	closed, err := manager.GetAccount(ctx, "1110")
+	require.NoError(t, err)
+	assert.Equal(t, Closed, closed.Status)
+	assert.True(t, closed.Balance.IsZero())
+	assert.Equal(t, "bank switch", closed.MetaData[CloseReasonKey])
+	assert.Equal(t, "bank switch", repo.reason)
+
+	target, err := manager.GetAccount(ctx, "1120")
+	require.NoError(t, err)
+	assert.True(t, target.Balance.Amount.Equal(decimal.NewFromInt(1250)))
+
+	t.Run("Postings Blocked", func(t *testing.T) {
+		tx := &transaction.Transaction{Entries: []transaction.Entry{
+			{AccountID: "1120", Amount: *usd(10), Type: transaction.Debit},
+			{AccountID: "1110", Amount: *usd(10), Type: transaction.Credit},
+		}}
+		results, err := NewStatusValidator(repo).Validate(ctx, tx)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "ACCOUNT_CLOSED", results[0].Code)
+		assert.Equal(t, "Entries[1].AccountID", results[0].Field)
+	})
+}
+
+func TestBalanceProjector(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	bus := event.NewMemoryBus()
+	projector := NewBalanceProjector(store)
+	require.NoError(t, projector.Subscribe(bus))
+
+	for _, acc := range []*Account{
+		{ID: "cash", Code: "1000", Name: "Cash", Type: Asset, Status: Active},
+		{ID: "sales", Code: "4000", Name: "Sales", Type: Revenue, Status: Active},
+	} {
+		require.NoError(t, store.Create(ctx, acc))
+	}
+	sale := func(id string, amount int64, debit, credit string) {
+		usd := money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+		require.NoError(t, store.Create(ctx, &transaction.Transaction{
+			ID:     id,
+			Status: transaction.Posted,
+			Entries: []transaction.Entry{
+				{AccountID: debit, Amount: usd, Type: transaction.Debit},
+				{AccountID: credit, Amount: usd, Type: transaction.Credit},
+			},
+		}))
+	}
+	publish := func(eventType string, data event.Payload) {
+		require.NoError(t, bus.Publish(ctx, event.Event{Type: eventType, Data: data}))
+	}
+	balance := func(id string) string {
+		var acc Account
+		require.NoError(t, store.Read(ctx, id, &acc))
+		require.NotNil(t, acc.Balance)
+		return acc.Balance.Amount.String()
+	}
+
+	sale("TX-1", 100, "cash", "sales")
+	publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "TX-1"})
+	publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "TX-1"})
+	assert.Equal(t, "100", balance("cash"))
+	assert.Equal(t, "100", balance("sales"))
+
+	var cash Account
+	require.NoError(t, store.Read(ctx, "cash", &cash))
+	assert.Equal(t, "TX-1", cash.LastTransactionID)
+	assert.Equal(t, "USD", cash.Balance.Currency)
+
+	t.Run("reversals post the reversing transaction", func(t *testing.T) {
+		sale("TX-2", 40, "cash", "sales")
+		sale("REV-TX-2", 40, "sales", "cash")
+		publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "TX-2"})
+		publish(event.TransactionReversed, event.TransactionReversedV1{TransactionID: "TX-2", ReversalID: "REV-TX-2"})
+		publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "REV-TX-2"})
+		assert.Equal(t, "100", balance("cash"))
+		assert.Equal(t, "100", balance("sales"))
+	})
+
+	t.Run("voids take the posting off", func(t *testing.T) {
+		sale("TX-3", 25, "cash", "sales")
+		publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "TX-3"})
+		assert.Equal(t, "125", balance("cash"))
+		publish(event.TransactionVoided, event.TransactionVoidedV1{TransactionID: "TX-3"})
+		publish(event.TransactionVoided, event.TransactionVoidedV1{TransactionID: "TX-3"})
+		assert.Equal(t, "100", balance("cash"))
+	})
+
+	t.Run("voids arriving first skip the posting", func(t *testing.T) {
+		sale("TX-4", 10, "cash", "sales")
+		publish(event.TransactionVoided, event.TransactionVoidedV1{TransactionID: "TX-4"})
+		publish(event.TransactionPosted, event.TransactionPostedV1{TransactionID: "TX-4"})
+		assert.Equal(t, "100", balance("cash"))
+	})
+
+	t.Run("missing transactions fail", func(t *testing.T) {
+		err := projector.Handle(ctx, event.Event{Type: event.TransactionPosted, Data: event.TransactionPostedV1{TransactionID: "TX-404"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestPostingValidator(t *testing.T) {
+	ctx := context.Background()
+	repo := &mapRepo{accounts: make(map[string]Account)}
+	for _, acc := range []*Account{
+		{ID: "1000", Type: Asset, Status: Active},
+		{ID: "1100", Type: Asset, Status: Frozen},
+		{ID: "6000", Type: Expense, Status: Active, MetaData: map[string]interface{}{HeaderKey: true}},
+		{ID: "6100", Type: Expense, Status: Active},
+		{ID: "6200", Type: Expense, Status: Active},
+		{ID: "4000", Type: Revenue, Status: Active},
+	} {
+		require.NoError(t, repo.Create(ctx, acc))
+	}
+	validator := NewPostingValidator(repo)
+	ten := money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}
+
+	entries := func(debit, credit string) *transaction.Transaction {
+		return &transaction.Transaction{Entries: []transaction.Entry{
+			{AccountID: debit, Amount: ten, Type: transaction.Debit},
+			{AccountID: credit, Amount: ten, Type: transaction.Credit},
+		}}
+	}
+
+	results, err := validator.Validate(ctx, entries("6100", "1000"))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Frozen accounts are left to StatusValidator
+	results, err = validator.Validate(ctx, entries("6000", "1100"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "ACCOUNT_NOT_POSTABLE", results[0].Code)
+	assert.Equal(t, "Entries[0].AccountID", results[0].Field)
+
+	// Crediting an expense or debiting revenue only warns
+	results, err = validator.Validate(ctx, entries("4000", "6100"))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "REVENUE_DEBITED", results[0].Code)
+	assert.Equal(t, validation.Warning, results[0].Severity)
+	assert.Equal(t, "EXPENSE_CREDITED", results[1].Code)
+	assert.Equal(t, "Entries[1].Type", results[1].Field)
+
+	// Rules for an account override the rules for its type
+	require.NoError(t, validator.AddValidationRule(ctx, &ValidationRule{
+		ID: "REFUNDS", Type: RuleTypeEntrySide, AccountID: "6100", Side: transaction.Credit, Allow: true,
+	}))
+	require.NoError(t, validator.AddValidationRule(ctx, &ValidationRule{
+		ID: "NO_REVERSALS", Description: "Supplies cannot be credited", Type: RuleTypeEntrySide,
+		AccountID: "6200", Side: transaction.Credit, Blocking: true,
+	}))
+	results, err = validator.Validate(ctx, entries("1000", "6100"))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = validator.Validate(ctx, entries("1000", "6200"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "NO_REVERSALS", results[0].Code)
+	assert.Equal(t, validation.Error, results[0].Severity)
+
+	rules, err := validator.GetValidationRules(ctx, Revenue)
+	require.NoError(t, err)
+	assert.Len(t, rules, 3)
+	require.NoError(t, validator.RemoveValidationRule(ctx, "REVENUE_DEBITED"))
+	assert.ErrorIs(t, validator.RemoveValidationRule(ctx, "REVENUE_DEBITED"), ErrInvalidOperation)
+	assert.ErrorIs(t, validator.AddValidationRule(ctx, &ValidationRule{ID: "X", Type: "balance"}), ErrInvalidOperation)
+}
+
+func TestAccountValidator(t *testing.T) {
+	ctx := context.Background()
+	repo := &mapRepo{accounts: make(map[string]Account)}
+	validator := NewAccountValidator(repo)
+	for accountType, rule := range StandardTypeRules() {
+		validator.SetTypeRule(accountType, rule)
+	}
+	validator.SetTypeRule(Expense, TypeRule{CodePrefixes: []string{"5", "6"}, ParentTypes: []AccountType{Expense, Revenue}})
+
+	// Validators of transactions and accounts share the engine
+	engine := validation.NewBasicValidationEngine()
+	require.NoError(t, engine.RegisterValidator(validation.NewTransactionValidator()))
+	require.NoError(t, engine.RegisterValidator(validator))
+	manager := NewManager(repo, engine, nil)
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "1000", Name: "Assets", Type: Asset}))
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "4000", Name: "Revenue", Type: Revenue}))
+
+	codes := func(acc *Account) []string {
+		results, _ := engine.Validate(ctx, acc)
+		out := make([]string, 0, len(results))
+		for _, r := range results {
+			out = append(out, r.Code)
+		}
+		return out
+	}
+	parent := func(id string) *string { return &id }
+
+	assert.Empty(t, codes(&Account{ID: "1100", Code: "1100", Type: Asset, ParentID: parent("1000"), MetaData: map[string]interface{}{CashKey: true}}))
+	assert.Empty(t, codes(&Account{ID: "6100", Code: "6100", Type: Expense, ParentID: parent("4000")}), "expense rule allows revenue parents")
+	assert.Equal(t, []string{"ACCOUNT_CODE_FORMAT"}, codes(&Account{ID: "1 1", Code: "1 1", Type: Asset}))
+	assert.Equal(t, []string{"ACCOUNT_CODE_RANGE"}, codes(&Account{ID: "2100", Code: "2100", Type: Asset}))
+	assert.Equal(t, []string{"ACCOUNT_FLAG_TYPE"}, codes(&Account{ID: "4100", Code: "4100", Type: Revenue, MetaData: map[string]interface{}{CurrentKey: true}}))
+	assert.Equal(t, []string{"ACCOUNT_PARENT_TYPE"}, codes(&Account{ID: "2100", Code: "2100", Type: Liability, ParentID: parent("1000")}))
+	assert.Equal(t, []string{"ACCOUNT_PARENT_NOT_FOUND"}, codes(&Account{ID: "1200", Code: "1200", Type: Asset, ParentID: parent("1900")}))
+	assert.Equal(t, []string{"ACCOUNT_CODE_DUPLICATE"}, codes(&Account{ID: "cash", Code: "1000", Type: Asset}))
+	assert.Equal(t, []string{"ACCOUNT_TYPE_INVALID"}, codes(&Account{ID: "X", Code: "X", Type: "OTHER"}))
+
+	err := manager.CreateAccount(ctx, &Account{Code: "5.5.", Name: "Bad", Type: Expense})
+	var failed *validation.ValidationError
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, "ACCOUNT_CODE_FORMAT", failed.Results[0].Code)
+
+	// The manager leaves parent types and duplicate codes to the validator
+	require.NoError(t, manager.CreateAccount(ctx, &Account{Code: "6100", Name: "Fees", Type: Expense, ParentID: parent("4000")}))
+	err = manager.CreateAccount(ctx, &Account{Code: "2100", Name: "Payables", Type: Liability, ParentID: parent("1000")})
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, "ACCOUNT_PARENT_TYPE", failed.Results[0].Code)
+	err = manager.CreateAccount(ctx, &Account{ID: "cash", Code: "1000", Name: "Cash", Type: Asset})
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, "ACCOUNT_CODE_DUPLICATE", failed.Results[0].Code)
+	assert.Error(t, validator.SetCodePattern("("))
+}
+
+func TestReferenceValidator(t *testing.T) {
+	ctx := context.Background()
+	repo := &mapRepo{accounts: map[string]Account{
+		"1000":    {ID: "1000", Status: Active},
+		"4000":    {ID: "4000", Status: Active},
+		"1900":    {ID: "1900", Status: Inactive},
+		"2900":    {ID: "2900", Status: Closed},
+		"UK-1000": {ID: "UK-1000", Status: Active, EntityID: "UK"},
+	}}
+	validator := NewReferenceValidator(repo)
+	ten := money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}
+
+	codes := func(ctx context.Context, accountIDs ...string) []string {
+		tx := &transaction.Transaction{ID: "T1"}
+		for _, id := range accountIDs {
+			tx.Entries = append(tx.Entries, transaction.Entry{AccountID: id, Amount: ten, Type: transaction.Debit})
+		}
+		results, err := validator.Validate(ctx, tx)
+		require.NoError(t, err)
+		out := make([]string, 0, len(results))
+		for _, r := range results {
+			out = append(out, fmt.Sprintf("%s %s", r.Field, r.Code))
+		}
+		return out
+	}
+
+	assert.Empty(t, codes(ctx, "1000", "4000"))
+	assert.Equal(t, []string{
+		"Entries[1].AccountID ACCOUNT_NOT_FOUND",
+		"Entries[2].AccountID ACCOUNT_INACTIVE",
+		"Entries[4].AccountID ACCOUNT_REQUIRED",
+	}, codes(ctx, "1000", "9999", "1900", "2900", ""), "closed accounts are left to StatusValidator")
+	assert.Equal(t, []string{"Entries[1].AccountID ACCOUNT_ENTITY_MISMATCH"}, codes(ctx, "1000", "UK-1000"))
+	assert.Equal(t, []string{"Entries[0].AccountID ACCOUNT_ENTITY_MISMATCH"}, codes(entity.WithEntity(ctx, "UK"), "1000", "UK-1000"))
+}
+
+func TestRuleLookup(t *testing.T) {
+	ctx := context.Background()
+	repo := &mapRepo{accounts: map[string]Account{
+		"6000": {ID: "6000", Code: "6000", Type: Expense, Status: Active},
+		"1000": {ID: "1000", Code: "1000", Type: Asset, Status: Active},
+	}}
+	validator := validation.NewRuleValidator(RuleLookup(repo))
+	require.NoError(t, validator.AddRule(validation.RuleDefinition{
+		ID:         "LARGE_EXPENSE",
+		Expression: `amount > 10000 && account.type == "EXPENSE" => require approval`,
+	}))
+
+	amount := money.Money{Amount: decimal.NewFromInt(15000), Currency: "USD"}
+	tx := &transaction.Transaction{ID: "T1", Entries: []transaction.Entry{
+		{AccountID: "6000", Amount: amount, Type: transaction.Debit},
+		{AccountID: "1000", Amount: amount, Type: transaction.Credit},
+		{AccountID: "9999", Amount: amount, Type: transaction.Credit},
+	}}
+	results, err := validator.Validate(ctx, tx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Entries[0]", results[0].Field)
+	assert.Equal(t, []string{"approval"}, validation.Requirements(results))
+}
@@ -0,0 +1,238 @@
+package account
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidChart    = errors.New("invalid chart of accounts")
+	ErrUnknownTemplate = errors.New("unknown chart of accounts template")
+)
+
+// Standard chart of accounts templates
+const (
+	TemplateUSGAAPSmallBusiness = "us-gaap-small-business"
+	TemplateIFRS                = "ifrs"
+	TemplateNonprofit           = "nonprofit"
+)
+
+// csvHeader is the column layout used by ImportCSV and ExportCSV
+var csvHeader = []string{"code", "name", "type", "parent_code"}
+
+// ChartOfAccounts is a complete account structure that can be installed
+// into a repository in one step. Accounts reference their parent by code.
+type ChartOfAccounts struct {
+	Name     string         `json:"name"`
+	Accounts []ChartAccount `json:"accounts"`
+}
+
+// ChartAccount is a single line of a chart of accounts
+type ChartAccount struct {
+	Code       string      `json:"code"`
+	Name       string      `json:"name"`
+	Type       AccountType `json:"type"`
+	ParentCode string      `json:"parent_code,omitempty"`
+}
+
+// Templates returns the names of the standard templates
+func Templates() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Template returns a copy of a standard chart of accounts
+func Template(name string) (*ChartOfAccounts, error) {
+	lines, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	return &ChartOfAccounts{Name: name, Accounts: append([]ChartAccount(nil), lines...)}, nil
+}
+
+// Validate checks that codes are unique, types are known and every parent
+// is part of the chart without forming a cycle
+func (c *ChartOfAccounts) Validate() error {
+	codes := make(map[string]bool, len(c.Accounts))
+	for _, line := range c.Accounts {
+		if line.Code == "" || line.Name == "" {
+			return fmt.Errorf("%w: code and name are required", ErrInvalidChart)
+		}
+		if codes[line.Code] {
+			return fmt.Errorf("%w: duplicate code %s", ErrInvalidChart, line.Code)
+		}
+		codes[line.Code] = true
+		switch line.Type {
+		case Asset, Liability, Equity, Revenue, Expense:
+		default:
+			return fmt.Errorf("%w: %s has type %q", ErrInvalidChart, line.Code, line.Type)
+		}
+	}
+	for _, line := range c.Accounts {
+		if line.ParentCode != "" && !codes[line.ParentCode] {
+			return fmt.Errorf("%w: %s has unknown parent %s", ErrInvalidChart, line.Code, line.ParentCode)
+		}
+	}
+	if _, err := NewHierarchy(c.accounts()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	return nil
+}
+
+// Install validates the chart and creates its accounts, parents first.
+// Account IDs are the account codes.
+func (c *ChartOfAccounts) Install(ctx context.Context, repo Repository) ([]*Account, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	h, err := NewHierarchy(c.accounts())
+	if err != nil {
+		return nil, err
+	}
+
+	var ordered []*Account
+	for _, root := range h.Roots() {
+		ordered = append(ordered, root)
+		ordered = append(ordered, h.Descendants(root.ID)...)
+	}
+
+	now := time.Now()
+	for _, acc := range ordered {
+		acc.Status = Active
+		acc.Created = now
+		acc.LastModified = now
+		if err := repo.Create(ctx, acc); err != nil {
+			return nil, fmt.Errorf("failed to store account %s: %w", acc.Code, err)
+		}
+	}
+	return ordered, nil
+}
+
+// accounts converts the chart lines into accounts keyed by code
+func (c *ChartOfAccounts) accounts() []*Account {
+	out := make([]*Account, 0, len(c.Accounts))
+	for _, line := range c.Accounts {
+		acc := &Account{ID: line.Code, Code: line.Code, Name: line.Name, Type: line.Type}
+		if line.ParentCode != "" {
+			parent := line.ParentCode
+			acc.ParentID = &parent
+		}
+		out = append(out, acc)
+	}
+	return out
+}
+
+// ChartFromAccounts builds a chart from existing accounts, resolving parent
+// IDs to codes
+func ChartFromAccounts(name string, accounts []*Account) *ChartOfAccounts {
+	codes := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		codes[acc.ID] = acc.Code
+	}
+
+	chart := &ChartOfAccounts{Name: name}
+	for _, acc := range accounts {
+		line := ChartAccount{Code: acc.Code, Name: acc.Name, Type: acc.Type}
+		if acc.ParentID != nil {
+			line.ParentCode = codes[*acc.ParentID]
+		}
+		chart.Accounts = append(chart.Accounts, line)
+	}
+	sort.Slice(chart.Accounts, func(i, j int) bool { return chart.Accounts[i].Code < chart.Accounts[j].Code })
+	return chart
+}
+
+// ImportJSON reads a chart of accounts from JSON
+func ImportJSON(r io.Reader) (*ChartOfAccounts, error) {
+	var chart ChartOfAccounts
+	if err := json.NewDecoder(r).Decode(&chart); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChart, err)
+	}
+	if err := chart.Validate(); err != nil {
+		return nil, err
+	}
+	return &chart, nil
+}
+
+// ExportJSON writes the chart of accounts as JSON
+func (c *ChartOfAccounts) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// ImportCSV reads a chart of accounts from CSV with the columns code, name,
+// type and parent_code. A header row is required.
+func ImportCSV(name string, r io.Reader) (*ChartOfAccounts, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalidChart, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, required := range csvHeader[:3] {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidChart, required)
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	chart := &ChartOfAccounts{Name: name}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidChart, line, err)
+		}
+		chart.Accounts = append(chart.Accounts, ChartAccount{
+			Code:       field(record, "code"),
+			Name:       field(record, "name"),
+			Type:       AccountType(strings.ToUpper(field(record, "type"))),
+			ParentCode: field(record, "parent_code"),
+		})
+	}
+	if err := chart.Validate(); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// ExportCSV writes the chart of accounts as CSV with a header row
+func (c *ChartOfAccounts) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, line := range c.Accounts {
+		if err := writer.Write([]string{line.Code, line.Name, string(line.Type), line.ParentCode}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,272 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+)
+
+// Metadata keys recorded on closed accounts
+const (
+	ClosedAtKey      = "closed_at"
+	ClosedByKey      = "closed_by"
+	CloseReasonKey   = "close_reason"
+	TransferredToKey = "transferred_to"
+)
+
+// CloseOptions controls how an account is closed
+type CloseOptions struct {
+	// Account that receives a residual balance. When empty the account
+	// must have a zero balance.
+	TransferTo string
+	// Date of the residual transfer; defaults to now
+	Date time.Time
+	// Justification recorded on the account and in the audit trail
+	Reason string
+}
+
+// CloseAccount implements AccountManager.CloseAccount. The closure is
+// attributed to the caller in the store's audit trail, and closed accounts
+// are rejected by the StatusValidator.
+func (m *BasicManager) CloseAccount(ctx context.Context, id string, opts CloseOptions) error {
+	if err := auth.Require(ctx, auth.AccountClose); err != nil {
+		return err
+	}
+	acc, err := m.GetAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+	if acc.Status == Closed {
+		return nil
+	}
+
+	children, err := m.ListAccounts(ctx, map[string]interface{}{"parent_id": id})
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child.Status != Closed {
+			return fmt.Errorf("%w: account %s has open child account %s", ErrInvalidOperation, id, child.ID)
+		}
+	}
+
+	// Record the reason with the audit entries the store writes
+	caller := audit.CallerFromContext(ctx)
+	if opts.Reason != "" {
+		caller.Reason = opts.Reason
+	}
+	if caller.Source == "" {
+		caller.Source = "account.close"
+	}
+	ctx = audit.WithCaller(ctx, caller)
+
+	if acc.Balance != nil && !acc.Balance.IsZero() {
+		if opts.TransferTo == "" {
+			return fmt.Errorf("%w: account %s has a balance of %s", ErrInvalidOperation, id, acc.Balance.Amount)
+		}
+		if err := m.transferResidual(ctx, acc, opts); err != nil {
+			return err
+		}
+	}
+
+	old := acc.Status
+	now := time.Now()
+	acc.Status = Closed
+	acc.LastModified = now
+	if acc.MetaData == nil {
+		acc.MetaData = make(map[string]interface{})
+	}
+	acc.MetaData[ClosedAtKey] = now.Format(time.RFC3339)
+	acc.MetaData[ClosedByKey] = caller.UserID
+	acc.MetaData[CloseReasonKey] = opts.Reason
+	if opts.TransferTo != "" {
+		acc.MetaData[TransferredToKey] = opts.TransferTo
+	}
+	if err := m.repo.Update(ctx, acc); err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+
+	return m.publish(ctx, event.AccountClosed, acc, event.AccountChangedV1{
+		AccountID: acc.ID,
+		Code:      acc.Code,
+		OldStatus: string(old),
+		NewStatus: string(Closed),
+		Reason:    opts.Reason,
+	})
+}
+
+// transferResidual posts a transaction moving an account's balance to the
+// destination account and updates both stored balances
+func (m *BasicManager) transferResidual(ctx context.Context, acc *Account, opts CloseOptions) error {
+	if m.processor == nil {
+		return fmt.Errorf("%w: no transaction processor to transfer the balance of %s", ErrInvalidOperation, acc.ID)
+	}
+	if opts.TransferTo == acc.ID {
+		return fmt.Errorf("%w: cannot transfer a balance to the account being closed", ErrInvalidOperation)
+	}
+	target, err := m.GetAccount(ctx, opts.TransferTo)
+	if err != nil {
+		return err
+	}
+	if target.Status == Closed || target.Status == Frozen {
+		return fmt.Errorf("%w: transfer account %s is %s", ErrAccountLocked, target.ID, target.Status)
+	}
+
+	// Balances are positive on the account's normal side, so a positive
+	// residual is cleared from the opposite side
+	residual := *acc.Balance
+	side := normalSide(acc.Type).Reverse()
+	if residual.IsNegative() {
+		side = side.Reverse()
+	}
+	amount := residual.Abs()
+
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	now := time.Now()
+	description := fmt.Sprintf("Close account %s", acc.Code)
+	tx := &transaction.Transaction{
+		ID:          fmt.Sprintf("CLOSE-%s-%d", acc.ID, now.UnixNano()),
+		Type:        transaction.Transfer,
+		Status:      transaction.Draft,
+		Date:        date,
+		Description: description,
+		Entries: []transaction.Entry{
+			{AccountID: acc.ID, Amount: amount, Type: side, Description: description},
+			{AccountID: target.ID, Amount: amount, Type: side.Reverse(), Description: description},
+		},
+		EntityID:     acc.EntityID,
+		Created:      now,
+		LastModified: now,
+	}
+	if err := transaction.CreateAndPost(ctx, m.transactions, m.processor, tx); err != nil {
+		return fmt.Errorf("failed to transfer balance of %s: %w", acc.ID, err)
+	}
+
+	targetChange := amount
+	if side.Reverse() != normalSide(target.Type) {
+		targetChange = amount.Multiply(decimal.NewFromInt(-1))
+	}
+	if target.Balance == nil {
+		target.Balance = &money.Money{Amount: decimal.Zero, Currency: amount.Currency}
+	}
+	updated, err := target.Balance.Add(targetChange)
+	if err != nil {
+		return fmt.Errorf("failed to update balance of account %s: %w", target.ID, err)
+	}
+	target.Balance = &updated
+	target.LastModified = now
+	if err := m.repo.Update(ctx, target); err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+
+	zero := money.Money{Amount: decimal.Zero, Currency: amount.Currency}
+	acc.Balance = &zero
+	return nil
+}
+
+// normalSide returns the entry type that increases an account of a type
+func normalSide(t AccountType) transaction.EntryType {
+	if t == Asset || t == Expense {
+		return transaction.Debit
+	}
+	return transaction.Credit
+}
+
+// StatusValidator rejects transactions that post to closed or frozen
+// accounts
+type StatusValidator struct {
+	repo Repository
+}
+
+// NewStatusValidator creates a validator reading account status from a
+// repository
+func NewStatusValidator(repo Repository) *StatusValidator {
+	return &StatusValidator{repo: repo}
+}
+
+// Validate implements validation.Validator
+func (v *StatusValidator) Validate(ctx context.Context, obj interface{}) ([]validation.ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	var results []validation.ValidationResult
+	seen := make(map[string]bool)
+	for i, entry := range tx.Entries {
+		if seen[entry.AccountID] {
+			continue
+		}
+		seen[entry.AccountID] = true
+
+		acc, err := readAccount(ctx, v.repo, entry.AccountID)
+		if err != nil {
+			// Unknown accounts are reported by other validators
+			continue
+		}
+
+		if result, ok := statusResult(acc, i); ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// statusResult reports an entry posting to a closed or frozen account
+func statusResult(acc *Account, entry int) (validation.ValidationResult, bool) {
+	code := ""
+	switch acc.Status {
+	case Closed:
+		code = "ACCOUNT_CLOSED"
+	case Frozen:
+		code = "ACCOUNT_FROZEN"
+	default:
+		return validation.ValidationResult{}, false
+	}
+	return validation.ValidationResult{
+		Code:     code,
+		Message:  fmt.Sprintf("account %s is %s", acc.ID, acc.Status),
+		Severity: validation.Error,
+		Field:    fmt.Sprintf("Entries[%d].AccountID", entry),
+	}, true
+}
+
+// Supports implements validation.ObjectFilter; only transactions are checked
+func (v *StatusValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements validation.Validator
+func (v *StatusValidator) GetRules() []validation.ValidationRule {
+	return []validation.ValidationRule{
+		{
+			ID:          "ACCOUNT_CLOSED",
+			Description: "Transactions cannot post to closed accounts",
+			Severity:    validation.Error,
+			Category:    "ACCOUNT",
+		},
+		{
+			ID:          "ACCOUNT_FROZEN",
+			Description: "Transactions cannot post to frozen accounts",
+			Severity:    validation.Error,
+			Category:    "ACCOUNT",
+		},
+	}
+}
+
+// Priority implements validation.Validator; status checks run early
+func (v *StatusValidator) Priority() int {
+	return 20
+}
@@ -0,0 +1,254 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// HierarchyManager walks and aggregates the parent/child structure of the
+// chart of accounts
+type HierarchyManager interface {
+	// GetChildren returns the direct children of an account ordered by code
+	GetChildren(ctx context.Context, id string) ([]*Account, error)
+
+	// GetDescendants returns every account below an account, depth first
+	GetDescendants(ctx context.Context, id string) ([]*Account, error)
+
+	// GetAncestors returns the parents of an account, nearest first
+	GetAncestors(ctx context.Context, id string) ([]*Account, error)
+
+	// GetRollup returns an account's balance tree with totals rolled up
+	// from its descendants
+	GetRollup(ctx context.Context, id string) (*RollupNode, error)
+}
+
+// BalanceFunc returns the balance of a single account for roll-ups
+type BalanceFunc func(ctx context.Context, acc *Account) (money.Money, error)
+
+// RollupNode is an account in a roll-up tree
+type RollupNode struct {
+	Account *Account `json:"account"`
+	// Balance of the account itself
+	Balance money.Money `json:"balance"`
+	// Balance of the account and all of its descendants
+	Total    money.Money   `json:"total"`
+	Children []*RollupNode `json:"children,omitempty"`
+}
+
+// Hierarchy is an in-memory index of accounts by parent
+type Hierarchy struct {
+	accounts map[string]*Account
+	children map[string][]*Account
+	roots    []*Account
+}
+
+// NewHierarchy indexes a set of accounts. Accounts whose parent is not in
+// the set are treated as roots; cycles are rejected.
+func NewHierarchy(accounts []*Account) (*Hierarchy, error) {
+	h := &Hierarchy{
+		accounts: make(map[string]*Account, len(accounts)),
+		children: make(map[string][]*Account),
+	}
+	for _, acc := range accounts {
+		h.accounts[acc.ID] = acc
+	}
+	for _, acc := range accounts {
+		if acc.ParentID != nil && *acc.ParentID != "" && h.accounts[*acc.ParentID] != nil {
+			h.children[*acc.ParentID] = append(h.children[*acc.ParentID], acc)
+		} else {
+			h.roots = append(h.roots, acc)
+		}
+	}
+	for _, list := range h.children {
+		sortByCode(list)
+	}
+	sortByCode(h.roots)
+
+	for _, acc := range accounts {
+		if _, err := h.Ancestors(acc.ID); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Account returns an account by ID
+func (h *Hierarchy) Account(id string) (*Account, bool) {
+	acc, ok := h.accounts[id]
+	return acc, ok
+}
+
+// Roots returns the accounts without a parent
+func (h *Hierarchy) Roots() []*Account {
+	return append([]*Account(nil), h.roots...)
+}
+
+// Children returns the direct children of an account
+func (h *Hierarchy) Children(id string) []*Account {
+	return append([]*Account(nil), h.children[id]...)
+}
+
+// Descendants returns every account below an account, depth first
+func (h *Hierarchy) Descendants(id string) []*Account {
+	var out []*Account
+	for _, child := range h.children[id] {
+		out = append(out, child)
+		out = append(out, h.Descendants(child.ID)...)
+	}
+	return out
+}
+
+// Ancestors returns the parents of an account, nearest first
+func (h *Hierarchy) Ancestors(id string) ([]*Account, error) {
+	acc, ok := h.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, id)
+	}
+
+	var out []*Account
+	seen := map[string]bool{id: true}
+	for acc.ParentID != nil {
+		parent, ok := h.accounts[*acc.ParentID]
+		if !ok {
+			break
+		}
+		if seen[parent.ID] {
+			return nil, fmt.Errorf("%w: account hierarchy has a cycle through %s", ErrInvalidOperation, parent.ID)
+		}
+		seen[parent.ID] = true
+		out = append(out, parent)
+		acc = parent
+	}
+	return out, nil
+}
+
+// Depth returns how many ancestors an account has
+func (h *Hierarchy) Depth(id string) int {
+	ancestors, _ := h.Ancestors(id)
+	return len(ancestors)
+}
+
+// Rollup builds the balance tree below an account. All balances in the
+// tree must share a currency.
+func (h *Hierarchy) Rollup(ctx context.Context, id string, balance BalanceFunc) (*RollupNode, error) {
+	acc, ok := h.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, id)
+	}
+
+	own, err := balance(ctx, acc)
+	if err != nil {
+		return nil, fmt.Errorf("error getting balance for account %s: %w", id, err)
+	}
+	node := &RollupNode{Account: acc, Balance: own, Total: own}
+	for _, child := range h.children[id] {
+		childNode, err := h.Rollup(ctx, child.ID, balance)
+		if err != nil {
+			return nil, err
+		}
+		// Accounts without a balance carry no currency and add nothing
+		if childNode.Total.Currency == "" {
+			node.Children = append(node.Children, childNode)
+			continue
+		}
+		if node.Total.Currency == "" {
+			node.Total.Currency = childNode.Total.Currency
+		}
+		if node.Total, err = node.Total.Add(childNode.Total); err != nil {
+			return nil, fmt.Errorf("cannot roll up %s into %s: %w", child.ID, id, err)
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+// CurrentBalance is a BalanceFunc reading the balance stored on the account
+func CurrentBalance(ctx context.Context, acc *Account) (money.Money, error) {
+	if acc.Balance == nil {
+		return money.Money{Amount: decimal.Zero}, nil
+	}
+	return *acc.Balance, nil
+}
+
+// BasicHierarchyManager implements HierarchyManager over an account
+// repository, loading the chart of accounts for each call
+type BasicHierarchyManager struct {
+	repo    Repository
+	balance BalanceFunc
+}
+
+// NewHierarchyManager creates a hierarchy manager. Roll-ups use the balance
+// stored on each account unless a BalanceFunc is supplied.
+func NewHierarchyManager(repo Repository, balance BalanceFunc) *BasicHierarchyManager {
+	if balance == nil {
+		balance = CurrentBalance
+	}
+	return &BasicHierarchyManager{repo: repo, balance: balance}
+}
+
+// Load indexes the chart of accounts visible in the context
+func (m *BasicHierarchyManager) Load(ctx context.Context) (*Hierarchy, error) {
+	var accounts []*Account
+	if err := m.repo.Query(ctx, entity.ScopeQuery(ctx, storage.Query{}), &accounts); err != nil {
+		return nil, fmt.Errorf("error querying accounts: %w", err)
+	}
+	return NewHierarchy(accounts)
+}
+
+// GetChildren implements HierarchyManager.GetChildren
+func (m *BasicHierarchyManager) GetChildren(ctx context.Context, id string) ([]*Account, error) {
+	h, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Children(id), nil
+}
+
+// GetDescendants implements HierarchyManager.GetDescendants
+func (m *BasicHierarchyManager) GetDescendants(ctx context.Context, id string) ([]*Account, error) {
+	h, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Descendants(id), nil
+}
+
+// GetAncestors implements HierarchyManager.GetAncestors
+func (m *BasicHierarchyManager) GetAncestors(ctx context.Context, id string) ([]*Account, error) {
+	h, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Ancestors(id)
+}
+
+// GetRollup implements HierarchyManager.GetRollup
+func (m *BasicHierarchyManager) GetRollup(ctx context.Context, id string) (*RollupNode, error) {
+	h, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.Rollup(ctx, id, m.balance)
+}
+
+// load indexes the chart and checks the account exists
+func (m *BasicHierarchyManager) load(ctx context.Context, id string) (*Hierarchy, error) {
+	h, err := m.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := h.Account(id); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, id)
+	}
+	return h, nil
+}
+
+func sortByCode(accounts []*Account) {
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+}
@@ -0,0 +1,399 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrDuplicateAccountCode = errors.New("duplicate account code")
+	ErrInvalidParent        = errors.New("invalid parent account")
+	ErrInvalidTransition    = errors.New("invalid account status transition")
+)
+
+// statusReasonKey is the metadata key holding the reason for the last
+// status change
+const statusReasonKey = "status_reason"
+
+// transitions lists the statuses an account may move to from each status.
+// Closed accounts cannot be reopened.
+var transitions = map[AccountStatus][]AccountStatus{
+	Active:   {Inactive, Frozen, Closed},
+	Inactive: {Active, Frozen, Closed},
+	Frozen:   {Active, Inactive, Closed},
+}
+
+// CanTransition reports whether an account may move between two statuses
+func CanTransition(from, to AccountStatus) bool {
+	if from == to {
+		return true
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// BasicManager implements AccountManager over an account repository
+type BasicManager struct {
+	repo       Repository
+	validation validation.ValidationEngine
+	bus        event.Publisher
+	// Optional store and processor used to post residual balance transfers
+	// on close
+	transactions storage.Repository
+	processor    transaction.TransactionProcessor
+}
+
+// NewManager creates an account manager. The validation engine and event
+// bus are optional.
+func NewManager(repo Repository, validationEngine validation.ValidationEngine, eventBus event.Publisher) *BasicManager {
+	return &BasicManager{
+		repo:       repo,
+		validation: validationEngine,
+		bus:        eventBus,
+	}
+}
+
+// SetTransactionProcessor sets the transaction store and processor used to
+// post residual balance transfers when accounts are closed
+func (m *BasicManager) SetTransactionProcessor(transactions storage.Repository, processor transaction.TransactionProcessor) {
+	m.transactions = transactions
+	m.processor = processor
+}
+
+// CreateAccount implements AccountManager.CreateAccount. The ID defaults to
+// the code and the status to active.
+func (m *BasicManager) CreateAccount(ctx context.Context, acc *Account) error {
+	if err := auth.Require(ctx, auth.AccountCreate); err != nil {
+		return err
+	}
+	if acc != nil && acc.ID == "" {
+		acc.ID = acc.Code
+	}
+	if acc != nil && acc.Status == "" {
+		acc.Status = Active
+	}
+	if err := m.ValidateAccount(ctx, acc); err != nil {
+		return err
+	}
+	if err := entity.CheckScope(ctx, acc.EntityID); err != nil {
+		return err
+	}
+	if acc.EntityID == "" {
+		acc.EntityID, _ = entity.FromContext(ctx)
+	}
+
+	now := time.Now()
+	acc.Created = now
+	acc.LastModified = now
+	if err := m.repo.Create(ctx, acc); err != nil {
+		return fmt.Errorf("failed to store account: %w", err)
+	}
+
+	return m.publish(ctx, event.AccountCreated, acc, event.AccountCreatedV1{
+		AccountID: acc.ID,
+		Code:      acc.Code,
+		Status:    string(acc.Status),
+	})
+}
+
+// GetAccount implements AccountManager.GetAccount. Accounts of another
+// entity than the context's are refused, which also guards every change
+// made through the manager.
+func (m *BasicManager) GetAccount(ctx context.Context, id string) (*Account, error) {
+	var acc Account
+	if err := m.repo.Read(ctx, id, &acc); err != nil {
+		if errors.Is(err, storage.ErrTenantMismatch) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s: %v", ErrAccountNotFound, id, err)
+	}
+	if err := entity.CheckStored(ctx, acc.EntityID); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// UpdateAccount implements AccountManager.UpdateAccount. Status changes must
+// follow the allowed transitions, except closing, which goes through
+// CloseAccount so balances and child accounts are checked. An account read at an older version than
+// the stored one is rejected with a wrapped *storage.OptimisticLockError.
+func (m *BasicManager) UpdateAccount(ctx context.Context, acc *Account) error {
+	if err := auth.Require(ctx, auth.AccountUpdate); err != nil {
+		return err
+	}
+	if acc == nil {
+		return fmt.Errorf("%w: account cannot be nil", ErrInvalidOperation)
+	}
+	existing, err := m.GetAccount(ctx, acc.ID)
+	if err != nil {
+		return err
+	}
+	if acc.Status == "" {
+		acc.Status = existing.Status
+	}
+	if existing.Status == Closed {
+		return fmt.Errorf("%w: account %s is closed", ErrAccountLocked, acc.ID)
+	}
+	if acc.Status == Closed {
+		return fmt.Errorf("%w: accounts are closed with CloseAccount", ErrInvalidOperation)
+	}
+	if !CanTransition(existing.Status, acc.Status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, existing.Status, acc.Status)
+	}
+	if err := m.ValidateAccount(ctx, acc); err != nil {
+		return err
+	}
+
+	acc.Created = existing.Created
+	acc.LastModified = time.Now()
+	if err := m.repo.Update(ctx, acc); err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+
+	eventType := event.AccountUpdated
+	if existing.Status != acc.Status {
+		eventType = event.AccountStatusChanged
+	}
+	return m.publish(ctx, eventType, acc, event.AccountChangedV1{
+		AccountID: acc.ID,
+		Code:      acc.Code,
+		OldStatus: string(existing.Status),
+		NewStatus: string(acc.Status),
+	})
+}
+
+// DeleteAccount implements AccountManager.DeleteAccount. Accounts are never
+// removed; they are closed once they have no balance and no open children.
+func (m *BasicManager) DeleteAccount(ctx context.Context, id string) error {
+	return m.CloseAccount(ctx, id, CloseOptions{})
+}
+
+// GetAccountStatus implements AccountManager.GetAccountStatus
+func (m *BasicManager) GetAccountStatus(ctx context.Context, id string) (*Status, error) {
+	acc, err := m.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, _ := acc.MetaData[statusReasonKey].(string)
+	return &Status{
+		Active:       acc.Status == Active,
+		Locked:       acc.Status == Frozen || acc.Status == Closed,
+		StatusReason: reason,
+		LastUpdated:  acc.LastModified,
+	}, nil
+}
+
+// SetAccountStatus implements AccountManager.SetAccountStatus. Locked
+// accounts are frozen, otherwise the account is made active or inactive.
+func (m *BasicManager) SetAccountStatus(ctx context.Context, id string, status *Status) error {
+	if err := auth.Require(ctx, auth.AccountUpdate); err != nil {
+		return err
+	}
+	if status == nil {
+		return fmt.Errorf("%w: status cannot be nil", ErrInvalidOperation)
+	}
+	acc, err := m.GetAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	next := Inactive
+	switch {
+	case status.Locked:
+		next = Frozen
+	case status.Active:
+		next = Active
+	}
+	if !CanTransition(acc.Status, next) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, acc.Status, next)
+	}
+
+	old := acc.Status
+	acc.Status = next
+	if acc.MetaData == nil {
+		acc.MetaData = make(map[string]interface{})
+	}
+	acc.MetaData[statusReasonKey] = status.StatusReason
+	acc.LastModified = time.Now()
+	if err := m.repo.Update(ctx, acc); err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+	return m.publish(ctx, event.AccountStatusChanged, acc, event.AccountChangedV1{
+		AccountID: acc.ID,
+		Code:      acc.Code,
+		OldStatus: string(old),
+		NewStatus: string(next),
+		Reason:    status.StatusReason,
+	})
+}
+
+// GetAccountBalance implements AccountManager.GetAccountBalance
+func (m *BasicManager) GetAccountBalance(ctx context.Context, id string) (*Balance, error) {
+	acc, err := m.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &Balance{
+		AccountID:         acc.ID,
+		AsOf:              acc.LastModified,
+		Amount:            decimal.Zero.String(),
+		LastTransactionID: acc.LastTransactionID,
+	}
+	if acc.Balance != nil {
+		balance.Amount = acc.Balance.Amount.String()
+		balance.Currency = acc.Balance.Currency
+	}
+	return balance, nil
+}
+
+// ValidateAccount implements AccountManager.ValidateAccount. It checks the
+// account's fields, that its code is unique and that its parent is an open
+// account of the same type, then runs the validation engine. When an
+// AccountValidator is registered with the engine, code uniqueness and
+// parent types are left to it, so its type rules decide which parents are
+// allowed.
+func (m *BasicManager) ValidateAccount(ctx context.Context, acc *Account) error {
+	if acc == nil {
+		return fmt.Errorf("%w: account cannot be nil", ErrInvalidOperation)
+	}
+	if acc.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidAccountCode)
+	}
+	if acc.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidOperation)
+	}
+	switch acc.Type {
+	case Asset, Liability, Equity, Revenue, Expense:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidAccountType, acc.Type)
+	}
+	if _, ok := transitions[acc.Status]; !ok && acc.Status != Closed {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidOperation, acc.Status)
+	}
+
+	ruled := m.hasAccountValidator()
+	if !ruled {
+		sameCode, err := m.ListAccounts(ctx, map[string]interface{}{"code": acc.Code})
+		if err != nil {
+			return err
+		}
+		for _, other := range sameCode {
+			if other.ID != acc.ID {
+				return fmt.Errorf("%w: %s", ErrDuplicateAccountCode, acc.Code)
+			}
+		}
+	}
+
+	if err := m.validateParent(ctx, acc, !ruled); err != nil {
+		return err
+	}
+
+	if m.validation != nil {
+		if _, err := m.validation.Validate(ctx, acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAccounts implements AccountManager.ListAccounts. Each filter matches
+// a field exactly; results are ordered by code.
+func (m *BasicManager) ListAccounts(ctx context.Context, filters map[string]interface{}) ([]*Account, error) {
+	query := storage.Query{
+		Sort: []storage.Sort{{Field: "code", Desc: false}},
+	}
+	for field, value := range filters {
+		query.Filters = append(query.Filters, storage.Filter{Field: field, Operator: "=", Value: value})
+	}
+
+	var accounts []*Account
+	if err := m.repo.Query(ctx, entity.ScopeQuery(ctx, query), &accounts); err != nil {
+		return nil, fmt.Errorf("error querying accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// hasAccountValidator reports whether an AccountValidator is registered
+// with the validation engine
+func (m *BasicManager) hasAccountValidator() bool {
+	if m.validation == nil {
+		return false
+	}
+	for _, v := range m.validation.GetValidators() {
+		if _, ok := v.(*AccountValidator); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateParent checks that the parent exists, is open and is not the
+// account itself or one of its descendants, and with sameType that it has
+// the account's type
+func (m *BasicManager) validateParent(ctx context.Context, acc *Account, sameType bool) error {
+	if acc.ParentID == nil || *acc.ParentID == "" {
+		return nil
+	}
+
+	parentID := *acc.ParentID
+	for seen := map[string]bool{}; parentID != ""; {
+		if parentID == acc.ID || seen[parentID] {
+			return fmt.Errorf("%w: %s would create a cycle", ErrInvalidParent, *acc.ParentID)
+		}
+		seen[parentID] = true
+
+		parent, err := m.GetAccount(ctx, parentID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidParent, err)
+		}
+		if parentID == *acc.ParentID {
+			if sameType && parent.Type != acc.Type {
+				return fmt.Errorf("%w: %s account cannot be a child of %s account %s", ErrInvalidParent, acc.Type, parent.Type, parent.ID)
+			}
+			if parent.Status == Closed {
+				return fmt.Errorf("%w: parent %s is closed", ErrInvalidParent, parent.ID)
+			}
+		}
+
+		parentID = ""
+		if parent.ParentID != nil {
+			parentID = *parent.ParentID
+		}
+	}
+	return nil
+}
+
+// publish sends an account event when an event bus is configured
+func (m *BasicManager) publish(ctx context.Context, eventType string, acc *Account, data event.Payload) error {
+	if m.bus == nil {
+		return nil
+	}
+
+	e := event.Event{
+		ID:        fmt.Sprintf("%s-%s-%d", acc.ID, eventType, acc.LastModified.UnixNano()),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Source:    "account",
+		Data:      data,
+	}
+	if err := m.bus.Publish(ctx, e); err != nil {
+		return fmt.Errorf("account %s saved but event %s was not published: %w", acc.ID, eventType, err)
+	}
+	return nil
+}
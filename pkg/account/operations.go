@@ -41,6 +41,10 @@ type AccountManager interface {
 
 	// ListAccounts retrieves accounts based on filters
 	ListAccounts(ctx context.Context, filters map[string]interface{}) ([]*Account, error)
+
+	// CloseAccount closes an account, first transferring any residual
+	// balance when the options name a destination account
+	CloseAccount(ctx context.Context, id string, opts CloseOptions) error
 }
 
 // ValidationManager defines the interface for account validation operations
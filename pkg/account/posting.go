@@ -0,0 +1,225 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+)
+
+// RuleTypeEntrySide is the ValidationRule type checking the side entries
+// are posted on
+const RuleTypeEntrySide = "entry_side"
+
+// DefaultPostingRules returns the entry side rules a PostingValidator starts
+// with: warnings for entries reducing an expense or revenue account, which
+// are usually refunds or corrections
+func DefaultPostingRules() []ValidationRule {
+	return []ValidationRule{
+		{
+			ID:          "EXPENSE_CREDITED",
+			Description: "Crediting an expense account is unusual",
+			Type:        RuleTypeEntrySide,
+			AccountType: Expense,
+			Side:        transaction.Credit,
+		},
+		{
+			ID:          "REVENUE_DEBITED",
+			Description: "Debiting a revenue account is unusual",
+			Type:        RuleTypeEntrySide,
+			AccountType: Revenue,
+			Side:        transaction.Debit,
+		},
+	}
+}
+
+// PostingValidator loads the accounts a transaction posts to, rejects
+// entries to header accounts and checks the side of each entry against
+// entry side rules. Closed and frozen accounts are left to StatusValidator.
+// Entry side rules are configured per account or per account type; blocking
+// rules report errors and the others warnings.
+type PostingValidator struct {
+	mu    sync.RWMutex
+	repo  Repository
+	rules []ValidationRule
+}
+
+// NewPostingValidator creates a validator reading accounts from a
+// repository, starting with DefaultPostingRules
+func NewPostingValidator(repo Repository) *PostingValidator {
+	return &PostingValidator{
+		repo:  repo,
+		rules: DefaultPostingRules(),
+	}
+}
+
+// AddValidationRule adds an entry side rule, replacing any rule with the
+// same ID
+func (v *PostingValidator) AddValidationRule(ctx context.Context, rule *ValidationRule) error {
+	if rule == nil || rule.ID == "" {
+		return fmt.Errorf("%w: rule ID is required", ErrInvalidOperation)
+	}
+	if rule.Type != RuleTypeEntrySide {
+		return fmt.Errorf("%w: unsupported rule type %q", ErrInvalidOperation, rule.Type)
+	}
+	if rule.Side != transaction.Debit && rule.Side != transaction.Credit {
+		return fmt.Errorf("%w: rule %s must check debits or credits", ErrInvalidOperation, rule.ID)
+	}
+	if rule.AccountID == "" && rule.AccountType == "" {
+		return fmt.Errorf("%w: rule %s must name an account or account type", ErrInvalidOperation, rule.ID)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.rules {
+		if v.rules[i].ID == rule.ID {
+			v.rules[i] = *rule
+			return nil
+		}
+	}
+	v.rules = append(v.rules, *rule)
+	return nil
+}
+
+// RemoveValidationRule removes a rule by ID
+func (v *PostingValidator) RemoveValidationRule(ctx context.Context, ruleID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.rules {
+		if v.rules[i].ID == ruleID {
+			v.rules = append(v.rules[:i], v.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no rule %s", ErrInvalidOperation, ruleID)
+}
+
+// GetValidationRules returns the rules applying to accounts of a type,
+// including those naming a single account
+func (v *PostingValidator) GetValidationRules(ctx context.Context, accountType AccountType) ([]*ValidationRule, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	rules := make([]*ValidationRule, 0)
+	for i := range v.rules {
+		if v.rules[i].AccountType == accountType || v.rules[i].AccountID != "" {
+			rule := v.rules[i]
+			rules = append(rules, &rule)
+		}
+	}
+	return rules, nil
+}
+
+// Validate implements validation.Validator
+func (v *PostingValidator) Validate(ctx context.Context, obj interface{}) ([]validation.ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var results []validation.ValidationResult
+	accounts := make(map[string]*Account)
+	for i, entry := range tx.Entries {
+		acc, seen := accounts[entry.AccountID]
+		if !seen {
+			if loaded, err := readAccount(ctx, v.repo, entry.AccountID); err == nil {
+				acc = loaded
+			}
+			accounts[entry.AccountID] = acc
+		}
+		if acc == nil {
+			// Unknown accounts are reported by other validators
+			continue
+		}
+
+		if !seen && acc.IsHeader() {
+			results = append(results, validation.ValidationResult{
+				Code:     "ACCOUNT_NOT_POSTABLE",
+				Message:  fmt.Sprintf("account %s is a header account", acc.ID),
+				Severity: validation.Error,
+				Field:    fmt.Sprintf("Entries[%d].AccountID", i),
+			})
+		}
+
+		rule := v.sideRule(acc, entry.Type)
+		if rule == nil || rule.Allow {
+			continue
+		}
+		severity := validation.Warning
+		if rule.Blocking {
+			severity = validation.Error
+		}
+		results = append(results, validation.ValidationResult{
+			Code:     rule.ID,
+			Message:  fmt.Sprintf("%s: %s %s", rule.Description, entry.Type, acc.ID),
+			Severity: severity,
+			Field:    fmt.Sprintf("Entries[%d].Type", i),
+		})
+	}
+	return results, nil
+}
+
+// sideRule returns the entry side rule applying to an entry on an account,
+// preferring rules that name the account over rules for its type
+func (v *PostingValidator) sideRule(acc *Account, side transaction.EntryType) *ValidationRule {
+	var byType *ValidationRule
+	for i := range v.rules {
+		rule := &v.rules[i]
+		if rule.Side != side {
+			continue
+		}
+		if rule.AccountID == acc.ID {
+			return rule
+		}
+		if byType == nil && rule.AccountID == "" && rule.AccountType == acc.Type {
+			byType = rule
+		}
+	}
+	return byType
+}
+
+// Supports implements validation.ObjectFilter; only transactions are checked
+func (v *PostingValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements validation.Validator
+func (v *PostingValidator) GetRules() []validation.ValidationRule {
+	rules := []validation.ValidationRule{
+		{
+			ID:          "ACCOUNT_NOT_POSTABLE",
+			Description: "Transactions cannot post to header accounts",
+			Severity:    validation.Error,
+			Category:    "ACCOUNT",
+		},
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, rule := range v.rules {
+		if rule.Allow {
+			continue
+		}
+		severity := validation.Warning
+		if rule.Blocking {
+			severity = validation.Error
+		}
+		rules = append(rules, validation.ValidationRule{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Severity:    severity,
+			Category:    "ACCOUNT",
+		})
+	}
+	return rules
+}
+
+// Priority implements validation.Validator; account checks run early
+func (v *PostingValidator) Priority() int {
+	return 20
+}
@@ -0,0 +1,186 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// projection records that a transaction's effect on balances has been
+// projected, so redelivered events are not applied twice
+type projection struct {
+	ID            string    `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	Voided        bool      `json:"voided"`
+	Updated       time.Time `json:"updated"`
+}
+
+func (p *projection) GetID() string { return p.ID }
+
+func projectionID(txID string) string { return "balance-projection:" + txID }
+
+// BalanceProjector keeps the stored balances of accounts up to date from
+// transaction events, so GetAccountBalance reads a single account instead
+// of scanning transactions. Accounts, transactions and the projector's
+// bookkeeping live in one repository.
+//
+// Balances should be maintained either by a projector or by the code
+// posting transactions, not both.
+type BalanceProjector struct {
+	repo      storage.Repository
+	txManager storage.TransactionManager
+	now       func() time.Time
+}
+
+// NewBalanceProjector creates a projector over a repository
+func NewBalanceProjector(repo storage.Repository) *BalanceProjector {
+	return &BalanceProjector{repo: repo, now: time.Now}
+}
+
+// SetTransactionManager sets the manager used to update the balances of a
+// transaction's accounts in one storage transaction
+func (p *BalanceProjector) SetTransactionManager(manager storage.TransactionManager) {
+	p.txManager = manager
+}
+
+// SetClock sets the clock used to time balance updates
+func (p *BalanceProjector) SetClock(now func() time.Time) {
+	p.now = now
+}
+
+// Subscribe registers the projector for the events that change balances
+func (p *BalanceProjector) Subscribe(bus event.Bus) error {
+	for _, eventType := range []string{event.TransactionPosted, event.TransactionVoided, event.TransactionReversed} {
+		if err := bus.Subscribe(eventType, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle implements event.Handler. Posted transactions are added to the
+// balances of their accounts and voided ones taken off again; a reversal
+// is applied as the posting of the reversing transaction.
+func (p *BalanceProjector) Handle(ctx context.Context, e event.Event) error {
+	switch data := e.Data.(type) {
+	case event.TransactionPostedV1:
+		return p.Post(ctx, data.TransactionID)
+	case event.TransactionReversedV1:
+		return p.Post(ctx, data.ReversalID)
+	case event.TransactionVoidedV1:
+		return p.Void(ctx, data.TransactionID)
+	}
+	switch e.Type {
+	case event.TransactionPosted, event.TransactionVoided, event.TransactionReversed:
+		return fmt.Errorf("unexpected %s payload %T", e.Type, e.Data)
+	}
+	return nil
+}
+
+// Post adds a transaction to the balances of its accounts. Transactions
+// already posted or voided are skipped.
+func (p *BalanceProjector) Post(ctx context.Context, txID string) error {
+	return p.atomically(ctx, func(ctx context.Context) error {
+		var marker projection
+		if err := p.repo.Read(ctx, projectionID(txID), &marker); err == nil {
+			return nil
+		}
+		if err := p.apply(ctx, txID, decimal.NewFromInt(1)); err != nil {
+			return err
+		}
+		marker = projection{ID: projectionID(txID), TransactionID: txID, Updated: p.now()}
+		if err := p.repo.Create(ctx, &marker); err != nil {
+			return fmt.Errorf("failed to store balance projection: %w", err)
+		}
+		return nil
+	})
+}
+
+// Void takes a posted transaction off the balances of its accounts. A void
+// arriving before the posting is recorded so the posting is skipped.
+func (p *BalanceProjector) Void(ctx context.Context, txID string) error {
+	return p.atomically(ctx, func(ctx context.Context) error {
+		var marker projection
+		if err := p.repo.Read(ctx, projectionID(txID), &marker); err != nil {
+			marker = projection{ID: projectionID(txID), TransactionID: txID, Voided: true, Updated: p.now()}
+			if err := p.repo.Create(ctx, &marker); err != nil {
+				return fmt.Errorf("failed to store balance projection: %w", err)
+			}
+			return nil
+		}
+		if marker.Voided {
+			return nil
+		}
+		if err := p.apply(ctx, txID, decimal.NewFromInt(-1)); err != nil {
+			return err
+		}
+		marker.Voided = true
+		marker.Updated = p.now()
+		if err := p.repo.Update(ctx, &marker); err != nil {
+			return fmt.Errorf("failed to store balance projection: %w", err)
+		}
+		return nil
+	})
+}
+
+// apply adds each entry of a transaction, times sign, to its account's
+// balance on the account's normal side
+func (p *BalanceProjector) apply(ctx context.Context, txID string, sign decimal.Decimal) error {
+	var tx transaction.Transaction
+	if err := p.repo.Read(ctx, txID, &tx); err != nil {
+		return fmt.Errorf("failed to read transaction %s: %w", txID, err)
+	}
+
+	// Entries are grouped so each account is written once
+	var order []string
+	changes := make(map[string][]transaction.Entry)
+	for _, entry := range tx.Entries {
+		if _, ok := changes[entry.AccountID]; !ok {
+			order = append(order, entry.AccountID)
+		}
+		changes[entry.AccountID] = append(changes[entry.AccountID], entry)
+	}
+
+	now := p.now()
+	for _, id := range order {
+		var acc Account
+		if err := p.repo.Read(ctx, id, &acc); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrAccountNotFound, id, err)
+		}
+		for _, entry := range changes[id] {
+			change := entry.Amount.Multiply(sign)
+			if entry.Type != normalSide(acc.Type) {
+				change = change.Multiply(decimal.NewFromInt(-1))
+			}
+			if acc.Balance == nil {
+				acc.Balance = &money.Money{Amount: decimal.Zero, Currency: change.Currency}
+			}
+			updated, err := acc.Balance.Add(change)
+			if err != nil {
+				return fmt.Errorf("failed to update balance of account %s: %w", id, err)
+			}
+			acc.Balance = &updated
+		}
+		acc.LastTransactionID = tx.ID
+		acc.LastModified = now
+		if err := p.repo.Update(ctx, &acc); err != nil {
+			return fmt.Errorf("failed to update account: %w", err)
+		}
+	}
+	return nil
+}
+
+// atomically runs fn in a storage transaction when the projector has a
+// transaction manager, and directly otherwise
+func (p *BalanceProjector) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.txManager == nil {
+		return fn(ctx)
+	}
+	return p.txManager.WithTransaction(ctx, fn)
+}
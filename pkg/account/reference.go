@@ -0,0 +1,127 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+)
+
+// ReferenceValidator checks that every entry of a transaction posts to an
+// existing, active account of the transaction's entity, so bad references
+// are rejected before posting rather than surfacing later in reports.
+// Closed and frozen accounts are left to StatusValidator.
+type ReferenceValidator struct {
+	repo Repository
+}
+
+// NewReferenceValidator creates a validator reading accounts from a
+// repository
+func NewReferenceValidator(repo Repository) *ReferenceValidator {
+	return &ReferenceValidator{repo: repo}
+}
+
+// Validate implements validation.Validator. Each entry referring to a
+// missing, inactive or foreign account gets its own result.
+func (v *ReferenceValidator) Validate(ctx context.Context, obj interface{}) ([]validation.ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	// Transactions posted in an entity's scope are stored under it; other
+	// transactions without an entity must keep to the entity of their
+	// first account
+	entityID := tx.EntityID
+	if id, ok := entity.FromContext(ctx); ok {
+		entityID = id
+	}
+	anchored := entityID != ""
+
+	var results []validation.ValidationResult
+	accounts := make(map[string]*Account)
+	foreign := make(map[string]bool)
+	for i, entry := range tx.Entries {
+		field := fmt.Sprintf("Entries[%d].AccountID", i)
+		if entry.AccountID == "" {
+			results = append(results, validation.ValidationResult{
+				Code:     "ACCOUNT_REQUIRED",
+				Message:  "entry has no account",
+				Severity: validation.Error,
+				Field:    field,
+			})
+			continue
+		}
+
+		acc, seen := accounts[entry.AccountID]
+		if !seen {
+			loaded, err := readAccount(ctx, v.repo, entry.AccountID)
+			switch {
+			case errors.Is(err, storage.ErrTenantMismatch):
+				foreign[entry.AccountID] = true
+			case err == nil:
+				acc = loaded
+				if !anchored {
+					entityID, anchored = acc.EntityID, true
+				}
+			}
+			accounts[entry.AccountID] = acc
+		}
+
+		switch {
+		case foreign[entry.AccountID] || (acc != nil && acc.EntityID != entityID):
+			results = append(results, validation.ValidationResult{
+				Code:     "ACCOUNT_ENTITY_MISMATCH",
+				Message:  fmt.Sprintf("account %s does not belong to entity %q", entry.AccountID, entityID),
+				Severity: validation.Error,
+				Field:    field,
+			})
+		case acc == nil:
+			results = append(results, validation.ValidationResult{
+				Code:     "ACCOUNT_NOT_FOUND",
+				Message:  fmt.Sprintf("account %s does not exist", entry.AccountID),
+				Severity: validation.Error,
+				Field:    field,
+			})
+		case acc.Status == Inactive:
+			results = append(results, validation.ValidationResult{
+				Code:     "ACCOUNT_INACTIVE",
+				Message:  fmt.Sprintf("account %s is %s", acc.ID, acc.Status),
+				Severity: validation.Error,
+				Field:    field,
+			})
+		}
+	}
+	return results, nil
+}
+
+// Supports implements validation.ObjectFilter; only transactions are checked
+func (v *ReferenceValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements validation.Validator
+func (v *ReferenceValidator) GetRules() []validation.ValidationRule {
+	rules := []validation.ValidationRule{
+		{ID: "ACCOUNT_REQUIRED", Description: "Entries must name an account"},
+		{ID: "ACCOUNT_NOT_FOUND", Description: "Entries must post to existing accounts"},
+		{ID: "ACCOUNT_ENTITY_MISMATCH", Description: "Entries must post to accounts of the transaction's entity"},
+		{ID: "ACCOUNT_INACTIVE", Description: "Transactions cannot post to inactive accounts"},
+	}
+	for i := range rules {
+		rules[i].Severity = validation.Error
+		rules[i].Category = "ACCOUNT"
+	}
+	return rules
+}
+
+// Priority implements validation.Validator; references are checked before
+// the other account checks
+func (v *ReferenceValidator) Priority() int {
+	return 15
+}
@@ -2,6 +2,8 @@ package account
 
 import (
 	"context"
+
+	"github.com/johnayoung/finlib/pkg/validation"
 )
 
 // Repository defines the interface for account data persistence
@@ -21,3 +23,45 @@ type Repository interface {
 	// Query executes a query and returns matching accounts
 	Query(ctx context.Context, query interface{}, results interface{}) error
 }
+
+// readAccount reads an account for a validator through the validation
+// cache of the context, so a batch reads each account once. The account
+// returned may be shared and must not be modified.
+func readAccount(ctx context.Context, repo Repository, id string) (*Account, error) {
+	value, err := validation.Cached(ctx, "account:"+id, func() (interface{}, error) {
+		var acc Account
+		if err := repo.Read(ctx, id, &acc); err != nil {
+			return nil, err
+		}
+		return &acc, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Account), nil
+}
+
+// RuleLookup returns a validation.AccountLookup exposing accounts to custom
+// rules as account.id, account.code, account.name, account.type,
+// account.status, account.entity and account.parent. Accounts that cannot
+// be read are reported as unknown.
+func RuleLookup(repo Repository) validation.AccountLookup {
+	return func(ctx context.Context, accountID string) (map[string]string, error) {
+		acc, err := readAccount(ctx, repo, accountID)
+		if err != nil {
+			return nil, nil
+		}
+		fields := map[string]string{
+			"id":     acc.ID,
+			"code":   acc.Code,
+			"name":   acc.Name,
+			"type":   string(acc.Type),
+			"status": string(acc.Status),
+			"entity": acc.EntityID,
+		}
+		if acc.ParentID != nil {
+			fields["parent"] = *acc.ParentID
+		}
+		return fields, nil
+	}
+}
@@ -0,0 +1,141 @@
+package account
+
+// templates holds the standard charts of accounts by name
+var templates = map[string][]ChartAccount{
+	TemplateUSGAAPSmallBusiness: {
+		{Code: "1000", Name: "Assets", Type: Asset},
+		{Code: "1100", Name: "Cash and Cash Equivalents", Type: Asset, ParentCode: "1000"},
+		{Code: "1110", Name: "Operating Checking", Type: Asset, ParentCode: "1100"},
+		{Code: "1120", Name: "Savings", Type: Asset, ParentCode: "1100"},
+		{Code: "1130", Name: "Petty Cash", Type: Asset, ParentCode: "1100"},
+		{Code: "1200", Name: "Accounts Receivable", Type: Asset, ParentCode: "1000"},
+		{Code: "1210", Name: "Allowance for Doubtful Accounts", Type: Asset, ParentCode: "1200"},
+		{Code: "1300", Name: "Inventory", Type: Asset, ParentCode: "1000"},
+		{Code: "1400", Name: "Prepaid Expenses", Type: Asset, ParentCode: "1000"},
+		{Code: "1500", Name: "Property and Equipment", Type: Asset, ParentCode: "1000"},
+		{Code: "1510", Name: "Furniture and Equipment", Type: Asset, ParentCode: "1500"},
+		{Code: "1520", Name: "Vehicles", Type: Asset, ParentCode: "1500"},
+		{Code: "1590", Name: "Accumulated Depreciation", Type: Asset, ParentCode: "1500"},
+		{Code: "2000", Name: "Liabilities", Type: Liability},
+		{Code: "2100", Name: "Accounts Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "2200", Name: "Accrued Liabilities", Type: Liability, ParentCode: "2000"},
+		{Code: "2210", Name: "Accrued Payroll", Type: Liability, ParentCode: "2200"},
+		{Code: "2300", Name: "Sales Tax Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "2400", Name: "Payroll Taxes Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "2500", Name: "Credit Cards Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "2700", Name: "Notes Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "3000", Name: "Equity", Type: Equity},
+		{Code: "3100", Name: "Owner's Capital", Type: Equity, ParentCode: "3000"},
+		{Code: "3200", Name: "Owner's Draws", Type: Equity, ParentCode: "3000"},
+		{Code: "3900", Name: "Retained Earnings", Type: Equity, ParentCode: "3000"},
+		{Code: "4000", Name: "Revenue", Type: Revenue},
+		{Code: "4100", Name: "Sales", Type: Revenue, ParentCode: "4000"},
+		{Code: "4200", Name: "Service Revenue", Type: Revenue, ParentCode: "4000"},
+		{Code: "4900", Name: "Other Income", Type: Revenue, ParentCode: "4000"},
+		{Code: "5000", Name: "Cost of Goods Sold", Type: Expense},
+		{Code: "5100", Name: "Purchases", Type: Expense, ParentCode: "5000"},
+		{Code: "5200", Name: "Freight In", Type: Expense, ParentCode: "5000"},
+		{Code: "6000", Name: "Operating Expenses", Type: Expense},
+		{Code: "6100", Name: "Salaries and Wages", Type: Expense, ParentCode: "6000"},
+		{Code: "6110", Name: "Payroll Taxes", Type: Expense, ParentCode: "6000"},
+		{Code: "6200", Name: "Rent", Type: Expense, ParentCode: "6000"},
+		{Code: "6300", Name: "Utilities", Type: Expense, ParentCode: "6000"},
+		{Code: "6400", Name: "Insurance", Type: Expense, ParentCode: "6000"},
+		{Code: "6500", Name: "Office Supplies", Type: Expense, ParentCode: "6000"},
+		{Code: "6600", Name: "Professional Fees", Type: Expense, ParentCode: "6000"},
+		{Code: "6700", Name: "Advertising", Type: Expense, ParentCode: "6000"},
+		{Code: "6800", Name: "Depreciation", Type: Expense, ParentCode: "6000"},
+		{Code: "6900", Name: "Bank Fees", Type: Expense, ParentCode: "6000"},
+		{Code: "7000", Name: "Income Tax Expense", Type: Expense},
+	},
+	TemplateIFRS: {
+		{Code: "1", Name: "Assets", Type: Asset},
+		{Code: "11", Name: "Non-current Assets", Type: Asset, ParentCode: "1"},
+		{Code: "111", Name: "Property, Plant and Equipment", Type: Asset, ParentCode: "11"},
+		{Code: "112", Name: "Right-of-use Assets", Type: Asset, ParentCode: "11"},
+		{Code: "113", Name: "Intangible Assets", Type: Asset, ParentCode: "11"},
+		{Code: "114", Name: "Investment Property", Type: Asset, ParentCode: "11"},
+		{Code: "115", Name: "Deferred Tax Assets", Type: Asset, ParentCode: "11"},
+		{Code: "12", Name: "Current Assets", Type: Asset, ParentCode: "1"},
+		{Code: "121", Name: "Inventories", Type: Asset, ParentCode: "12"},
+		{Code: "122", Name: "Trade and Other Receivables", Type: Asset, ParentCode: "12"},
+		{Code: "123", Name: "Contract Assets", Type: Asset, ParentCode: "12"},
+		{Code: "124", Name: "Prepayments", Type: Asset, ParentCode: "12"},
+		{Code: "125", Name: "Cash and Cash Equivalents", Type: Asset, ParentCode: "12"},
+		{Code: "2", Name: "Equity", Type: Equity},
+		{Code: "21", Name: "Share Capital", Type: Equity, ParentCode: "2"},
+		{Code: "22", Name: "Share Premium", Type: Equity, ParentCode: "2"},
+		{Code: "23", Name: "Other Reserves", Type: Equity, ParentCode: "2"},
+		{Code: "24", Name: "Retained Earnings", Type: Equity, ParentCode: "2"},
+		{Code: "3", Name: "Liabilities", Type: Liability},
+		{Code: "31", Name: "Non-current Liabilities", Type: Liability, ParentCode: "3"},
+		{Code: "311", Name: "Borrowings", Type: Liability, ParentCode: "31"},
+		{Code: "312", Name: "Lease Liabilities", Type: Liability, ParentCode: "31"},
+		{Code: "313", Name: "Provisions", Type: Liability, ParentCode: "31"},
+		{Code: "314", Name: "Deferred Tax Liabilities", Type: Liability, ParentCode: "31"},
+		{Code: "32", Name: "Current Liabilities", Type: Liability, ParentCode: "3"},
+		{Code: "321", Name: "Trade and Other Payables", Type: Liability, ParentCode: "32"},
+		{Code: "322", Name: "Contract Liabilities", Type: Liability, ParentCode: "32"},
+		{Code: "323", Name: "Current Tax Liabilities", Type: Liability, ParentCode: "32"},
+		{Code: "324", Name: "Short-term Borrowings", Type: Liability, ParentCode: "32"},
+		{Code: "4", Name: "Revenue", Type: Revenue},
+		{Code: "41", Name: "Revenue from Contracts with Customers", Type: Revenue, ParentCode: "4"},
+		{Code: "42", Name: "Other Income", Type: Revenue, ParentCode: "4"},
+		{Code: "43", Name: "Finance Income", Type: Revenue, ParentCode: "4"},
+		{Code: "5", Name: "Expenses", Type: Expense},
+		{Code: "51", Name: "Cost of Sales", Type: Expense, ParentCode: "5"},
+		{Code: "52", Name: "Distribution Costs", Type: Expense, ParentCode: "5"},
+		{Code: "53", Name: "Administrative Expenses", Type: Expense, ParentCode: "5"},
+		{Code: "54", Name: "Employee Benefits Expense", Type: Expense, ParentCode: "5"},
+		{Code: "55", Name: "Depreciation and Amortisation", Type: Expense, ParentCode: "5"},
+		{Code: "56", Name: "Impairment Losses", Type: Expense, ParentCode: "5"},
+		{Code: "57", Name: "Finance Costs", Type: Expense, ParentCode: "5"},
+		{Code: "58", Name: "Income Tax Expense", Type: Expense, ParentCode: "5"},
+	},
+	TemplateNonprofit: {
+		{Code: "1000", Name: "Assets", Type: Asset},
+		{Code: "1100", Name: "Cash", Type: Asset, ParentCode: "1000"},
+		{Code: "1110", Name: "Operating Cash", Type: Asset, ParentCode: "1100"},
+		{Code: "1120", Name: "Restricted Cash", Type: Asset, ParentCode: "1100"},
+		{Code: "1200", Name: "Contributions Receivable", Type: Asset, ParentCode: "1000"},
+		{Code: "1210", Name: "Grants Receivable", Type: Asset, ParentCode: "1000"},
+		{Code: "1300", Name: "Prepaid Expenses", Type: Asset, ParentCode: "1000"},
+		{Code: "1400", Name: "Investments", Type: Asset, ParentCode: "1000"},
+		{Code: "1500", Name: "Property and Equipment", Type: Asset, ParentCode: "1000"},
+		{Code: "1590", Name: "Accumulated Depreciation", Type: Asset, ParentCode: "1500"},
+		{Code: "1900", Name: "Due from Other Funds", Type: Asset, ParentCode: "1000"},
+		{Code: "2000", Name: "Liabilities", Type: Liability},
+		{Code: "2100", Name: "Accounts Payable", Type: Liability, ParentCode: "2000"},
+		{Code: "2200", Name: "Accrued Expenses", Type: Liability, ParentCode: "2000"},
+		{Code: "2300", Name: "Deferred Revenue", Type: Liability, ParentCode: "2000"},
+		{Code: "2400", Name: "Refundable Advances", Type: Liability, ParentCode: "2000"},
+		{Code: "2900", Name: "Due to Other Funds", Type: Liability, ParentCode: "2000"},
+		{Code: "3000", Name: "Net Assets", Type: Equity},
+		{Code: "3100", Name: "Net Assets Without Donor Restrictions", Type: Equity, ParentCode: "3000"},
+		{Code: "3110", Name: "Board-designated Net Assets", Type: Equity, ParentCode: "3100"},
+		{Code: "3200", Name: "Net Assets With Donor Restrictions", Type: Equity, ParentCode: "3000"},
+		{Code: "3210", Name: "Purpose Restricted", Type: Equity, ParentCode: "3200"},
+		{Code: "3220", Name: "Time Restricted", Type: Equity, ParentCode: "3200"},
+		{Code: "3230", Name: "Perpetual Endowment", Type: Equity, ParentCode: "3200"},
+		{Code: "4000", Name: "Support and Revenue", Type: Revenue},
+		{Code: "4100", Name: "Contributions", Type: Revenue, ParentCode: "4000"},
+		{Code: "4200", Name: "Grants", Type: Revenue, ParentCode: "4000"},
+		{Code: "4300", Name: "Program Service Fees", Type: Revenue, ParentCode: "4000"},
+		{Code: "4400", Name: "Special Events", Type: Revenue, ParentCode: "4000"},
+		{Code: "4500", Name: "In-kind Contributions", Type: Revenue, ParentCode: "4000"},
+		{Code: "4600", Name: "Investment Income", Type: Revenue, ParentCode: "4000"},
+		{Code: "4900", Name: "Net Assets Released from Restrictions", Type: Revenue, ParentCode: "4000"},
+		{Code: "5000", Name: "Program Services", Type: Expense},
+		{Code: "5100", Name: "Program Salaries", Type: Expense, ParentCode: "5000"},
+		{Code: "5200", Name: "Program Supplies", Type: Expense, ParentCode: "5000"},
+		{Code: "5300", Name: "Grants to Others", Type: Expense, ParentCode: "5000"},
+		{Code: "6000", Name: "Management and General", Type: Expense},
+		{Code: "6100", Name: "Administrative Salaries", Type: Expense, ParentCode: "6000"},
+		{Code: "6200", Name: "Occupancy", Type: Expense, ParentCode: "6000"},
+		{Code: "6300", Name: "Professional Fees", Type: Expense, ParentCode: "6000"},
+		{Code: "6400", Name: "Depreciation", Type: Expense, ParentCode: "6000"},
+		{Code: "7000", Name: "Fundraising", Type: Expense},
+		{Code: "7100", Name: "Fundraising Salaries", Type: Expense, ParentCode: "7000"},
+		{Code: "7200", Name: "Event Costs", Type: Expense, ParentCode: "7000"},
+	},
+}
@@ -3,6 +3,7 @@ package account
 import (
 	"time"
 	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
 )
 
 // AccountType represents the classification of an account
@@ -16,6 +17,26 @@ const (
 	Expense   AccountType = "EXPENSE"
 )
 
+// Standard dimensions used to tag accounts and transaction entries. Any
+// other dimension name may be used as well.
+const (
+	DimensionDepartment = "department"
+	DimensionProject    = "project"
+	DimensionLocation   = "location"
+	DimensionCostCenter = "cost_center"
+)
+
+// MetaData flags placing an asset or liability on the balance sheet
+const (
+	// CurrentKey marks a balance realised or settled within the operating cycle
+	CurrentKey = "current"
+	// CashKey marks an asset as cash or a cash equivalent
+	CashKey = "cash"
+	// HeaderKey marks a header or summary account that only groups other
+	// accounts and cannot be posted to
+	HeaderKey = "header"
+)
+
 // AccountStatus represents the status of an account
 type AccountStatus string
 
@@ -29,25 +50,98 @@ const (
 // Account represents a financial account in the system
 type Account struct {
 	// Unique identifier for the account
-	ID string
+	ID string `json:"id"`
 	// Account code used for reporting and categorization
-	Code string
+	Code string `json:"code"`
 	// Human-readable name of the account
-	Name string
+	Name string `json:"name"`
 	// Type of account (Asset, Liability, etc.)
-	Type AccountType
+	Type AccountType `json:"type"`
 	// Status of the account
-	Status AccountStatus
+	Status AccountStatus `json:"status"`
 	// Optional parent account ID for hierarchical structures
-	ParentID *string
+	ParentID *string `json:"parent_id,omitempty"`
+	// Legal entity whose books the account belongs to
+	EntityID string `json:"entity_id,omitempty"`
+	// Dimension tags such as department or cost center; entries posted to
+	// the account inherit them unless they set their own
+	Dimensions map[string]string `json:"dimensions,omitempty"`
 	// When the account was created
-	Created time.Time
+	Created time.Time `json:"created"`
 	// Last modification timestamp
-	LastModified time.Time
+	LastModified time.Time `json:"last_modified"`
+	// Users who created and last modified the account
+	CreatedBy  string `json:"created_by,omitempty"`
+	ModifiedBy string `json:"modified_by,omitempty"`
 	// Additional metadata for extensibility
-	MetaData map[string]interface{}
+	MetaData map[string]interface{} `json:"metadata,omitempty" encrypt:"true"`
 	// Balance of the account
-	Balance *money.Money
+	Balance *money.Money `json:"balance,omitempty"`
+	// Last transaction applied to the balance, set by BalanceProjector
+	LastTransactionID string `json:"last_transaction_id,omitempty"`
+	// Version the account was stored at, set by the repository; updates
+	// made from an outdated version fail with storage.OptimisticLockError
+	Version int64 `json:"version,omitempty"`
+}
+
+// GetID returns the account identifier
+func (a *Account) GetID() string { return a.ID }
+
+// GetVersion returns the version the account was stored at
+func (a *Account) GetVersion() int64 { return a.Version }
+
+// SetVersion records the version the account was stored at
+func (a *Account) SetVersion(version int64) { a.Version = version }
+
+// GetTenantID returns the entity (tenant) that owns the account
+func (a *Account) GetTenantID() string { return a.EntityID }
+
+// SetTenantID sets the entity (tenant) that owns the account
+func (a *Account) SetTenantID(id string) { a.EntityID = id }
+
+// GetCreatedBy returns the user who created the account
+func (a *Account) GetCreatedBy() string { return a.CreatedBy }
+
+// SetCreatedBy records the user who created the account
+func (a *Account) SetCreatedBy(userID string) { a.CreatedBy = userID }
+
+// SetModifiedBy records the user who last modified the account
+func (a *Account) SetModifiedBy(userID string) { a.ModifiedBy = userID }
+
+// EntryDimensions returns the dimensions of an entry posted to the account:
+// the account's tags overridden by the entry's own
+func (a *Account) EntryDimensions(entry map[string]string) map[string]string {
+	dims := make(map[string]string, len(a.Dimensions)+len(entry))
+	for k, v := range a.Dimensions {
+		dims[k] = v
+	}
+	for k, v := range entry {
+		dims[k] = v
+	}
+	return dims
+}
+
+// IsCurrent reports whether the account is flagged as a current asset or
+// liability
+func (a *Account) IsCurrent() bool { return a.flag(CurrentKey) }
+
+// IsCash reports whether the account is flagged as cash or a cash equivalent
+func (a *Account) IsCash() bool { return a.flag(CashKey) }
+
+// IsHeader reports whether the account is flagged as a header or summary
+// account
+func (a *Account) IsHeader() bool { return a.flag(HeaderKey) }
+
+// flag reads a boolean MetaData flag, accepting "true" as stored by
+// string-only backends
+func (a *Account) flag(key string) bool {
+	switch v := a.MetaData[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
 }
 
 // Status represents the current state of an account
@@ -72,17 +166,27 @@ type ValidationRule struct {
 	Type string
 	// Whether rule violation blocks operations
 	Blocking bool
+	// Account the rule applies to; rules naming an account take precedence
+	// over rules for its type
+	AccountID string
+	// Account type the rule applies to when it names no account
+	AccountType AccountType
+	// Entry side checked by entry side rules
+	Side transaction.EntryType
+	// Whether matching entries are allowed, exempting them from a broader
+	// rule
+	Allow bool
 }
 
 // Balance represents the current balance of an account
 type Balance struct {
 	// Account ID this balance belongs to
-	AccountID string
+	AccountID string `json:"account_id"`
 	// Timestamp of the balance
-	AsOf time.Time
+	AsOf time.Time `json:"as_of"`
 	// Actual balance amount and currency
-	Amount string
-	Currency string
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
 	// Last transaction ID that affected this balance
-	LastTransactionID string
+	LastTransactionID string `json:"last_transaction_id,omitempty"`
 }
@@ -0,0 +1,208 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/validation"
+)
+
+// DefaultCodePattern accepts codes of letters and digits, optionally split
+// into segments by dots or dashes, such as 1100, 6100-10 or EXP.TRAVEL
+const DefaultCodePattern = `^[A-Za-z0-9]+([.-][A-Za-z0-9]+)*$`
+
+// TypeRule holds the rules for accounts of one type
+type TypeRule struct {
+	// Prefixes one of which the codes of the type must start with, e.g. "1"
+	// for assets; any code is accepted when empty
+	CodePrefixes []string
+	// Types the parent of an account of the type may have; only the same
+	// type is accepted when empty
+	ParentTypes []AccountType
+}
+
+// StandardTypeRules returns code prefixes following the common numbering of
+// a chart of accounts: assets 1, liabilities 2, equity 3, revenue 4 and
+// expenses 5 to 9
+func StandardTypeRules() map[AccountType]TypeRule {
+	return map[AccountType]TypeRule{
+		Asset:     {CodePrefixes: []string{"1"}},
+		Liability: {CodePrefixes: []string{"2"}},
+		Equity:    {CodePrefixes: []string{"3"}},
+		Revenue:   {CodePrefixes: []string{"4"}},
+		Expense:   {CodePrefixes: []string{"5", "6", "7", "8", "9"}},
+	}
+}
+
+// AccountValidator checks accounts before they are stored: the format of
+// the code, the rules for the account's type, that the parent has a
+// compatible type and that no other account of the entity uses the code.
+// Without a repository the parent and uniqueness checks are skipped.
+type AccountValidator struct {
+	mu          sync.RWMutex
+	repo        Repository
+	codePattern *regexp.Regexp
+	typeRules   map[AccountType]TypeRule
+}
+
+// NewAccountValidator creates a validator reading parents and codes in use
+// from a repository, which may be nil. Codes must match DefaultCodePattern
+// and no type rules are set.
+func NewAccountValidator(repo Repository) *AccountValidator {
+	return &AccountValidator{
+		repo:        repo,
+		codePattern: regexp.MustCompile(DefaultCodePattern),
+		typeRules:   make(map[AccountType]TypeRule),
+	}
+}
+
+// SetCodePattern sets the regular expression account codes must match
+func (v *AccountValidator) SetCodePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%w: invalid code pattern: %v", ErrInvalidOperation, err)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.codePattern = re
+	return nil
+}
+
+// SetTypeRule sets the rules for accounts of a type
+func (v *AccountValidator) SetTypeRule(accountType AccountType, rule TypeRule) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.typeRules[accountType] = rule
+}
+
+// Validate implements validation.Validator
+func (v *AccountValidator) Validate(ctx context.Context, obj interface{}) ([]validation.ValidationResult, error) {
+	acc, ok := obj.(*Account)
+	if !ok {
+		return nil, fmt.Errorf("expected *account.Account, got %T", obj)
+	}
+
+	v.mu.RLock()
+	pattern := v.codePattern
+	rule, hasRule := v.typeRules[acc.Type]
+	v.mu.RUnlock()
+
+	var results []validation.ValidationResult
+	fail := func(code, field, format string, args ...interface{}) {
+		results = append(results, validation.ValidationResult{
+			Code:     code,
+			Message:  fmt.Sprintf(format, args...),
+			Severity: validation.Error,
+			Field:    field,
+		})
+	}
+
+	if acc.Code == "" {
+		fail("ACCOUNT_CODE_REQUIRED", "Code", "account code is required")
+	} else if !pattern.MatchString(acc.Code) {
+		fail("ACCOUNT_CODE_FORMAT", "Code", "account code %q does not match %s", acc.Code, pattern)
+	}
+
+	switch acc.Type {
+	case Asset, Liability, Equity, Revenue, Expense:
+	default:
+		fail("ACCOUNT_TYPE_INVALID", "Type", "unknown account type %q", acc.Type)
+		return results, nil
+	}
+	if hasRule && len(rule.CodePrefixes) > 0 && acc.Code != "" && !hasPrefix(acc.Code, rule.CodePrefixes) {
+		fail("ACCOUNT_CODE_RANGE", "Code", "%s account codes must start with %s, got %s", acc.Type, strings.Join(rule.CodePrefixes, " or "), acc.Code)
+	}
+	if acc.IsCash() && acc.Type != Asset {
+		fail("ACCOUNT_FLAG_TYPE", "MetaData", "only asset accounts can be cash, not %s account %s", acc.Type, acc.Code)
+	}
+	if acc.IsCurrent() && acc.Type != Asset && acc.Type != Liability {
+		fail("ACCOUNT_FLAG_TYPE", "MetaData", "only asset and liability accounts can be current, not %s account %s", acc.Type, acc.Code)
+	}
+
+	if v.repo == nil {
+		return results, nil
+	}
+	if acc.ParentID != nil && *acc.ParentID != "" {
+		var parent Account
+		if err := v.repo.Read(ctx, *acc.ParentID, &parent); err != nil {
+			fail("ACCOUNT_PARENT_NOT_FOUND", "ParentID", "parent account %s not found", *acc.ParentID)
+		} else if !compatibleParent(acc.Type, parent.Type, rule.ParentTypes) {
+			fail("ACCOUNT_PARENT_TYPE", "ParentID", "%s account cannot be a child of %s account %s", acc.Type, parent.Type, parent.ID)
+		}
+	}
+	if acc.Code != "" {
+		query := storage.Query{
+			Filters: []storage.Filter{{Field: "code", Operator: "=", Value: acc.Code}},
+		}
+		var sameCode []*Account
+		if err := v.repo.Query(ctx, entity.ScopeQuery(ctx, query), &sameCode); err != nil {
+			return nil, fmt.Errorf("error querying accounts: %w", err)
+		}
+		for _, other := range sameCode {
+			if other.ID != acc.ID && other.EntityID == acc.EntityID {
+				fail("ACCOUNT_CODE_DUPLICATE", "Code", "account code %s is already used by %s", acc.Code, other.ID)
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// hasPrefix reports whether a code starts with any of the prefixes
+func hasPrefix(code string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(code, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compatibleParent reports whether an account may sit under a parent of a
+// type, given the parent types its type allows
+func compatibleParent(child, parent AccountType, allowed []AccountType) bool {
+	if len(allowed) == 0 {
+		return child == parent
+	}
+	for _, t := range allowed {
+		if t == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// Supports implements validation.ObjectFilter; only accounts are checked
+func (v *AccountValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*Account)
+	return ok
+}
+
+// GetRules implements validation.Validator
+func (v *AccountValidator) GetRules() []validation.ValidationRule {
+	rules := []validation.ValidationRule{
+		{ID: "ACCOUNT_CODE_REQUIRED", Description: "Accounts must have a code"},
+		{ID: "ACCOUNT_CODE_FORMAT", Description: "Account codes must match the code pattern"},
+		{ID: "ACCOUNT_TYPE_INVALID", Description: "Accounts must have a known type"},
+		{ID: "ACCOUNT_CODE_RANGE", Description: "Account codes must use a prefix of their type"},
+		{ID: "ACCOUNT_FLAG_TYPE", Description: "Cash and current flags must suit the account type"},
+		{ID: "ACCOUNT_PARENT_NOT_FOUND", Description: "Parent accounts must exist"},
+		{ID: "ACCOUNT_PARENT_TYPE", Description: "Parent accounts must have a compatible type"},
+		{ID: "ACCOUNT_CODE_DUPLICATE", Description: "Account codes must be unique within an entity"},
+	}
+	for i := range rules {
+		rules[i].Severity = validation.Error
+		rules[i].Category = "ACCOUNT"
+	}
+	return rules
+}
+
+// Priority implements validation.Validator; account checks run early
+func (v *AccountValidator) Priority() int {
+	return 20
+}
@@ -0,0 +1,210 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor posts transactions with the library's processor and
+// records them
+type recordingProcessor struct {
+	*transaction.BasicTransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func newRecordingProcessor(store *memory.MemoryStore) *recordingProcessor {
+	return &recordingProcessor{BasicTransactionProcessor: transaction.NewBasicTransactionProcessor(store)}
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	if err := p.BasicTransactionProcessor.ProcessTransaction(ctx, tx); err != nil {
+		return err
+	}
+	p.posted = append(p.posted, tx)
+	return nil
+}
+
+func (p *recordingProcessor) ProcessTransactionBatch(ctx context.Context, txs []*transaction.Transaction) error {
+	if err := p.BasicTransactionProcessor.ProcessTransactionBatch(ctx, txs); err != nil {
+		return err
+	}
+	p.posted = append(p.posted, txs...)
+	return nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := newRecordingProcessor(store)
+	engine := NewEngine(store, processor)
+
+	january := Period{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}
+	february := Period{Start: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)}
+
+	require.NoError(t, engine.Register(&Definition{
+		ID:              "RENT",
+		Description:     "Office rent",
+		DebitAccountID:  "6100",
+		CreditAccountID: "2100",
+		Amount:          usd(1000),
+		StartDate:       january.End,
+		AutoReverse:     true,
+		Active:          true,
+	}))
+	require.NoError(t, engine.Register(&Definition{
+		ID:              "UTIL",
+		Description:     "Utilities estimate",
+		DebitAccountID:  "6200",
+		CreditAccountID: "2100",
+		Estimate: func(ctx context.Context, def *Definition, period Period) (money.Money, error) {
+			return usd(int64(period.End.Day()) * 10), nil
+		},
+		StartDate: january.End,
+		Active:    true,
+	}))
+
+	t.Run("validation", func(t *testing.T) {
+		err := engine.Register(&Definition{ID: "BAD", DebitAccountID: "6100", CreditAccountID: "2100"})
+		assert.ErrorIs(t, err, ErrInvalidDefinition)
+	})
+
+	t.Run("period close generates accruals once", func(t *testing.T) {
+		generated, err := engine.OnPeriodClose(ctx, january)
+		require.NoError(t, err)
+		require.Len(t, generated, 2)
+		assert.Equal(t, "1000", generated[0].Amount.Amount.String())
+		assert.Equal(t, "310", generated[1].Amount.Amount.String())
+		assert.Equal(t, january.End, processor.posted[0].Date)
+		stored, err := processor.GetTransaction(ctx, generated[0].TransactionID)
+		require.NoError(t, err)
+		assert.Equal(t, transaction.Posted, stored.Status)
+
+		generated, err = engine.OnPeriodClose(ctx, january)
+		require.NoError(t, err)
+		assert.Empty(t, generated)
+	})
+
+	t.Run("period open reverses auto-reversing accruals", func(t *testing.T) {
+		reversed, err := engine.OnPeriodOpen(ctx, february)
+		require.NoError(t, err)
+		require.Len(t, reversed, 1)
+		assert.Equal(t, "RENT", reversed[0].DefinitionID)
+
+		reversal := processor.posted[len(processor.posted)-1]
+		assert.Equal(t, transaction.Reversal, reversal.Type)
+		assert.Equal(t, february.Start, reversal.Date)
+		assert.Equal(t, "2100", reversal.Entries[0].AccountID)
+		assert.Equal(t, transaction.Debit, reversal.Entries[0].Type)
+
+		reversed, err = engine.OnPeriodOpen(ctx, february)
+		require.NoError(t, err)
+		assert.Empty(t, reversed)
+	})
+
+	t.Run("postings survive a restart", func(t *testing.T) {
+		restarted := NewEngine(store, processor)
+		postings, err := restarted.Postings(ctx)
+		require.NoError(t, err)
+		require.Len(t, postings, 2)
+		assert.Equal(t, "RENT", postings[0].DefinitionID)
+		assert.Equal(t, "REV-ACR-RENT-20240131", postings[0].ReversalID)
+		assert.Empty(t, postings[1].ReversalID)
+
+		require.NoError(t, restarted.Register(&Definition{
+			ID: "RENT", Description: "Office rent", DebitAccountID: "6100", CreditAccountID: "2100",
+			Amount: usd(1000), StartDate: january.End, AutoReverse: true, Active: true,
+		}))
+		generated, err := restarted.OnPeriodClose(ctx, january)
+		require.NoError(t, err)
+		assert.Empty(t, generated)
+		reversed, err := restarted.OnPeriodOpen(ctx, february)
+		require.NoError(t, err)
+		assert.Empty(t, reversed)
+	})
+}
+
+func TestSchedules(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := newRecordingProcessor(store)
+	engine := NewEngine(store, processor)
+
+	insurance := &Schedule{
+		ID:                   "INS-2024",
+		Description:          "Annual insurance",
+		Kind:                 PrepaidExpense,
+		Amount:               usd(1000),
+		DeferralAccountID:    "1500",
+		RecognitionAccountID: "6300",
+		StartDate:            time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Periods:              12,
+	}
+	require.NoError(t, engine.AddSchedule(ctx, insurance))
+	require.Len(t, insurance.Installments, 12)
+	require.Len(t, processor.posted, 12)
+	assert.Equal(t, "83.33", insurance.Installments[0].Amount.Amount.String())
+	assert.Equal(t, "83.37", insurance.Installments[11].Amount.Amount.String())
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), insurance.Installments[1].Date)
+	assert.Equal(t, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), processor.posted[11].Date)
+	assert.Equal(t, "6300", processor.posted[0].Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, processor.posted[0].Entries[0].Type)
+	stored, err := processor.GetTransaction(ctx, insurance.Installments[11].TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, stored.Status)
+
+	support := &Schedule{
+		ID:                   "SUP-1",
+		Description:          "Support contract",
+		Kind:                 DeferredRevenue,
+		Amount:               usd(600),
+		DeferralAccountID:    "2400",
+		RecognitionAccountID: "4200",
+		StartDate:            time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Periods:              6,
+	}
+	require.NoError(t, engine.AddSchedule(ctx, support))
+	last := processor.posted[len(processor.posted)-1]
+	assert.Equal(t, "SCH-SUP-1-006", last.ID)
+	assert.Equal(t, "SUP-1", last.Reference)
+	assert.Equal(t, "2400", last.Entries[0].AccountID)
+	assert.Equal(t, "4200", last.Entries[1].AccountID)
+	assert.Equal(t, "100", last.Entries[1].Amount.Amount.String())
+
+	assert.ErrorIs(t, engine.AddSchedule(ctx, support), ErrInvalidSchedule)
+	assert.ErrorIs(t, engine.AddSchedule(ctx, &Schedule{ID: "X", Kind: PrepaidExpense, Amount: usd(1), DeferralAccountID: "1500", RecognitionAccountID: "6300", StartDate: support.StartDate}), ErrInvalidSchedule)
+
+	// Cancelling reverses the installments after the termination date
+	reversed, err := engine.CancelSchedule(ctx, "SUP-1", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, reversed, 4)
+	assert.Equal(t, 3, reversed[0].Number)
+	reversal := processor.posted[len(processor.posted)-1]
+	assert.Equal(t, transaction.Reversal, reversal.Type)
+	assert.Equal(t, "SCH-SUP-1-006", reversal.ReversedFrom)
+	assert.Equal(t, last.Date, reversal.Date)
+	assert.Equal(t, "4200", reversal.Entries[1].AccountID)
+	assert.Equal(t, transaction.Debit, reversal.Entries[1].Type)
+
+	reversed, err = engine.CancelSchedule(ctx, "SUP-1", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Empty(t, reversed)
+	_, err = engine.CancelSchedule(ctx, "missing", time.Now())
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+
+	schedules := engine.Schedules()
+	require.Len(t, schedules, 2)
+	assert.Equal(t, "INS-2024", schedules[0].ID)
+	assert.NotNil(t, schedules[1].Installments[5].ReversedAt)
+}
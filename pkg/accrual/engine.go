@@ -0,0 +1,238 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var (
+	ErrInvalidDefinition  = errors.New("invalid accrual definition")
+	ErrDefinitionNotFound = errors.New("accrual definition not found")
+	ErrInvalidSchedule    = errors.New("invalid recognition schedule")
+	ErrScheduleNotFound   = errors.New("recognition schedule not found")
+)
+
+// Engine generates recurring accruals at period end and reverses them at the
+// start of the following period. It is driven by the period close through
+// OnPeriodClose and OnPeriodOpen; period.Closer calls both when an engine
+// is set. It also books recognition schedules for prepaid expenses and
+// deferred revenue.
+type Engine struct {
+	mu          sync.RWMutex
+	repo        storage.Repository
+	processor   transaction.TransactionProcessor
+	definitions map[string]*Definition
+	schedules   map[string]*Schedule
+}
+
+// NewEngine creates a new accrual engine. Accruals, reversals and
+// recognition entries are stored in the repository and posted with the
+// processor, and the postings recording accruals are kept in the same
+// repository, so a restarted engine still reverses them.
+func NewEngine(repo storage.Repository, processor transaction.TransactionProcessor) *Engine {
+	return &Engine{
+		repo:        repo,
+		processor:   processor,
+		definitions: make(map[string]*Definition),
+		schedules:   make(map[string]*Schedule),
+	}
+}
+
+// Register adds or replaces a recurring accrual definition
+func (e *Engine) Register(def *Definition) error {
+	if def == nil || def.ID == "" {
+		return fmt.Errorf("%w: ID is required", ErrInvalidDefinition)
+	}
+	if def.DebitAccountID == "" || def.CreditAccountID == "" {
+		return fmt.Errorf("%w: debit and credit accounts are required", ErrInvalidDefinition)
+	}
+	if def.DebitAccountID == def.CreditAccountID {
+		return fmt.Errorf("%w: debit and credit accounts must differ", ErrInvalidDefinition)
+	}
+	if def.Estimate == nil && !def.Amount.IsPositive() {
+		return fmt.Errorf("%w: a positive fixed amount or an estimate callback is required", ErrInvalidDefinition)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.definitions[def.ID] = def
+	return nil
+}
+
+// Unregister removes an accrual definition. Postings already generated are kept
+// so they are still reversed.
+func (e *Engine) Unregister(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.definitions[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrDefinitionNotFound, id)
+	}
+	delete(e.definitions, id)
+	return nil
+}
+
+// Postings returns the accruals generated so far, ordered by period and
+// definition
+func (e *Engine) Postings(ctx context.Context) ([]Posting, error) {
+	var postings []Posting
+	query := storage.Query{Sort: []storage.Sort{{Field: "period.end"}, {Field: "definition_id"}}}
+	if err := e.repo.Query(ctx, query, &postings); err != nil {
+		return nil, fmt.Errorf("error querying accrual postings: %w", err)
+	}
+	return postings, nil
+}
+
+// OnPeriodClose generates the accruals for a closing period. Each definition
+// is generated at most once per period, so the call is safe to repeat.
+func (e *Engine) OnPeriodClose(ctx context.Context, period Period) ([]Posting, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ids := make([]string, 0, len(e.definitions))
+	for id := range e.definitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	generated := make([]Posting, 0)
+	for _, id := range ids {
+		def := e.definitions[id]
+		if !def.appliesTo(period) {
+			continue
+		}
+		var existing Posting
+		err := e.repo.Read(ctx, PostingID(id, period.End), &existing)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return generated, fmt.Errorf("error reading accrual posting: %w", err)
+		}
+
+		amount := def.Amount
+		if def.Estimate != nil {
+			estimate, err := def.Estimate(ctx, def, period)
+			if err != nil {
+				return generated, fmt.Errorf("failed to estimate accrual %s: %w", id, err)
+			}
+			amount = estimate
+		}
+		if amount.IsZero() {
+			continue
+		}
+		if amount.IsNegative() {
+			return generated, fmt.Errorf("%w: accrual %s estimated a negative amount", ErrInvalidDefinition, id)
+		}
+
+		now := time.Now()
+		description := fmt.Sprintf("Accrual: %s", def.Description)
+		tx := &transaction.Transaction{
+			ID:          fmt.Sprintf("ACR-%s-%s", id, period.End.Format("20060102")),
+			Type:        transaction.Adjusting,
+			Status:      transaction.Draft,
+			Date:        period.End,
+			Description: description,
+			Entries: []transaction.Entry{
+				{AccountID: def.DebitAccountID, Amount: amount, Type: transaction.Debit, Description: description},
+				{AccountID: def.CreditAccountID, Amount: amount, Type: transaction.Credit, Description: description},
+			},
+			Created:      now,
+			LastModified: now,
+		}
+		posting := &Posting{
+			ID:              PostingID(id, period.End),
+			DefinitionID:    id,
+			Period:          period,
+			DebitAccountID:  def.DebitAccountID,
+			CreditAccountID: def.CreditAccountID,
+			Amount:          amount,
+			TransactionID:   tx.ID,
+			AutoReverse:     def.AutoReverse,
+		}
+		err = e.atomically(ctx, func(ctx context.Context) error {
+			if err := transaction.CreateAndPost(ctx, e.repo, e.processor, tx); err != nil {
+				return err
+			}
+			return e.repo.Create(ctx, posting)
+		})
+		if err != nil {
+			return generated, fmt.Errorf("failed to post accrual %s: %w", id, err)
+		}
+		generated = append(generated, *posting)
+	}
+
+	return generated, nil
+}
+
+// OnPeriodOpen reverses every auto-reversing accrual generated for periods
+// ending before the new period starts. Reversals are dated on the first day
+// of the new period.
+func (e *Engine) OnPeriodOpen(ctx context.Context, period Period) ([]Posting, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var postings []*Posting
+	query := storage.Query{
+		Filters: []storage.Filter{{Field: "auto_reverse", Operator: "=", Value: true}},
+		Sort:    []storage.Sort{{Field: "period.end"}, {Field: "definition_id"}},
+	}
+	if err := e.repo.Query(ctx, query, &postings); err != nil {
+		return nil, fmt.Errorf("error querying accrual postings: %w", err)
+	}
+
+	reversed := make([]Posting, 0)
+	for _, posting := range postings {
+		if !posting.AutoReverse || posting.ReversedAt != nil || !posting.Period.End.Before(period.Start) {
+			continue
+		}
+
+		now := time.Now()
+		description := fmt.Sprintf("Reversal of accrual %s", posting.TransactionID)
+		tx := &transaction.Transaction{
+			ID:           fmt.Sprintf("REV-%s", posting.TransactionID),
+			Type:         transaction.Reversal,
+			Status:       transaction.Draft,
+			Date:         period.Start,
+			Description:  description,
+			ReversedFrom: posting.TransactionID,
+			Created:      now,
+			LastModified: now,
+		}
+		tx.Entries = []transaction.Entry{
+			{AccountID: posting.CreditAccountID, Amount: posting.Amount, Type: transaction.Debit, Description: description},
+			{AccountID: posting.DebitAccountID, Amount: posting.Amount, Type: transaction.Credit, Description: description},
+		}
+
+		err := e.atomically(ctx, func(ctx context.Context) error {
+			if err := transaction.CreateAndPost(ctx, e.repo, e.processor, tx); err != nil {
+				return err
+			}
+			posting.ReversalID = tx.ID
+			posting.ReversedAt = &now
+			return e.repo.Update(ctx, posting)
+		})
+		if err != nil {
+			return reversed, fmt.Errorf("failed to reverse accrual %s: %w", posting.TransactionID, err)
+		}
+		reversed = append(reversed, *posting)
+	}
+
+	return reversed, nil
+}
+
+// atomically runs fn in a storage transaction when the repository supports
+// them, so an accrual and its posting are stored together
+func (e *Engine) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if manager, ok := e.repo.(storage.TransactionManager); ok {
+		return manager.WithTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,185 @@
+package accrual
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// AddSchedule splits a schedule's amount straight-line over its periods and
+// posts every installment at once, each dated on the last day of its month.
+// Rounding differences are taken up by the final installment. The
+// installments are posted as one batch, so a failure posts none of them.
+func (e *Engine) AddSchedule(ctx context.Context, schedule *Schedule) error {
+	if err := validateSchedule(schedule); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.schedules[schedule.ID]; ok {
+		return fmt.Errorf("%w: schedule %s already exists", ErrInvalidSchedule, schedule.ID)
+	}
+
+	installments := BuildInstallments(schedule)
+	txs := make([]*transaction.Transaction, len(installments))
+	for i := range installments {
+		txs[i] = schedule.recognitionEntry(&installments[i])
+		installments[i].TransactionID = txs[i].ID
+	}
+	if err := transaction.CreateAndPostBatch(ctx, e.repo, e.processor, txs); err != nil {
+		return fmt.Errorf("failed to post schedule %s: %w", schedule.ID, err)
+	}
+
+	schedule.Installments = installments
+	e.schedules[schedule.ID] = schedule
+	return nil
+}
+
+// Schedules returns the recognition schedules added so far, ordered by ID
+func (e *Engine) Schedules() []Schedule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	schedules := make([]Schedule, 0, len(e.schedules))
+	for _, s := range e.schedules {
+		copied := *s
+		copied.Installments = append([]Installment(nil), s.Installments...)
+		schedules = append(schedules, copied)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+// CancelSchedule reverses the installments of a schedule falling after a
+// date, for example when a prepaid contract is terminated. Each reversal is
+// dated on its installment's period end, so no later period recognizes any
+// of the cancelled amount. The remaining balance is left on the deferral
+// account.
+func (e *Engine) CancelSchedule(ctx context.Context, id string, after time.Time) ([]Installment, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	schedule, ok := e.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+
+	reversed := make([]Installment, 0)
+	for i := range schedule.Installments {
+		installment := &schedule.Installments[i]
+		if !installment.Date.After(after) || installment.ReversedAt != nil {
+			continue
+		}
+		now := time.Now()
+		description := fmt.Sprintf("Reversal of %s", installment.TransactionID)
+		original := schedule.recognitionEntry(installment)
+		tx := &transaction.Transaction{
+			ID:           fmt.Sprintf("REV-%s", installment.TransactionID),
+			Type:         transaction.Reversal,
+			Status:       transaction.Draft,
+			Date:         installment.Date,
+			Description:  description,
+			ReversedFrom: installment.TransactionID,
+			Created:      now,
+			LastModified: now,
+		}
+		for _, entry := range original.Entries {
+			entry.Type = entry.Type.Reverse()
+			entry.Description = description
+			tx.Entries = append(tx.Entries, entry)
+		}
+		if err := transaction.CreateAndPost(ctx, e.repo, e.processor, tx); err != nil {
+			return reversed, fmt.Errorf("failed to reverse installment %s: %w", installment.TransactionID, err)
+		}
+		installment.ReversalID = tx.ID
+		installment.ReversedAt = &now
+		reversed = append(reversed, *installment)
+	}
+	return reversed, nil
+}
+
+// BuildInstallments splits a schedule's amount into equal monthly
+// installments rounded to the currency, the last one absorbing the rounding
+// difference
+func BuildInstallments(schedule *Schedule) []Installment {
+	total := schedule.Amount.Amount
+	each := money.Money{
+		Amount:   total.Div(decimal.NewFromInt(int64(schedule.Periods))),
+		Currency: schedule.Amount.Currency,
+	}.RoundToCurrency().Amount
+
+	start := schedule.StartDate
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	installments := make([]Installment, schedule.Periods)
+	remaining := total
+	for i := range installments {
+		amount := each
+		if i == len(installments)-1 {
+			amount = remaining
+		}
+		remaining = remaining.Sub(amount)
+		installments[i] = Installment{
+			Number: i + 1,
+			Date:   first.AddDate(0, i+1, -1),
+			Amount: money.Money{Amount: amount, Currency: schedule.Amount.Currency},
+		}
+	}
+	return installments
+}
+
+// recognitionEntry builds the transaction releasing an installment from the
+// deferral account. Prepaid expenses debit the expense and deferred revenue
+// debits the liability.
+func (s *Schedule) recognitionEntry(installment *Installment) *transaction.Transaction {
+	now := time.Now()
+	description := fmt.Sprintf("%s %d/%d: %s", s.Kind.label(), installment.Number, s.Periods, s.Description)
+	debit, credit := s.RecognitionAccountID, s.DeferralAccountID
+	if s.Kind == DeferredRevenue {
+		debit, credit = credit, debit
+	}
+	return &transaction.Transaction{
+		ID:          fmt.Sprintf("SCH-%s-%03d", s.ID, installment.Number),
+		Type:        transaction.Journal,
+		Status:      transaction.Draft,
+		Date:        installment.Date,
+		Description: description,
+		Reference:   s.ID,
+		Entries: []transaction.Entry{
+			{AccountID: debit, Amount: installment.Amount, Type: transaction.Debit, Description: description},
+			{AccountID: credit, Amount: installment.Amount, Type: transaction.Credit, Description: description},
+		},
+		Created:      now,
+		LastModified: now,
+	}
+}
+
+func validateSchedule(schedule *Schedule) error {
+	if schedule == nil || schedule.ID == "" {
+		return fmt.Errorf("%w: ID is required", ErrInvalidSchedule)
+	}
+	if schedule.Kind != PrepaidExpense && schedule.Kind != DeferredRevenue {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidSchedule, schedule.Kind)
+	}
+	if schedule.DeferralAccountID == "" || schedule.RecognitionAccountID == "" {
+		return fmt.Errorf("%w: deferral and recognition accounts are required", ErrInvalidSchedule)
+	}
+	if schedule.DeferralAccountID == schedule.RecognitionAccountID {
+		return fmt.Errorf("%w: deferral and recognition accounts must differ", ErrInvalidSchedule)
+	}
+	if !schedule.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidSchedule)
+	}
+	if schedule.Periods <= 0 {
+		return fmt.Errorf("%w: at least one period is required", ErrInvalidSchedule)
+	}
+	if schedule.StartDate.IsZero() {
+		return fmt.Errorf("%w: start date is required", ErrInvalidSchedule)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package accrual
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// Period represents an accounting period an accrual is generated for
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// EstimateFunc estimates the amount to accrue for a definition in a period
+type EstimateFunc func(ctx context.Context, def *Definition, period Period) (money.Money, error)
+
+// Definition describes a recurring accrual
+type Definition struct {
+	// Unique identifier of the accrual
+	ID string
+	// Human-readable description used on generated entries
+	Description string
+	// Account debited when the accrual is generated (typically an expense)
+	DebitAccountID string
+	// Account credited when the accrual is generated (typically an accrued liability)
+	CreditAccountID string
+	// Fixed amount to accrue each period; ignored when Estimate is set
+	Amount money.Money
+	// Optional callback computing the amount for each period
+	Estimate EstimateFunc
+	// First period end the accrual applies to
+	StartDate time.Time
+	// Optional last period end the accrual applies to
+	EndDate *time.Time
+	// Whether the accrual is reversed at the start of the next period
+	AutoReverse bool
+	// Whether the accrual is currently active
+	Active bool
+}
+
+// appliesTo returns true if the definition should be generated for a period
+func (d *Definition) appliesTo(period Period) bool {
+	if !d.Active || period.End.Before(d.StartDate) {
+		return false
+	}
+	return d.EndDate == nil || !period.End.After(*d.EndDate)
+}
+
+// Posting records an accrual entry generated for a period. Postings are
+// stored in the engine's repository, one per definition and period.
+type Posting struct {
+	ID              string      `json:"id"`
+	DefinitionID    string      `json:"definition_id"`
+	Period          Period      `json:"period"`
+	DebitAccountID  string      `json:"debit_account_id"`
+	CreditAccountID string      `json:"credit_account_id"`
+	Amount          money.Money `json:"amount"`
+	TransactionID   string      `json:"transaction_id"`
+	AutoReverse     bool        `json:"auto_reverse"`
+	// ID of the reversing transaction, once reversed
+	ReversalID string     `json:"reversal_id,omitempty"`
+	ReversedAt *time.Time `json:"reversed_at,omitempty"`
+	// Version the posting was stored at, set by the repository
+	Version int64 `json:"version,omitempty"`
+}
+
+// GetID returns the posting identifier
+func (p *Posting) GetID() string { return p.ID }
+
+// GetVersion returns the version the posting was stored at
+func (p *Posting) GetVersion() int64 { return p.Version }
+
+// SetVersion records the version the posting was stored at
+func (p *Posting) SetVersion(version int64) { p.Version = version }
+
+// PostingID returns the ID of the posting of a definition for the period
+// ending at end
+func PostingID(definitionID string, end time.Time) string {
+	return fmt.Sprintf("ACCRUAL-%s-%s", definitionID, end.Format("20060102"))
+}
+
+// ScheduleKind identifies what a recognition schedule releases
+type ScheduleKind string
+
+const (
+	// PrepaidExpense releases a prepaid asset to expense
+	PrepaidExpense ScheduleKind = "PREPAID_EXPENSE"
+	// DeferredRevenue releases a deferred revenue liability to revenue
+	DeferredRevenue ScheduleKind = "DEFERRED_REVENUE"
+)
+
+// label returns the prefix used on entry descriptions
+func (k ScheduleKind) label() string {
+	if k == DeferredRevenue {
+		return "Revenue recognition"
+	}
+	return "Prepaid expense"
+}
+
+// Schedule describes an amount paid or billed up front and recognized
+// straight-line over a number of months
+type Schedule struct {
+	// Unique identifier of the schedule, e.g. the contract or invoice
+	ID string
+	// Human-readable description used on generated entries
+	Description string
+	Kind        ScheduleKind
+	// Total amount to recognize
+	Amount money.Money
+	// Balance sheet account holding the unrecognized amount: the prepaid
+	// asset or the deferred revenue liability
+	DeferralAccountID string
+	// Account the amount is recognized in: the expense or the revenue
+	RecognitionAccountID string
+	// Any date in the first month recognized
+	StartDate time.Time
+	// Number of months the amount is spread over, e.g. 12
+	Periods int
+	// Installments posted for the schedule
+	Installments []Installment
+}
+
+// Installment records the amount of a schedule recognized in one month
+type Installment struct {
+	Number int
+	// Last day of the month the installment is recognized in
+	Date          time.Time
+	Amount        money.Money
+	TransactionID string
+	// ID of the reversing transaction, once the schedule is cancelled
+	ReversalID string
+	ReversedAt *time.Time
+}
@@ -0,0 +1,231 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor records transactions, and who posted them, instead of
+// storing them
+type recordingProcessor struct {
+	transaction.TransactionProcessor
+	posted     []*transaction.Transaction
+	principals []string
+	actors     []string
+}
+
+func (p *recordingProcessor) ValidateTransaction(ctx context.Context, tx *transaction.Transaction) (*transaction.ValidationResult, error) {
+	return &transaction.ValidationResult{Valid: true}, nil
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	if err := auth.Require(ctx, auth.TransactionPost); err != nil {
+		return err
+	}
+	tx.Status = transaction.Posted
+	p.posted = append(p.posted, tx)
+	p.principals = append(p.principals, auth.PrincipalID(ctx))
+	p.actors = append(p.actors, storage.ActorFromContext(ctx).UserID)
+	return nil
+}
+
+// eventRecorder collects published event types
+type eventRecorder struct {
+	types []string
+}
+
+func (r *eventRecorder) Handle(ctx context.Context, e event.Event) error {
+	r.types = append(r.types, e.Type)
+	return nil
+}
+
+func newTransaction(id string, amount int64) *transaction.Transaction {
+	m := money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	return &transaction.Transaction{
+		ID:     id,
+		Type:   transaction.Journal,
+		Status: transaction.Draft,
+		Date:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Entries: []transaction.Entry{
+			{AccountID: "6000", Amount: m, Type: transaction.Debit},
+			{AccountID: "1000", Amount: m, Type: transaction.Credit},
+		},
+	}
+}
+
+func principal(id string, roles ...string) context.Context {
+	p := &auth.Principal{ID: id}
+	for _, role := range append([]string{"staff"}, roles...) {
+		p.Roles = append(p.Roles, &auth.Role{Name: role, Permissions: []auth.Permission{auth.TransactionPost, auth.TransactionApprove}})
+	}
+	return auth.WithPrincipal(context.Background(), p)
+}
+
+func newEngine(t *testing.T) (*Engine, *recordingProcessor, *eventRecorder) {
+	bus := event.NewMemoryBus()
+	recorder := &eventRecorder{}
+	for _, eventType := range []string{event.ApprovalRequested, event.ApprovalStepCompleted, event.ApprovalApproved, event.ApprovalRejected, event.ApprovalExpired} {
+		require.NoError(t, bus.Subscribe(eventType, recorder))
+	}
+
+	processor := &recordingProcessor{}
+	engine := NewEngine(memory.NewMemoryStore(), processor, bus)
+	require.NoError(t, engine.DefineChain(&Chain{
+		ID:       "LARGE",
+		Priority: 10,
+		Criteria: Criteria{MinAmount: decimal.NewFromInt(10000)},
+		Steps: []Step{
+			{Name: "Managers", Approvers: []string{"m1", "m2"}, Mode: Sequential},
+			{Name: "Finance", Roles: []string{"controller"}, Mode: Parallel},
+		},
+		Expiry: 48 * time.Hour,
+	}))
+	return engine, processor, recorder
+}
+
+func TestCriteriaMatches(t *testing.T) {
+	tx := newTransaction("TX1", 500)
+
+	assert.True(t, Criteria{}.Matches(tx))
+	assert.True(t, Criteria{MinAmount: decimal.NewFromInt(100), MaxAmount: decimal.NewFromInt(500)}.Matches(tx))
+	assert.False(t, Criteria{MinAmount: decimal.NewFromInt(501)}.Matches(tx))
+	assert.True(t, Criteria{AccountIDs: []string{"1000"}}.Matches(tx))
+	assert.False(t, Criteria{AccountIDs: []string{"2000"}}.Matches(tx))
+	assert.False(t, Criteria{TransactionTypes: []transaction.TransactionType{transaction.Transfer}}.Matches(tx))
+}
+
+func TestDefineChainValidation(t *testing.T) {
+	engine := NewEngine(memory.NewMemoryStore(), &recordingProcessor{}, nil)
+
+	assert.ErrorIs(t, engine.DefineChain(&Chain{ID: "EMPTY"}), ErrInvalidChain)
+	assert.ErrorIs(t, engine.DefineChain(&Chain{ID: "X", Steps: []Step{{Name: "none", Mode: Parallel}}}), ErrInvalidChain)
+	assert.ErrorIs(t, engine.DefineChain(&Chain{ID: "X", Steps: []Step{{Approvers: []string{"a"}, Mode: "ANY"}}}), ErrInvalidChain)
+}
+
+func TestApprovalWorkflow(t *testing.T) {
+	t.Run("unmatched transactions post immediately", func(t *testing.T) {
+		engine, processor, _ := newEngine(t)
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX1", 50))
+		require.NoError(t, err)
+		assert.Nil(t, req)
+		assert.Len(t, processor.posted, 1)
+	})
+
+	t.Run("sequential then role step", func(t *testing.T) {
+		engine, processor, recorder := newEngine(t)
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX2", 20000))
+		require.NoError(t, err)
+		require.NotNil(t, req)
+		assert.Equal(t, transaction.Pending, req.Transaction.Status)
+
+		_, err = engine.Approve(principal("clerk", "controller"), req.ID, "")
+		assert.ErrorIs(t, err, ErrSelfApproval)
+
+		_, err = engine.Approve(principal("m2"), req.ID, "")
+		assert.ErrorIs(t, err, ErrNotApprover, "m2 must wait for m1")
+
+		_, err = engine.Approve(principal("m1"), req.ID, "ok")
+		require.NoError(t, err)
+		req, err = engine.Approve(principal("m2"), req.ID, "ok")
+		require.NoError(t, err)
+		assert.Equal(t, 1, req.CurrentStep)
+		assert.Empty(t, processor.posted)
+
+		req, err = engine.Approve(principal("c1", "controller"), req.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, Approved, req.Status)
+		require.Len(t, processor.posted, 1)
+		assert.Equal(t, "clerk", processor.posted[0].CreatedBy)
+		assert.Equal(t, []string{WorkflowPrincipalID}, processor.principals)
+		assert.Equal(t, []string{"c1"}, processor.actors, "the final approver is the actor")
+
+		assert.Equal(t, []string{
+			event.ApprovalRequested,
+			event.ApprovalStepCompleted,
+			event.ApprovalStepCompleted,
+			event.ApprovalApproved,
+		}, recorder.types)
+	})
+
+	t.Run("delegation", func(t *testing.T) {
+		engine, _, _ := newEngine(t)
+		require.NoError(t, engine.Delegate(Delegation{
+			From:  "m1",
+			To:    "d1",
+			Start: time.Now().Add(-time.Hour),
+			End:   time.Now().Add(time.Hour),
+		}))
+
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX3", 20000))
+		require.NoError(t, err)
+
+		req, err = engine.Approve(principal("d1"), req.ID, "covering")
+		require.NoError(t, err)
+		assert.Equal(t, "m1", req.Decisions[0].OnBehalfOf)
+	})
+
+	t.Run("rejection returns transaction to draft", func(t *testing.T) {
+		engine, _, recorder := newEngine(t)
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX4", 20000))
+		require.NoError(t, err)
+
+		req, err = engine.Reject(principal("m1"), req.ID, "missing receipt")
+		require.NoError(t, err)
+		assert.Equal(t, Rejected, req.Status)
+		assert.Equal(t, transaction.Draft, req.Transaction.Status)
+		assert.Contains(t, recorder.types, event.ApprovalRejected)
+
+		_, err = engine.Approve(principal("m1"), req.ID, "")
+		assert.ErrorIs(t, err, ErrNotPending)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		engine, _, recorder := newEngine(t)
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX5", 20000))
+		require.NoError(t, err)
+
+		engine.now = func() time.Time { return time.Now().Add(72 * time.Hour) }
+		_, err = engine.Approve(principal("m1"), req.ID, "")
+		assert.ErrorIs(t, err, ErrRequestExpired)
+
+		stored, err := engine.GetRequest(context.Background(), req.ID)
+		require.NoError(t, err)
+		assert.Equal(t, Expired, stored.Status)
+		assert.Contains(t, recorder.types, event.ApprovalExpired)
+	})
+
+	t.Run("approval requires a principal", func(t *testing.T) {
+		engine, _, _ := newEngine(t)
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX6", 20000))
+		require.NoError(t, err)
+
+		_, err = engine.Approve(context.Background(), req.ID, "")
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+
+	t.Run("posting checks the workflow principal's permissions", func(t *testing.T) {
+		engine, processor, _ := newEngine(t)
+		engine.SetWorkflowPrincipal(&auth.Principal{ID: "read-only"})
+		req, err := engine.Submit(principal("clerk"), newTransaction("TX7", 20000))
+		require.NoError(t, err)
+
+		_, err = engine.Approve(principal("m1"), req.ID, "")
+		require.NoError(t, err)
+		_, err = engine.Approve(principal("m2"), req.ID, "")
+		require.NoError(t, err)
+		_, err = engine.Approve(principal("c1", "controller"), req.ID, "")
+		assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+		assert.Empty(t, processor.posted)
+	})
+}
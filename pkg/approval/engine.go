@@ -0,0 +1,475 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidChain      = errors.New("invalid approval chain")
+	ErrInvalidDelegation = errors.New("invalid delegation")
+	ErrRequestNotFound   = errors.New("approval request not found")
+	ErrNotPending        = errors.New("approval request is not pending")
+	ErrNotApprover       = errors.New("principal cannot approve this step")
+	ErrSelfApproval      = errors.New("submitter cannot approve their own transaction")
+	ErrRequestExpired    = errors.New("approval request has expired")
+)
+
+// WorkflowPrincipalID identifies the approval workflow when it posts
+// approved transactions
+const WorkflowPrincipalID = "approval-workflow"
+
+// WorkflowPrincipal returns the default principal approved transactions are
+// posted as, holding only the transaction.post permission
+func WorkflowPrincipal() *auth.Principal {
+	return &auth.Principal{
+		ID:    WorkflowPrincipalID,
+		Name:  "Approval workflow",
+		Roles: []*auth.Role{{Name: WorkflowPrincipalID, Permissions: []auth.Permission{auth.TransactionPost}}},
+	}
+}
+
+// Engine matches transactions to approval chains and tracks their requests
+type Engine struct {
+	mu          sync.RWMutex
+	repo        storage.Repository
+	processor   transaction.TransactionProcessor
+	bus         event.Publisher
+	workflow    *auth.Principal
+	chains      []*Chain
+	delegations []Delegation
+	now         func() time.Time
+}
+
+// NewEngine creates an approval engine. Requests are stored in repo, approved
+// transactions are posted through processor as WorkflowPrincipal and
+// workflow events are published to bus when it is non-nil.
+func NewEngine(repo storage.Repository, processor transaction.TransactionProcessor, bus event.Publisher) *Engine {
+	return &Engine{
+		repo:      repo,
+		processor: processor,
+		bus:       bus,
+		workflow:  WorkflowPrincipal(),
+		now:       time.Now,
+	}
+}
+
+// SetWorkflowPrincipal sets the principal approved transactions are posted
+// as. It must hold the transaction.post permission.
+func (e *Engine) SetWorkflowPrincipal(principal *auth.Principal) {
+	e.workflow = principal
+}
+
+// DefineChain adds or replaces an approval chain
+func (e *Engine) DefineChain(chain *Chain) error {
+	if chain == nil || chain.ID == "" {
+		return fmt.Errorf("%w: chain ID is required", ErrInvalidChain)
+	}
+	if len(chain.Steps) == 0 {
+		return fmt.Errorf("%w: chain %s has no steps", ErrInvalidChain, chain.ID)
+	}
+	for i, step := range chain.Steps {
+		if len(step.Approvers) == 0 && len(step.Roles) == 0 {
+			return fmt.Errorf("%w: step %d has no approvers", ErrInvalidChain, i)
+		}
+		if step.Mode != Sequential && step.Mode != Parallel {
+			return fmt.Errorf("%w: step %d has invalid mode %q", ErrInvalidChain, i, step.Mode)
+		}
+		if len(step.Approvers) > 0 && step.Required > len(step.Approvers) {
+			return fmt.Errorf("%w: step %d requires more approvals than approvers", ErrInvalidChain, i)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, existing := range e.chains {
+		if existing.ID == chain.ID {
+			e.chains[i] = chain
+			return nil
+		}
+	}
+	e.chains = append(e.chains, chain)
+	sort.SliceStable(e.chains, func(i, j int) bool {
+		return e.chains[i].Priority > e.chains[j].Priority
+	})
+	return nil
+}
+
+// Delegate allows one principal to approve on behalf of another
+func (e *Engine) Delegate(d Delegation) error {
+	if d.From == "" || d.To == "" || d.From == d.To {
+		return fmt.Errorf("%w: distinct from and to principals are required", ErrInvalidDelegation)
+	}
+	if d.End.Before(d.Start) {
+		return fmt.Errorf("%w: delegation ends before it starts", ErrInvalidDelegation)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.delegations = append(e.delegations, d)
+	return nil
+}
+
+// ChainFor returns the highest priority chain matching a transaction
+func (e *Engine) ChainFor(tx *transaction.Transaction) (*Chain, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, chain := range e.chains {
+		if chain.Criteria.Matches(tx) {
+			return chain, true
+		}
+	}
+	return nil, false
+}
+
+// Submit routes a transaction for posting. Transactions matching a chain are
+// held in Pending status until approved; all others are posted immediately
+// and a nil request is returned.
+func (e *Engine) Submit(ctx context.Context, tx *transaction.Transaction) (*Request, error) {
+	if err := auth.Require(ctx, auth.TransactionPost); err != nil {
+		return nil, err
+	}
+
+	chain, ok := e.ChainFor(tx)
+	if !ok {
+		if err := e.processor.ProcessTransaction(ctx, tx); err != nil {
+			return nil, fmt.Errorf("failed to post transaction: %w", err)
+		}
+		return nil, nil
+	}
+
+	result, err := e.processor.ValidateTransaction(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate transaction: %w", err)
+	}
+	if !result.Valid {
+		return nil, fmt.Errorf("transaction validation failed: %v", result.Errors)
+	}
+
+	now := e.now()
+	submitter := auth.PrincipalID(ctx)
+	if tx.CreatedBy == "" {
+		tx.CreatedBy = submitter
+	}
+	tx.Status = transaction.Pending
+	tx.LastModified = now
+
+	req := &Request{
+		ID:          fmt.Sprintf("APR-%s", tx.ID),
+		ChainID:     chain.ID,
+		Transaction: tx,
+		Status:      Pending,
+		SubmittedBy: submitter,
+		Submitted:   now,
+	}
+	if chain.Expiry > 0 {
+		expires := now.Add(chain.Expiry)
+		req.ExpiresAt = &expires
+	}
+
+	if err := e.repo.Create(ctx, req); err != nil {
+		return nil, fmt.Errorf("failed to store approval request: %w", err)
+	}
+	e.publish(ctx, event.ApprovalRequested, req, submitter, "")
+	return req, nil
+}
+
+// GetRequest retrieves an approval request by ID
+func (e *Engine) GetRequest(ctx context.Context, id string) (*Request, error) {
+	var req Request
+	if err := e.repo.Read(ctx, id, &req); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrRequestNotFound, id, err)
+	}
+	return &req, nil
+}
+
+// Approve records the context principal's approval of the current step.
+// When the final step completes the transaction is posted.
+func (e *Engine) Approve(ctx context.Context, requestID, comment string) (*Request, error) {
+	req, chain, decision, err := e.decide(ctx, requestID, comment, true)
+	if err != nil {
+		return nil, err
+	}
+
+	step := chain.Steps[req.CurrentStep]
+	if len(req.stepDecisions(req.CurrentStep)) < requiredApprovals(step) {
+		return req, e.save(ctx, req)
+	}
+
+	e.publish(ctx, event.ApprovalStepCompleted, req, decision.ApproverID, comment)
+	req.CurrentStep++
+	if req.CurrentStep < len(chain.Steps) {
+		return req, e.save(ctx, req)
+	}
+
+	// The chain is complete; posting is performed by the workflow principal
+	// rather than the final approver, who may not hold posting rights. The
+	// approver is kept as the actor, so the change is still attributed to
+	// them.
+	if err := e.processor.ProcessTransaction(e.postingContext(ctx, decision), req.Transaction); err != nil {
+		return nil, fmt.Errorf("failed to post approved transaction: %w", err)
+	}
+	e.complete(req, Approved)
+	if err := e.save(ctx, req); err != nil {
+		return nil, err
+	}
+	e.publish(ctx, event.ApprovalApproved, req, decision.ApproverID, comment)
+	return req, nil
+}
+
+// Reject records the context principal's rejection of the current step and
+// returns the transaction to Draft status
+func (e *Engine) Reject(ctx context.Context, requestID, reason string) (*Request, error) {
+	req, _, decision, err := e.decide(ctx, requestID, reason, false)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Transaction.Status = transaction.Draft
+	e.complete(req, Rejected)
+	if err := e.save(ctx, req); err != nil {
+		return nil, err
+	}
+	e.publish(ctx, event.ApprovalRejected, req, decision.ApproverID, reason)
+	return req, nil
+}
+
+// postingContext returns the context approved transactions are posted in:
+// the workflow principal acting for the final approver
+func (e *Engine) postingContext(ctx context.Context, decision Decision) context.Context {
+	actor := storage.ActorFromContext(ctx)
+	actor.UserID = decision.ApproverID
+	if actor.Source == "" {
+		actor.Source = "approval"
+	}
+	return storage.WithActor(auth.WithPrincipal(ctx, e.workflow), actor)
+}
+
+// decide loads a pending request, checks the context principal may act on
+// its current step and records their decision
+func (e *Engine) decide(ctx context.Context, requestID, comment string, approved bool) (*Request, *Chain, Decision, error) {
+	if err := auth.RequireAuthenticated(ctx, auth.TransactionApprove); err != nil {
+		return nil, nil, Decision{}, err
+	}
+
+	req, err := e.GetRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, Decision{}, err
+	}
+	if req.Status != Pending {
+		return nil, nil, Decision{}, fmt.Errorf("%w: %s is %s", ErrNotPending, req.ID, req.Status)
+	}
+
+	now := e.now()
+	if req.ExpiresAt != nil && now.After(*req.ExpiresAt) {
+		e.complete(req, Expired)
+		if err := e.save(ctx, req); err != nil {
+			return nil, nil, Decision{}, err
+		}
+		e.publish(ctx, event.ApprovalExpired, req, "", "")
+		return nil, nil, Decision{}, fmt.Errorf("%w: %s", ErrRequestExpired, req.ID)
+	}
+
+	chain, err := e.chain(req.ChainID)
+	if err != nil {
+		return nil, nil, Decision{}, err
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	if principal.ID == req.SubmittedBy {
+		return nil, nil, Decision{}, ErrSelfApproval
+	}
+
+	onBehalfOf, err := e.eligibleAs(principal, chain.Steps[req.CurrentStep], req, now)
+	if err != nil {
+		return nil, nil, Decision{}, err
+	}
+
+	decision := Decision{
+		Step:       req.CurrentStep,
+		ApproverID: principal.ID,
+		OnBehalfOf: onBehalfOf,
+		Approved:   approved,
+		Comment:    comment,
+		At:         now,
+	}
+	req.Decisions = append(req.Decisions, decision)
+	return req, chain, decision, nil
+}
+
+// eligibleAs determines whether a principal can act on a step, returning the
+// approver they act on behalf of when the right comes from a delegation
+func (e *Engine) eligibleAs(principal *auth.Principal, step Step, req *Request, at time.Time) (string, error) {
+	acted := make(map[string]bool)
+	for _, d := range req.stepDecisions(req.CurrentStep) {
+		acted[d.ApproverID] = true
+		if d.OnBehalfOf != "" {
+			acted[d.OnBehalfOf] = true
+		}
+	}
+
+	// Approvers that may still act on the step
+	candidates := step.Approvers
+	if step.Mode == Sequential && len(step.Approvers) > 0 {
+		next := len(req.stepDecisions(req.CurrentStep))
+		if next >= len(step.Approvers) {
+			return "", ErrNotApprover
+		}
+		candidates = step.Approvers[next : next+1]
+	}
+
+	for _, approver := range candidates {
+		if acted[approver] {
+			continue
+		}
+		if approver == principal.ID {
+			return "", nil
+		}
+		if e.delegatedTo(approver, principal.ID, at) {
+			return approver, nil
+		}
+	}
+
+	if !acted[principal.ID] {
+		for _, role := range step.Roles {
+			if principal.HasRole(role) {
+				return "", nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s on step %q", ErrNotApprover, principal.ID, step.Name)
+}
+
+func (e *Engine) delegatedTo(from, to string, at time.Time) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, d := range e.delegations {
+		if d.From == from && d.To == to && d.Active(at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) chain(id string) (*Chain, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, chain := range e.chains {
+		if chain.ID == id {
+			return chain, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown chain %s", ErrInvalidChain, id)
+}
+
+func (e *Engine) complete(req *Request, status Status) {
+	now := e.now()
+	req.Status = status
+	req.Completed = &now
+}
+
+func (e *Engine) save(ctx context.Context, req *Request) error {
+	if err := e.repo.Update(ctx, req); err != nil {
+		return fmt.Errorf("failed to store approval request: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) publish(ctx context.Context, eventType string, req *Request, approverID, comment string) {
+	if e.bus == nil {
+		return
+	}
+
+	now := e.now()
+	_ = e.bus.Publish(ctx, event.Event{
+		ID:        fmt.Sprintf("%s-%d", req.ID, now.UnixNano()),
+		Type:      eventType,
+		Timestamp: now,
+		Source:    "approval",
+		Data: event.ApprovalV1{
+			RequestID:     req.ID,
+			TransactionID: req.Transaction.ID,
+			ChainID:       req.ChainID,
+			Step:          req.CurrentStep,
+			ApproverID:    approverID,
+			Status:        string(req.Status),
+			Comment:       comment,
+		},
+	})
+}
+
+// requiredApprovals returns the number of approvals needed to complete a step
+func requiredApprovals(step Step) int {
+	switch {
+	case step.Mode == Sequential && len(step.Approvers) > 0:
+		return len(step.Approvers)
+	case step.Required > 0:
+		return step.Required
+	case len(step.Approvers) > 0:
+		return len(step.Approvers)
+	default:
+		return 1
+	}
+}
+
+// Matches reports whether a transaction meets the criteria
+func (c Criteria) Matches(tx *transaction.Transaction) bool {
+	if len(c.TransactionTypes) > 0 && !containsType(c.TransactionTypes, tx.Type) {
+		return false
+	}
+
+	if len(c.AccountIDs) > 0 {
+		touched := false
+		for _, entry := range tx.Entries {
+			for _, id := range c.AccountIDs {
+				if entry.AccountID == id {
+					touched = true
+				}
+			}
+		}
+		if !touched {
+			return false
+		}
+	}
+
+	total := decimal.Zero
+	for _, entry := range tx.Entries {
+		if entry.Type != transaction.Debit {
+			continue
+		}
+		if c.Currency != "" && entry.Amount.Currency != c.Currency {
+			continue
+		}
+		total = total.Add(entry.Amount.Amount)
+	}
+	if total.LessThan(c.MinAmount) {
+		return false
+	}
+	if !c.MaxAmount.IsZero() && total.GreaterThan(c.MaxAmount) {
+		return false
+	}
+	return true
+}
+
+func containsType(types []transaction.TransactionType, t transaction.TransactionType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+// Package approval routes transactions through configurable multi-level
+// approval chains before they are posted.
+package approval
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// Mode controls how the approvers within a step act
+type Mode string
+
+const (
+	// Sequential steps require approvers to sign off in the listed order
+	Sequential Mode = "SEQUENTIAL"
+	// Parallel steps accept sign-offs in any order
+	Parallel Mode = "PARALLEL"
+)
+
+// Criteria selects the transactions a chain applies to. Empty fields match
+// any transaction.
+type Criteria struct {
+	// Minimum total debit amount, inclusive
+	MinAmount decimal.Decimal `json:"min_amount"`
+	// Maximum total debit amount, inclusive; zero means unbounded
+	MaxAmount decimal.Decimal `json:"max_amount"`
+	// Currency the amount bounds apply to
+	Currency string `json:"currency,omitempty"`
+	// Accounts of which at least one must be touched by the transaction
+	AccountIDs []string `json:"account_ids,omitempty"`
+	// Transaction types the chain applies to
+	TransactionTypes []transaction.TransactionType `json:"transaction_types,omitempty"`
+}
+
+// Step is a single level of an approval chain
+type Step struct {
+	// Display name of the step (e.g., "Controller review")
+	Name string `json:"name"`
+	// Principal IDs allowed to approve the step
+	Approvers []string `json:"approvers,omitempty"`
+	// Roles whose holders may approve the step
+	Roles []string `json:"roles,omitempty"`
+	// How approvers within the step act
+	Mode Mode `json:"mode"`
+	// Number of approvals needed to complete a parallel step; zero requires
+	// every listed approver, or one approval for role-only steps
+	Required int `json:"required,omitempty"`
+}
+
+// Chain defines the approval levels required for matching transactions
+type Chain struct {
+	// Unique identifier for the chain
+	ID string `json:"id"`
+	// Display name
+	Name string `json:"name"`
+	// Higher priority chains are matched first
+	Priority int `json:"priority"`
+	// Transactions the chain applies to
+	Criteria Criteria `json:"criteria"`
+	// Approval levels, completed in order
+	Steps []Step `json:"steps"`
+	// Time allowed to complete the chain; zero means no expiry
+	Expiry time.Duration `json:"expiry,omitempty"`
+}
+
+// Delegation allows one principal to approve on behalf of another
+type Delegation struct {
+	// Principal delegating their approvals
+	From string `json:"from"`
+	// Principal receiving the delegation
+	To string `json:"to"`
+	// Start of the delegation window
+	Start time.Time `json:"start"`
+	// End of the delegation window
+	End time.Time `json:"end"`
+}
+
+// Active reports whether the delegation applies at a point in time
+func (d Delegation) Active(at time.Time) bool {
+	return !at.Before(d.Start) && !at.After(d.End)
+}
+
+// Status represents the state of an approval request
+type Status string
+
+const (
+	Pending  Status = "PENDING"
+	Approved Status = "APPROVED"
+	Rejected Status = "REJECTED"
+	Expired  Status = "EXPIRED"
+)
+
+// Decision records a single approver's action on a request
+type Decision struct {
+	// Step the decision applies to
+	Step int `json:"step"`
+	// Principal that made the decision
+	ApproverID string `json:"approver_id"`
+	// Approver the decision was made on behalf of, when delegated
+	OnBehalfOf string `json:"on_behalf_of,omitempty"`
+	// Whether the step was approved or rejected
+	Approved bool `json:"approved"`
+	// Optional comment
+	Comment string `json:"comment,omitempty"`
+	// When the decision was made
+	At time.Time `json:"at"`
+}
+
+// Request tracks a transaction moving through an approval chain
+type Request struct {
+	// Unique identifier for the request
+	ID string `json:"id"`
+	// Chain the request follows
+	ChainID string `json:"chain_id"`
+	// Transaction awaiting approval
+	Transaction *transaction.Transaction `json:"transaction"`
+	// Current status
+	Status Status `json:"status"`
+	// Index of the step awaiting approval
+	CurrentStep int `json:"current_step"`
+	// Decisions made so far
+	Decisions []Decision `json:"decisions,omitempty"`
+	// Principal that submitted the transaction
+	SubmittedBy string `json:"submitted_by,omitempty"`
+	// When the request was submitted
+	Submitted time.Time `json:"submitted"`
+	// When the request expires, if the chain has an expiry
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// When the request reached a final status
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// GetID returns the request identifier
+func (r *Request) GetID() string { return r.ID }
+
+// stepDecisions returns the approvals recorded for a step
+func (r *Request) stepDecisions(step int) []Decision {
+	var decisions []Decision
+	for _, d := range r.Decisions {
+		if d.Step == step && d.Approved {
+			decisions = append(decisions, d)
+		}
+	}
+	return decisions
+}
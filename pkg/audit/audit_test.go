@@ -0,0 +1,229 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+func (r *record) GetID() string { return r.ID }
+
+func TestCallerFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, audit.UserID(ctx))
+
+	principalCtx := auth.WithPrincipal(ctx, &auth.Principal{ID: "u1"})
+	assert.Equal(t, "u1", audit.UserID(principalCtx))
+
+	callerCtx := audit.WithCaller(principalCtx, audit.Caller{UserID: "svc", Source: "import", Reason: "nightly load"})
+	caller := audit.CallerFromContext(callerCtx)
+	assert.Equal(t, "svc", caller.UserID)
+	assert.Equal(t, map[string]interface{}{"source": "import", "reason": "nightly load"}, caller.Metadata())
+	assert.Nil(t, audit.Caller{}.Metadata())
+}
+
+func TestAuditChain(t *testing.T) {
+	store := memory.NewMemoryStore()
+	ctx := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice", Source: "api", Reason: "correction"})
+
+	r := &record{ID: "R1", Value: "a"}
+	require.NoError(t, store.Create(ctx, r))
+	r.Value = "b"
+	require.NoError(t, store.Update(audit.WithCaller(context.Background(), audit.Caller{UserID: "bob"}), r))
+	require.NoError(t, store.Create(ctx, &record{ID: "R2", Value: "c"}))
+
+	reporter := audit.NewReporter(store)
+
+	t.Run("entries are attributed", func(t *testing.T) {
+		entries, err := reporter.Query(context.Background(), storage.AuditQuery{UserID: "alice"})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "api", entries[0].Metadata["source"])
+		assert.Equal(t, "correction", entries[0].Metadata["reason"])
+	})
+
+	t.Run("chain verifies", func(t *testing.T) {
+		entries, err := reporter.Query(context.Background(), storage.AuditQuery{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, entries[0].Hash, entries[1].PreviousHash)
+		assert.NoError(t, reporter.Verify(context.Background()))
+
+		// Later mutation of the stored entity does not break the chain
+		r.Value = "mutated"
+		assert.NoError(t, reporter.Verify(context.Background()))
+	})
+
+	t.Run("tampering is detected", func(t *testing.T) {
+		entries, err := reporter.Query(context.Background(), storage.AuditQuery{})
+		require.NoError(t, err)
+
+		entries[1].UserID = "mallory"
+		err = audit.VerifyChain(entries)
+		assert.ErrorIs(t, err, audit.ErrChainBroken)
+		var chainErr *audit.ChainError
+		require.ErrorAs(t, err, &chainErr)
+		assert.Equal(t, 1, chainErr.Index)
+
+		assert.ErrorIs(t, audit.VerifyChain(append(entries[:1:1], entries[2])), audit.ErrChainBroken)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		report, err := reporter.Report(context.Background(), storage.AuditQuery{EntityID: "R1"})
+		require.NoError(t, err)
+		assert.Len(t, report.Entries, 2)
+		assert.Equal(t, 1, report.ByUser["alice"])
+		assert.Equal(t, 1, report.ByUser["bob"])
+		assert.Equal(t, 1, report.ByOperation["UPDATE"])
+		assert.True(t, report.ChainIntact)
+	})
+}
+
+func TestExporter(t *testing.T) {
+	store := memory.NewMemoryStore()
+	alice := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice"})
+	bob := audit.WithCaller(context.Background(), audit.Caller{UserID: "bob"})
+	for _, id := range []string{"r1", "r2", "r3"} {
+		require.NoError(t, store.Create(alice, &record{ID: id, Value: "v1"}))
+	}
+	require.NoError(t, store.Update(bob, &record{ID: "r2", Value: "v2, with a comma"}))
+	require.NoError(t, store.Delete(bob, "r3"))
+
+	page, err := store.QueryAuditTrail(context.Background(), storage.AuditFilter{
+		AuditQuery: storage.AuditQuery{UserID: "alice"},
+		Pagination: &storage.Pagination{Offset: 1, Limit: 1},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, "r2", page.Entries[0].EntityID)
+
+	exporter := audit.NewExporter(store)
+	exporter.SetPageSize(2)
+
+	var csvOut strings.Builder
+	n, err := exporter.Export(context.Background(), &csvOut, storage.AuditQuery{}, audit.ExportCSV)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	rows, err := csv.NewReader(strings.NewReader(csvOut.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	assert.Equal(t, "sequence", rows[0][0])
+	assert.Equal(t, []string{"UPDATE", "bob"}, rows[4][5:7])
+	assert.JSONEq(t, `{"id":"r2","value":"v2, with a comma"}`, rows[4][8])
+	assert.Equal(t, "DELETE", rows[5][5])
+
+	var jsonOut strings.Builder
+	n, err = exporter.Export(context.Background(), &jsonOut, storage.AuditQuery{Operation: "CREATE"}, audit.ExportJSON)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	var records []audit.ExportRecord
+	require.NoError(t, json.Unmarshal([]byte(jsonOut.String()), &records))
+	require.Len(t, records, 3)
+	assert.Equal(t, "r1", records[0].EntityID)
+	assert.Empty(t, records[0].PreviousState)
+
+	jsonOut.Reset()
+	_, err = exporter.Export(context.Background(), &jsonOut, storage.AuditQuery{UserID: "nobody"}, audit.ExportJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", jsonOut.String())
+
+	_, err = exporter.Export(context.Background(), &jsonOut, storage.AuditQuery{}, "xml")
+	assert.Error(t, err)
+}
+
+// tamperedLog serves a store's audit log after passing it through tamper
+type tamperedLog struct {
+	*memory.MemoryStore
+	tamper func([]storage.AuditEntry) []storage.AuditEntry
+}
+
+func (l *tamperedLog) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	entries, err := l.MemoryStore.QueryAudit(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return l.tamper(entries), nil
+}
+
+func TestVerifyAuditIntegrity(t *testing.T) {
+	ctx := context.Background()
+	signer := audit.NewHMACSigner([]byte("audit-key"))
+	store := memory.NewMemoryStore()
+	store.SetSigner(signer)
+
+	r := &record{ID: "R1", Value: "a"}
+	require.NoError(t, store.Create(ctx, r))
+	r.Value = "b"
+	require.NoError(t, store.Update(ctx, r))
+	require.NoError(t, store.Create(ctx, &record{ID: "R2", Value: "c"}))
+	require.NoError(t, store.Create(ctx, &record{ID: "R3", Value: "d"}))
+
+	verify := func(entityID string, tamper func([]storage.AuditEntry) []storage.AuditEntry) error {
+		reporter := audit.NewReporter(&tamperedLog{MemoryStore: store, tamper: tamper})
+		reporter.SetSigner(signer)
+		return reporter.VerifyAuditIntegrity(ctx, entityID)
+	}
+	untouched := func(entries []storage.AuditEntry) []storage.AuditEntry { return entries }
+
+	assert.NoError(t, verify("R1", untouched))
+	assert.NoError(t, verify("", untouched))
+	assert.NoError(t, verify("unknown", untouched))
+
+	// Deleting an entry of the entity leaves a gap
+	deleted := func(entries []storage.AuditEntry) []storage.AuditEntry {
+		return append(entries[:1:1], entries[2:]...)
+	}
+	assert.ErrorIs(t, verify("R1", deleted), audit.ErrChainBroken)
+
+	// So does deleting its latest entry
+	assert.ErrorIs(t, verify("R2", func(entries []storage.AuditEntry) []storage.AuditEntry {
+		return append(entries[:2:2], entries[3:]...)
+	}), audit.ErrChainBroken)
+
+	// Tampering after an entity's history doesn't concern it
+	modified := func(entries []storage.AuditEntry) []storage.AuditEntry {
+		entries[3].UserID = "mallory"
+		return entries
+	}
+	assert.NoError(t, verify("R1", modified))
+	assert.ErrorIs(t, verify("R3", modified), audit.ErrChainBroken)
+
+	// A chain rewritten and resealed without the key fails its signatures
+	resealed := func(entries []storage.AuditEntry) []storage.AuditEntry {
+		entries[1].UserID = "mallory"
+		for i := range entries {
+			var previous *storage.AuditEntry
+			if i > 0 {
+				previous = &entries[i-1]
+			}
+			audit.Seal(&entries[i], previous)
+		}
+		return entries
+	}
+	assert.NoError(t, audit.VerifyChain(resealed(mustQuery(t, store))))
+	err := verify("R1", resealed)
+	var chainErr *audit.ChainError
+	require.ErrorAs(t, err, &chainErr)
+	assert.Equal(t, 1, chainErr.Index)
+}
+
+func mustQuery(t *testing.T, store *memory.MemoryStore) []storage.AuditEntry {
+	entries, err := store.QueryAudit(context.Background(), storage.AuditQuery{})
+	require.NoError(t, err)
+	return entries
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+var ErrChainBroken = errors.New("audit chain broken")
+
+// ChainError identifies the first audit entry that fails verification
+type ChainError struct {
+	Index   int
+	EntryID string
+	Reason  string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v at entry %d (%s): %s", ErrChainBroken, e.Index, e.EntryID, e.Reason)
+}
+
+// Unwrap allows errors.Is(err, ErrChainBroken)
+func (e *ChainError) Unwrap() error {
+	return ErrChainBroken
+}
+
+// StateDigest returns a digest of an entity state. States are digested when
+// an entry is recorded because stored entities may be mutated afterwards.
+func StateDigest(state interface{}) string {
+	if state == nil {
+		return ""
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%+v", state))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash computes the hash sealing an audit entry. It covers every recorded
+// field except the entity states themselves, which are represented by
+// StateHash.
+func Hash(entry storage.AuditEntry) string {
+	metadata, _ := json.Marshal(entry.Metadata)
+	fields := []string{
+		strconv.FormatInt(entry.Sequence, 10),
+		entry.ID,
+		entry.EntityType,
+		entry.EntityID,
+		entry.Operation,
+		entry.UserID,
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		entry.StateHash,
+		string(metadata),
+		entry.PreviousHash,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Seal links an entry to the previous entry in the chain and computes its
+// hash. A nil previous entry starts a new chain.
+func Seal(entry *storage.AuditEntry, previous *storage.AuditEntry) {
+	entry.Sequence = 1
+	entry.PreviousHash = ""
+	if previous != nil {
+		entry.Sequence = previous.Sequence + 1
+		entry.PreviousHash = previous.Hash
+	}
+	entry.StateHash = StateDigest(entry.NewState)
+	entry.Hash = Hash(*entry)
+}
+
+// VerifyChain checks that a complete audit log is intact: sequences are
+// contiguous from 1, every entry links to its predecessor and every hash
+// matches the entry's contents.
+func VerifyChain(entries []storage.AuditEntry) error {
+	previousHash := ""
+	for i, entry := range entries {
+		switch {
+		case entry.Sequence != int64(i+1):
+			return &ChainError{Index: i, EntryID: entry.ID, Reason: fmt.Sprintf("expected sequence %d, got %d", i+1, entry.Sequence)}
+		case entry.PreviousHash != previousHash:
+			return &ChainError{Index: i, EntryID: entry.ID, Reason: "previous hash does not match"}
+		case entry.Hash != Hash(entry):
+			return &ChainError{Index: i, EntryID: entry.ID, Reason: "entry hash does not match contents"}
+		}
+		previousHash = entry.Hash
+	}
+	return nil
+}
+
+// ErrInvalidSignature is returned when an audit entry's signature does not
+// verify
+var ErrInvalidSignature = errors.New("invalid audit signature")
+
+// Signer signs the hashes of audit entries, typically with a key held
+// outside the database, so that someone able to rewrite the whole chain
+// still cannot forge it
+type Signer interface {
+	// Sign returns the signature of an entry hash
+	Sign(ctx context.Context, hash string) (string, error)
+
+	// Verify returns ErrInvalidSignature unless the signature is one Sign
+	// returned for the hash
+	Verify(ctx context.Context, hash, signature string) error
+}
+
+// HMACSigner signs entry hashes with HMAC-SHA256
+type HMACSigner struct {
+	key []byte
+}
+
+// NewHMACSigner creates a signer from a secret key
+func NewHMACSigner(key []byte) *HMACSigner {
+	return &HMACSigner{key: append([]byte(nil), key...)}
+}
+
+// Sign implements Signer.Sign
+func (s *HMACSigner) Sign(ctx context.Context, hash string) (string, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify implements Signer.Verify
+func (s *HMACSigner) Verify(ctx context.Context, hash, signature string) error {
+	expected, _ := s.Sign(ctx, hash)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SealSigned seals an entry like Seal and, when a signer is given, signs
+// its hash
+func SealSigned(ctx context.Context, entry *storage.AuditEntry, previous *storage.AuditEntry, signer Signer) error {
+	Seal(entry, previous)
+	entry.Signature = ""
+	if signer == nil {
+		return nil
+	}
+	signature, err := signer.Sign(ctx, entry.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign audit entry: %w", err)
+	}
+	entry.Signature = signature
+	return nil
+}
+
+// VerifySignatures checks that every entry carries a valid signature of its
+// hash. It complements VerifyChain, which checks the hashes themselves.
+func VerifySignatures(ctx context.Context, entries []storage.AuditEntry, signer Signer) error {
+	for i, entry := range entries {
+		if entry.Signature == "" {
+			return &ChainError{Index: i, EntryID: entry.ID, Reason: "entry is not signed"}
+		}
+		if err := signer.Verify(ctx, entry.Hash, entry.Signature); err != nil {
+			return &ChainError{Index: i, EntryID: entry.ID, Reason: err.Error()}
+		}
+	}
+	return nil
+}
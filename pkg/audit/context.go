@@ -0,0 +1,30 @@
+// Package audit attributes changes to the callers that made them and keeps
+// a tamper-evident, hash-chained record of every change in a store.
+package audit
+
+import (
+	"context"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// Caller identifies who is making a change and why. It is the actor stores
+// read with storage.ActorFromContext.
+type Caller = storage.Actor
+
+// WithCaller returns a context carrying caller identity. It is equivalent
+// to storage.WithActor.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return storage.WithActor(ctx, caller)
+}
+
+// CallerFromContext returns the caller identity carried by a context. When no
+// user is set explicitly the authenticated principal, if any, is used.
+func CallerFromContext(ctx context.Context) Caller {
+	return storage.ActorFromContext(ctx)
+}
+
+// UserID returns the ID of the user making a change, or an empty string
+func UserID(ctx context.Context) string {
+	return CallerFromContext(ctx).UserID
+}
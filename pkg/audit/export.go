@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// ExportFormat is a file format audit entries are exported in
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+)
+
+// DefaultExportPageSize is the number of entries an exporter reads per page
+const DefaultExportPageSize = 500
+
+// exportHeader is the column layout of CSV exports
+var exportHeader = []string{
+	"sequence", "id", "timestamp", "entity_type", "entity_id", "operation", "user_id",
+	"previous_state", "new_state", "metadata", "state_hash", "previous_hash", "hash",
+}
+
+// ExportRecord is an audit entry as written by exports. States and
+// metadata are JSON; in CSV they are JSON-encoded cells.
+type ExportRecord struct {
+	Sequence      int64           `json:"sequence"`
+	ID            string          `json:"id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	EntityType    string          `json:"entity_type"`
+	EntityID      string          `json:"entity_id"`
+	Operation     string          `json:"operation"`
+	UserID        string          `json:"user_id"`
+	PreviousState json.RawMessage `json:"previous_state,omitempty"`
+	NewState      json.RawMessage `json:"new_state,omitempty"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	StateHash     string          `json:"state_hash"`
+	PreviousHash  string          `json:"previous_hash"`
+	Hash          string          `json:"hash"`
+}
+
+// NewExportRecord converts an audit entry for export
+func NewExportRecord(entry storage.AuditEntry) (ExportRecord, error) {
+	record := ExportRecord{
+		Sequence:     entry.Sequence,
+		ID:           entry.ID,
+		Timestamp:    entry.Timestamp.UTC(),
+		EntityType:   entry.EntityType,
+		EntityID:     entry.EntityID,
+		Operation:    entry.Operation,
+		UserID:       entry.UserID,
+		StateHash:    entry.StateHash,
+		PreviousHash: entry.PreviousHash,
+		Hash:         entry.Hash,
+	}
+	var err error
+	if record.PreviousState, err = rawJSON(entry.PreviousState); err != nil {
+		return record, fmt.Errorf("failed to encode previous state of %s: %w", entry.ID, err)
+	}
+	if record.NewState, err = rawJSON(entry.NewState); err != nil {
+		return record, fmt.Errorf("failed to encode new state of %s: %w", entry.ID, err)
+	}
+	if len(entry.Metadata) > 0 {
+		if record.Metadata, err = json.Marshal(entry.Metadata); err != nil {
+			return record, fmt.Errorf("failed to encode metadata of %s: %w", entry.ID, err)
+		}
+	}
+	return record, nil
+}
+
+// rawJSON encodes a state, passing stored JSON through as it is
+func rawJSON(state interface{}) (json.RawMessage, error) {
+	switch s := state.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return s, nil
+	}
+	return json.Marshal(state)
+}
+
+// Exporter writes the complete change history matching a query, reading
+// the audit trail page by page so large histories are never held in memory
+type Exporter struct {
+	repo     storage.AuditableRepository
+	pageSize int64
+}
+
+// NewExporter creates an exporter over a store's audit trail
+func NewExporter(repo storage.AuditableRepository) *Exporter {
+	return &Exporter{repo: repo, pageSize: DefaultExportPageSize}
+}
+
+// SetPageSize sets the number of entries read per page
+func (e *Exporter) SetPageSize(size int64) {
+	if size > 0 {
+		e.pageSize = size
+	}
+}
+
+// Export writes the audit entries matching a query in chain order and
+// returns how many were written. CSV exports start with a header row; JSON
+// exports are an array of ExportRecords.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, query storage.AuditQuery, format ExportFormat) (int64, error) {
+	var sink recordSink
+	switch format {
+	case ExportCSV:
+		sink = newCSVSink(w)
+	case ExportJSON:
+		sink = &jsonSink{w: w}
+	default:
+		return 0, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	var written int64
+	for {
+		page, err := e.repo.QueryAuditTrail(ctx, storage.AuditFilter{
+			AuditQuery: query,
+			Pagination: &storage.Pagination{Offset: written, Limit: e.pageSize},
+		})
+		if err != nil {
+			return written, fmt.Errorf("error querying audit trail: %w", err)
+		}
+		for _, entry := range page.Entries {
+			record, err := NewExportRecord(entry)
+			if err != nil {
+				return written, err
+			}
+			if err := sink.write(record); err != nil {
+				return written, fmt.Errorf("failed to write audit entry: %w", err)
+			}
+			written++
+		}
+		if int64(len(page.Entries)) < e.pageSize {
+			break
+		}
+	}
+	if err := sink.close(); err != nil {
+		return written, fmt.Errorf("failed to write audit export: %w", err)
+	}
+	return written, nil
+}
+
+// recordSink writes export records in one format
+type recordSink interface {
+	write(record ExportRecord) error
+	close() error
+}
+
+type csvSink struct {
+	writer *csv.Writer
+	header bool
+}
+
+func newCSVSink(w io.Writer) *csvSink {
+	return &csvSink{writer: csv.NewWriter(w)}
+}
+
+func (s *csvSink) write(r ExportRecord) error {
+	if !s.header {
+		if err := s.writer.Write(exportHeader); err != nil {
+			return err
+		}
+		s.header = true
+	}
+	return s.writer.Write([]string{
+		strconv.FormatInt(r.Sequence, 10), r.ID, r.Timestamp.Format(time.RFC3339Nano),
+		r.EntityType, r.EntityID, r.Operation, r.UserID,
+		string(r.PreviousState), string(r.NewState), string(r.Metadata),
+		r.StateHash, r.PreviousHash, r.Hash,
+	})
+}
+
+func (s *csvSink) close() error {
+	if !s.header {
+		if err := s.writer.Write(exportHeader); err != nil {
+			return err
+		}
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+type jsonSink struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *jsonSink) write(r ExportRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if !s.started {
+		prefix = "[\n"
+		s.started = true
+	}
+	if _, err := io.WriteString(s.w, prefix); err != nil {
+		return err
+	}
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *jsonSink) close() error {
+	closing := "\n]\n"
+	if !s.started {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(s.w, closing)
+	return err
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// Report summarises the audit entries matching a query
+type Report struct {
+	Query       storage.AuditQuery   `json:"query"`
+	Entries     []storage.AuditEntry `json:"entries"`
+	ByUser      map[string]int       `json:"by_user"`
+	ByOperation map[string]int       `json:"by_operation"`
+	// Whether the store's complete audit chain verified successfully
+	ChainIntact bool `json:"chain_intact"`
+	// Verification failure, when the chain is broken
+	ChainError string    `json:"chain_error,omitempty"`
+	Generated  time.Time `json:"generated"`
+}
+
+// Reporter queries and verifies a store's audit log
+type Reporter struct {
+	log    storage.AuditLogRepository
+	signer Signer
+}
+
+// NewReporter creates a reporter over an audit log
+func NewReporter(log storage.AuditLogRepository) *Reporter {
+	return &Reporter{log: log}
+}
+
+// SetSigner makes verification also check entry signatures, for stores
+// that sign their entries with the same signer
+func (r *Reporter) SetSigner(signer Signer) {
+	r.signer = signer
+}
+
+// Query retrieves audit entries in chain order
+func (r *Reporter) Query(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	entries, err := r.log.QueryAudit(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Verify checks the integrity of the complete audit chain
+func (r *Reporter) Verify(ctx context.Context) error {
+	entries, err := r.Query(ctx, storage.AuditQuery{})
+	if err != nil {
+		return err
+	}
+	return r.verify(ctx, entries)
+}
+
+// VerifyAuditIntegrity checks that the audit history of an entity has not
+// been modified or had entries deleted. Changing an entry breaks its hash
+// and deleting one leaves a gap in the chain, so the chain is verified up
+// to the entry following the entity's latest one, with signatures when the
+// reporter has a signer. Entities without entries, and an empty entity ID,
+// verify the whole chain. Removing the newest entries of the whole log can
+// only be detected against a copy of its head kept elsewhere.
+func (r *Reporter) VerifyAuditIntegrity(ctx context.Context, entityID string) error {
+	entries, err := r.Query(ctx, storage.AuditQuery{})
+	if err != nil {
+		return err
+	}
+	end := len(entries)
+	for i := len(entries) - 1; i >= 0 && entityID != ""; i-- {
+		if entries[i].EntityID == entityID {
+			if i+2 < end {
+				end = i + 2
+			}
+			break
+		}
+	}
+	if err := r.verify(ctx, entries[:end]); err != nil {
+		return fmt.Errorf("audit history of %s: %w", entityID, err)
+	}
+	return nil
+}
+
+// verify checks the hashes, and signatures when there is a signer, of the
+// start of an audit chain
+func (r *Reporter) verify(ctx context.Context, entries []storage.AuditEntry) error {
+	if err := VerifyChain(entries); err != nil {
+		return err
+	}
+	if r.signer != nil {
+		return VerifySignatures(ctx, entries, r.signer)
+	}
+	return nil
+}
+
+// Report builds an audit report for a query, including the result of
+// verifying the complete chain
+func (r *Reporter) Report(ctx context.Context, query storage.AuditQuery) (*Report, error) {
+	entries, err := r.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Query:       query,
+		Entries:     entries,
+		ByUser:      make(map[string]int),
+		ByOperation: make(map[string]int),
+		ChainIntact: true,
+		Generated:   time.Now(),
+	}
+	for _, entry := range entries {
+		report.ByUser[entry.UserID]++
+		report.ByOperation[entry.Operation]++
+	}
+
+	if err := r.Verify(ctx); err != nil {
+		report.ChainIntact = false
+		report.ChainError = err.Error()
+	}
+	return report, nil
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrUnauthenticated  = errors.New("no principal in context")
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrInvalidRole      = errors.New("invalid role")
+)
+
+type contextKey struct{}
+
+// WithPrincipal returns a context carrying a principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal carried by a context, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// Require checks that the context principal holds a permission. Contexts
+// without a principal are not restricted, so single-user deployments keep
+// working unchanged; use RequireAuthenticated where a principal is mandatory.
+func Require(ctx context.Context, perm Permission) error {
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if !principal.Can(perm) {
+		return fmt.Errorf("%w: %s lacks %s", ErrPermissionDenied, principal.ID, perm)
+	}
+	return nil
+}
+
+// RequireAuthenticated checks that the context carries a principal holding
+// a permission
+func RequireAuthenticated(ctx context.Context, perm Permission) error {
+	if _, ok := FromContext(ctx); !ok {
+		return ErrUnauthenticated
+	}
+	return Require(ctx, perm)
+}
+
+// PrincipalID returns the ID of the context principal, or an empty string
+func PrincipalID(ctx context.Context) string {
+	if principal, ok := FromContext(ctx); ok {
+		return principal.ID
+	}
+	return ""
+}
+
+// Registry holds role definitions and builds principals from role names
+type Registry struct {
+	mu    sync.RWMutex
+	roles map[string]*Role
+}
+
+// NewRegistry creates a registry with the given roles
+func NewRegistry(roles ...*Role) (*Registry, error) {
+	r := &Registry{roles: make(map[string]*Role)}
+	for _, role := range roles {
+		if err := r.DefineRole(role); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// DefineRole adds or replaces a role
+func (r *Registry) DefineRole(role *Role) error {
+	if role == nil || role.Name == "" {
+		return fmt.Errorf("%w: role name is required", ErrInvalidRole)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[role.Name] = role
+	return nil
+}
+
+// Role returns a role by name
+func (r *Registry) Role(name string) (*Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+	}
+	return role, nil
+}
+
+// NewPrincipal builds a principal holding the named roles
+func (r *Registry) NewPrincipal(id, name string, roles ...string) (*Principal, error) {
+	principal := &Principal{ID: id, Name: name}
+	for _, roleName := range roles {
+		role, err := r.Role(roleName)
+		if err != nil {
+			return nil, err
+		}
+		principal.Roles = append(principal.Roles, role)
+	}
+	return principal, nil
+}
+
+// DefaultRoles returns a starting set of roles for a typical finance team
+func DefaultRoles() []*Role {
+	return []*Role{
+		{Name: "admin", Description: "Full access", Permissions: []Permission{All}},
+		{
+			Name:        "accountant",
+			Description: "Records and corrects journal entries",
+			Permissions: []Permission{TransactionPost, TransactionVoid, TransactionReverse, AccountCreate, AccountUpdate, ReportGenerate},
+		},
+		{
+			Name:        "controller",
+			Description: "Approves entries and closes books",
+			Permissions: []Permission{"transaction.*", "account.*", ReportGenerate, PeriodClose},
+		},
+		{Name: "auditor", Description: "Read-only reporting access", Permissions: []Permission{ReportGenerate}},
+	}
+}
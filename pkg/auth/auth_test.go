@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionMatches(t *testing.T) {
+	assert.True(t, All.Matches(AccountClose))
+	assert.True(t, TransactionPost.Matches(TransactionPost))
+	assert.True(t, Permission("transaction.*").Matches(TransactionVoid))
+	assert.False(t, Permission("transaction.*").Matches(AccountClose))
+	assert.False(t, ReportGenerate.Matches(TransactionPost))
+}
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry(DefaultRoles()...)
+	require.NoError(t, err)
+
+	accountant, err := registry.NewPrincipal("u1", "Alex", "accountant")
+	require.NoError(t, err)
+	assert.True(t, accountant.HasRole("accountant"))
+	assert.True(t, accountant.Can(TransactionPost))
+	assert.False(t, accountant.Can(AccountClose))
+
+	controller, err := registry.NewPrincipal("u2", "Sam", "controller")
+	require.NoError(t, err)
+	assert.True(t, controller.Can(AccountClose))
+	assert.True(t, controller.Can(TransactionApprove))
+
+	_, err = registry.NewPrincipal("u3", "Kim", "unknown")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+
+	assert.ErrorIs(t, registry.DefineRole(&Role{}), ErrInvalidRole)
+}
+
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+	auditor := &Principal{ID: "u1", Roles: []*Role{{Name: "auditor", Permissions: []Permission{ReportGenerate}}}}
+
+	t.Run("unauthenticated context is unrestricted", func(t *testing.T) {
+		assert.NoError(t, Require(ctx, TransactionPost))
+		assert.ErrorIs(t, RequireAuthenticated(ctx, TransactionPost), ErrUnauthenticated)
+		assert.Empty(t, PrincipalID(ctx))
+	})
+
+	t.Run("principal permissions are enforced", func(t *testing.T) {
+		scoped := WithPrincipal(ctx, auditor)
+		assert.Equal(t, "u1", PrincipalID(scoped))
+		assert.NoError(t, Require(scoped, ReportGenerate))
+		assert.NoError(t, RequireAuthenticated(scoped, ReportGenerate))
+		assert.ErrorIs(t, Require(scoped, TransactionPost), ErrPermissionDenied)
+	})
+}
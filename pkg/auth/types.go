@@ -0,0 +1,90 @@
+// Package auth provides role-based access control for ledger operations.
+// Principals are attached to the request context and sensitive operations
+// check the principal's permissions before they run.
+package auth
+
+import (
+	"strings"
+)
+
+// Permission identifies an operation that can be granted to a role
+type Permission string
+
+const (
+	TransactionPost    Permission = "transaction.post"
+	TransactionVoid    Permission = "transaction.void"
+	TransactionReverse Permission = "transaction.reverse"
+	TransactionApprove Permission = "transaction.approve"
+	TransactionAdjust  Permission = "transaction.adjust"
+	TransactionUnlock  Permission = "transaction.unlock"
+	AccountCreate      Permission = "account.create"
+	AccountUpdate      Permission = "account.update"
+	AccountClose       Permission = "account.close"
+	ReportGenerate     Permission = "report.generate"
+	PeriodClose        Permission = "period.close"
+
+	// All grants every permission
+	All Permission = "*"
+)
+
+// Matches reports whether a granted permission covers the requested one.
+// Grants may use a trailing wildcard, e.g. "transaction.*".
+func (p Permission) Matches(requested Permission) bool {
+	if p == All || p == requested {
+		return true
+	}
+	if strings.HasSuffix(string(p), ".*") {
+		return strings.HasPrefix(string(requested), strings.TrimSuffix(string(p), "*"))
+	}
+	return false
+}
+
+// Role is a named set of permissions
+type Role struct {
+	// Unique role name (e.g., "accountant")
+	Name string `json:"name"`
+	// Human readable description
+	Description string `json:"description,omitempty"`
+	// Permissions granted by the role
+	Permissions []Permission `json:"permissions"`
+}
+
+// Grants reports whether the role grants a permission
+func (r *Role) Grants(perm Permission) bool {
+	for _, granted := range r.Permissions {
+		if granted.Matches(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is an authenticated user or service acting on the ledger
+type Principal struct {
+	// Unique identifier of the user or service
+	ID string `json:"id"`
+	// Display name
+	Name string `json:"name,omitempty"`
+	// Roles held by the principal
+	Roles []*Role `json:"roles"`
+}
+
+// Can reports whether any of the principal's roles grant a permission
+func (p *Principal) Can(perm Permission) bool {
+	for _, role := range p.Roles {
+		if role.Grants(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal holds a role
+func (p *Principal) HasRole(name string) bool {
+	for _, role := range p.Roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCalculator returns fixed net changes per account
+type fakeCalculator struct {
+	reporting.ReportCalculator
+	changes map[string]int64
+}
+
+func (c *fakeCalculator) CalculateChanges(ctx context.Context, accountID string, period reporting.ReportPeriod) (*reporting.BalanceChange, error) {
+	return &reporting.BalanceChange{NetChange: usd(c.changes[accountID])}, nil
+}
+
+// accountStore serves accounts from a map
+type accountStore struct {
+	account.Repository
+	accounts map[string]account.Account
+}
+
+func (s *accountStore) Read(ctx context.Context, id string, entity interface{}) error {
+	*(entity.(*account.Account)) = s.accounts[id]
+	return nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func TestGenerateBudgetVsActual(t *testing.T) {
+	ctx := context.Background()
+	accounts := &accountStore{accounts: map[string]account.Account{
+		"4000": {ID: "4000", Code: "4000", Name: "Sales", Type: account.Revenue},
+		"6100": {ID: "6100", Code: "6100", Name: "Rent", Type: account.Expense},
+		"6200": {ID: "6200", Code: "6200", Name: "Travel", Type: account.Expense},
+	}}
+
+	month := func(m time.Month) (time.Time, time.Time) {
+		start := time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	budgets := NewMemoryRepository()
+	for i, b := range []struct {
+		account string
+		month   time.Month
+		amount  int64
+	}{
+		{"4000", time.January, 10000},
+		{"4000", time.February, 10000},
+		{"6100", time.January, 2000},
+		{"6100", time.February, 2000},
+		{"6200", time.January, 500},
+		{"4000", time.March, 99999},
+	} {
+		start, end := month(b.month)
+		require.NoError(t, budgets.Save(ctx, &Budget{
+			ID: string(rune('a' + i)), AccountID: b.account, Start: start, End: end, Amount: usd(b.amount),
+		}))
+	}
+	// A quarterly budget counts in proportion to the part within the period
+	q1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, budgets.Save(ctx, &Budget{ID: "q", AccountID: "6200", Start: q1, End: q1.AddDate(0, 0, 120), Amount: usd(1200)}))
+
+	reporter := NewReporter(budgets, accounts, &fakeCalculator{changes: map[string]int64{"4000": 22000, "6100": 4400, "6200": 300}})
+	start, _ := month(time.January)
+	_, end := month(time.February)
+	report, err := reporter.GenerateBudgetVsActual(ctx, reporting.ReportPeriod{Start: start, End: end})
+	require.NoError(t, err)
+
+	assert.Equal(t, BudgetVsActual, report.Type)
+	assert.Equal(t, "USD", report.Currency)
+	require.Len(t, report.Lines, 3)
+
+	sales := report.Lines[0]
+	assert.Equal(t, "Sales", sales.AccountName)
+	assert.True(t, sales.Amount.Amount.Equal(decimal.NewFromInt(22000)))
+	assert.True(t, sales.Details[DetailBudget].(money.Money).Amount.Equal(decimal.NewFromInt(20000)))
+	assert.True(t, sales.Details[DetailVariance].(money.Money).Amount.Equal(decimal.NewFromInt(2000)))
+	assert.Equal(t, "10", sales.Details[DetailVariancePercent].(decimal.Decimal).String())
+	assert.Equal(t, true, sales.Details[DetailFavorable])
+
+	rent := report.Lines[1]
+	assert.Equal(t, "10", rent.Details[DetailVariancePercent].(decimal.Decimal).String())
+	assert.Equal(t, false, rent.Details[DetailFavorable], "overspending is unfavorable")
+
+	travel := report.Lines[2]
+	assert.Equal(t, "1100", travel.Details[DetailBudget].(money.Money).Amount.String(), "500 plus 60 of the 120 days of 1200")
+	assert.Equal(t, true, travel.Details[DetailFavorable])
+
+	assert.Equal(t, "5100", report.Totals["Expense Budget"].Amount.String())
+	assert.Equal(t, "4700", report.Totals["Expense Actual"].Amount.String())
+	assert.Equal(t, "-400", report.Totals["Expense Variance"].Amount.String())
+	assert.Equal(t, "2000", report.Totals["Revenue Variance"].Amount.String())
+
+	t.Run("Mixed currencies", func(t *testing.T) {
+		require.NoError(t, budgets.Save(ctx, &Budget{ID: "eur", AccountID: "6200", Start: start, End: end,
+			Amount: money.Money{Amount: decimal.NewFromInt(100), Currency: "EUR"}}))
+		_, err := reporter.GenerateBudgetVsActual(ctx, reporting.ReportPeriod{Start: start, End: end})
+		assert.ErrorIs(t, err, ErrCurrency)
+	})
+
+	t.Run("Repository", func(t *testing.T) {
+		assert.NoError(t, budgets.Delete(ctx, "eur"))
+		_, err := budgets.Get(ctx, "eur")
+		assert.ErrorIs(t, err, ErrBudgetNotFound)
+		assert.ErrorIs(t, budgets.Save(ctx, &Budget{}), ErrInvalidBudget)
+	})
+}
@@ -0,0 +1,156 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrCurrency = errors.New("currency mismatch")
+)
+
+// Reporter compares budgets with the actual results of their accounts
+type Reporter struct {
+	budgets    Repository
+	accounts   account.Repository
+	calculator reporting.ReportCalculator
+	now        func() time.Time
+}
+
+// NewReporter creates a budget-vs-actual reporter
+func NewReporter(budgets Repository, accounts account.Repository, calculator reporting.ReportCalculator) *Reporter {
+	return &Reporter{
+		budgets:    budgets,
+		accounts:   accounts,
+		calculator: calculator,
+		now:        time.Now,
+	}
+}
+
+// GenerateBudgetVsActual reports, for every account budgeted in a period,
+// the actual net change of the account against the budgeted amount.
+// Budgets that only partly overlap the period count in proportion to the
+// overlap. Each line carries the actual amount, with the budget, variance
+// (actual less budget), variance percentage and whether the variance is
+// favorable in its details: more revenue or less of anything else than
+// budgeted is favorable. Totals are kept per account type, e.g. "Expense
+// Budget", "Expense Actual" and "Expense Variance".
+func (r *Reporter) GenerateBudgetVsActual(ctx context.Context, period reporting.ReportPeriod) (*reporting.Report, error) {
+	if err := auth.Require(ctx, auth.ReportGenerate); err != nil {
+		return nil, err
+	}
+	if !period.Start.Before(period.End) {
+		return nil, fmt.Errorf("period end %s must be after start %s", period.End.Format(time.RFC3339), period.Start.Format(time.RFC3339))
+	}
+
+	budgets, err := r.budgets.ListForPeriod(ctx, period)
+	if err != nil {
+		return nil, fmt.Errorf("error querying budgets: %w", err)
+	}
+
+	currency := ""
+	budgeted := make(map[string]decimal.Decimal)
+	for _, b := range budgets {
+		if currency == "" {
+			currency = b.Amount.Currency
+		} else if b.Amount.Currency != currency {
+			return nil, fmt.Errorf("%w: budget %s is in %s, report is in %s", ErrCurrency, b.ID, b.Amount.Currency, currency)
+		}
+		budgeted[b.AccountID] = budgeted[b.AccountID].Add(b.amountWithin(period))
+	}
+
+	accounts := make([]*account.Account, 0, len(budgeted))
+	for id := range budgeted {
+		var acc account.Account
+		if err := r.accounts.Read(ctx, id, &acc); err != nil {
+			return nil, fmt.Errorf("error reading account %s: %w", id, err)
+		}
+		accounts = append(accounts, &acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Code != accounts[j].Code {
+			return accounts[i].Code < accounts[j].Code
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	report := &reporting.Report{
+		ID:          fmt.Sprintf("BVA_%d", r.now().UnixNano()),
+		Type:        BudgetVsActual,
+		Title:       "Budget vs Actual",
+		Period:      period,
+		Currency:    currency,
+		GeneratedAt: r.now(),
+		Lines:       make([]*reporting.ReportLine, 0, len(accounts)),
+		Totals:      make(map[string]money.Money),
+	}
+
+	addTotal := func(name string, amount decimal.Decimal) {
+		total, ok := report.Totals[name]
+		if !ok {
+			total = money.Money{Amount: decimal.Zero, Currency: currency}
+		}
+		total.Amount = total.Amount.Add(amount)
+		report.Totals[name] = total
+	}
+
+	for _, acc := range accounts {
+		change, err := r.calculator.CalculateChanges(ctx, acc.ID, period)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating actuals for account %s: %w", acc.ID, err)
+		}
+		actual := change.NetChange.Amount
+		if c := change.NetChange.Currency; c != "" && c != currency && !actual.IsZero() {
+			return nil, fmt.Errorf("%w: account %s is in %s, report is in %s", ErrCurrency, acc.ID, c, currency)
+		}
+
+		budget := budgeted[acc.ID]
+		variance := actual.Sub(budget)
+		favorable := !variance.IsPositive()
+		if acc.Type == account.Revenue {
+			favorable = !variance.IsNegative()
+		}
+		details := map[string]interface{}{
+			DetailBudget:    money.Money{Amount: budget, Currency: currency},
+			DetailVariance:  money.Money{Amount: variance, Currency: currency},
+			DetailFavorable: favorable,
+		}
+		if !budget.IsZero() {
+			details[DetailVariancePercent] = variance.Div(budget.Abs()).Mul(decimal.NewFromInt(100)).Round(2)
+		}
+
+		report.Lines = append(report.Lines, &reporting.ReportLine{
+			AccountID:   acc.ID,
+			AccountCode: acc.Code,
+			AccountName: acc.Name,
+			Amount:      money.Money{Amount: actual, Currency: currency},
+			Details:     details,
+		})
+
+		group := typeLabel(acc.Type)
+		addTotal(group+" Budget", budget)
+		addTotal(group+" Actual", actual)
+		addTotal(group+" Variance", variance)
+	}
+
+	return report, nil
+}
+
+// typeLabel names an account type in report totals, e.g. "Expense"
+func typeLabel(t account.AccountType) string {
+	s := strings.ToLower(string(t))
+	if s == "" {
+		return "Other"
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
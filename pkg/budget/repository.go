@@ -0,0 +1,102 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+)
+
+var (
+	ErrBudgetNotFound = errors.New("budget not found")
+	ErrInvalidBudget  = errors.New("invalid budget")
+)
+
+// Repository persists budgets
+type Repository interface {
+	// Save stores a budget, replacing any budget with the same ID
+	Save(ctx context.Context, budget *Budget) error
+
+	// Get retrieves a budget by ID
+	Get(ctx context.Context, id string) (*Budget, error)
+
+	// Delete removes a budget by ID
+	Delete(ctx context.Context, id string) error
+
+	// ListForPeriod returns the budgets whose period overlaps a report
+	// period, ordered by account and start
+	ListForPeriod(ctx context.Context, period reporting.ReportPeriod) ([]*Budget, error)
+}
+
+// MemoryRepository provides an in-memory implementation of Repository
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	budgets map[string]*Budget
+}
+
+// NewMemoryRepository creates a new in-memory budget repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{budgets: make(map[string]*Budget)}
+}
+
+// Save implements Repository.Save
+func (r *MemoryRepository) Save(ctx context.Context, budget *Budget) error {
+	if budget.ID == "" {
+		return fmt.Errorf("%w: ID is required", ErrInvalidBudget)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *budget
+	r.budgets[budget.ID] = &stored
+	return nil
+}
+
+// Get implements Repository.Get
+func (r *MemoryRepository) Get(ctx context.Context, id string) (*Budget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	budget, ok := r.budgets[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBudgetNotFound, id)
+	}
+	result := *budget
+	return &result, nil
+}
+
+// Delete implements Repository.Delete
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.budgets[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrBudgetNotFound, id)
+	}
+	delete(r.budgets, id)
+	return nil
+}
+
+// ListForPeriod implements Repository.ListForPeriod
+func (r *MemoryRepository) ListForPeriod(ctx context.Context, period reporting.ReportPeriod) ([]*Budget, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make([]*Budget, 0)
+	for _, budget := range r.budgets {
+		if budget.overlaps(period) {
+			result := *budget
+			results = append(results, &result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].AccountID != results[j].AccountID {
+			return results[i].AccountID < results[j].AccountID
+		}
+		return results[i].Start.Before(results[j].Start)
+	})
+	return results, nil
+}
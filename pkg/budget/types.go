@@ -0,0 +1,65 @@
+// Package budget stores budgeted amounts per account and period and
+// compares them with actual results.
+package budget
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+)
+
+// BudgetVsActual is the report type of budget-vs-actual reports
+const BudgetVsActual reporting.ReportType = "BUDGET_VS_ACTUAL"
+
+// Keys of the report line details of a budget-vs-actual report
+const (
+	DetailBudget          = "budget"
+	DetailVariance        = "variance"
+	DetailVariancePercent = "variance_percent"
+	DetailFavorable       = "favorable"
+)
+
+// Budget is the amount budgeted for one account over one period. Amounts
+// are on the account's normal side, e.g. positive for both planned revenue
+// and planned expenses.
+type Budget struct {
+	ID        string      `json:"id"`
+	AccountID string      `json:"account_id"`
+	Start     time.Time   `json:"start"`
+	End       time.Time   `json:"end"`
+	Amount    money.Money `json:"amount"`
+	// Optional budget version or scenario, e.g. "FY2024 original"
+	Name         string    `json:"name,omitempty"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// GetID returns the budget identifier
+func (b *Budget) GetID() string { return b.ID }
+
+// overlaps returns true if the budget period shares any time with a period
+func (b *Budget) overlaps(period reporting.ReportPeriod) bool {
+	return b.Start.Before(period.End) && period.Start.Before(b.End)
+}
+
+// amountWithin returns the share of the budget falling within a period,
+// spread evenly over the budget period
+func (b *Budget) amountWithin(period reporting.ReportPeriod) decimal.Decimal {
+	start, end := b.Start, b.End
+	if period.Start.After(start) {
+		start = period.Start
+	}
+	if period.End.Before(end) {
+		end = period.End
+	}
+	if !start.Before(end) {
+		return decimal.Zero
+	}
+	if start.Equal(b.Start) && end.Equal(b.End) {
+		return b.Amount.Amount
+	}
+	share := decimal.NewFromInt(int64(end.Sub(start))).Div(decimal.NewFromInt(int64(b.End.Sub(b.Start))))
+	return b.Amount.Amount.Mul(share).Round(2)
+}
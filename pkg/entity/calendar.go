@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Validate checks that the calendar is well formed
+func (c FiscalCalendar) Validate() error {
+	if c.StartMonth < time.January || c.StartMonth > time.December {
+		return fmt.Errorf("%w: invalid fiscal year start month %d", ErrInvalidCalendar, c.StartMonth)
+	}
+	if c.PeriodsPerYear <= 0 || 12%c.PeriodsPerYear != 0 {
+		return fmt.Errorf("%w: periods per year must divide 12, got %d", ErrInvalidCalendar, c.PeriodsPerYear)
+	}
+	return nil
+}
+
+// FiscalYear returns the fiscal year containing a date. Fiscal years are
+// named after the calendar year in which they end.
+func (c FiscalCalendar) FiscalYear(date time.Time) int {
+	year := date.Year()
+	if c.StartMonth != time.January && date.Month() >= c.StartMonth {
+		year++
+	}
+	return year
+}
+
+// YearStart returns the first day of a fiscal year in the given location
+func (c FiscalCalendar) YearStart(fiscalYear int, loc *time.Location) time.Time {
+	year := fiscalYear
+	if c.StartMonth != time.January {
+		year--
+	}
+	return time.Date(year, c.StartMonth, 1, 0, 0, 0, 0, loc)
+}
+
+// Period returns a numbered period of a fiscal year
+func (c FiscalCalendar) Period(fiscalYear, number int, loc *time.Location) (FiscalPeriod, error) {
+	if err := c.Validate(); err != nil {
+		return FiscalPeriod{}, err
+	}
+	if number < 1 || number > c.PeriodsPerYear {
+		return FiscalPeriod{}, fmt.Errorf("%w: period %d out of range", ErrInvalidCalendar, number)
+	}
+
+	months := 12 / c.PeriodsPerYear
+	start := c.YearStart(fiscalYear, loc).AddDate(0, (number-1)*months, 0)
+	return FiscalPeriod{
+		Year:   fiscalYear,
+		Number: number,
+		Start:  start,
+		End:    start.AddDate(0, months, 0).Add(-time.Nanosecond),
+	}, nil
+}
+
+// PeriodFor returns the fiscal period containing a date
+func (c FiscalCalendar) PeriodFor(date time.Time) (FiscalPeriod, error) {
+	if err := c.Validate(); err != nil {
+		return FiscalPeriod{}, err
+	}
+
+	fiscalYear := c.FiscalYear(date)
+	start := c.YearStart(fiscalYear, date.Location())
+	elapsed := (date.Year()-start.Year())*12 + int(date.Month()-start.Month())
+	return c.Period(fiscalYear, elapsed/(12/c.PeriodsPerYear)+1, date.Location())
+}
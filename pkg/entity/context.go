@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"context"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// WithEntity returns a context scoped to an entity. It is equivalent to
+// tenant.WithTenant.
+func WithEntity(ctx context.Context, entityID string) context.Context {
+	return tenant.WithTenant(ctx, entityID)
+}
+
+// FromContext returns the entity a context is scoped to, if any. It is
+// equivalent to tenant.FromContext.
+func FromContext(ctx context.Context) (string, bool) {
+	return tenant.FromContext(ctx)
+}
+
+// ScopeQuery adds an entity filter to a query when the context is scoped to
+// an entity. It is equivalent to storage.ScopeQuery.
+func ScopeQuery(ctx context.Context, query storage.Query) storage.Query {
+	return storage.ScopeQuery(ctx, query)
+}
+
+// CheckScope verifies that a record about to be written may be stored under
+// the context entity; records without an entity take the context's. It is
+// equivalent to storage.CheckTenant.
+func CheckScope(ctx context.Context, recordEntityID string) error {
+	return storage.CheckTenant(ctx, recordEntityID)
+}
+
+// CheckStored verifies that a stored record belongs to the context entity,
+// so records are visible by ID exactly when ScopeQuery would find them. It
+// is equivalent to storage.CheckStoredTenant.
+func CheckStored(ctx context.Context, recordEntityID string) error {
+	return storage.CheckStoredTenant(ctx, recordEntityID)
+}
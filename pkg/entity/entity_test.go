@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiscalCalendar(t *testing.T) {
+	calendar := FiscalCalendar{StartMonth: time.April, PeriodsPerYear: 12}
+
+	t.Run("validation", func(t *testing.T) {
+		assert.NoError(t, calendar.Validate())
+		assert.ErrorIs(t, FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 5}.Validate(), ErrInvalidCalendar)
+		assert.ErrorIs(t, FiscalCalendar{PeriodsPerYear: 12}.Validate(), ErrInvalidCalendar)
+	})
+
+	t.Run("fiscal year is named after its ending year", func(t *testing.T) {
+		assert.Equal(t, 2024, calendar.FiscalYear(time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, 2025, calendar.FiscalYear(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("period for date", func(t *testing.T) {
+		period, err := calendar.PeriodFor(time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, 2025, period.Year)
+		assert.Equal(t, 3, period.Number)
+		assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), period.Start)
+		assert.Equal(t, 30, period.End.Day())
+	})
+
+	t.Run("quarterly periods", func(t *testing.T) {
+		quarterly := FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 4}
+		period, err := quarterly.PeriodFor(time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, 3, period.Number)
+		assert.Equal(t, time.July, period.Start.Month())
+
+		_, err = quarterly.Period(2024, 5, time.UTC)
+		assert.ErrorIs(t, err, ErrInvalidCalendar)
+	})
+}
+
+func TestBasicManager(t *testing.T) {
+	ctx := context.Background()
+	manager := NewBasicManager(memory.NewMemoryStore())
+
+	entity := &Entity{
+		ID:           "E1",
+		Code:         "US01",
+		Name:         "US Operations",
+		BaseCurrency: "USD",
+		Calendar:     FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 12},
+	}
+	require.NoError(t, manager.CreateEntity(ctx, entity))
+	assert.Equal(t, Active, entity.Status)
+
+	stored, err := manager.GetEntity(ctx, "E1")
+	require.NoError(t, err)
+	assert.Equal(t, "US Operations", stored.Name)
+
+	_, err = manager.GetEntity(ctx, "missing")
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+
+	err = manager.CreateEntity(ctx, &Entity{ID: "E2", Code: "X", Name: "X", BaseCurrency: "US"})
+	assert.ErrorIs(t, err, ErrInvalidEntity)
+}
+
+func TestScope(t *testing.T) {
+	ctx := context.Background()
+	query := storage.Query{Filters: []storage.Filter{{Field: "status", Operator: "=", Value: "POSTED"}}}
+
+	assert.Equal(t, query, ScopeQuery(ctx, query))
+	assert.NoError(t, CheckScope(ctx, "E1"))
+
+	scoped := WithEntity(ctx, "E1")
+	id, ok := FromContext(scoped)
+	assert.True(t, ok)
+	assert.Equal(t, "E1", id)
+
+	result := ScopeQuery(scoped, query)
+	require.Len(t, result.Filters, 2)
+	assert.Equal(t, "entity_id", result.Filters[1].Field)
+	assert.Len(t, query.Filters, 1)
+
+	assert.NoError(t, CheckScope(scoped, "E1"))
+	assert.NoError(t, CheckScope(scoped, ""))
+	assert.ErrorIs(t, CheckScope(scoped, "E2"), ErrEntityMismatch)
+	assert.ErrorIs(t, CheckScope(scoped, "E2"), storage.ErrTenantMismatch)
+	assert.NoError(t, CheckStored(scoped, "E1"))
+	assert.ErrorIs(t, CheckStored(scoped, ""), ErrEntityMismatch, "stored records without an entity are outside every scope")
+	assert.NoError(t, CheckStored(ctx, ""))
+}
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+var (
+	ErrEntityNotFound  = errors.New("entity not found")
+	ErrInvalidEntity   = errors.New("invalid entity")
+	ErrInvalidCalendar = errors.New("invalid fiscal calendar")
+	// ErrEntityMismatch is storage.ErrTenantMismatch, returned when a
+	// context scoped to one entity touches another entity's records
+	ErrEntityMismatch = storage.ErrTenantMismatch
+)
+
+// Manager defines the interface for legal entity operations
+type Manager interface {
+	// CreateEntity creates a new legal entity
+	CreateEntity(ctx context.Context, entity *Entity) error
+
+	// GetEntity retrieves an entity by ID
+	GetEntity(ctx context.Context, id string) (*Entity, error)
+
+	// UpdateEntity updates an existing entity
+	UpdateEntity(ctx context.Context, entity *Entity) error
+
+	// ListEntities retrieves all entities, optionally restricted to children of a parent
+	ListEntities(ctx context.Context, parentID *string) ([]*Entity, error)
+}
+
+// BasicManager provides a storage-backed implementation of Manager
+type BasicManager struct {
+	repo storage.Repository
+}
+
+// NewBasicManager creates a new BasicManager
+func NewBasicManager(repo storage.Repository) *BasicManager {
+	return &BasicManager{repo: repo}
+}
+
+// CreateEntity implements Manager.CreateEntity
+func (m *BasicManager) CreateEntity(ctx context.Context, entity *Entity) error {
+	if err := validateEntity(entity); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if entity.Status == "" {
+		entity.Status = Active
+	}
+	entity.Created = now
+	entity.LastModified = now
+
+	if err := m.repo.Create(ctx, entity); err != nil {
+		return fmt.Errorf("failed to store entity: %w", err)
+	}
+	return nil
+}
+
+// GetEntity implements Manager.GetEntity
+func (m *BasicManager) GetEntity(ctx context.Context, id string) (*Entity, error) {
+	var entity Entity
+	if err := m.repo.Read(ctx, id, &entity); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrEntityNotFound, id, err)
+	}
+	return &entity, nil
+}
+
+// UpdateEntity implements Manager.UpdateEntity
+func (m *BasicManager) UpdateEntity(ctx context.Context, entity *Entity) error {
+	if err := validateEntity(entity); err != nil {
+		return err
+	}
+
+	entity.LastModified = time.Now()
+	if err := m.repo.Update(ctx, entity); err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+	return nil
+}
+
+// ListEntities implements Manager.ListEntities
+func (m *BasicManager) ListEntities(ctx context.Context, parentID *string) ([]*Entity, error) {
+	query := storage.Query{
+		Sort: []storage.Sort{{Field: "code", Desc: false}},
+	}
+	if parentID != nil {
+		query.Filters = append(query.Filters, storage.Filter{Field: "parent_id", Operator: "=", Value: *parentID})
+	}
+
+	var entities []*Entity
+	if err := m.repo.Query(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("error querying entities: %w", err)
+	}
+	return entities, nil
+}
+
+func validateEntity(entity *Entity) error {
+	if entity == nil {
+		return fmt.Errorf("%w: entity cannot be nil", ErrInvalidEntity)
+	}
+	if entity.ID == "" || entity.Code == "" || entity.Name == "" {
+		return fmt.Errorf("%w: ID, code and name are required", ErrInvalidEntity)
+	}
+	if len(entity.BaseCurrency) != 3 {
+		return fmt.Errorf("%w: base currency must be an ISO 4217 code", ErrInvalidEntity)
+	}
+	if entity.ParentID != nil && *entity.ParentID == entity.ID {
+		return fmt.Errorf("%w: entity cannot be its own parent", ErrInvalidEntity)
+	}
+	return entity.Calendar.Validate()
+}
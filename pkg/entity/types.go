@@ -0,0 +1,68 @@
+// Package entity provides legal entity management so a single deployment can
+// keep separate books for multiple companies. Accounts, transactions and
+// reports are scoped to an entity through their EntityID fields and the
+// entity carried on the request context.
+package entity
+
+import (
+	"time"
+)
+
+// Status represents the status of a legal entity
+type Status string
+
+const (
+	Active   Status = "ACTIVE"
+	Inactive Status = "INACTIVE"
+)
+
+// FiscalCalendar describes how an entity's fiscal year is divided into periods
+type FiscalCalendar struct {
+	// Month the fiscal year starts in (e.g., time.April)
+	StartMonth time.Month `json:"start_month"`
+	// Number of periods in a fiscal year; must divide 12 (e.g., 12, 4 or 1)
+	PeriodsPerYear int `json:"periods_per_year"`
+}
+
+// FiscalPeriod identifies a single period within a fiscal year
+type FiscalPeriod struct {
+	// Fiscal year, named after the calendar year in which it ends
+	Year int `json:"year"`
+	// Period number within the year, starting at 1
+	Number int `json:"number"`
+	// First day of the period
+	Start time.Time `json:"start"`
+	// Last instant of the period
+	End time.Time `json:"end"`
+}
+
+// Entity represents a legal entity keeping its own set of books
+type Entity struct {
+	// Unique identifier for the entity
+	ID string `json:"id"`
+	// Short code used in reports and transaction numbering
+	Code string `json:"code"`
+	// Display name
+	Name string `json:"name"`
+	// Registered legal name
+	LegalName string `json:"legal_name,omitempty"`
+	// ISO 4217 currency the entity's books are kept in
+	BaseCurrency string `json:"base_currency"`
+	// Fiscal calendar used for period and year-end processing
+	Calendar FiscalCalendar `json:"calendar"`
+	// Chart of accounts the entity's accounts are drawn from
+	ChartOfAccountsID string `json:"chart_of_accounts_id,omitempty"`
+	// Optional parent entity for group structures
+	ParentID *string `json:"parent_id,omitempty"`
+	// Status of the entity
+	Status Status `json:"status"`
+	// When the entity was created
+	Created time.Time `json:"created"`
+	// Last modification timestamp
+	LastModified time.Time `json:"last_modified"`
+	// Additional metadata for extensibility
+	MetaData map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// GetID returns the entity identifier
+func (e *Entity) GetID() string { return e.ID }
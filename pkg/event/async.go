@@ -0,0 +1,154 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// ErrBusClosed is returned when publishing to an asynchronous bus that has
+// been closed
+var ErrBusClosed = errors.New("event bus is closed")
+
+// AsyncOptions configures asynchronous dispatch on a MemoryBus
+type AsyncOptions struct {
+	// Number of workers running handlers; defaults to the number of CPUs
+	Workers int
+	// Events each worker can queue before Publish waits; defaults to 256
+	QueueSize int
+	// Returns the key events are ordered by: events with the same key are
+	// handled one at a time in publish order. Defaults to PartitionKey.
+	Key func(Event) string
+	// Called with the errors handlers return; errors are dropped without it
+	OnError func(ctx context.Context, e Event, err error)
+}
+
+// PartitionKey returns the account or transaction a built-in event is
+// about, or the event type for other events
+func PartitionKey(e Event) string {
+	switch data := e.Data.(type) {
+	case AccountBalanceUpdatedV1:
+		return data.AccountID
+	case AccountCreatedV1:
+		return data.AccountID
+	case AccountChangedV1:
+		return data.AccountID
+	case TransactionPostedV1:
+		return data.TransactionID
+	case TransactionVoidedV1:
+		return data.TransactionID
+	case TransactionReversedV1:
+		return data.TransactionID
+	case TransactionValidatedV1:
+		return data.TransactionID
+	case ApprovalV1:
+		return data.RequestID
+	}
+	return e.Type
+}
+
+// job is an event queued for its handlers
+type job struct {
+	ctx      context.Context
+	event    Event
+	handlers []Handler
+}
+
+// workerPool runs handlers on a fixed set of workers. Each key is always
+// handled by the same worker, which keeps events with one key in order.
+type workerPool struct {
+	opts    AsyncOptions
+	queues  []chan job
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+
+	// queued counts events not yet handled; idle is signalled at zero
+	queuedMu sync.Mutex
+	idle     *sync.Cond
+	queued   int
+}
+
+func newWorkerPool(opts AsyncOptions) *workerPool {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	if opts.Key == nil {
+		opts.Key = PartitionKey
+	}
+
+	p := &workerPool{opts: opts, queues: make([]chan job, opts.Workers)}
+	p.idle = sync.NewCond(&p.queuedMu)
+	for i := range p.queues {
+		p.queues[i] = make(chan job, opts.QueueSize)
+		p.workers.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// submit queues an event for its handlers, waiting while the key's worker
+// queue is full
+func (p *workerPool) submit(ctx context.Context, e Event, handlers []Handler) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrBusClosed
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(p.opts.Key(e)))
+	queue := p.queues[h.Sum32()%uint32(len(p.queues))]
+
+	// Handlers outlive the publishing request, so only its values are kept
+	p.queuedMu.Lock()
+	p.queued++
+	p.queuedMu.Unlock()
+	queue <- job{ctx: context.WithoutCancel(ctx), event: e, handlers: handlers}
+	return nil
+}
+
+func (p *workerPool) work(queue chan job) {
+	defer p.workers.Done()
+	for j := range queue {
+		for _, handler := range j.handlers {
+			if err := handler.Handle(j.ctx, j.event); err != nil && p.opts.OnError != nil {
+				p.opts.OnError(j.ctx, j.event, err)
+			}
+		}
+		p.queuedMu.Lock()
+		if p.queued--; p.queued == 0 {
+			p.idle.Broadcast()
+		}
+		p.queuedMu.Unlock()
+	}
+}
+
+// wait blocks until every queued event has been handled
+func (p *workerPool) wait() {
+	p.queuedMu.Lock()
+	defer p.queuedMu.Unlock()
+	for p.queued > 0 {
+		p.idle.Wait()
+	}
+}
+
+// close stops accepting events and waits for the queued ones
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.workers.Wait()
+}
@@ -0,0 +1,104 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderHandler records the events it handles per account, sleeping first
+// to stand in for slow handlers
+type orderHandler struct {
+	mu    sync.Mutex
+	delay time.Duration
+	seen  map[string][]string
+}
+
+func (h *orderHandler) Handle(ctx context.Context, e event.Event) error {
+	time.Sleep(h.delay)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := event.PartitionKey(e)
+	h.seen[key] = append(h.seen[key], e.ID)
+	return nil
+}
+
+// handlerFunc adapts a function to event.Handler
+type handlerFunc func(ctx context.Context, e event.Event) error
+
+func (f handlerFunc) Handle(ctx context.Context, e event.Event) error { return f(ctx, e) }
+
+func TestAsyncMemoryBus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("orders events per key", func(t *testing.T) {
+		bus := event.NewAsyncMemoryBus(event.AsyncOptions{Workers: 4, QueueSize: 64})
+		defer bus.Close()
+
+		handler := &orderHandler{delay: time.Millisecond, seen: make(map[string][]string)}
+		require.NoError(t, bus.Subscribe(event.AccountBalanceUpdated, handler))
+
+		want := make(map[string][]string)
+		for i := 0; i < 20; i++ {
+			for _, account := range []string{"cash", "revenue", "payables"} {
+				id := account + "-" + string(rune('a'+i))
+				want[account] = append(want[account], id)
+				require.NoError(t, bus.Publish(ctx, event.Event{
+					ID:   id,
+					Type: event.AccountBalanceUpdated,
+					Data: event.AccountBalanceUpdatedV1{AccountID: account},
+				}))
+			}
+		}
+
+		bus.Wait()
+		handler.mu.Lock()
+		defer handler.mu.Unlock()
+		assert.Equal(t, want, handler.seen)
+	})
+
+	t.Run("publish does not wait for handlers", func(t *testing.T) {
+		bus := event.NewAsyncMemoryBus(event.AsyncOptions{Workers: 1})
+		defer bus.Close()
+
+		release := make(chan struct{})
+		handled := make(chan string, 1)
+		require.NoError(t, bus.Subscribe(event.TransactionPosted, handlerFunc(func(ctx context.Context, e event.Event) error {
+			<-release
+			handled <- e.ID
+			return nil
+		})))
+
+		require.NoError(t, bus.Publish(ctx, event.Event{ID: "tx-1", Type: event.TransactionPosted}))
+		close(release)
+		assert.Equal(t, "tx-1", <-handled)
+	})
+
+	t.Run("reports handler errors and rejects publishes after close", func(t *testing.T) {
+		var mu sync.Mutex
+		var failed []string
+		bus := event.NewAsyncMemoryBus(event.AsyncOptions{
+			OnError: func(ctx context.Context, e event.Event, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				failed = append(failed, e.ID)
+			},
+		})
+		require.NoError(t, bus.Subscribe(event.TransactionPosted, handlerFunc(func(ctx context.Context, e event.Event) error {
+			return errors.New("projection unavailable")
+		})))
+
+		require.NoError(t, bus.Publish(ctx, event.Event{ID: "tx-1", Type: event.TransactionPosted}))
+		require.NoError(t, bus.Close())
+		assert.Equal(t, []string{"tx-1"}, failed)
+
+		err := bus.Publish(ctx, event.Event{ID: "tx-2", Type: event.TransactionPosted})
+		assert.ErrorIs(t, err, event.ErrBusClosed)
+	})
+}
@@ -3,12 +3,15 @@ package event
 import (
 	"context"
 	"sync"
+
+	"github.com/johnayoung/finlib/pkg/tenant"
 )
 
 // MemoryBus provides an in-memory implementation of the event bus
 type MemoryBus struct {
 	mu       sync.RWMutex
 	handlers map[string][]Handler
+	pool     *workerPool
 }
 
 // NewMemoryBus creates a new memory event bus
@@ -18,12 +21,36 @@ func NewMemoryBus() *MemoryBus {
 	}
 }
 
-// Publish publishes an event to all registered handlers
+// NewAsyncMemoryBus creates a memory event bus that runs handlers on a
+// bounded worker pool instead of in Publish. Events sharing a key are handled
+// in publish order; Close stops the workers.
+func NewAsyncMemoryBus(opts AsyncOptions) *MemoryBus {
+	b := NewMemoryBus()
+	b.pool = newWorkerPool(opts)
+	return b
+}
+
+// Publish publishes an event to all registered handlers. The context tenant
+// is recorded in the event metadata and handlers run in that tenant's scope.
+// On an asynchronous bus Publish returns once the event is queued.
 func (b *MemoryBus) Publish(ctx context.Context, event Event) error {
+	event = WithTenant(ctx, event)
+	if id, ok := TenantID(event); ok {
+		ctx = tenant.WithTenant(ctx, id)
+	}
+
 	b.mu.RLock()
 	handlers := b.handlers[event.Type]
 	b.mu.RUnlock()
 
+	if b.pool != nil {
+		if len(handlers) == 0 {
+			return nil
+		}
+		// Unsubscribe reuses the slice, so queue a copy
+		return b.pool.submit(ctx, event, append([]Handler(nil), handlers...))
+	}
+
 	for _, handler := range handlers {
 		if err := handler.Handle(ctx, event); err != nil {
 			// Log error but continue processing other handlers
@@ -62,3 +89,20 @@ func (b *MemoryBus) Unsubscribe(eventType string, handler Handler) error {
 	}
 	return nil
 }
+
+// Wait blocks until every event queued on an asynchronous bus has been
+// handled
+func (b *MemoryBus) Wait() {
+	if b.pool != nil {
+		b.pool.wait()
+	}
+}
+
+// Close stops an asynchronous bus after handling the queued events. Later
+// publishes return ErrBusClosed.
+func (b *MemoryBus) Close() error {
+	if b.pool != nil {
+		b.pool.close()
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// ErrDuplicateHandler is returned when two handlers subscribed to the same
+// event type of a durable bus share a name
+var ErrDuplicateHandler = errors.New("duplicate handler name")
+
+// NamedHandler is a handler with a stable name. A durable bus tracks
+// deliveries by handler name, so handlers whose deliveries must survive a
+// restart should implement it; other handlers are named after their type.
+type NamedHandler interface {
+	Handler
+	Name() string
+}
+
+// RetryPolicy controls how often a failed delivery is retried. The delay
+// doubles after every failed attempt up to MaxBackoff.
+type RetryPolicy struct {
+	// Attempts made before a delivery is dead-lettered
+	MaxAttempts int
+	// Delay before the first retry
+	Backoff time.Duration
+	// Longest delay between retries
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy makes five attempts starting one second apart
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Minute}
+
+// Delay returns how long to wait before retrying after a number of failed
+// attempts
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// DeliveryStatus is the state of an event's delivery to one handler
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	DeliveryDead      DeliveryStatus = "DEAD_LETTER"
+)
+
+// Delivery tracks an event's delivery to one handler. Repositories that
+// serialize it store the event as an Envelope, so the payload of a retried
+// event decodes through DefaultRegistry.
+type Delivery struct {
+	ID          string         `json:"id"`
+	Event       Event          `json:"event"`
+	Handler     string         `json:"handler"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	LastError   string         `json:"last_error,omitempty"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	Created     time.Time      `json:"created"`
+	DeliveredAt *time.Time     `json:"delivered_at,omitempty"`
+}
+
+// DeliveryID returns the ID of an event's delivery to a handler
+func DeliveryID(eventID, handler string) string {
+	return "delivery:" + eventID + ":" + handler
+}
+
+// GetID returns the storage identifier of the delivery
+func (d *Delivery) GetID() string { return d.ID }
+
+// deliveryIndex lists the deliveries awaiting a retry and the
+// dead-lettered ones, so they can be found with reads by ID alone
+type deliveryIndex struct {
+	Pending []string `json:"pending"`
+	Dead    []string `json:"dead"`
+}
+
+func (i *deliveryIndex) GetID() string { return "delivery-index" }
+
+type subscription struct {
+	name    string
+	handler Handler
+	policy  *RetryPolicy
+}
+
+// DurableBus is an event bus with at-least-once delivery. Every delivery
+// of an event to a handler is recorded in a repository before the handler
+// runs; failed deliveries are retried by Process according to the
+// handler's retry policy and dead-lettered once its attempts run out.
+// Handlers must tolerate receiving an event more than once.
+type DurableBus struct {
+	mu            sync.RWMutex
+	indexMu       sync.Mutex
+	repo          storage.Repository
+	policy        RetryPolicy
+	subscriptions map[string][]subscription
+	now           func() time.Time
+}
+
+// NewDurableBus creates a durable bus recording deliveries in a repository
+func NewDurableBus(repo storage.Repository) *DurableBus {
+	return &DurableBus{
+		repo:          repo,
+		policy:        DefaultRetryPolicy,
+		subscriptions: make(map[string][]subscription),
+		now:           time.Now,
+	}
+}
+
+// SetRetryPolicy sets the retry policy of handlers subscribed without one
+func (b *DurableBus) SetRetryPolicy(policy RetryPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.policy = policy
+}
+
+// SetClock sets the function used to read the current time
+func (b *DurableBus) SetClock(now func() time.Time) {
+	b.now = now
+}
+
+// Subscribe registers a handler for an event type using the bus retry policy
+func (b *DurableBus) Subscribe(eventType string, handler Handler) error {
+	return b.subscribe(eventType, handler, nil)
+}
+
+// SubscribeWithPolicy registers a handler for an event type with its own
+// retry policy
+func (b *DurableBus) SubscribeWithPolicy(eventType string, handler Handler, policy RetryPolicy) error {
+	return b.subscribe(eventType, handler, &policy)
+}
+
+func (b *DurableBus) subscribe(eventType string, handler Handler, policy *RetryPolicy) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name := handlerName(handler)
+	for _, s := range b.subscriptions[eventType] {
+		if s.name == name {
+			return fmt.Errorf("%w: %s for %s", ErrDuplicateHandler, name, eventType)
+		}
+	}
+	b.subscriptions[eventType] = append(b.subscriptions[eventType], subscription{name: name, handler: handler, policy: policy})
+	return nil
+}
+
+// Unsubscribe removes a handler for an event type. Its pending deliveries
+// stay queued until a handler with the same name subscribes again.
+func (b *DurableBus) Unsubscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscriptions[eventType]
+	for i, s := range subs {
+		if s.handler == handler {
+			b.subscriptions[eventType] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Publish records a delivery of the event to every handler subscribed to its
+// type and attempts each one. Handler failures are queued for retry rather
+// than returned; errors are returned only when deliveries cannot be
+// recorded. Events without an ID are given one, and events with an ID
+// already delivered to a handler are not delivered to it again.
+func (b *DurableBus) Publish(ctx context.Context, event Event) error {
+	event = WithTenant(ctx, event)
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%s-%d", event.Type, b.now().UnixNano())
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = b.now()
+	}
+
+	b.mu.RLock()
+	subs := append([]subscription(nil), b.subscriptions[event.Type]...)
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		// Events published again, e.g. by an outbox retrying a dispatch, are
+		// delivered only once per handler
+		if err := b.repo.Read(ctx, DeliveryID(event.ID, s.name), &Delivery{}); err == nil {
+			continue
+		}
+		now := b.now()
+		d := &Delivery{
+			ID:          DeliveryID(event.ID, s.name),
+			Event:       event,
+			Handler:     s.name,
+			Status:      DeliveryPending,
+			NextAttempt: now,
+			Created:     now,
+		}
+		if err := b.repo.Create(ctx, d); err != nil {
+			return fmt.Errorf("failed to record delivery of %s to %s: %w", event.ID, s.name, err)
+		}
+		if err := b.updateIndex(ctx, func(index *deliveryIndex) {
+			index.Pending = append(index.Pending, d.ID)
+		}); err != nil {
+			return err
+		}
+		if _, err := b.deliver(ctx, s, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Process retries the pending deliveries that are due and returns how many
+// succeeded. Deliveries to handlers no longer subscribed are left queued.
+func (b *DurableBus) Process(ctx context.Context) (int, error) {
+	index, _ := b.readIndex(ctx)
+
+	delivered := 0
+	for _, id := range index.Pending {
+		d := &Delivery{}
+		if err := b.repo.Read(ctx, id, d); err != nil {
+			return delivered, fmt.Errorf("error reading delivery %s: %w", id, err)
+		}
+		if d.Status != DeliveryPending || d.NextAttempt.After(b.now()) {
+			continue
+		}
+		s, ok := b.subscription(d.Event.Type, d.Handler)
+		if !ok {
+			continue
+		}
+		ok, err := b.deliver(ctx, s, d)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Run processes due deliveries every interval until the context is
+// cancelled
+func (b *DurableBus) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Storage failures are retried on the next tick
+		_, _ = b.Process(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetDelivery returns the delivery of an event to a handler
+func (b *DurableBus) GetDelivery(ctx context.Context, eventID, handler string) (*Delivery, error) {
+	d := &Delivery{}
+	if err := b.repo.Read(ctx, DeliveryID(eventID, handler), d); err != nil {
+		return nil, fmt.Errorf("error reading delivery: %w", err)
+	}
+	return d, nil
+}
+
+// Pending returns the deliveries awaiting a retry
+func (b *DurableBus) Pending(ctx context.Context) ([]*Delivery, error) {
+	index, _ := b.readIndex(ctx)
+	return b.readDeliveries(ctx, index.Pending)
+}
+
+// DeadLetters returns the deliveries whose attempts ran out
+func (b *DurableBus) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	index, _ := b.readIndex(ctx)
+	return b.readDeliveries(ctx, index.Dead)
+}
+
+// Redrive moves a dead-lettered delivery back to the queue with its attempts
+// reset, typically after the handler has been fixed
+func (b *DurableBus) Redrive(ctx context.Context, deliveryID string) error {
+	d := &Delivery{}
+	if err := b.repo.Read(ctx, deliveryID, d); err != nil {
+		return fmt.Errorf("error reading delivery: %w", err)
+	}
+	if d.Status != DeliveryDead {
+		return fmt.Errorf("delivery %s is not dead-lettered", deliveryID)
+	}
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttempt = b.now()
+	if err := b.repo.Update(ctx, d); err != nil {
+		return fmt.Errorf("failed to store delivery: %w", err)
+	}
+	return b.updateIndex(ctx, func(index *deliveryIndex) {
+		index.Dead = without(index.Dead, d.ID)
+		index.Pending = append(index.Pending, d.ID)
+	})
+}
+
+// deliver runs a handler for a recorded delivery and records the outcome.
+// It reports whether the handler succeeded; errors are storage failures.
+func (b *DurableBus) deliver(ctx context.Context, s subscription, d *Delivery) (bool, error) {
+	handlerCtx := ctx
+	if id, ok := TenantID(d.Event); ok {
+		handlerCtx = tenant.WithTenant(ctx, id)
+	}
+	handleErr := s.handler.Handle(handlerCtx, d.Event)
+
+	now := b.now()
+	d.Attempts++
+	policy := b.retryPolicy(s)
+	switch {
+	case handleErr == nil:
+		d.Status = DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case d.Attempts >= policy.MaxAttempts:
+		d.Status = DeliveryDead
+		d.LastError = handleErr.Error()
+	default:
+		d.LastError = handleErr.Error()
+		d.NextAttempt = now.Add(policy.Delay(d.Attempts))
+	}
+	if err := b.repo.Update(ctx, d); err != nil {
+		return false, fmt.Errorf("failed to store delivery: %w", err)
+	}
+
+	if d.Status != DeliveryPending {
+		if err := b.updateIndex(ctx, func(index *deliveryIndex) {
+			index.Pending = without(index.Pending, d.ID)
+			if d.Status == DeliveryDead {
+				index.Dead = append(index.Dead, d.ID)
+			}
+		}); err != nil {
+			return false, err
+		}
+	}
+	return handleErr == nil, nil
+}
+
+func (b *DurableBus) retryPolicy(s subscription) RetryPolicy {
+	if s.policy != nil {
+		return *s.policy
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.policy
+}
+
+func (b *DurableBus) subscription(eventType, name string) (subscription, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscriptions[eventType] {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return subscription{}, false
+}
+
+func (b *DurableBus) readDeliveries(ctx context.Context, ids []string) ([]*Delivery, error) {
+	deliveries := make([]*Delivery, 0, len(ids))
+	for _, id := range ids {
+		d := &Delivery{}
+		if err := b.repo.Read(ctx, id, d); err != nil {
+			return nil, fmt.Errorf("error reading delivery %s: %w", id, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// readIndex returns the delivery index and whether it is stored; a missing
+// index is empty
+func (b *DurableBus) readIndex(ctx context.Context) (*deliveryIndex, bool) {
+	index := &deliveryIndex{}
+	if err := b.repo.Read(ctx, index.GetID(), index); err != nil {
+		return &deliveryIndex{}, false
+	}
+	return index, true
+}
+
+// updateIndex applies a change to the stored delivery index
+func (b *DurableBus) updateIndex(ctx context.Context, change func(index *deliveryIndex)) error {
+	b.indexMu.Lock()
+	defer b.indexMu.Unlock()
+
+	index, exists := b.readIndex(ctx)
+	change(index)
+	var err error
+	if exists {
+		err = b.repo.Update(ctx, index)
+	} else {
+		err = b.repo.Create(ctx, index)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store delivery index: %w", err)
+	}
+	return nil
+}
+
+// handlerName returns the name a handler's deliveries are tracked under
+func handlerName(handler Handler) string {
+	if named, ok := handler.(NamedHandler); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+func without(ids []string, id string) []string {
+	kept := ids[:0:0]
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
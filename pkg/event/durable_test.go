@@ -0,0 +1,102 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyHandler fails until it has been called a number of times
+type flakyHandler struct {
+	name     string
+	failures int
+	calls    int
+}
+
+func (h *flakyHandler) Name() string { return h.name }
+
+func (h *flakyHandler) Handle(ctx context.Context, e event.Event) error {
+	h.calls++
+	if h.calls <= h.failures {
+		return errors.New("handler unavailable")
+	}
+	return nil
+}
+
+func TestDurableBus(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	bus := event.NewDurableBus(memory.NewMemoryStore())
+	bus.SetClock(func() time.Time { return now })
+	bus.SetRetryPolicy(event.RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour})
+
+	healthy := &flakyHandler{name: "ledger"}
+	flaky := &flakyHandler{name: "notifier", failures: 1}
+	broken := &flakyHandler{name: "webhook", failures: 10}
+	require.NoError(t, bus.Subscribe(event.TransactionPosted, healthy))
+	require.NoError(t, bus.Subscribe(event.TransactionPosted, flaky))
+	require.NoError(t, bus.SubscribeWithPolicy(event.TransactionPosted, broken, event.RetryPolicy{MaxAttempts: 2, Backoff: time.Minute}))
+	assert.ErrorIs(t, bus.Subscribe(event.TransactionPosted, &flakyHandler{name: "ledger"}), event.ErrDuplicateHandler)
+
+	require.NoError(t, bus.Publish(ctx, event.Event{ID: "evt-1", Type: event.TransactionPosted}))
+
+	delivered, err := bus.GetDelivery(ctx, "evt-1", "ledger")
+	require.NoError(t, err)
+	assert.Equal(t, event.DeliveryDelivered, delivered.Status)
+
+	retrying, err := bus.GetDelivery(ctx, "evt-1", "notifier")
+	require.NoError(t, err)
+	assert.Equal(t, event.DeliveryPending, retrying.Status)
+	assert.Equal(t, "handler unavailable", retrying.LastError)
+	assert.Equal(t, now.Add(time.Minute), retrying.NextAttempt)
+
+	pending, err := bus.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	// Nothing is due until the backoff has passed
+	count, err := bus.Process(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	now = now.Add(time.Minute)
+	count, err = bus.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 2, flaky.calls)
+	assert.Equal(t, 1, healthy.calls, "delivered handlers are not called again")
+
+	dead, err := bus.DeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "webhook", dead[0].Handler)
+	assert.Equal(t, 2, dead[0].Attempts)
+
+	pending, err = bus.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Redriven deliveries are retried with fresh attempts
+	broken.failures = 0
+	require.NoError(t, bus.Redrive(ctx, dead[0].ID))
+	count, err = bus.Process(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	dead, err = bus.DeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, dead)
+
+	assert.Error(t, bus.Redrive(ctx, event.DeliveryID("evt-1", "ledger")))
+
+	// Publishing the same event again delivers nothing twice
+	require.NoError(t, bus.Publish(ctx, event.Event{ID: "evt-1", Type: event.TransactionPosted}))
+	assert.Equal(t, 1, healthy.calls)
+	assert.Equal(t, 2*time.Minute, event.RetryPolicy{Backoff: time.Minute, MaxBackoff: 3 * time.Minute}.Delay(2))
+	assert.Equal(t, 3*time.Minute, event.RetryPolicy{Backoff: time.Minute, MaxBackoff: 3 * time.Minute}.Delay(3))
+}
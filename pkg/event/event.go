@@ -3,18 +3,47 @@ package event
 import (
 	"context"
 	"time"
+
+	"github.com/johnayoung/finlib/pkg/tenant"
 )
 
-// Event represents a domain event in the system
+// Event represents a domain event in the system. Data is a versioned
+// payload such as TransactionPostedV1; events are serialized as an Envelope
+// recording the payload's schema version.
 type Event struct {
 	ID        string
 	Type      string
 	Timestamp time.Time
 	Source    string
-	Data      interface{}
+	Data      Payload
 	Metadata  map[string]interface{}
 }
 
+// WithTenant records the context tenant in an event's metadata unless the
+// event already names one. The metadata map is copied, not modified.
+func WithTenant(ctx context.Context, e Event) Event {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return e
+	}
+	if _, set := tenant.FromMetadata(e.Metadata); set {
+		return e
+	}
+
+	metadata := make(map[string]interface{}, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	metadata[tenant.MetadataKey] = id
+	e.Metadata = metadata
+	return e
+}
+
+// TenantID returns the tenant recorded in an event's metadata, if any
+func TenantID(e Event) (string, bool) {
+	return tenant.FromMetadata(e.Metadata)
+}
+
 // Handler processes events
 type Handler interface {
 	Handle(ctx context.Context, event Event) error
@@ -41,31 +70,30 @@ const (
 	TransactionPosted    = "transaction.posted"
 	TransactionFailed    = "transaction.failed"
 	TransactionVoided    = "transaction.voided"
+	TransactionReversed  = "transaction.reversed"
+
+	// Streamed batch posting events
+	TransactionBatchProgress  = "transaction.batch.progress"
+	TransactionBatchCompleted = "transaction.batch.completed"
 
 	// Account events
 	AccountBalanceUpdated = "account.balance.updated"
-)
+	AccountCreated        = "account.created"
+	AccountUpdated        = "account.updated"
+	AccountStatusChanged  = "account.status.changed"
+	AccountClosed         = "account.closed"
 
-// ValidationEvent contains validation result details
-type ValidationEvent struct {
-	TransactionID string
-	Valid         bool
-	Errors        []string
-	Warnings      []string
-}
+	// Approval events
+	ApprovalRequested     = "approval.requested"
+	ApprovalStepCompleted = "approval.step.completed"
+	ApprovalApproved      = "approval.approved"
+	ApprovalRejected      = "approval.rejected"
+	ApprovalExpired       = "approval.expired"
 
-// TransactionStatusEvent contains transaction status change details
-type TransactionStatusEvent struct {
-	TransactionID string
-	OldStatus     string
-	NewStatus     string
-	Reason        string
-}
+	// Cash management events
+	CashShortfallProjected = "cash.shortfall.projected"
 
-// BalanceUpdateEvent contains balance update details
-type BalanceUpdateEvent struct {
-	AccountID  string
-	OldBalance interface{}
-	NewBalance interface{}
-	ChangeType string
-}
+	// Reporting events
+	ReportGenerated = "report.generated"
+	ReportFailed    = "report.failed"
+)
@@ -0,0 +1,121 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// OutboxMessage is an event waiting in an outbox to be published
+type OutboxMessage struct {
+	ID           string     `json:"id"`
+	Event        Event      `json:"event"`
+	Dispatched   bool       `json:"dispatched"`
+	Created      time.Time  `json:"created"`
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty"`
+}
+
+// GetID returns the storage identifier of the message
+func (m *OutboxMessage) GetID() string { return m.ID }
+
+// Outbox stores events in the repository that holds the state they
+// describe. Adding an event with the context of a storage transaction
+// commits or rolls it back together with the state change, so an event is
+// recorded exactly when the change is. Dispatch then publishes the stored
+// events; paired with a DurableBus, which ignores events it has already
+// recorded, consumers see every event once.
+type Outbox struct {
+	repo storage.Repository
+	now  func() time.Time
+}
+
+// NewOutbox creates an outbox storing events in a repository
+func NewOutbox(repo storage.Repository) *Outbox {
+	return &Outbox{repo: repo, now: time.Now}
+}
+
+// SetClock sets the function used to read the current time
+func (o *Outbox) SetClock(now func() time.Time) {
+	o.now = now
+}
+
+// Add stores an event to be published. The context tenant is recorded in
+// the event; events need an ID, which also identifies the message.
+func (o *Outbox) Add(ctx context.Context, event Event) error {
+	if event.ID == "" {
+		return fmt.Errorf("outbox event ID is required")
+	}
+	event = WithTenant(ctx, event)
+	if event.Timestamp.IsZero() {
+		event.Timestamp = o.now()
+	}
+	message := &OutboxMessage{ID: "outbox:" + event.ID, Event: event, Created: o.now()}
+	if err := o.repo.Create(ctx, message); err != nil {
+		return fmt.Errorf("failed to store outbox event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Pending returns the messages not yet dispatched, oldest first
+func (o *Outbox) Pending(ctx context.Context) ([]*OutboxMessage, error) {
+	var messages []*OutboxMessage
+	err := o.repo.Query(ctx, storage.Query{
+		Filters: []storage.Filter{{Field: "dispatched", Operator: "=", Value: false}},
+	}, &messages)
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbox: %w", err)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		if !messages[i].Created.Equal(messages[j].Created) {
+			return messages[i].Created.Before(messages[j].Created)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+	return messages, nil
+}
+
+// Dispatch publishes pending messages in order and marks them dispatched.
+// It stops at the first event that cannot be published so later events are
+// not published ahead of it, and returns how many were dispatched.
+func (o *Outbox) Dispatch(ctx context.Context, publisher Publisher) (int, error) {
+	messages, err := o.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, m := range messages {
+		if err := publisher.Publish(ctx, m.Event); err != nil {
+			return dispatched, fmt.Errorf("failed to publish outbox event %s: %w", m.Event.ID, err)
+		}
+		now := o.now()
+		m.Dispatched = true
+		m.DispatchedAt = &now
+		if err := o.repo.Update(ctx, m); err != nil {
+			return dispatched, fmt.Errorf("failed to store outbox event %s: %w", m.Event.ID, err)
+		}
+		dispatched++
+	}
+	return dispatched, nil
+}
+
+// Run dispatches pending messages every interval until the context is
+// cancelled
+func (o *Outbox) Run(ctx context.Context, publisher Publisher, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Failed messages are retried on the next tick
+		_, _ = o.Dispatch(ctx, publisher)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package event
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// Payload is the typed data of an event. Each payload struct is one
+// version of the schema of an event type, or of a family of event types
+// sharing a schema; changing a schema means adding a new struct with a
+// higher version and an Upcaster from the previous one.
+type Payload interface {
+	// SchemaVersion returns the version of the payload's schema
+	SchemaVersion() int
+}
+
+// TransactionValidatedV1 is the payload of TransactionValidated events
+type TransactionValidatedV1 struct {
+	TransactionID string   `json:"transaction_id"`
+	Valid         bool     `json:"valid"`
+	Errors        []string `json:"errors,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+func (TransactionValidatedV1) SchemaVersion() int { return 1 }
+
+// TransactionPostedV1 is the payload of TransactionPosted events
+type TransactionPostedV1 struct {
+	TransactionID string `json:"transaction_id"`
+	OldStatus     string `json:"old_status"`
+	NewStatus     string `json:"new_status"`
+}
+
+func (TransactionPostedV1) SchemaVersion() int { return 1 }
+
+// TransactionVoidedV1 is the payload of TransactionVoided events
+type TransactionVoidedV1 struct {
+	TransactionID string `json:"transaction_id"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+func (TransactionVoidedV1) SchemaVersion() int { return 1 }
+
+// TransactionReversedV1 is the payload of TransactionReversed events
+type TransactionReversedV1 struct {
+	TransactionID string `json:"transaction_id"`
+	ReversalID    string `json:"reversal_id"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+func (TransactionReversedV1) SchemaVersion() int { return 1 }
+
+// TransactionBatchV1 is the payload of TransactionBatchProgress and
+// TransactionBatchCompleted events, counting transactions so far
+type TransactionBatchV1 struct {
+	BatchID  string `json:"batch_id"`
+	Received int64  `json:"received"`
+	Posted   int64  `json:"posted"`
+	Failed   int64  `json:"failed"`
+	Chunks   int64  `json:"chunks"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (TransactionBatchV1) SchemaVersion() int { return 1 }
+
+// AccountBalanceUpdatedV1 is the payload of AccountBalanceUpdated events
+type AccountBalanceUpdatedV1 struct {
+	AccountID  string      `json:"account_id"`
+	OldBalance money.Money `json:"old_balance"`
+	NewBalance money.Money `json:"new_balance"`
+	ChangeType string      `json:"change_type,omitempty"`
+}
+
+func (AccountBalanceUpdatedV1) SchemaVersion() int { return 1 }
+
+// AccountCreatedV1 is the payload of AccountCreated events
+type AccountCreatedV1 struct {
+	AccountID string `json:"account_id"`
+	Code      string `json:"code"`
+	Status    string `json:"status"`
+}
+
+func (AccountCreatedV1) SchemaVersion() int { return 1 }
+
+// AccountChangedV1 is the payload of AccountUpdated, AccountStatusChanged
+// and AccountClosed events
+type AccountChangedV1 struct {
+	AccountID string `json:"account_id"`
+	Code      string `json:"code"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (AccountChangedV1) SchemaVersion() int { return 1 }
+
+// ApprovalV1 is the payload of approval workflow events
+type ApprovalV1 struct {
+	RequestID     string `json:"request_id"`
+	TransactionID string `json:"transaction_id"`
+	ChainID       string `json:"chain_id"`
+	Step          int    `json:"step"`
+	ApproverID    string `json:"approver_id,omitempty"`
+	Status        string `json:"status"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+func (ApprovalV1) SchemaVersion() int { return 1 }
+
+// CashShortfallProjectedV1 is the payload of CashShortfallProjected events.
+// Amounts are decimal strings in the forecast currency.
+type CashShortfallProjectedV1 struct {
+	Date     time.Time `json:"date"`
+	Currency string    `json:"currency"`
+	Closing  string    `json:"closing"`
+	Minimum  string    `json:"minimum"`
+	Gap      string    `json:"gap"`
+}
+
+func (CashShortfallProjectedV1) SchemaVersion() int { return 1 }
+
+// ReportRunV1 is the payload of ReportGenerated and ReportFailed events
+type ReportRunV1 struct {
+	ScheduleID  string `json:"schedule_id"`
+	ReportID    string `json:"report_id,omitempty"`
+	Format      string `json:"format"`
+	Destination string `json:"destination,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func (ReportRunV1) SchemaVersion() int { return 1 }
+
+// registerBuiltins registers the payloads of the built-in event types
+func registerBuiltins(r *Registry) {
+	for eventType, payload := range map[string]Payload{
+		TransactionValidated:      TransactionValidatedV1{},
+		TransactionPosted:         TransactionPostedV1{},
+		TransactionVoided:         TransactionVoidedV1{},
+		TransactionReversed:       TransactionReversedV1{},
+		TransactionBatchProgress:  TransactionBatchV1{},
+		TransactionBatchCompleted: TransactionBatchV1{},
+		AccountBalanceUpdated:     AccountBalanceUpdatedV1{},
+		AccountCreated:            AccountCreatedV1{},
+		AccountUpdated:            AccountChangedV1{},
+		AccountStatusChanged:      AccountChangedV1{},
+		AccountClosed:             AccountChangedV1{},
+		ApprovalRequested:         ApprovalV1{},
+		ApprovalStepCompleted:     ApprovalV1{},
+		ApprovalApproved:          ApprovalV1{},
+		ApprovalRejected:          ApprovalV1{},
+		ApprovalExpired:           ApprovalV1{},
+		CashShortfallProjected:    CashShortfallProjectedV1{},
+		ReportGenerated:           ReportRunV1{},
+		ReportFailed:              ReportRunV1{},
+	} {
+		r.Register(eventType, payload)
+	}
+}
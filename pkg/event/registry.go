@@ -0,0 +1,167 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrUnknownSchema is returned when decoding an event whose type and schema
+// version have no registered payload
+var ErrUnknownSchema = errors.New("unknown event schema")
+
+// Upcaster rewrites the JSON of a payload from one schema version into the
+// next, so events stored under old versions decode into current payloads
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// Envelope is the serialized form of an event. Data is the JSON of the
+// payload at the recorded schema version.
+type Envelope struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Version   int                    `json:"version,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Source    string                 `json:"source,omitempty"`
+	Data      json.RawMessage        `json:"data,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type schemaKey struct {
+	eventType string
+	version   int
+}
+
+// Registry maps event types and schema versions to payload types and holds
+// the upcasters between versions
+type Registry struct {
+	mu        sync.RWMutex
+	payloads  map[schemaKey]reflect.Type
+	upcasters map[schemaKey]Upcaster
+}
+
+// DefaultRegistry knows the payloads of the built-in event types. Events are
+// serialized with it, so custom payloads should be registered here too.
+var DefaultRegistry = NewDefaultRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		payloads:  make(map[schemaKey]reflect.Type),
+		upcasters: make(map[schemaKey]Upcaster),
+	}
+}
+
+// NewDefaultRegistry creates a registry with the built-in payloads
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	registerBuiltins(r)
+	return r
+}
+
+// Register records the payload type of an event type at the payload's
+// schema version
+func (r *Registry) Register(eventType string, payload Payload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads[schemaKey{eventType, payload.SchemaVersion()}] = reflect.TypeOf(payload)
+}
+
+// RegisterUpcaster records how to rewrite payloads of an event type from a
+// schema version into the next
+func (r *Registry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upcasters[schemaKey{eventType, fromVersion}] = upcaster
+}
+
+// Marshal serializes an event as an Envelope
+func (r *Registry) Marshal(e Event) ([]byte, error) {
+	envelope := Envelope{
+		ID:        e.ID,
+		Type:      e.Type,
+		Timestamp: e.Timestamp,
+		Source:    e.Source,
+		Metadata:  e.Metadata,
+	}
+	if e.Data != nil {
+		data, err := json.Marshal(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s payload: %w", e.Type, err)
+		}
+		envelope.Version = e.Data.SchemaVersion()
+		envelope.Data = data
+	}
+	return json.Marshal(envelope)
+}
+
+// Unmarshal decodes an Envelope, upcasting its payload to the latest
+// registered schema version
+func (r *Registry) Unmarshal(data []byte) (Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	e := Event{
+		ID:        envelope.ID,
+		Type:      envelope.Type,
+		Timestamp: envelope.Timestamp,
+		Source:    envelope.Source,
+		Metadata:  envelope.Metadata,
+	}
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return e, nil
+	}
+	payload, err := r.Decode(envelope.Type, envelope.Version, envelope.Data)
+	if err != nil {
+		return Event{}, err
+	}
+	e.Data = payload
+	return e, nil
+}
+
+// Decode decodes the JSON of a payload stored at a schema version,
+// upcasting it to the latest registered version
+func (r *Registry) Decode(eventType string, version int, data json.RawMessage) (Payload, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for {
+		upcast, ok := r.upcasters[schemaKey{eventType, version}]
+		if !ok {
+			break
+		}
+		var err error
+		if data, err = upcast(data); err != nil {
+			return nil, fmt.Errorf("failed to upcast %s from version %d: %w", eventType, version, err)
+		}
+		version++
+	}
+
+	t, ok := r.payloads[schemaKey{eventType, version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s version %d", ErrUnknownSchema, eventType, version)
+	}
+	payload := reflect.New(t)
+	if err := json.Unmarshal(data, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload: %w", eventType, err)
+	}
+	return payload.Elem().Interface().(Payload), nil
+}
+
+// MarshalJSON serializes the event as an Envelope using DefaultRegistry
+func (e Event) MarshalJSON() ([]byte, error) {
+	return DefaultRegistry.Marshal(e)
+}
+
+// UnmarshalJSON decodes an Envelope using DefaultRegistry
+func (e *Event) UnmarshalJSON(data []byte) error {
+	decoded, err := DefaultRegistry.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	*e = decoded
+	return nil
+}
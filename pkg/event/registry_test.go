@@ -0,0 +1,90 @@
+package event_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transactionPostedV2 splits the status change of TransactionPostedV1 into
+// a nested object
+type transactionPostedV2 struct {
+	TransactionID string `json:"transaction_id"`
+	Status        struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"status"`
+}
+
+func (transactionPostedV2) SchemaVersion() int { return 2 }
+
+func TestRegistry(t *testing.T) {
+	posted := event.Event{
+		ID:        "TX001-posted",
+		Type:      event.TransactionPosted,
+		Timestamp: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		Source:    "transaction",
+		Data:      event.TransactionPostedV1{TransactionID: "TX001", OldStatus: "DRAFT", NewStatus: "POSTED"},
+		Metadata:  map[string]interface{}{"tenant_id": "acme"},
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := json.Marshal(posted)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"version":1`)
+
+		var decoded event.Event
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, posted, decoded)
+
+		balance := event.Event{ID: "b", Type: event.AccountBalanceUpdated, Data: event.AccountBalanceUpdatedV1{
+			AccountID:  "1000",
+			NewBalance: money.Money{Amount: decimal.NewFromInt(5), Currency: "USD"},
+		}}
+		data, err = json.Marshal(balance)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		payload, ok := decoded.Data.(event.AccountBalanceUpdatedV1)
+		require.True(t, ok)
+		assert.Equal(t, "5", payload.NewBalance.Amount.String())
+	})
+
+	t.Run("upcasting", func(t *testing.T) {
+		registry := event.NewDefaultRegistry()
+		registry.Register(event.TransactionPosted, transactionPostedV2{})
+		registry.RegisterUpcaster(event.TransactionPosted, 1, func(data json.RawMessage) (json.RawMessage, error) {
+			var v1 event.TransactionPostedV1
+			if err := json.Unmarshal(data, &v1); err != nil {
+				return nil, err
+			}
+			var v2 transactionPostedV2
+			v2.TransactionID = v1.TransactionID
+			v2.Status.From, v2.Status.To = v1.OldStatus, v1.NewStatus
+			return json.Marshal(v2)
+		})
+
+		stored, err := event.DefaultRegistry.Marshal(posted)
+		require.NoError(t, err)
+		decoded, err := registry.Unmarshal(stored)
+		require.NoError(t, err)
+		v2, ok := decoded.Data.(transactionPostedV2)
+		require.True(t, ok)
+		assert.Equal(t, "TX001", v2.TransactionID)
+		assert.Equal(t, "POSTED", v2.Status.To)
+	})
+
+	t.Run("unknown schema", func(t *testing.T) {
+		_, err := event.NewRegistry().Unmarshal([]byte(`{"id":"x","type":"custom.event","version":1,"data":{}}`))
+		assert.ErrorIs(t, err, event.ErrUnknownSchema)
+
+		decoded, err := event.NewRegistry().Unmarshal([]byte(`{"id":"x","type":"custom.event"}`))
+		require.NoError(t, err)
+		assert.Nil(t, decoded.Data)
+	})
+}
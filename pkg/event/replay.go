@@ -0,0 +1,417 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// LoggedEvent is an event in a replayable bus's log. Offsets start at one
+// and increase by one with every published event.
+type LoggedEvent struct {
+	ID       string    `json:"id"`
+	Offset   int64     `json:"offset"`
+	Recorded time.Time `json:"recorded"`
+	Event    Event     `json:"event"`
+}
+
+// GetID returns the storage identifier of the logged event
+func (e *LoggedEvent) GetID() string { return e.ID }
+
+func logID(offset int64) string {
+	return fmt.Sprintf("event-log:%d", offset)
+}
+
+// logHead records the offset of the last logged event
+type logHead struct {
+	Offset int64 `json:"offset"`
+}
+
+func (h *logHead) GetID() string { return "event-log-head" }
+
+// SubscriptionCursor is the persisted position of a subscriber in the
+// event log: the offset of the last event it has processed
+type SubscriptionCursor struct {
+	ID         string    `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	Offset     int64     `json:"offset"`
+	Updated    time.Time `json:"updated"`
+}
+
+// GetID returns the storage identifier of the cursor
+func (c *SubscriptionCursor) GetID() string { return c.ID }
+
+func cursorID(subscriber string) string {
+	return "cursor:" + subscriber
+}
+
+// consumer is a named subscriber of a replayable bus and its position
+type consumer struct {
+	mu      sync.Mutex
+	name    string
+	handler Handler
+	types   map[string]bool
+	// Offset of the last event processed, once loaded
+	offset atomic.Int64
+	// Head of the log when the consumer subscribed, where it starts
+	// without a persisted position
+	start  int64
+	loaded bool
+	stored bool
+}
+
+// ReplayableBus is an event bus that logs every event in a repository and
+// tracks how far each subscriber has read. Subscribers resume from their
+// persisted position after a restart, and new subscribers can start from
+// any offset or time to replay history, e.g. to build a projection.
+//
+// Each subscriber receives events in log order. An event its handler fails
+// is retried on the next Publish or CatchUp, holding back later events, so
+// handlers must tolerate receiving an event more than once.
+type ReplayableBus struct {
+	mu        sync.Mutex
+	repo      storage.Repository
+	consumers map[string]*consumer
+	head      int64
+	headRead  bool
+	now       func() time.Time
+}
+
+// NewReplayableBus creates a replayable bus logging events in a repository
+func NewReplayableBus(repo storage.Repository) *ReplayableBus {
+	return &ReplayableBus{
+		repo:      repo,
+		consumers: make(map[string]*consumer),
+		now:       time.Now,
+	}
+}
+
+// SetClock sets the function used to read the current time
+func (b *ReplayableBus) SetClock(now func() time.Time) {
+	b.now = now
+}
+
+// Publish appends an event to the log and delivers it to the subscribers
+// of its type that have caught up. Errors are returned only when the event
+// cannot be logged; failed handlers retry it later.
+func (b *ReplayableBus) Publish(ctx context.Context, event Event) error {
+	event = WithTenant(ctx, event)
+	if event.Timestamp.IsZero() {
+		event.Timestamp = b.now()
+	}
+
+	b.mu.Lock()
+	head := b.readHead(ctx)
+	logged := &LoggedEvent{ID: logID(head + 1), Offset: head + 1, Recorded: b.now(), Event: event}
+	if logged.Event.ID == "" {
+		logged.Event.ID = logged.ID
+	}
+	if err := b.repo.Create(ctx, logged); err != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("failed to log event: %w", err)
+	}
+	if err := b.writeHead(ctx, logged.Offset); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	consumers := b.consumersOf(event.Type)
+	b.mu.Unlock()
+
+	for _, c := range consumers {
+		// Handler failures hold the subscriber back until it catches up
+		_ = b.drain(ctx, c)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for an event type. A handler's events are
+// tracked under its name (see NamedHandler): it resumes from its persisted
+// position, or receives only new events when it has none. Subscribing the
+// same handler to several types shares one position.
+func (b *ReplayableBus) Subscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribe(context.Background(), eventType, handler)
+	return nil
+}
+
+// SubscribeFrom registers a handler for event types and replays the logged
+// events after an offset to it, replacing any persisted position. Offset
+// zero replays the whole log.
+func (b *ReplayableBus) SubscribeFrom(ctx context.Context, handler Handler, offset int64, eventTypes ...string) error {
+	b.mu.Lock()
+	var c *consumer
+	for _, eventType := range eventTypes {
+		c = b.subscribe(ctx, eventType, handler)
+	}
+	b.mu.Unlock()
+	if c == nil {
+		return fmt.Errorf("at least one event type is required")
+	}
+
+	c.mu.Lock()
+	b.loadCursor(ctx, c)
+	c.offset.Store(offset)
+	err := b.saveCursor(ctx, c)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return b.drain(ctx, c)
+}
+
+// SubscribeSince registers a handler for event types and replays the events
+// logged at or after a time to it
+func (b *ReplayableBus) SubscribeSince(ctx context.Context, handler Handler, since time.Time, eventTypes ...string) error {
+	offset, err := b.OffsetAt(ctx, since)
+	if err != nil {
+		return err
+	}
+	return b.SubscribeFrom(ctx, handler, offset, eventTypes...)
+}
+
+// Unsubscribe removes a handler for an event type. Its position is kept.
+func (b *ReplayableBus) Unsubscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.consumers[handlerName(handler)]
+	if !ok {
+		return nil
+	}
+	delete(c.types, eventType)
+	if len(c.types) == 0 {
+		delete(b.consumers, c.name)
+	}
+	return nil
+}
+
+// CatchUp delivers every subscriber the events logged after its position,
+// for instance after a restart or once a failing handler has recovered. It
+// returns the first handler or storage error after trying every subscriber.
+func (b *ReplayableBus) CatchUp(ctx context.Context) error {
+	b.mu.Lock()
+	consumers := make([]*consumer, 0, len(b.consumers))
+	for _, c := range b.consumers {
+		consumers = append(consumers, c)
+	}
+	b.mu.Unlock()
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].name < consumers[j].name })
+
+	var first error
+	for _, c := range consumers {
+		if err := b.drain(ctx, c); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Head returns the offset of the last logged event
+func (b *ReplayableBus) Head(ctx context.Context) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.readHead(ctx), nil
+}
+
+// Position returns the offset of the last event a subscriber has processed
+func (b *ReplayableBus) Position(ctx context.Context, subscriber string) (int64, error) {
+	cursor := &SubscriptionCursor{}
+	if err := b.repo.Read(ctx, cursorID(subscriber), cursor); err != nil {
+		return 0, fmt.Errorf("error reading cursor of %s: %w", subscriber, err)
+	}
+	return cursor.Offset, nil
+}
+
+// OffsetAt returns the offset to replay from to receive the events logged
+// at or after a time
+func (b *ReplayableBus) OffsetAt(ctx context.Context, since time.Time) (int64, error) {
+	head, err := b.Head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var searchErr error
+	first := sort.Search(int(head), func(i int) bool {
+		logged, err := b.read(ctx, int64(i)+1)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !logged.Recorded.Before(since)
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+	return int64(first), nil
+}
+
+// subscribe adds an event type to a handler's subscription. Callers hold
+// b.mu.
+func (b *ReplayableBus) subscribe(ctx context.Context, eventType string, handler Handler) *consumer {
+	name := handlerName(handler)
+	c, ok := b.consumers[name]
+	if !ok {
+		c = &consumer{name: name, handler: handler, types: make(map[string]bool), start: b.readHead(ctx)}
+		b.consumers[name] = c
+	}
+	c.types[eventType] = true
+	return c
+}
+
+func (b *ReplayableBus) consumersOf(eventType string) []*consumer {
+	consumers := make([]*consumer, 0)
+	for _, c := range b.consumers {
+		if c.types[eventType] {
+			consumers = append(consumers, c)
+		}
+	}
+	return consumers
+}
+
+// drain delivers a subscriber the events after its position until it
+// reaches the head of the log. A subscriber already being drained is left
+// to that drain, which picks up events logged while it runs.
+func (b *ReplayableBus) drain(ctx context.Context, c *consumer) error {
+	for {
+		if !c.mu.TryLock() {
+			return nil
+		}
+		err := b.drainLocked(ctx, c)
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		head, err := b.Head(ctx)
+		if err != nil || c.offset.Load() >= head {
+			return err
+		}
+	}
+}
+
+func (b *ReplayableBus) drainLocked(ctx context.Context, c *consumer) error {
+	b.loadCursor(ctx, c)
+	start := c.offset.Load()
+	defer func() {
+		if c.offset.Load() != start {
+			_ = b.saveCursor(ctx, c)
+		}
+	}()
+
+	for {
+		head, err := b.Head(ctx)
+		if err != nil {
+			return err
+		}
+		if c.offset.Load() >= head {
+			return nil
+		}
+		for offset := c.offset.Load() + 1; offset <= head; offset++ {
+			logged, err := b.read(ctx, offset)
+			if err != nil {
+				return err
+			}
+			if b.subscribed(c, logged.Event.Type) {
+				handlerCtx := ctx
+				if id, ok := TenantID(logged.Event); ok {
+					handlerCtx = tenant.WithTenant(ctx, id)
+				}
+				if err := c.handler.Handle(handlerCtx, logged.Event); err != nil {
+					return fmt.Errorf("%s failed at offset %d: %w", c.name, offset, err)
+				}
+				c.offset.Store(offset)
+				if err := b.saveCursor(ctx, c); err != nil {
+					return err
+				}
+				continue
+			}
+			c.offset.Store(offset)
+		}
+	}
+}
+
+func (b *ReplayableBus) subscribed(c *consumer, eventType string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return c.types[eventType]
+}
+
+// loadCursor reads a subscriber's persisted position the first time it is
+// needed. Subscribers without one start at the head of the log as it was
+// when they subscribed.
+func (b *ReplayableBus) loadCursor(ctx context.Context, c *consumer) {
+	if c.loaded {
+		return
+	}
+	cursor := &SubscriptionCursor{}
+	if err := b.repo.Read(ctx, cursorID(c.name), cursor); err == nil {
+		c.offset.Store(cursor.Offset)
+		c.stored = true
+	} else {
+		c.offset.Store(c.start)
+	}
+	c.loaded = true
+}
+
+func (b *ReplayableBus) saveCursor(ctx context.Context, c *consumer) error {
+	cursor := &SubscriptionCursor{
+		ID:         cursorID(c.name),
+		Subscriber: c.name,
+		Offset:     c.offset.Load(),
+		Updated:    b.now(),
+	}
+	var err error
+	if c.stored {
+		err = b.repo.Update(ctx, cursor)
+	} else {
+		err = b.repo.Create(ctx, cursor)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store cursor of %s: %w", c.name, err)
+	}
+	c.stored = true
+	return nil
+}
+
+func (b *ReplayableBus) read(ctx context.Context, offset int64) (*LoggedEvent, error) {
+	logged := &LoggedEvent{}
+	if err := b.repo.Read(ctx, logID(offset), logged); err != nil {
+		return nil, fmt.Errorf("error reading event %d: %w", offset, err)
+	}
+	return logged, nil
+}
+
+// readHead returns the offset of the last logged event; an empty log has
+// no stored head. Callers hold b.mu.
+func (b *ReplayableBus) readHead(ctx context.Context) int64 {
+	if !b.headRead {
+		head := &logHead{}
+		if err := b.repo.Read(ctx, head.GetID(), head); err == nil {
+			b.head = head.Offset
+		}
+		b.headRead = true
+	}
+	return b.head
+}
+
+// writeHead stores the offset of the last logged event. Callers hold b.mu.
+func (b *ReplayableBus) writeHead(ctx context.Context, offset int64) error {
+	head := &logHead{Offset: offset}
+	var err error
+	if b.head == 0 {
+		err = b.repo.Create(ctx, head)
+	} else {
+		err = b.repo.Update(ctx, head)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store event log head: %w", err)
+	}
+	b.head = offset
+	return nil
+}
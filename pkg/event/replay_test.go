@@ -0,0 +1,97 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the IDs of the events it handles and fails
+// while broken is set
+type recordingHandler struct {
+	name   string
+	broken bool
+	seen   []string
+}
+
+func (h *recordingHandler) Name() string { return h.name }
+
+func (h *recordingHandler) Handle(ctx context.Context, e event.Event) error {
+	if h.broken {
+		return errors.New("projection unavailable")
+	}
+	h.seen = append(h.seen, e.ID)
+	return nil
+}
+
+func TestReplayableBus(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	bus := event.NewReplayableBus(store)
+	bus.SetClock(func() time.Time { return now })
+
+	live := &recordingHandler{name: "cache"}
+	require.NoError(t, bus.Subscribe(event.TransactionPosted, live))
+
+	publish := func(id, eventType string) {
+		require.NoError(t, bus.Publish(ctx, event.Event{ID: id, Type: eventType}))
+		now = now.Add(time.Hour)
+	}
+	publish("tx-1", event.TransactionPosted)
+	publish("acc-1", event.AccountCreated)
+	publish("tx-2", event.TransactionPosted)
+	assert.Equal(t, []string{"tx-1", "tx-2"}, live.seen)
+
+	head, err := bus.Head(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), head)
+
+	t.Run("replay from offset", func(t *testing.T) {
+		projector := &recordingHandler{name: "projector"}
+		require.NoError(t, bus.SubscribeFrom(ctx, projector, 0, event.TransactionPosted, event.AccountCreated))
+		assert.Equal(t, []string{"tx-1", "acc-1", "tx-2"}, projector.seen)
+
+		position, err := bus.Position(ctx, "projector")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), position)
+	})
+
+	t.Run("replay from time", func(t *testing.T) {
+		late := &recordingHandler{name: "late"}
+		since := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, bus.SubscribeSince(ctx, late, since, event.TransactionPosted, event.AccountCreated))
+		assert.Equal(t, []string{"acc-1", "tx-2"}, late.seen)
+	})
+
+	t.Run("failed handlers hold their position", func(t *testing.T) {
+		live.broken = true
+		publish("tx-3", event.TransactionPosted)
+		publish("tx-4", event.TransactionPosted)
+		position, err := bus.Position(ctx, "cache")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), position)
+
+		live.broken = false
+		require.NoError(t, bus.CatchUp(ctx))
+		assert.Equal(t, []string{"tx-1", "tx-2", "tx-3", "tx-4"}, live.seen)
+	})
+
+	t.Run("restart resumes from the persisted position", func(t *testing.T) {
+		restarted := event.NewReplayableBus(store)
+		resumed := &recordingHandler{name: "cache"}
+		require.NoError(t, restarted.Subscribe(event.TransactionPosted, resumed))
+		require.NoError(t, restarted.Publish(ctx, event.Event{ID: "tx-5", Type: event.TransactionPosted}))
+		assert.Equal(t, []string{"tx-5"}, resumed.seen)
+
+		head, err := restarted.Head(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), head)
+	})
+}
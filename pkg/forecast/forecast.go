@@ -0,0 +1,189 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// Forecaster projects cash balances from a set of flow sources and raises
+// shortfall alerts on the event bus
+type Forecaster struct {
+	mu        sync.RWMutex
+	sources   []Source
+	publisher event.Publisher
+	now       func() time.Time
+}
+
+// NewForecaster creates a forecaster. The publisher may be nil, in which case
+// shortfalls are only reported in the forecast itself.
+func NewForecaster(publisher event.Publisher, sources ...Source) *Forecaster {
+	return &Forecaster{
+		sources:   sources,
+		publisher: publisher,
+		now:       time.Now,
+	}
+}
+
+// AddSource registers an additional flow source
+func (f *Forecaster) AddSource(source Source) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sources = append(f.sources, source)
+}
+
+// Forecast projects the cash position over the requested horizon
+func (f *Forecaster) Forecast(ctx context.Context, req Request) (*Forecast, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+
+	currency := req.OpeningBalance.Currency
+	start := day(req.Start)
+	width := 1
+	if req.Granularity == Weekly {
+		width = 7
+	}
+	end := start.AddDate(0, 0, req.Periods*width-1)
+
+	forecast := &Forecast{
+		Currency:    currency,
+		Granularity: req.Granularity,
+		Start:       start,
+		End:         end,
+		Opening:     req.OpeningBalance,
+		Buckets:     make([]Bucket, req.Periods),
+		GeneratedAt: f.now(),
+	}
+	for i := range forecast.Buckets {
+		bucketStart := start.AddDate(0, 0, i*width)
+		forecast.Buckets[i] = Bucket{
+			Start:    bucketStart,
+			End:      bucketStart.AddDate(0, 0, width-1),
+			Inflows:  zero(currency),
+			Outflows: zero(currency),
+		}
+	}
+
+	f.mu.RLock()
+	sources := append([]Source(nil), f.sources...)
+	f.mu.RUnlock()
+
+	for _, source := range sources {
+		flows, err := source.Flows(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("error collecting cash flows: %w", err)
+		}
+		for _, flow := range flows {
+			if flow.Amount.Currency != currency {
+				return nil, fmt.Errorf("%w: flow %s is in %s, forecast is in %s",
+					ErrCurrency, flow.Reference, flow.Amount.Currency, currency)
+			}
+			date := day(flow.Date)
+			if date.Before(start) || date.After(end) {
+				continue
+			}
+			bucket := &forecast.Buckets[daysBetween(start, date)/width]
+			bucket.Flows = append(bucket.Flows, flow)
+			if flow.Direction == Outflow {
+				bucket.Outflows.Amount = bucket.Outflows.Amount.Add(flow.Amount.Amount)
+			} else {
+				bucket.Inflows.Amount = bucket.Inflows.Amount.Add(flow.Amount.Amount)
+			}
+		}
+	}
+
+	balance := req.OpeningBalance.Amount
+	for i := range forecast.Buckets {
+		bucket := &forecast.Buckets[i]
+		sort.SliceStable(bucket.Flows, func(a, b int) bool {
+			return bucket.Flows[a].Date.Before(bucket.Flows[b].Date)
+		})
+		bucket.Opening = money.Money{Amount: balance, Currency: currency}
+		balance = balance.Add(bucket.Inflows.Amount).Sub(bucket.Outflows.Amount)
+		bucket.Closing = money.Money{Amount: balance, Currency: currency}
+
+		if minimum := req.MinimumBalance.Amount; balance.LessThan(minimum) {
+			forecast.Shortfalls = append(forecast.Shortfalls, Shortfall{
+				Date:    bucket.End,
+				Closing: bucket.Closing,
+				Minimum: money.Money{Amount: minimum, Currency: currency},
+				Gap:     money.Money{Amount: minimum.Sub(balance), Currency: currency},
+			})
+		}
+	}
+	forecast.Closing = money.Money{Amount: balance, Currency: currency}
+
+	if err := f.publishShortfalls(ctx, forecast); err != nil {
+		return forecast, err
+	}
+	return forecast, nil
+}
+
+// publishShortfalls raises one alert per projected shortfall
+func (f *Forecaster) publishShortfalls(ctx context.Context, forecast *Forecast) error {
+	if f.publisher == nil {
+		return nil
+	}
+	for _, s := range forecast.Shortfalls {
+		err := f.publisher.Publish(ctx, event.Event{
+			ID:        fmt.Sprintf("shortfall-%s", s.Date.Format("2006-01-02")),
+			Type:      event.CashShortfallProjected,
+			Timestamp: forecast.GeneratedAt,
+			Source:    "forecast",
+			Data: event.CashShortfallProjectedV1{
+				Date:     s.Date,
+				Currency: forecast.Currency,
+				Closing:  s.Closing.Amount.String(),
+				Minimum:  s.Minimum.Amount.String(),
+				Gap:      s.Gap.Amount.String(),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish shortfall alert: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateRequest(req Request) error {
+	if req.OpeningBalance.Currency == "" {
+		return fmt.Errorf("%w: opening balance currency is required", ErrInvalidRequest)
+	}
+	if req.Start.IsZero() {
+		return fmt.Errorf("%w: start date is required", ErrInvalidRequest)
+	}
+	if req.Periods <= 0 {
+		return fmt.Errorf("%w: periods must be positive", ErrInvalidRequest)
+	}
+	if req.Granularity != Daily && req.Granularity != Weekly {
+		return fmt.Errorf("%w: unknown granularity %q", ErrInvalidRequest, req.Granularity)
+	}
+	if req.MinimumBalance.Currency != "" && req.MinimumBalance.Currency != req.OpeningBalance.Currency {
+		return fmt.Errorf("%w: minimum balance must be in %s", ErrCurrency, req.OpeningBalance.Currency)
+	}
+	return nil
+}
+
+// day truncates a time to midnight in its own location
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween counts calendar days between two midnights, tolerating
+// daylight saving shifts
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func zero(currency string) money.Money {
+	return money.Money{Amount: decimal.Zero, Currency: currency}
+}
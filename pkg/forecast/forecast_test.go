@@ -0,0 +1,135 @@
+package forecast
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/invoice"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/payable"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	events []event.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e event.Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func date(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestForecast(t *testing.T) {
+	ctx := context.Background()
+	overdue := date(time.February, 20)
+	due := date(time.March, 4)
+	invoices := []*invoice.Invoice{
+		{
+			ID: "inv-1", Number: "INV-1", Type: invoice.StandardInvoice, Status: invoice.Issued,
+			Currency: "USD", DueDate: &overdue,
+			Lines: []invoice.Line{{Quantity: decimal.NewFromInt(1), UnitPrice: usd(300)}},
+		},
+		{
+			ID: "inv-2", Number: "INV-2", Type: invoice.StandardInvoice, Status: invoice.Issued,
+			Currency: "USD", DueDate: &due,
+			Lines: []invoice.Line{{Quantity: decimal.NewFromInt(2), UnitPrice: usd(100)}},
+		},
+		{
+			ID: "inv-3", Type: invoice.StandardInvoice, Status: invoice.Paid,
+			Currency: "USD", DueDate: &due,
+			Lines: []invoice.Line{{Quantity: decimal.NewFromInt(1), UnitPrice: usd(999)}},
+		},
+	}
+	bills := []*payable.Bill{
+		{
+			ID: "bill-1", Number: "B-1", Status: payable.Posted, Currency: "USD",
+			DueDate: date(time.March, 2),
+			Lines:   []payable.BillLine{{Amount: usd(1200)}},
+		},
+	}
+	payroll := RecurringItem{
+		ID: "payroll", Description: "Payroll", Amount: usd(250), Direction: Outflow,
+		Frequency: EveryWeek, Start: date(time.February, 23),
+	}
+	loan := Flow{Date: date(time.March, 6), Amount: usd(2000), Direction: Inflow, Reference: "loan"}
+
+	publisher := &recordingPublisher{}
+	forecaster := NewForecaster(publisher, Receivables(invoices), Payables(bills), Recurring(payroll))
+	forecaster.AddSource(Scheduled(loan))
+
+	t.Run("daily", func(t *testing.T) {
+		publisher.events = nil
+		result, err := forecaster.Forecast(ctx, Request{
+			OpeningBalance: usd(1000),
+			Start:          date(time.March, 1).Add(9 * time.Hour),
+			Periods:        7,
+			Granularity:    Daily,
+			MinimumBalance: usd(100),
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Buckets, 7)
+		assert.Equal(t, date(time.March, 7), result.End)
+
+		// Overdue receivable is collected on day one; payroll runs every Friday
+		assert.True(t, result.Buckets[0].Inflows.Amount.Equal(decimal.NewFromInt(300)))
+		assert.True(t, result.Buckets[0].Outflows.Amount.Equal(decimal.NewFromInt(250)))
+		assert.True(t, result.Buckets[0].Closing.Amount.Equal(decimal.NewFromInt(1050)))
+
+		// Bill on the 2nd drives the balance below zero until the loan lands
+		assert.True(t, result.Buckets[1].Closing.Amount.Equal(decimal.NewFromInt(-150)))
+		assert.True(t, result.Buckets[3].Closing.Amount.Equal(decimal.NewFromInt(50)))
+		assert.True(t, result.Closing.Amount.Equal(decimal.NewFromInt(2050)))
+
+		require.Len(t, result.Shortfalls, 4)
+		assert.Equal(t, date(time.March, 2), result.Shortfalls[0].Date)
+		assert.True(t, result.Shortfalls[0].Gap.Amount.Equal(decimal.NewFromInt(250)))
+		assert.Equal(t, date(time.March, 2), result.LowestBalance().Start)
+
+		require.Len(t, publisher.events, 4)
+		assert.Equal(t, event.CashShortfallProjected, publisher.events[0].Type)
+		data := publisher.events[0].Data.(event.CashShortfallProjectedV1)
+		assert.Equal(t, "250", data.Gap)
+	})
+
+	t.Run("weekly", func(t *testing.T) {
+		publisher.events = nil
+		result, err := forecaster.Forecast(ctx, Request{
+			OpeningBalance: usd(1000),
+			Start:          date(time.March, 1),
+			Periods:        2,
+			Granularity:    Weekly,
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Buckets, 2)
+		assert.Len(t, result.Buckets[0].Flows, 5)
+		assert.True(t, result.Buckets[1].Outflows.Amount.Equal(decimal.NewFromInt(250)))
+		assert.Empty(t, result.Shortfalls)
+		assert.Empty(t, publisher.events)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := forecaster.Forecast(ctx, Request{OpeningBalance: usd(0), Start: date(time.March, 1), Granularity: Daily})
+		assert.ErrorIs(t, err, ErrInvalidRequest)
+
+		eur := NewForecaster(nil, Scheduled(loan))
+		_, err = eur.Forecast(ctx, Request{
+			OpeningBalance: money.Money{Amount: decimal.Zero, Currency: "EUR"},
+			Start:          date(time.March, 1),
+			Periods:        7,
+			Granularity:    Daily,
+		})
+		assert.ErrorIs(t, err, ErrCurrency)
+	})
+}
@@ -0,0 +1,112 @@
+package forecast
+
+import (
+	"context"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/invoice"
+	"github.com/johnayoung/finlib/pkg/payable"
+)
+
+// Receivables projects collection of open invoices on their due dates.
+// Overdue invoices are expected on the first day of the forecast.
+func Receivables(invoices []*invoice.Invoice) Source {
+	return SourceFunc(func(ctx context.Context, from, to time.Time) ([]Flow, error) {
+		var flows []Flow
+		for _, inv := range invoices {
+			if !inv.IsOpen() || inv.DueDate == nil {
+				continue
+			}
+			date := clamp(*inv.DueDate, from)
+			if date.After(to) {
+				continue
+			}
+			flows = append(flows, Flow{
+				Date:        date,
+				Amount:      inv.OpenBalance(),
+				Direction:   Inflow,
+				Source:      "receivable",
+				Reference:   inv.ID,
+				Description: "Invoice " + inv.Number,
+			})
+		}
+		return flows, nil
+	})
+}
+
+// Payables projects payment of open bills on their due dates. Overdue bills
+// are expected to be paid on the first day of the forecast.
+func Payables(bills []*payable.Bill) Source {
+	return SourceFunc(func(ctx context.Context, from, to time.Time) ([]Flow, error) {
+		var flows []Flow
+		for _, bill := range bills {
+			if !bill.IsOpen() {
+				continue
+			}
+			date := clamp(bill.DueDate, from)
+			if date.After(to) {
+				continue
+			}
+			flows = append(flows, Flow{
+				Date:        date,
+				Amount:      bill.OpenBalance(),
+				Direction:   Outflow,
+				Source:      "payable",
+				Reference:   bill.ID,
+				Description: "Bill " + bill.Number,
+			})
+		}
+		return flows, nil
+	})
+}
+
+// Recurring expands recurring items into their occurrences
+func Recurring(items ...RecurringItem) Source {
+	return SourceFunc(func(ctx context.Context, from, to time.Time) ([]Flow, error) {
+		var flows []Flow
+		for _, item := range items {
+			for date := item.Start; !date.After(to); date = item.next(date) {
+				if item.End != nil && date.After(*item.End) {
+					break
+				}
+				if date.Before(from) {
+					continue
+				}
+				flows = append(flows, Flow{
+					Date:        date,
+					Amount:      item.Amount,
+					Direction:   item.Direction,
+					Source:      "recurring",
+					Reference:   item.ID,
+					Description: item.Description,
+				})
+			}
+		}
+		return flows, nil
+	})
+}
+
+// Scheduled supplies one-off payments or receipts planned for known dates
+func Scheduled(flows ...Flow) Source {
+	return SourceFunc(func(ctx context.Context, from, to time.Time) ([]Flow, error) {
+		var due []Flow
+		for _, f := range flows {
+			if f.Date.Before(from) || f.Date.After(to) {
+				continue
+			}
+			if f.Source == "" {
+				f.Source = "scheduled"
+			}
+			due = append(due, f)
+		}
+		return due, nil
+	})
+}
+
+// clamp moves dates before the start of the forecast onto the start
+func clamp(date, from time.Time) time.Time {
+	if date.Before(from) {
+		return from
+	}
+	return date
+}
@@ -0,0 +1,156 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid forecast request")
+	ErrCurrency       = errors.New("currency mismatch")
+)
+
+// Granularity is the width of a forecast bucket
+type Granularity string
+
+const (
+	Daily  Granularity = "DAILY"
+	Weekly Granularity = "WEEKLY"
+)
+
+// Direction indicates whether a flow brings cash in or takes it out
+type Direction string
+
+const (
+	Inflow  Direction = "IN"
+	Outflow Direction = "OUT"
+)
+
+// Frequency is the interval between occurrences of a recurring item
+type Frequency string
+
+const (
+	EveryDay   Frequency = "DAILY"
+	EveryWeek  Frequency = "WEEKLY"
+	EveryMonth Frequency = "MONTHLY"
+)
+
+// Flow is a single projected movement of cash
+type Flow struct {
+	Date      time.Time   `json:"date"`
+	Amount    money.Money `json:"amount"`
+	Direction Direction   `json:"direction"`
+	// Where the flow came from (e.g., "receivable", "payable", "recurring")
+	Source string `json:"source"`
+	// Identifier of the underlying document or schedule
+	Reference   string `json:"reference,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Signed returns the flow amount, negative for outflows
+func (f Flow) Signed() decimal.Decimal {
+	if f.Direction == Outflow {
+		return f.Amount.Amount.Neg()
+	}
+	return f.Amount.Amount
+}
+
+// Source supplies the flows expected between two dates (inclusive)
+type Source interface {
+	Flows(ctx context.Context, from, to time.Time) ([]Flow, error)
+}
+
+// SourceFunc adapts a function to the Source interface
+type SourceFunc func(ctx context.Context, from, to time.Time) ([]Flow, error)
+
+// Flows implements Source
+func (f SourceFunc) Flows(ctx context.Context, from, to time.Time) ([]Flow, error) {
+	return f(ctx, from, to)
+}
+
+// RecurringItem describes a cash flow that repeats on a fixed schedule, such
+// as payroll or rent
+type RecurringItem struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Amount      money.Money `json:"amount"`
+	Direction   Direction   `json:"direction"`
+	Frequency   Frequency   `json:"frequency"`
+	// First occurrence
+	Start time.Time `json:"start"`
+	// Optional last date an occurrence may fall on
+	End *time.Time `json:"end,omitempty"`
+}
+
+// next returns the occurrence after t
+func (r RecurringItem) next(t time.Time) time.Time {
+	switch r.Frequency {
+	case EveryDay:
+		return t.AddDate(0, 0, 1)
+	case EveryWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+// Request describes the forecast to produce
+type Request struct {
+	// Cash on hand at the start of the forecast
+	OpeningBalance money.Money
+	// First day of the forecast
+	Start time.Time
+	// Number of buckets to project
+	Periods     int
+	Granularity Granularity
+	// Closing balances below this amount raise a shortfall alert
+	MinimumBalance money.Money
+}
+
+// Bucket is the projected cash position for a single day or week
+type Bucket struct {
+	Start    time.Time   `json:"start"`
+	End      time.Time   `json:"end"`
+	Opening  money.Money `json:"opening"`
+	Inflows  money.Money `json:"inflows"`
+	Outflows money.Money `json:"outflows"`
+	Closing  money.Money `json:"closing"`
+	Flows    []Flow      `json:"flows,omitempty"`
+}
+
+// Shortfall records a bucket whose closing balance falls below the minimum
+type Shortfall struct {
+	Date    time.Time   `json:"date"`
+	Closing money.Money `json:"closing"`
+	Minimum money.Money `json:"minimum"`
+	// Amount needed to bring the balance back to the minimum
+	Gap money.Money `json:"gap"`
+}
+
+// Forecast is the projected cash position over a horizon
+type Forecast struct {
+	Currency    string      `json:"currency"`
+	Granularity Granularity `json:"granularity"`
+	Start       time.Time   `json:"start"`
+	End         time.Time   `json:"end"`
+	Opening     money.Money `json:"opening"`
+	Closing     money.Money `json:"closing"`
+	Buckets     []Bucket    `json:"buckets"`
+	Shortfalls  []Shortfall `json:"shortfalls,omitempty"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
+// LowestBalance returns the bucket with the lowest closing balance
+func (f *Forecast) LowestBalance() *Bucket {
+	var lowest *Bucket
+	for i := range f.Buckets {
+		if lowest == nil || f.Buckets[i].Closing.Amount.LessThan(lowest.Closing.Amount) {
+			lowest = &f.Buckets[i]
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,229 @@
+package ingest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// camtDocument is the part of an ISO 20022 camt.053 document read here.
+// Elements are matched whatever the schema version's namespace.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	Number   string        `xml:"ElctrncSeqNb"`
+	IBAN     string        `xml:"Acct>Id>IBAN"`
+	OtherID  string        `xml:"Acct>Id>Othr>Id"`
+	Currency string        `xml:"Acct>Ccy"`
+	From     string        `xml:"FrToDt>FrDtTm"`
+	To       string        `xml:"FrToDt>ToDtTm"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type   string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount camtAmount `xml:"Amt"`
+	Side   string     `xml:"CdtDbtInd"`
+	Date   camtDate   `xml:"Dt"`
+}
+
+// camtDate holds a date given as either a date or a date and time
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtEntry struct {
+	Amount    camtAmount `xml:"Amt"`
+	Side      string     `xml:"CdtDbtInd"`
+	Reversal  bool       `xml:"RvslInd"`
+	Status    camtStatus `xml:"Sts"`
+	Booked    camtDate   `xml:"BookgDt"`
+	Value     camtDate   `xml:"ValDt"`
+	Reference string     `xml:"AcctSvcrRef"`
+	Details   []struct {
+		EndToEndID string   `xml:"Refs>EndToEndId"`
+		Debtor     string   `xml:"RltdPties>Dbtr>Nm"`
+		Creditor   string   `xml:"RltdPties>Cdtr>Nm"`
+		Remittance []string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+	Info string `xml:"AddtlNtryInf"`
+}
+
+// camtStatus is an entry status, a bare code in older versions and a Cd
+// element in newer ones
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+func (s camtStatus) code() string {
+	if s.Code != "" {
+		return s.Code
+	}
+	return strings.TrimSpace(s.Text)
+}
+
+// ParseCamt053 reads the booked entries of the statements in an ISO 20022
+// camt.053 bank-to-customer statement. Pending entries are skipped and
+// entries that cannot be read are left out and described in the report,
+// where their line is their position in the file.
+func ParseCamt053(r io.Reader) ([]*ParsedStatement, *ImportReport, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, nil, fmt.Errorf("%w: no statements", ErrInvalidStatement)
+	}
+
+	report := newReport("CAMT053")
+	statements := make([]*ParsedStatement, 0, len(doc.Statements))
+	position := 0
+	for _, s := range doc.Statements {
+		stmt := &ParsedStatement{
+			BankAccount: s.IBAN,
+			Reference:   s.ID,
+			Number:      s.Number,
+			Currency:    s.Currency,
+		}
+		if stmt.BankAccount == "" {
+			stmt.BankAccount = s.OtherID
+		}
+
+		for _, b := range s.Balances {
+			balance, err := camtMoney(b.Amount, b.Side, false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: statement %s: balance: %v", ErrInvalidStatement, s.ID, err)
+			}
+			date, _ := b.Date.parse()
+			switch b.Type {
+			case "OPBD", "PRCD":
+				// The opening balance wins over the previous closing one
+				if stmt.OpeningBalance == nil || b.Type == "OPBD" {
+					stmt.OpeningBalance = &balance
+					stmt.Start = date
+				}
+			case "CLBD":
+				stmt.ClosingBalance = &balance
+				stmt.End = date
+			}
+			if stmt.Currency == "" {
+				stmt.Currency = balance.Currency
+			}
+		}
+
+		for _, e := range s.Entries {
+			position++
+			if e.Status.code() != "" && e.Status.code() != "BOOK" {
+				continue
+			}
+			report.Rows++
+			row, err := camtRow(e)
+			if err != nil {
+				report.reject(position, err)
+				continue
+			}
+			row.Line = position
+			stmt.add(row)
+			report.accept(row)
+		}
+
+		if from, err := (camtDate{DateTime: s.From}).parse(); err == nil {
+			stmt.Start = from
+		}
+		if to, err := (camtDate{DateTime: s.To}).parse(); err == nil {
+			stmt.End = to
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, report, nil
+}
+
+// camtRow reads a booked entry. Credits are deposits; reversals count the
+// other way.
+func camtRow(e camtEntry) (Row, error) {
+	amount, err := camtMoney(e.Amount, e.Side, e.Reversal)
+	if err != nil {
+		return Row{}, err
+	}
+	date, err := e.Booked.parse()
+	if err != nil {
+		if date, err = e.Value.parse(); err != nil {
+			return Row{}, fmt.Errorf("entry %s has no booking date", e.Reference)
+		}
+	}
+
+	row := Row{Date: date, Amount: amount, Reference: e.Reference}
+	var description []string
+	for _, details := range e.Details {
+		if details.EndToEndID != "" && details.EndToEndID != "NOTPROVIDED" {
+			row.Reference = details.EndToEndID
+		}
+		party := details.Debtor
+		if amount.IsNegative() {
+			party = details.Creditor
+		}
+		if party != "" {
+			description = append(description, party)
+		}
+		description = append(description, details.Remittance...)
+	}
+	if len(description) == 0 && e.Info != "" {
+		description = append(description, e.Info)
+	}
+	row.Description = strings.Join(description, " ")
+	return row, nil
+}
+
+// camtMoney reads an amount signed by its credit or debit indicator
+func camtMoney(a camtAmount, side string, reversal bool) (money.Money, error) {
+	amount, err := decimal.NewFromString(strings.TrimSpace(a.Value))
+	if err != nil {
+		return money.Money{}, fmt.Errorf("invalid amount %q", a.Value)
+	}
+	switch side {
+	case "CRDT":
+	case "DBIT":
+		amount = amount.Neg()
+	default:
+		return money.Money{}, fmt.Errorf("invalid credit/debit indicator %q", side)
+	}
+	if reversal {
+		amount = amount.Neg()
+	}
+	value := money.Money{Amount: amount, Currency: a.Currency}
+	if err := value.Validate(); err != nil {
+		return money.Money{}, err
+	}
+	return value, nil
+}
+
+// parse returns the date, dropping any time of day
+func (d camtDate) parse() (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(d.DateTime))
+	if err != nil {
+		// Times without a zone are common in bank files
+		if t, err = time.Parse("2006-01-02T15:04:05", strings.TrimSpace(d.DateTime)); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
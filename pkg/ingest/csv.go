@@ -0,0 +1,493 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reconciliation"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidProfile is returned when a CSV profile cannot be used
+var ErrInvalidProfile = errors.New("invalid CSV profile")
+
+// SignConvention describes how the amount of a CSV row is signed. Amounts
+// are normalized so deposits into the profile's account are positive.
+type SignConvention string
+
+const (
+	// One amount column, positive for deposits
+	SignedAmount SignConvention = "SIGNED"
+	// One amount column, positive for withdrawals, as in card exports
+	InvertedAmount SignConvention = "INVERTED"
+	// Unsigned amounts in separate debit (withdrawal) and credit (deposit)
+	// columns, as in most bank exports
+	DebitCreditColumns SignConvention = "DEBIT_CREDIT"
+	// An unsigned amount and an indicator column naming the side
+	IndicatorColumn SignConvention = "INDICATOR"
+)
+
+// ColumnMapping names the CSV columns holding each field. Columns are
+// matched against the header case-insensitively or, in files without a
+// header, given as 1-based positions.
+type ColumnMapping struct {
+	Date        string `json:"date"`
+	Amount      string `json:"amount,omitempty"`
+	Debit       string `json:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty"`
+	Indicator   string `json:"indicator,omitempty"`
+	Description string `json:"description,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+	// Optional row identifier, used as the transaction ID
+	ID string `json:"id,omitempty"`
+	// Optional currency code; the profile currency is used otherwise
+	Currency string `json:"currency,omitempty"`
+	// Optional account the row is posted against instead of the profile's
+	// contra account
+	ContraAccount string `json:"contra_account,omitempty"`
+}
+
+// CSVProfile describes the layout of one kind of CSV export
+type CSVProfile struct {
+	Name    string        `json:"name"`
+	Columns ColumnMapping `json:"columns"`
+	// Whether the file lacks a header row, so columns are positions
+	NoHeader bool `json:"no_header,omitempty"`
+	// Rows before the header or first record, such as a bank's preamble
+	SkipRows int `json:"skip_rows,omitempty"`
+	// Field delimiter; defaults to a comma
+	Delimiter rune `json:"delimiter,omitempty"`
+	// Go layout of dates; defaults to 2006-01-02
+	DateFormat string `json:"date_format,omitempty"`
+	// Decimal and thousands separators of amounts; default to "." and none
+	DecimalSeparator   string         `json:"decimal_separator,omitempty"`
+	ThousandsSeparator string         `json:"thousands_separator,omitempty"`
+	Sign               SignConvention `json:"sign"`
+	// Indicator values, matched case-insensitively, marking withdrawals;
+	// other values mark deposits. Defaults to DR and D.
+	DebitIndicators []string `json:"debit_indicators,omitempty"`
+	// Currency of rows without a currency column
+	Currency string `json:"currency,omitempty"`
+	// Account the rows move money in and out of, such as a bank account,
+	// and the account each movement is posted against by default
+	AccountID       string `json:"account_id,omitempty"`
+	ContraAccountID string `json:"contra_account_id,omitempty"`
+	// Prefix of generated transaction IDs; defaults to the profile name
+	IDPrefix string `json:"id_prefix,omitempty"`
+}
+
+// withDefaults fills unset fields with their defaults
+func (p CSVProfile) withDefaults() CSVProfile {
+	if p.Delimiter == 0 {
+		p.Delimiter = ','
+	}
+	if p.DateFormat == "" {
+		p.DateFormat = "2006-01-02"
+	}
+	if p.DecimalSeparator == "" {
+		p.DecimalSeparator = "."
+	}
+	if p.Sign == "" {
+		p.Sign = SignedAmount
+	}
+	if len(p.DebitIndicators) == 0 {
+		p.DebitIndicators = []string{"DR", "D"}
+	}
+	if p.IDPrefix == "" {
+		p.IDPrefix = p.Name
+	}
+	return p
+}
+
+// Validate checks that the profile maps the columns its sign convention
+// needs
+func (p CSVProfile) Validate() error {
+	p = p.withDefaults()
+	if p.Columns.Date == "" {
+		return fmt.Errorf("%w: date column is required", ErrInvalidProfile)
+	}
+	switch p.Sign {
+	case SignedAmount, InvertedAmount:
+		if p.Columns.Amount == "" {
+			return fmt.Errorf("%w: amount column is required", ErrInvalidProfile)
+		}
+	case DebitCreditColumns:
+		if p.Columns.Debit == "" || p.Columns.Credit == "" {
+			return fmt.Errorf("%w: debit and credit columns are required", ErrInvalidProfile)
+		}
+	case IndicatorColumn:
+		if p.Columns.Amount == "" || p.Columns.Indicator == "" {
+			return fmt.Errorf("%w: amount and indicator columns are required", ErrInvalidProfile)
+		}
+	default:
+		return fmt.Errorf("%w: unknown sign convention %q", ErrInvalidProfile, p.Sign)
+	}
+	if p.Currency == "" && p.Columns.Currency == "" {
+		return fmt.Errorf("%w: a currency or currency column is required", ErrInvalidProfile)
+	}
+	if p.DecimalSeparator == p.ThousandsSeparator {
+		return fmt.Errorf("%w: decimal and thousands separators must differ", ErrInvalidProfile)
+	}
+	return nil
+}
+
+// Row is a CSV record read through a profile
+type Row struct {
+	// Line of the record in the file
+	Line int       `json:"line"`
+	ID   string    `json:"id,omitempty"`
+	Date time.Time `json:"date"`
+	// Signed amount, positive for deposits
+	Amount        money.Money `json:"amount"`
+	Description   string      `json:"description,omitempty"`
+	Reference     string      `json:"reference,omitempty"`
+	ContraAccount string      `json:"contra_account,omitempty"`
+}
+
+// RowError records why a CSV record was rejected
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportReport describes the outcome of reading a CSV file
+type ImportReport struct {
+	Profile string `json:"profile"`
+	// Records read, and how many were accepted and rejected
+	Rows     int `json:"rows"`
+	Valid    int `json:"valid"`
+	Rejected int `json:"rejected"`
+	// Rejected records with their reasons
+	Errors []RowError `json:"errors,omitempty"`
+	// Net signed amount of the accepted records per currency
+	Totals map[string]decimal.Decimal `json:"totals"`
+}
+
+// reject records a rejected record
+func (r *ImportReport) reject(line int, err error) {
+	r.Rejected++
+	r.Errors = append(r.Errors, RowError{Line: line, Message: err.Error()})
+}
+
+// accept records an accepted record
+func (r *ImportReport) accept(row Row) {
+	r.Valid++
+	r.Totals[row.Amount.Currency] = r.Totals[row.Amount.Currency].Add(row.Amount.Amount)
+}
+
+// unaccept rejects a record accepted earlier
+func (r *ImportReport) unaccept(row Row, err error) {
+	r.Valid--
+	r.Totals[row.Amount.Currency] = r.Totals[row.Amount.Currency].Sub(row.Amount.Amount)
+	r.reject(row.Line, err)
+}
+
+// CSVImporter reads CSV exports through a mapping profile
+type CSVImporter struct {
+	profile CSVProfile
+}
+
+// NewCSVImporter creates an importer for a profile
+func NewCSVImporter(profile CSVProfile) (*CSVImporter, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return &CSVImporter{profile: profile.withDefaults()}, nil
+}
+
+// Rows reads the records of a CSV file. Records that cannot be read are
+// left out and described in the report; an error is only returned when the
+// file itself cannot be read.
+func (i *CSVImporter) Rows(r io.Reader) ([]Row, *ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = i.profile.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	report := newReport(i.profile.Name)
+	// line is the file line of the last record read
+	line := 0
+	next := func() ([]string, error) {
+		record, err := reader.Read()
+		var parseErr *csv.ParseError
+		switch {
+		case err == nil:
+			line, _ = reader.FieldPos(0)
+		case errors.As(err, &parseErr):
+			line = parseErr.Line
+		}
+		return record, err
+	}
+
+	for skipped := 0; skipped < i.profile.SkipRows; skipped++ {
+		if _, err := next(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+	}
+	columns := make(map[string]int)
+	if !i.profile.NoHeader {
+		header, err := next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		for index, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = index
+		}
+	}
+	index, err := i.columnIndexes(columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("failed to read line %d: %w", line, err)
+			}
+			report.Rows++
+			report.reject(line, err)
+			continue
+		}
+		if blank(record) {
+			continue
+		}
+		report.Rows++
+		row, err := i.row(record, index)
+		if err != nil {
+			report.reject(line, err)
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+		report.accept(row)
+	}
+	return rows, report, nil
+}
+
+// Transactions reads a CSV file as draft transactions moving each row's
+// amount between the profile account and the row's contra account
+func (i *CSVImporter) Transactions(r io.Reader) ([]*transaction.Transaction, *ImportReport, error) {
+	_, txs, report, err := i.transactions(r)
+	return txs, report, err
+}
+
+// StatementLines reads a CSV file as bank statement lines to reconcile
+func (i *CSVImporter) StatementLines(r io.Reader) ([]reconciliation.StatementLine, *ImportReport, error) {
+	rows, report, err := i.Rows(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statementLines(rows), report, nil
+}
+
+// DryRun reads a CSV file as transactions and validates them with the
+// processor without posting anything. Transactions failing validation are
+// reported as rejected rows.
+func (i *CSVImporter) DryRun(ctx context.Context, r io.Reader, processor transaction.TransactionProcessor) (*ImportReport, error) {
+	rows, txs, report, err := i.transactions(r)
+	if err != nil {
+		return nil, err
+	}
+	for n, tx := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := processor.ValidateTransaction(ctx, tx)
+		switch {
+		case err != nil:
+			report.unaccept(rows[n], fmt.Errorf("failed to validate transaction: %w", err))
+		case !result.Valid:
+			report.unaccept(rows[n], fmt.Errorf("transaction validation failed: %v", result.Errors))
+		}
+	}
+	return report, nil
+}
+
+// transactions reads a CSV file as draft transactions, returning them with
+// the rows they were built from
+func (i *CSVImporter) transactions(r io.Reader) ([]Row, []*transaction.Transaction, *ImportReport, error) {
+	if i.profile.AccountID == "" {
+		return nil, nil, nil, fmt.Errorf("%w: an account is required to build transactions", ErrInvalidProfile)
+	}
+	rows, report, err := i.Rows(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	built := rows[:0]
+	txs := make([]*transaction.Transaction, 0, len(rows))
+	for _, row := range rows {
+		contra := row.ContraAccount
+		if contra == "" {
+			contra = i.profile.ContraAccountID
+		}
+		if contra == "" {
+			report.unaccept(row, errors.New("no contra account"))
+			continue
+		}
+		txs = append(txs, draftTransaction(row, i.profile.AccountID, contra, i.profile.IDPrefix))
+		built = append(built, row)
+	}
+	return built, txs, report, nil
+}
+
+// columnIndexes resolves the profile's columns against a header, or as
+// positions when there is none
+func (i *CSVImporter) columnIndexes(header map[string]int) (map[string]int, error) {
+	mapping := i.profile.Columns
+	named := map[string]string{
+		"date":           mapping.Date,
+		"amount":         mapping.Amount,
+		"debit":          mapping.Debit,
+		"credit":         mapping.Credit,
+		"indicator":      mapping.Indicator,
+		"description":    mapping.Description,
+		"reference":      mapping.Reference,
+		"id":             mapping.ID,
+		"currency":       mapping.Currency,
+		"contra_account": mapping.ContraAccount,
+	}
+
+	index := make(map[string]int, len(named))
+	for field, column := range named {
+		if column == "" {
+			continue
+		}
+		if i.profile.NoHeader {
+			position, err := strconv.Atoi(column)
+			if err != nil || position < 1 {
+				return nil, fmt.Errorf("%w: %s column %q is not a position", ErrInvalidProfile, field, column)
+			}
+			index[field] = position - 1
+			continue
+		}
+		position, ok := header[strings.ToLower(strings.TrimSpace(column))]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidProfile, column)
+		}
+		index[field] = position
+	}
+	return index, nil
+}
+
+// row reads a record through the profile
+func (i *CSVImporter) row(record []string, index map[string]int) (Row, error) {
+	field := func(name string) string {
+		position, ok := index[name]
+		if !ok || position >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[position])
+	}
+
+	date, err := time.Parse(i.profile.DateFormat, field("date"))
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid date %q", field("date"))
+	}
+
+	var amount decimal.Decimal
+	switch i.profile.Sign {
+	case SignedAmount, InvertedAmount, IndicatorColumn:
+		if amount, err = i.number(field("amount")); err != nil {
+			return Row{}, err
+		}
+		switch i.profile.Sign {
+		case InvertedAmount:
+			amount = amount.Neg()
+		case IndicatorColumn:
+			amount = amount.Abs()
+			indicator := field("indicator")
+			for _, debit := range i.profile.DebitIndicators {
+				if strings.EqualFold(indicator, debit) {
+					amount = amount.Neg()
+					break
+				}
+			}
+		}
+	case DebitCreditColumns:
+		debit, credit := field("debit"), field("credit")
+		var withdrawn, deposited decimal.Decimal
+		if debit != "" {
+			if withdrawn, err = i.number(debit); err != nil {
+				return Row{}, err
+			}
+		}
+		if credit != "" {
+			if deposited, err = i.number(credit); err != nil {
+				return Row{}, err
+			}
+		}
+		amount = deposited.Abs().Sub(withdrawn.Abs())
+	}
+	if amount.IsZero() {
+		return Row{}, errors.New("amount is zero or missing")
+	}
+
+	currency := strings.ToUpper(field("currency"))
+	if currency == "" {
+		currency = i.profile.Currency
+	}
+	value := money.Money{Amount: amount, Currency: currency}
+	if err := value.Validate(); err != nil {
+		return Row{}, err
+	}
+
+	return Row{
+		ID:            field("id"),
+		Date:          date,
+		Amount:        value,
+		Description:   field("description"),
+		Reference:     field("reference"),
+		ContraAccount: field("contra_account"),
+	}, nil
+}
+
+// number parses an amount in the profile's number format. Negative amounts
+// may carry a leading or trailing minus or be wrapped in parentheses.
+func (i *CSVImporter) number(s string) (decimal.Decimal, error) {
+	text := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	negative := false
+	switch {
+	case strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")"):
+		negative, text = true, text[1:len(text)-1]
+	case strings.HasSuffix(text, "-"):
+		negative, text = true, strings.TrimSuffix(text, "-")
+	}
+	if i.profile.ThousandsSeparator != "" {
+		text = strings.ReplaceAll(text, i.profile.ThousandsSeparator, "")
+	}
+	text = strings.ReplaceAll(text, i.profile.DecimalSeparator, ".")
+
+	amount, err := decimal.NewFromString(text)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+	return amount, nil
+}
+
+// blank reports whether every field of a record is empty
+func blank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,415 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProcessor validates with the basic validator and counts postings
+type countingProcessor struct {
+	transaction.TransactionProcessor
+	validator transaction.BasicValidator
+	mu        sync.Mutex
+	posted    map[string]bool
+	batches   int
+	failOn    string
+}
+
+func newCountingProcessor() *countingProcessor {
+	return &countingProcessor{posted: make(map[string]bool)}
+}
+
+func (p *countingProcessor) ValidateTransaction(ctx context.Context, tx *transaction.Transaction) (*transaction.ValidationResult, error) {
+	return p.validator.Validate(ctx, tx)
+}
+
+func (p *countingProcessor) ProcessTransactionBatch(ctx context.Context, txs []*transaction.Transaction) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tx := range txs {
+		if tx.ID == p.failOn {
+			return errors.New("store unavailable")
+		}
+	}
+	for _, tx := range txs {
+		tx.Status = transaction.Posted
+		p.posted[tx.ID] = true
+	}
+	p.batches++
+	return nil
+}
+
+func journal(id string, amount int64) *transaction.Transaction {
+	usd := money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	return &transaction.Transaction{
+		ID:     id,
+		Type:   transaction.Journal,
+		Status: transaction.Draft,
+		Entries: []transaction.Entry{
+			{AccountID: "1000", Amount: usd, Type: transaction.Debit},
+			{AccountID: "4000", Amount: usd, Type: transaction.Credit},
+		},
+	}
+}
+
+func TestPipelineChannel(t *testing.T) {
+	processor := newCountingProcessor()
+	pipeline := NewPipeline(processor, Config{BatchSize: 10, Workers: 4, QueueSize: 1})
+
+	ch := make(chan *transaction.Transaction)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 1000; i++ {
+			amount := int64(100)
+			if i%100 == 0 {
+				amount = 0 // rejected by validation
+			}
+			ch <- journal(fmt.Sprintf("TX%04d", i), amount)
+		}
+	}()
+
+	stats, err := pipeline.Run(context.Background(), FromChannel(ch))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), stats.Received)
+	assert.Equal(t, int64(990), stats.Posted)
+	assert.Equal(t, int64(10), stats.Rejected)
+	assert.Len(t, stats.Errors, 10)
+	assert.Len(t, processor.posted, 990)
+	assert.Equal(t, int64(processor.batches), stats.Batches)
+	assert.False(t, stats.Completed.Before(stats.Started))
+}
+
+func TestPipelineReader(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id":"A","type":"JOURNAL","entries":[{"account_id":"1000","amount":{"amount":"5","currency":"USD"},"type":"DEBIT"},{"account_id":"4000","amount":{"amount":"5","currency":"USD"},"type":"CREDIT"}]}`,
+		`{"id":"B","type":"JOURNAL","entries":[{"account_id":"1000","amount":{"amount":"7","currency":"USD"},"type":"DEBIT"},{"account_id":"4000","amount":{"amount":"7","currency":"USD"},"type":"CREDIT"}]}`,
+	}, "\n")
+
+	t.Run("posts decoded transactions", func(t *testing.T) {
+		processor := newCountingProcessor()
+		stats, err := NewPipeline(processor, Config{}).Run(context.Background(), FromReader(strings.NewReader(input)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.Posted)
+		assert.True(t, processor.posted["A"] && processor.posted["B"])
+	})
+
+	t.Run("batch failures are counted", func(t *testing.T) {
+		processor := newCountingProcessor()
+		processor.failOn = "B"
+		stats, err := NewPipeline(processor, Config{BatchSize: 1, Workers: 1}).Run(context.Background(), FromReader(strings.NewReader(input)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Posted)
+		assert.Equal(t, int64(1), stats.Failed)
+		require.Len(t, stats.Errors, 1)
+		assert.Equal(t, "B", stats.Errors[0].TransactionID)
+	})
+
+	t.Run("malformed input stops the run", func(t *testing.T) {
+		stats, err := NewPipeline(newCountingProcessor(), Config{}).Run(context.Background(), FromReader(strings.NewReader(input+"\n{bad")))
+		assert.Error(t, err)
+		assert.Equal(t, int64(2), stats.Received)
+	})
+}
+
+const bankExport = `Account statement 1010;;;;
+Date;Text;Withdrawal;Deposit;Category
+01.03.2024;Customer ACME;;1.250,00;4000
+02.03.2024;Rent March;900,00;;6100
+31.02.2024;Bad date;10,00;;6100
+03.03.2024;Nothing;;;6100
+04.03.2024;Unknown payee;12,50;;
+`
+
+func TestCSVImporter(t *testing.T) {
+	profile := CSVProfile{
+		Name:               "BANK",
+		Columns:            ColumnMapping{Date: "date", Description: "text", Debit: "withdrawal", Credit: "deposit", ContraAccount: "category"},
+		SkipRows:           1,
+		Delimiter:          ';',
+		DateFormat:         "02.01.2006",
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		Sign:               DebitCreditColumns,
+		Currency:           "EUR",
+		AccountID:          "1010",
+	}
+	importer, err := NewCSVImporter(profile)
+	require.NoError(t, err)
+
+	t.Run("transactions", func(t *testing.T) {
+		txs, report, err := importer.Transactions(strings.NewReader(bankExport))
+		require.NoError(t, err)
+		require.Len(t, txs, 2)
+
+		deposit := txs[0]
+		assert.Equal(t, "BANK-3", deposit.ID)
+		assert.Equal(t, transaction.Draft, deposit.Status)
+		assert.Equal(t, "1010", deposit.Entries[0].AccountID)
+		assert.Equal(t, transaction.Debit, deposit.Entries[0].Type)
+		assert.Equal(t, "4000", deposit.Entries[1].AccountID)
+		assert.True(t, deposit.Entries[0].Amount.Amount.Equal(decimal.NewFromInt(1250)))
+
+		rent := txs[1]
+		assert.Equal(t, transaction.Credit, rent.Entries[0].Type)
+		assert.Equal(t, "6100", rent.Entries[1].AccountID)
+
+		assert.Equal(t, 5, report.Rows)
+		assert.Equal(t, 2, report.Valid)
+		assert.Equal(t, 3, report.Rejected)
+		lines := make([]int, 0, len(report.Errors))
+		for _, e := range report.Errors {
+			lines = append(lines, e.Line)
+		}
+		assert.Equal(t, []int{5, 6, 7}, lines)
+		assert.True(t, report.Totals["EUR"].Equal(decimal.NewFromInt(350)))
+	})
+
+	t.Run("dry run validates without posting", func(t *testing.T) {
+		processor := newCountingProcessor()
+		report, err := importer.DryRun(context.Background(), strings.NewReader(bankExport), processor)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Valid)
+		assert.Empty(t, processor.posted)
+	})
+
+	t.Run("statement lines without a header", func(t *testing.T) {
+		cards, err := NewCSVImporter(CSVProfile{
+			Name:     "CARD",
+			Columns:  ColumnMapping{Date: "1", Amount: "2", Indicator: "3", Description: "4"},
+			NoHeader: true,
+			Sign:     IndicatorColumn,
+			Currency: "USD",
+		})
+		require.NoError(t, err)
+
+		lines, report, err := cards.StatementLines(strings.NewReader("2024-03-01,19.99,DR,Coffee\n2024-03-02,5.00,CR,Refund\n"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Valid)
+		require.Len(t, lines, 2)
+		assert.Equal(t, "-19.99", lines[0].Amount.Amount.String())
+		assert.Equal(t, "5", lines[1].Amount.Amount.String())
+		assert.Equal(t, "Coffee", lines[0].Description)
+	})
+
+	t.Run("invalid profiles", func(t *testing.T) {
+		_, err := NewCSVImporter(CSVProfile{Columns: ColumnMapping{Date: "date"}, Sign: DebitCreditColumns, Currency: "USD"})
+		assert.ErrorIs(t, err, ErrInvalidProfile)
+		_, err = NewCSVImporter(CSVProfile{Columns: ColumnMapping{Date: "date", Amount: "amount"}})
+		assert.ErrorIs(t, err, ErrInvalidProfile)
+
+		signed, err := NewCSVImporter(CSVProfile{Columns: ColumnMapping{Date: "date", Amount: "amount"}, Currency: "USD"})
+		require.NoError(t, err)
+		_, _, err = signed.Rows(strings.NewReader("when,amount\n"))
+		assert.ErrorIs(t, err, ErrInvalidProfile)
+		_, _, err = signed.Transactions(strings.NewReader("date,amount\n"))
+		assert.ErrorIs(t, err, ErrInvalidProfile)
+	})
+}
+
+const qifExport = `!Type:Bank
+D03/01'24
+T1,250.00
+PCustomer ACME
+N1001
+^
+D3/ 2/2024
+T-900.00
+PLandlord
+MRent March
+SRent
+$-900.00
+^
+D13/45/2024
+T-5.00
+PBad date
+^
+!Type:Cat
+NGroceries
+^
+`
+
+func TestParseQIF(t *testing.T) {
+	stmt, report, err := ParseQIF(strings.NewReader(qifExport), QIFOptions{Currency: "USD"})
+	require.NoError(t, err)
+	require.Len(t, stmt.Rows, 2)
+	assert.Equal(t, 3, report.Rows)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 14, report.Errors[0].Line)
+
+	deposit, rent := stmt.Rows[0], stmt.Rows[1]
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), deposit.Date)
+	assert.Equal(t, "1250", deposit.Amount.Amount.String())
+	assert.Equal(t, "Customer ACME", deposit.Description)
+	assert.Equal(t, "1001", deposit.Reference)
+	assert.Equal(t, "-900", rent.Amount.Amount.String())
+	assert.Equal(t, "Landlord", rent.Description)
+
+	// Without bank balances the closing balance is the sum of the rows
+	closing := stmt.Statement("1010").ClosingBalance
+	assert.Equal(t, "350", closing.Amount.String())
+
+	txs := stmt.Transactions("1010", "9999", "QIF")
+	require.Len(t, txs, 2)
+	assert.Equal(t, "QIF-2", txs[0].ID)
+	assert.Equal(t, transaction.Credit, txs[1].Entries[0].Type)
+
+	dayFirst, _, err := ParseQIF(strings.NewReader("!Type:CCard\nD31.12.23\nT-4.50\n^\n"), QIFOptions{Currency: "EUR", DayFirst: true})
+	require.NoError(t, err)
+	require.Len(t, dayFirst.Rows, 1)
+	assert.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), dayFirst.Rows[0].Date)
+
+	_, _, err = ParseQIF(strings.NewReader(qifExport), QIFOptions{})
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+const mt940Export = `{1:F01BANKDEFFAXXX0000000000}{2:O9400000000000BANKDEFFXXXX00000000000000000000N}{4:
+:20:STMT240331
+:25:10020030/1234567
+:28C:00001/001
+:60F:C240301EUR1000,00
+:61:2403010301D900,00NTRFNONREF//B4C01
+:86:?00DAUERAUFTRAG?20Rent March?32LANDLORD GMBH
+:61:240302C1250,00NMSCINV-2024-17
+:86:Customer ACME payment for invoice 2024-
+17
+:61:240303X5,00NCHG
+:62F:C240331EUR1350,00
+-}
+`
+
+func TestParseMT940(t *testing.T) {
+	statements, report, err := ParseMT940(strings.NewReader(mt940Export))
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, 3, report.Rows)
+	assert.Equal(t, 1, report.Rejected)
+
+	stmt := statements[0]
+	assert.Equal(t, "STMT240331", stmt.Reference)
+	assert.Equal(t, "10020030/1234567", stmt.BankAccount)
+	assert.Equal(t, "EUR", stmt.Currency)
+	assert.Equal(t, "1000", stmt.OpeningBalance.Amount.String())
+	assert.Equal(t, "1350", stmt.ClosingBalance.Amount.String())
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), stmt.End)
+
+	require.Len(t, stmt.Rows, 2)
+	rent, deposit := stmt.Rows[0], stmt.Rows[1]
+	assert.Equal(t, "-900", rent.Amount.Amount.String())
+	assert.Equal(t, "", rent.Reference)
+	assert.Equal(t, "DAUERAUFTRAG Rent March LANDLORD GMBH", rent.Description)
+	assert.Equal(t, "1250", deposit.Amount.Amount.String())
+	assert.Equal(t, "INV-2024-17", deposit.Reference)
+	assert.Equal(t, "Customer ACME payment for invoice 2024-17", deposit.Description)
+
+	lines := stmt.StatementLines()
+	require.Len(t, lines, 2)
+	assert.Equal(t, "EUR", lines[0].Amount.Currency)
+	assert.Equal(t, "1350", stmt.Statement("1010").ClosingBalance.Amount.String())
+
+	_, _, err = ParseMT940(strings.NewReader(":20:X\n:61:240302C1,00NMSCREF\n"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
+
+const camt053Export = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT-2024-04</Id>
+      <ElctrncSeqNb>4</ElctrncSeqNb>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-04-01</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1350.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-04-30</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <Amt Ccy="EUR">900.00</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-04-02</Dt></BookgDt>
+        <AcctSvcrRef>BANK-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+          <RltdPties><Cdtr><Nm>Landlord GmbH</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Rent April</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">1250.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2024-04-05T10:30:00+02:00</DtTm></BookgDt>
+        <AcctSvcrRef>BANK-2</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>INV-2024-17</EndToEndId></Refs>
+          <RltdPties><Dbtr><Nm>ACME</Nm></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Invoice 2024-17</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">50.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2024-04-30</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">abc</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-04-20</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCamt053(t *testing.T) {
+	statements, report, err := ParseCamt053(strings.NewReader(camt053Export))
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	assert.Equal(t, 3, report.Rows)
+	assert.Equal(t, 1, report.Rejected)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 4, report.Errors[0].Line)
+
+	stmt := statements[0]
+	assert.Equal(t, "STMT-2024-04", stmt.Reference)
+	assert.Equal(t, "4", stmt.Number)
+	assert.Equal(t, "DE89370400440532013000", stmt.BankAccount)
+	assert.Equal(t, "EUR", stmt.Currency)
+	assert.Equal(t, "1000", stmt.OpeningBalance.Amount.String())
+	assert.Equal(t, "1350", stmt.ClosingBalance.Amount.String())
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), stmt.Start)
+	assert.Equal(t, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), stmt.End)
+
+	require.Len(t, stmt.Rows, 2)
+	rent, deposit := stmt.Rows[0], stmt.Rows[1]
+	assert.Equal(t, "-900", rent.Amount.Amount.String())
+	assert.Equal(t, "BANK-1", rent.Reference)
+	assert.Equal(t, "Landlord GmbH Rent April", rent.Description)
+	assert.Equal(t, "1250", deposit.Amount.Amount.String())
+	assert.Equal(t, "INV-2024-17", deposit.Reference)
+	assert.Equal(t, "ACME Invoice 2024-17", deposit.Description)
+	assert.Equal(t, time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC), deposit.Date)
+
+	lines := stmt.StatementLines()
+	require.Len(t, lines, 2)
+	assert.Equal(t, "INV-2024-17", lines[1].Reference)
+
+	_, _, err = ParseCamt053(strings.NewReader("<Document></Document>"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+	_, _, err = ParseCamt053(strings.NewReader("not xml"))
+	assert.ErrorIs(t, err, ErrInvalidStatement)
+}
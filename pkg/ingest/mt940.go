@@ -0,0 +1,224 @@
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// mt940Field is a tagged field of an MT940 message with its continuation
+// lines
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+var (
+	// :61: value date, optional entry date, mark, optional third currency
+	// letter, amount, transaction type, customer and bank references
+	mt940EntryPattern = regexp.MustCompile(`^(\d{6})(\d{4})?(C|D|RC|RD)[A-Z]?(\d+,\d*)[NSF][A-Z0-9]{3}([^/\n]*)(?://([^\n]*))?`)
+	// :60F:, :62F: and their intermediate forms: mark, date, currency, amount
+	mt940BalancePattern = regexp.MustCompile(`^(C|D)(\d{6})([A-Z]{3})(\d+,\d*)`)
+	// Structured :86: subfield codes such as ?20
+	mt940SubfieldPattern = regexp.MustCompile(`\?\d{2}`)
+)
+
+// ParseMT940 reads the statements of a SWIFT MT940 file. Entries that
+// cannot be read are left out and described in the report; statements
+// without an opening balance are an error.
+func ParseMT940(r io.Reader) ([]*ParsedStatement, *ImportReport, error) {
+	fields, err := mt940Fields(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := newReport("MT940")
+	var statements []*ParsedStatement
+	var current *ParsedStatement
+	var last *Row
+	for _, field := range fields {
+		if field.tag == "20" {
+			current = &ParsedStatement{Reference: field.value}
+			statements = append(statements, current)
+			last = nil
+			continue
+		}
+		if current == nil {
+			return nil, nil, fmt.Errorf("%w: line %d: field :%s: before :20:", ErrInvalidStatement, field.line, field.tag)
+		}
+
+		switch field.tag {
+		case "25":
+			current.BankAccount = field.value
+		case "28C", "28":
+			current.Number = field.value
+		case "60F", "60M":
+			balance, date, err := mt940Balance(field.value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: line %d: %v", ErrInvalidStatement, field.line, err)
+			}
+			if current.OpeningBalance == nil {
+				current.OpeningBalance = &balance
+				current.Currency = balance.Currency
+				current.Start = date
+			}
+		case "62F", "62M":
+			balance, date, err := mt940Balance(field.value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: line %d: %v", ErrInvalidStatement, field.line, err)
+			}
+			current.ClosingBalance = &balance
+			if date.After(current.End) {
+				current.End = date
+			}
+		case "61":
+			last = nil
+			report.Rows++
+			if current.Currency == "" {
+				return nil, nil, fmt.Errorf("%w: line %d: entry before the opening balance", ErrInvalidStatement, field.line)
+			}
+			row, err := mt940Row(field.value, current.Currency)
+			if err != nil {
+				report.reject(field.line, err)
+				continue
+			}
+			row.Line = field.line
+			current.add(row)
+			report.accept(row)
+			last = &current.Rows[len(current.Rows)-1]
+		case "86":
+			// Information to the account owner describes the preceding entry
+			if last != nil {
+				last.Description = mt940Description(field.value)
+			}
+		}
+	}
+	for _, stmt := range statements {
+		if stmt.OpeningBalance == nil {
+			return nil, nil, fmt.Errorf("%w: statement %s has no opening balance", ErrInvalidStatement, stmt.Reference)
+		}
+	}
+	return statements, report, nil
+}
+
+// mt940Fields splits an MT940 file into its tagged fields, dropping the
+// SWIFT block headers and message terminators
+func mt940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r ")
+		if i := strings.Index(text, "{4:"); i >= 0 {
+			text = text[i+3:]
+		}
+		switch {
+		case text == "" || text == "-" || text == "-}" || strings.HasPrefix(text, "{"):
+			continue
+		case strings.HasPrefix(text, ":"):
+			tag, value, ok := strings.Cut(text[1:], ":")
+			if !ok {
+				return nil, fmt.Errorf("%w: line %d: malformed field %q", ErrInvalidStatement, line, text)
+			}
+			fields = append(fields, mt940Field{tag: tag, value: value, line: line})
+		case len(fields) > 0:
+			fields[len(fields)-1].value += "\n" + text
+		default:
+			return nil, fmt.Errorf("%w: line %d: text before the first field", ErrInvalidStatement, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read MT940 file: %w", err)
+	}
+	return fields, nil
+}
+
+// mt940Balance reads a balance field
+func mt940Balance(value string) (money.Money, time.Time, error) {
+	m := mt940BalancePattern.FindStringSubmatch(value)
+	if m == nil {
+		return money.Money{}, time.Time{}, fmt.Errorf("malformed balance %q", value)
+	}
+	date, err := mt940Date(m[2])
+	if err != nil {
+		return money.Money{}, time.Time{}, err
+	}
+	amount, err := mt940Amount(m[4])
+	if err != nil {
+		return money.Money{}, time.Time{}, err
+	}
+	if m[1] == "D" {
+		amount = amount.Neg()
+	}
+	return money.Money{Amount: amount, Currency: m[3]}, date, nil
+}
+
+// mt940Row reads a statement line field. Credits are deposits; reversals
+// of credits and debits count the other way.
+func mt940Row(field, currency string) (Row, error) {
+	m := mt940EntryPattern.FindStringSubmatch(field)
+	if m == nil {
+		return Row{}, fmt.Errorf("malformed entry %q", strings.SplitN(field, "\n", 2)[0])
+	}
+	date, err := mt940Date(m[1])
+	if err != nil {
+		return Row{}, err
+	}
+	amount, err := mt940Amount(m[4])
+	if err != nil {
+		return Row{}, err
+	}
+	if m[3] == "D" || m[3] == "RC" {
+		amount = amount.Neg()
+	}
+	value := money.Money{Amount: amount, Currency: currency}
+	if err := value.Validate(); err != nil {
+		return Row{}, err
+	}
+
+	reference := strings.TrimSpace(m[5])
+	if reference == "NONREF" {
+		reference = ""
+	}
+	return Row{Date: date, Amount: value, Reference: reference}, nil
+}
+
+// mt940Date reads a YYMMDD date
+func mt940Date(s string) (time.Time, error) {
+	date, err := time.Parse("060102", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return date, nil
+}
+
+// mt940Amount reads an amount with a decimal comma
+func mt940Amount(s string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.Replace(s, ",", ".", 1))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
+
+// mt940Description flattens a :86: field, dropping structured subfield
+// codes and line breaks
+func mt940Description(value string) string {
+	value = strings.ReplaceAll(value, "\n", "")
+	if !strings.HasPrefix(value, "?") {
+		return strings.TrimSpace(value)
+	}
+	var parts []string
+	for _, part := range mt940SubfieldPattern.Split(value, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,180 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Pipeline batches, validates and posts transactions read from a source.
+// Batches are validated concurrently, so transactions are not necessarily
+// posted in the order they were read.
+type Pipeline struct {
+	processor transaction.TransactionProcessor
+	config    Config
+}
+
+// NewPipeline creates a pipeline posting through the given processor
+func NewPipeline(processor transaction.TransactionProcessor, config Config) *Pipeline {
+	return &Pipeline{
+		processor: processor,
+		config:    config.withDefaults(),
+	}
+}
+
+// run holds the state of a single pipeline run
+type run struct {
+	mu        sync.Mutex
+	stats     Stats
+	maxErrors int
+}
+
+func (r *run) add(counter *int64, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*counter += int64(n)
+}
+
+func (r *run) recordError(txID string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.stats.Errors) < r.maxErrors {
+		r.stats.Errors = append(r.stats.Errors, ItemError{TransactionID: txID, Message: err.Error()})
+	}
+}
+
+// Run ingests every transaction from the source. Validation and posting
+// failures are reported in the returned stats; an error is only returned
+// when the source fails or the context is cancelled, in which case the stats
+// describe the work completed so far.
+func (p *Pipeline) Run(ctx context.Context, source Source) (*Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &run{maxErrors: p.config.MaxErrors}
+	r.stats.Started = time.Now()
+
+	batches := make(chan []*transaction.Transaction, p.config.QueueSize)
+	validated := make(chan []*transaction.Transaction, p.config.QueueSize)
+
+	// Read and batch; sends block while the queue is full
+	var sourceErr error
+	go func() {
+		defer close(batches)
+		sourceErr = p.read(ctx, source, r, batches)
+	}()
+
+	// Validate batches concurrently
+	var workers sync.WaitGroup
+	for i := 0; i < p.config.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for batch := range batches {
+				valid := p.validate(ctx, batch, r)
+				if len(valid) == 0 {
+					continue
+				}
+				select {
+				case validated <- valid:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(validated)
+	}()
+
+	// Post batches one at a time
+	for batch := range validated {
+		if ctx.Err() != nil {
+			continue
+		}
+		p.post(ctx, batch, r)
+	}
+
+	r.stats.Completed = time.Now()
+	if sourceErr != nil {
+		return &r.stats, sourceErr
+	}
+	return &r.stats, ctx.Err()
+}
+
+// read fills batches from the source until it is exhausted
+func (p *Pipeline) read(ctx context.Context, source Source, r *run, batches chan<- []*transaction.Transaction) error {
+	batch := make([]*transaction.Transaction, 0, p.config.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		batch = make([]*transaction.Transaction, 0, p.config.BatchSize)
+		return nil
+	}
+
+	for {
+		tx, err := source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return flush()
+		}
+		if err != nil {
+			return fmt.Errorf("error reading source: %w", err)
+		}
+		if tx == nil {
+			continue
+		}
+
+		if tx.Status == "" {
+			tx.Status = transaction.Draft
+		}
+		r.add(&r.stats.Received, 1)
+		batch = append(batch, tx)
+		if len(batch) == p.config.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// validate returns the transactions of a batch that pass validation
+func (p *Pipeline) validate(ctx context.Context, batch []*transaction.Transaction, r *run) []*transaction.Transaction {
+	valid := make([]*transaction.Transaction, 0, len(batch))
+	for _, tx := range batch {
+		result, err := p.processor.ValidateTransaction(ctx, tx)
+		switch {
+		case err != nil:
+			r.add(&r.stats.Rejected, 1)
+			r.recordError(tx.ID, fmt.Errorf("failed to validate transaction: %w", err))
+		case !result.Valid:
+			r.add(&r.stats.Rejected, 1)
+			r.recordError(tx.ID, fmt.Errorf("transaction validation failed: %v", result.Errors))
+		default:
+			valid = append(valid, tx)
+		}
+	}
+	return valid
+}
+
+// post submits a validated batch to the processor
+func (p *Pipeline) post(ctx context.Context, batch []*transaction.Transaction, r *run) {
+	r.add(&r.stats.Batches, 1)
+	if err := p.processor.ProcessTransactionBatch(ctx, batch); err != nil {
+		r.add(&r.stats.Failed, len(batch))
+		r.recordError(batch[0].ID, fmt.Errorf("failed to post batch of %d: %w", len(batch), err))
+		return
+	}
+	r.add(&r.stats.Posted, len(batch))
+}
@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// QIFOptions controls how a QIF file is read
+type QIFOptions struct {
+	// Currency of the amounts; QIF files do not record one
+	Currency string
+	// Whether dates are day first (31/12/2024) rather than US month first
+	DayFirst bool
+}
+
+// qifCashTypes are the QIF sections holding cash movements
+var qifCashTypes = map[string]bool{
+	"bank":  true,
+	"cash":  true,
+	"ccard": true,
+	"oth a": true,
+	"oth l": true,
+}
+
+// ParseQIF reads the bank, cash and credit card transactions of a Quicken
+// Interchange Format file. Investment, category and other sections are
+// skipped. Records that cannot be read are left out and described in the
+// report.
+func ParseQIF(r io.Reader, opts QIFOptions) (*ParsedStatement, *ImportReport, error) {
+	if opts.Currency == "" {
+		return nil, nil, fmt.Errorf("%w: a currency is required", ErrInvalidStatement)
+	}
+
+	stmt := &ParsedStatement{Currency: opts.Currency}
+	report := newReport("QIF")
+	scanner := bufio.NewScanner(r)
+
+	cash := false
+	record := make(map[byte]string)
+	start := 0
+	finish := func() {
+		if len(record) == 0 {
+			return
+		}
+		defer func() { record = make(map[byte]string) }()
+		report.Rows++
+		row, err := qifRow(record, opts)
+		if err != nil {
+			report.reject(start, err)
+			return
+		}
+		row.Line = start
+		stmt.add(row)
+		report.accept(row)
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "!"):
+			finish()
+			header := strings.ToLower(strings.TrimSpace(text[1:]))
+			if kind, ok := strings.CutPrefix(header, "type:"); ok {
+				cash = qifCashTypes[strings.TrimSpace(kind)]
+			} else if header != "option:autoswitch" && header != "clear:autoswitch" {
+				// !Account and similar headers start non-transaction lists
+				cash = false
+			}
+		case text == "^":
+			if cash {
+				finish()
+			}
+			record = make(map[byte]string)
+		case cash:
+			if len(record) == 0 {
+				start = line
+			}
+			// Split lines repeat their codes; the first value is the record's
+			if _, ok := record[text[0]]; !ok {
+				record[text[0]] = strings.TrimSpace(text[1:])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read QIF file: %w", err)
+	}
+	if cash {
+		finish()
+	}
+	return stmt, report, nil
+}
+
+// qifRow reads a QIF transaction record
+func qifRow(record map[byte]string, opts QIFOptions) (Row, error) {
+	date, err := qifDate(record['D'], opts.DayFirst)
+	if err != nil {
+		return Row{}, err
+	}
+
+	text := record['T']
+	if text == "" {
+		text = record['U']
+	}
+	amount, err := decimal.NewFromString(strings.ReplaceAll(strings.ReplaceAll(text, ",", ""), " ", ""))
+	if err != nil {
+		return Row{}, fmt.Errorf("invalid amount %q", text)
+	}
+	if amount.IsZero() {
+		return Row{}, errors.New("amount is zero or missing")
+	}
+	value := money.Money{Amount: amount, Currency: opts.Currency}
+	if err := value.Validate(); err != nil {
+		return Row{}, err
+	}
+
+	description := record['P']
+	if description == "" {
+		description = record['M']
+	}
+	return Row{
+		Date:        date,
+		Amount:      value,
+		Description: description,
+		Reference:   record['N'],
+	}, nil
+}
+
+// qifDate reads a QIF date such as 12/31/2024, 12/31'24 or 31.12.24. Two
+// digit years written after an apostrophe are in the 2000s; others are in
+// the 2000s below 70 and in the 1900s from 70.
+func qifDate(s string, dayFirst bool) (time.Time, error) {
+	text := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	apostrophe := strings.Contains(text, "'")
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '\''
+	})
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", s)
+		}
+		numbers[i] = n
+	}
+	month, day, year := numbers[0], numbers[1], numbers[2]
+	if dayFirst {
+		month, day = day, month
+	}
+	if len(parts[2]) <= 2 {
+		switch {
+		case apostrophe || year < 70:
+			year += 2000
+		default:
+			year += 1900
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return date, nil
+}
@@ -0,0 +1,68 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Source yields the transactions to ingest. Next returns io.EOF once the
+// source is exhausted.
+type Source interface {
+	Next(ctx context.Context) (*transaction.Transaction, error)
+}
+
+// channelSource reads transactions from a channel until it is closed
+type channelSource struct {
+	ch <-chan *transaction.Transaction
+}
+
+// FromChannel returns a source reading from a channel until it is closed
+func FromChannel(ch <-chan *transaction.Transaction) Source {
+	return &channelSource{ch: ch}
+}
+
+func (s *channelSource) Next(ctx context.Context) (*transaction.Transaction, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case tx, ok := <-s.ch:
+		if !ok {
+			return nil, io.EOF
+		}
+		return tx, nil
+	}
+}
+
+// readerSource decodes a stream of JSON transactions
+type readerSource struct {
+	decoder *json.Decoder
+	count   int
+}
+
+// FromReader returns a source decoding JSON transactions from a reader, such
+// as newline-delimited JSON exported by another system
+func FromReader(r io.Reader) Source {
+	return &readerSource{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+func (s *readerSource) Next(ctx context.Context) (*transaction.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var tx transaction.Transaction
+	if err := s.decoder.Decode(&tx); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error decoding transaction %d: %w", s.count+1, err)
+	}
+	s.count++
+	return &tx, nil
+}
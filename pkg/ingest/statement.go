@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reconciliation"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidStatement is returned when a statement file cannot be read
+var ErrInvalidStatement = errors.New("invalid statement file")
+
+// ParsedStatement is a bank statement read from a QIF, MT940 or camt.053
+// file
+type ParsedStatement struct {
+	// Bank account the statement belongs to, as identified by the bank
+	BankAccount string `json:"bank_account,omitempty"`
+	// Bank's reference and sequence number of the statement
+	Reference string `json:"reference,omitempty"`
+	Number    string `json:"number,omitempty"`
+	Currency  string `json:"currency"`
+	// Balances reported by the bank, when the format carries them
+	OpeningBalance *money.Money `json:"opening_balance,omitempty"`
+	ClosingBalance *money.Money `json:"closing_balance,omitempty"`
+	Start          time.Time    `json:"start"`
+	End            time.Time    `json:"end"`
+	Rows           []Row        `json:"rows"`
+}
+
+// add appends a row, extending the statement period to its date
+func (s *ParsedStatement) add(row Row) {
+	if s.Start.IsZero() || row.Date.Before(s.Start) {
+		s.Start = row.Date
+	}
+	if row.Date.After(s.End) {
+		s.End = row.Date
+	}
+	s.Rows = append(s.Rows, row)
+}
+
+// StatementLines returns the rows as lines to reconcile
+func (s *ParsedStatement) StatementLines() []reconciliation.StatementLine {
+	return statementLines(s.Rows)
+}
+
+// Statement returns the statement to import for reconciliation against a
+// ledger account. Without a closing balance from the bank, it is the
+// opening balance, or zero, plus the rows.
+func (s *ParsedStatement) Statement(accountID string) *reconciliation.Statement {
+	opening := money.Money{Amount: decimal.Zero, Currency: s.Currency}
+	if s.OpeningBalance != nil {
+		opening = *s.OpeningBalance
+	}
+	closing := opening
+	if s.ClosingBalance != nil {
+		closing = *s.ClosingBalance
+	} else {
+		for _, row := range s.Rows {
+			closing.Amount = closing.Amount.Add(row.Amount.Amount)
+		}
+	}
+	return &reconciliation.Statement{
+		AccountID:      accountID,
+		Start:          s.Start,
+		End:            s.End,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+	}
+}
+
+// Transactions returns the rows as draft transactions between a ledger
+// account and a contra account, with IDs built from a prefix and the row
+// line
+func (s *ParsedStatement) Transactions(accountID, contraAccountID, idPrefix string) []*transaction.Transaction {
+	txs := make([]*transaction.Transaction, 0, len(s.Rows))
+	for _, row := range s.Rows {
+		contra := row.ContraAccount
+		if contra == "" {
+			contra = contraAccountID
+		}
+		txs = append(txs, draftTransaction(row, accountID, contra, idPrefix))
+	}
+	return txs
+}
+
+// statementLines converts rows into lines to reconcile
+func statementLines(rows []Row) []reconciliation.StatementLine {
+	lines := make([]reconciliation.StatementLine, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, reconciliation.StatementLine{
+			Date:        row.Date,
+			Amount:      row.Amount,
+			Description: row.Description,
+			Reference:   row.Reference,
+		})
+	}
+	return lines
+}
+
+// draftTransaction builds a draft transaction moving a row's amount
+// between an account, debited for deposits, and a contra account
+func draftTransaction(row Row, accountID, contraAccountID, idPrefix string) *transaction.Transaction {
+	side := transaction.Debit
+	if row.Amount.IsNegative() {
+		side = transaction.Credit
+	}
+	amount := row.Amount.Abs()
+	id := row.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-%d", idPrefix, row.Line)
+	}
+	return &transaction.Transaction{
+		ID:          id,
+		Type:        transaction.Journal,
+		Status:      transaction.Draft,
+		Date:        row.Date,
+		Description: row.Description,
+		Reference:   row.Reference,
+		Entries: []transaction.Entry{
+			{AccountID: accountID, Amount: amount, Type: side, Description: row.Description},
+			{AccountID: contraAccountID, Amount: amount, Type: side.Reverse(), Description: row.Description},
+		},
+	}
+}
+
+// newReport creates an empty import report
+func newReport(profile string) *ImportReport {
+	return &ImportReport{Profile: profile, Totals: make(map[string]decimal.Decimal)}
+}
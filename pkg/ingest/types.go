@@ -0,0 +1,93 @@
+// Package ingest posts high volumes of transactions from a stream. A pipeline
+// batches incoming transactions, validates them concurrently and posts the
+// valid ones through the processor's batch API. Bounded queues between the
+// stages apply backpressure to the source when posting falls behind.
+//
+// CSVImporter reads bank and accounting CSV exports through mapping profiles,
+// and ParseQIF, ParseMT940 and ParseCamt053 read QIF, SWIFT MT940 and ISO
+// 20022 camt.053 statements, into draft transactions for a pipeline or lines
+// for reconciliation.
+package ingest
+
+import (
+	"runtime"
+	"time"
+)
+
+const (
+	// DefaultBatchSize is the number of transactions posted per batch
+	DefaultBatchSize = 500
+	// DefaultMaxErrors is the number of item errors retained in Stats
+	DefaultMaxErrors = 100
+)
+
+// Config controls batching and concurrency of a pipeline
+type Config struct {
+	// Number of transactions posted per batch
+	BatchSize int
+	// Number of concurrent validation workers; defaults to the number of CPUs
+	Workers int
+	// Number of batches buffered between stages; defaults to twice the workers
+	QueueSize int
+	// Number of item errors retained in Stats; further errors are only counted
+	MaxErrors int
+}
+
+// withDefaults fills unset fields with their defaults
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.Workers <= 0 {
+		c.Workers = runtime.NumCPU()
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = c.Workers * 2
+	}
+	if c.MaxErrors <= 0 {
+		c.MaxErrors = DefaultMaxErrors
+	}
+	return c
+}
+
+// ItemError records why a transaction was not posted
+type ItemError struct {
+	// ID of the rejected transaction
+	TransactionID string `json:"transaction_id"`
+	// Reason the transaction was rejected
+	Message string `json:"message"`
+}
+
+// Stats reports the outcome of a pipeline run
+type Stats struct {
+	// Transactions read from the source
+	Received int64 `json:"received"`
+	// Transactions posted
+	Posted int64 `json:"posted"`
+	// Transactions that failed validation
+	Rejected int64 `json:"rejected"`
+	// Transactions in batches the processor failed to post
+	Failed int64 `json:"failed"`
+	// Batches submitted to the processor
+	Batches int64 `json:"batches"`
+	// First MaxErrors item errors
+	Errors []ItemError `json:"errors,omitempty"`
+	// When the run started
+	Started time.Time `json:"started"`
+	// When the run completed
+	Completed time.Time `json:"completed"`
+}
+
+// Duration returns how long the run took
+func (s *Stats) Duration() time.Duration {
+	return s.Completed.Sub(s.Started)
+}
+
+// Throughput returns the number of transactions posted per second
+func (s *Stats) Throughput() float64 {
+	seconds := s.Duration().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(s.Posted) / seconds
+}
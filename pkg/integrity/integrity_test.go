@@ -0,0 +1,143 @@
+package integrity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedStore returns a fixed set of records from Query
+type fixedStore struct {
+	storage.Repository
+	txs      []*transaction.Transaction
+	accounts []*account.Account
+	audit    []storage.AuditEntry
+}
+
+func (s *fixedStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	switch out := results.(type) {
+	case *[]*transaction.Transaction:
+		*out = append(*out, s.txs...)
+	case *[]*account.Account:
+		*out = append(*out, s.accounts...)
+	}
+	return nil
+}
+
+func (s *fixedStore) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	return s.audit, nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func posted(id string, debit, credit string, amount int64) *transaction.Transaction {
+	return &transaction.Transaction{
+		ID:     id,
+		Status: transaction.Posted,
+		Entries: []transaction.Entry{
+			{AccountID: debit, Amount: usd(amount), Type: transaction.Debit},
+			{AccountID: credit, Amount: usd(amount), Type: transaction.Credit},
+		},
+	}
+}
+
+func balance(amount int64) *money.Money {
+	m := usd(amount)
+	return &m
+}
+
+func sealedChain(n int) []storage.AuditEntry {
+	entries := make([]storage.AuditEntry, n)
+	for i := range entries {
+		entries[i] = storage.AuditEntry{ID: string(rune('a' + i)), Operation: "CREATE"}
+		if i == 0 {
+			audit.Seal(&entries[i], nil)
+		} else {
+			audit.Seal(&entries[i], &entries[i-1])
+		}
+	}
+	return entries
+}
+
+func TestVerifyLedger(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clean ledger", func(t *testing.T) {
+		original := posted("TX1", "1000", "4000", 100)
+		reversal := posted("TX2", "4000", "1000", 100)
+		original.ReversalID = "TX2"
+		reversal.ReversedFrom = "TX1"
+
+		store := &fixedStore{
+			txs: []*transaction.Transaction{original, reversal, posted("TX3", "1000", "4000", 50)},
+			accounts: []*account.Account{
+				{ID: "1000", Type: account.Asset, Balance: balance(50)},
+				{ID: "4000", Type: account.Revenue, Balance: balance(50)},
+				{ID: "5000", Type: account.Expense},
+			},
+			audit: sealedChain(3),
+		}
+
+		report, err := NewVerifier(store, store, store).VerifyLedger(ctx)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), "%v", report.Findings)
+		assert.Equal(t, 3, report.TransactionsChecked)
+		assert.Equal(t, 2, report.AccountsChecked)
+		assert.Equal(t, 3, report.AuditEntriesChecked)
+	})
+
+	t.Run("violations are reported", func(t *testing.T) {
+		unbalanced := posted("TX1", "1000", "4000", 100)
+		unbalanced.Entries[1].Amount = usd(90)
+		orphan := posted("TX2", "4000", "1000", 10)
+		orphan.ReversedFrom = "TX1"
+		draft := posted("TX3", "1000", "4000", 1000)
+		draft.Status = transaction.Draft
+
+		chain := sealedChain(3)
+		chain[2].UserID = "mallory"
+
+		store := &fixedStore{
+			txs: []*transaction.Transaction{unbalanced, orphan, draft},
+			accounts: []*account.Account{
+				{ID: "1000", Type: account.Asset, Balance: balance(90)},
+				{ID: "4000", Type: account.Revenue, Balance: balance(0)},
+			},
+			audit: chain,
+		}
+
+		report, err := NewVerifier(store, store, store).VerifyLedger(ctx)
+		require.NoError(t, err)
+		assert.False(t, report.OK())
+
+		require.Len(t, report.FindingsOf(UnbalancedTransaction), 1)
+		assert.Equal(t, "TX1", report.FindingsOf(UnbalancedTransaction)[0].SubjectID)
+
+		require.Len(t, report.FindingsOf(BrokenReversalLink), 1)
+		assert.Equal(t, "TX2", report.FindingsOf(BrokenReversalLink)[0].SubjectID)
+
+		require.Len(t, report.FindingsOf(BalanceMismatch), 1)
+		assert.Equal(t, "4000", report.FindingsOf(BalanceMismatch)[0].SubjectID)
+
+		require.Len(t, report.FindingsOf(AuditChainBroken), 1)
+		assert.Equal(t, "c", report.FindingsOf(AuditChainBroken)[0].SubjectID)
+	})
+
+	t.Run("audit check is optional", func(t *testing.T) {
+		store := &fixedStore{}
+		report, err := NewVerifier(store, store, nil).VerifyLedger(ctx)
+		require.NoError(t, err)
+		assert.True(t, report.OK())
+		assert.Zero(t, report.AuditEntriesChecked)
+	})
+}
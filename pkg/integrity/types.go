@@ -0,0 +1,62 @@
+// Package integrity verifies ledger-wide invariants across a store.
+package integrity
+
+import (
+	"time"
+)
+
+// FindingType classifies an integrity violation
+type FindingType string
+
+const (
+	// A posted transaction whose debits and credits differ
+	UnbalancedTransaction FindingType = "UNBALANCED_TRANSACTION"
+	// A stored account balance that differs from the sum of its movements
+	BalanceMismatch FindingType = "BALANCE_MISMATCH"
+	// A reversal whose original and reversal transactions do not point at each other
+	BrokenReversalLink FindingType = "BROKEN_REVERSAL_LINK"
+	// An audit log whose hash chain does not verify
+	AuditChainBroken FindingType = "AUDIT_CHAIN_BROKEN"
+)
+
+// Finding describes a single integrity violation
+type Finding struct {
+	// Kind of violation
+	Type FindingType `json:"type"`
+	// Transaction, account or audit entry the finding concerns
+	SubjectID string `json:"subject_id"`
+	// Human readable explanation
+	Message string `json:"message"`
+}
+
+// Report is the result of a ledger verification run
+type Report struct {
+	// Number of transactions inspected
+	TransactionsChecked int `json:"transactions_checked"`
+	// Number of accounts whose balances were inspected
+	AccountsChecked int `json:"accounts_checked"`
+	// Number of audit entries verified
+	AuditEntriesChecked int `json:"audit_entries_checked"`
+	// Violations found
+	Findings []Finding `json:"findings"`
+	// When verification started
+	Started time.Time `json:"started"`
+	// When verification completed
+	Completed time.Time `json:"completed"`
+}
+
+// OK reports whether verification found no violations
+func (r *Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// FindingsOf returns the findings of a given type
+func (r *Report) FindingsOf(t FindingType) []Finding {
+	var findings []Finding
+	for _, f := range r.Findings {
+		if f.Type == t {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
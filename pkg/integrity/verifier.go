@@ -0,0 +1,226 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// Verifier checks ledger invariants across the whole store
+type Verifier struct {
+	transactions storage.Repository
+	accounts     storage.Repository
+	auditLog     storage.AuditLogRepository
+}
+
+// NewVerifier creates a verifier. The audit log is optional; when nil the
+// audit chain check is skipped.
+func NewVerifier(transactions, accounts storage.Repository, auditLog storage.AuditLogRepository) *Verifier {
+	return &Verifier{
+		transactions: transactions,
+		accounts:     accounts,
+		auditLog:     auditLog,
+	}
+}
+
+// VerifyLedger checks that every posted transaction balances, that stored
+// account balances equal the sum of their movements, that reversal links
+// are bidirectional and that the audit chain is intact. Violations are
+// returned as findings; an error is only returned when the store cannot be
+// read.
+func (v *Verifier) VerifyLedger(ctx context.Context) (*Report, error) {
+	report := &Report{Started: time.Now()}
+
+	var txs []*transaction.Transaction
+	if err := v.transactions.Query(ctx, storage.Query{}, &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+	var accounts []*account.Account
+	if err := v.accounts.Query(ctx, storage.Query{}, &accounts); err != nil {
+		return nil, fmt.Errorf("error querying accounts: %w", err)
+	}
+	report.TransactionsChecked = len(txs)
+
+	report.Findings = append(report.Findings, checkBalanced(txs)...)
+	report.Findings = append(report.Findings, checkReversalLinks(txs)...)
+
+	balanceFindings, checked := checkAccountBalances(accounts, txs)
+	report.Findings = append(report.Findings, balanceFindings...)
+	report.AccountsChecked = checked
+
+	if v.auditLog != nil {
+		entries, err := v.auditLog.QueryAudit(ctx, storage.AuditQuery{})
+		if err != nil {
+			return nil, fmt.Errorf("error querying audit log: %w", err)
+		}
+		report.AuditEntriesChecked = len(entries)
+		if err := audit.VerifyChain(entries); err != nil {
+			finding := Finding{Type: AuditChainBroken, Message: err.Error()}
+			if chainErr, ok := err.(*audit.ChainError); ok {
+				finding.SubjectID = chainErr.EntryID
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	report.Completed = time.Now()
+	return report, nil
+}
+
+// checkBalanced verifies debits equal credits per currency for posted transactions
+func checkBalanced(txs []*transaction.Transaction) []Finding {
+	var findings []Finding
+	for _, tx := range txs {
+		if tx.Status != transaction.Posted {
+			continue
+		}
+
+		net := make(map[string]decimal.Decimal)
+		for _, entry := range tx.Entries {
+			amount := entry.Amount.Amount
+			if entry.Type == transaction.Credit {
+				amount = amount.Neg()
+			}
+			net[entry.Amount.Currency] = net[entry.Amount.Currency].Add(amount)
+		}
+
+		for _, currency := range sortedKeys(net) {
+			if !net[currency].IsZero() {
+				findings = append(findings, Finding{
+					Type:      UnbalancedTransaction,
+					SubjectID: tx.ID,
+					Message:   fmt.Sprintf("debits exceed credits by %s %s", net[currency].String(), currency),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkReversalLinks verifies that originals and reversals reference each other
+func checkReversalLinks(txs []*transaction.Transaction) []Finding {
+	byID := make(map[string]*transaction.Transaction, len(txs))
+	for _, tx := range txs {
+		byID[tx.ID] = tx
+	}
+
+	var findings []Finding
+	for _, tx := range txs {
+		if tx.ReversedFrom != "" {
+			original, ok := byID[tx.ReversedFrom]
+			switch {
+			case !ok:
+				findings = append(findings, Finding{
+					Type:      BrokenReversalLink,
+					SubjectID: tx.ID,
+					Message:   fmt.Sprintf("reverses missing transaction %s", tx.ReversedFrom),
+				})
+			case original.ReversalID != tx.ID:
+				findings = append(findings, Finding{
+					Type:      BrokenReversalLink,
+					SubjectID: tx.ID,
+					Message:   fmt.Sprintf("original %s does not reference this reversal", original.ID),
+				})
+			}
+		}
+
+		if tx.ReversalID != "" {
+			reversal, ok := byID[tx.ReversalID]
+			switch {
+			case !ok:
+				findings = append(findings, Finding{
+					Type:      BrokenReversalLink,
+					SubjectID: tx.ID,
+					Message:   fmt.Sprintf("reversal %s does not exist", tx.ReversalID),
+				})
+			case reversal.ReversedFrom != tx.ID:
+				findings = append(findings, Finding{
+					Type:      BrokenReversalLink,
+					SubjectID: tx.ID,
+					Message:   fmt.Sprintf("reversal %s does not reference this transaction", reversal.ID),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkAccountBalances compares stored balances with posted movements.
+// Accounts without a stored balance are skipped.
+func checkAccountBalances(accounts []*account.Account, txs []*transaction.Transaction) ([]Finding, int) {
+	type movement struct {
+		amount     decimal.Decimal
+		currencies map[string]bool
+	}
+	movements := make(map[string]*movement)
+	for _, tx := range txs {
+		if tx.Status != transaction.Posted {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			m, ok := movements[entry.AccountID]
+			if !ok {
+				m = &movement{currencies: make(map[string]bool)}
+				movements[entry.AccountID] = m
+			}
+			m.currencies[entry.Amount.Currency] = true
+			if entry.Type == transaction.Debit {
+				m.amount = m.amount.Add(entry.Amount.Amount)
+			} else {
+				m.amount = m.amount.Sub(entry.Amount.Amount)
+			}
+		}
+	}
+
+	var findings []Finding
+	checked := 0
+	for _, acc := range accounts {
+		if acc.Balance == nil {
+			continue
+		}
+		checked++
+
+		expected := decimal.Zero
+		if m, ok := movements[acc.ID]; ok {
+			for currency := range m.currencies {
+				if currency != acc.Balance.Currency {
+					findings = append(findings, Finding{
+						Type:      BalanceMismatch,
+						SubjectID: acc.ID,
+						Message:   fmt.Sprintf("movements in %s do not match balance currency %s", currency, acc.Balance.Currency),
+					})
+				}
+			}
+			expected = m.amount
+			// Liability, equity and revenue accounts carry credit balances
+			if acc.Type != account.Asset && acc.Type != account.Expense {
+				expected = expected.Neg()
+			}
+		}
+
+		if !acc.Balance.Amount.Equal(expected) {
+			findings = append(findings, Finding{
+				Type:      BalanceMismatch,
+				SubjectID: acc.ID,
+				Message:   fmt.Sprintf("stored balance %s does not equal movements %s", acc.Balance.Amount.String(), expected.String()),
+			})
+		}
+	}
+	return findings, checked
+}
+
+func sortedKeys(m map[string]decimal.Decimal) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
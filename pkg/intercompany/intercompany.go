@@ -0,0 +1,322 @@
+package intercompany
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrTransactionNotFound  = errors.New("intercompany transaction not found")
+	ErrInvalidTransaction   = errors.New("invalid intercompany transaction")
+	ErrInvalidRelationship  = errors.New("invalid intercompany relationship")
+	ErrRelationshipNotFound = errors.New("intercompany relationship not found")
+)
+
+// Manager defines the interface for intercompany operations
+type Manager interface {
+	// AddRelationship registers the accounts an entity keeps its balances
+	// with a counterparty in
+	AddRelationship(rel Relationship) error
+
+	// Post books an intercompany transaction in both entities' books
+	Post(ctx context.Context, ic *Transaction) error
+
+	// GetTransaction retrieves an intercompany transaction by ID
+	GetTransaction(ctx context.Context, id string) (*Transaction, error)
+
+	// Match compares the intercompany balances of every entity pair
+	Match(ctx context.Context, asOf time.Time, currency string) (*MatchingReport, error)
+}
+
+// pairKey identifies a relationship by entity and counterparty
+type pairKey struct {
+	entityID       string
+	counterpartyID string
+}
+
+// BasicManager provides a storage-backed implementation of Manager
+type BasicManager struct {
+	repo          storage.Repository
+	processor     transaction.TransactionProcessor
+	calculator    reporting.ReportCalculator
+	relationships map[pairKey]Relationship
+}
+
+// NewBasicManager creates a new BasicManager. The calculator reads the
+// due-to and due-from balances of each entity for matching.
+func NewBasicManager(repo storage.Repository, processor transaction.TransactionProcessor, calculator reporting.ReportCalculator) *BasicManager {
+	return &BasicManager{
+		repo:          repo,
+		processor:     processor,
+		calculator:    calculator,
+		relationships: make(map[pairKey]Relationship),
+	}
+}
+
+// AddRelationship implements Manager.AddRelationship. A relationship
+// registered again replaces the earlier one.
+func (m *BasicManager) AddRelationship(rel Relationship) error {
+	if rel.EntityID == "" || rel.CounterpartyID == "" {
+		return fmt.Errorf("%w: entity and counterparty are required", ErrInvalidRelationship)
+	}
+	if rel.EntityID == rel.CounterpartyID {
+		return fmt.Errorf("%w: entity %s cannot be its own counterparty", ErrInvalidRelationship, rel.EntityID)
+	}
+	if rel.DueFromAccountID == "" || rel.DueToAccountID == "" {
+		return fmt.Errorf("%w: due-from and due-to accounts are required", ErrInvalidRelationship)
+	}
+	if rel.DueFromAccountID == rel.DueToAccountID {
+		return fmt.Errorf("%w: due-from and due-to accounts must differ", ErrInvalidRelationship)
+	}
+	m.relationships[pairKey{rel.EntityID, rel.CounterpartyID}] = rel
+	return nil
+}
+
+// relationship returns the relationship of an entity with a counterparty
+func (m *BasicManager) relationship(entityID, counterpartyID string) (Relationship, error) {
+	rel, ok := m.relationships[pairKey{entityID, counterpartyID}]
+	if !ok {
+		return Relationship{}, fmt.Errorf("%w: %s with %s", ErrRelationshipNotFound, entityID, counterpartyID)
+	}
+	return rel, nil
+}
+
+// Post implements Manager.Post. The journal entries of both entities are
+// posted as one batch, so either both books record the transaction or
+// neither does. The context must not be scoped to a single entity.
+func (m *BasicManager) Post(ctx context.Context, ic *Transaction) error {
+	if err := validateTransaction(ic); err != nil {
+		return err
+	}
+	source, err := m.relationship(ic.SourceEntityID, ic.TargetEntityID)
+	if err != nil {
+		return err
+	}
+	target, err := m.relationship(ic.TargetEntityID, ic.SourceEntityID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if ic.ID == "" {
+		ic.ID = fmt.Sprintf("IC_%d", now.UnixNano())
+	}
+	sourceTx, targetTx := BuildJournalEntries(ic, source, target)
+	if err := transaction.CreateAndPostBatch(ctx, m.repo, m.processor, []*transaction.Transaction{sourceTx, targetTx}); err != nil {
+		return fmt.Errorf("failed to post intercompany journal entries: %w", err)
+	}
+
+	ic.SourceTransactionID = sourceTx.ID
+	ic.TargetTransactionID = targetTx.ID
+	ic.Created = now
+	if err := m.repo.Create(ctx, ic); err != nil {
+		return fmt.Errorf("failed to store intercompany transaction: %w", err)
+	}
+	return nil
+}
+
+// GetTransaction implements Manager.GetTransaction
+func (m *BasicManager) GetTransaction(ctx context.Context, id string) (*Transaction, error) {
+	var ic Transaction
+	if err := m.repo.Read(ctx, id, &ic); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrTransactionNotFound, id, err)
+	}
+	return &ic, nil
+}
+
+// BuildJournalEntries creates the mirrored journal entries of an
+// intercompany transaction in the source's and the target's books. Charges
+// and funding debit the source's due-from account and credit the target's
+// due-to account; settlements reverse both.
+func BuildJournalEntries(ic *Transaction, source, target Relationship) (*transaction.Transaction, *transaction.Transaction) {
+	dimensions := func() map[string]string {
+		return map[string]string{DimensionID: ic.ID}
+	}
+	sourceEntries := []transaction.Entry{
+		{
+			AccountID:   source.DueFromAccountID,
+			Amount:      ic.Amount,
+			Type:        transaction.Debit,
+			Description: fmt.Sprintf("Due from %s", ic.TargetEntityID),
+			PartyID:     ic.TargetEntityID,
+			Dimensions:  dimensions(),
+		},
+		{
+			AccountID:   ic.SourceAccountID,
+			Amount:      ic.Amount,
+			Type:        transaction.Credit,
+			Description: ic.Description,
+			Dimensions:  dimensions(),
+		},
+	}
+	targetEntries := []transaction.Entry{
+		{
+			AccountID:   ic.TargetAccountID,
+			Amount:      ic.Amount,
+			Type:        transaction.Debit,
+			Description: ic.Description,
+			Dimensions:  dimensions(),
+		},
+		{
+			AccountID:   target.DueToAccountID,
+			Amount:      ic.Amount,
+			Type:        transaction.Credit,
+			Description: fmt.Sprintf("Due to %s", ic.SourceEntityID),
+			PartyID:     ic.SourceEntityID,
+			Dimensions:  dimensions(),
+		},
+	}
+	if ic.Kind == Settlement {
+		for i := range sourceEntries {
+			sourceEntries[i].Type = sourceEntries[i].Type.Reverse()
+			targetEntries[i].Type = targetEntries[i].Type.Reverse()
+		}
+	}
+
+	now := time.Now()
+	journal := func(entityID string, entries []transaction.Entry) *transaction.Transaction {
+		return &transaction.Transaction{
+			ID:           fmt.Sprintf("TX-%s-%s", ic.ID, entityID),
+			Type:         transaction.Journal,
+			Status:       transaction.Draft,
+			Date:         ic.Date,
+			Description:  fmt.Sprintf("Intercompany %s %s", strings.ToLower(string(ic.Kind)), ic.ID),
+			Reference:    ic.ID,
+			Entries:      entries,
+			EntityID:     entityID,
+			Created:      now,
+			LastModified: now,
+		}
+	}
+	return journal(ic.SourceEntityID, sourceEntries), journal(ic.TargetEntityID, targetEntries)
+}
+
+// Match implements Manager.Match. Each pair of entities with relationships
+// in both directions is reported once, and is matched when what one entity
+// is owed in its books equals what the other owes in its own.
+func (m *BasicManager) Match(ctx context.Context, asOf time.Time, currency string) (*MatchingReport, error) {
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	keys := make([]pairKey, 0, len(m.relationships))
+	for key := range m.relationships {
+		if key.entityID < key.counterpartyID {
+			if _, ok := m.relationships[pairKey{key.counterpartyID, key.entityID}]; ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].entityID != keys[j].entityID {
+			return keys[i].entityID < keys[j].entityID
+		}
+		return keys[i].counterpartyID < keys[j].counterpartyID
+	})
+
+	report := &MatchingReport{AsOf: asOf, Currency: currency, Pairs: make([]PairBalance, 0, len(keys))}
+	for _, key := range keys {
+		balance, err := m.netBalance(ctx, m.relationships[key], asOf, currency)
+		if err != nil {
+			return nil, err
+		}
+		counterparty, err := m.netBalance(ctx, m.relationships[pairKey{key.counterpartyID, key.entityID}], asOf, currency)
+		if err != nil {
+			return nil, err
+		}
+		difference := balance.Add(counterparty)
+		report.Pairs = append(report.Pairs, PairBalance{
+			EntityID:            key.entityID,
+			CounterpartyID:      key.counterpartyID,
+			Balance:             money.Money{Amount: balance, Currency: currency},
+			CounterpartyBalance: money.Money{Amount: counterparty, Currency: currency},
+			Difference:          money.Money{Amount: difference, Currency: currency},
+			Matched:             difference.IsZero(),
+		})
+	}
+	return report, nil
+}
+
+// netBalance returns the due-from less the due-to balance in an entity's
+// books at the end of a day
+func (m *BasicManager) netBalance(ctx context.Context, rel Relationship, asOf time.Time, currency string) (decimal.Decimal, error) {
+	scoped := entity.WithEntity(ctx, rel.EntityID)
+	period := reporting.ReportPeriod{End: asOf}
+	net := decimal.Zero
+	for _, accountID := range []string{rel.DueFromAccountID, rel.DueToAccountID} {
+		balance, err := m.calculator.CalculateBalance(scoped, accountID, period)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("error calculating balance of %s for entity %s: %w", accountID, rel.EntityID, err)
+		}
+		if !balance.IsZero() && balance.Currency != currency {
+			return decimal.Zero, fmt.Errorf("account %s of entity %s is kept in %s", accountID, rel.EntityID, balance.Currency)
+		}
+		if accountID == rel.DueFromAccountID {
+			net = net.Add(balance.Amount)
+		} else {
+			net = net.Sub(balance.Amount)
+		}
+	}
+	return net, nil
+}
+
+// EliminationRules returns a consolidation elimination rule for the due-to
+// and due-from accounts of every entity pair
+func (m *BasicManager) EliminationRules() []statements.EliminationRule {
+	rules := make([]statements.EliminationRule, 0)
+	for key, rel := range m.relationships {
+		if key.entityID > key.counterpartyID {
+			continue
+		}
+		other, ok := m.relationships[pairKey{key.counterpartyID, key.entityID}]
+		if !ok {
+			continue
+		}
+		rules = append(rules, statements.EliminationRule{
+			ID:          fmt.Sprintf("IC-%s-%s", key.entityID, key.counterpartyID),
+			Description: fmt.Sprintf("Intercompany balances between %s and %s", key.entityID, key.counterpartyID),
+			AccountIDs:  []string{rel.DueFromAccountID, rel.DueToAccountID, other.DueFromAccountID, other.DueToAccountID},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+func validateTransaction(ic *Transaction) error {
+	if ic == nil {
+		return fmt.Errorf("%w: transaction cannot be nil", ErrInvalidTransaction)
+	}
+	switch ic.Kind {
+	case Charge, Funding, Settlement:
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidTransaction, ic.Kind)
+	}
+	if ic.SourceEntityID == "" || ic.TargetEntityID == "" {
+		return fmt.Errorf("%w: source and target entities are required", ErrInvalidTransaction)
+	}
+	if ic.SourceEntityID == ic.TargetEntityID {
+		return fmt.Errorf("%w: source and target must be different entities", ErrInvalidTransaction)
+	}
+	if ic.SourceAccountID == "" || ic.TargetAccountID == "" {
+		return fmt.Errorf("%w: source and target accounts are required", ErrInvalidTransaction)
+	}
+	if !ic.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidTransaction)
+	}
+	if ic.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidTransaction)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package intercompany
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor posts transactions with the library's processor and
+// records them
+type recordingProcessor struct {
+	*transaction.BasicTransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func (p *recordingProcessor) ProcessTransactionBatch(ctx context.Context, txs []*transaction.Transaction) error {
+	if err := p.BasicTransactionProcessor.ProcessTransactionBatch(ctx, txs); err != nil {
+		return err
+	}
+	p.posted = append(p.posted, txs...)
+	return nil
+}
+
+// ledgerCalculator reports balances of the context entity from recorded
+// transactions, with credit balances positive for liability accounts
+type ledgerCalculator struct {
+	reporting.ReportCalculator
+	processor   *recordingProcessor
+	liabilities map[string]bool
+}
+
+func (c *ledgerCalculator) CalculateBalance(ctx context.Context, accountID string, period reporting.ReportPeriod) (money.Money, error) {
+	entityID, _ := entity.FromContext(ctx)
+	balance := decimal.Zero
+	for _, tx := range c.processor.posted {
+		if tx.EntityID != entityID || tx.Date.After(period.End) {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				continue
+			}
+			if (entry.Type == transaction.Debit) != c.liabilities[accountID] {
+				balance = balance.Add(entry.Amount.Amount)
+			} else {
+				balance = balance.Sub(entry.Amount.Amount)
+			}
+		}
+	}
+	return money.Money{Amount: balance, Currency: "USD"}, nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func newTestManager(t *testing.T) (*BasicManager, *recordingProcessor) {
+	store := memory.NewMemoryStore()
+	processor := &recordingProcessor{BasicTransactionProcessor: transaction.NewBasicTransactionProcessor(store)}
+	calculator := &ledgerCalculator{processor: processor, liabilities: map[string]bool{"US-2300": true, "UK-2300": true}}
+	manager := NewBasicManager(store, processor, calculator)
+	require.NoError(t, manager.AddRelationship(Relationship{EntityID: "US", CounterpartyID: "UK", DueFromAccountID: "US-1300", DueToAccountID: "US-2300"}))
+	require.NoError(t, manager.AddRelationship(Relationship{EntityID: "UK", CounterpartyID: "US", DueFromAccountID: "UK-1300", DueToAccountID: "UK-2300"}))
+	return manager, processor
+}
+
+func TestPost(t *testing.T) {
+	ctx := context.Background()
+	manager, processor := newTestManager(t)
+	jan := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	charge := &Transaction{
+		ID:              "IC1",
+		Kind:            Charge,
+		Date:            jan,
+		Description:     "Management fee",
+		SourceEntityID:  "US",
+		TargetEntityID:  "UK",
+		Amount:          usd(1000),
+		SourceAccountID: "US-4500",
+		TargetAccountID: "UK-6500",
+	}
+	require.NoError(t, manager.Post(ctx, charge))
+	assert.Equal(t, "TX-IC1-US", charge.SourceTransactionID)
+	assert.Equal(t, "TX-IC1-UK", charge.TargetTransactionID)
+	require.Len(t, processor.posted, 2)
+
+	source, target := processor.posted[0], processor.posted[1]
+	assert.Equal(t, "US", source.EntityID)
+	assert.Equal(t, "IC1", source.Reference)
+	assert.Equal(t, "US-1300", source.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, source.Entries[0].Type)
+	assert.Equal(t, "UK", source.Entries[0].PartyID)
+	assert.Equal(t, "IC1", source.Entries[0].Dimensions[DimensionID])
+	assert.Equal(t, "UK", target.EntityID)
+	assert.Equal(t, "UK-2300", target.Entries[1].AccountID)
+	assert.Equal(t, transaction.Credit, target.Entries[1].Type)
+
+	stored, err := manager.GetTransaction(ctx, "IC1")
+	require.NoError(t, err)
+	assert.Equal(t, "TX-IC1-UK", stored.TargetTransactionID)
+	for _, id := range []string{stored.SourceTransactionID, stored.TargetTransactionID} {
+		tx, err := processor.GetTransaction(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, transaction.Posted, tx.Status)
+	}
+
+	settlement := &Transaction{
+		ID:              "IC2",
+		Kind:            Settlement,
+		Date:            jan.AddDate(0, 1, 0),
+		SourceEntityID:  "US",
+		TargetEntityID:  "UK",
+		Amount:          usd(400),
+		SourceAccountID: "US-1000",
+		TargetAccountID: "UK-1000",
+	}
+	require.NoError(t, manager.Post(ctx, settlement))
+	source, target = processor.posted[2], processor.posted[3]
+	assert.Equal(t, transaction.Credit, source.Entries[0].Type)
+	assert.Equal(t, transaction.Debit, target.Entries[1].Type)
+
+	err = manager.Post(ctx, &Transaction{Kind: Funding, Date: jan, SourceEntityID: "US", TargetEntityID: "DE", Amount: usd(1), SourceAccountID: "1000", TargetAccountID: "1000"})
+	assert.ErrorIs(t, err, ErrRelationshipNotFound)
+	err = manager.Post(ctx, &Transaction{Kind: Funding, Date: jan, SourceEntityID: "US", TargetEntityID: "US", Amount: usd(1), SourceAccountID: "1000", TargetAccountID: "1000"})
+	assert.ErrorIs(t, err, ErrInvalidTransaction)
+	assert.ErrorIs(t, manager.AddRelationship(Relationship{EntityID: "US", CounterpartyID: "DE", DueFromAccountID: "X", DueToAccountID: "X"}), ErrInvalidRelationship)
+	_, err = manager.GetTransaction(ctx, "missing")
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+}
+
+func TestMatch(t *testing.T) {
+	ctx := context.Background()
+	manager, processor := newTestManager(t)
+	jan := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, manager.Post(ctx, &Transaction{
+		ID: "IC1", Kind: Charge, Date: jan, SourceEntityID: "US", TargetEntityID: "UK",
+		Amount: usd(1000), SourceAccountID: "US-4500", TargetAccountID: "UK-6500",
+	}))
+	require.NoError(t, manager.Post(ctx, &Transaction{
+		ID: "IC2", Kind: Funding, Date: jan, SourceEntityID: "UK", TargetEntityID: "US",
+		Amount: usd(300), SourceAccountID: "UK-1000", TargetAccountID: "US-1000",
+	}))
+
+	report, err := manager.Match(ctx, jan, "USD")
+	require.NoError(t, err)
+	require.Len(t, report.Pairs, 1)
+	pair := report.Pairs[0]
+	assert.Equal(t, "UK", pair.EntityID)
+	assert.Equal(t, "-700", pair.Balance.Amount.String())
+	assert.Equal(t, "700", pair.CounterpartyBalance.Amount.String())
+	assert.True(t, pair.Matched)
+	assert.Empty(t, report.Unmatched())
+
+	// An entry booked in one entity's books only leaves the pair unmatched
+	processor.posted = append(processor.posted, &transaction.Transaction{
+		ID:       "TX-MANUAL",
+		Status:   transaction.Posted,
+		Date:     jan,
+		EntityID: "UK",
+		Entries: []transaction.Entry{
+			{AccountID: "UK-6500", Amount: usd(50), Type: transaction.Debit},
+			{AccountID: "UK-2300", Amount: usd(50), Type: transaction.Credit},
+		},
+	})
+	report, err = manager.Match(ctx, jan, "USD")
+	require.NoError(t, err)
+	unmatched := report.Unmatched()
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, "-50", unmatched[0].Difference.Amount.String())
+
+	// Balances are read at the end of the as-of day
+	report, err = manager.Match(ctx, jan.AddDate(0, 0, -1), "USD")
+	require.NoError(t, err)
+	assert.True(t, report.Pairs[0].Matched)
+	assert.True(t, report.Pairs[0].Balance.IsZero())
+
+	rules := manager.EliminationRules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "IC-UK-US", rules[0].ID)
+	assert.ElementsMatch(t, []string{"US-1300", "US-2300", "UK-1300", "UK-2300"}, rules[0].AccountIDs)
+}
@@ -0,0 +1,100 @@
+// Package intercompany posts transactions between entities of a group.
+// Each intercompany transaction is booked in both entities' books at once:
+// the entity owed the amount records it in a due-from account and the
+// entity owing it in a due-to account. A matching report compares the two
+// sides of every entity pair so unmatched balances can be resolved before
+// the group is consolidated.
+package intercompany
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// DimensionID is the entry dimension holding the intercompany transaction
+// an entry was posted for
+const DimensionID = "intercompany_id"
+
+// Kind identifies the nature of an intercompany transaction
+type Kind string
+
+const (
+	// Charge bills the target for goods, services or costs borne by the
+	// source
+	Charge Kind = "CHARGE"
+	// Funding advances cash from the source to the target
+	Funding Kind = "FUNDING"
+	// Settlement pays back an amount the target owes the source
+	Settlement Kind = "SETTLEMENT"
+)
+
+// Relationship names the accounts an entity keeps its balances with a
+// counterparty in. Both directions of a pair must be registered.
+type Relationship struct {
+	EntityID       string `json:"entity_id"`
+	CounterpartyID string `json:"counterparty_id"`
+	// Asset account for amounts the counterparty owes the entity
+	DueFromAccountID string `json:"due_from_account_id"`
+	// Liability account for amounts the entity owes the counterparty
+	DueToAccountID string `json:"due_to_account_id"`
+}
+
+// Transaction represents an amount passed between two entities. The source
+// entity is owed the amount by the target, or for settlements receives it.
+type Transaction struct {
+	ID             string      `json:"id"`
+	Kind           Kind        `json:"kind"`
+	Date           time.Time   `json:"date"`
+	Description    string      `json:"description"`
+	Reference      string      `json:"reference,omitempty"`
+	SourceEntityID string      `json:"source_entity_id"`
+	TargetEntityID string      `json:"target_entity_id"`
+	Amount         money.Money `json:"amount"`
+	// Account in the source's books offsetting the due-from account, such
+	// as revenue for charges or cash for funding and settlements
+	SourceAccountID string `json:"source_account_id"`
+	// Account in the target's books offsetting the due-to account, such as
+	// an expense for charges or cash for funding and settlements
+	TargetAccountID string `json:"target_account_id"`
+	// Journal entries posted in each entity's books
+	SourceTransactionID string    `json:"source_transaction_id,omitempty"`
+	TargetTransactionID string    `json:"target_transaction_id,omitempty"`
+	Created             time.Time `json:"created"`
+}
+
+// GetID returns the intercompany transaction identifier
+func (t *Transaction) GetID() string { return t.ID }
+
+// PairBalance compares the balances two entities hold with each other
+type PairBalance struct {
+	EntityID       string `json:"entity_id"`
+	CounterpartyID string `json:"counterparty_id"`
+	// Net amount the counterparty owes the entity in the entity's books;
+	// negative when the entity owes the counterparty
+	Balance money.Money `json:"balance"`
+	// Net amount the entity owes the counterparty in the counterparty's
+	// books, with the same sign convention
+	CounterpartyBalance money.Money `json:"counterparty_balance"`
+	// Sum of both balances, zero when the books agree
+	Difference money.Money `json:"difference"`
+	Matched    bool        `json:"matched"`
+}
+
+// MatchingReport lists the intercompany balances of every entity pair
+type MatchingReport struct {
+	AsOf     time.Time     `json:"as_of"`
+	Currency string        `json:"currency"`
+	Pairs    []PairBalance `json:"pairs"`
+}
+
+// Unmatched returns the pairs whose balances do not agree
+func (r *MatchingReport) Unmatched() []PairBalance {
+	unmatched := make([]PairBalance, 0)
+	for _, pair := range r.Pairs {
+		if !pair.Matched {
+			unmatched = append(unmatched, pair)
+		}
+	}
+	return unmatched
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrItemNotFound         = errors.New("inventory item not found")
+	ErrInvalidItem          = errors.New("invalid inventory item")
+	ErrInvalidQuantity      = errors.New("invalid quantity")
+	ErrInsufficientQuantity = errors.New("insufficient quantity on hand")
+	ErrUnknownMethod        = errors.New("unknown costing method")
+)
+
+// Receive adds stock to the item's cost layers. Weighted-average items keep a
+// single layer whose unit cost is re-averaged on every receipt.
+func (i *Item) Receive(quantity, unitCost decimal.Decimal, at time.Time, reference string) error {
+	if !quantity.IsPositive() {
+		return fmt.Errorf("%w: receipt quantity must be positive", ErrInvalidQuantity)
+	}
+	if unitCost.IsNegative() {
+		return fmt.Errorf("%w: unit cost cannot be negative", ErrInvalidQuantity)
+	}
+
+	layer := CostLayer{ReceivedAt: at, Quantity: quantity, UnitCost: unitCost, Reference: reference}
+
+	switch i.Method {
+	case FIFO, LIFO:
+		i.Layers = append(i.Layers, layer)
+	case WeightedAverage:
+		if len(i.Layers) == 0 {
+			i.Layers = []CostLayer{layer}
+			return nil
+		}
+		current := i.Layers[0]
+		totalQty := current.Quantity.Add(quantity)
+		totalValue := current.Value().Add(layer.Value())
+		i.Layers = []CostLayer{{
+			ReceivedAt: at,
+			Quantity:   totalQty,
+			UnitCost:   totalValue.Div(totalQty),
+			Reference:  reference,
+		}}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownMethod, i.Method)
+	}
+	return nil
+}
+
+// Issue removes stock from the item's cost layers and returns the cost of
+// the issued quantity, rounded to the minor units of the item's currency.
+// FIFO consumes the oldest layers first, LIFO the newest, and weighted
+// average uses the running average cost.
+func (i *Item) Issue(quantity decimal.Decimal) (decimal.Decimal, error) {
+	if !quantity.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: issue quantity must be positive", ErrInvalidQuantity)
+	}
+	if quantity.GreaterThan(i.Quantity()) {
+		return decimal.Zero, fmt.Errorf("%w: requested %s, on hand %s", ErrInsufficientQuantity, quantity, i.Quantity())
+	}
+
+	before := i.Valuation().Amount
+	remaining := quantity
+
+	switch i.Method {
+	case FIFO:
+		for len(i.Layers) > 0 && remaining.IsPositive() {
+			remaining = consume(&i.Layers[0], remaining)
+			if i.Layers[0].Quantity.IsZero() {
+				i.Layers = i.Layers[1:]
+			}
+		}
+	case LIFO:
+		for len(i.Layers) > 0 && remaining.IsPositive() {
+			last := len(i.Layers) - 1
+			remaining = consume(&i.Layers[last], remaining)
+			if i.Layers[last].Quantity.IsZero() {
+				i.Layers = i.Layers[:last]
+			}
+		}
+	case WeightedAverage:
+		consume(&i.Layers[0], remaining)
+		if i.Layers[0].Quantity.IsZero() {
+			i.Layers = nil
+		}
+	default:
+		return decimal.Zero, fmt.Errorf("%w: %s", ErrUnknownMethod, i.Method)
+	}
+
+	// Cost is the change in rounded valuation so the inventory account
+	// always equals the valuation of the remaining layers
+	return before.Sub(i.Valuation().Amount), nil
+}
+
+// consume takes up to quantity from a layer and returns the unfilled quantity
+func consume(layer *CostLayer, quantity decimal.Decimal) decimal.Decimal {
+	taken := decimal.Min(layer.Quantity, quantity)
+	layer.Quantity = layer.Quantity.Sub(taken)
+	return quantity.Sub(taken)
+}
@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// CostingEngine defines the interface for inventory costing operations
+type CostingEngine interface {
+	// CreateItem registers a new stock item
+	CreateItem(ctx context.Context, item *Item) error
+
+	// GetItem retrieves an item by ID
+	GetItem(ctx context.Context, id string) (*Item, error)
+
+	// RecordReceipt adds stock at a unit cost and posts the inventory entry
+	// against the offset account (e.g., payables or goods received not invoiced)
+	RecordReceipt(ctx context.Context, itemID string, quantity, unitCost decimal.Decimal, offsetAccountID string, at time.Time, reference string) (*Movement, error)
+
+	// RecordIssue removes stock and posts the cost of goods sold entry
+	RecordIssue(ctx context.Context, itemID string, quantity decimal.Decimal, at time.Time, reference string) (*Movement, error)
+}
+
+// BasicCostingEngine provides a storage-backed implementation of CostingEngine
+type BasicCostingEngine struct {
+	repo      storage.Repository
+	processor transaction.TransactionProcessor
+}
+
+// NewBasicCostingEngine creates a new BasicCostingEngine
+func NewBasicCostingEngine(repo storage.Repository, processor transaction.TransactionProcessor) *BasicCostingEngine {
+	return &BasicCostingEngine{
+		repo:      repo,
+		processor: processor,
+	}
+}
+
+// CreateItem implements CostingEngine.CreateItem
+func (e *BasicCostingEngine) CreateItem(ctx context.Context, item *Item) error {
+	if item == nil || item.ID == "" {
+		return fmt.Errorf("%w: item ID is required", ErrInvalidItem)
+	}
+	if item.InventoryAccountID == "" || item.COGSAccountID == "" {
+		return fmt.Errorf("%w: inventory and COGS accounts are required", ErrInvalidItem)
+	}
+	switch item.Method {
+	case FIFO, LIFO, WeightedAverage:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownMethod, item.Method)
+	}
+
+	now := time.Now()
+	item.Created = now
+	item.LastModified = now
+	if err := e.repo.Create(ctx, item); err != nil {
+		return fmt.Errorf("failed to store item: %w", err)
+	}
+	return nil
+}
+
+// GetItem implements CostingEngine.GetItem
+func (e *BasicCostingEngine) GetItem(ctx context.Context, id string) (*Item, error) {
+	var item Item
+	if err := e.repo.Read(ctx, id, &item); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrItemNotFound, id, err)
+	}
+	return &item, nil
+}
+
+// RecordReceipt implements CostingEngine.RecordReceipt
+func (e *BasicCostingEngine) RecordReceipt(ctx context.Context, itemID string, quantity, unitCost decimal.Decimal, offsetAccountID string, at time.Time, reference string) (*Movement, error) {
+	item, err := e.GetItem(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := item.Valuation().Amount
+	if err := item.Receive(quantity, unitCost, at, reference); err != nil {
+		return nil, err
+	}
+	cost := item.Valuation().Amount.Sub(before)
+
+	movement := newMovement(item, Receipt, quantity, cost, at, reference)
+	if cost.IsPositive() {
+		tx := buildEntry(movement, item.InventoryAccountID, offsetAccountID,
+			fmt.Sprintf("Receipt of %s %s", quantity, item.SKU))
+		if err := transaction.CreateAndPost(ctx, e.repo, e.processor, tx); err != nil {
+			return nil, fmt.Errorf("failed to post receipt entry: %w", err)
+		}
+		movement.TransactionID = tx.ID
+	}
+
+	if err := e.save(ctx, item, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// RecordIssue implements CostingEngine.RecordIssue
+func (e *BasicCostingEngine) RecordIssue(ctx context.Context, itemID string, quantity decimal.Decimal, at time.Time, reference string) (*Movement, error) {
+	item, err := e.GetItem(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	cost, err := item.Issue(quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	movement := newMovement(item, Issue, quantity, cost, at, reference)
+	if cost.IsPositive() {
+		tx := buildEntry(movement, item.COGSAccountID, item.InventoryAccountID,
+			fmt.Sprintf("Issue of %s %s", quantity, item.SKU))
+		if err := transaction.CreateAndPost(ctx, e.repo, e.processor, tx); err != nil {
+			return nil, fmt.Errorf("failed to post COGS entry: %w", err)
+		}
+		movement.TransactionID = tx.ID
+	}
+
+	if err := e.save(ctx, item, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (e *BasicCostingEngine) save(ctx context.Context, item *Item, movement *Movement) error {
+	item.LastModified = time.Now()
+	if err := e.repo.Update(ctx, item); err != nil {
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+	if err := e.repo.Create(ctx, movement); err != nil {
+		return fmt.Errorf("failed to store movement: %w", err)
+	}
+	return nil
+}
+
+func newMovement(item *Item, movementType MovementType, quantity, cost decimal.Decimal, at time.Time, reference string) *Movement {
+	return &Movement{
+		ID:        fmt.Sprintf("MOV_%d", time.Now().UnixNano()),
+		ItemID:    item.ID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Cost:      money.Money{Amount: cost, Currency: item.Currency},
+		Date:      at,
+		Reference: reference,
+	}
+}
+
+// buildEntry creates a two-line journal entry for a movement
+func buildEntry(movement *Movement, debitAccountID, creditAccountID, description string) *transaction.Transaction {
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:          fmt.Sprintf("TX-%s", movement.ID),
+		Type:        transaction.Journal,
+		Status:      transaction.Draft,
+		Date:        movement.Date,
+		Description: description,
+		Entries: []transaction.Entry{
+			{AccountID: debitAccountID, Amount: movement.Cost, Type: transaction.Debit, Description: description},
+			{AccountID: creditAccountID, Amount: movement.Cost, Type: transaction.Credit, Description: description},
+		},
+		Created:      now,
+		LastModified: now,
+	}
+}
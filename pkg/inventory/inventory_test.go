@@ -0,0 +1,140 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor records transactions instead of storing them
+type recordingProcessor struct {
+	transaction.TransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	tx.Status = transaction.Posted
+	p.posted = append(p.posted, tx)
+	return nil
+}
+
+func d(v float64) decimal.Decimal { return decimal.NewFromFloat(v) }
+
+func TestCostingMethods(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		method        CostingMethod
+		wantCost      string
+		wantValuation string
+	}{
+		// Receipts: 10 @ 5.00, 10 @ 7.00; issue 15
+		{method: FIFO, wantCost: "85", wantValuation: "35"},
+		{method: LIFO, wantCost: "95", wantValuation: "25"},
+		{method: WeightedAverage, wantCost: "90", wantValuation: "30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			item := &Item{ID: "ITEM", Method: tt.method, Currency: "USD"}
+			require.NoError(t, item.Receive(d(10), d(5), now, "R1"))
+			require.NoError(t, item.Receive(d(10), d(7), now, "R2"))
+			assert.Equal(t, "120", item.Valuation().Amount.String())
+
+			cost, err := item.Issue(d(15))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCost, cost.String())
+			assert.Equal(t, "5", item.Quantity().String())
+			assert.Equal(t, tt.wantValuation, item.Valuation().Amount.String())
+
+			_, err = item.Issue(d(6))
+			assert.ErrorIs(t, err, ErrInsufficientQuantity)
+		})
+	}
+}
+
+func TestWeightedAverageRounding(t *testing.T) {
+	item := &Item{ID: "ITEM", Method: WeightedAverage, Currency: "USD"}
+	require.NoError(t, item.Receive(d(3), d(10), time.Now(), "R1"))
+	require.NoError(t, item.Receive(d(3), d(10.01), time.Now(), "R2"))
+
+	total := decimal.Zero
+	for i := 0; i < 6; i++ {
+		cost, err := item.Issue(d(1))
+		require.NoError(t, err)
+		total = total.Add(cost)
+	}
+	assert.Equal(t, "60.03", total.String())
+	assert.True(t, item.Valuation().Amount.IsZero())
+}
+
+func TestValuationScale(t *testing.T) {
+	yen := &Item{ID: "ITEM", Method: WeightedAverage, Currency: "JPY"}
+	require.NoError(t, yen.Receive(d(3), d(100), time.Now(), "R1"))
+	require.NoError(t, yen.Receive(d(3), d(101), time.Now(), "R2"))
+	cost, err := yen.Issue(d(1))
+	require.NoError(t, err)
+	assert.Equal(t, "100", cost.String())
+	assert.Equal(t, "503", yen.Valuation().Amount.String())
+
+	dinar := &Item{ID: "ITEM", Method: FIFO, Currency: "KWD"}
+	require.NoError(t, dinar.Receive(d(3), d(1.2345), time.Now(), "R1"))
+	assert.Equal(t, "3.704", dinar.Valuation().Amount.String())
+}
+
+func TestBasicCostingEngine(t *testing.T) {
+	ctx := context.Background()
+	processor := &recordingProcessor{}
+	engine := NewBasicCostingEngine(memory.NewMemoryStore(), processor)
+
+	item := &Item{ID: "WIDGET", SKU: "W-1", Method: FIFO, Currency: "USD", InventoryAccountID: "1300", COGSAccountID: "5000"}
+	require.NoError(t, engine.CreateItem(ctx, item))
+
+	receipt, err := engine.RecordReceipt(ctx, "WIDGET", d(10), d(4), "2100", time.Now(), "PO-1")
+	require.NoError(t, err)
+	assert.Equal(t, "40", receipt.Cost.Amount.String())
+
+	issue, err := engine.RecordIssue(ctx, "WIDGET", d(4), time.Now(), "SO-1")
+	require.NoError(t, err)
+	assert.Equal(t, "16", issue.Cost.Amount.String())
+
+	require.Len(t, processor.posted, 2)
+	cogs := processor.posted[1]
+	assert.Equal(t, "5000", cogs.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, cogs.Entries[0].Type)
+	assert.Equal(t, "1300", cogs.Entries[1].AccountID)
+	assert.Equal(t, transaction.Credit, cogs.Entries[1].Type)
+
+	stored, err := engine.GetItem(ctx, "WIDGET")
+	require.NoError(t, err)
+	assert.Equal(t, "24", stored.Valuation().Amount.String())
+
+	err = engine.CreateItem(ctx, &Item{ID: "BAD", Method: "RANDOM", InventoryAccountID: "1300", COGSAccountID: "5000"})
+	assert.ErrorIs(t, err, ErrUnknownMethod)
+}
+
+func TestBasicCostingEngine_Posting(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+	engine := NewBasicCostingEngine(store, processor)
+
+	item := &Item{ID: "GADGET", SKU: "G-1", Method: WeightedAverage, Currency: "JPY", InventoryAccountID: "1300", COGSAccountID: "5000"}
+	require.NoError(t, engine.CreateItem(ctx, item))
+	receipt, err := engine.RecordReceipt(ctx, "GADGET", d(3), d(333.5), "2100", time.Now(), "PO-1")
+	require.NoError(t, err)
+	issue, err := engine.RecordIssue(ctx, "GADGET", d(1), time.Now(), "SO-1")
+	require.NoError(t, err)
+
+	for _, movement := range []*Movement{receipt, issue} {
+		tx, err := processor.GetTransaction(ctx, movement.TransactionID)
+		require.NoError(t, err)
+		assert.Equal(t, transaction.Posted, tx.Status)
+		assert.True(t, tx.Entries[0].Amount.ConformsToScale())
+	}
+}
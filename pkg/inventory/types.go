@@ -0,0 +1,93 @@
+package inventory
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// CostingMethod determines how issue costs are derived from cost layers
+type CostingMethod string
+
+const (
+	FIFO            CostingMethod = "FIFO"
+	LIFO            CostingMethod = "LIFO"
+	WeightedAverage CostingMethod = "WEIGHTED_AVERAGE"
+)
+
+// MovementType represents the direction of an inventory movement
+type MovementType string
+
+const (
+	Receipt MovementType = "RECEIPT"
+	Issue   MovementType = "ISSUE"
+)
+
+// CostLayer represents a quantity of stock received at a single unit cost
+type CostLayer struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	UnitCost   decimal.Decimal `json:"unit_cost"`
+	Reference  string          `json:"reference,omitempty"`
+}
+
+// Value returns the carrying value of the layer
+func (l CostLayer) Value() decimal.Decimal {
+	return l.Quantity.Mul(l.UnitCost)
+}
+
+// Item represents a stock item and its open cost layers
+type Item struct {
+	ID     string        `json:"id"`
+	SKU    string        `json:"sku"`
+	Name   string        `json:"name"`
+	Method CostingMethod `json:"method"`
+	// Currency all costs are expressed in
+	Currency string `json:"currency"`
+	// Balance sheet inventory account kept in sync with the layers
+	InventoryAccountID string `json:"inventory_account_id"`
+	// Expense account debited with the cost of issued stock
+	COGSAccountID string `json:"cogs_account_id"`
+	// Open cost layers, oldest first
+	Layers       []CostLayer `json:"layers"`
+	Created      time.Time   `json:"created"`
+	LastModified time.Time   `json:"last_modified"`
+}
+
+// GetID returns the item identifier
+func (i *Item) GetID() string { return i.ID }
+
+// Quantity returns the quantity on hand
+func (i *Item) Quantity() decimal.Decimal {
+	total := decimal.Zero
+	for _, layer := range i.Layers {
+		total = total.Add(layer.Quantity)
+	}
+	return total
+}
+
+// Valuation returns the carrying value of the stock on hand, rounded to the
+// minor units of the item's currency
+func (i *Item) Valuation() money.Money {
+	total := decimal.Zero
+	for _, layer := range i.Layers {
+		total = total.Add(layer.Value())
+	}
+	return money.Money{Amount: total, Currency: i.Currency}.RoundToCurrency()
+}
+
+// Movement records a receipt or issue of stock and its cost
+type Movement struct {
+	ID            string          `json:"id"`
+	ItemID        string          `json:"item_id"`
+	Type          MovementType    `json:"type"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	Cost          money.Money     `json:"cost"`
+	Date          time.Time       `json:"date"`
+	Reference     string          `json:"reference,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+}
+
+// GetID returns the movement identifier
+func (m *Movement) GetID() string { return m.ID }
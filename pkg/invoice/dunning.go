@@ -0,0 +1,142 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// DunningLevel describes a reminder stage reached after a number of days overdue
+type DunningLevel struct {
+	// Level number, starting at 1 for the first reminder
+	Level int `json:"level"`
+	// Human-readable name (e.g., "First reminder", "Final notice")
+	Name string `json:"name"`
+	// Days past the due date (after the grace period) at which this level applies
+	DaysOverdue int `json:"days_overdue"`
+	// Flat fee charged when this level is reached
+	Fee decimal.Decimal `json:"fee,omitempty"`
+}
+
+// LateFeeFunc calculates the late fee for an overdue invoice at a dunning level
+type LateFeeFunc func(inv *Invoice, daysOverdue int, level DunningLevel) money.Money
+
+// DunningPolicy configures how overdue invoices are escalated
+type DunningPolicy struct {
+	// Days after the due date before an invoice is considered overdue
+	GracePeriodDays int
+	// Reminder levels; evaluated in ascending DaysOverdue order
+	Levels []DunningLevel
+	// Optional hook for late fee calculation; defaults to the level's flat fee
+	LateFee LateFeeFunc
+}
+
+// OverdueItem contains the data needed to send a reminder for an invoice
+type OverdueItem struct {
+	Invoice     *Invoice     `json:"invoice"`
+	DaysOverdue int          `json:"days_overdue"`
+	Level       DunningLevel `json:"level"`
+	OpenBalance money.Money  `json:"open_balance"`
+	LateFee     money.Money  `json:"late_fee"`
+	// Whether the level is higher than the last reminder sent
+	Escalated bool `json:"escalated"`
+}
+
+// ListOverdueInvoices implements Manager.ListOverdueInvoices
+func (m *BasicManager) ListOverdueInvoices(ctx context.Context, asOf time.Time, policy DunningPolicy) ([]OverdueItem, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "type", Operator: "=", Value: StandardInvoice},
+			{Field: "status", Operator: "in", Value: []InvoiceStatus{Issued, PartiallyPaid}},
+			{Field: "due_date", Operator: "<", Value: asOf},
+		},
+	}
+
+	var invoices []*Invoice
+	if err := m.repo.Query(ctx, query, &invoices); err != nil {
+		return nil, fmt.Errorf("error querying invoices: %w", err)
+	}
+
+	return EvaluateDunning(invoices, asOf, policy), nil
+}
+
+// RecordDunning implements Manager.RecordDunning
+func (m *BasicManager) RecordDunning(ctx context.Context, invoiceID string, level int, at time.Time) error {
+	inv, err := m.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if !inv.IsOpen() {
+		return fmt.Errorf("%w: invoice %s is not open", ErrInvalidStatus, invoiceID)
+	}
+
+	inv.DunningLevel = level
+	inv.LastDunnedAt = &at
+	inv.LastModified = time.Now()
+	if err := m.repo.Update(ctx, inv); err != nil {
+		return fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return nil
+}
+
+// EvaluateDunning determines the dunning level and late fee for each open
+// invoice that is past its due date plus the grace period as of a date.
+// Results are ordered by days overdue, most overdue first.
+func EvaluateDunning(invoices []*Invoice, asOf time.Time, policy DunningPolicy) []OverdueItem {
+	levels := append([]DunningLevel(nil), policy.Levels...)
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].DaysOverdue < levels[j].DaysOverdue
+	})
+
+	items := make([]OverdueItem, 0)
+	for _, inv := range invoices {
+		if !inv.IsOpen() || inv.DueDate == nil {
+			continue
+		}
+
+		daysOverdue := int(asOf.Sub(*inv.DueDate).Hours()/24) - policy.GracePeriodDays
+		if daysOverdue <= 0 {
+			continue
+		}
+
+		level := DunningLevel{}
+		for _, l := range levels {
+			if daysOverdue >= l.DaysOverdue {
+				level = l
+			}
+		}
+
+		fee := money.Money{Amount: level.Fee, Currency: inv.Currency}
+		if policy.LateFee != nil {
+			fee = policy.LateFee(inv, daysOverdue, level)
+		}
+
+		items = append(items, OverdueItem{
+			Invoice:     inv,
+			DaysOverdue: daysOverdue,
+			Level:       level,
+			OpenBalance: inv.OpenBalance(),
+			LateFee:     fee,
+			Escalated:   level.Level > inv.DunningLevel,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DaysOverdue > items[j].DaysOverdue
+	})
+	return items
+}
+
+// PercentageLateFee returns a LateFeeFunc charging a percentage of the open
+// balance per level, rounded to cents
+func PercentageLateFee(rate decimal.Decimal) LateFeeFunc {
+	return func(inv *Invoice, daysOverdue int, level DunningLevel) money.Money {
+		open := inv.OpenBalance()
+		return money.Money{Amount: open.Amount.Mul(rate).Round(2), Currency: open.Currency}
+	}
+}
@@ -0,0 +1,147 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// FXConfig enables realized exchange gains and losses on invoices in a
+// foreign currency
+type FXConfig struct {
+	// Currency gains and losses are measured and posted in
+	BaseCurrency string
+	// Rates used when an invoice or payment does not carry its own
+	Rates         money.ExchangeRateProvider
+	GainAccountID string
+	LossAccountID string
+	// Base currency account carrying the exchange adjustment of the
+	// receivable. It is required: the receivable account itself holds
+	// the invoice currency.
+	AdjustmentAccountID string
+}
+
+// SetFX enables realized exchange gain and loss accounting. Foreign
+// invoices record the rate they were issued at, and payments settling them
+// at another rate post the difference.
+func (m *BasicManager) SetFX(cfg FXConfig) error {
+	switch {
+	case cfg.BaseCurrency == "":
+		return fmt.Errorf("%w: FX needs a base currency", ErrInvalidInvoice)
+	case cfg.GainAccountID == "" || cfg.LossAccountID == "":
+		return fmt.Errorf("%w: FX needs gain and loss accounts", ErrInvalidInvoice)
+	case cfg.AdjustmentAccountID == "":
+		return fmt.Errorf("%w: FX needs a base currency adjustment account", ErrInvalidInvoice)
+	}
+	m.fx = &cfg
+	return nil
+}
+
+// foreign returns true if a currency is not the base currency
+func (m *BasicManager) foreign(currency string) bool {
+	return m.fx != nil && currency != m.fx.BaseCurrency
+}
+
+// rate returns a given rate to the base currency, or looks one up
+func (m *BasicManager) rate(ctx context.Context, given decimal.Decimal, currency string, date time.Time) (decimal.Decimal, error) {
+	if given.IsPositive() {
+		return given, nil
+	}
+	rate, err := m.fx.Rates.Rate(ctx, currency, m.fx.BaseCurrency, date)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error looking up %s/%s rate: %w", currency, m.fx.BaseCurrency, err)
+	}
+	return rate, nil
+}
+
+// buildFXEntry posts the exchange difference of each invoice a payment
+// settles, as the difference between the base currency values of the
+// application at the payment's and the invoice's rates. It returns the gain
+// or loss per application, and nil when there is none.
+func (m *BasicManager) buildFXEntry(ctx context.Context, payment *Payment, invoices []*Invoice) (*transaction.Transaction, []money.Money, error) {
+	settled, err := m.rate(ctx, payment.ExchangeRate, payment.Amount.Currency, payment.Date)
+	if err != nil {
+		return nil, nil, err
+	}
+	payment.ExchangeRate = settled
+
+	base := m.fx.BaseCurrency
+	differences := make([]money.Money, len(payment.Applications))
+	// Net amounts per account, debits positive, as the validator allows
+	// each account only once per transaction
+	balances := make(map[string]decimal.Decimal)
+	parties := make(map[string]string)
+	order := make([]string, 0)
+	post := func(accountID string, amount decimal.Decimal, partyID string) {
+		if _, ok := balances[accountID]; !ok {
+			order = append(order, accountID)
+			parties[accountID] = partyID
+		}
+		balances[accountID] = balances[accountID].Add(amount)
+	}
+	for i, app := range payment.Applications {
+		inv := invoices[i]
+		booked, err := m.rate(ctx, inv.ExchangeRate, inv.Currency, *inv.IssueDate)
+		if err != nil {
+			return nil, nil, err
+		}
+		difference := money.RealizedDifference(app.Amount, booked, settled, base)
+		differences[i] = difference
+		if difference.IsZero() {
+			continue
+		}
+
+		// Gains increase the receivable and losses reduce it
+		post(m.fx.AdjustmentAccountID, difference.Amount, inv.CustomerID)
+		if difference.IsPositive() {
+			post(m.fx.GainAccountID, difference.Amount.Neg(), "")
+		} else {
+			post(m.fx.LossAccountID, difference.Amount.Neg(), "")
+		}
+	}
+
+	entries := make([]transaction.Entry, 0, len(order))
+	for _, accountID := range order {
+		amount := balances[accountID]
+		if amount.IsZero() {
+			continue
+		}
+		entry := transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: amount.Abs(), Currency: base},
+			Type:        transaction.Debit,
+			Description: fmt.Sprintf("Exchange difference on payment %s", payment.ID),
+			PartyID:     parties[accountID],
+		}
+		if amount.IsNegative() {
+			entry.Type = transaction.Credit
+		}
+		switch accountID {
+		case m.fx.GainAccountID:
+			entry.Description = fmt.Sprintf("Realized exchange gain on payment %s", payment.ID)
+		case m.fx.LossAccountID:
+			entry.Description = fmt.Sprintf("Realized exchange loss on payment %s", payment.ID)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, differences, nil
+	}
+
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s-FX", payment.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         payment.Date,
+		Description:  fmt.Sprintf("Realized exchange difference on payment %s", payment.ID),
+		Reference:    payment.ID,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}, differences, nil
+}
@@ -0,0 +1,359 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvoiceNotFound     = errors.New("invoice not found")
+	ErrInvalidInvoice      = errors.New("invalid invoice")
+	ErrInvalidStatus       = errors.New("invalid invoice status")
+	ErrCreditExceedsAmount = errors.New("credit note exceeds invoice amount")
+	ErrInvalidPayment      = errors.New("invalid payment")
+	ErrOverApplication     = errors.New("application exceeds open balance")
+)
+
+// NumberGenerator assigns document numbers to issued invoices
+type NumberGenerator interface {
+	// NextNumber returns the next number in the sequence for a document type
+	NextNumber(ctx context.Context, invoiceType InvoiceType) (string, error)
+}
+
+// SequentialNumberGenerator numbers documents from a sequence per entity
+// and document type. With a transaction.StoreSequenceProvider on the
+// repository invoices are posted to, numbering continues across restarts,
+// and on a storage.TransactionManager an issue that fails gives its
+// number back, so the numbers are gap-free.
+type SequentialNumberGenerator struct {
+	sequences transaction.SequenceProvider
+	prefixes  map[InvoiceType]string
+}
+
+// NewSequentialNumberGenerator creates a generator using "INV-" and "CN-"
+// prefixes. A nil provider keeps the sequences in memory, so numbering
+// starts over at 1 with every process.
+func NewSequentialNumberGenerator(sequences transaction.SequenceProvider) *SequentialNumberGenerator {
+	if sequences == nil {
+		sequences = transaction.NewMemorySequenceProvider()
+	}
+	return &SequentialNumberGenerator{
+		sequences: sequences,
+		prefixes: map[InvoiceType]string{
+			StandardInvoice: "INV-",
+			CreditNote:      "CN-",
+		},
+	}
+}
+
+// NextNumber implements NumberGenerator.NextNumber
+func (g *SequentialNumberGenerator) NextNumber(ctx context.Context, invoiceType InvoiceType) (string, error) {
+	entityID, _ := entity.FromContext(ctx)
+	n, err := g.sequences.Next(ctx, transaction.SequenceKey{EntityID: entityID, JournalID: "invoice/" + string(invoiceType)})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%06d", g.prefixes[invoiceType], n), nil
+}
+
+// Manager defines the interface for invoice operations
+type Manager interface {
+	// CreateInvoice stores a new draft invoice
+	CreateInvoice(ctx context.Context, inv *Invoice) error
+
+	// GetInvoice retrieves an invoice by ID
+	GetInvoice(ctx context.Context, id string) (*Invoice, error)
+
+	// IssueInvoice numbers a draft invoice and posts its journal entry
+	IssueInvoice(ctx context.Context, id string, issueDate time.Time) (*Invoice, error)
+
+	// CreateCreditNote issues a credit note against an issued invoice
+	CreateCreditNote(ctx context.Context, invoiceID string, lines []Line, reason string) (*Invoice, error)
+
+	// ListOpenInvoices retrieves issued invoices with an open balance for a customer
+	ListOpenInvoices(ctx context.Context, customerID string) ([]*Invoice, error)
+
+	// ApplyPayment applies a received payment to open invoices and posts the settlement
+	ApplyPayment(ctx context.Context, payment *Payment, applications []Application) (*Payment, error)
+
+	// ListOverdueInvoices evaluates open invoices against a dunning policy
+	ListOverdueInvoices(ctx context.Context, asOf time.Time, policy DunningPolicy) ([]OverdueItem, error)
+
+	// RecordDunning records that a reminder at the given level was sent
+	RecordDunning(ctx context.Context, invoiceID string, level int, at time.Time) error
+}
+
+// BasicManager provides a storage-backed implementation of Manager
+type BasicManager struct {
+	repo      storage.Repository
+	processor transaction.TransactionProcessor
+	numbers   NumberGenerator
+	// Optional realized exchange gain and loss accounting
+	fx *FXConfig
+}
+
+// NewBasicManager creates a new BasicManager. A nil number generator
+// numbers invoices sequentially from sequences stored in repo.
+func NewBasicManager(repo storage.Repository, processor transaction.TransactionProcessor, numbers NumberGenerator) *BasicManager {
+	if numbers == nil {
+		numbers = NewSequentialNumberGenerator(transaction.NewStoreSequenceProvider(repo))
+	}
+	return &BasicManager{
+		repo:      repo,
+		processor: processor,
+		numbers:   numbers,
+	}
+}
+
+// CreateInvoice implements Manager.CreateInvoice
+func (m *BasicManager) CreateInvoice(ctx context.Context, inv *Invoice) error {
+	if err := validateInvoice(inv); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if inv.ID == "" {
+		inv.ID = fmt.Sprintf("INV_%d", now.UnixNano())
+	}
+	if inv.Type == "" {
+		inv.Type = StandardInvoice
+	}
+	inv.Status = Draft
+	inv.Created = now
+	inv.LastModified = now
+
+	if err := m.repo.Create(ctx, inv); err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	return nil
+}
+
+// GetInvoice implements Manager.GetInvoice
+func (m *BasicManager) GetInvoice(ctx context.Context, id string) (*Invoice, error) {
+	var inv Invoice
+	if err := m.repo.Read(ctx, id, &inv); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvoiceNotFound, id, err)
+	}
+	return &inv, nil
+}
+
+// IssueInvoice implements Manager.IssueInvoice
+func (m *BasicManager) IssueInvoice(ctx context.Context, id string, issueDate time.Time) (*Invoice, error) {
+	inv, err := m.GetInvoice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status != Draft {
+		return nil, fmt.Errorf("%w: only draft invoices can be issued", ErrInvalidStatus)
+	}
+
+	if err := m.issue(ctx, inv, issueDate); err != nil {
+		return nil, err
+	}
+	if err := m.repo.Update(ctx, inv); err != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return inv, nil
+}
+
+// CreateCreditNote implements Manager.CreateCreditNote. When lines is empty
+// the credit note reverses the full invoice.
+func (m *BasicManager) CreateCreditNote(ctx context.Context, invoiceID string, lines []Line, reason string) (*Invoice, error) {
+	orig, err := m.GetInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if !orig.IsOpen() {
+		return nil, fmt.Errorf("%w: credit notes require an open invoice", ErrInvalidStatus)
+	}
+	if len(lines) == 0 {
+		lines = append([]Line(nil), orig.Lines...)
+	}
+
+	note := &Invoice{
+		Type:                CreditNote,
+		CustomerID:          orig.CustomerID,
+		Currency:            orig.Currency,
+		Lines:               lines,
+		ReceivableAccountID: orig.ReceivableAccountID,
+		OriginalInvoiceID:   orig.ID,
+		Notes:               reason,
+	}
+	if note.Total().Amount.GreaterThan(orig.OpenBalance().Amount) {
+		return nil, ErrCreditExceedsAmount
+	}
+
+	if err := m.CreateInvoice(ctx, note); err != nil {
+		return nil, err
+	}
+	if err := m.issue(ctx, note, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := m.repo.Update(ctx, note); err != nil {
+		return nil, fmt.Errorf("failed to update credit note: %w", err)
+	}
+
+	orig.AmountCredited = orig.AmountCredited.Add(note.Total().Amount)
+	orig.Settlements = append(orig.Settlements, Settlement{
+		Type:          SettledByCredit,
+		CreditNoteID:  note.ID,
+		Date:          *note.IssueDate,
+		Amount:        note.Total().Amount,
+		TransactionID: note.TransactionID,
+	})
+	orig.Status = settlementStatus(orig)
+	orig.LastModified = time.Now()
+	if err := m.repo.Update(ctx, orig); err != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return note, nil
+}
+
+// issue assigns the document number and dates and posts the journal entry.
+// The number is reserved in the same storage transaction as the posting
+// when the repository supports them.
+func (m *BasicManager) issue(ctx context.Context, inv *Invoice, issueDate time.Time) error {
+	manager, ok := m.repo.(storage.TransactionManager)
+	if !ok {
+		return m.numberAndPost(ctx, inv, issueDate)
+	}
+	return manager.WithTransaction(ctx, func(ctx context.Context) error {
+		return m.numberAndPost(ctx, inv, issueDate)
+	})
+}
+
+// numberAndPost assigns the document number and dates and posts the
+// journal entry
+func (m *BasicManager) numberAndPost(ctx context.Context, inv *Invoice, issueDate time.Time) error {
+	number, err := m.numbers.NextNumber(ctx, inv.Type)
+	if err != nil {
+		return fmt.Errorf("failed to assign invoice number: %w", err)
+	}
+	dueDate := issueDate.AddDate(0, 0, inv.Terms.NetDays)
+
+	inv.Number = number
+	inv.IssueDate = &issueDate
+	inv.DueDate = &dueDate
+	if m.foreign(inv.Currency) {
+		if inv.ExchangeRate, err = m.rate(ctx, inv.ExchangeRate, inv.Currency, issueDate); err != nil {
+			return err
+		}
+	}
+
+	tx, err := BuildJournalEntry(inv)
+	if err != nil {
+		return err
+	}
+	if err := transaction.CreateAndPost(ctx, m.repo, m.processor, tx); err != nil {
+		return fmt.Errorf("failed to post invoice journal entry: %w", err)
+	}
+
+	inv.TransactionID = tx.ID
+	inv.Status = Issued
+	inv.LastModified = time.Now()
+	return nil
+}
+
+// BuildJournalEntry creates the AR, revenue and tax entries for an invoice.
+// Invoices debit receivables and credit revenue and tax; credit notes do the
+// reverse. Amounts are aggregated per account.
+func BuildJournalEntry(inv *Invoice) (*transaction.Transaction, error) {
+	if err := validateInvoice(inv); err != nil {
+		return nil, err
+	}
+
+	credits := make(map[string]decimal.Decimal)
+	order := make([]string, 0)
+	addCredit := func(accountID string, amount decimal.Decimal) {
+		if amount.IsZero() {
+			return
+		}
+		if _, ok := credits[accountID]; !ok {
+			order = append(order, accountID)
+		}
+		credits[accountID] = credits[accountID].Add(amount)
+	}
+	for _, line := range inv.Lines {
+		addCredit(line.RevenueAccountID, line.NetAmount().Amount)
+		if line.Tax != nil {
+			addCredit(line.Tax.AccountID, line.TaxAmount().Amount)
+		}
+	}
+
+	receivableType, incomeType := transaction.Debit, transaction.Credit
+	if inv.Type == CreditNote {
+		receivableType, incomeType = transaction.Credit, transaction.Debit
+	}
+
+	total := inv.Total()
+	entries := []transaction.Entry{{
+		AccountID:   inv.ReceivableAccountID,
+		Amount:      total,
+		Type:        receivableType,
+		Description: fmt.Sprintf("Receivable %s", inv.Number),
+		PartyID:     inv.CustomerID,
+	}}
+	for _, accountID := range order {
+		entries = append(entries, transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: credits[accountID], Currency: inv.Currency},
+			Type:        incomeType,
+			Description: fmt.Sprintf("%s %s", inv.Type, inv.Number),
+		})
+	}
+
+	date := time.Now()
+	if inv.IssueDate != nil {
+		date = *inv.IssueDate
+	}
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", inv.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         date,
+		Description:  fmt.Sprintf("%s %s for customer %s", inv.Type, inv.Number, inv.CustomerID),
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}, nil
+}
+
+func validateInvoice(inv *Invoice) error {
+	if inv == nil {
+		return fmt.Errorf("%w: invoice cannot be nil", ErrInvalidInvoice)
+	}
+	if inv.CustomerID == "" {
+		return fmt.Errorf("%w: customer is required", ErrInvalidInvoice)
+	}
+	if inv.ReceivableAccountID == "" {
+		return fmt.Errorf("%w: receivable account is required", ErrInvalidInvoice)
+	}
+	if len(inv.Lines) == 0 {
+		return fmt.Errorf("%w: at least one line is required", ErrInvalidInvoice)
+	}
+	for i, line := range inv.Lines {
+		if line.RevenueAccountID == "" {
+			return fmt.Errorf("%w: line %d has no revenue account", ErrInvalidInvoice, i)
+		}
+		if line.UnitPrice.Currency != inv.Currency {
+			return fmt.Errorf("%w: line %d currency %s does not match invoice currency %s",
+				ErrInvalidInvoice, i, line.UnitPrice.Currency, inv.Currency)
+		}
+		if !line.Quantity.IsPositive() || line.UnitPrice.IsNegative() {
+			return fmt.Errorf("%w: line %d has an invalid quantity or price", ErrInvalidInvoice, i)
+		}
+		if line.Tax != nil && line.Tax.AccountID == "" {
+			return fmt.Errorf("%w: line %d tax has no account", ErrInvalidInvoice, i)
+		}
+	}
+	return nil
+}
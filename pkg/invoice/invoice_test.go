@@ -0,0 +1,474 @@
+package invoice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor validates and records transactions instead of storing them
+type recordingProcessor struct {
+	transaction.TransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	result, err := (&transaction.BasicValidator{}).Validate(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if !result.Valid {
+		return assert.AnError
+	}
+	tx.Status = transaction.Posted
+	p.posted = append(p.posted, tx)
+	return nil
+}
+
+// invoiceStore extends MemoryStore with a Query that returns every stored invoice
+type invoiceStore struct {
+	*memory.MemoryStore
+	ids []string
+}
+
+func newInvoiceStore() *invoiceStore {
+	return &invoiceStore{MemoryStore: memory.NewMemoryStore()}
+}
+
+func (s *invoiceStore) Create(ctx context.Context, entity interface{}) error {
+	if err := s.MemoryStore.Create(ctx, entity); err != nil {
+		return err
+	}
+	if inv, ok := entity.(*Invoice); ok {
+		s.ids = append(s.ids, inv.ID)
+	}
+	return nil
+}
+
+func (s *invoiceStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	out := results.(*[]*Invoice)
+	for _, id := range s.ids {
+		var inv Invoice
+		if err := s.Read(ctx, id, &inv); err != nil {
+			return err
+		}
+		*out = append(*out, &inv)
+	}
+	return nil
+}
+
+func usd(amount float64) money.Money {
+	return money.Money{Amount: decimal.NewFromFloat(amount), Currency: "USD"}
+}
+
+func newTestInvoice() *Invoice {
+	vat := &TaxCode{Code: "VAT10", Rate: decimal.NewFromFloat(0.1), AccountID: "2200"}
+	return &Invoice{
+		CustomerID:          "CUST001",
+		Currency:            "USD",
+		ReceivableAccountID: "1200",
+		Terms:               Terms{Code: "NET30", NetDays: 30},
+		Lines: []Line{
+			{Description: "Consulting", Quantity: decimal.NewFromInt(2), UnitPrice: usd(100), RevenueAccountID: "4000", Tax: vat},
+			{Description: "Support", Quantity: decimal.NewFromInt(1), UnitPrice: usd(50), RevenueAccountID: "4000", Tax: vat},
+			{Description: "Training", Quantity: decimal.NewFromInt(1), UnitPrice: usd(25), RevenueAccountID: "4100"},
+		},
+	}
+}
+
+func TestInvoiceTotals(t *testing.T) {
+	inv := newTestInvoice()
+	assert.Equal(t, "275", inv.Subtotal().Amount.String())
+	assert.Equal(t, "25", inv.TaxTotal().Amount.String())
+	assert.Equal(t, "300", inv.Total().Amount.String())
+}
+
+func TestLineRounding(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	manager := NewBasicManager(store, transaction.NewBasicTransactionProcessor(store), nil)
+	vat := &TaxCode{Code: "VAT10", Rate: decimal.NewFromFloat(0.1), AccountID: "2200"}
+
+	// A fractional quantity of a price in cents
+	fractional := &Invoice{
+		CustomerID:          "CUST001",
+		Currency:            "USD",
+		ReceivableAccountID: "1200",
+		Terms:               Terms{Code: "NET30", NetDays: 30},
+		Lines: []Line{
+			{Description: "Fuel", Quantity: decimal.RequireFromString("1.5"), UnitPrice: money.Money{Amount: decimal.RequireFromString("0.99"), Currency: "USD"}, RevenueAccountID: "4000", Tax: vat},
+		},
+	}
+	assert.True(t, fractional.Lines[0].NetAmount().ConformsToScale())
+	assert.True(t, fractional.Lines[0].TaxAmount().ConformsToScale())
+	require.NoError(t, manager.CreateInvoice(ctx, fractional))
+	_, err := manager.IssueInvoice(ctx, fractional.ID, time.Now())
+	require.NoError(t, err)
+
+	// A currency without minor units
+	yen := &Invoice{
+		CustomerID:          "CUST001",
+		Currency:            "JPY",
+		ReceivableAccountID: "1200",
+		Terms:               Terms{Code: "NET30", NetDays: 30},
+		Lines: []Line{
+			{Description: "Consulting", Quantity: decimal.NewFromInt(3), UnitPrice: money.Money{Amount: decimal.NewFromInt(333), Currency: "JPY"}, RevenueAccountID: "4000", Tax: vat},
+		},
+	}
+	assert.Equal(t, "999", yen.Subtotal().Amount.String())
+	assert.Equal(t, "100", yen.TaxTotal().Amount.String())
+	require.NoError(t, manager.CreateInvoice(ctx, yen))
+	issued, err := manager.IssueInvoice(ctx, yen.ID, time.Now())
+	require.NoError(t, err)
+	var tx transaction.Transaction
+	require.NoError(t, store.Read(ctx, issued.TransactionID, &tx))
+	assert.Equal(t, transaction.Posted, tx.Status)
+	assert.Equal(t, "1099", tx.Entries[0].Amount.Amount.String())
+}
+
+func TestBuildJournalEntry(t *testing.T) {
+	inv := newTestInvoice()
+	inv.ID = "INV1"
+
+	tx, err := BuildJournalEntry(inv)
+	require.NoError(t, err)
+	require.Len(t, tx.Entries, 4)
+
+	assert.Equal(t, "1200", tx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, tx.Entries[0].Type)
+	assert.Equal(t, "300", tx.Entries[0].Amount.Amount.String())
+
+	amounts := make(map[string]string)
+	for _, entry := range tx.Entries[1:] {
+		assert.Equal(t, transaction.Credit, entry.Type)
+		amounts[entry.AccountID] = entry.Amount.Amount.String()
+	}
+	assert.Equal(t, map[string]string{"4000": "250", "2200": "25", "4100": "25"}, amounts)
+
+	inv.Lines = nil
+	_, err = BuildJournalEntry(inv)
+	assert.ErrorIs(t, err, ErrInvalidInvoice)
+}
+
+func TestBasicManager(t *testing.T) {
+	ctx := context.Background()
+	processor := &recordingProcessor{}
+	manager := NewBasicManager(memory.NewMemoryStore(), processor, nil)
+
+	inv := newTestInvoice()
+	require.NoError(t, manager.CreateInvoice(ctx, inv))
+	assert.Equal(t, Draft, inv.Status)
+
+	issueDate := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("IssueInvoice", func(t *testing.T) {
+		issued, err := manager.IssueInvoice(ctx, inv.ID, issueDate)
+		require.NoError(t, err)
+		assert.Equal(t, Issued, issued.Status)
+		assert.Equal(t, "INV-000001", issued.Number)
+		assert.Equal(t, issueDate.AddDate(0, 0, 30), *issued.DueDate)
+		assert.NotEmpty(t, issued.TransactionID)
+		require.Len(t, processor.posted, 1)
+
+		_, err = manager.IssueInvoice(ctx, inv.ID, issueDate)
+		assert.ErrorIs(t, err, ErrInvalidStatus)
+	})
+
+	t.Run("CreateCreditNote", func(t *testing.T) {
+		partial := []Line{{Description: "Refund", Quantity: decimal.NewFromInt(1), UnitPrice: usd(25), RevenueAccountID: "4100"}}
+		note, err := manager.CreateCreditNote(ctx, inv.ID, partial, "training cancelled")
+		require.NoError(t, err)
+		assert.Equal(t, CreditNote, note.Type)
+		assert.Equal(t, "CN-000001", note.Number)
+		assert.Equal(t, inv.ID, note.OriginalInvoiceID)
+
+		tx := processor.posted[len(processor.posted)-1]
+		assert.Equal(t, transaction.Credit, tx.Entries[0].Type)
+		assert.Equal(t, transaction.Debit, tx.Entries[1].Type)
+
+		tooLarge := []Line{{Description: "Refund", Quantity: decimal.NewFromInt(10), UnitPrice: usd(100), RevenueAccountID: "4000"}}
+		_, err = manager.CreateCreditNote(ctx, inv.ID, tooLarge, "error")
+		assert.ErrorIs(t, err, ErrCreditExceedsAmount)
+	})
+
+	t.Run("GetInvoice not found", func(t *testing.T) {
+		_, err := manager.GetInvoice(ctx, "missing")
+		assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	})
+}
+
+func TestNumbering(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	issue := func() *Invoice {
+		manager := NewBasicManager(store, transaction.NewBasicTransactionProcessor(store), nil)
+		inv := newTestInvoice()
+		require.NoError(t, manager.CreateInvoice(ctx, inv))
+		issued, err := manager.IssueInvoice(ctx, inv.ID, time.Now())
+		require.NoError(t, err)
+		return issued
+	}
+
+	assert.Equal(t, "INV-000001", issue().Number)
+	// a new manager on the same store continues after the last issued number
+	assert.Equal(t, "INV-000002", issue().Number)
+}
+
+func TestBasicManager_Posting(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+	manager := NewBasicManager(store, processor, nil)
+
+	inv := newTestInvoice()
+	require.NoError(t, manager.CreateInvoice(ctx, inv))
+	issued, err := manager.IssueInvoice(ctx, inv.ID, time.Now())
+	require.NoError(t, err)
+	note, err := manager.CreateCreditNote(ctx, inv.ID, []Line{{Description: "Refund", Quantity: decimal.NewFromInt(1), UnitPrice: usd(25), RevenueAccountID: "4100"}}, "discount")
+	require.NoError(t, err)
+	payment, err := manager.ApplyPayment(ctx, &Payment{CustomerID: "CUST001", Amount: usd(100), CashAccountID: "1000", Date: time.Now()},
+		[]Application{{InvoiceID: inv.ID, Amount: usd(100)}})
+	require.NoError(t, err)
+
+	for _, id := range []string{issued.TransactionID, note.TransactionID, payment.TransactionID} {
+		tx, err := processor.GetTransaction(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, transaction.Posted, tx.Status)
+	}
+
+	settled, err := manager.GetInvoice(ctx, inv.ID)
+	require.NoError(t, err)
+	require.Len(t, settled.Settlements, 2)
+	assert.Equal(t, SettledByCredit, settled.Settlements[0].Type)
+	assert.Equal(t, note.ID, settled.Settlements[0].CreditNoteID)
+	assert.Equal(t, SettledByPayment, settled.Settlements[1].Type)
+	assert.Equal(t, payment.ID, settled.Settlements[1].PaymentID)
+	assert.True(t, settled.OpenBalanceAt(issued.IssueDate.AddDate(0, 0, -1)).IsZero())
+}
+
+func TestRealizedFX(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+	manager := NewBasicManager(store, processor, nil)
+	rates := money.NewRateTable()
+	rates.Set("EUR", "USD", decimal.RequireFromString("1.08"))
+	assert.ErrorIs(t, manager.SetFX(FXConfig{BaseCurrency: "USD", Rates: rates, GainAccountID: "7100", LossAccountID: "7200"}), ErrInvalidInvoice,
+		"the EUR receivable account cannot carry USD adjustments")
+	require.NoError(t, manager.SetFX(FXConfig{BaseCurrency: "USD", Rates: rates, GainAccountID: "7100", LossAccountID: "7200", AdjustmentAccountID: "1290"}))
+
+	eur := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "EUR"}
+	}
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	inv := &Invoice{
+		ID:                  "I1",
+		CustomerID:          "CUST001",
+		Currency:            "EUR",
+		ReceivableAccountID: "1200",
+		Lines:               []Line{{Description: "Services", Quantity: decimal.NewFromInt(1), UnitPrice: eur(1100), RevenueAccountID: "4000"}},
+	}
+	require.NoError(t, manager.CreateInvoice(ctx, inv))
+	issued, err := manager.IssueInvoice(ctx, "I1", jan)
+	require.NoError(t, err)
+	assert.Equal(t, "1.08", issued.ExchangeRate.String())
+
+	_, err = manager.ApplyPayment(ctx, &Payment{
+		ID:            "P1",
+		CustomerID:    "CUST001",
+		Date:          jan.AddDate(0, 1, 0),
+		Amount:        eur(550),
+		CashAccountID: "1000",
+		ExchangeRate:  decimal.RequireFromString("1.10"),
+	}, nil)
+	require.NoError(t, err)
+
+	fx, err := processor.GetTransaction(ctx, "TX-P1-FX")
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, fx.Status)
+	assert.Equal(t, "P1", fx.Reference)
+	require.Len(t, fx.Entries, 2)
+	assert.Equal(t, "1290", fx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, fx.Entries[0].Type)
+	assert.Equal(t, "7100", fx.Entries[1].AccountID)
+	assert.Equal(t, "11", fx.Entries[1].Amount.Amount.String())
+	assert.Equal(t, "USD", fx.Entries[1].Amount.Currency)
+
+	paid, err := manager.GetInvoice(ctx, "I1")
+	require.NoError(t, err)
+	require.Len(t, paid.Settlements, 1)
+	assert.Equal(t, "11", paid.Settlements[0].RealizedFX.Amount.String())
+	assert.Equal(t, "TX-P1-FX", paid.Settlements[0].FXTransactionID)
+
+	// Settling at the booked rate realizes nothing
+	payment, err := manager.ApplyPayment(ctx, &Payment{
+		ID:            "P2",
+		CustomerID:    "CUST001",
+		Date:          jan.AddDate(0, 2, 0),
+		Amount:        eur(550),
+		CashAccountID: "1000",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1.08", payment.ExchangeRate.String())
+	_, err = processor.GetTransaction(ctx, "TX-P2-FX")
+	assert.Error(t, err)
+}
+
+func TestMatchOpenItems(t *testing.T) {
+	due := func(day int) *time.Time {
+		d := time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	line := func(amount float64) []Line {
+		return []Line{{Quantity: decimal.NewFromInt(1), UnitPrice: usd(amount), RevenueAccountID: "4000"}}
+	}
+	invoices := []*Invoice{
+		{ID: "B", Type: StandardInvoice, Status: Issued, Currency: "USD", Lines: line(200), DueDate: due(20)},
+		{ID: "A", Type: StandardInvoice, Status: Issued, Currency: "USD", Lines: line(100), DueDate: due(10)},
+		{ID: "C", Type: StandardInvoice, Status: Paid, Currency: "USD", Lines: line(50), DueDate: due(1)},
+	}
+
+	t.Run("exact match", func(t *testing.T) {
+		apps := MatchOpenItems(usd(200), invoices)
+		require.Len(t, apps, 1)
+		assert.Equal(t, "B", apps[0].InvoiceID)
+	})
+
+	t.Run("oldest first", func(t *testing.T) {
+		apps := MatchOpenItems(usd(250), invoices)
+		require.Len(t, apps, 2)
+		assert.Equal(t, "A", apps[0].InvoiceID)
+		assert.Equal(t, "100", apps[0].Amount.Amount.String())
+		assert.Equal(t, "B", apps[1].InvoiceID)
+		assert.Equal(t, "150", apps[1].Amount.Amount.String())
+	})
+}
+
+func TestApplyPayment(t *testing.T) {
+	ctx := context.Background()
+	processor := &recordingProcessor{}
+	manager := NewBasicManager(newInvoiceStore(), processor, nil)
+
+	inv := newTestInvoice()
+	require.NoError(t, manager.CreateInvoice(ctx, inv))
+	_, err := manager.IssueInvoice(ctx, inv.ID, time.Now())
+	require.NoError(t, err)
+
+	t.Run("partial payment", func(t *testing.T) {
+		payment := &Payment{CustomerID: "CUST001", Amount: usd(100), CashAccountID: "1000", Date: time.Now()}
+		_, err := manager.ApplyPayment(ctx, payment, nil)
+		require.NoError(t, err)
+
+		updated, err := manager.GetInvoice(ctx, inv.ID)
+		require.NoError(t, err)
+		assert.Equal(t, PartiallyPaid, updated.Status)
+		assert.Equal(t, "200", updated.OpenBalance().Amount.String())
+	})
+
+	t.Run("over application", func(t *testing.T) {
+		payment := &Payment{CustomerID: "CUST001", Amount: usd(500), CashAccountID: "1000", Date: time.Now()}
+		_, err := manager.ApplyPayment(ctx, payment, []Application{{InvoiceID: inv.ID, Amount: usd(500)}})
+		assert.ErrorIs(t, err, ErrOverApplication)
+	})
+
+	t.Run("duplicate and foreign applications", func(t *testing.T) {
+		payment := &Payment{CustomerID: "CUST001", Amount: usd(300), CashAccountID: "1000", Date: time.Now()}
+		_, err := manager.ApplyPayment(ctx, payment, []Application{
+			{InvoiceID: inv.ID, Amount: usd(150)},
+			{InvoiceID: inv.ID, Amount: usd(150)},
+		})
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+
+		eur := money.Money{Amount: decimal.NewFromInt(100), Currency: "EUR"}
+		payment = &Payment{CustomerID: "CUST001", Amount: eur, CashAccountID: "1000", Date: time.Now()}
+		_, err = manager.ApplyPayment(ctx, payment, []Application{{InvoiceID: inv.ID, Amount: usd(100)}})
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+	})
+
+	t.Run("overpayment requires unapplied account", func(t *testing.T) {
+		payment := &Payment{CustomerID: "CUST001", Amount: usd(250), CashAccountID: "1000", Date: time.Now()}
+		_, err := manager.ApplyPayment(ctx, payment, nil)
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+	})
+
+	t.Run("overpayment to credit", func(t *testing.T) {
+		payment := &Payment{
+			CustomerID:         "CUST001",
+			Amount:             usd(250),
+			CashAccountID:      "1000",
+			UnappliedAccountID: "2300",
+			Date:               time.Now(),
+		}
+		result, err := manager.ApplyPayment(ctx, payment, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "50", result.Unapplied.Amount.String())
+
+		tx := processor.posted[len(processor.posted)-1]
+		require.Len(t, tx.Entries, 3)
+		assert.Equal(t, "2300", tx.Entries[2].AccountID)
+
+		updated, err := manager.GetInvoice(ctx, inv.ID)
+		require.NoError(t, err)
+		assert.Equal(t, Paid, updated.Status)
+		assert.True(t, updated.OpenBalance().IsZero())
+
+		open, err := manager.ListOpenInvoices(ctx, "CUST001")
+		require.NoError(t, err)
+		assert.Empty(t, open)
+	})
+}
+
+func TestEvaluateDunning(t *testing.T) {
+	asOf := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	dueDaysAgo := func(days int) *time.Time {
+		d := asOf.AddDate(0, 0, -days)
+		return &d
+	}
+	line := []Line{{Quantity: decimal.NewFromInt(1), UnitPrice: usd(1000), RevenueAccountID: "4000"}}
+	invoices := []*Invoice{
+		{ID: "A", Type: StandardInvoice, Status: Issued, Currency: "USD", Lines: line, DueDate: dueDaysAgo(3)},
+		{ID: "B", Type: StandardInvoice, Status: Issued, Currency: "USD", Lines: line, DueDate: dueDaysAgo(20)},
+		{ID: "C", Type: StandardInvoice, Status: PartiallyPaid, Currency: "USD", Lines: line, DueDate: dueDaysAgo(50),
+			AmountPaid: decimal.NewFromInt(400), DunningLevel: 2},
+		{ID: "D", Type: StandardInvoice, Status: Paid, Currency: "USD", Lines: line, DueDate: dueDaysAgo(90)},
+	}
+	policy := DunningPolicy{
+		GracePeriodDays: 5,
+		Levels: []DunningLevel{
+			{Level: 2, Name: "Second reminder", DaysOverdue: 30, Fee: decimal.NewFromInt(25)},
+			{Level: 1, Name: "First reminder", DaysOverdue: 1},
+		},
+	}
+
+	t.Run("levels and grace period", func(t *testing.T) {
+		items := EvaluateDunning(invoices, asOf, policy)
+		require.Len(t, items, 2)
+
+		assert.Equal(t, "C", items[0].Invoice.ID)
+		assert.Equal(t, 45, items[0].DaysOverdue)
+		assert.Equal(t, 2, items[0].Level.Level)
+		assert.Equal(t, "600", items[0].OpenBalance.Amount.String())
+		assert.Equal(t, "25", items[0].LateFee.Amount.String())
+		assert.False(t, items[0].Escalated)
+
+		assert.Equal(t, "B", items[1].Invoice.ID)
+		assert.Equal(t, 1, items[1].Level.Level)
+		assert.True(t, items[1].Escalated)
+	})
+
+	t.Run("late fee hook", func(t *testing.T) {
+		policy.LateFee = PercentageLateFee(decimal.NewFromFloat(0.015))
+		items := EvaluateDunning(invoices, asOf, policy)
+		require.Len(t, items, 2)
+		assert.Equal(t, "9", items[0].LateFee.Amount.String())
+		assert.Equal(t, "15", items[1].LateFee.Amount.String())
+	})
+}
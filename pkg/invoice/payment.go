@@ -0,0 +1,282 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// ListOpenInvoices implements Manager.ListOpenInvoices
+func (m *BasicManager) ListOpenInvoices(ctx context.Context, customerID string) ([]*Invoice, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "customer_id", Operator: "=", Value: customerID},
+			{Field: "type", Operator: "=", Value: StandardInvoice},
+			{Field: "status", Operator: "in", Value: []InvoiceStatus{Issued, PartiallyPaid}},
+		},
+		Sort: []storage.Sort{
+			{Field: "due_date", Desc: false},
+		},
+	}
+
+	var invoices []*Invoice
+	if err := m.repo.Query(ctx, query, &invoices); err != nil {
+		return nil, fmt.Errorf("error querying invoices: %w", err)
+	}
+
+	open := make([]*Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		if inv.CustomerID == customerID && inv.IsOpen() {
+			open = append(open, inv)
+		}
+	}
+	sortByDueDate(open)
+	return open, nil
+}
+
+// ApplyPayment implements Manager.ApplyPayment. When no applications are
+// given the payment is matched against the customer's open invoices using
+// MatchOpenItems. Any amount left after application is posted to the
+// payment's unapplied account as a customer credit. With FX accounting
+// enabled, settling foreign invoices at another rate than they were issued
+// at also posts the realized exchange gain or loss.
+func (m *BasicManager) ApplyPayment(ctx context.Context, payment *Payment, applications []Application) (*Payment, error) {
+	if err := validatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	if len(applications) == 0 {
+		open, err := m.ListOpenInvoices(ctx, payment.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		applications = MatchOpenItems(payment.Amount, open)
+	}
+
+	// Validate each application against the invoice open balance. An
+	// invoice may only be applied to once per payment, so each application
+	// is checked against the balance it actually settles.
+	invoices := make([]*Invoice, 0, len(applications))
+	applied := decimal.Zero
+	seen := make(map[string]bool)
+	for _, app := range applications {
+		if seen[app.InvoiceID] {
+			return nil, fmt.Errorf("%w: invoice %s is applied to twice", ErrInvalidPayment, app.InvoiceID)
+		}
+		seen[app.InvoiceID] = true
+		if app.Amount.Currency != payment.Amount.Currency {
+			return nil, fmt.Errorf("%w: application to invoice %s is in %s, not the payment currency %s",
+				ErrInvalidPayment, app.InvoiceID, app.Amount.Currency, payment.Amount.Currency)
+		}
+
+		inv, err := m.GetInvoice(ctx, app.InvoiceID)
+		if err != nil {
+			return nil, err
+		}
+		if inv.CustomerID != payment.CustomerID {
+			return nil, fmt.Errorf("%w: invoice %s belongs to another customer", ErrInvalidPayment, inv.ID)
+		}
+		if !inv.IsOpen() {
+			return nil, fmt.Errorf("%w: invoice %s is not open", ErrInvalidStatus, inv.ID)
+		}
+		if app.Amount.Currency != inv.Currency || !app.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: invalid application amount for invoice %s", ErrInvalidPayment, inv.ID)
+		}
+		if app.Amount.Amount.GreaterThan(inv.OpenBalance().Amount) {
+			return nil, fmt.Errorf("%w: invoice %s", ErrOverApplication, inv.ID)
+		}
+		applied = applied.Add(app.Amount.Amount)
+		invoices = append(invoices, inv)
+	}
+	if applied.GreaterThan(payment.Amount.Amount) {
+		return nil, fmt.Errorf("%w: applications exceed payment amount", ErrOverApplication)
+	}
+
+	unapplied := payment.Amount.Amount.Sub(applied)
+	if unapplied.IsPositive() && payment.UnappliedAccountID == "" {
+		return nil, fmt.Errorf("%w: unapplied account is required for overpayments", ErrInvalidPayment)
+	}
+
+	now := time.Now()
+	if payment.ID == "" {
+		payment.ID = fmt.Sprintf("PMT_%d", now.UnixNano())
+	}
+	payment.Applications = applications
+	payment.Unapplied = money.Money{Amount: unapplied, Currency: payment.Amount.Currency}
+	payment.Created = now
+
+	var fxTx *transaction.Transaction
+	var differences []money.Money
+	var err error
+	tx := buildSettlementEntry(payment, invoices)
+	if m.foreign(payment.Amount.Currency) {
+		// The settlement and its exchange difference are posted together
+		fxTx, differences, err = m.buildFXEntry(ctx, payment, invoices)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fxTx != nil {
+		err = transaction.CreateAndPostBatch(ctx, m.repo, m.processor, []*transaction.Transaction{tx, fxTx})
+	} else {
+		err = transaction.CreateAndPost(ctx, m.repo, m.processor, tx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to post settlement entry: %w", err)
+	}
+	payment.TransactionID = tx.ID
+
+	// Update per-invoice open balances
+	for i, inv := range invoices {
+		inv.AmountPaid = inv.AmountPaid.Add(applications[i].Amount.Amount)
+		settlement := Settlement{
+			Type:          SettledByPayment,
+			PaymentID:     payment.ID,
+			Date:          payment.Date,
+			Amount:        applications[i].Amount.Amount,
+			TransactionID: tx.ID,
+		}
+		if differences != nil && !differences[i].IsZero() {
+			settlement.RealizedFX = differences[i]
+			settlement.FXTransactionID = fxTx.ID
+		}
+		inv.Settlements = append(inv.Settlements, settlement)
+		inv.Status = settlementStatus(inv)
+		inv.LastModified = now
+		if err := m.repo.Update(ctx, inv); err != nil {
+			return nil, fmt.Errorf("failed to update invoice %s: %w", inv.ID, err)
+		}
+	}
+
+	if err := m.repo.Create(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to store payment: %w", err)
+	}
+	return payment, nil
+}
+
+// MatchOpenItems allocates an amount across open invoices. An invoice whose
+// open balance equals the amount exactly is preferred; otherwise invoices are
+// settled oldest due date first until the amount is exhausted.
+func MatchOpenItems(amount money.Money, invoices []*Invoice) []Application {
+	candidates := make([]*Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		if inv.IsOpen() && inv.Currency == amount.Currency {
+			candidates = append(candidates, inv)
+		}
+	}
+	sortByDueDate(candidates)
+
+	for _, inv := range candidates {
+		if inv.OpenBalance().Amount.Equal(amount.Amount) {
+			return []Application{{InvoiceID: inv.ID, Amount: inv.OpenBalance()}}
+		}
+	}
+
+	applications := make([]Application, 0)
+	remaining := amount.Amount
+	for _, inv := range candidates {
+		if !remaining.IsPositive() {
+			break
+		}
+		portion := decimal.Min(remaining, inv.OpenBalance().Amount)
+		applications = append(applications, Application{
+			InvoiceID: inv.ID,
+			Amount:    money.Money{Amount: portion, Currency: amount.Currency},
+		})
+		remaining = remaining.Sub(portion)
+	}
+	return applications
+}
+
+// buildSettlementEntry debits cash and credits receivables and customer credit
+func buildSettlementEntry(payment *Payment, invoices []*Invoice) *transaction.Transaction {
+	currency := payment.Amount.Currency
+	entries := []transaction.Entry{{
+		AccountID:   payment.CashAccountID,
+		Amount:      payment.Amount,
+		Type:        transaction.Debit,
+		Description: fmt.Sprintf("Payment %s", payment.Reference),
+	}}
+
+	receivables := make(map[string]decimal.Decimal)
+	order := make([]string, 0)
+	for i, inv := range invoices {
+		if _, ok := receivables[inv.ReceivableAccountID]; !ok {
+			order = append(order, inv.ReceivableAccountID)
+		}
+		receivables[inv.ReceivableAccountID] = receivables[inv.ReceivableAccountID].Add(payment.Applications[i].Amount.Amount)
+	}
+	for _, accountID := range order {
+		entries = append(entries, transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: receivables[accountID], Currency: currency},
+			Type:        transaction.Credit,
+			Description: fmt.Sprintf("Settlement from customer %s", payment.CustomerID),
+			PartyID:     payment.CustomerID,
+		})
+	}
+	if payment.Unapplied.IsPositive() {
+		entries = append(entries, transaction.Entry{
+			AccountID:   payment.UnappliedAccountID,
+			Amount:      payment.Unapplied,
+			Type:        transaction.Credit,
+			Description: fmt.Sprintf("Unapplied cash from customer %s", payment.CustomerID),
+			PartyID:     payment.CustomerID,
+		})
+	}
+
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", payment.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         payment.Date,
+		Description:  fmt.Sprintf("Customer payment %s from %s", payment.Reference, payment.CustomerID),
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+}
+
+// settlementStatus derives the status of an invoice from its open balance
+func settlementStatus(inv *Invoice) InvoiceStatus {
+	if !inv.OpenBalance().IsPositive() {
+		return Paid
+	}
+	if inv.AmountPaid.IsPositive() || inv.AmountCredited.IsPositive() {
+		return PartiallyPaid
+	}
+	return Issued
+}
+
+func sortByDueDate(invoices []*Invoice) {
+	sort.SliceStable(invoices, func(i, j int) bool {
+		a, b := invoices[i].DueDate, invoices[j].DueDate
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Before(*b)
+	})
+}
+
+func validatePayment(payment *Payment) error {
+	if payment == nil {
+		return fmt.Errorf("%w: payment cannot be nil", ErrInvalidPayment)
+	}
+	if payment.CustomerID == "" {
+		return fmt.Errorf("%w: customer is required", ErrInvalidPayment)
+	}
+	if payment.CashAccountID == "" {
+		return fmt.Errorf("%w: cash account is required", ErrInvalidPayment)
+	}
+	if !payment.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// InvoiceType distinguishes invoices from credit notes
+type InvoiceType string
+
+const (
+	StandardInvoice InvoiceType = "INVOICE"
+	CreditNote      InvoiceType = "CREDIT_NOTE"
+)
+
+// InvoiceStatus represents the lifecycle state of an invoice
+type InvoiceStatus string
+
+const (
+	Draft         InvoiceStatus = "DRAFT"
+	Issued        InvoiceStatus = "ISSUED"
+	PartiallyPaid InvoiceStatus = "PARTIALLY_PAID"
+	Paid          InvoiceStatus = "PAID"
+	Cancelled     InvoiceStatus = "CANCELLED"
+	WrittenOff    InvoiceStatus = "WRITTEN_OFF"
+)
+
+// SettlementType identifies how part of an invoice was settled
+type SettlementType string
+
+const (
+	SettledByPayment  SettlementType = "PAYMENT"
+	SettledByCredit   SettlementType = "CREDIT_NOTE"
+	SettledByWriteOff SettlementType = "WRITE_OFF"
+)
+
+// TaxCode describes a tax applied to an invoice line
+type TaxCode struct {
+	// Code identifying the tax (e.g., "VAT20")
+	Code string `json:"code"`
+	// Rate applied to the line net amount (e.g., 0.2 for 20%)
+	Rate decimal.Decimal `json:"rate"`
+	// Liability account the tax is posted to
+	AccountID string `json:"account_id"`
+}
+
+// Terms describes the payment terms of an invoice
+type Terms struct {
+	// Short code for the terms (e.g., "NET30")
+	Code string `json:"code"`
+	// Number of days after the issue date the invoice is due
+	NetDays int `json:"net_days"`
+	// Optional early payment discount rate
+	DiscountRate decimal.Decimal `json:"discount_rate,omitempty"`
+	// Number of days the early payment discount is available
+	DiscountDays int `json:"discount_days,omitempty"`
+}
+
+// Line represents a single billable line on an invoice
+type Line struct {
+	Description      string          `json:"description"`
+	Quantity         decimal.Decimal `json:"quantity"`
+	UnitPrice        money.Money     `json:"unit_price"`
+	RevenueAccountID string          `json:"revenue_account_id"`
+	Tax              *TaxCode        `json:"tax,omitempty"`
+}
+
+// NetAmount returns the line amount before tax, rounded to the minor units
+// of the line currency
+func (l Line) NetAmount() money.Money {
+	return l.UnitPrice.Multiply(l.Quantity).RoundToCurrency()
+}
+
+// TaxAmount returns the tax charged on the line, rounded to the minor units
+// of the line currency
+func (l Line) TaxAmount() money.Money {
+	if l.Tax == nil {
+		return money.Money{Amount: decimal.Zero, Currency: l.UnitPrice.Currency}
+	}
+	return l.NetAmount().Multiply(l.Tax.Rate).RoundToCurrency()
+}
+
+// Settlement records a payment, credit note or write-off applied to an
+// invoice
+type Settlement struct {
+	Type SettlementType `json:"type"`
+	// Payment or credit note the amount was settled by, if any
+	PaymentID     string          `json:"payment_id,omitempty"`
+	CreditNoteID  string          `json:"credit_note_id,omitempty"`
+	Date          time.Time       `json:"date"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID string          `json:"transaction_id"`
+	// Exchange gain, or loss when negative, realized in the base currency
+	// and the transaction it was posted in
+	RealizedFX      money.Money `json:"realized_fx,omitempty"`
+	FXTransactionID string      `json:"fx_transaction_id,omitempty"`
+}
+
+// Invoice represents a customer invoice or credit note. Invoices in a
+// foreign currency record the rate to the base currency they were issued
+// at, and every payment, credit note and write-off is kept as a settlement
+// so balances can be aged as of any date.
+type Invoice struct {
+	ID                  string          `json:"id"`
+	Number              string          `json:"number"`
+	Type                InvoiceType     `json:"type"`
+	Status              InvoiceStatus   `json:"status"`
+	CustomerID          string          `json:"customer_id"`
+	Currency            string          `json:"currency"`
+	Lines               []Line          `json:"lines"`
+	Terms               Terms           `json:"terms"`
+	ReceivableAccountID string          `json:"receivable_account_id"`
+	IssueDate           *time.Time      `json:"issue_date,omitempty"`
+	DueDate             *time.Time      `json:"due_date,omitempty"`
+	TransactionID       string          `json:"transaction_id,omitempty"`
+	AmountPaid          decimal.Decimal `json:"amount_paid"`
+	AmountCredited      decimal.Decimal `json:"amount_credited"`
+	AmountWrittenOff    decimal.Decimal `json:"amount_written_off"`
+	ExchangeRate        decimal.Decimal `json:"exchange_rate,omitempty"`
+	Settlements         []Settlement    `json:"settlements,omitempty"`
+	DunningLevel        int             `json:"dunning_level,omitempty"`
+	LastDunnedAt        *time.Time      `json:"last_dunned_at,omitempty"`
+	OriginalInvoiceID   string          `json:"original_invoice_id,omitempty"`
+	Notes               string          `json:"notes,omitempty"`
+	Created             time.Time       `json:"created"`
+	LastModified        time.Time       `json:"last_modified"`
+}
+
+// GetID returns the invoice identifier
+func (i *Invoice) GetID() string { return i.ID }
+
+// Subtotal returns the sum of all line net amounts
+func (i *Invoice) Subtotal() money.Money {
+	total := money.Money{Amount: decimal.Zero, Currency: i.Currency}
+	for _, line := range i.Lines {
+		total.Amount = total.Amount.Add(line.NetAmount().Amount)
+	}
+	return total
+}
+
+// TaxTotal returns the sum of all line tax amounts
+func (i *Invoice) TaxTotal() money.Money {
+	total := money.Money{Amount: decimal.Zero, Currency: i.Currency}
+	for _, line := range i.Lines {
+		total.Amount = total.Amount.Add(line.TaxAmount().Amount)
+	}
+	return total
+}
+
+// Total returns the gross amount of the invoice including tax
+func (i *Invoice) Total() money.Money {
+	return money.Money{
+		Amount:   i.Subtotal().Amount.Add(i.TaxTotal().Amount),
+		Currency: i.Currency,
+	}
+}
+
+// OpenBalance returns the amount still owed after payments, credit notes
+// and write-offs
+func (i *Invoice) OpenBalance() money.Money {
+	return money.Money{
+		Amount:   i.Total().Amount.Sub(i.AmountPaid).Sub(i.AmountCredited).Sub(i.AmountWrittenOff),
+		Currency: i.Currency,
+	}
+}
+
+// OpenBalanceAt returns the amount the customer owed at the end of a day,
+// counting only settlements made by then. Invoices not yet issued owe
+// nothing.
+func (i *Invoice) OpenBalanceAt(asOf time.Time) money.Money {
+	open := decimal.Zero
+	if i.IssueDate != nil && i.Status != Draft && i.Status != Cancelled && !i.IssueDate.After(asOf) {
+		open = i.Total().Amount
+		for _, s := range i.Settlements {
+			if !s.Date.After(asOf) {
+				open = open.Sub(s.Amount)
+			}
+		}
+	}
+	return money.Money{Amount: open, Currency: i.Currency}
+}
+
+// IsOpen returns true if the invoice is issued and still has a balance owing
+func (i *Invoice) IsOpen() bool {
+	if i.Type != StandardInvoice {
+		return false
+	}
+	if i.Status != Issued && i.Status != PartiallyPaid {
+		return false
+	}
+	return i.OpenBalance().IsPositive()
+}
+
+// Application allocates part of a payment to a single invoice
+type Application struct {
+	InvoiceID string      `json:"invoice_id"`
+	Amount    money.Money `json:"amount"`
+}
+
+// Payment represents cash received from a customer
+type Payment struct {
+	ID            string      `json:"id"`
+	CustomerID    string      `json:"customer_id"`
+	Amount        money.Money `json:"amount"`
+	Date          time.Time   `json:"date"`
+	Reference     string      `json:"reference,omitempty"`
+	CashAccountID string      `json:"cash_account_id"`
+	// Account credited with any amount not applied to invoices (customer credit)
+	UnappliedAccountID string        `json:"unapplied_account_id,omitempty"`
+	Applications       []Application `json:"applications"`
+	Unapplied          money.Money   `json:"unapplied"`
+	// Rate to the base currency the payment was received at; looked up
+	// for the payment date when zero
+	ExchangeRate  decimal.Decimal `json:"exchange_rate,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Created       time.Time       `json:"created"`
+}
+
+// GetID returns the payment identifier
+func (p *Payment) GetID() string { return p.ID }
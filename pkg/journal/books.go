@@ -0,0 +1,199 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Books holds the journal definitions of a ledger. Each journal numbers
+// its transactions from its own sequence of a transaction.SequenceProvider,
+// so journal numbers and the numbers a processor assigns are one scheme.
+type Books struct {
+	mu        sync.Mutex
+	journals  map[string]*Journal
+	sequences transaction.SequenceProvider
+}
+
+// NewBooks creates a set of books with the given journals, numbering them
+// from in-memory sequences
+func NewBooks(journals ...*Journal) (*Books, error) {
+	b := &Books{
+		journals:  make(map[string]*Journal),
+		sequences: transaction.NewMemorySequenceProvider(),
+	}
+	for _, j := range journals {
+		if err := b.Define(j); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Define adds or replaces a journal. Replacing a journal keeps its sequence.
+func (b *Books) Define(j *Journal) error {
+	if err := j.Validate(); err != nil {
+		return err
+	}
+	if j.Width == 0 {
+		j.Width = 6
+	}
+	if j.Created.IsZero() {
+		j.Created = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.journals[j.ID] = j
+	return nil
+}
+
+// Journal returns a journal by ID
+func (b *Books) Journal(id string) (*Journal, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	j, ok := b.journals[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJournalNotFound, id)
+	}
+	return j, nil
+}
+
+// Journals returns every journal ordered by ID
+func (b *Books) Journals() []*Journal {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	journals := make([]*Journal, 0, len(b.journals))
+	for _, j := range b.journals {
+		journals = append(journals, j)
+	}
+	sort.Slice(journals, func(i, k int) bool { return journals[i].ID < journals[k].ID })
+	return journals
+}
+
+// SetSequenceProvider sets the provider journal numbers are reserved from,
+// such as a transaction.StoreSequenceProvider on the ledger's repository so
+// numbering survives restarts
+func (b *Books) SetSequenceProvider(provider transaction.SequenceProvider) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sequences = provider
+}
+
+// SetNext sets the next number a journal's sequence will assign, e.g. when
+// continuing numbering carried over from another system. The sequence
+// provider must support setting numbers, as the in-memory one does.
+func (b *Books) SetNext(id string, next int64) error {
+	if next < 1 {
+		return fmt.Errorf("%w: next number must be positive", ErrInvalidJournal)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.journals[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrJournalNotFound, id)
+	}
+	setter, ok := b.sequences.(interface {
+		SetNext(key transaction.SequenceKey, next int64) error
+	})
+	if !ok {
+		return fmt.Errorf("%w: %T cannot set sequence numbers", ErrInvalidJournal, b.sequences)
+	}
+	return setter.SetNext(sequenceKey(id), next)
+}
+
+// sequenceKey returns the sequence a journal numbers its transactions from
+func sequenceKey(journalID string) transaction.SequenceKey {
+	return transaction.SequenceKey{JournalID: journalID}
+}
+
+// Record prepares a transaction for posting in a journal: entries without an
+// account receive the journal's default account, the transaction is checked
+// against the journal's filters and it is assigned the next number from the
+// journal's sequence as its Number. Transactions without an ID take the
+// number as their ID.
+func (b *Books) Record(ctx context.Context, journalID string, tx *transaction.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	j, ok := b.journals[journalID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJournalNotFound, journalID)
+	}
+	if !j.Active {
+		return fmt.Errorf("%w: %s", ErrJournalInactive, journalID)
+	}
+
+	for i := range tx.Entries {
+		if tx.Entries[i].AccountID == "" {
+			tx.Entries[i].AccountID = j.DefaultAccounts[tx.Entries[i].Type]
+		}
+		if tx.Entries[i].AccountID == "" {
+			return fmt.Errorf("%w: entry %d has no account and %s has no default %s account", ErrNotAccepted, i, j.ID, tx.Entries[i].Type)
+		}
+	}
+	if err := j.Accepts(tx); err != nil {
+		return err
+	}
+
+	n, err := b.sequences.Next(ctx, sequenceKey(j.ID))
+	if err != nil {
+		return fmt.Errorf("failed to number transaction in %s: %w", j.ID, err)
+	}
+	tx.JournalID = j.ID
+	tx.Number = fmt.Sprintf("%s%0*d", j.Prefix, j.Width, n)
+	if tx.ID == "" {
+		tx.ID = tx.Number
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// WithJournal returns a context scoped to a journal, so queries made by
+// reports and exports only see that journal's transactions
+func WithJournal(ctx context.Context, journalID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, journalID)
+}
+
+// FromContext returns the journal a context is scoped to, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ScopeQuery adds a journal filter to a transaction query when the context
+// is scoped to a journal. Unscoped contexts return the query unchanged.
+func ScopeQuery(ctx context.Context, query storage.Query) storage.Query {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return query
+	}
+
+	scoped := query
+	scoped.Filters = append(append([]storage.Filter(nil), query.Filters...), storage.Filter{
+		Field:    "journal_id",
+		Operator: "=",
+		Value:    id,
+	})
+	return scoped
+}
+
+// Filter returns the transactions recorded in a journal
+func Filter(txs []*transaction.Transaction, journalID string) []*transaction.Transaction {
+	filtered := make([]*transaction.Transaction, 0)
+	for _, tx := range txs {
+		if tx.JournalID == journalID {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
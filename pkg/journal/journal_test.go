@@ -0,0 +1,110 @@
+package journal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receipt(account string) *transaction.Transaction {
+	amount := money.Money{Amount: decimal.NewFromInt(50), Currency: "USD"}
+	return &transaction.Transaction{
+		Type: transaction.Journal,
+		Entries: []transaction.Entry{
+			{AccountID: "", Amount: amount, Type: transaction.Debit},
+			{AccountID: account, Amount: amount, Type: transaction.Credit},
+		},
+	}
+}
+
+func TestBooks(t *testing.T) {
+	ctx := context.Background()
+	journals := DefaultJournals()
+	cash := journals[3]
+	cash.DefaultAccounts = map[transaction.EntryType]string{transaction.Debit: "1000"}
+	cash.RequiredAccounts = []string{"1000"}
+
+	books, err := NewBooks(journals...)
+	require.NoError(t, err)
+	require.Len(t, books.Journals(), 4)
+
+	t.Run("independent sequences", func(t *testing.T) {
+		first := receipt("1200")
+		require.NoError(t, books.Record(ctx, "CJ", first))
+		assert.Equal(t, "CJ-000001", first.ID)
+		assert.Equal(t, "CJ", first.JournalID)
+		assert.Equal(t, "1000", first.Entries[0].AccountID)
+
+		sale := receipt("4000")
+		sale.ID = "INV-1"
+		sale.Entries[0].AccountID = "1200"
+		require.NoError(t, books.Record(ctx, "SJ", sale))
+		assert.Equal(t, "INV-1", sale.ID)
+		assert.Equal(t, "SJ-000001", sale.Number)
+
+		second := receipt("1200")
+		require.NoError(t, books.Record(ctx, "CJ", second))
+		assert.Equal(t, "CJ-000002", second.Number)
+
+		require.NoError(t, books.SetNext("GJ", 500))
+		adjustment := receipt("3000")
+		adjustment.Entries[0].AccountID = "6000"
+		require.NoError(t, books.Record(ctx, "GJ", adjustment))
+		assert.Equal(t, "GJ-000500", adjustment.Number)
+
+		assert.Len(t, Filter([]*transaction.Transaction{first, sale, second, adjustment}, "CJ"), 2)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		// No default account for credits in the general journal
+		err := books.Record(ctx, "GJ", receipt("4000"))
+		assert.ErrorIs(t, err, ErrNotAccepted)
+
+		noCash := receipt("4000")
+		noCash.Entries[0].AccountID = "1200"
+		assert.ErrorIs(t, books.Record(ctx, "CJ", noCash), ErrNotAccepted)
+
+		assert.ErrorIs(t, books.Record(ctx, "XX", receipt("4000")), ErrJournalNotFound)
+		assert.ErrorIs(t, books.Define(&Journal{ID: "BAD", Name: "No prefix"}), ErrInvalidJournal)
+	})
+
+	t.Run("scoped queries", func(t *testing.T) {
+		query := storage.Query{}
+		assert.Equal(t, query, ScopeQuery(ctx, query))
+
+		scoped := ScopeQuery(WithJournal(ctx, "SJ"), query)
+		require.Len(t, scoped.Filters, 1)
+		assert.Equal(t, "journal_id", scoped.Filters[0].Field)
+		assert.Equal(t, "SJ", scoped.Filters[0].Value)
+	})
+}
+
+func TestBooksSequenceProvider(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	books, err := NewBooks(DefaultJournals()...)
+	require.NoError(t, err)
+	books.SetSequenceProvider(transaction.NewStoreSequenceProvider(store))
+	assert.ErrorIs(t, books.SetNext("GJ", 10), ErrInvalidJournal)
+
+	tx := receipt("3000")
+	tx.Entries[0].AccountID = "6000"
+	tx.Status = transaction.Draft
+	require.NoError(t, books.Record(ctx, "GJ", tx))
+	assert.Equal(t, "GJ-000001", tx.Number)
+
+	// A numbering processor keeps the journal number
+	processor := transaction.NewBasicTransactionProcessor(store)
+	processor.SetSequenceProvider(transaction.NewStoreSequenceProvider(store), transaction.NumberFormat{})
+	require.NoError(t, transaction.CreateAndPost(ctx, store, processor, tx))
+	posted, err := processor.GetTransaction(ctx, tx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "GJ-000001", posted.Number)
+}
@@ -0,0 +1,107 @@
+// Package journal provides journal books (general, sales, purchases, cash,
+// ...) that transactions are recorded in. Each journal numbers its entries
+// from its own sequence, can supply default accounts for entries and
+// restricts which transactions belong to it, so reports and exports can be
+// scoped to a single book.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var (
+	ErrJournalNotFound = errors.New("journal not found")
+	ErrInvalidJournal  = errors.New("invalid journal")
+	ErrJournalInactive = errors.New("journal is inactive")
+	ErrNotAccepted     = errors.New("transaction not accepted by journal")
+)
+
+// Type classifies a journal book
+type Type string
+
+const (
+	General   Type = "GENERAL"
+	Sales     Type = "SALES"
+	Purchases Type = "PURCHASES"
+	Cash      Type = "CASH"
+)
+
+// Journal defines a book of original entry
+type Journal struct {
+	// Unique identifier, e.g. "SJ"
+	ID string `json:"id"`
+	// Display name
+	Name string `json:"name"`
+	// Kind of journal
+	Type Type `json:"type"`
+	// Prefix of the numbers assigned by the journal's sequence
+	Prefix string `json:"prefix"`
+	// Digits the sequence is zero-padded to; defaults to 6
+	Width int `json:"width,omitempty"`
+	// Accounts used for entries recorded without an account, by entry type
+	// (e.g. the bank account on the debit side of a cash receipts journal)
+	DefaultAccounts map[transaction.EntryType]string `json:"default_accounts,omitempty"`
+	// When set, every transaction must touch at least one of these accounts
+	RequiredAccounts []string `json:"required_accounts,omitempty"`
+	// When set, only these transaction types may be recorded
+	TransactionTypes []transaction.TransactionType `json:"transaction_types,omitempty"`
+	// Whether new entries may be recorded
+	Active bool `json:"active"`
+	// When the journal was created
+	Created time.Time `json:"created"`
+}
+
+// Validate checks that a journal definition is well formed
+func (j *Journal) Validate() error {
+	if j == nil || j.ID == "" || j.Name == "" {
+		return fmt.Errorf("%w: ID and name are required", ErrInvalidJournal)
+	}
+	if j.Prefix == "" {
+		return fmt.Errorf("%w: %s: prefix is required", ErrInvalidJournal, j.ID)
+	}
+	if j.Width < 0 || j.Width > 18 {
+		return fmt.Errorf("%w: %s: width must be at most 18", ErrInvalidJournal, j.ID)
+	}
+	return nil
+}
+
+// Accepts checks that a transaction may be recorded in the journal
+func (j *Journal) Accepts(tx *transaction.Transaction) error {
+	if len(j.TransactionTypes) > 0 {
+		allowed := false
+		for _, t := range j.TransactionTypes {
+			allowed = allowed || t == tx.Type
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s does not record %s transactions", ErrNotAccepted, j.ID, tx.Type)
+		}
+	}
+
+	if len(j.RequiredAccounts) > 0 {
+		for _, entry := range tx.Entries {
+			for _, id := range j.RequiredAccounts {
+				if entry.AccountID == id {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: %s requires an entry on one of %v", ErrNotAccepted, j.ID, j.RequiredAccounts)
+	}
+	return nil
+}
+
+// DefaultJournals returns the general, sales, purchases and cash journals
+// found in most bookkeeping regimes. Default and required accounts are left
+// for the caller to configure against their chart of accounts.
+func DefaultJournals() []*Journal {
+	return []*Journal{
+		{ID: "GJ", Name: "General Journal", Type: General, Prefix: "GJ-", Active: true},
+		{ID: "SJ", Name: "Sales Journal", Type: Sales, Prefix: "SJ-", Active: true},
+		{ID: "PJ", Name: "Purchases Journal", Type: Purchases, Prefix: "PJ-", Active: true},
+		{ID: "CJ", Name: "Cash Journal", Type: Cash, Prefix: "CJ-", Active: true},
+	}
+}
@@ -0,0 +1,478 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+)
+
+// EventSourcedLedger is a ledger whose only state is an append-only stream
+// of events. Opening accounts and posting journals append events; balances
+// are a projection of the stream, so any past balance can be derived by
+// replaying it. Posted transactions are never changed: mistakes are
+// corrected by posting reversals.
+type EventSourcedLedger struct {
+	mu         sync.Mutex
+	events     LedgerEventStore
+	validation *validation.BasicValidationEngine
+	bus        event.Bus
+	currency   string
+	nextID     func() string
+	current    *Projection
+}
+
+// OpenEventSourcedLedger opens a ledger over an event store, replaying any
+// events already in it. Options are the same as for OpenLedger; journal IDs
+// default to a sequence continuing from the transactions in the stream.
+func OpenEventSourcedLedger(ctx context.Context, events LedgerEventStore, opts Options) (*EventSourcedLedger, error) {
+	if events == nil {
+		return nil, fmt.Errorf("event store cannot be nil")
+	}
+
+	l := &EventSourcedLedger{
+		events:     events,
+		validation: validation.NewBasicValidationEngine(),
+		bus:        opts.Bus,
+		currency:   opts.BaseCurrency,
+		nextID:     opts.NextID,
+	}
+	if l.bus == nil {
+		l.bus = event.NewMemoryBus()
+	}
+	if l.currency == "" {
+		l.currency = DefaultCurrency
+	}
+	if l.nextID == nil {
+		l.nextID = func() string {
+			return fmt.Sprintf("JE-%06d", len(l.current.transactions)+1)
+		}
+	}
+
+	validators := append([]validation.Validator{validation.NewTransactionValidator()}, opts.Validators...)
+	for _, v := range validators {
+		if err := l.validation.RegisterValidator(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.Rebuild(ctx); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Bus returns the event bus postings are published to
+func (l *EventSourcedLedger) Bus() event.Bus {
+	return l.bus
+}
+
+// CreateAccount records the opening of an account. The ID defaults to the
+// code; the account starts with a zero balance in the base currency.
+func (l *EventSourcedLedger) CreateAccount(ctx context.Context, acc *account.Account) error {
+	if acc == nil || acc.Code == "" || acc.Name == "" {
+		return fmt.Errorf("%w: code and name are required", account.ErrInvalidAccountCode)
+	}
+	if !debitNormal(acc.Type) && acc.Type != account.Liability && acc.Type != account.Equity && acc.Type != account.Revenue {
+		return fmt.Errorf("%w: %q", account.ErrInvalidAccountType, acc.Type)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if acc.ID == "" {
+		acc.ID = acc.Code
+	}
+	for _, existing := range l.current.accounts {
+		if existing.Code == acc.Code || existing.ID == acc.ID {
+			return fmt.Errorf("%w: %s", ErrDuplicateCode, acc.Code)
+		}
+	}
+
+	now := time.Now()
+	e, err := l.appendEvent(ctx, LedgerEvent{
+		ID:          fmt.Sprintf("account-%s", acc.ID),
+		Type:        event.AccountCreated,
+		Recorded:    now,
+		Date:        now,
+		UserID:      storage.ActorFromContext(ctx).UserID,
+		AccountID:   acc.ID,
+		AccountCode: acc.Code,
+		AccountName: acc.Name,
+		AccountType: acc.Type,
+	})
+	if err != nil {
+		return err
+	}
+	*acc = *copyAccount(l.current.accounts[e.AccountID])
+	return nil
+}
+
+// PostJournal validates a journal entry and records it as a posted
+// transaction. Balances are updated by projecting the new event.
+func (l *EventSourcedLedger) PostJournal(ctx context.Context, description string, date time.Time, entries ...transaction.Entry) (*transaction.Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range entries {
+		acc, ok := l.current.accounts[entries[i].AccountID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, entries[i].AccountID)
+		}
+		if acc.Status != account.Active {
+			return nil, fmt.Errorf("%w: %s is %s", ErrAccountInactive, acc.ID, acc.Status)
+		}
+		if entries[i].Description == "" {
+			entries[i].Description = description
+		}
+	}
+
+	now := time.Now()
+	draft := &transaction.Transaction{
+		ID:           l.nextID(),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         date,
+		Description:  description,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+	if _, err := l.validation.Validate(ctx, draft); err != nil {
+		return nil, err
+	}
+	return l.post(ctx, LedgerEvent{
+		ID:            fmt.Sprintf("%s-posted", draft.ID),
+		Type:          event.TransactionPosted,
+		Recorded:      now,
+		Date:          date,
+		UserID:        storage.ActorFromContext(ctx).UserID,
+		TransactionID: draft.ID,
+		Description:   description,
+		Entries:       entries,
+	})
+}
+
+// Reverse records a transaction that reverses every entry of a posted
+// transaction, effective on the given date
+func (l *EventSourcedLedger) Reverse(ctx context.Context, transactionID string, date time.Time) (*transaction.Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	original, ok := l.current.transactions[transactionID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTransaction, transactionID)
+	}
+	if original.ReversalID != "" {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyReversed, transactionID)
+	}
+	if original.ReversedFrom != "" {
+		return nil, fmt.Errorf("cannot reverse reversal %s", transactionID)
+	}
+
+	entries := copyEntries(original.Entries)
+	for i := range entries {
+		entries[i].Type = entries[i].Type.Reverse()
+	}
+	id := l.nextID()
+	return l.post(ctx, LedgerEvent{
+		ID:            fmt.Sprintf("%s-posted", id),
+		Type:          event.TransactionPosted,
+		Recorded:      time.Now(),
+		Date:          date,
+		UserID:        storage.ActorFromContext(ctx).UserID,
+		TransactionID: id,
+		Description:   fmt.Sprintf("Reversal of %s", transactionID),
+		Entries:       entries,
+		Reverses:      transactionID,
+	})
+}
+
+// GetBalance returns the current balance of an account, signed so that an
+// account's normal balance is positive
+func (l *EventSourcedLedger) GetBalance(ctx context.Context, accountID string) (money.Money, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current.Balance(accountID)
+}
+
+// BalanceAt returns the balance of an account including only the postings
+// dated on or before a point in time, derived by replaying the stream
+func (l *EventSourcedLedger) BalanceAt(ctx context.Context, accountID string, at time.Time) (money.Money, error) {
+	p, err := l.Replay(ctx, at)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return p.Balance(accountID)
+}
+
+// GetTransaction returns a posted transaction as projected from the stream
+func (l *EventSourcedLedger) GetTransaction(ctx context.Context, id string) (*transaction.Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current.Transaction(id)
+}
+
+// Accounts returns the chart of accounts ordered by code
+func (l *EventSourcedLedger) Accounts(ctx context.Context) []*account.Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current.Accounts()
+}
+
+// TrialBalance lists the current balance of every account
+func (l *EventSourcedLedger) TrialBalance(ctx context.Context) (*TrialBalance, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return newTrialBalance(l.currency, time.Now(), l.current.Accounts())
+}
+
+// Replay builds a projection from the event stream including postings
+// dated on or before toTime, or every posting when toTime is zero. The
+// chart of accounts is always projected in full so that back-dated
+// postings to accounts opened later replay cleanly.
+func (l *EventSourcedLedger) Replay(ctx context.Context, toTime time.Time) (*Projection, error) {
+	events, err := l.events.Load(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ledger events: %w", err)
+	}
+	p := newProjection(l.currency, toTime)
+	for _, e := range events {
+		if _, err := p.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to replay event %d (%s): %w", e.Sequence, e.ID, err)
+		}
+	}
+	return p, nil
+}
+
+// Rebuild discards the current projection and replays the whole stream,
+// picking up events appended by other ledgers over the same store
+func (l *EventSourcedLedger) Rebuild(ctx context.Context) error {
+	p, err := l.Replay(ctx, time.Time{})
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.current = p
+	return nil
+}
+
+// post checks that a posting projects cleanly, appends it and publishes
+// the resulting events
+func (l *EventSourcedLedger) post(ctx context.Context, e LedgerEvent) (*transaction.Transaction, error) {
+	if _, err := l.current.postingChanges(e); err != nil {
+		return nil, err
+	}
+	appended, err := l.events.Append(ctx, e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append ledger event: %w", err)
+	}
+	changes, err := l.current.apply(appended[0])
+	if err != nil {
+		return nil, fmt.Errorf("event %s appended but not projected: %w", e.ID, err)
+	}
+
+	tx := l.current.transactions[e.TransactionID]
+	if err := publishPosting(ctx, l.bus, tx, changes); err != nil {
+		return copyTransaction(tx), err
+	}
+	return copyTransaction(tx), nil
+}
+
+// appendEvent appends a non-posting event and projects it
+func (l *EventSourcedLedger) appendEvent(ctx context.Context, e LedgerEvent) (LedgerEvent, error) {
+	appended, err := l.events.Append(ctx, e)
+	if err != nil {
+		return e, fmt.Errorf("failed to append ledger event: %w", err)
+	}
+	if _, err := l.current.apply(appended[0]); err != nil {
+		return e, fmt.Errorf("event %s appended but not projected: %w", e.ID, err)
+	}
+	return appended[0], nil
+}
+
+// Projection is the state of a ledger derived from its event stream: the
+// chart of accounts with their balances and the posted transactions
+type Projection struct {
+	// Point in time the projection includes postings up to; zero when it
+	// includes every posting
+	AsOf time.Time
+	// Sequence of the last event applied
+	Sequence int64
+
+	currency     string
+	accounts     map[string]*account.Account
+	transactions map[string]*transaction.Transaction
+}
+
+func newProjection(currency string, asOf time.Time) *Projection {
+	return &Projection{
+		AsOf:         asOf,
+		currency:     currency,
+		accounts:     make(map[string]*account.Account),
+		transactions: make(map[string]*transaction.Transaction),
+	}
+}
+
+// Balance returns the projected balance of an account
+func (p *Projection) Balance(accountID string) (money.Money, error) {
+	acc, ok := p.accounts[accountID]
+	if !ok {
+		return money.Money{}, fmt.Errorf("%w: %s", ErrUnknownAccount, accountID)
+	}
+	return *acc.Balance, nil
+}
+
+// Transaction returns a copy of a projected transaction
+func (p *Projection) Transaction(id string) (*transaction.Transaction, error) {
+	tx, ok := p.transactions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTransaction, id)
+	}
+	return copyTransaction(tx), nil
+}
+
+// Accounts returns copies of the projected accounts ordered by code
+func (p *Projection) Accounts() []*account.Account {
+	accounts := make([]*account.Account, 0, len(p.accounts))
+	for _, acc := range p.accounts {
+		accounts = append(accounts, copyAccount(acc))
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+	return accounts
+}
+
+// TrialBalance lists the projected balance of every account
+func (p *Projection) TrialBalance() (*TrialBalance, error) {
+	asOf := p.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	return newTrialBalance(p.currency, asOf, p.Accounts())
+}
+
+// includes reports whether a posting dated at a time is in the projection
+func (p *Projection) includes(date time.Time) bool {
+	return p.AsOf.IsZero() || !date.After(p.AsOf)
+}
+
+// apply projects an event. Unknown event types are skipped so streams
+// written by newer versions still replay.
+func (p *Projection) apply(e LedgerEvent) ([]balanceChange, error) {
+	switch e.Type {
+	case event.AccountCreated:
+		if _, exists := p.accounts[e.AccountID]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateCode, e.AccountCode)
+		}
+		p.accounts[e.AccountID] = &account.Account{
+			ID:           e.AccountID,
+			Code:         e.AccountCode,
+			Name:         e.AccountName,
+			Type:         e.AccountType,
+			Status:       account.Active,
+			Balance:      &money.Money{Amount: decimal.Zero, Currency: p.currency},
+			CreatedBy:    e.UserID,
+			Created:      e.Recorded,
+			LastModified: e.Recorded,
+		}
+	case event.TransactionPosted:
+		changes, err := p.postingChanges(e)
+		if err != nil {
+			return nil, err
+		}
+		p.Sequence = e.Sequence
+		if !p.includes(e.Date) {
+			return nil, nil
+		}
+		for _, change := range changes {
+			acc := p.accounts[change.accountID]
+			after := change.after
+			acc.Balance = &after
+			acc.LastModified = e.Recorded
+		}
+		p.record(e)
+		return changes, nil
+	}
+	p.Sequence = e.Sequence
+	return nil, nil
+}
+
+// postingChanges computes the balance changes a posting event makes
+// without applying them
+func (p *Projection) postingChanges(e LedgerEvent) ([]balanceChange, error) {
+	if _, exists := p.transactions[e.TransactionID]; exists {
+		return nil, fmt.Errorf("%w: transaction %s", ErrDuplicateEvent, e.TransactionID)
+	}
+	balances := make(map[string]money.Money, len(e.Entries))
+	changes := make([]balanceChange, 0, len(e.Entries))
+	for _, entry := range e.Entries {
+		acc, ok := p.accounts[entry.AccountID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, entry.AccountID)
+		}
+		before, seen := balances[acc.ID]
+		if !seen {
+			before = *acc.Balance
+		}
+		after, err := before.Add(normalAmount(acc.Type, entry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to update balance of account %s: %w", acc.ID, err)
+		}
+		balances[acc.ID] = after
+		changes = append(changes, balanceChange{accountID: acc.ID, before: before, after: after})
+	}
+	return changes, nil
+}
+
+// record adds the transaction a posting event describes and links a
+// reversal to the transaction it reverses
+func (p *Projection) record(e LedgerEvent) {
+	recorded := e.Recorded
+	tx := &transaction.Transaction{
+		ID:           e.TransactionID,
+		Type:         transaction.Journal,
+		Status:       transaction.Posted,
+		Date:         e.Date,
+		Description:  e.Description,
+		Entries:      copyEntries(e.Entries),
+		CreatedBy:    e.UserID,
+		Created:      e.Recorded,
+		LastModified: e.Recorded,
+		PostedAt:     &recorded,
+		ReversedFrom: e.Reverses,
+	}
+	if e.Reverses != "" {
+		tx.Type = transaction.Reversal
+		if original, ok := p.transactions[e.Reverses]; ok {
+			original.ReversalID = tx.ID
+			original.ReversedAt = &recorded
+		}
+	}
+	p.transactions[tx.ID] = tx
+}
+
+// copyTransaction returns a copy of a projected transaction that shares no
+// entries with it
+func copyTransaction(tx *transaction.Transaction) *transaction.Transaction {
+	copied := *tx
+	copied.Entries = copyEntries(tx.Entries)
+	return &copied
+}
+
+// copyAccount returns a copy of a projected account that shares no balance
+// with it
+func copyAccount(acc *account.Account) *account.Account {
+	copied := *acc
+	balance := *acc.Balance
+	copied.Balance = &balance
+	return &copied
+}
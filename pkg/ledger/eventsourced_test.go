@@ -0,0 +1,117 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSourcedLedger(t *testing.T) {
+	ctx := context.Background()
+	events := NewMemoryEventStore()
+	l, err := OpenEventSourcedLedger(ctx, events, Options{})
+	require.NoError(t, err)
+
+	posted := &countingHandler{}
+	require.NoError(t, l.Bus().Subscribe(event.TransactionPosted, posted))
+
+	for _, acc := range []*account.Account{
+		{Code: "1000", Name: "Cash", Type: account.Asset},
+		{Code: "3000", Name: "Capital", Type: account.Equity},
+		{Code: "4000", Name: "Sales", Type: account.Revenue},
+	} {
+		require.NoError(t, l.CreateAccount(ctx, acc))
+	}
+	assert.ErrorIs(t, l.CreateAccount(ctx, &account.Account{Code: "1000", Name: "Dup", Type: account.Asset}), ErrDuplicateCode)
+
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	_, err = l.PostJournal(ctx, "Owner investment", march, Debit("1000", usd(1000)), Credit("3000", usd(1000)))
+	require.NoError(t, err)
+	sale, err := l.PostJournal(ctx, "Cash sale", april, Debit("1000", usd(250)), Credit("4000", usd(250)))
+	require.NoError(t, err)
+	assert.Equal(t, "JE-000002", sale.ID)
+
+	cash, err := l.GetBalance(ctx, "1000")
+	require.NoError(t, err)
+	assert.Equal(t, "1250", cash.Amount.String())
+
+	t.Run("point in time", func(t *testing.T) {
+		cash, err := l.BalanceAt(ctx, "1000", march.AddDate(0, 0, 15))
+		require.NoError(t, err)
+		assert.Equal(t, "1000", cash.Amount.String())
+
+		sales, err := l.BalanceAt(ctx, "4000", march)
+		require.NoError(t, err)
+		assert.True(t, sales.Amount.IsZero())
+
+		p, err := l.Replay(ctx, april)
+		require.NoError(t, err)
+		tb, err := p.TrialBalance()
+		require.NoError(t, err)
+		assert.True(t, tb.Balanced())
+		assert.Equal(t, "1250", tb.TotalDebits.Amount.String())
+	})
+
+	t.Run("reversal", func(t *testing.T) {
+		may := april.AddDate(0, 1, 0)
+		reversal, err := l.Reverse(ctx, sale.ID, may)
+		require.NoError(t, err)
+		assert.Equal(t, sale.ID, reversal.ReversedFrom)
+
+		_, err = l.Reverse(ctx, sale.ID, may)
+		assert.ErrorIs(t, err, ErrAlreadyReversed)
+
+		cash, err := l.GetBalance(ctx, "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "1000", cash.Amount.String())
+
+		// The sale is still in April's balance; the stream is never rewritten
+		cash, err = l.BalanceAt(ctx, "1000", april)
+		require.NoError(t, err)
+		assert.Equal(t, "1250", cash.Amount.String())
+
+		original, err := l.GetTransaction(ctx, sale.ID)
+		require.NoError(t, err)
+		assert.Equal(t, reversal.ID, original.ReversalID)
+	})
+
+	t.Run("rejected journals append nothing", func(t *testing.T) {
+		before, err := events.Load(ctx, 0)
+		require.NoError(t, err)
+
+		_, err = l.PostJournal(ctx, "Unknown", april, Debit("9999", usd(10)), Credit("4000", usd(10)))
+		assert.ErrorIs(t, err, ErrUnknownAccount)
+		euro := money.Money{Amount: decimal.NewFromInt(10), Currency: "EUR"}
+		_, err = l.PostJournal(ctx, "Euro", april, Debit("1000", euro), Credit("4000", euro))
+		assert.Error(t, err)
+
+		after, err := events.Load(ctx, 0)
+		require.NoError(t, err)
+		assert.Len(t, after, len(before))
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		reopened, err := OpenEventSourcedLedger(ctx, events, Options{})
+		require.NoError(t, err)
+		cash, err := reopened.GetBalance(ctx, "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "1000", cash.Amount.String())
+
+		tx, err := reopened.PostJournal(ctx, "Another sale", april, Debit("1000", usd(5)), Credit("4000", usd(5)))
+		require.NoError(t, err)
+		assert.Equal(t, "JE-000004", tx.ID)
+	})
+
+	assert.Equal(t, 3, posted.count)
+
+	_, err = events.Append(ctx, LedgerEvent{ID: "JE-000001-posted", Type: event.TransactionPosted})
+	assert.ErrorIs(t, err, ErrDuplicateEvent)
+}
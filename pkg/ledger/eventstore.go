@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// LedgerEvent is an immutable fact recorded by an event-sourced ledger.
+// Account events carry the account fields; posting events carry the
+// transaction fields.
+type LedgerEvent struct {
+	// Position in the stream, assigned by the store on append
+	Sequence int64     `json:"sequence"`
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Recorded time.Time `json:"recorded"`
+	// Date the event takes effect; point-in-time queries include postings
+	// dated on or before the point in time
+	Date   time.Time `json:"date"`
+	UserID string    `json:"user_id,omitempty"`
+
+	AccountID   string              `json:"account_id,omitempty"`
+	AccountCode string              `json:"account_code,omitempty"`
+	AccountName string              `json:"account_name,omitempty"`
+	AccountType account.AccountType `json:"account_type,omitempty"`
+
+	TransactionID string              `json:"transaction_id,omitempty"`
+	Description   string              `json:"description,omitempty"`
+	Entries       []transaction.Entry `json:"entries,omitempty"`
+	// ID of the transaction a reversal reverses
+	Reverses string `json:"reverses,omitempty"`
+}
+
+// LedgerEventStore is an append-only stream of ledger events. Events are
+// never updated or deleted; corrections are recorded as new events.
+type LedgerEventStore interface {
+	// Append atomically adds events to the end of the stream and returns
+	// them with their sequence numbers. Events with an ID already in the
+	// stream are rejected with ErrDuplicateEvent.
+	Append(ctx context.Context, events ...LedgerEvent) ([]LedgerEvent, error)
+	// Load returns the events after a sequence number in stream order
+	Load(ctx context.Context, after int64) ([]LedgerEvent, error)
+}
+
+// MemoryEventStore is an in-memory LedgerEventStore
+type MemoryEventStore struct {
+	mu     sync.RWMutex
+	events []LedgerEvent
+	ids    map[string]bool
+}
+
+// NewMemoryEventStore creates an empty in-memory event store
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{ids: make(map[string]bool)}
+}
+
+// Append implements LedgerEventStore.Append
+func (s *MemoryEventStore) Append(ctx context.Context, events ...LedgerEvent) ([]LedgerEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		if e.ID == "" {
+			return nil, fmt.Errorf("ledger event ID is required")
+		}
+		if s.ids[e.ID] || seen[e.ID] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateEvent, e.ID)
+		}
+		seen[e.ID] = true
+	}
+
+	appended := make([]LedgerEvent, 0, len(events))
+	for _, e := range events {
+		e.Sequence = int64(len(s.events)) + 1
+		e.Entries = copyEntries(e.Entries)
+		s.events = append(s.events, e)
+		s.ids[e.ID] = true
+		appended = append(appended, copyEvent(e))
+	}
+	return appended, nil
+}
+
+// Load implements LedgerEventStore.Load
+func (s *MemoryEventStore) Load(ctx context.Context, after int64) ([]LedgerEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if after < 0 {
+		after = 0
+	}
+	if after >= int64(len(s.events)) {
+		return nil, nil
+	}
+	events := make([]LedgerEvent, 0, int64(len(s.events))-after)
+	for _, e := range s.events[after:] {
+		events = append(events, copyEvent(e))
+	}
+	return events, nil
+}
+
+// copyEvent returns a copy of an event that shares no entries with it
+func copyEvent(e LedgerEvent) LedgerEvent {
+	e.Entries = copyEntries(e.Entries)
+	return e
+}
+
+func copyEntries(entries []transaction.Entry) []transaction.Entry {
+	if entries == nil {
+		return nil
+	}
+	copied := make([]transaction.Entry, len(entries))
+	for i, entry := range entries {
+		if entry.Dimensions != nil {
+			dimensions := make(map[string]string, len(entry.Dimensions))
+			for k, v := range entry.Dimensions {
+				dimensions[k] = v
+			}
+			entry.Dimensions = dimensions
+		}
+		copied[i] = entry
+	}
+	return copied
+}
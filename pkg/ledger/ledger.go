@@ -0,0 +1,344 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+)
+
+// Ledger is a ready-to-use double-entry ledger. Accounts and transactions
+// are persisted in the store; the ledger keeps its chart of accounts and
+// running balances up to date as journals are posted.
+type Ledger struct {
+	mu         sync.Mutex
+	store      storage.Repository
+	processor  *transaction.BasicTransactionProcessor
+	validation *validation.BasicValidationEngine
+	bus        event.Bus
+	currency   string
+	nextID     func() string
+	accounts   map[string]*account.Account
+}
+
+// OpenLedger creates a ledger over a store. Accounts already in the store
+// are loaded, and journal entries are numbered on from the highest JE
+// number stored.
+func OpenLedger(ctx context.Context, store storage.Repository, opts Options) (*Ledger, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+
+	l := &Ledger{
+		store:      store,
+		processor:  transaction.NewBasicTransactionProcessor(store),
+		validation: validation.NewBasicValidationEngine(),
+		bus:        opts.Bus,
+		currency:   opts.BaseCurrency,
+		nextID:     opts.NextID,
+		accounts:   make(map[string]*account.Account),
+	}
+	if l.bus == nil {
+		l.bus = event.NewMemoryBus()
+	}
+	if l.currency == "" {
+		l.currency = DefaultCurrency
+	}
+	var accounts []*account.Account
+	if err := store.Query(ctx, storage.Query{}, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	for _, acc := range accounts {
+		if acc.Balance == nil {
+			acc.Balance = &money.Money{Amount: decimal.Zero, Currency: l.currency}
+		}
+		l.accounts[acc.ID] = acc
+	}
+	if l.nextID == nil {
+		sequence, err := lastJournalNumber(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		l.nextID = func() string {
+			sequence++
+			return fmt.Sprintf("JE-%06d", sequence)
+		}
+	}
+
+	validators := append([]validation.Validator{validation.NewTransactionValidator()}, opts.Validators...)
+	for _, v := range validators {
+		if err := l.validation.RegisterValidator(v); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// lastJournalNumber returns the highest JE number of the transactions in a
+// store, or zero when there are none
+func lastJournalNumber(ctx context.Context, store storage.Repository) (int64, error) {
+	var txs []*transaction.Transaction
+	if err := store.Query(ctx, storage.Query{}, &txs); err != nil {
+		return 0, fmt.Errorf("failed to load transactions: %w", err)
+	}
+	var last int64
+	for _, tx := range txs {
+		var n int64
+		if _, err := fmt.Sscanf(tx.ID, "JE-%d", &n); err == nil && n > last {
+			last = n
+		}
+	}
+	return last, nil
+}
+
+// Bus returns the event bus the ledger publishes to
+func (l *Ledger) Bus() event.Bus {
+	return l.bus
+}
+
+// Processor returns the underlying transaction processor
+func (l *Ledger) Processor() transaction.TransactionProcessor {
+	return l.processor
+}
+
+// CreateAccount adds an account to the chart. The ID defaults to the code,
+// the status to active and the balance to zero in the base currency.
+func (l *Ledger) CreateAccount(ctx context.Context, acc *account.Account) error {
+	if acc == nil || acc.Code == "" || acc.Name == "" {
+		return fmt.Errorf("%w: code and name are required", account.ErrInvalidAccountCode)
+	}
+	if !debitNormal(acc.Type) && acc.Type != account.Liability && acc.Type != account.Equity && acc.Type != account.Revenue {
+		return fmt.Errorf("%w: %q", account.ErrInvalidAccountType, acc.Type)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if acc.ID == "" {
+		acc.ID = acc.Code
+	}
+	for _, existing := range l.accounts {
+		if existing.Code == acc.Code {
+			return fmt.Errorf("%w: %s", ErrDuplicateCode, acc.Code)
+		}
+	}
+	if acc.Status == "" {
+		acc.Status = account.Active
+	}
+	if acc.Balance == nil {
+		acc.Balance = &money.Money{Amount: decimal.Zero, Currency: l.currency}
+	}
+	now := time.Now()
+	acc.Created = now
+	acc.LastModified = now
+
+	if err := l.store.Create(ctx, acc); err != nil {
+		return fmt.Errorf("failed to store account: %w", err)
+	}
+	l.accounts[acc.ID] = acc
+	return nil
+}
+
+// PostJournal validates and posts a journal entry, updates the balances of
+// the accounts it touches and publishes the resulting events
+func (l *Ledger) PostJournal(ctx context.Context, description string, date time.Time, entries ...transaction.Entry) (*transaction.Transaction, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range entries {
+		acc, ok := l.accounts[entries[i].AccountID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, entries[i].AccountID)
+		}
+		if acc.Status != account.Active {
+			return nil, fmt.Errorf("%w: %s is %s", ErrAccountInactive, acc.ID, acc.Status)
+		}
+		if entries[i].Description == "" {
+			entries[i].Description = description
+		}
+	}
+
+	now := time.Now()
+	tx := &transaction.Transaction{
+		ID:           l.nextID(),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         date,
+		Description:  description,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+	if _, err := l.validation.Validate(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := l.store.Create(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to store transaction: %w", err)
+	}
+	if err := l.processor.ProcessTransaction(ctx, tx); err != nil {
+		_ = l.store.Delete(ctx, tx.ID)
+		return nil, err
+	}
+
+	changes, err := l.applyBalances(ctx, tx)
+	if err != nil {
+		return tx, err
+	}
+	return tx, publishPosting(ctx, l.bus, tx, changes)
+}
+
+// GetBalance returns the current balance of an account, signed so that an
+// account's normal balance is positive
+func (l *Ledger) GetBalance(ctx context.Context, accountID string) (money.Money, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	acc, ok := l.accounts[accountID]
+	if !ok {
+		return money.Money{}, fmt.Errorf("%w: %s", ErrUnknownAccount, accountID)
+	}
+	return *acc.Balance, nil
+}
+
+// Accounts returns the chart of accounts ordered by code
+func (l *Ledger) Accounts(ctx context.Context) []*account.Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sortedAccounts()
+}
+
+// TrialBalance lists the current balance of every account
+func (l *Ledger) TrialBalance(ctx context.Context) (*TrialBalance, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return newTrialBalance(l.currency, time.Now(), l.sortedAccounts())
+}
+
+// balanceChange records an account balance before and after a posting
+type balanceChange struct {
+	accountID string
+	before    money.Money
+	after     money.Money
+}
+
+// applyBalances updates and stores the balances of the accounts a posted
+// transaction touches
+func (l *Ledger) applyBalances(ctx context.Context, tx *transaction.Transaction) ([]balanceChange, error) {
+	changes := make([]balanceChange, 0, len(tx.Entries))
+	for _, entry := range tx.Entries {
+		acc := l.accounts[entry.AccountID]
+
+		updated, err := acc.Balance.Add(normalAmount(acc.Type, entry))
+		if err != nil {
+			return changes, fmt.Errorf("failed to update balance of account %s: %w", acc.ID, err)
+		}
+
+		change := balanceChange{accountID: acc.ID, before: *acc.Balance, after: updated}
+		acc.Balance = &updated
+		acc.LastModified = time.Now()
+		if err := l.store.Update(ctx, acc); err != nil {
+			return changes, fmt.Errorf("failed to store account %s: %w", acc.ID, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// publishPosting announces a posted transaction and its balance changes
+func publishPosting(ctx context.Context, bus event.Bus, tx *transaction.Transaction, changes []balanceChange) error {
+	events := []event.Event{{
+		ID:        fmt.Sprintf("%s-posted", tx.ID),
+		Type:      event.TransactionPosted,
+		Timestamp: time.Now(),
+		Source:    "ledger",
+		Data: event.TransactionPostedV1{
+			TransactionID: tx.ID,
+			OldStatus:     string(transaction.Draft),
+			NewStatus:     string(tx.Status),
+		},
+	}}
+	for _, change := range changes {
+		events = append(events, event.Event{
+			ID:        fmt.Sprintf("%s-%s-balance", tx.ID, change.accountID),
+			Type:      event.AccountBalanceUpdated,
+			Timestamp: time.Now(),
+			Source:    "ledger",
+			Data: event.AccountBalanceUpdatedV1{
+				AccountID:  change.accountID,
+				OldBalance: change.before,
+				NewBalance: change.after,
+				ChangeType: string(tx.Type),
+			},
+		})
+	}
+
+	for _, e := range events {
+		if err := bus.Publish(ctx, e); err != nil {
+			return fmt.Errorf("transaction %s posted but event %s was not published: %w", tx.ID, e.Type, err)
+		}
+	}
+	return nil
+}
+
+// newTrialBalance lists the balances of accounts ordered as given
+func newTrialBalance(currency string, asOf time.Time, accounts []*account.Account) (*TrialBalance, error) {
+	zero := money.Money{Amount: decimal.Zero, Currency: currency}
+	tb := &TrialBalance{
+		AsOf:         asOf,
+		Currency:     currency,
+		Lines:        make([]TrialBalanceLine, 0, len(accounts)),
+		TotalDebits:  zero,
+		TotalCredits: zero,
+	}
+
+	for _, acc := range accounts {
+		line := TrialBalanceLine{
+			AccountID:   acc.ID,
+			AccountCode: acc.Code,
+			AccountName: acc.Name,
+			AccountType: acc.Type,
+			Debit:       zero,
+			Credit:      zero,
+		}
+
+		// Normal balances go in the account's natural column
+		balance := *acc.Balance
+		if !debitNormal(acc.Type) {
+			balance = balance.Multiply(decimal.NewFromInt(-1))
+		}
+		if balance.IsNegative() {
+			line.Credit = balance.Abs()
+		} else {
+			line.Debit = balance
+		}
+
+		var err error
+		if tb.TotalDebits, err = tb.TotalDebits.Add(line.Debit); err != nil {
+			return nil, fmt.Errorf("account %s: %w", acc.ID, err)
+		}
+		if tb.TotalCredits, err = tb.TotalCredits.Add(line.Credit); err != nil {
+			return nil, fmt.Errorf("account %s: %w", acc.ID, err)
+		}
+		tb.Lines = append(tb.Lines, line)
+	}
+	return tb, nil
+}
+
+func (l *Ledger) sortedAccounts() []*account.Account {
+	accounts := make([]*account.Account, 0, len(l.accounts))
+	for _, acc := range l.accounts {
+		accounts = append(accounts, acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+	return accounts
+}
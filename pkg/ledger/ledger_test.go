@@ -0,0 +1,116 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler counts the events it receives
+type countingHandler struct {
+	count int
+}
+
+func (h *countingHandler) Handle(ctx context.Context, e event.Event) error {
+	h.count++
+	return nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func TestLedger(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	l, err := OpenLedger(ctx, store, Options{})
+	require.NoError(t, err)
+
+	posted := &countingHandler{}
+	balances := &countingHandler{}
+	require.NoError(t, l.Bus().Subscribe(event.TransactionPosted, posted))
+	require.NoError(t, l.Bus().Subscribe(event.AccountBalanceUpdated, balances))
+
+	for _, acc := range []*account.Account{
+		{Code: "1000", Name: "Cash", Type: account.Asset},
+		{Code: "3000", Name: "Capital", Type: account.Equity},
+		{Code: "4000", Name: "Sales", Type: account.Revenue},
+		{Code: "6000", Name: "Rent", Type: account.Expense},
+	} {
+		require.NoError(t, l.CreateAccount(ctx, acc))
+	}
+	assert.ErrorIs(t, l.CreateAccount(ctx, &account.Account{Code: "1000", Name: "Dup", Type: account.Asset}), ErrDuplicateCode)
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err = l.PostJournal(ctx, "Owner investment", date, Debit("1000", usd(1000)), Credit("3000", usd(1000)))
+	require.NoError(t, err)
+	_, err = l.PostJournal(ctx, "Cash sale", date, Debit("1000", usd(250)), Credit("4000", usd(250)))
+	require.NoError(t, err)
+	tx, err := l.PostJournal(ctx, "March rent", date, Debit("6000", usd(400)), Credit("1000", usd(400)))
+	require.NoError(t, err)
+	assert.Equal(t, "JE-000003", tx.ID)
+
+	t.Run("balances", func(t *testing.T) {
+		cash, err := l.GetBalance(ctx, "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "850", cash.Amount.String())
+
+		sales, err := l.GetBalance(ctx, "4000")
+		require.NoError(t, err)
+		assert.Equal(t, "250", sales.Amount.String())
+
+		var stored account.Account
+		require.NoError(t, store.Read(ctx, "1000", &stored))
+		assert.Equal(t, "850", stored.Balance.Amount.String())
+	})
+
+	t.Run("trial balance", func(t *testing.T) {
+		tb, err := l.TrialBalance(ctx)
+		require.NoError(t, err)
+		require.Len(t, tb.Lines, 4)
+		assert.True(t, tb.Balanced())
+		assert.Equal(t, "1250", tb.TotalDebits.Amount.String())
+		assert.Equal(t, "1000", tb.Lines[1].Credit.Amount.String())
+	})
+
+	t.Run("events", func(t *testing.T) {
+		assert.Equal(t, 3, posted.count)
+		assert.Equal(t, 6, balances.count)
+	})
+
+	t.Run("rejected journals", func(t *testing.T) {
+		_, err := l.PostJournal(ctx, "Unbalanced", date, Debit("1000", usd(10)), Credit("4000", usd(5)))
+		var validationErr *validation.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+
+		_, err = l.PostJournal(ctx, "Unknown", date, Debit("9999", usd(10)), Credit("4000", usd(10)))
+		assert.ErrorIs(t, err, ErrUnknownAccount)
+
+		cash, err := l.GetBalance(ctx, "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "850", cash.Amount.String())
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		reopened, err := OpenLedger(ctx, store, Options{})
+		require.NoError(t, err)
+		assert.Len(t, reopened.Accounts(ctx), 4)
+		cash, err := reopened.GetBalance(ctx, "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "850", cash.Amount.String())
+
+		tx, err := reopened.PostJournal(ctx, "April rent", date, Debit("6000", usd(400)), Credit("1000", usd(400)))
+		require.NoError(t, err)
+		assert.Equal(t, "JE-000004", tx.ID)
+		assert.ErrorIs(t, reopened.CreateAccount(ctx, &account.Account{Code: "1000", Name: "Dup", Type: account.Asset}), ErrDuplicateCode)
+	})
+}
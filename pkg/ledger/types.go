@@ -0,0 +1,104 @@
+// Package ledger is a small facade over the accounts, transaction, validation
+// and event packages. It gives new users a working double-entry ledger in a
+// few lines:
+//
+//	l, _ := ledger.OpenLedger(ctx, memory.NewMemoryStore(), ledger.Options{})
+//	_ = l.CreateAccount(ctx, &account.Account{Code: "1000", Name: "Cash", Type: account.Asset})
+//	_ = l.CreateAccount(ctx, &account.Account{Code: "4000", Name: "Sales", Type: account.Revenue})
+//	_, _ = l.PostJournal(ctx, "Cash sale", time.Now(),
+//		ledger.Debit("1000", amount), ledger.Credit("4000", amount))
+//	tb, _ := l.TrialBalance(ctx)
+//
+// EventSourcedLedger offers the same operations over an append-only
+// LedgerEventStore, deriving balances at any point in time from the stream.
+//
+// Applications needing finer control can use the underlying packages
+// directly; the facade only wires them together.
+package ledger
+
+import (
+	"errors"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownAccount  = errors.New("unknown account")
+	ErrAccountInactive = errors.New("account is not active")
+	ErrDuplicateCode   = errors.New("duplicate account code")
+
+	ErrUnknownTransaction = errors.New("unknown transaction")
+	ErrAlreadyReversed    = errors.New("transaction is already reversed")
+	ErrDuplicateEvent     = errors.New("duplicate ledger event")
+)
+
+// DefaultCurrency is the base currency used when Options does not name one
+const DefaultCurrency = "USD"
+
+// Options configures a ledger
+type Options struct {
+	// ISO 4217 currency accounts are kept in; defaults to USD
+	BaseCurrency string
+	// Event bus posting and balance events are published to; defaults to an
+	// in-memory bus
+	Bus event.Bus
+	// Additional validators run before every journal is posted
+	Validators []validation.Validator
+	// Generates journal IDs; defaults to a sequence of the form JE-000001
+	NextID func() string
+}
+
+// Debit returns a debit entry for an account
+func Debit(accountID string, amount money.Money) transaction.Entry {
+	return transaction.Entry{AccountID: accountID, Amount: amount, Type: transaction.Debit}
+}
+
+// Credit returns a credit entry for an account
+func Credit(accountID string, amount money.Money) transaction.Entry {
+	return transaction.Entry{AccountID: accountID, Amount: amount, Type: transaction.Credit}
+}
+
+// TrialBalanceLine is the balance of one account in a trial balance. The
+// balance is shown in the debit or credit column according to its sign.
+type TrialBalanceLine struct {
+	AccountID   string              `json:"account_id"`
+	AccountCode string              `json:"account_code"`
+	AccountName string              `json:"account_name"`
+	AccountType account.AccountType `json:"account_type"`
+	Debit       money.Money         `json:"debit"`
+	Credit      money.Money         `json:"credit"`
+}
+
+// TrialBalance lists every account balance with debit and credit totals
+type TrialBalance struct {
+	AsOf         time.Time          `json:"as_of"`
+	Currency     string             `json:"currency"`
+	Lines        []TrialBalanceLine `json:"lines"`
+	TotalDebits  money.Money        `json:"total_debits"`
+	TotalCredits money.Money        `json:"total_credits"`
+}
+
+// Balanced reports whether total debits equal total credits
+func (tb *TrialBalance) Balanced() bool {
+	return tb.TotalDebits.Equal(tb.TotalCredits)
+}
+
+// normalAmount returns an entry's amount signed so that it increases the
+// normal balance of an account of the given type
+func normalAmount(t account.AccountType, entry transaction.Entry) money.Money {
+	if (entry.Type == transaction.Debit) != debitNormal(t) {
+		return entry.Amount.Multiply(decimal.NewFromInt(-1))
+	}
+	return entry.Amount
+}
+
+// debitNormal reports whether an account type increases with debits
+func debitNormal(t account.AccountType) bool {
+	return t == account.Asset || t == account.Expense
+}
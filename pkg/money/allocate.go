@@ -0,0 +1,81 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Allocate distributes the amount in proportion to the ratios without losing
+// or creating minor units. Units left over after the proportional shares are
+// rounded down go to the shares with the largest remainders, earliest first.
+func (m Money) Allocate(ratios []int) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("%w: at least one ratio is required", ErrInvalidAmount)
+	}
+	total := int64(0)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("%w: ratios cannot be negative", ErrInvalidAmount)
+		}
+		total += int64(r)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: ratios must not all be zero", ErrInvalidAmount)
+	}
+
+	scale := m.Scale()
+	units := m.Amount.Abs().Shift(scale)
+	if !units.Equal(units.Truncate(0)) {
+		return nil, fmt.Errorf("%w: %s has more than %d decimal places for %s",
+			ErrInvalidAmount, m.Amount.String(), scale, m.Currency)
+	}
+
+	whole := units.BigInt()
+	denominator := big.NewInt(total)
+	shares := make([]*big.Int, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	allocated := new(big.Int)
+	for i, r := range ratios {
+		product := new(big.Int).Mul(whole, big.NewInt(int64(r)))
+		shares[i], remainders[i] = new(big.Int).QuoRem(product, denominator, new(big.Int))
+		allocated.Add(allocated, shares[i])
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	leftover := new(big.Int).Sub(whole, allocated).Int64()
+	for i := int64(0); i < leftover; i++ {
+		shares[order[i]].Add(shares[order[i]], big.NewInt(1))
+	}
+
+	result := make([]Money, len(shares))
+	for i, share := range shares {
+		amount := decimal.NewFromBigInt(share, -scale)
+		if m.Amount.IsNegative() {
+			amount = amount.Neg()
+		}
+		result[i] = Money{Amount: amount, Currency: m.Currency}
+	}
+	return result, nil
+}
+
+// Split divides the amount into n parts that differ by at most one minor
+// unit and add up to the original amount
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: cannot split into %d parts", ErrInvalidAmount, n)
+	}
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios)
+}
@@ -0,0 +1,50 @@
+package money
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Converter converts monetary values between currencies
+type Converter interface {
+	// Convert returns the amount expressed in the target currency at the
+	// rate in effect at asOf, rounded to the target currency's minor units
+	Convert(ctx context.Context, amount Money, targetCurrency string, asOf time.Time) (Money, error)
+}
+
+// RateConverter converts using rates from an ExchangeRateProvider
+type RateConverter struct {
+	provider ExchangeRateProvider
+}
+
+// NewConverter creates a converter backed by a rate provider
+func NewConverter(provider ExchangeRateProvider) *RateConverter {
+	return &RateConverter{provider: provider}
+}
+
+// Convert implements Converter
+func (c *RateConverter) Convert(ctx context.Context, amount Money, targetCurrency string, asOf time.Time) (Money, error) {
+	if amount.Currency == targetCurrency {
+		return amount, nil
+	}
+
+	rate, err := c.provider.Rate(ctx, amount.Currency, targetCurrency, asOf)
+	if err != nil {
+		return Money{}, fmt.Errorf("error converting %s to %s: %w", amount.Currency, targetCurrency, err)
+	}
+	converted := Money{Amount: amount.Amount.Mul(rate), Currency: targetCurrency}
+	return converted.RoundToCurrency(), nil
+}
+
+// RealizedDifference returns how much more a foreign amount is worth in a
+// base currency at the rate it was settled at than at the rate it was booked
+// at. Both values are rounded to the base currency before they are
+// compared; the difference is negative when the settled value is lower.
+func RealizedDifference(amount Money, booked, settled decimal.Decimal, base string) Money {
+	bookedValue := Money{Amount: amount.Amount.Mul(booked), Currency: base}.RoundToCurrency()
+	settledValue := Money{Amount: amount.Amount.Mul(settled), Currency: base}.RoundToCurrency()
+	return Money{Amount: settledValue.Amount.Sub(bookedValue.Amount), Currency: base}
+}
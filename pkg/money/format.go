@@ -0,0 +1,251 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// Locale formats for common locales. They use the currency's minor units.
+var locales = map[string]Format{
+	"en-US": {DecimalSeparator: ".", ThousandSeparator: ",", CurrencyScale: true},
+	"en-GB": {DecimalSeparator: ".", ThousandSeparator: ",", CurrencyScale: true},
+	"en-IN": {DecimalSeparator: ".", ThousandSeparator: ",", CurrencyScale: true},
+	"de-DE": {DecimalSeparator: ",", ThousandSeparator: ".", CurrencyScale: true, Symbol: SymbolAfter, SymbolSpace: true},
+	"de-CH": {DecimalSeparator: ".", ThousandSeparator: "'", CurrencyScale: true, Symbol: SymbolBefore, SymbolSpace: true},
+	"fr-FR": {DecimalSeparator: ",", ThousandSeparator: " ", CurrencyScale: true, Symbol: SymbolAfter, SymbolSpace: true},
+	"es-ES": {DecimalSeparator: ",", ThousandSeparator: ".", CurrencyScale: true, Symbol: SymbolAfter, SymbolSpace: true},
+	"it-IT": {DecimalSeparator: ",", ThousandSeparator: ".", CurrencyScale: true, Symbol: SymbolAfter, SymbolSpace: true},
+	"nl-NL": {DecimalSeparator: ",", ThousandSeparator: ".", CurrencyScale: true, Symbol: SymbolBefore, SymbolSpace: true},
+	"pt-BR": {DecimalSeparator: ",", ThousandSeparator: ".", CurrencyScale: true, Symbol: SymbolBefore, SymbolSpace: true},
+	"ja-JP": {DecimalSeparator: ".", ThousandSeparator: ",", CurrencyScale: true},
+}
+
+// DefaultFormat renders amounts like $1,234.56
+var DefaultFormat = locales["en-US"]
+
+// LocaleFormat returns the format for a locale such as "de-DE"
+func LocaleFormat(locale string) (Format, bool) {
+	f, ok := locales[locale]
+	return f, ok
+}
+
+// Format renders the amount using the given format
+func (m Money) Format(f Format) string {
+	places := int32(f.DecimalPlaces)
+	if f.CurrencyScale {
+		places = m.Scale()
+	}
+	decimalSep := f.DecimalSeparator
+	if decimalSep == "" {
+		decimalSep = "."
+	}
+
+	digits := m.Amount.Abs().StringFixed(places)
+	whole, fraction, _ := strings.Cut(digits, ".")
+	number := group(whole, f.ThousandSeparator)
+	if fraction != "" {
+		number += decimalSep + fraction
+	}
+
+	if symbol, before := m.symbol(f); symbol != "" {
+		switch {
+		case before && f.SymbolSpace:
+			number = symbol + " " + number
+		case before:
+			number = symbol + number
+		default:
+			number = number + " " + symbol
+		}
+	}
+
+	if !m.Amount.Round(places).IsNegative() {
+		return number
+	}
+	switch f.Negative {
+	case NegativeParentheses:
+		return "(" + number + ")"
+	case NegativeTrailing:
+		return number + "-"
+	default:
+		return "-" + number
+	}
+}
+
+// String renders the amount with the default format
+func (m Money) String() string {
+	return m.Format(DefaultFormat)
+}
+
+// symbol returns the symbol to render and whether it goes before the amount
+func (m Money) symbol(f Format) (string, bool) {
+	if f.Symbol == SymbolNone {
+		return "", false
+	}
+
+	c, ok := DefaultRegistry.Lookup(m.Currency)
+	symbol := c.Symbol
+	if f.UseCode || !ok || symbol == "" {
+		symbol = m.Currency
+	}
+	before := ok && c.SymbolPrefix && !f.UseCode
+	switch f.Symbol {
+	case SymbolBefore:
+		before = true
+	case SymbolAfter:
+		before = false
+	}
+	if f.UseCode && f.Symbol == SymbolDefault {
+		// Codes read best after the amount unless placed explicitly
+		before = false
+	}
+	return symbol, before
+}
+
+// group inserts a thousands separator every three digits
+func group(whole, sep string) string {
+	if sep == "" || len(whole) <= 3 {
+		return whole
+	}
+	var b strings.Builder
+	lead := len(whole) % 3
+	if lead > 0 {
+		b.WriteString(whole[:lead])
+	}
+	for i := lead; i < len(whole); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(whole[i : i+3])
+	}
+	return b.String()
+}
+
+// Parse reads an amount written in any common style, such as "$1,234.56",
+// "1.234,56 €", "(45.00)" or "USD 12". The decimal separator is inferred:
+// when both "." and "," appear the last one is decimal, and a single
+// separator followed by exactly three digits is a thousands separator unless
+// the currency has three or more minor units.
+func Parse(s string, currency string) (Money, error) {
+	number, negative, err := clean(s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	decimalSep := inferDecimalSeparator(number, currency)
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case string(r) == decimalSep:
+			b.WriteRune('.')
+		}
+	}
+	return build(b.String(), negative, currency, s)
+}
+
+// ParseFormat reads an amount written with a specific format
+func ParseFormat(s string, currency string, f Format) (Money, error) {
+	number, negative, err := clean(s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	decimalSep := f.DecimalSeparator
+	if decimalSep == "" {
+		decimalSep = "."
+	}
+	if f.ThousandSeparator != "" {
+		number = strings.ReplaceAll(number, f.ThousandSeparator, "")
+	}
+	number = strings.Replace(number, decimalSep, ".", 1)
+	return build(number, negative, currency, s)
+}
+
+// clean strips the currency symbol or code, whitespace and sign markers,
+// returning the remaining number and whether it was negative
+func clean(s string, currency string) (string, bool, error) {
+	text := strings.TrimSpace(s)
+	negative := false
+
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		negative = true
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+
+	text = strings.ReplaceAll(text, currency, "")
+	if c, ok := DefaultRegistry.Lookup(currency); ok && c.Symbol != "" {
+		text = strings.ReplaceAll(text, c.Symbol, "")
+	}
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+
+	if strings.HasPrefix(text, "-") {
+		negative = !negative
+		text = text[1:]
+	} else if strings.HasSuffix(text, "-") {
+		negative = !negative
+		text = text[:len(text)-1]
+	} else if strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+
+	if text == "" || !unicode.IsDigit(rune(text[0])) && text[0] != '.' && text[0] != ',' {
+		return "", false, fmt.Errorf("%w: cannot parse %q", ErrInvalidAmount, s)
+	}
+	for _, r := range text {
+		if !unicode.IsDigit(r) && !strings.ContainsRune(".,'", r) {
+			return "", false, fmt.Errorf("%w: cannot parse %q", ErrInvalidAmount, s)
+		}
+	}
+	return text, negative, nil
+}
+
+func inferDecimalSeparator(number, currency string) string {
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return "."
+		}
+		return ","
+	case lastDot < 0 && lastComma < 0:
+		return ""
+	}
+
+	sep, at := ".", lastDot
+	if lastComma >= 0 {
+		sep, at = ",", lastComma
+	}
+	if strings.Count(number, sep) > 1 {
+		return ""
+	}
+	scale := Money{Currency: currency}.Scale()
+	if len(number)-at-1 == 3 && scale < 3 {
+		return ""
+	}
+	return sep
+}
+
+func build(number string, negative bool, currency string, original string) (Money, error) {
+	amount, err := decimal.NewFromString(number)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: cannot parse %q", ErrInvalidAmount, original)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+	m := Money{Amount: amount, Currency: currency}
+	if err := checkScale(m); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
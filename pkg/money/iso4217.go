@@ -0,0 +1,177 @@
+package money
+
+// iso4217 lists the ISO 4217 currencies with their minor units. Withdrawn
+// currencies are kept so historical balances remain readable but are marked
+// inactive.
+var iso4217 = []Currency{
+	{Code: "AED", Name: "UAE Dirham", DefaultScale: 2, Symbol: "د.إ", Active: true},
+	{Code: "AFN", Name: "Afghani", DefaultScale: 2, Symbol: "؋", Active: true},
+	{Code: "ALL", Name: "Lek", DefaultScale: 2, Symbol: "L", Active: true},
+	{Code: "AMD", Name: "Armenian Dram", DefaultScale: 2, Symbol: "֏", Active: true},
+	{Code: "ANG", Name: "Netherlands Antillean Guilder", DefaultScale: 2, Symbol: "ƒ", SymbolPrefix: true},
+	{Code: "AOA", Name: "Kwanza", DefaultScale: 2, Symbol: "Kz", Active: true},
+	{Code: "ARS", Name: "Argentine Peso", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "AUD", Name: "Australian Dollar", DefaultScale: 2, Symbol: "A$", SymbolPrefix: true, Active: true},
+	{Code: "AWG", Name: "Aruban Florin", DefaultScale: 2, Symbol: "ƒ", SymbolPrefix: true, Active: true},
+	{Code: "AZN", Name: "Azerbaijan Manat", DefaultScale: 2, Symbol: "₼", Active: true},
+	{Code: "BAM", Name: "Convertible Mark", DefaultScale: 2, Symbol: "KM", Active: true},
+	{Code: "BBD", Name: "Barbados Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "BDT", Name: "Taka", DefaultScale: 2, Symbol: "৳", SymbolPrefix: true, Active: true},
+	{Code: "BGN", Name: "Bulgarian Lev", DefaultScale: 2, Symbol: "лв", Active: true},
+	{Code: "BHD", Name: "Bahraini Dinar", DefaultScale: 3, Symbol: ".د.ب", Active: true},
+	{Code: "BIF", Name: "Burundi Franc", DefaultScale: 0, Symbol: "FBu", Active: true},
+	{Code: "BMD", Name: "Bermudian Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "BND", Name: "Brunei Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "BOB", Name: "Boliviano", DefaultScale: 2, Symbol: "Bs.", SymbolPrefix: true, Active: true},
+	{Code: "BOV", Name: "Mvdol", DefaultScale: 2, Active: true},
+	{Code: "BRL", Name: "Brazilian Real", DefaultScale: 2, Symbol: "R$", SymbolPrefix: true, Active: true},
+	{Code: "BSD", Name: "Bahamian Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "BTN", Name: "Ngultrum", DefaultScale: 2, Symbol: "Nu.", SymbolPrefix: true, Active: true},
+	{Code: "BWP", Name: "Pula", DefaultScale: 2, Symbol: "P", SymbolPrefix: true, Active: true},
+	{Code: "BYN", Name: "Belarusian Ruble", DefaultScale: 2, Symbol: "Br", Active: true},
+	{Code: "BZD", Name: "Belize Dollar", DefaultScale: 2, Symbol: "BZ$", SymbolPrefix: true, Active: true},
+	{Code: "CAD", Name: "Canadian Dollar", DefaultScale: 2, Symbol: "CA$", SymbolPrefix: true, Active: true},
+	{Code: "CDF", Name: "Congolese Franc", DefaultScale: 2, Symbol: "FC", Active: true},
+	{Code: "CHE", Name: "WIR Euro", DefaultScale: 2, Active: true},
+	{Code: "CHF", Name: "Swiss Franc", DefaultScale: 2, Symbol: "CHF", SymbolPrefix: true, Active: true},
+	{Code: "CHW", Name: "WIR Franc", DefaultScale: 2, Active: true},
+	{Code: "CLF", Name: "Unidad de Fomento", DefaultScale: 4, Symbol: "UF", SymbolPrefix: true, Active: true},
+	{Code: "CLP", Name: "Chilean Peso", DefaultScale: 0, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "CNY", Name: "Yuan Renminbi", DefaultScale: 2, Symbol: "¥", SymbolPrefix: true, Active: true},
+	{Code: "COP", Name: "Colombian Peso", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "COU", Name: "Unidad de Valor Real", DefaultScale: 2, Active: true},
+	{Code: "CRC", Name: "Costa Rican Colon", DefaultScale: 2, Symbol: "₡", SymbolPrefix: true, Active: true},
+	{Code: "CUC", Name: "Peso Convertible", DefaultScale: 2, Symbol: "$", SymbolPrefix: true},
+	{Code: "CUP", Name: "Cuban Peso", DefaultScale: 2, Symbol: "₱", SymbolPrefix: true, Active: true},
+	{Code: "CVE", Name: "Cabo Verde Escudo", DefaultScale: 2, Symbol: "$", Active: true},
+	{Code: "CZK", Name: "Czech Koruna", DefaultScale: 2, Symbol: "Kč", Active: true},
+	{Code: "DJF", Name: "Djibouti Franc", DefaultScale: 0, Symbol: "Fdj", Active: true},
+	{Code: "DKK", Name: "Danish Krone", DefaultScale: 2, Symbol: "kr", Active: true},
+	{Code: "DOP", Name: "Dominican Peso", DefaultScale: 2, Symbol: "RD$", SymbolPrefix: true, Active: true},
+	{Code: "DZD", Name: "Algerian Dinar", DefaultScale: 2, Symbol: "د.ج", Active: true},
+	{Code: "EGP", Name: "Egyptian Pound", DefaultScale: 2, Symbol: "E£", SymbolPrefix: true, Active: true},
+	{Code: "ERN", Name: "Nakfa", DefaultScale: 2, Symbol: "Nfk", Active: true},
+	{Code: "ETB", Name: "Ethiopian Birr", DefaultScale: 2, Symbol: "Br", Active: true},
+	{Code: "EUR", Name: "Euro", DefaultScale: 2, Symbol: "€", SymbolPrefix: true, Active: true},
+	{Code: "FJD", Name: "Fiji Dollar", DefaultScale: 2, Symbol: "FJ$", SymbolPrefix: true, Active: true},
+	{Code: "FKP", Name: "Falkland Islands Pound", DefaultScale: 2, Symbol: "£", SymbolPrefix: true, Active: true},
+	{Code: "GBP", Name: "Pound Sterling", DefaultScale: 2, Symbol: "£", SymbolPrefix: true, Active: true},
+	{Code: "GEL", Name: "Lari", DefaultScale: 2, Symbol: "₾", Active: true},
+	{Code: "GHS", Name: "Ghana Cedi", DefaultScale: 2, Symbol: "GH₵", SymbolPrefix: true, Active: true},
+	{Code: "GIP", Name: "Gibraltar Pound", DefaultScale: 2, Symbol: "£", SymbolPrefix: true, Active: true},
+	{Code: "GMD", Name: "Dalasi", DefaultScale: 2, Symbol: "D", Active: true},
+	{Code: "GNF", Name: "Guinean Franc", DefaultScale: 0, Symbol: "FG", Active: true},
+	{Code: "GTQ", Name: "Quetzal", DefaultScale: 2, Symbol: "Q", SymbolPrefix: true, Active: true},
+	{Code: "GYD", Name: "Guyana Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "HKD", Name: "Hong Kong Dollar", DefaultScale: 2, Symbol: "HK$", SymbolPrefix: true, Active: true},
+	{Code: "HNL", Name: "Lempira", DefaultScale: 2, Symbol: "L", SymbolPrefix: true, Active: true},
+	{Code: "HRK", Name: "Kuna", DefaultScale: 2, Symbol: "kn"},
+	{Code: "HTG", Name: "Gourde", DefaultScale: 2, Symbol: "G", Active: true},
+	{Code: "HUF", Name: "Forint", DefaultScale: 2, Symbol: "Ft", Active: true},
+	{Code: "IDR", Name: "Rupiah", DefaultScale: 2, Symbol: "Rp", SymbolPrefix: true, Active: true},
+	{Code: "ILS", Name: "New Israeli Sheqel", DefaultScale: 2, Symbol: "₪", SymbolPrefix: true, Active: true},
+	{Code: "INR", Name: "Indian Rupee", DefaultScale: 2, Symbol: "₹", SymbolPrefix: true, Active: true},
+	{Code: "IQD", Name: "Iraqi Dinar", DefaultScale: 3, Symbol: "ع.د", Active: true},
+	{Code: "IRR", Name: "Iranian Rial", DefaultScale: 2, Symbol: "﷼", Active: true},
+	{Code: "ISK", Name: "Iceland Krona", DefaultScale: 0, Symbol: "kr", Active: true},
+	{Code: "JMD", Name: "Jamaican Dollar", DefaultScale: 2, Symbol: "J$", SymbolPrefix: true, Active: true},
+	{Code: "JOD", Name: "Jordanian Dinar", DefaultScale: 3, Symbol: "د.ا", Active: true},
+	{Code: "JPY", Name: "Yen", DefaultScale: 0, Symbol: "¥", SymbolPrefix: true, Active: true},
+	{Code: "KES", Name: "Kenyan Shilling", DefaultScale: 2, Symbol: "KSh", SymbolPrefix: true, Active: true},
+	{Code: "KGS", Name: "Som", DefaultScale: 2, Symbol: "с", Active: true},
+	{Code: "KHR", Name: "Riel", DefaultScale: 2, Symbol: "៛", Active: true},
+	{Code: "KMF", Name: "Comorian Franc", DefaultScale: 0, Symbol: "CF", Active: true},
+	{Code: "KPW", Name: "North Korean Won", DefaultScale: 2, Symbol: "₩", SymbolPrefix: true, Active: true},
+	{Code: "KRW", Name: "Won", DefaultScale: 0, Symbol: "₩", SymbolPrefix: true, Active: true},
+	{Code: "KWD", Name: "Kuwaiti Dinar", DefaultScale: 3, Symbol: "د.ك", Active: true},
+	{Code: "KYD", Name: "Cayman Islands Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "KZT", Name: "Tenge", DefaultScale: 2, Symbol: "₸", Active: true},
+	{Code: "LAK", Name: "Lao Kip", DefaultScale: 2, Symbol: "₭", SymbolPrefix: true, Active: true},
+	{Code: "LBP", Name: "Lebanese Pound", DefaultScale: 2, Symbol: "ل.ل", Active: true},
+	{Code: "LKR", Name: "Sri Lanka Rupee", DefaultScale: 2, Symbol: "Rs", SymbolPrefix: true, Active: true},
+	{Code: "LRD", Name: "Liberian Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "LSL", Name: "Loti", DefaultScale: 2, Symbol: "L", Active: true},
+	{Code: "LYD", Name: "Libyan Dinar", DefaultScale: 3, Symbol: "ل.د", Active: true},
+	{Code: "MAD", Name: "Moroccan Dirham", DefaultScale: 2, Symbol: "د.م.", Active: true},
+	{Code: "MDL", Name: "Moldovan Leu", DefaultScale: 2, Symbol: "L", Active: true},
+	{Code: "MGA", Name: "Malagasy Ariary", DefaultScale: 2, Symbol: "Ar", Active: true},
+	{Code: "MKD", Name: "Denar", DefaultScale: 2, Symbol: "ден", Active: true},
+	{Code: "MMK", Name: "Kyat", DefaultScale: 2, Symbol: "K", Active: true},
+	{Code: "MNT", Name: "Tugrik", DefaultScale: 2, Symbol: "₮", SymbolPrefix: true, Active: true},
+	{Code: "MOP", Name: "Pataca", DefaultScale: 2, Symbol: "MOP$", SymbolPrefix: true, Active: true},
+	{Code: "MRU", Name: "Ouguiya", DefaultScale: 2, Symbol: "UM", Active: true},
+	{Code: "MUR", Name: "Mauritius Rupee", DefaultScale: 2, Symbol: "₨", SymbolPrefix: true, Active: true},
+	{Code: "MVR", Name: "Rufiyaa", DefaultScale: 2, Symbol: "Rf", Active: true},
+	{Code: "MWK", Name: "Malawi Kwacha", DefaultScale: 2, Symbol: "MK", SymbolPrefix: true, Active: true},
+	{Code: "MXN", Name: "Mexican Peso", DefaultScale: 2, Symbol: "MX$", SymbolPrefix: true, Active: true},
+	{Code: "MXV", Name: "Mexican Unidad de Inversion (UDI)", DefaultScale: 2, Active: true},
+	{Code: "MYR", Name: "Malaysian Ringgit", DefaultScale: 2, Symbol: "RM", SymbolPrefix: true, Active: true},
+	{Code: "MZN", Name: "Mozambique Metical", DefaultScale: 2, Symbol: "MT", Active: true},
+	{Code: "NAD", Name: "Namibia Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "NGN", Name: "Naira", DefaultScale: 2, Symbol: "₦", SymbolPrefix: true, Active: true},
+	{Code: "NIO", Name: "Cordoba Oro", DefaultScale: 2, Symbol: "C$", SymbolPrefix: true, Active: true},
+	{Code: "NOK", Name: "Norwegian Krone", DefaultScale: 2, Symbol: "kr", Active: true},
+	{Code: "NPR", Name: "Nepalese Rupee", DefaultScale: 2, Symbol: "₨", SymbolPrefix: true, Active: true},
+	{Code: "NZD", Name: "New Zealand Dollar", DefaultScale: 2, Symbol: "NZ$", SymbolPrefix: true, Active: true},
+	{Code: "OMR", Name: "Rial Omani", DefaultScale: 3, Symbol: "ر.ع.", Active: true},
+	{Code: "PAB", Name: "Balboa", DefaultScale: 2, Symbol: "B/.", SymbolPrefix: true, Active: true},
+	{Code: "PEN", Name: "Sol", DefaultScale: 2, Symbol: "S/", SymbolPrefix: true, Active: true},
+	{Code: "PGK", Name: "Kina", DefaultScale: 2, Symbol: "K", SymbolPrefix: true, Active: true},
+	{Code: "PHP", Name: "Philippine Peso", DefaultScale: 2, Symbol: "₱", SymbolPrefix: true, Active: true},
+	{Code: "PKR", Name: "Pakistan Rupee", DefaultScale: 2, Symbol: "₨", SymbolPrefix: true, Active: true},
+	{Code: "PLN", Name: "Zloty", DefaultScale: 2, Symbol: "zł", Active: true},
+	{Code: "PYG", Name: "Guarani", DefaultScale: 0, Symbol: "₲", SymbolPrefix: true, Active: true},
+	{Code: "QAR", Name: "Qatari Rial", DefaultScale: 2, Symbol: "ر.ق", Active: true},
+	{Code: "RON", Name: "Romanian Leu", DefaultScale: 2, Symbol: "lei", Active: true},
+	{Code: "RSD", Name: "Serbian Dinar", DefaultScale: 2, Symbol: "дин.", Active: true},
+	{Code: "RUB", Name: "Russian Ruble", DefaultScale: 2, Symbol: "₽", Active: true},
+	{Code: "RWF", Name: "Rwanda Franc", DefaultScale: 0, Symbol: "FRw", Active: true},
+	{Code: "SAR", Name: "Saudi Riyal", DefaultScale: 2, Symbol: "ر.س", Active: true},
+	{Code: "SBD", Name: "Solomon Islands Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "SCR", Name: "Seychelles Rupee", DefaultScale: 2, Symbol: "₨", SymbolPrefix: true, Active: true},
+	{Code: "SDG", Name: "Sudanese Pound", DefaultScale: 2, Symbol: "ج.س.", Active: true},
+	{Code: "SEK", Name: "Swedish Krona", DefaultScale: 2, Symbol: "kr", Active: true},
+	{Code: "SGD", Name: "Singapore Dollar", DefaultScale: 2, Symbol: "S$", SymbolPrefix: true, Active: true},
+	{Code: "SHP", Name: "Saint Helena Pound", DefaultScale: 2, Symbol: "£", SymbolPrefix: true, Active: true},
+	{Code: "SLE", Name: "Leone", DefaultScale: 2, Symbol: "Le", SymbolPrefix: true, Active: true},
+	{Code: "SLL", Name: "Leone (old)", DefaultScale: 2, Symbol: "Le", SymbolPrefix: true},
+	{Code: "SOS", Name: "Somali Shilling", DefaultScale: 2, Symbol: "Sh", SymbolPrefix: true, Active: true},
+	{Code: "SRD", Name: "Surinam Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "SSP", Name: "South Sudanese Pound", DefaultScale: 2, Symbol: "£", SymbolPrefix: true, Active: true},
+	{Code: "STN", Name: "Dobra", DefaultScale: 2, Symbol: "Db", Active: true},
+	{Code: "SVC", Name: "El Salvador Colon", DefaultScale: 2, Symbol: "₡", SymbolPrefix: true, Active: true},
+	{Code: "SYP", Name: "Syrian Pound", DefaultScale: 2, Symbol: "£S", SymbolPrefix: true, Active: true},
+	{Code: "SZL", Name: "Lilangeni", DefaultScale: 2, Symbol: "E", SymbolPrefix: true, Active: true},
+	{Code: "THB", Name: "Baht", DefaultScale: 2, Symbol: "฿", SymbolPrefix: true, Active: true},
+	{Code: "TJS", Name: "Somoni", DefaultScale: 2, Symbol: "SM", Active: true},
+	{Code: "TMT", Name: "Turkmenistan New Manat", DefaultScale: 2, Symbol: "m", Active: true},
+	{Code: "TND", Name: "Tunisian Dinar", DefaultScale: 3, Symbol: "د.ت", Active: true},
+	{Code: "TOP", Name: "Pa'anga", DefaultScale: 2, Symbol: "T$", SymbolPrefix: true, Active: true},
+	{Code: "TRY", Name: "Turkish Lira", DefaultScale: 2, Symbol: "₺", SymbolPrefix: true, Active: true},
+	{Code: "TTD", Name: "Trinidad and Tobago Dollar", DefaultScale: 2, Symbol: "TT$", SymbolPrefix: true, Active: true},
+	{Code: "TWD", Name: "New Taiwan Dollar", DefaultScale: 2, Symbol: "NT$", SymbolPrefix: true, Active: true},
+	{Code: "TZS", Name: "Tanzanian Shilling", DefaultScale: 2, Symbol: "TSh", SymbolPrefix: true, Active: true},
+	{Code: "UAH", Name: "Hryvnia", DefaultScale: 2, Symbol: "₴", SymbolPrefix: true, Active: true},
+	{Code: "UGX", Name: "Uganda Shilling", DefaultScale: 0, Symbol: "USh", SymbolPrefix: true, Active: true},
+	{Code: "USD", Name: "US Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "USN", Name: "US Dollar (Next day)", DefaultScale: 2, Symbol: "$", SymbolPrefix: true, Active: true},
+	{Code: "UYI", Name: "Uruguay Peso en Unidades Indexadas (UI)", DefaultScale: 0, Active: true},
+	{Code: "UYU", Name: "Peso Uruguayo", DefaultScale: 2, Symbol: "$U", SymbolPrefix: true, Active: true},
+	{Code: "UYW", Name: "Unidad Previsional", DefaultScale: 4, Active: true},
+	{Code: "UZS", Name: "Uzbekistan Sum", DefaultScale: 2, Symbol: "soʻm", Active: true},
+	{Code: "VED", Name: "Bolívar Soberano", DefaultScale: 2, Symbol: "Bs.D", SymbolPrefix: true, Active: true},
+	{Code: "VES", Name: "Bolívar Soberano", DefaultScale: 2, Symbol: "Bs.S", SymbolPrefix: true, Active: true},
+	{Code: "VND", Name: "Dong", DefaultScale: 0, Symbol: "₫", Active: true},
+	{Code: "VUV", Name: "Vatu", DefaultScale: 0, Symbol: "VT", Active: true},
+	{Code: "WST", Name: "Tala", DefaultScale: 2, Symbol: "WS$", SymbolPrefix: true, Active: true},
+	{Code: "XAF", Name: "CFA Franc BEAC", DefaultScale: 0, Symbol: "FCFA", Active: true},
+	{Code: "XCD", Name: "East Caribbean Dollar", DefaultScale: 2, Symbol: "EC$", SymbolPrefix: true, Active: true},
+	{Code: "XCG", Name: "Caribbean Guilder", DefaultScale: 2, Symbol: "Cg", SymbolPrefix: true, Active: true},
+	{Code: "XOF", Name: "CFA Franc BCEAO", DefaultScale: 0, Symbol: "CFA", Active: true},
+	{Code: "XPF", Name: "CFP Franc", DefaultScale: 0, Symbol: "₣", Active: true},
+	{Code: "YER", Name: "Yemeni Rial", DefaultScale: 2, Symbol: "﷼", Active: true},
+	{Code: "ZAR", Name: "Rand", DefaultScale: 2, Symbol: "R", SymbolPrefix: true, Active: true},
+	{Code: "ZMW", Name: "Zambian Kwacha", DefaultScale: 2, Symbol: "ZK", SymbolPrefix: true, Active: true},
+	{Code: "ZWG", Name: "Zimbabwe Gold", DefaultScale: 2, Symbol: "ZiG", Active: true},
+	{Code: "ZWL", Name: "Zimbabwe Dollar", DefaultScale: 2, Symbol: "$", SymbolPrefix: true},
+}
@@ -1,7 +1,13 @@
 package money
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
@@ -130,3 +136,345 @@ func TestFormat(t *testing.T) {
 		assert.Equal(t, uint8(2), format.DecimalPlaces)
 	})
 }
+
+func TestRateTable(t *testing.T) {
+	ctx := context.Background()
+	rates := NewRateTable()
+	rates.Set("EUR", "USD", decimal.NewFromFloat(1.25))
+
+	rate, err := rates.Rate(ctx, "EUR", "USD", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(1.25).Equal(rate))
+
+	rate, err = rates.Rate(ctx, "USD", "EUR", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(0.8).Equal(rate))
+
+	rate, err = rates.Rate(ctx, "JPY", "JPY", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1).Equal(rate))
+
+	_, err = rates.Rate(ctx, "USD", "JPY", time.Now())
+	assert.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestCurrencyRegistry(t *testing.T) {
+	registry := NewCurrencyRegistry()
+
+	usd, err := registry.Get("USD")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(2), usd.DefaultScale)
+	assert.Equal(t, "$", usd.Symbol)
+
+	jpy, ok := registry.Lookup("JPY")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(0), jpy.DefaultScale)
+
+	kwd, _ := registry.Lookup("KWD")
+	assert.Equal(t, uint8(3), kwd.DefaultScale)
+
+	hrk, _ := registry.Lookup("HRK")
+	assert.False(t, hrk.Active)
+
+	_, err = registry.Get("XYZ")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+
+	assert.NoError(t, registry.Register(Currency{Code: "PTS", Name: "Loyalty Points", DefaultScale: 0, Active: true}))
+	_, ok = registry.Lookup("PTS")
+	assert.True(t, ok)
+	assert.ErrorIs(t, registry.Register(Currency{Name: "Nameless"}), ErrInvalidCurrency)
+
+	currencies := registry.Currencies()
+	assert.Greater(t, len(currencies), 150)
+	assert.Equal(t, "AED", currencies[0].Code)
+}
+
+func TestCurrencyScale(t *testing.T) {
+	_, err := New(decimal.RequireFromString("10.25"), "USD")
+	assert.NoError(t, err)
+
+	_, err = New(decimal.RequireFromString("10.255"), "USD")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	// Trailing zeros do not count towards the scale
+	_, err = New(decimal.RequireFromString("10.500"), "USD")
+	assert.NoError(t, err)
+	_, err = New(decimal.RequireFromString("100.00"), "JPY")
+	assert.NoError(t, err)
+
+	_, err = New(decimal.RequireFromString("1.5"), "JPY")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = New(decimal.NewFromInt(1), "XYZ")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+
+	rounded := Money{Amount: decimal.RequireFromString("1.2345"), Currency: "BHD"}.RoundToCurrency()
+	assert.Equal(t, "1.235", rounded.Amount.String())
+	assert.Equal(t, "1235", Money{Amount: decimal.RequireFromString("1234.5"), Currency: "JPY"}.RoundToCurrency().Amount.String())
+
+	m := Money{Amount: decimal.RequireFromString("1.001"), Currency: "USD"}
+	_, err = m.Add(Money{Amount: decimal.NewFromInt(1), Currency: "USD"})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	sum, err := Money{Amount: decimal.RequireFromString("10.500"), Currency: "USD"}.Add(Money{Amount: decimal.NewFromInt(1), Currency: "USD"})
+	assert.NoError(t, err)
+	assert.True(t, sum.Amount.Equal(decimal.RequireFromString("11.5")))
+
+	// Unregistered currencies are not scale checked
+	points := Money{Amount: decimal.RequireFromString("1.001"), Currency: "PTS"}
+	_, err = points.Add(points)
+	assert.NoError(t, err)
+}
+
+func TestFormatting(t *testing.T) {
+	amount := func(value, currency string) Money {
+		return Money{Amount: decimal.RequireFromString(value), Currency: currency}
+	}
+	de, _ := LocaleFormat("de-DE")
+	ch, _ := LocaleFormat("de-CH")
+
+	tests := []struct {
+		money    Money
+		format   Format
+		expected string
+	}{
+		{amount("1234567.891", "USD"), DefaultFormat, "$1,234,567.89"},
+		{amount("-1234.5", "USD"), DefaultFormat, "-$1,234.50"},
+		{amount("-1234.5", "USD"), Format{DecimalSeparator: ".", ThousandSeparator: ",", CurrencyScale: true, Negative: NegativeParentheses}, "($1,234.50)"},
+		{amount("-12", "USD"), Format{CurrencyScale: true, Negative: NegativeTrailing}, "$12.00-"},
+		{amount("1234.5", "EUR"), de, "1.234,50 €"},
+		{amount("1234567", "CHF"), ch, "CHF 1'234'567.00"},
+		{amount("1234567", "JPY"), DefaultFormat, "¥1,234,567"},
+		{amount("12.5", "KWD"), DefaultFormat, "12.500 د.ك"},
+		{amount("12.5", "USD"), Format{DecimalPlaces: 1, UseCode: true, SymbolSpace: true}, "12.5 USD"},
+		{amount("12.5", "USD"), Format{DecimalPlaces: 0, Symbol: SymbolNone}, "13"},
+		{amount("-0.001", "USD"), DefaultFormat, "$0.00"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.money.Format(tt.format))
+	}
+	assert.Equal(t, "$5.00", amount("5", "USD").String())
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		expected string
+	}{
+		{"$1,234.56", "USD", "1234.56"},
+		{"1.234,56 €", "EUR", "1234.56"},
+		{"1 234,5", "EUR", "1234.5"},
+		{"(45.00)", "USD", "-45"},
+		{"USD -12", "USD", "-12"},
+		{"12.00-", "USD", "-12"},
+		{"1,234", "USD", "1234"},
+		{"1,5", "EUR", "1.5"},
+		{"1,234", "KWD", "1.234"},
+		{"1'234'567.00", "CHF", "1234567"},
+		{"¥1,234,567", "JPY", "1234567"},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.input, tt.currency)
+		if assert.NoError(t, err, tt.input) {
+			assert.Equal(t, tt.expected, m.Amount.String(), tt.input)
+			assert.Equal(t, tt.currency, m.Currency)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "$12.34.x", "1.2345"} {
+		_, err := Parse(bad, "USD")
+		assert.ErrorIs(t, err, ErrInvalidAmount, bad)
+	}
+
+	de, _ := LocaleFormat("de-DE")
+	m, err := ParseFormat("1.234", "EUR", de)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", m.Amount.String())
+
+	// Round trip
+	original := Money{Amount: decimal.RequireFromString("-98765.43"), Currency: "EUR"}
+	parsed, err := ParseFormat(original.Format(de), "EUR", de)
+	assert.NoError(t, err)
+	assert.True(t, original.Equal(parsed))
+}
+
+// rateStore keeps exchange rates in a map
+type rateStore struct {
+	rates map[string]ExchangeRate
+}
+
+func (s *rateStore) Create(ctx context.Context, entity interface{}) error {
+	rate := entity.(*ExchangeRate)
+	s.rates[rate.ID] = *rate
+	return nil
+}
+
+func (s *rateStore) Read(ctx context.Context, id string, entity interface{}) error {
+	rate, ok := s.rates[id]
+	if !ok {
+		return ErrRateNotFound
+	}
+	*(entity.(*ExchangeRate)) = rate
+	return nil
+}
+
+func (s *rateStore) Update(ctx context.Context, entity interface{}) error {
+	return s.Create(ctx, entity)
+}
+
+func TestConverter(t *testing.T) {
+	ctx := context.Background()
+	friday := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sunday := friday.AddDate(0, 0, 2)
+
+	store := &rateStore{rates: make(map[string]ExchangeRate)}
+	repo := NewRepositoryRateProvider(store)
+	assert.NoError(t, repo.SaveRate(ctx, &ExchangeRate{From: "EUR", To: "USD", Rate: decimal.RequireFromString("1.08"), AsOf: friday}))
+	assert.NoError(t, repo.SaveRate(ctx, &ExchangeRate{From: "EUR", To: "USD", Rate: decimal.RequireFromString("1.0825"), AsOf: friday}))
+	assert.Len(t, store.rates, 1)
+
+	t.Run("repository", func(t *testing.T) {
+		converter := NewConverter(repo)
+		eur := Money{Amount: decimal.RequireFromString("100.10"), Currency: "EUR"}
+
+		usd, err := converter.Convert(ctx, eur, "USD", sunday)
+		assert.NoError(t, err)
+		assert.Equal(t, "108.36", usd.Amount.String())
+		assert.Equal(t, "USD", usd.Currency)
+
+		back, err := converter.Convert(ctx, usd, "EUR", friday)
+		assert.NoError(t, err)
+		assert.Equal(t, "100.1", back.Amount.String())
+
+		_, err = converter.Convert(ctx, eur, "USD", friday.AddDate(0, 0, -1))
+		assert.ErrorIs(t, err, ErrRateNotFound)
+
+		same, err := converter.Convert(ctx, eur, "EUR", friday)
+		assert.NoError(t, err)
+		assert.True(t, same.Equal(eur))
+	})
+
+	t.Run("realized difference", func(t *testing.T) {
+		eur := Money{Amount: decimal.RequireFromString("1000.05"), Currency: "EUR"}
+		gain := RealizedDifference(eur, decimal.RequireFromString("1.08"), decimal.RequireFromString("1.1"), "USD")
+		assert.Equal(t, "20.01", gain.Amount.String())
+		assert.Equal(t, "USD", gain.Currency)
+		loss := RealizedDifference(eur, decimal.RequireFromString("1.1"), decimal.RequireFromString("1.08"), "USD")
+		assert.Equal(t, "-20.01", loss.Amount.String())
+	})
+
+	t.Run("http", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/2024-03-01", r.URL.Path)
+			if r.URL.Query().Get("base") != "GBP" {
+				http.Error(w, "unsupported", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"base":"GBP","rates":{"JPY":190.456}}`)
+		}))
+		defer server.Close()
+
+		provider := NewHTTPRateProvider(server.URL + "/v1")
+		jpy, err := NewConverter(provider).Convert(ctx, Money{Amount: decimal.NewFromInt(10), Currency: "GBP"}, "JPY", friday)
+		assert.NoError(t, err)
+		assert.Equal(t, "1905", jpy.Amount.String())
+
+		_, err = provider.Rate(ctx, "CHF", "JPY", friday)
+		assert.Error(t, err)
+
+		// The repository answers first; the web service fills the gaps
+		fallback := FallbackProvider{repo, provider}
+		rate, err := fallback.Rate(ctx, "GBP", "JPY", friday)
+		assert.NoError(t, err)
+		assert.Equal(t, "190.456", rate.String())
+		rate, err = fallback.Rate(ctx, "EUR", "USD", friday)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0825", rate.String())
+	})
+}
+
+func TestAllocate(t *testing.T) {
+	amounts := func(values []Money) []string {
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = v.Amount.String()
+		}
+		return out
+	}
+	usd := func(value string) Money {
+		return Money{Amount: decimal.RequireFromString(value), Currency: "USD"}
+	}
+
+	parts, err := usd("100").Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"33.34", "33.33", "33.33"}, amounts(parts))
+
+	parts, err = usd("0.05").Allocate([]int{3, 7})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0.02", "0.03"}, amounts(parts))
+
+	parts, err = usd("-10").Allocate([]int{1, 1, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-3.34", "-3.33", "-3.33"}, amounts(parts))
+
+	parts, err = usd("1").Allocate([]int{0, 1, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "0"}, amounts(parts))
+
+	// Largest remainder wins the leftover unit, not the first share
+	parts, err = usd("0.07").Allocate([]int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0.02", "0.05"}, amounts(parts))
+
+	yen, err := Money{Amount: decimal.NewFromInt(1000), Currency: "JPY"}.Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"334", "333", "333"}, amounts(yen))
+
+	for _, ratios := range [][]int{nil, {0, 0}, {1, -1}} {
+		_, err = usd("1").Allocate(ratios)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	}
+	_, err = usd("1.005").Split(2)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = usd("1").Split(0)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.345", RoundHalfDown, "2.34"},
+		{"-2.345", RoundHalfDown, "-2.34"},
+		{"2.3451", RoundHalfDown, "2.35"},
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.341", RoundUp, "2.35"},
+		{"-2.341", RoundUp, "-2.35"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+		{"-2.349", RoundCeiling, "-2.34"},
+		{"2.341", RoundCeiling, "2.35"},
+		{"-2.341", RoundFloor, "-2.35"},
+		{"2.349", RoundFloor, "2.34"},
+	}
+	for _, tt := range tests {
+		m := Money{Amount: decimal.RequireFromString(tt.value), Currency: "USD"}
+		assert.Equal(t, tt.expected, m.Round(2, tt.mode).Amount.String(), "%s %s", tt.value, tt.mode)
+	}
+
+	// Currencies can carry their own rounding policy
+	registry := DefaultRegistry
+	defer func() { DefaultRegistry = registry }()
+	DefaultRegistry = NewCurrencyRegistry()
+	assert.NoError(t, DefaultRegistry.Register(Currency{Code: "BNK", DefaultScale: 2, Rounding: RoundHalfEven}))
+	assert.Equal(t, "0.12", Money{Amount: decimal.RequireFromString("0.125"), Currency: "BNK"}.RoundToCurrency().Amount.String())
+	assert.Equal(t, "0.13", Money{Amount: decimal.RequireFromString("0.125"), Currency: "USD"}.RoundToCurrency().Amount.String())
+
+	assert.True(t, Money{Amount: decimal.RequireFromString("1.250"), Currency: "USD"}.ConformsToScale())
+	assert.False(t, Money{Amount: decimal.RequireFromString("1.255"), Currency: "USD"}.ConformsToScale())
+}
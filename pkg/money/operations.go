@@ -2,6 +2,8 @@ package money
 
 import (
 	"errors"
+	"fmt"
+
 	"github.com/shopspring/decimal"
 )
 
@@ -11,11 +13,73 @@ var (
 	ErrDivisionByZero      = errors.New("division by zero")
 )
 
+// New creates a monetary value after checking that the currency is known
+// and the amount fits its minor units
+func New(amount decimal.Decimal, currency string) (Money, error) {
+	m := Money{Amount: amount, Currency: currency}
+	if err := m.Validate(); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// Validate checks that the currency is registered and the amount has no
+// more decimal places than the currency's minor units. Trailing zeros do
+// not count, so 10.500 USD is valid.
+func (m Money) Validate() error {
+	c, err := DefaultRegistry.Get(m.Currency)
+	if err != nil {
+		return err
+	}
+	if !m.ConformsToScale() {
+		return fmt.Errorf("%w: %s has more than %d decimal places for %s",
+			ErrInvalidAmount, m.Amount.String(), c.DefaultScale, m.Currency)
+	}
+	return nil
+}
+
+// Scale returns the number of minor units of the currency, defaulting to
+// two for currencies that are not registered
+func (m Money) Scale() int32 {
+	if c, ok := DefaultRegistry.Lookup(m.Currency); ok {
+		return int32(c.DefaultScale)
+	}
+	return 2
+}
+
+// RoundToCurrency rounds the amount to the currency's minor units using the
+// currency's rounding mode
+func (m Money) RoundToCurrency() Money {
+	return m.Round(m.Scale(), m.roundingMode())
+}
+
+// ConformsToScale reports whether the amount has no more decimal places
+// than the currency's minor units
+func (m Money) ConformsToScale() bool {
+	return m.Amount.Equal(m.Amount.Truncate(m.Scale()))
+}
+
+// checkScale rejects amounts finer than a registered currency allows
+func checkScale(values ...Money) error {
+	for _, v := range values {
+		if _, ok := DefaultRegistry.Lookup(v.Currency); !ok {
+			continue
+		}
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Add adds two monetary values of the same currency
 func (m Money) Add(other Money) (Money, error) {
 	if m.Currency != other.Currency {
 		return Money{}, ErrMismatchedCurrencies
 	}
+	if err := checkScale(m, other); err != nil {
+		return Money{}, err
+	}
 	return Money{
 		Amount:   m.Amount.Add(other.Amount),
 		Currency: m.Currency,
@@ -27,6 +91,9 @@ func (m Money) Subtract(other Money) (Money, error) {
 	if m.Currency != other.Currency {
 		return Money{}, ErrMismatchedCurrencies
 	}
+	if err := checkScale(m, other); err != nil {
+		return Money{}, err
+	}
 	return Money{
 		Amount:   m.Amount.Sub(other.Amount),
 		Currency: m.Currency,
@@ -0,0 +1,163 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate is a rate between two currencies published for a day
+type ExchangeRate struct {
+	ID   string          `json:"id"`
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Rate decimal.Decimal `json:"rate"`
+	// Day the rate applies to
+	AsOf time.Time `json:"as_of"`
+	// Where the rate came from (e.g., "ECB")
+	Source string `json:"source,omitempty"`
+}
+
+// GetID returns the rate identifier
+func (r *ExchangeRate) GetID() string { return r.ID }
+
+// RateID identifies the stored rate between two currencies on a day
+func RateID(from, to string, day time.Time) string {
+	return fmt.Sprintf("%s/%s/%s", from, to, day.Format("2006-01-02"))
+}
+
+// RateStore is the persistence needed by RepositoryRateProvider. It is
+// satisfied by storage.Repository.
+type RateStore interface {
+	Create(ctx context.Context, entity interface{}) error
+	Read(ctx context.Context, id string, entity interface{}) error
+	Update(ctx context.Context, entity interface{}) error
+}
+
+// RepositoryRateProvider serves daily rates kept in a repository. When no
+// rate is stored for the requested day it falls back to earlier days, so
+// weekend and holiday lookups use the last published rate.
+type RepositoryRateProvider struct {
+	store RateStore
+	// Number of earlier days searched when a day has no rate
+	LookbackDays int
+}
+
+// NewRepositoryRateProvider creates a provider over a store, looking back up
+// to a week for missing days
+func NewRepositoryRateProvider(store RateStore) *RepositoryRateProvider {
+	return &RepositoryRateProvider{store: store, LookbackDays: 7}
+}
+
+// SaveRate stores or replaces the rate for its day
+func (p *RepositoryRateProvider) SaveRate(ctx context.Context, rate *ExchangeRate) error {
+	rate.ID = RateID(rate.From, rate.To, rate.AsOf)
+	var existing ExchangeRate
+	if err := p.store.Read(ctx, rate.ID, &existing); err == nil {
+		if err := p.store.Update(ctx, rate); err != nil {
+			return fmt.Errorf("failed to update exchange rate: %w", err)
+		}
+		return nil
+	}
+	if err := p.store.Create(ctx, rate); err != nil {
+		return fmt.Errorf("failed to store exchange rate: %w", err)
+	}
+	return nil
+}
+
+// Rate implements ExchangeRateProvider, using the inverse of a stored rate
+// when only the opposite direction is available
+func (p *RepositoryRateProvider) Rate(ctx context.Context, from, to string, asOf time.Time) (decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	for back := 0; back <= p.LookbackDays; back++ {
+		day := asOf.AddDate(0, 0, -back)
+
+		var rate ExchangeRate
+		if err := p.store.Read(ctx, RateID(from, to, day), &rate); err == nil {
+			return rate.Rate, nil
+		}
+		if err := p.store.Read(ctx, RateID(to, from, day), &rate); err == nil && !rate.Rate.IsZero() {
+			return decimal.NewFromInt(1).DivRound(rate.Rate, 10), nil
+		}
+	}
+	return decimal.Zero, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, asOf.Format("2006-01-02"))
+}
+
+// HTTPRateProvider fetches rates from a JSON web service. Requests are sent
+// as GET {BaseURL}/{date}?base={from}&symbols={to} and the response must
+// contain a "rates" object keyed by currency, the shape used by most public
+// rate services.
+type HTTPRateProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewHTTPRateProvider creates a provider for a rate service
+func NewHTTPRateProvider(baseURL string) *HTTPRateProvider {
+	return &HTTPRateProvider{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Rate implements ExchangeRateProvider
+func (p *HTTPRateProvider) Rate(ctx context.Context, from, to string, asOf time.Time) (decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	endpoint, err := url.JoinPath(p.BaseURL, asOf.Format("2006-01-02"))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid rate service URL: %w", err)
+	}
+	query := url.Values{"base": {from}, "symbols": {to}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error building rate request: %w", err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error fetching exchange rate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("rate service returned %s", resp.Status)
+	}
+
+	var body struct {
+		Rates map[string]decimal.Decimal `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return decimal.Zero, fmt.Errorf("error decoding exchange rate: %w", err)
+	}
+	rate, ok := body.Rates[to]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+	}
+	return rate, nil
+}
+
+// FallbackProvider asks each provider in turn until one has the rate,
+// returning the last provider's error when none does
+type FallbackProvider []ExchangeRateProvider
+
+// Rate implements ExchangeRateProvider
+func (f FallbackProvider) Rate(ctx context.Context, from, to string, asOf time.Time) (decimal.Decimal, error) {
+	err := fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+	for _, provider := range f {
+		var rate decimal.Decimal
+		if rate, err = provider.Rate(ctx, from, to, asOf); err == nil {
+			return rate, nil
+		}
+	}
+	return decimal.Zero, err
+}
@@ -0,0 +1,57 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// ExchangeRateProvider supplies exchange rates between currencies
+type ExchangeRateProvider interface {
+	// Rate returns how many units of the target currency one unit of the
+	// source currency buys at the given time
+	Rate(ctx context.Context, from, to string, asOf time.Time) (decimal.Decimal, error)
+}
+
+// RateTable is a fixed set of exchange rates. Inverse rates are derived
+// when only one direction is set.
+type RateTable struct {
+	mu    sync.RWMutex
+	rates map[string]decimal.Decimal
+}
+
+// NewRateTable creates an empty rate table
+func NewRateTable() *RateTable {
+	return &RateTable{rates: make(map[string]decimal.Decimal)}
+}
+
+// Set records the rate from one currency to another
+func (t *RateTable) Set(from, to string, rate decimal.Decimal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rates[from+"/"+to] = rate
+}
+
+// Rate implements ExchangeRateProvider. The table ignores asOf.
+func (t *RateTable) Rate(ctx context.Context, from, to string, asOf time.Time) (decimal.Decimal, error) {
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if rate, ok := t.rates[from+"/"+to]; ok {
+		return rate, nil
+	}
+	if inverse, ok := t.rates[to+"/"+from]; ok && !inverse.IsZero() {
+		return decimal.NewFromInt(1).DivRound(inverse, 10), nil
+	}
+	return decimal.Zero, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
@@ -0,0 +1,85 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrInvalidCurrency = errors.New("invalid currency")
+)
+
+// CurrencyRegistry holds the currencies known to the system
+type CurrencyRegistry struct {
+	mu         sync.RWMutex
+	currencies map[string]Currency
+}
+
+// NewCurrencyRegistry creates a registry preloaded with the ISO 4217
+// currencies
+func NewCurrencyRegistry() *CurrencyRegistry {
+	r := &CurrencyRegistry{currencies: make(map[string]Currency, len(iso4217))}
+	for _, c := range iso4217 {
+		r.currencies[c.Code] = c
+	}
+	return r
+}
+
+// DefaultRegistry is consulted by Money operations for scale and rounding
+var DefaultRegistry = NewCurrencyRegistry()
+
+// Register adds a custom currency or replaces an existing definition
+func (r *CurrencyRegistry) Register(c Currency) error {
+	if c.Code == "" || strings.TrimSpace(c.Code) != c.Code {
+		return fmt.Errorf("%w: code %q", ErrInvalidCurrency, c.Code)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.currencies[c.Code] = c
+	return nil
+}
+
+// Lookup returns a currency by code
+func (r *CurrencyRegistry) Lookup(code string) (Currency, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.currencies[code]
+	return c, ok
+}
+
+// Get returns a currency by code, or ErrUnknownCurrency
+func (r *CurrencyRegistry) Get(code string) (Currency, error) {
+	c, ok := r.Lookup(code)
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Currencies returns all registered currencies ordered by code
+func (r *CurrencyRegistry) Currencies() []Currency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	currencies := make([]Currency, 0, len(r.currencies))
+	for _, c := range r.currencies {
+		currencies = append(currencies, c)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies
+}
+
+// RegisterCurrency adds a custom currency to the default registry
+func RegisterCurrency(c Currency) error {
+	return DefaultRegistry.Register(c)
+}
+
+// LookupCurrency returns a currency from the default registry
+func LookupCurrency(code string) (Currency, bool) {
+	return DefaultRegistry.Lookup(code)
+}
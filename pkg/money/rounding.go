@@ -0,0 +1,67 @@
+package money
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// RoundingMode selects how amounts between two representable values are
+// rounded
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero: 2.345 -> 2.35, -2.345 -> -2.35
+	RoundHalfUp RoundingMode = "HALF_UP"
+	// RoundHalfDown rounds halves towards zero: 2.345 -> 2.34
+	RoundHalfDown RoundingMode = "HALF_DOWN"
+	// RoundHalfEven rounds halves to the even neighbour (banker's rounding):
+	// 2.345 -> 2.34, 2.355 -> 2.36
+	RoundHalfEven RoundingMode = "HALF_EVEN"
+	// RoundUp rounds away from zero: 2.341 -> 2.35
+	RoundUp RoundingMode = "UP"
+	// RoundDown rounds towards zero (truncates): 2.349 -> 2.34
+	RoundDown RoundingMode = "DOWN"
+	// RoundCeiling rounds towards positive infinity: -2.349 -> -2.34
+	RoundCeiling RoundingMode = "CEILING"
+	// RoundFloor rounds towards negative infinity: -2.341 -> -2.35
+	RoundFloor RoundingMode = "FLOOR"
+)
+
+// DefaultRoundingMode is used for currencies that do not set one
+const DefaultRoundingMode = RoundHalfUp
+
+// Round rounds the amount to the given number of decimal places
+func (m Money) Round(scale int32, mode RoundingMode) Money {
+	return Money{Amount: roundDecimal(m.Amount, scale, mode), Currency: m.Currency}
+}
+
+// roundingMode returns the currency's rounding mode
+func (m Money) roundingMode() RoundingMode {
+	if c, ok := DefaultRegistry.Lookup(m.Currency); ok && c.Rounding != "" {
+		return c.Rounding
+	}
+	return DefaultRoundingMode
+}
+
+func roundDecimal(d decimal.Decimal, scale int32, mode RoundingMode) decimal.Decimal {
+	switch mode {
+	case RoundHalfDown:
+		truncated := d.Truncate(scale)
+		half := decimal.New(5, -scale-1)
+		if d.Sub(truncated).Abs().Equal(half) {
+			return truncated
+		}
+		return d.Round(scale)
+	case RoundHalfEven:
+		return d.RoundBank(scale)
+	case RoundUp:
+		return d.RoundUp(scale)
+	case RoundDown:
+		return d.RoundDown(scale)
+	case RoundCeiling:
+		return d.RoundCeil(scale)
+	case RoundFloor:
+		return d.RoundFloor(scale)
+	default:
+		return d.Round(scale)
+	}
+}
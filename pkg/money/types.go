@@ -6,8 +6,8 @@ import (
 
 // Money represents a monetary value in a specific currency
 type Money struct {
-	Amount   decimal.Decimal
-	Currency string
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
 }
 
 // Currency represents a currency definition in the system
@@ -24,8 +24,34 @@ type Currency struct {
 	SymbolPrefix bool
 	// Whether this currency is still active
 	Active bool
+	// Rounding applied when amounts are rounded to the currency's scale;
+	// empty uses DefaultRoundingMode
+	Rounding RoundingMode
 }
 
+// SymbolPlacement controls where the currency symbol is rendered
+type SymbolPlacement string
+
+const (
+	// SymbolDefault follows the currency's SymbolPrefix setting
+	SymbolDefault SymbolPlacement = ""
+	SymbolBefore  SymbolPlacement = "BEFORE"
+	SymbolAfter   SymbolPlacement = "AFTER"
+	SymbolNone    SymbolPlacement = "NONE"
+)
+
+// NegativeFormat controls how negative amounts are rendered
+type NegativeFormat string
+
+const (
+	// NegativeMinus renders -$1.00
+	NegativeMinus NegativeFormat = ""
+	// NegativeParentheses renders ($1.00)
+	NegativeParentheses NegativeFormat = "PARENTHESES"
+	// NegativeTrailing renders $1.00-
+	NegativeTrailing NegativeFormat = "TRAILING"
+)
+
 // Format represents currency formatting options
 type Format struct {
 	// Decimal separator (e.g., "." or ",")
@@ -34,4 +60,15 @@ type Format struct {
 	ThousandSeparator string
 	// Number of decimal places to display
 	DecimalPlaces uint8
+	// Use the currency's minor units instead of DecimalPlaces
+	CurrencyScale bool
+	// Where the symbol is placed
+	Symbol SymbolPlacement
+	// Render the ISO code instead of the symbol
+	UseCode bool
+	// Put a space between a leading symbol and the amount; trailing
+	// symbols are always separated by a space
+	SymbolSpace bool
+	// How negative amounts are shown
+	Negative NegativeFormat
 }
@@ -0,0 +1,132 @@
+package party
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTransactionStore is a mock storage.Repository returning canned transactions
+type MockTransactionStore struct {
+	mock.Mock
+	storage.Repository
+}
+
+func (m *MockTransactionStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	args := m.Called(ctx, query, results)
+	if txs, ok := args.Get(0).([]*transaction.Transaction); ok {
+		*(results.(*[]*transaction.Transaction)) = txs
+	}
+	return args.Error(1)
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func postedTx(id string, date time.Time, entries ...transaction.Entry) *transaction.Transaction {
+	return &transaction.Transaction{ID: id, Status: transaction.Posted, Date: date, Description: id, Entries: entries}
+}
+
+func TestSubledger(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	txs := []*transaction.Transaction{
+		postedTx("INV1", day(2),
+			transaction.Entry{AccountID: "1200", Amount: usd(500), Type: transaction.Debit, PartyID: "P1"},
+			transaction.Entry{AccountID: "4000", Amount: usd(500), Type: transaction.Credit}),
+		postedTx("PMT1", day(10),
+			transaction.Entry{AccountID: "1000", Amount: usd(200), Type: transaction.Debit},
+			transaction.Entry{AccountID: "1200", Amount: usd(200), Type: transaction.Credit, PartyID: "P1"}),
+		postedTx("BILL1", day(12),
+			transaction.Entry{AccountID: "6000", Amount: usd(80), Type: transaction.Debit},
+			transaction.Entry{AccountID: "2000", Amount: usd(80), Type: transaction.Credit, PartyID: "P1"}),
+		postedTx("OTHER", day(15),
+			transaction.Entry{AccountID: "1200", Amount: usd(999), Type: transaction.Debit, PartyID: "P2"},
+			transaction.Entry{AccountID: "4000", Amount: usd(999), Type: transaction.Credit}),
+	}
+
+	txStore := &MockTransactionStore{}
+	txStore.On("Query", ctx, mock.Anything, mock.Anything).Return(txs, nil)
+
+	ledger := NewSubledger(memory.NewMemoryStore(), txStore)
+	require.NoError(t, ledger.CreateParty(ctx, &Party{
+		ID:                  "P1",
+		Name:                "Acme Corp",
+		Roles:               []Role{Customer, Vendor},
+		ReceivableAccountID: "1200",
+		PayableAccountID:    "2000",
+		Currency:            "USD",
+	}))
+
+	t.Run("validation", func(t *testing.T) {
+		err := ledger.CreateParty(ctx, &Party{ID: "P3", Name: "No Control", Roles: []Role{Customer}})
+		assert.ErrorIs(t, err, ErrInvalidParty)
+	})
+
+	t.Run("GetBalance", func(t *testing.T) {
+		balance, err := ledger.GetBalance(ctx, "P1", day(31))
+		require.NoError(t, err)
+		assert.Equal(t, "300", balance.Receivable.Amount.String())
+		assert.Equal(t, "80", balance.Payable.Amount.String())
+		assert.Equal(t, "220", balance.Net.Amount.String())
+	})
+
+	t.Run("GenerateStatement", func(t *testing.T) {
+		stmt, err := ledger.GenerateStatement(ctx, "P1", Customer, day(5), day(31))
+		require.NoError(t, err)
+		assert.Equal(t, "500", stmt.OpeningBalance.Amount.String())
+		require.Len(t, stmt.Lines, 1)
+		assert.Equal(t, "PMT1", stmt.Lines[0].TransactionID)
+		assert.Equal(t, "200", stmt.Lines[0].Credit.Amount.String())
+		assert.Equal(t, "300", stmt.ClosingBalance.Amount.String())
+
+		vendorStmt, err := ledger.GenerateStatement(ctx, "P1", Vendor, day(1), day(31))
+		require.NoError(t, err)
+		assert.Equal(t, "80", vendorStmt.ClosingBalance.Amount.String())
+	})
+
+	t.Run("GetParty not found", func(t *testing.T) {
+		_, err := ledger.GetParty(ctx, "missing")
+		assert.ErrorIs(t, err, ErrPartyNotFound)
+	})
+}
+
+func TestSubledgerReversal(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+	ledger := NewSubledger(memory.NewMemoryStore(), store)
+	require.NoError(t, ledger.CreateParty(ctx, &Party{
+		ID:                  "P1",
+		Name:                "Acme Corp",
+		Roles:               []Role{Customer},
+		ReceivableAccountID: "1200",
+		Currency:            "USD",
+	}))
+
+	invoice := postedTx("INV1", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		transaction.Entry{AccountID: "1200", Amount: usd(500), Type: transaction.Debit, PartyID: "P1"},
+		transaction.Entry{AccountID: "4000", Amount: usd(500), Type: transaction.Credit})
+	invoice.Status = transaction.Draft
+	require.NoError(t, transaction.CreateAndPost(ctx, store, processor, invoice))
+
+	balance, err := ledger.GetBalance(ctx, "P1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "500", balance.Receivable.Amount.String())
+
+	require.NoError(t, processor.ReverseTransaction(ctx, "INV1", "cancelled"))
+	balance, err = ledger.GetBalance(ctx, "P1", time.Now())
+	require.NoError(t, err)
+	assert.True(t, balance.Receivable.Amount.IsZero(), "the reversed invoice is no longer owed, got %s", balance.Receivable.Amount)
+}
@@ -0,0 +1,269 @@
+package party
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrPartyNotFound = errors.New("party not found")
+	ErrInvalidParty  = errors.New("invalid party")
+	ErrRoleNotHeld   = errors.New("party does not hold role")
+)
+
+// Manager defines the interface for party and subledger operations
+type Manager interface {
+	// CreateParty creates a new customer or vendor
+	CreateParty(ctx context.Context, party *Party) error
+
+	// GetParty retrieves a party by ID
+	GetParty(ctx context.Context, id string) (*Party, error)
+
+	// UpdateParty updates an existing party
+	UpdateParty(ctx context.Context, party *Party) error
+
+	// GetBalance derives a party's control account balances as of a date
+	GetBalance(ctx context.Context, partyID string, asOf time.Time) (*Balance, error)
+
+	// GenerateStatement lists a party's activity for a role over a period
+	GenerateStatement(ctx context.Context, partyID string, role Role, start, end time.Time) (*Statement, error)
+}
+
+// Subledger provides a storage-backed implementation of Manager. Balances are
+// derived from posted transactions whose entries reference the party and one
+// of its control accounts.
+type Subledger struct {
+	parties      storage.Repository
+	transactions storage.Repository
+}
+
+// NewSubledger creates a new Subledger
+func NewSubledger(parties storage.Repository, transactions storage.Repository) *Subledger {
+	return &Subledger{
+		parties:      parties,
+		transactions: transactions,
+	}
+}
+
+// CreateParty implements Manager.CreateParty
+func (s *Subledger) CreateParty(ctx context.Context, party *Party) error {
+	if err := validateParty(party); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if party.Status == "" {
+		party.Status = Active
+	}
+	party.Created = now
+	party.LastModified = now
+
+	if err := s.parties.Create(ctx, party); err != nil {
+		return fmt.Errorf("failed to store party: %w", err)
+	}
+	return nil
+}
+
+// GetParty implements Manager.GetParty
+func (s *Subledger) GetParty(ctx context.Context, id string) (*Party, error) {
+	var party Party
+	if err := s.parties.Read(ctx, id, &party); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPartyNotFound, id, err)
+	}
+	return &party, nil
+}
+
+// UpdateParty implements Manager.UpdateParty
+func (s *Subledger) UpdateParty(ctx context.Context, party *Party) error {
+	if err := validateParty(party); err != nil {
+		return err
+	}
+
+	party.LastModified = time.Now()
+	if err := s.parties.Update(ctx, party); err != nil {
+		return fmt.Errorf("failed to update party: %w", err)
+	}
+	return nil
+}
+
+// GetBalance implements Manager.GetBalance
+func (s *Subledger) GetBalance(ctx context.Context, partyID string, asOf time.Time) (*Balance, error) {
+	party, err := s.GetParty(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+
+	txs, err := s.getTransactions(ctx, partyID, time.Time{}, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	receivable := sumControlAccount(txs, partyID, party.ReceivableAccountID, transaction.Debit)
+	payable := sumControlAccount(txs, partyID, party.PayableAccountID, transaction.Credit)
+
+	return &Balance{
+		PartyID:    partyID,
+		AsOf:       asOf,
+		Receivable: money.Money{Amount: receivable, Currency: party.Currency},
+		Payable:    money.Money{Amount: payable, Currency: party.Currency},
+		Net:        money.Money{Amount: receivable.Sub(payable), Currency: party.Currency},
+	}, nil
+}
+
+// GenerateStatement implements Manager.GenerateStatement. Balances are shown
+// in the normal direction of the role: amounts owed by a customer and amounts
+// owed to a vendor are both positive.
+func (s *Subledger) GenerateStatement(ctx context.Context, partyID string, role Role, start, end time.Time) (*Statement, error) {
+	party, err := s.GetParty(ctx, partyID)
+	if err != nil {
+		return nil, err
+	}
+	if !party.HasRole(role) {
+		return nil, fmt.Errorf("%w: %s is not a %s", ErrRoleNotHeld, partyID, role)
+	}
+
+	txs, err := s.getTransactions(ctx, partyID, time.Time{}, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildStatement(party, role, txs, start, end), nil
+}
+
+// BuildStatement builds a party statement from a set of transactions
+func BuildStatement(party *Party, role Role, txs []*transaction.Transaction, start, end time.Time) *Statement {
+	controlAccount := party.ControlAccount(role)
+	normal := transaction.Debit
+	if role == Vendor {
+		normal = transaction.Credit
+	}
+	currency := party.Currency
+	zero := money.Money{Amount: decimal.Zero, Currency: currency}
+
+	sorted := append([]*transaction.Transaction(nil), txs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	opening := decimal.Zero
+	running := decimal.Zero
+	lines := make([]StatementLine, 0)
+	for _, tx := range sorted {
+		if tx.Status != transaction.Posted || tx.Date.After(end) {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.PartyID != party.ID || entry.AccountID != controlAccount {
+				continue
+			}
+
+			change := entry.Amount.Amount
+			if entry.Type != normal {
+				change = change.Neg()
+			}
+
+			if tx.Date.Before(start) {
+				opening = opening.Add(change)
+				running = running.Add(change)
+				continue
+			}
+
+			running = running.Add(change)
+			line := StatementLine{
+				Date:          tx.Date,
+				TransactionID: tx.ID,
+				Description:   tx.Description,
+				Debit:         zero,
+				Credit:        zero,
+				Balance:       money.Money{Amount: running, Currency: currency},
+			}
+			if entry.Type == transaction.Debit {
+				line.Debit = entry.Amount
+			} else {
+				line.Credit = entry.Amount
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	return &Statement{
+		Party:          party,
+		Role:           role,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		OpeningBalance: money.Money{Amount: opening, Currency: currency},
+		Lines:          lines,
+		ClosingBalance: money.Money{Amount: running, Currency: currency},
+	}
+}
+
+func (s *Subledger) getTransactions(ctx context.Context, partyID string, start, end time.Time) ([]*transaction.Transaction, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "entries.party_id", Operator: "=", Value: partyID},
+			{Field: "date", Operator: ">=", Value: start},
+			{Field: "date", Operator: "<=", Value: end},
+			{Field: "status", Operator: "=", Value: transaction.Posted},
+		},
+		Sort: []storage.Sort{
+			{Field: "date", Desc: false},
+		},
+	}
+
+	var txs []*transaction.Transaction
+	if err := s.transactions.Query(ctx, query, &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+	return txs, nil
+}
+
+// sumControlAccount nets a party's entries on a control account in its normal direction
+func sumControlAccount(txs []*transaction.Transaction, partyID, accountID string, normal transaction.EntryType) decimal.Decimal {
+	total := decimal.Zero
+	if accountID == "" {
+		return total
+	}
+	for _, tx := range txs {
+		if tx.Status != transaction.Posted {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.PartyID != partyID || entry.AccountID != accountID {
+				continue
+			}
+			if entry.Type == normal {
+				total = total.Add(entry.Amount.Amount)
+			} else {
+				total = total.Sub(entry.Amount.Amount)
+			}
+		}
+	}
+	return total
+}
+
+func validateParty(party *Party) error {
+	if party == nil {
+		return fmt.Errorf("%w: party cannot be nil", ErrInvalidParty)
+	}
+	if party.ID == "" || party.Name == "" {
+		return fmt.Errorf("%w: ID and name are required", ErrInvalidParty)
+	}
+	if len(party.Roles) == 0 {
+		return fmt.Errorf("%w: at least one role is required", ErrInvalidParty)
+	}
+	if party.HasRole(Customer) && party.ReceivableAccountID == "" {
+		return fmt.Errorf("%w: customers require a receivable control account", ErrInvalidParty)
+	}
+	if party.HasRole(Vendor) && party.PayableAccountID == "" {
+		return fmt.Errorf("%w: vendors require a payable control account", ErrInvalidParty)
+	}
+	return nil
+}
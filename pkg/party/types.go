@@ -0,0 +1,103 @@
+package party
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// Role describes the relationship a party has with the business
+type Role string
+
+const (
+	Customer Role = "CUSTOMER"
+	Vendor   Role = "VENDOR"
+)
+
+// Status represents the status of a party
+type Status string
+
+const (
+	Active   Status = "ACTIVE"
+	Inactive Status = "INACTIVE"
+)
+
+// Party represents a customer or vendor tracked in the subledger
+type Party struct {
+	// Unique identifier, referenced by transaction entries via PartyID
+	ID string `json:"id"`
+	// Short code used for lookups and reporting
+	Code string `json:"code"`
+	// Display name of the party
+	Name string `json:"name"`
+	// Roles the party plays; a party may be both customer and vendor
+	Roles []Role `json:"roles"`
+	// Status of the party
+	Status Status `json:"status"`
+	// Control account for amounts owed by the party (customer role)
+	ReceivableAccountID string `json:"receivable_account_id,omitempty"`
+	// Control account for amounts owed to the party (vendor role)
+	PayableAccountID string `json:"payable_account_id,omitempty"`
+	// Default currency for the party
+	Currency string `json:"currency"`
+	// When the party was created
+	Created time.Time `json:"created"`
+	// Last modification timestamp
+	LastModified time.Time `json:"last_modified"`
+	// Additional metadata for extensibility
+	MetaData map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// GetID returns the party identifier
+func (p *Party) GetID() string { return p.ID }
+
+// HasRole returns true if the party plays the given role
+func (p *Party) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ControlAccount returns the control account used for a role
+func (p *Party) ControlAccount(role Role) string {
+	if role == Vendor {
+		return p.PayableAccountID
+	}
+	return p.ReceivableAccountID
+}
+
+// Balance contains the subledger balances of a party at a point in time
+type Balance struct {
+	PartyID string    `json:"party_id"`
+	AsOf    time.Time `json:"as_of"`
+	// Amount owed by the party on its receivable control account
+	Receivable money.Money `json:"receivable"`
+	// Amount owed to the party on its payable control account
+	Payable money.Money `json:"payable"`
+	// Receivable less payable
+	Net money.Money `json:"net"`
+}
+
+// StatementLine represents a single movement on a party statement
+type StatementLine struct {
+	Date          time.Time   `json:"date"`
+	TransactionID string      `json:"transaction_id"`
+	Description   string      `json:"description"`
+	Debit         money.Money `json:"debit"`
+	Credit        money.Money `json:"credit"`
+	Balance       money.Money `json:"balance"`
+}
+
+// Statement lists a party's control account activity over a period
+type Statement struct {
+	Party          *Party          `json:"party"`
+	Role           Role            `json:"role"`
+	PeriodStart    time.Time       `json:"period_start"`
+	PeriodEnd      time.Time       `json:"period_end"`
+	OpeningBalance money.Money     `json:"opening_balance"`
+	Lines          []StatementLine `json:"lines"`
+	ClosingBalance money.Money     `json:"closing_balance"`
+}
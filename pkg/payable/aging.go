@@ -0,0 +1,171 @@
+package payable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// VendorAging is a vendor's line on an aging report
+type VendorAging struct {
+	VendorID string `json:"vendor_id"`
+	reporting.AgingBuckets
+	// Bills with an open balance, oldest due first
+	BillIDs []string `json:"bill_ids"`
+}
+
+// AgingReport lists open payables by vendor and age as of a date
+type AgingReport struct {
+	AsOf     time.Time              `json:"as_of"`
+	Currency string                 `json:"currency"`
+	Vendors  []VendorAging          `json:"vendors"`
+	Totals   reporting.AgingBuckets `json:"totals"`
+	// Balance of the payable accounts in the ledger and its difference from
+	// the aged total, when a report calculator is set
+	LedgerBalance *money.Money     `json:"ledger_balance,omitempty"`
+	Difference    *decimal.Decimal `json:"difference,omitempty"`
+}
+
+// CashRequirement is the amount due to vendors on a day
+type CashRequirement struct {
+	Date    time.Time   `json:"date"`
+	Amount  money.Money `json:"amount"`
+	BillIDs []string    `json:"bill_ids"`
+}
+
+// Aging implements Manager.Aging. Balances are those open at the end of the
+// as-of day, so payments and credit notes made later are ignored. With a
+// report calculator set, the aged total is reconciled against the balance
+// of the payable accounts the bills were posted to.
+func (m *BasicManager) Aging(ctx context.Context, asOf time.Time, currency string) (*AgingReport, error) {
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "currency", Operator: "=", Value: currency},
+			{Field: "status", Operator: "in", Value: []BillStatus{Posted, PartiallyPaid, Paid}},
+			{Field: "bill_date", Operator: "<=", Value: asOf},
+		},
+		Sort: []storage.Sort{
+			{Field: "due_date", Desc: false},
+		},
+	}
+	var bills []*Bill
+	if err := m.repo.Query(ctx, query, &bills); err != nil {
+		return nil, fmt.Errorf("error querying bills: %w", err)
+	}
+	sort.SliceStable(bills, func(i, j int) bool {
+		return bills[i].DueDate.Before(bills[j].DueDate)
+	})
+
+	report := BuildAgingReport(bills, asOf, currency)
+
+	if m.calculator != nil {
+		accounts := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, bill := range bills {
+			if bill.Currency == currency && !seen[bill.PayableAccountID] {
+				seen[bill.PayableAccountID] = true
+				accounts = append(accounts, bill.PayableAccountID)
+			}
+		}
+
+		ledger := decimal.Zero
+		for _, accountID := range accounts {
+			balance, err := m.calculator.CalculateBalance(ctx, accountID, reporting.ReportPeriod{End: asOf})
+			if err != nil {
+				return nil, fmt.Errorf("error calculating balance of %s: %w", accountID, err)
+			}
+			ledger = ledger.Add(balance.Amount)
+		}
+		difference := ledger.Sub(report.Totals.Total())
+		report.LedgerBalance = &money.Money{Amount: ledger, Currency: currency}
+		report.Difference = &difference
+	}
+	return report, nil
+}
+
+// BuildAgingReport ages the open balances of bills in a currency as of a
+// date. Vendors are listed in order of their first open bill.
+func BuildAgingReport(bills []*Bill, asOf time.Time, currency string) *AgingReport {
+	report := &AgingReport{
+		AsOf:     asOf,
+		Currency: currency,
+		Vendors:  make([]VendorAging, 0),
+	}
+	index := make(map[string]int)
+	for _, bill := range bills {
+		if bill.Currency != currency {
+			continue
+		}
+		open := bill.OpenBalanceAt(asOf).Amount
+		if !open.IsPositive() {
+			continue
+		}
+
+		i, ok := index[bill.VendorID]
+		if !ok {
+			i = len(report.Vendors)
+			index[bill.VendorID] = i
+			report.Vendors = append(report.Vendors, VendorAging{VendorID: bill.VendorID})
+		}
+		days := int(asOf.Sub(bill.DueDate).Hours() / 24)
+		report.Vendors[i].Add(days, open)
+		report.Vendors[i].BillIDs = append(report.Vendors[i].BillIDs, bill.ID)
+		report.Totals.Add(days, open)
+	}
+	return report
+}
+
+// ForecastPayments implements Manager.ForecastPayments. Open bills due by
+// the end date are totalled per due date and currency for cash planning;
+// bills already overdue on the start date are due on it.
+func (m *BasicManager) ForecastPayments(ctx context.Context, from, to time.Time, currency string) ([]CashRequirement, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("forecast ends before it starts")
+	}
+	bills, err := m.ListDueBills(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := make([]CashRequirement, 0)
+	type key struct {
+		date     time.Time
+		currency string
+	}
+	index := make(map[key]int)
+	for _, bill := range bills {
+		if currency != "" && bill.Currency != currency {
+			continue
+		}
+		due := bill.DueDate
+		if due.Before(from) {
+			due = from
+		}
+		k := key{date: due, currency: bill.Currency}
+		i, ok := index[k]
+		if !ok {
+			i = len(requirements)
+			index[k] = i
+			requirements = append(requirements, CashRequirement{
+				Date:   due,
+				Amount: money.Money{Amount: decimal.Zero, Currency: bill.Currency},
+			})
+		}
+		requirements[i].Amount.Amount = requirements[i].Amount.Amount.Add(bill.OpenBalance().Amount)
+		requirements[i].BillIDs = append(requirements[i].BillIDs, bill.ID)
+	}
+	sort.SliceStable(requirements, func(i, j int) bool {
+		return requirements[i].Date.Before(requirements[j].Date)
+	})
+	return requirements, nil
+}
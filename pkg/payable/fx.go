@@ -0,0 +1,142 @@
+package payable
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// FXConfig enables realized exchange gains and losses on bills in a
+// foreign currency
+type FXConfig struct {
+	// Currency gains and losses are measured and posted in
+	BaseCurrency string
+	// Rates used when a bill or payment does not carry its own
+	Rates         money.ExchangeRateProvider
+	GainAccountID string
+	LossAccountID string
+	// Base currency account carrying the exchange adjustment of the
+	// payable. It is required: the payable account itself holds the bill
+	// currency.
+	AdjustmentAccountID string
+}
+
+// SetFX enables realized exchange gain and loss accounting. Foreign bills
+// record the rate they were posted at, and payments settling them at
+// another rate post the difference.
+func (m *BasicManager) SetFX(cfg FXConfig) error {
+	switch {
+	case cfg.BaseCurrency == "":
+		return fmt.Errorf("%w: FX needs a base currency", ErrInvalidBill)
+	case cfg.GainAccountID == "" || cfg.LossAccountID == "":
+		return fmt.Errorf("%w: FX needs gain and loss accounts", ErrInvalidBill)
+	case cfg.AdjustmentAccountID == "":
+		return fmt.Errorf("%w: FX needs a base currency adjustment account", ErrInvalidBill)
+	}
+	m.fx = &cfg
+	return nil
+}
+
+// foreign returns true if a currency is not the base currency
+func (m *BasicManager) foreign(currency string) bool {
+	return m.fx != nil && currency != m.fx.BaseCurrency
+}
+
+// rate returns a given rate to the base currency, or looks one up
+func (m *BasicManager) rate(ctx context.Context, given decimal.Decimal, currency string, date time.Time) (decimal.Decimal, error) {
+	if given.IsPositive() {
+		return given, nil
+	}
+	rate, err := m.fx.Rates.Rate(ctx, currency, m.fx.BaseCurrency, date)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error looking up %s/%s rate: %w", currency, m.fx.BaseCurrency, err)
+	}
+	return rate, nil
+}
+
+// buildFXEntry posts the exchange difference of paying amounts of bills at
+// a settlement rate. Paying more in the base currency than the bill was
+// booked at is a loss. It returns the gain or loss per bill, and no
+// transaction when there is none.
+func (m *BasicManager) buildFXEntry(ctx context.Context, reference string, date time.Time, settled decimal.Decimal, bills []*Bill, amounts []decimal.Decimal) (*transaction.Transaction, []money.Money, error) {
+	base := m.fx.BaseCurrency
+	gains := make([]money.Money, len(bills))
+	// Net amounts per account, debits positive, as the validator allows
+	// each account only once per transaction
+	balances := make(map[string]decimal.Decimal)
+	parties := make(map[string]string)
+	order := make([]string, 0)
+	post := func(accountID string, amount decimal.Decimal, partyID string) {
+		if _, ok := balances[accountID]; !ok {
+			order = append(order, accountID)
+			parties[accountID] = partyID
+		}
+		balances[accountID] = balances[accountID].Add(amount)
+	}
+	for i, bill := range bills {
+		booked, err := m.rate(ctx, bill.ExchangeRate, bill.Currency, bill.BillDate)
+		if err != nil {
+			return nil, nil, err
+		}
+		amount := money.Money{Amount: amounts[i], Currency: bill.Currency}
+		gain := money.RealizedDifference(amount, booked, settled, base)
+		gain.Amount = gain.Amount.Neg()
+		gains[i] = gain
+		if gain.IsZero() {
+			continue
+		}
+
+		// Gains reduce the payable and losses increase it
+		post(m.fx.AdjustmentAccountID, gain.Amount, bill.VendorID)
+		if gain.IsPositive() {
+			post(m.fx.GainAccountID, gain.Amount.Neg(), "")
+		} else {
+			post(m.fx.LossAccountID, gain.Amount.Neg(), "")
+		}
+	}
+
+	entries := make([]transaction.Entry, 0, len(order))
+	for _, accountID := range order {
+		amount := balances[accountID]
+		if amount.IsZero() {
+			continue
+		}
+		entry := transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: amount.Abs(), Currency: base},
+			Type:        transaction.Debit,
+			Description: fmt.Sprintf("Exchange difference on payment %s", reference),
+			PartyID:     parties[accountID],
+		}
+		if amount.IsNegative() {
+			entry.Type = transaction.Credit
+		}
+		switch accountID {
+		case m.fx.GainAccountID:
+			entry.Description = fmt.Sprintf("Realized exchange gain on payment %s", reference)
+		case m.fx.LossAccountID:
+			entry.Description = fmt.Sprintf("Realized exchange loss on payment %s", reference)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, gains, nil
+	}
+
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s-FX", reference),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         date,
+		Description:  fmt.Sprintf("Realized exchange difference on payment %s", reference),
+		Reference:    reference,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}, gains, nil
+}
@@ -0,0 +1,161 @@
+package payable
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// Pain001Namespace is the ISO 20022 schema of the credit transfer
+// initiations written by WritePain001
+const Pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+type pain001Document struct {
+	XMLName    xml.Name          `xml:"Document"`
+	Namespace  string            `xml:"xmlns,attr"`
+	Initiation pain001Initiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type pain001Initiation struct {
+	MsgID          string         `xml:"GrpHdr>MsgId"`
+	Created        string         `xml:"GrpHdr>CreDtTm"`
+	NumberOfTxs    int            `xml:"GrpHdr>NbOfTxs"`
+	ControlSum     string         `xml:"GrpHdr>CtrlSum"`
+	InitiatingName string         `xml:"GrpHdr>InitgPty>Nm"`
+	Payment        pain001Payment `xml:"PmtInf"`
+}
+
+type pain001Payment struct {
+	ID            string            `xml:"PmtInfId"`
+	Method        string            `xml:"PmtMtd"`
+	NumberOfTxs   int               `xml:"NbOfTxs"`
+	ControlSum    string            `xml:"CtrlSum"`
+	ServiceLevel  string            `xml:"PmtTpInf>SvcLvl>Cd,omitempty"`
+	ExecutionDate string            `xml:"ReqdExctnDt"`
+	DebtorName    string            `xml:"Dbtr>Nm"`
+	DebtorIBAN    string            `xml:"DbtrAcct>Id>IBAN"`
+	DebtorAgent   pain001Agent      `xml:"DbtrAgt>FinInstnId"`
+	ChargeBearer  string            `xml:"ChrgBr"`
+	Transfers     []pain001Transfer `xml:"CdtTrfTxInf"`
+}
+
+// pain001Agent identifies a bank by BIC, or as not provided
+type pain001Agent struct {
+	BIC   string `xml:"BIC,omitempty"`
+	Other string `xml:"Othr>Id,omitempty"`
+}
+
+type pain001Transfer struct {
+	EndToEndID    string        `xml:"PmtId>EndToEndId"`
+	Amount        pain001Amount `xml:"Amt>InstdAmt"`
+	CreditorAgent *pain001Agent `xml:"CdtrAgt>FinInstnId,omitempty"`
+	CreditorName  string        `xml:"Cdtr>Nm"`
+	CreditorIBAN  string        `xml:"CdtrAcct>Id>IBAN"`
+	Remittance    string        `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+type pain001Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// WritePain001 writes an executed payment run as an ISO 20022 pain.001
+// customer credit transfer initiation for the debtor's bank, one transfer
+// per batch. Only executed runs, whose payments have been posted, can be
+// sent. Runs in euro are flagged for SEPA.
+func WritePain001(w io.Writer, run *PaymentRun, created time.Time) error {
+	if run.Status != RunExecuted {
+		return fmt.Errorf("%w: payment run %s has not been executed", ErrInvalidStatus, run.ID)
+	}
+	if run.Debtor.IBAN == "" {
+		return fmt.Errorf("%w: debtor of payment run %s", ErrMissingBankInfo, run.ID)
+	}
+
+	total := decimal.Zero
+	payment := pain001Payment{
+		ID:            truncate(run.ID, 35),
+		Method:        "TRF",
+		NumberOfTxs:   len(run.Batches),
+		ExecutionDate: run.ExecutionDate.Format("2006-01-02"),
+		DebtorName:    truncate(run.Debtor.Name, 70),
+		DebtorIBAN:    run.Debtor.IBAN,
+		DebtorAgent:   agent(run.Debtor.BIC),
+		ChargeBearer:  "SLEV",
+		Transfers:     make([]pain001Transfer, 0, len(run.Batches)),
+	}
+	if run.Currency == "EUR" {
+		payment.ServiceLevel = "SEPA"
+	}
+	for _, batch := range run.Batches {
+		if batch.Creditor.IBAN == "" {
+			return fmt.Errorf("%w: batch %s", ErrMissingBankInfo, batch.Reference)
+		}
+		if !batch.Amount.IsPositive() || batch.Amount.Currency != run.Currency {
+			return fmt.Errorf("%w: batch %s pays %s in a %s run", ErrInvalidBill, batch.Reference, batch.Amount, run.Currency)
+		}
+		transfer := pain001Transfer{
+			EndToEndID:   truncate(batch.Reference, 35),
+			Amount:       pain001Amount{Currency: batch.Amount.Currency, Value: fixed(batch.Amount)},
+			CreditorName: truncate(batch.Creditor.Name, 70),
+			CreditorIBAN: batch.Creditor.IBAN,
+			Remittance:   truncate(strings.Join(batch.BillIDs, " "), 140),
+		}
+		if batch.Creditor.BIC != "" {
+			creditorAgent := agent(batch.Creditor.BIC)
+			transfer.CreditorAgent = &creditorAgent
+		}
+		payment.Transfers = append(payment.Transfers, transfer)
+		total = total.Add(batch.Amount.Amount)
+	}
+	sum := fixed(money.Money{Amount: total, Currency: run.Currency})
+	payment.ControlSum = sum
+
+	doc := pain001Document{
+		Namespace: Pain001Namespace,
+		Initiation: pain001Initiation{
+			MsgID:          truncate(run.ID, 35),
+			Created:        created.UTC().Format("2006-01-02T15:04:05"),
+			NumberOfTxs:    len(run.Batches),
+			ControlSum:     sum,
+			InitiatingName: truncate(run.Debtor.Name, 70),
+			Payment:        payment,
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write pain.001: %w", err)
+	}
+	return nil
+}
+
+// agent identifies a bank by BIC, or as not provided as SEPA allows
+func agent(bic string) pain001Agent {
+	if bic == "" {
+		return pain001Agent{Other: "NOTPROVIDED"}
+	}
+	return pain001Agent{BIC: bic}
+}
+
+// fixed formats an amount with its currency's decimal places
+func fixed(m money.Money) string {
+	return m.Amount.StringFixed(m.Scale())
+}
+
+// truncate shortens text to the length a pain.001 field allows
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
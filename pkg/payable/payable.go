@@ -0,0 +1,690 @@
+package payable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrBillNotFound    = errors.New("bill not found")
+	ErrInvalidBill     = errors.New("invalid bill")
+	ErrInvalidStatus   = errors.New("invalid bill status")
+	ErrNoBillsDue      = errors.New("no bills due for payment")
+	ErrMissingBankInfo = errors.New("missing vendor bank account")
+	ErrInvalidPayment  = errors.New("invalid payment")
+	ErrOverpayment     = errors.New("payment exceeds open balance")
+)
+
+// Manager defines the interface for accounts payable operations
+type Manager interface {
+	// CreateBill stores a new draft bill
+	CreateBill(ctx context.Context, bill *Bill) error
+
+	// GetBill retrieves a bill by ID
+	GetBill(ctx context.Context, id string) (*Bill, error)
+
+	// PostBill posts the expense and payable entries for a draft bill
+	PostBill(ctx context.Context, id string) (*Bill, error)
+
+	// ListDueBills retrieves open bills due on or before a date
+	ListDueBills(ctx context.Context, dueBy time.Time) ([]*Bill, error)
+
+	// BuildPaymentRun selects due bills and groups them into payment batches
+	BuildPaymentRun(ctx context.Context, criteria PaymentRunCriteria) (*PaymentRun, error)
+
+	// ExecutePaymentRun posts the payment journals and settles the bills
+	ExecutePaymentRun(ctx context.Context, run *PaymentRun) error
+
+	// RecordPayment posts a single vendor payment, which may settle bills in part
+	RecordPayment(ctx context.Context, payment *Payment) error
+
+	// ApplyCreditNote posts a vendor credit note and applies it to its bill
+	ApplyCreditNote(ctx context.Context, note *CreditNote) error
+
+	// Aging groups the open balances of bills by days past due
+	Aging(ctx context.Context, asOf time.Time, currency string) (*AgingReport, error)
+
+	// ForecastPayments totals the open balances of bills by due date
+	ForecastPayments(ctx context.Context, from, to time.Time, currency string) ([]CashRequirement, error)
+}
+
+// BasicManager provides a storage-backed implementation of Manager
+type BasicManager struct {
+	repo      storage.Repository
+	processor transaction.TransactionProcessor
+	// Optional calculator used to reconcile aging against the ledger
+	calculator reporting.ReportCalculator
+	// Optional realized exchange gain and loss accounting
+	fx *FXConfig
+}
+
+// NewBasicManager creates a new BasicManager
+func NewBasicManager(repo storage.Repository, processor transaction.TransactionProcessor) *BasicManager {
+	return &BasicManager{
+		repo:      repo,
+		processor: processor,
+	}
+}
+
+// SetCalculator sets the report calculator used to read payable account
+// balances, so aging reports can be reconciled against the ledger
+func (m *BasicManager) SetCalculator(calculator reporting.ReportCalculator) {
+	m.calculator = calculator
+}
+
+// CreateBill implements Manager.CreateBill
+func (m *BasicManager) CreateBill(ctx context.Context, bill *Bill) error {
+	if err := validateBill(bill); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if bill.ID == "" {
+		bill.ID = fmt.Sprintf("BILL_%d", now.UnixNano())
+	}
+	bill.Status = Draft
+	bill.Created = now
+	bill.LastModified = now
+
+	if err := m.repo.Create(ctx, bill); err != nil {
+		return fmt.Errorf("failed to store bill: %w", err)
+	}
+	return nil
+}
+
+// GetBill implements Manager.GetBill
+func (m *BasicManager) GetBill(ctx context.Context, id string) (*Bill, error) {
+	var bill Bill
+	if err := m.repo.Read(ctx, id, &bill); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBillNotFound, id, err)
+	}
+	return &bill, nil
+}
+
+// PostBill implements Manager.PostBill
+func (m *BasicManager) PostBill(ctx context.Context, id string) (*Bill, error) {
+	bill, err := m.GetBill(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if bill.Status != Draft {
+		return nil, fmt.Errorf("%w: only draft bills can be posted", ErrInvalidStatus)
+	}
+
+	if m.foreign(bill.Currency) {
+		if bill.ExchangeRate, err = m.rate(ctx, bill.ExchangeRate, bill.Currency, bill.BillDate); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := BuildJournalEntry(bill)
+	if err != nil {
+		return nil, err
+	}
+	if err := transaction.CreateAndPost(ctx, m.repo, m.processor, tx); err != nil {
+		return nil, fmt.Errorf("failed to post bill journal entry: %w", err)
+	}
+
+	bill.TransactionID = tx.ID
+	bill.Status = Posted
+	bill.LastModified = time.Now()
+	if err := m.repo.Update(ctx, bill); err != nil {
+		return nil, fmt.Errorf("failed to update bill: %w", err)
+	}
+	return bill, nil
+}
+
+// ListDueBills implements Manager.ListDueBills
+func (m *BasicManager) ListDueBills(ctx context.Context, dueBy time.Time) ([]*Bill, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "status", Operator: "in", Value: []BillStatus{Posted, PartiallyPaid}},
+			{Field: "due_date", Operator: "<=", Value: dueBy},
+		},
+		Sort: []storage.Sort{
+			{Field: "due_date", Desc: false},
+		},
+	}
+
+	var bills []*Bill
+	if err := m.repo.Query(ctx, query, &bills); err != nil {
+		return nil, fmt.Errorf("error querying bills: %w", err)
+	}
+
+	due := make([]*Bill, 0, len(bills))
+	for _, bill := range bills {
+		if bill.IsOpen() && !bill.DueDate.After(dueBy) {
+			due = append(due, bill)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].DueDate.Before(due[j].DueDate)
+	})
+	return due, nil
+}
+
+// BuildPaymentRun implements Manager.BuildPaymentRun. Bills are grouped into
+// one batch per vendor and pay-to bank account, so each batch maps to a
+// single credit transfer.
+func (m *BasicManager) BuildPaymentRun(ctx context.Context, criteria PaymentRunCriteria) (*PaymentRun, error) {
+	if criteria.PaymentAccountID == "" {
+		return nil, fmt.Errorf("payment account is required")
+	}
+
+	bills, err := m.ListDueBills(ctx, criteria.DueBy)
+	if err != nil {
+		return nil, err
+	}
+
+	vendors := make(map[string]bool)
+	for _, id := range criteria.VendorIDs {
+		vendors[id] = true
+	}
+
+	batches := make(map[string]*PaymentBatch)
+	order := make([]string, 0)
+	for _, bill := range bills {
+		if criteria.Currency != "" && bill.Currency != criteria.Currency {
+			continue
+		}
+		if len(vendors) > 0 && !vendors[bill.VendorID] {
+			continue
+		}
+		if bill.PayTo == nil || bill.PayTo.IBAN == "" {
+			return nil, fmt.Errorf("%w: bill %s", ErrMissingBankInfo, bill.ID)
+		}
+
+		key := bill.VendorID + "|" + bill.PayTo.IBAN + "|" + bill.Currency
+		batch, ok := batches[key]
+		if !ok {
+			batch = &PaymentBatch{
+				VendorID: bill.VendorID,
+				Creditor: *bill.PayTo,
+				Amount:   money.Money{Amount: decimal.Zero, Currency: bill.Currency},
+			}
+			batches[key] = batch
+			order = append(order, key)
+		}
+		batch.Amount.Amount = batch.Amount.Amount.Add(bill.OpenBalance().Amount)
+		batch.BillIDs = append(batch.BillIDs, bill.ID)
+	}
+
+	if len(order) == 0 {
+		return nil, ErrNoBillsDue
+	}
+
+	now := time.Now()
+	run := &PaymentRun{
+		ID:               fmt.Sprintf("RUN_%d", now.UnixNano()),
+		Status:           RunProposed,
+		ExecutionDate:    criteria.ExecutionDate,
+		Currency:         criteria.Currency,
+		PaymentAccountID: criteria.PaymentAccountID,
+		Debtor:           criteria.Debtor,
+		Batches:          make([]PaymentBatch, 0, len(order)),
+		Created:          now,
+	}
+	for i, key := range order {
+		batch := batches[key]
+		batch.Reference = fmt.Sprintf("%s-%03d", run.ID, i+1)
+		if run.Currency == "" {
+			run.Currency = batch.Amount.Currency
+		}
+		run.Batches = append(run.Batches, *batch)
+	}
+	return run, nil
+}
+
+// ExecutePaymentRun implements Manager.ExecutePaymentRun
+func (m *BasicManager) ExecutePaymentRun(ctx context.Context, run *PaymentRun) error {
+	if run.Status != RunProposed {
+		return fmt.Errorf("payment run %s has already been executed", run.ID)
+	}
+
+	for i := range run.Batches {
+		batch := &run.Batches[i]
+
+		bills := make([]*Bill, 0, len(batch.BillIDs))
+		open := decimal.Zero
+		for _, id := range batch.BillIDs {
+			bill, err := m.GetBill(ctx, id)
+			if err != nil {
+				return err
+			}
+			if !bill.IsOpen() {
+				return fmt.Errorf("%w: bill %s is no longer open", ErrInvalidStatus, id)
+			}
+			bills = append(bills, bill)
+			open = open.Add(bill.OpenBalance().Amount)
+		}
+		if !open.Equal(batch.Amount.Amount) {
+			return fmt.Errorf("%w: bills of batch %s were settled since the run was built", ErrInvalidStatus, batch.Reference)
+		}
+
+		var fxTx *transaction.Transaction
+		var gains []money.Money
+		if m.foreign(batch.Amount.Currency) {
+			rate, err := m.rate(ctx, run.ExchangeRate, batch.Amount.Currency, run.ExecutionDate)
+			if err != nil {
+				return err
+			}
+			amounts := make([]decimal.Decimal, len(bills))
+			for j, bill := range bills {
+				amounts[j] = bill.OpenBalance().Amount
+			}
+			if fxTx, gains, err = m.buildFXEntry(ctx, batch.Reference, run.ExecutionDate, rate, bills, amounts); err != nil {
+				return err
+			}
+		}
+
+		tx := buildPaymentEntry(run, batch, bills)
+		if err := m.post(ctx, tx, fxTx); err != nil {
+			return fmt.Errorf("failed to post payment for batch %s: %w", batch.Reference, err)
+		}
+		batch.TransactionID = tx.ID
+
+		now := time.Now()
+		for j, bill := range bills {
+			settlement := Settlement{
+				Type:          SettledByPayment,
+				Reference:     batch.Reference,
+				Date:          run.ExecutionDate,
+				Amount:        bill.OpenBalance().Amount,
+				TransactionID: tx.ID,
+			}
+			if gains != nil && !gains[j].IsZero() {
+				settlement.RealizedFX = gains[j]
+				settlement.FXTransactionID = fxTx.ID
+			}
+			bill.settle(settlement)
+			bill.LastModified = now
+			if err := m.repo.Update(ctx, bill); err != nil {
+				return fmt.Errorf("failed to update bill %s: %w", bill.ID, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	run.Status = RunExecuted
+	run.ExecutedAt = &now
+	if err := m.repo.Create(ctx, run); err != nil {
+		return fmt.Errorf("failed to store payment run: %w", err)
+	}
+	return nil
+}
+
+// RecordPayment implements Manager.RecordPayment. The allocations must
+// total the payment amount, and no bill may be paid beyond its open
+// balance. With FX accounting enabled, paying foreign bills at another rate
+// than they were posted at also posts the realized exchange gain or loss.
+func (m *BasicManager) RecordPayment(ctx context.Context, payment *Payment) error {
+	if err := validatePayment(payment); err != nil {
+		return err
+	}
+
+	allocated := decimal.Zero
+	seen := make(map[string]bool)
+	bills := make([]*Bill, 0, len(payment.Allocations))
+	for _, allocation := range payment.Allocations {
+		if seen[allocation.BillID] {
+			return fmt.Errorf("%w: bill %s is allocated twice", ErrInvalidPayment, allocation.BillID)
+		}
+		seen[allocation.BillID] = true
+		if !allocation.Amount.IsPositive() {
+			return fmt.Errorf("%w: allocation to bill %s must be positive", ErrInvalidPayment, allocation.BillID)
+		}
+
+		bill, err := m.GetBill(ctx, allocation.BillID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case bill.VendorID != payment.VendorID:
+			return fmt.Errorf("%w: bill %s belongs to another vendor", ErrInvalidPayment, bill.ID)
+		case bill.Currency != payment.Amount.Currency:
+			return fmt.Errorf("%w: bill %s is in %s", ErrInvalidPayment, bill.ID, bill.Currency)
+		case !bill.IsOpen():
+			return fmt.Errorf("%w: bill %s is not open", ErrInvalidStatus, bill.ID)
+		case allocation.Amount.GreaterThan(bill.OpenBalance().Amount):
+			return fmt.Errorf("%w: bill %s has %s open", ErrOverpayment, bill.ID, bill.OpenBalance())
+		}
+		allocated = allocated.Add(allocation.Amount)
+		bills = append(bills, bill)
+	}
+	if !allocated.Equal(payment.Amount.Amount) {
+		return fmt.Errorf("%w: allocations total %s of %s", ErrInvalidPayment, allocated, payment.Amount)
+	}
+
+	now := time.Now()
+	if payment.ID == "" {
+		payment.ID = fmt.Sprintf("PMT_%d", now.UnixNano())
+	}
+	var fxTx *transaction.Transaction
+	var gains []money.Money
+	if m.foreign(payment.Amount.Currency) {
+		rate, err := m.rate(ctx, payment.ExchangeRate, payment.Amount.Currency, payment.Date)
+		if err != nil {
+			return err
+		}
+		payment.ExchangeRate = rate
+		amounts := make([]decimal.Decimal, len(bills))
+		for i, allocation := range payment.Allocations {
+			amounts[i] = allocation.Amount
+		}
+		if fxTx, gains, err = m.buildFXEntry(ctx, payment.ID, payment.Date, rate, bills, amounts); err != nil {
+			return err
+		}
+	}
+
+	payables := make(map[string]decimal.Decimal)
+	order := make([]string, 0)
+	for i, allocation := range payment.Allocations {
+		accountID := bills[i].PayableAccountID
+		if _, ok := payables[accountID]; !ok {
+			order = append(order, accountID)
+		}
+		payables[accountID] = payables[accountID].Add(allocation.Amount)
+	}
+	entries := make([]transaction.Entry, 0, len(order)+1)
+	for _, accountID := range order {
+		entries = append(entries, transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: payables[accountID], Currency: payment.Amount.Currency},
+			Type:        transaction.Debit,
+			Description: fmt.Sprintf("Payment to vendor %s", payment.VendorID),
+			PartyID:     payment.VendorID,
+		})
+	}
+	entries = append(entries, transaction.Entry{
+		AccountID:   payment.PaymentAccountID,
+		Amount:      payment.Amount,
+		Type:        transaction.Credit,
+		Description: fmt.Sprintf("Payment %s", payment.ID),
+	})
+	tx := &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", payment.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         payment.Date,
+		Description:  fmt.Sprintf("Vendor payment %s", payment.ID),
+		Reference:    payment.Reference,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+	if err := m.post(ctx, tx, fxTx); err != nil {
+		return fmt.Errorf("failed to post payment journal entry: %w", err)
+	}
+	payment.TransactionID = tx.ID
+	payment.Created = now
+
+	for i, allocation := range payment.Allocations {
+		bill := bills[i]
+		settlement := Settlement{
+			Type:          SettledByPayment,
+			Reference:     payment.ID,
+			Date:          payment.Date,
+			Amount:        allocation.Amount,
+			TransactionID: tx.ID,
+		}
+		if gains != nil && !gains[i].IsZero() {
+			settlement.RealizedFX = gains[i]
+			settlement.FXTransactionID = fxTx.ID
+		}
+		bill.settle(settlement)
+		bill.LastModified = now
+		if err := m.repo.Update(ctx, bill); err != nil {
+			return fmt.Errorf("failed to update bill %s: %w", bill.ID, err)
+		}
+	}
+
+	if err := m.repo.Create(ctx, payment); err != nil {
+		return fmt.Errorf("failed to store payment: %w", err)
+	}
+	return nil
+}
+
+// ApplyCreditNote implements Manager.ApplyCreditNote. The credit note
+// debits the payable and credits the expense and tax accounts of its lines,
+// and may not exceed the open balance of the bill.
+func (m *BasicManager) ApplyCreditNote(ctx context.Context, note *CreditNote) error {
+	if note == nil {
+		return fmt.Errorf("%w: credit note cannot be nil", ErrInvalidBill)
+	}
+	bill, err := m.GetBill(ctx, note.BillID)
+	if err != nil {
+		return err
+	}
+	if note.PayableAccountID == "" {
+		note.PayableAccountID = bill.PayableAccountID
+	}
+	if err := validateLines(note.Lines, note.Currency); err != nil {
+		return err
+	}
+	switch {
+	case note.VendorID != bill.VendorID:
+		return fmt.Errorf("%w: credit note is from another vendor than bill %s", ErrInvalidBill, bill.ID)
+	case note.Currency != bill.Currency:
+		return fmt.Errorf("%w: bill %s is in %s", ErrInvalidBill, bill.ID, bill.Currency)
+	case !bill.IsOpen():
+		return fmt.Errorf("%w: bill %s is not open", ErrInvalidStatus, bill.ID)
+	case note.Total().Amount.GreaterThan(bill.OpenBalance().Amount):
+		return fmt.Errorf("%w: bill %s has %s open", ErrOverpayment, bill.ID, bill.OpenBalance())
+	}
+
+	now := time.Now()
+	if note.ID == "" {
+		note.ID = fmt.Sprintf("CN_%d", now.UnixNano())
+	}
+	entries := []transaction.Entry{{
+		AccountID:   note.PayableAccountID,
+		Amount:      note.Total(),
+		Type:        transaction.Debit,
+		Description: fmt.Sprintf("Credit from vendor %s", note.VendorID),
+		PartyID:     note.VendorID,
+	}}
+	entries = append(entries, lineEntries(note.Lines, note.Currency, transaction.Credit, fmt.Sprintf("Credit note %s", note.Number))...)
+	tx := &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", note.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         note.Date,
+		Description:  fmt.Sprintf("Credit note %s from vendor %s", note.Number, note.VendorID),
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+	if err := transaction.CreateAndPost(ctx, m.repo, m.processor, tx); err != nil {
+		return fmt.Errorf("failed to post credit note journal entry: %w", err)
+	}
+	note.TransactionID = tx.ID
+	note.Created = now
+
+	bill.settle(Settlement{
+		Type:          SettledByCreditNote,
+		Reference:     note.ID,
+		Date:          note.Date,
+		Amount:        note.Total().Amount,
+		TransactionID: tx.ID,
+	})
+	bill.LastModified = now
+	if err := m.repo.Update(ctx, bill); err != nil {
+		return fmt.Errorf("failed to update bill: %w", err)
+	}
+	if err := m.repo.Create(ctx, note); err != nil {
+		return fmt.Errorf("failed to store credit note: %w", err)
+	}
+	return nil
+}
+
+// post creates and posts a settlement entry. Its exchange difference entry,
+// if any, is posted in the same batch, so neither is posted without the
+// other.
+func (m *BasicManager) post(ctx context.Context, tx, fxTx *transaction.Transaction) error {
+	if fxTx == nil {
+		return transaction.CreateAndPost(ctx, m.repo, m.processor, tx)
+	}
+	return transaction.CreateAndPostBatch(ctx, m.repo, m.processor, []*transaction.Transaction{tx, fxTx})
+}
+
+// BuildJournalEntry creates the expense, tax and payable entries for a bill
+func BuildJournalEntry(bill *Bill) (*transaction.Transaction, error) {
+	if err := validateBill(bill); err != nil {
+		return nil, err
+	}
+
+	entries := lineEntries(bill.Lines, bill.Currency, transaction.Debit, fmt.Sprintf("Bill %s", bill.Number))
+	entries = append(entries, transaction.Entry{
+		AccountID:   bill.PayableAccountID,
+		Amount:      bill.Total(),
+		Type:        transaction.Credit,
+		Description: fmt.Sprintf("Payable to vendor %s", bill.VendorID),
+		PartyID:     bill.VendorID,
+	})
+
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", bill.ID),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         bill.BillDate,
+		Description:  fmt.Sprintf("Bill %s from vendor %s", bill.Number, bill.VendorID),
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}, nil
+}
+
+// lineEntries totals the expense and tax amounts of bill lines per account
+// into entries on one side
+func lineEntries(lines []BillLine, currency string, side transaction.EntryType, description string) []transaction.Entry {
+	totals := make(map[string]decimal.Decimal)
+	order := make([]string, 0)
+	add := func(accountID string, amount decimal.Decimal) {
+		if amount.IsZero() {
+			return
+		}
+		if _, ok := totals[accountID]; !ok {
+			order = append(order, accountID)
+		}
+		totals[accountID] = totals[accountID].Add(amount)
+	}
+	for _, line := range lines {
+		add(line.ExpenseAccountID, line.Amount.Amount)
+		add(line.TaxAccountID, line.TaxAmount.Amount)
+	}
+
+	entries := make([]transaction.Entry, 0, len(order)+1)
+	for _, accountID := range order {
+		entries = append(entries, transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: totals[accountID], Currency: currency},
+			Type:        side,
+			Description: description,
+		})
+	}
+	return entries
+}
+
+// buildPaymentEntry debits payables and credits the payment account for a batch
+func buildPaymentEntry(run *PaymentRun, batch *PaymentBatch, bills []*Bill) *transaction.Transaction {
+	payables := make(map[string]decimal.Decimal)
+	order := make([]string, 0)
+	for _, bill := range bills {
+		if _, ok := payables[bill.PayableAccountID]; !ok {
+			order = append(order, bill.PayableAccountID)
+		}
+		payables[bill.PayableAccountID] = payables[bill.PayableAccountID].Add(bill.OpenBalance().Amount)
+	}
+
+	entries := make([]transaction.Entry, 0, len(order)+1)
+	for _, accountID := range order {
+		entries = append(entries, transaction.Entry{
+			AccountID:   accountID,
+			Amount:      money.Money{Amount: payables[accountID], Currency: batch.Amount.Currency},
+			Type:        transaction.Debit,
+			Description: fmt.Sprintf("Payment to vendor %s", batch.VendorID),
+			PartyID:     batch.VendorID,
+		})
+	}
+	entries = append(entries, transaction.Entry{
+		AccountID:   run.PaymentAccountID,
+		Amount:      batch.Amount,
+		Type:        transaction.Credit,
+		Description: fmt.Sprintf("Payment run %s", run.ID),
+	})
+
+	now := time.Now()
+	return &transaction.Transaction{
+		ID:           fmt.Sprintf("TX-%s", batch.Reference),
+		Type:         transaction.Journal,
+		Status:       transaction.Draft,
+		Date:         run.ExecutionDate,
+		Description:  fmt.Sprintf("Vendor payment %s", batch.Reference),
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+}
+
+func validateBill(bill *Bill) error {
+	if bill == nil {
+		return fmt.Errorf("%w: bill cannot be nil", ErrInvalidBill)
+	}
+	if bill.VendorID == "" {
+		return fmt.Errorf("%w: vendor is required", ErrInvalidBill)
+	}
+	if bill.PayableAccountID == "" {
+		return fmt.Errorf("%w: payable account is required", ErrInvalidBill)
+	}
+	if bill.DueDate.Before(bill.BillDate) {
+		return fmt.Errorf("%w: due date precedes bill date", ErrInvalidBill)
+	}
+	return validateLines(bill.Lines, bill.Currency)
+}
+
+func validateLines(lines []BillLine, currency string) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: at least one line is required", ErrInvalidBill)
+	}
+	for i, line := range lines {
+		if line.ExpenseAccountID == "" {
+			return fmt.Errorf("%w: line %d has no expense account", ErrInvalidBill, i)
+		}
+		if line.Amount.Currency != currency || !line.Amount.IsPositive() {
+			return fmt.Errorf("%w: line %d has an invalid amount", ErrInvalidBill, i)
+		}
+		if !line.TaxAmount.IsZero() && line.TaxAccountID == "" {
+			return fmt.Errorf("%w: line %d tax has no account", ErrInvalidBill, i)
+		}
+	}
+	return nil
+}
+
+func validatePayment(payment *Payment) error {
+	if payment == nil {
+		return fmt.Errorf("%w: payment cannot be nil", ErrInvalidPayment)
+	}
+	if payment.VendorID == "" {
+		return fmt.Errorf("%w: vendor is required", ErrInvalidPayment)
+	}
+	if payment.PaymentAccountID == "" {
+		return fmt.Errorf("%w: payment account is required", ErrInvalidPayment)
+	}
+	if !payment.Amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPayment)
+	}
+	if len(payment.Allocations) == 0 {
+		return fmt.Errorf("%w: at least one allocation is required", ErrInvalidPayment)
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package payable
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor validates and records transactions instead of storing them
+type recordingProcessor struct {
+	transaction.TransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	result, err := (&transaction.BasicValidator{}).Validate(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if !result.Valid {
+		return assert.AnError
+	}
+	tx.Status = transaction.Posted
+	p.posted = append(p.posted, tx)
+	return nil
+}
+
+// billStore extends MemoryStore with a Query that returns every stored bill
+type billStore struct {
+	*memory.MemoryStore
+	ids []string
+}
+
+func (s *billStore) Create(ctx context.Context, entity interface{}) error {
+	if err := s.MemoryStore.Create(ctx, entity); err != nil {
+		return err
+	}
+	if bill, ok := entity.(*Bill); ok {
+		s.ids = append(s.ids, bill.ID)
+	}
+	return nil
+}
+
+func (s *billStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	out := results.(*[]*Bill)
+	for _, id := range s.ids {
+		var bill Bill
+		if err := s.Read(ctx, id, &bill); err != nil {
+			return err
+		}
+		*out = append(*out, &bill)
+	}
+	return nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func newTestBill(id, vendorID, iban string, amount int64, due time.Time) *Bill {
+	return &Bill{
+		ID:               id,
+		Number:           "V-" + id,
+		VendorID:         vendorID,
+		Currency:         "USD",
+		PayableAccountID: "2000",
+		BillDate:         due.AddDate(0, 0, -30),
+		DueDate:          due,
+		PayTo:            &BankAccount{Name: vendorID, IBAN: iban},
+		Lines: []BillLine{
+			{Description: "Supplies", Amount: usd(amount), ExpenseAccountID: "6000", TaxAmount: usd(amount / 10), TaxAccountID: "1400"},
+		},
+	}
+}
+
+func TestBuildJournalEntry(t *testing.T) {
+	bill := newTestBill("B1", "VEND1", "DE001", 100, time.Now())
+	tx, err := BuildJournalEntry(bill)
+	require.NoError(t, err)
+	require.Len(t, tx.Entries, 3)
+
+	assert.Equal(t, "6000", tx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, tx.Entries[0].Type)
+	assert.Equal(t, "1400", tx.Entries[1].AccountID)
+	assert.Equal(t, "10", tx.Entries[1].Amount.Amount.String())
+	assert.Equal(t, "2000", tx.Entries[2].AccountID)
+	assert.Equal(t, transaction.Credit, tx.Entries[2].Type)
+	assert.Equal(t, "110", tx.Entries[2].Amount.Amount.String())
+}
+
+func TestPaymentRun(t *testing.T) {
+	ctx := context.Background()
+	processor := &recordingProcessor{}
+	manager := NewBasicManager(&billStore{MemoryStore: memory.NewMemoryStore()}, processor)
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	bills := []*Bill{
+		newTestBill("B1", "VEND1", "DE001", 100, today.AddDate(0, 0, -2)),
+		newTestBill("B2", "VEND1", "DE001", 200, today),
+		newTestBill("B3", "VEND2", "FR001", 300, today.AddDate(0, 0, -1)),
+		newTestBill("B4", "VEND2", "FR001", 400, today.AddDate(0, 0, 10)),
+	}
+	for _, bill := range bills {
+		require.NoError(t, manager.CreateBill(ctx, bill))
+		_, err := manager.PostBill(ctx, bill.ID)
+		require.NoError(t, err)
+	}
+
+	criteria := PaymentRunCriteria{
+		DueBy:            today,
+		ExecutionDate:    today,
+		Currency:         "USD",
+		PaymentAccountID: "1000",
+		Debtor:           BankAccount{Name: "ACME", IBAN: "US001"},
+	}
+
+	run, err := manager.BuildPaymentRun(ctx, criteria)
+	require.NoError(t, err)
+	require.Len(t, run.Batches, 2)
+	assert.Equal(t, "VEND1", run.Batches[0].VendorID)
+	assert.Equal(t, []string{"B1", "B2"}, run.Batches[0].BillIDs)
+	assert.Equal(t, "330", run.Batches[0].Amount.Amount.String())
+	assert.Equal(t, "VEND2", run.Batches[1].VendorID)
+	assert.Equal(t, "330", run.Batches[1].Amount.Amount.String())
+	assert.Equal(t, "660", run.Total().Amount.String())
+
+	require.NoError(t, manager.ExecutePaymentRun(ctx, run))
+	assert.Equal(t, RunExecuted, run.Status)
+	assert.NotEmpty(t, run.Batches[0].TransactionID)
+
+	paid, err := manager.GetBill(ctx, "B2")
+	require.NoError(t, err)
+	assert.Equal(t, Paid, paid.Status)
+
+	assert.Error(t, manager.ExecutePaymentRun(ctx, run))
+
+	_, err = manager.BuildPaymentRun(ctx, criteria)
+	assert.ErrorIs(t, err, ErrNoBillsDue)
+}
+
+func TestWritePain001(t *testing.T) {
+	ctx := context.Background()
+	manager := NewBasicManager(&billStore{MemoryStore: memory.NewMemoryStore()}, &recordingProcessor{})
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	bills := []*Bill{
+		newTestBill("B1", "VEND1", "DE001", 100, today),
+		newTestBill("B2", "VEND2", "FR001", 300, today),
+	}
+	for _, bill := range bills {
+		require.NoError(t, manager.CreateBill(ctx, bill))
+		_, err := manager.PostBill(ctx, bill.ID)
+		require.NoError(t, err)
+	}
+
+	run, err := manager.BuildPaymentRun(ctx, PaymentRunCriteria{
+		DueBy:            today,
+		ExecutionDate:    today,
+		Currency:         "USD",
+		PaymentAccountID: "1000",
+		Debtor:           BankAccount{Name: "ACME", IBAN: "US001", BIC: "ACMEUS33"},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.ErrorIs(t, WritePain001(&buf, run, today), ErrInvalidStatus)
+
+	require.NoError(t, manager.ExecutePaymentRun(ctx, run))
+	buf.Reset()
+	require.NoError(t, WritePain001(&buf, run, today))
+	out := buf.String()
+
+	assert.Contains(t, out, `<Document xmlns="`+Pain001Namespace+`">`)
+	assert.Contains(t, out, "<MsgId>"+run.ID+"</MsgId>")
+	assert.Contains(t, out, "<CreDtTm>2024-06-01T00:00:00</CreDtTm>")
+	assert.Contains(t, out, "<NbOfTxs>2</NbOfTxs>")
+	assert.Contains(t, out, "<CtrlSum>440.00</CtrlSum>")
+	assert.Contains(t, out, "<ReqdExctnDt>2024-06-01</ReqdExctnDt>")
+	assert.Contains(t, out, "<BIC>ACMEUS33</BIC>")
+	assert.Contains(t, out, "<IBAN>US001</IBAN>")
+	assert.Contains(t, out, "<IBAN>FR001</IBAN>")
+	assert.Contains(t, out, `<InstdAmt Ccy="USD">110.00</InstdAmt>`)
+	assert.Contains(t, out, "<EndToEndId>"+run.Batches[0].Reference+"</EndToEndId>")
+	assert.Contains(t, out, "<Ustrd>B2</Ustrd>")
+	assert.NotContains(t, out, "SEPA")
+
+	run.Batches[1].Creditor.IBAN = ""
+	assert.ErrorIs(t, WritePain001(&buf, run, today), ErrMissingBankInfo)
+}
+
+func TestPartialPaymentsAndCreditNotes(t *testing.T) {
+	ctx := context.Background()
+	processor := &recordingProcessor{}
+	manager := NewBasicManager(&billStore{MemoryStore: memory.NewMemoryStore()}, processor)
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	bill := newTestBill("B1", "VEND1", "DE001", 1000, today)
+	require.NoError(t, manager.CreateBill(ctx, bill))
+	_, err := manager.PostBill(ctx, "B1")
+	require.NoError(t, err)
+
+	note := &CreditNote{
+		ID:       "CN1",
+		Number:   "C-1",
+		VendorID: "VEND1",
+		BillID:   "B1",
+		Currency: "USD",
+		Date:     today.AddDate(0, 0, -5),
+		Lines: []BillLine{
+			{Description: "Returned supplies", Amount: usd(100), ExpenseAccountID: "6000", TaxAmount: usd(10), TaxAccountID: "1400"},
+		},
+	}
+	require.NoError(t, manager.ApplyCreditNote(ctx, note))
+	assert.Equal(t, "2000", note.PayableAccountID)
+	tx := processor.posted[len(processor.posted)-1]
+	require.Len(t, tx.Entries, 3)
+	assert.Equal(t, transaction.Debit, tx.Entries[0].Type)
+	assert.Equal(t, "2000", tx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Credit, tx.Entries[1].Type)
+	assert.Equal(t, "6000", tx.Entries[1].AccountID)
+
+	credited, err := manager.GetBill(ctx, "B1")
+	require.NoError(t, err)
+	assert.Equal(t, PartiallyPaid, credited.Status)
+	assert.Equal(t, "990", credited.OpenBalance().Amount.String())
+
+	payment := &Payment{
+		ID:               "P1",
+		VendorID:         "VEND1",
+		Date:             today,
+		Amount:           usd(400),
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B1", Amount: decimal.NewFromInt(400)}},
+	}
+	require.NoError(t, manager.RecordPayment(ctx, payment))
+	assert.Equal(t, "TX-P1", payment.TransactionID)
+
+	paid, err := manager.GetBill(ctx, "B1")
+	require.NoError(t, err)
+	assert.Equal(t, PartiallyPaid, paid.Status)
+	assert.Equal(t, "590", paid.OpenBalance().Amount.String())
+	assert.Equal(t, "990", paid.OpenBalanceAt(today.AddDate(0, 0, -1)).Amount.String())
+	require.Len(t, paid.Settlements, 2)
+
+	err = manager.RecordPayment(ctx, &Payment{
+		VendorID:         "VEND1",
+		Date:             today,
+		Amount:           usd(600),
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B1", Amount: decimal.NewFromInt(600)}},
+	})
+	assert.ErrorIs(t, err, ErrOverpayment)
+	err = manager.RecordPayment(ctx, &Payment{
+		VendorID:         "VEND1",
+		Date:             today,
+		Amount:           usd(100),
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B1", Amount: decimal.NewFromInt(50)}},
+	})
+	assert.ErrorIs(t, err, ErrInvalidPayment)
+	note.ID, note.VendorID = "CN2", "VEND2"
+	assert.ErrorIs(t, manager.ApplyCreditNote(ctx, note), ErrInvalidBill)
+
+	// Payment runs pay what remains open
+	run, err := manager.BuildPaymentRun(ctx, PaymentRunCriteria{
+		DueBy:            today,
+		ExecutionDate:    today,
+		Currency:         "USD",
+		PaymentAccountID: "1000",
+		Debtor:           BankAccount{Name: "ACME", IBAN: "US001"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "590", run.Total().Amount.String())
+	require.NoError(t, manager.ExecutePaymentRun(ctx, run))
+
+	settled, err := manager.GetBill(ctx, "B1")
+	require.NoError(t, err)
+	assert.Equal(t, Paid, settled.Status)
+	assert.Equal(t, "990", settled.AmountPaid.String())
+	assert.Equal(t, "110", settled.AmountCredited.String())
+}
+
+func TestBasicManager_Posting(t *testing.T) {
+	ctx := context.Background()
+	store := &billStore{MemoryStore: memory.NewMemoryStore()}
+	processor := transaction.NewBasicTransactionProcessor(store)
+	manager := NewBasicManager(store, processor)
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	bill := newTestBill("B1", "VEND1", "DE001", 1000, today)
+	require.NoError(t, manager.CreateBill(ctx, bill))
+	posted, err := manager.PostBill(ctx, "B1")
+	require.NoError(t, err)
+
+	note := &CreditNote{
+		ID:       "CN1",
+		Number:   "C-1",
+		VendorID: "VEND1",
+		BillID:   "B1",
+		Currency: "USD",
+		Date:     today,
+		Lines:    []BillLine{{Description: "Returned supplies", Amount: usd(100), ExpenseAccountID: "6000"}},
+	}
+	require.NoError(t, manager.ApplyCreditNote(ctx, note))
+	payment := &Payment{
+		ID:               "P1",
+		VendorID:         "VEND1",
+		Date:             today,
+		Amount:           usd(500),
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B1", Amount: decimal.NewFromInt(500)}},
+	}
+	require.NoError(t, manager.RecordPayment(ctx, payment))
+	run, err := manager.BuildPaymentRun(ctx, PaymentRunCriteria{DueBy: today, ExecutionDate: today, Currency: "USD", PaymentAccountID: "1000"})
+	require.NoError(t, err)
+	require.NoError(t, manager.ExecutePaymentRun(ctx, run))
+
+	for _, id := range []string{posted.TransactionID, note.TransactionID, payment.TransactionID, run.Batches[0].TransactionID} {
+		tx, err := processor.GetTransaction(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, transaction.Posted, tx.Status)
+	}
+
+	paid, err := manager.GetBill(ctx, "B1")
+	require.NoError(t, err)
+	assert.Equal(t, Paid, paid.Status)
+
+	// A failed posting leaves no draft behind
+	failed := newTestBill("B2", "VEND1", "DE001", 100, today)
+	require.NoError(t, manager.CreateBill(ctx, failed))
+	_, err = manager.PostBill(auth.WithPrincipal(ctx, &auth.Principal{ID: "viewer"}), "B2")
+	assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+	_, err = processor.GetTransaction(ctx, "TX-B2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestAgingAndForecast(t *testing.T) {
+	ctx := context.Background()
+	manager := NewBasicManager(&billStore{MemoryStore: memory.NewMemoryStore()}, &recordingProcessor{})
+
+	asOf := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	bills := []*Bill{
+		newTestBill("B1", "VEND1", "DE001", 100, asOf.AddDate(0, 0, 10)),
+		newTestBill("B2", "VEND1", "DE001", 200, asOf.AddDate(0, 0, -10)),
+		newTestBill("B3", "VEND2", "FR001", 300, asOf.AddDate(0, 0, -100)),
+		newTestBill("B4", "VEND2", "FR001", 400, asOf.AddDate(0, 0, 10)),
+	}
+	for _, bill := range bills {
+		require.NoError(t, manager.CreateBill(ctx, bill))
+		_, err := manager.PostBill(ctx, bill.ID)
+		require.NoError(t, err)
+	}
+	require.NoError(t, manager.RecordPayment(ctx, &Payment{
+		VendorID:         "VEND2",
+		Date:             asOf.AddDate(0, 0, 1),
+		Amount:           usd(330),
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B3", Amount: decimal.NewFromInt(330)}},
+	}))
+
+	report, err := manager.Aging(ctx, asOf, "USD")
+	require.NoError(t, err)
+	require.Len(t, report.Vendors, 2)
+	assert.Equal(t, "VEND2", report.Vendors[0].VendorID)
+	assert.Equal(t, "330", report.Vendors[0].Over90.String())
+	assert.Equal(t, "440", report.Vendors[0].Current.String())
+	assert.Equal(t, "VEND1", report.Vendors[1].VendorID)
+	assert.Equal(t, "220", report.Vendors[1].Days30.String())
+	assert.Equal(t, "1100", report.Totals.Total().String())
+	assert.Nil(t, report.LedgerBalance)
+
+	forecast, err := manager.ForecastPayments(ctx, asOf, asOf.AddDate(0, 1, 0), "USD")
+	require.NoError(t, err)
+	require.Len(t, forecast, 2)
+	assert.Equal(t, asOf, forecast[0].Date)
+	assert.Equal(t, "220", forecast[0].Amount.Amount.String())
+	assert.Equal(t, []string{"B2"}, forecast[0].BillIDs)
+	assert.Equal(t, asOf.AddDate(0, 0, 10), forecast[1].Date)
+	assert.Equal(t, "550", forecast[1].Amount.Amount.String())
+	assert.Equal(t, []string{"B1", "B4"}, forecast[1].BillIDs)
+}
+
+func TestRealizedFX(t *testing.T) {
+	ctx := context.Background()
+	store := &billStore{MemoryStore: memory.NewMemoryStore()}
+	processor := transaction.NewBasicTransactionProcessor(store)
+	manager := NewBasicManager(store, processor)
+	rates := money.NewRateTable()
+	rates.Set("EUR", "USD", decimal.RequireFromString("1.10"))
+	assert.ErrorIs(t, manager.SetFX(FXConfig{BaseCurrency: "USD", Rates: rates, GainAccountID: "7100", LossAccountID: "7200"}), ErrInvalidBill,
+		"the EUR payable account cannot carry USD adjustments")
+	require.NoError(t, manager.SetFX(FXConfig{BaseCurrency: "USD", Rates: rates, GainAccountID: "7100", LossAccountID: "7200", AdjustmentAccountID: "2090"}))
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"B1", "B2"} {
+		bill := newTestBill(id, "VEND1", "DE001", 1000, today)
+		bill.Currency = "EUR"
+		bill.ExchangeRate = decimal.RequireFromString("1.08")
+		for i := range bill.Lines {
+			bill.Lines[i].Amount.Currency = "EUR"
+			bill.Lines[i].TaxAmount.Currency = "EUR"
+		}
+		require.NoError(t, manager.CreateBill(ctx, bill))
+		_, err := manager.PostBill(ctx, id)
+		require.NoError(t, err)
+	}
+
+	// Paying at a higher rate than the bill was posted at is a loss
+	payment := &Payment{
+		ID:               "P1",
+		VendorID:         "VEND1",
+		Date:             today,
+		Amount:           money.Money{Amount: decimal.NewFromInt(500), Currency: "EUR"},
+		PaymentAccountID: "1000",
+		Allocations:      []Allocation{{BillID: "B1", Amount: decimal.NewFromInt(500)}},
+	}
+	require.NoError(t, manager.RecordPayment(ctx, payment))
+	assert.Equal(t, "1.1", payment.ExchangeRate.String())
+
+	fx, err := processor.GetTransaction(ctx, "TX-P1-FX")
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, fx.Status)
+	require.Len(t, fx.Entries, 2)
+	assert.Equal(t, "2090", fx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Credit, fx.Entries[0].Type)
+	assert.Equal(t, "7200", fx.Entries[1].AccountID)
+	assert.Equal(t, transaction.Debit, fx.Entries[1].Type)
+	assert.Equal(t, "10", fx.Entries[1].Amount.Amount.String())
+
+	bill, err := manager.GetBill(ctx, "B1")
+	require.NoError(t, err)
+	require.Len(t, bill.Settlements, 1)
+	assert.Equal(t, "-10", bill.Settlements[0].RealizedFX.Amount.String())
+	assert.Equal(t, "TX-P1-FX", bill.Settlements[0].FXTransactionID)
+
+	// Payment runs post the difference per batch
+	run, err := manager.BuildPaymentRun(ctx, PaymentRunCriteria{DueBy: today, ExecutionDate: today, Currency: "EUR", PaymentAccountID: "1000"})
+	require.NoError(t, err)
+	run.ExchangeRate = decimal.RequireFromString("1.06")
+	require.NoError(t, manager.ExecutePaymentRun(ctx, run))
+
+	fx, err = processor.GetTransaction(ctx, "TX-"+run.Batches[0].Reference+"-FX")
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, fx.Status)
+	assert.Equal(t, "7100", fx.Entries[len(fx.Entries)-1].AccountID)
+	bill, err = manager.GetBill(ctx, "B2")
+	require.NoError(t, err)
+	assert.Equal(t, "22", bill.Settlements[0].RealizedFX.Amount.String())
+}
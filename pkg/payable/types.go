@@ -0,0 +1,257 @@
+package payable
+
+import (
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// BillStatus represents the lifecycle state of a vendor bill
+type BillStatus string
+
+const (
+	Draft         BillStatus = "DRAFT"
+	Posted        BillStatus = "POSTED"
+	PartiallyPaid BillStatus = "PARTIALLY_PAID"
+	Paid          BillStatus = "PAID"
+	Cancelled     BillStatus = "CANCELLED"
+)
+
+// SettlementType identifies how part of a bill was settled
+type SettlementType string
+
+const (
+	SettledByPayment    SettlementType = "PAYMENT"
+	SettledByCreditNote SettlementType = "CREDIT_NOTE"
+)
+
+// BankAccount identifies a bank account used to send or receive payments
+type BankAccount struct {
+	// Account holder name
+	Name string `json:"name"`
+	// International bank account number
+	IBAN string `json:"iban"`
+	// Bank identifier code of the servicing institution
+	BIC string `json:"bic,omitempty"`
+}
+
+// BillLine represents a single expense line on a vendor bill
+type BillLine struct {
+	Description      string      `json:"description"`
+	Amount           money.Money `json:"amount"`
+	ExpenseAccountID string      `json:"expense_account_id"`
+	// Recoverable tax on the line, if any
+	TaxAmount    money.Money `json:"tax_amount,omitempty"`
+	TaxAccountID string      `json:"tax_account_id,omitempty"`
+}
+
+// Settlement records a payment or credit note applied to a bill
+type Settlement struct {
+	Type SettlementType `json:"type"`
+	// Payment, payment batch or credit note that settled the amount
+	Reference     string          `json:"reference"`
+	Date          time.Time       `json:"date"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID string          `json:"transaction_id"`
+	// Exchange gain, or loss when negative, realized in the base currency
+	// and the transaction it was posted in
+	RealizedFX      money.Money `json:"realized_fx,omitempty"`
+	FXTransactionID string      `json:"fx_transaction_id,omitempty"`
+}
+
+// Bill represents an invoice received from a vendor
+type Bill struct {
+	ID string `json:"id"`
+	// Vendor's own invoice number
+	Number           string          `json:"number"`
+	VendorID         string          `json:"vendor_id"`
+	Status           BillStatus      `json:"status"`
+	Currency         string          `json:"currency"`
+	Lines            []BillLine      `json:"lines"`
+	PayableAccountID string          `json:"payable_account_id"`
+	BillDate         time.Time       `json:"bill_date"`
+	DueDate          time.Time       `json:"due_date"`
+	PayTo            *BankAccount    `json:"pay_to,omitempty"`
+	AmountPaid       decimal.Decimal `json:"amount_paid"`
+	AmountCredited   decimal.Decimal `json:"amount_credited"`
+	// Rate to the base currency the bill was booked at, for bills in a
+	// foreign currency
+	ExchangeRate  decimal.Decimal `json:"exchange_rate,omitempty"`
+	Settlements   []Settlement    `json:"settlements,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Created       time.Time       `json:"created"`
+	LastModified  time.Time       `json:"last_modified"`
+}
+
+// GetID returns the bill identifier
+func (b *Bill) GetID() string { return b.ID }
+
+// Total returns the gross amount of the bill including tax
+func (b *Bill) Total() money.Money {
+	total := decimal.Zero
+	for _, line := range b.Lines {
+		total = total.Add(line.Amount.Amount).Add(line.TaxAmount.Amount)
+	}
+	return money.Money{Amount: total, Currency: b.Currency}
+}
+
+// OpenBalance returns the amount still owed to the vendor
+func (b *Bill) OpenBalance() money.Money {
+	open := b.Total().Amount.Sub(b.AmountPaid).Sub(b.AmountCredited)
+	return money.Money{Amount: open, Currency: b.Currency}
+}
+
+// OpenBalanceAt returns the amount owed to the vendor at the end of a day,
+// counting only settlements made by then. Bills not yet posted or dated
+// later owe nothing.
+func (b *Bill) OpenBalanceAt(asOf time.Time) money.Money {
+	open := decimal.Zero
+	if b.Status != Draft && b.Status != Cancelled && !b.BillDate.After(asOf) {
+		open = b.Total().Amount
+		for _, s := range b.Settlements {
+			if !s.Date.After(asOf) {
+				open = open.Sub(s.Amount)
+			}
+		}
+	}
+	return money.Money{Amount: open, Currency: b.Currency}
+}
+
+// settle applies a payment or credit note to the bill and updates its status
+func (b *Bill) settle(s Settlement) {
+	switch s.Type {
+	case SettledByPayment:
+		b.AmountPaid = b.AmountPaid.Add(s.Amount)
+	case SettledByCreditNote:
+		b.AmountCredited = b.AmountCredited.Add(s.Amount)
+	}
+	b.Settlements = append(b.Settlements, s)
+	b.Status = PartiallyPaid
+	if !b.OpenBalance().IsPositive() {
+		b.Status = Paid
+	}
+}
+
+// IsOpen returns true if the bill is posted and still has a balance owing
+func (b *Bill) IsOpen() bool {
+	return (b.Status == Posted || b.Status == PartiallyPaid) && b.OpenBalance().IsPositive()
+}
+
+// PaymentRunStatus represents the state of a payment run
+type PaymentRunStatus string
+
+const (
+	RunProposed PaymentRunStatus = "PROPOSED"
+	RunExecuted PaymentRunStatus = "EXECUTED"
+)
+
+// PaymentRunCriteria controls which bills are selected for a payment run
+type PaymentRunCriteria struct {
+	// Bills due on or before this date are selected
+	DueBy time.Time
+	// Date the payments will be executed
+	ExecutionDate time.Time
+	// Only bills in this currency are selected
+	Currency string
+	// Optional restriction to specific vendors
+	VendorIDs []string
+	// Ledger account credited when payments are posted
+	PaymentAccountID string
+	// Bank account the payments are made from
+	Debtor BankAccount
+}
+
+// PaymentBatch groups the bills paid to a single vendor bank account
+type PaymentBatch struct {
+	VendorID      string      `json:"vendor_id"`
+	Creditor      BankAccount `json:"creditor"`
+	Amount        money.Money `json:"amount"`
+	BillIDs       []string    `json:"bill_ids"`
+	Reference     string      `json:"reference"`
+	TransactionID string      `json:"transaction_id,omitempty"`
+}
+
+// PaymentRun is a set of vendor payments proposed or executed together
+type PaymentRun struct {
+	ID               string           `json:"id"`
+	Status           PaymentRunStatus `json:"status"`
+	ExecutionDate    time.Time        `json:"execution_date"`
+	Currency         string           `json:"currency"`
+	PaymentAccountID string           `json:"payment_account_id"`
+	Debtor           BankAccount      `json:"debtor"`
+	// Rate to the base currency the payments are made at; looked up for the
+	// execution date when zero
+	ExchangeRate decimal.Decimal `json:"exchange_rate,omitempty"`
+	Batches      []PaymentBatch  `json:"batches"`
+	Created      time.Time       `json:"created"`
+	ExecutedAt   *time.Time      `json:"executed_at,omitempty"`
+}
+
+// GetID returns the payment run identifier
+func (r *PaymentRun) GetID() string { return r.ID }
+
+// Total returns the sum of all batch amounts in the run
+func (r *PaymentRun) Total() money.Money {
+	total := decimal.Zero
+	for _, batch := range r.Batches {
+		total = total.Add(batch.Amount.Amount)
+	}
+	return money.Money{Amount: total, Currency: r.Currency}
+}
+
+// Allocation applies part of a payment to a bill
+type Allocation struct {
+	BillID string          `json:"bill_id"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Payment represents a single payment to a vendor outside a payment run,
+// which may settle bills in part
+type Payment struct {
+	ID       string      `json:"id"`
+	VendorID string      `json:"vendor_id"`
+	Date     time.Time   `json:"date"`
+	Amount   money.Money `json:"amount"`
+	// Ledger account credited with the payment, such as a bank account
+	PaymentAccountID string       `json:"payment_account_id"`
+	Reference        string       `json:"reference,omitempty"`
+	Allocations      []Allocation `json:"allocations"`
+	// Rate to the base currency the payment was made at; looked up for the
+	// payment date when zero
+	ExchangeRate  decimal.Decimal `json:"exchange_rate,omitempty"`
+	TransactionID string          `json:"transaction_id,omitempty"`
+	Created       time.Time       `json:"created"`
+}
+
+// GetID returns the payment identifier
+func (p *Payment) GetID() string { return p.ID }
+
+// CreditNote represents a credit received from a vendor against a bill, for
+// returns or price corrections. Its lines reverse the bill's expense and tax.
+type CreditNote struct {
+	ID string `json:"id"`
+	// Vendor's own credit note number
+	Number   string     `json:"number"`
+	VendorID string     `json:"vendor_id"`
+	BillID   string     `json:"bill_id"`
+	Currency string     `json:"currency"`
+	Lines    []BillLine `json:"lines"`
+	// Defaults to the payable account of the bill
+	PayableAccountID string    `json:"payable_account_id"`
+	Date             time.Time `json:"date"`
+	TransactionID    string    `json:"transaction_id,omitempty"`
+	Created          time.Time `json:"created"`
+}
+
+// GetID returns the credit note identifier
+func (n *CreditNote) GetID() string { return n.ID }
+
+// Total returns the gross amount of the credit note including tax
+func (n *CreditNote) Total() money.Money {
+	total := decimal.Zero
+	for _, line := range n.Lines {
+		total = total.Add(line.Amount.Amount).Add(line.TaxAmount.Amount)
+	}
+	return money.Money{Amount: total, Currency: n.Currency}
+}
@@ -0,0 +1,59 @@
+package period
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+)
+
+// PeriodID returns the ID given to a fiscal period, e.g. FY2024-P03
+func PeriodID(fiscalYear, number int) string {
+	return fmt.Sprintf("FY%d-P%02d", fiscalYear, number)
+}
+
+// DefineFiscalYear adds every period of a fiscal year as laid out by the
+// calendar. No periods are added if any of them overlaps an existing one.
+func (m *Manager) DefineFiscalYear(cal entity.FiscalCalendar, fiscalYear int, loc *time.Location) ([]*Period, error) {
+	if err := cal.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPeriod, err)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	periods := make([]*Period, 0, cal.PeriodsPerYear)
+	for n := 1; n <= cal.PeriodsPerYear; n++ {
+		fp, err := cal.Period(fiscalYear, n, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPeriod, err)
+		}
+		periods = append(periods, &Period{
+			ID:         PeriodID(fiscalYear, n),
+			Name:       fmt.Sprintf("FY%d period %d", fiscalYear, n),
+			Start:      fp.Start,
+			End:        fp.End,
+			Status:     Open,
+			FiscalYear: fiscalYear,
+			Number:     n,
+		})
+	}
+
+	m.mu.RLock()
+	for _, p := range periods {
+		for _, existing := range m.periods {
+			if !p.Start.After(existing.End) && !p.End.Before(existing.Start) {
+				m.mu.RUnlock()
+				return nil, fmt.Errorf("%w: %s overlaps %s", ErrOverlappingPeriod, p.ID, existing.ID)
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, p := range periods {
+		if err := m.Define(p); err != nil {
+			return nil, err
+		}
+	}
+	return periods, nil
+}
@@ -0,0 +1,224 @@
+package period
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/accrual"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceFunc returns an account's activity within a date range, positive
+// on the account's normal side. reporting.ReportCalculator.CalculateBalance
+// can be adapted to it.
+type BalanceFunc func(ctx context.Context, accountID string, start, end time.Time) (money.Money, error)
+
+// Closer runs the period-end close: it rolls revenue and expense balances
+// into retained earnings and then hard closes the period
+type Closer struct {
+	periods   *Manager
+	accounts  account.Repository
+	balances  BalanceFunc
+	repo      storage.Repository
+	processor transaction.TransactionProcessor
+	locker    transaction.Locker
+	accruals  *accrual.Engine
+}
+
+// NewCloser creates a period-end closer. Closing transactions are stored in
+// the repository and posted with the processor.
+func NewCloser(periods *Manager, accounts account.Repository, balances BalanceFunc, repo storage.Repository, processor transaction.TransactionProcessor) *Closer {
+	return &Closer{
+		periods:   periods,
+		accounts:  accounts,
+		balances:  balances,
+		repo:      repo,
+		processor: processor,
+	}
+}
+
+// SetLocker sets the locker used to lock the transactions of a period once
+// it is closed. Without one, transactions are left unlocked.
+func (c *Closer) SetLocker(locker transaction.Locker) {
+	c.locker = locker
+}
+
+// SetAccruals sets the engine whose recurring accruals are posted when a
+// period closes and reversed at the start of the next one
+func (c *Closer) SetAccruals(engine *accrual.Engine) {
+	c.accruals = engine
+}
+
+// ClosePeriod posts closing entries for a period and hard closes it. Each
+// currency gets one closing transaction, dated at the end of the period.
+// It brings revenue and expense accounts to zero and moves the net income
+// or loss to retained earnings. The closing transactions post in one batch.
+// Open periods are soft closed first. A locker, when set, locks the
+// period's posted transactions after the hard close. An accrual engine,
+// when set, posts its accruals before the closing entries and reverses the
+// auto-reversing ones at the start of the next period.
+func (c *Closer) ClosePeriod(ctx context.Context, periodID, retainedEarningsID string) ([]*transaction.Transaction, error) {
+	if err := auth.Require(ctx, auth.PeriodClose); err != nil {
+		return nil, err
+	}
+	if retainedEarningsID == "" {
+		return nil, fmt.Errorf("%w: a retained earnings account is required", ErrInvalidClose)
+	}
+
+	p, err := c.periods.Period(periodID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == Closed {
+		return nil, fmt.Errorf("%w: %s is already closed", ErrInvalidClose, periodID)
+	}
+	// Accruals are posted first so the closing entries include them
+	if c.accruals != nil {
+		if _, err := c.accruals.OnPeriodClose(ctx, accrual.Period{Start: p.Start, End: p.End}); err != nil {
+			return nil, fmt.Errorf("failed to post accruals for %s: %w", periodID, err)
+		}
+	}
+	if p.Status == Open {
+		if p, err = c.periods.SoftClose(ctx, periodID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := storage.Query{
+		Filters: []storage.Filter{{
+			Field:    "type",
+			Operator: "in",
+			Value:    []account.AccountType{account.Revenue, account.Expense},
+		}},
+		Sort: []storage.Sort{{Field: "code", Desc: false}},
+	}
+	var accounts []*account.Account
+	if err := c.accounts.Query(ctx, entity.ScopeQuery(ctx, query), &accounts); err != nil {
+		return nil, fmt.Errorf("error querying accounts: %w", err)
+	}
+
+	txs, err := c.closingEntries(ctx, p, accounts, retainedEarningsID)
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) > 0 {
+		if err := transaction.CreateAndPostBatch(ctx, c.repo, c.processor, txs); err != nil {
+			return nil, fmt.Errorf("failed to post closing entries: %w", err)
+		}
+	}
+
+	if _, err := c.periods.HardClose(ctx, periodID); err != nil {
+		return txs, err
+	}
+	if c.locker != nil {
+		reason := fmt.Sprintf("period %s closed", periodID)
+		if _, err := c.locker.LockPeriod(ctx, p.Start, p.End, reason); err != nil {
+			return txs, fmt.Errorf("failed to lock transactions of %s: %w", periodID, err)
+		}
+	}
+	if c.accruals != nil {
+		if _, err := c.accruals.OnPeriodOpen(ctx, c.nextPeriod(p)); err != nil {
+			return txs, fmt.Errorf("failed to reverse accruals of %s: %w", periodID, err)
+		}
+	}
+	return txs, nil
+}
+
+// nextPeriod returns the period following p: the first defined period
+// starting after it ends, or one starting on the next day
+func (c *Closer) nextPeriod(p *Period) accrual.Period {
+	for _, next := range c.periods.Periods() {
+		if next.Start.After(p.End) {
+			return accrual.Period{Start: next.Start, End: next.End}
+		}
+	}
+	start := time.Date(p.End.Year(), p.End.Month(), p.End.Day()+1, 0, 0, 0, 0, p.End.Location())
+	return accrual.Period{Start: start, End: start}
+}
+
+// closingEntries builds the closing transactions for a period
+func (c *Closer) closingEntries(ctx context.Context, p *Period, accounts []*account.Account, retainedEarningsID string) ([]*transaction.Transaction, error) {
+	entries := make(map[string][]transaction.Entry)
+	// Net credit to retained earnings by currency
+	net := make(map[string]decimal.Decimal)
+
+	for _, acc := range accounts {
+		if acc.Type != account.Revenue && acc.Type != account.Expense {
+			continue
+		}
+		balance, err := c.balances(ctx, acc.ID, p.Start, p.End)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating balance for account %s: %w", acc.ID, err)
+		}
+		if balance.IsZero() {
+			continue
+		}
+
+		// Clear the balance from the side opposite its normal side
+		side := transaction.Debit
+		change := balance.Amount
+		if acc.Type == account.Expense {
+			side = transaction.Credit
+			change = change.Neg()
+		}
+		if balance.IsNegative() {
+			side = side.Reverse()
+		}
+		entries[balance.Currency] = append(entries[balance.Currency], transaction.Entry{
+			AccountID:   acc.ID,
+			Amount:      balance.Abs(),
+			Type:        side,
+			Description: fmt.Sprintf("Close %s to retained earnings", acc.Code),
+		})
+		net[balance.Currency] = net[balance.Currency].Add(change)
+	}
+
+	currencies := make([]string, 0, len(entries))
+	for currency := range entries {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	now := time.Now()
+	txs := make([]*transaction.Transaction, 0, len(currencies))
+	for _, currency := range currencies {
+		lines := entries[currency]
+		if amount := net[currency]; !amount.IsZero() {
+			side := transaction.Credit
+			if amount.IsNegative() {
+				side = transaction.Debit
+			}
+			lines = append(lines, transaction.Entry{
+				AccountID:   retainedEarningsID,
+				Amount:      money.Money{Amount: amount.Abs(), Currency: currency},
+				Type:        side,
+				Description: "Net income for " + p.Name,
+			})
+		}
+		if len(lines) < 2 {
+			continue
+		}
+
+		effective := p.End
+		txs = append(txs, &transaction.Transaction{
+			ID:            fmt.Sprintf("CLOSE-%s-%s", p.ID, currency),
+			Type:          transaction.Closing,
+			Status:        transaction.Draft,
+			Date:          p.End,
+			EffectiveDate: &effective,
+			Description:   fmt.Sprintf("Closing entries for %s", p.Name),
+			Entries:       lines,
+			Created:       now,
+			LastModified:  now,
+		})
+	}
+	return txs, nil
+}
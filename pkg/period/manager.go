@@ -0,0 +1,270 @@
+package period
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// Manager tracks accounting periods, enforces their posting rules and
+// records the adjustments made while they are soft closed
+type Manager struct {
+	mu          sync.RWMutex
+	periods     map[string]*Period
+	adjustments map[string][]Adjustment
+	now         func() time.Time
+}
+
+// NewManager creates an empty period manager
+func NewManager() *Manager {
+	return &Manager{
+		periods:     make(map[string]*Period),
+		adjustments: make(map[string][]Adjustment),
+		now:         time.Now,
+	}
+}
+
+// Define adds an open period. Periods may not overlap.
+func (m *Manager) Define(p *Period) error {
+	if p.ID == "" {
+		return fmt.Errorf("%w: ID is required", ErrInvalidPeriod)
+	}
+	if p.End.Before(p.Start) {
+		return fmt.Errorf("%w: %s ends before it starts", ErrInvalidPeriod, p.ID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.periods {
+		if existing.ID == p.ID {
+			return fmt.Errorf("%w: %s already exists", ErrInvalidPeriod, p.ID)
+		}
+		if !p.Start.After(existing.End) && !p.End.Before(existing.Start) {
+			return fmt.Errorf("%w: %s overlaps %s", ErrOverlappingPeriod, p.ID, existing.ID)
+		}
+	}
+
+	stored := *p
+	if stored.Status == "" {
+		stored.Status = Open
+	}
+	m.periods[p.ID] = &stored
+	return nil
+}
+
+// Period returns a copy of a period
+func (m *Manager) Period(id string) (*Period, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.periods[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPeriodNotFound, id)
+	}
+	copied := *p
+	return &copied, nil
+}
+
+// Periods returns copies of all periods ordered by start date
+func (m *Manager) Periods() []*Period {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	periods := make([]*Period, 0, len(m.periods))
+	for _, p := range m.periods {
+		copied := *p
+		periods = append(periods, &copied)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods
+}
+
+// PeriodFor returns a copy of the period containing a date
+func (m *Manager) PeriodFor(date time.Time) (*Period, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if p := m.periodFor(date); p != nil {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("%w: no period contains %s", ErrPeriodNotFound, date.Format("2006-01-02"))
+}
+
+// SoftClose blocks normal postings into an open period while still allowing
+// adjusting entries
+func (m *Manager) SoftClose(ctx context.Context, id string) (*Period, error) {
+	return m.transition(ctx, id, Open, SoftClosed)
+}
+
+// Reopen returns a soft-closed period to open
+func (m *Manager) Reopen(ctx context.Context, id string) (*Period, error) {
+	return m.transition(ctx, id, SoftClosed, Open)
+}
+
+// HardClose blocks all postings into a soft-closed period
+func (m *Manager) HardClose(ctx context.Context, id string) (*Period, error) {
+	return m.transition(ctx, id, SoftClosed, Closed)
+}
+
+func (m *Manager) transition(ctx context.Context, id string, from, to Status) (*Period, error) {
+	if err := auth.Require(ctx, auth.PeriodClose); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.periods[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPeriodNotFound, id)
+	}
+	if p.Status != from {
+		return nil, fmt.Errorf("%w: %s is %s, not %s", ErrInvalidTransition, id, p.Status, from)
+	}
+
+	now := m.now()
+	user := actor(ctx)
+	switch to {
+	case SoftClosed:
+		p.SoftClosedAt, p.SoftClosedBy = &now, user
+	case Closed:
+		p.ClosedAt, p.ClosedBy = &now, user
+	case Open:
+		p.SoftClosedAt, p.SoftClosedBy = nil, ""
+	}
+	p.Status = to
+
+	copied := *p
+	return &copied, nil
+}
+
+// CheckPosting reports whether a transaction may be posted into the period
+// containing its date. Soft-closed periods also accept the closing entries
+// posted by ClosePeriod. Dates outside every defined period are not
+// restricted.
+func (m *Manager) CheckPosting(ctx context.Context, tx *transaction.Transaction) error {
+	m.mu.RLock()
+	p := m.periodFor(tx.Date)
+	m.mu.RUnlock()
+	if p == nil {
+		return nil
+	}
+
+	switch p.Status {
+	case Closed:
+		return fmt.Errorf("%w: %s", ErrPeriodClosed, p.ID)
+	case SoftClosed:
+		if tx.Type == transaction.Closing {
+			return auth.Require(ctx, auth.PeriodClose)
+		}
+		if tx.Type != transaction.Adjusting {
+			return fmt.Errorf("%w: %s accepts adjusting entries only", ErrPeriodSoftClosed, p.ID)
+		}
+		return auth.Require(ctx, auth.TransactionAdjust)
+	}
+	return nil
+}
+
+// RecordPosting notes a posted transaction. Adjusting entries posted into a
+// soft-closed period are added to that period's adjustment log, once each.
+// A PostingRecorder subscribed to the processor's events calls it for every
+// posting.
+func (m *Manager) RecordPosting(ctx context.Context, tx *transaction.Transaction) {
+	if tx.Type != transaction.Adjusting {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.periodFor(tx.Date)
+	if p == nil || p.Status != SoftClosed {
+		return
+	}
+	for _, adj := range m.adjustments[p.ID] {
+		if adj.TransactionID == tx.ID {
+			return
+		}
+	}
+
+	amount := money.Money{Amount: decimal.Zero}
+	for _, entry := range tx.Entries {
+		if entry.Type == transaction.Debit {
+			amount.Currency = entry.Amount.Currency
+			amount.Amount = amount.Amount.Add(entry.Amount.Amount)
+		}
+	}
+
+	postedBy := tx.CreatedBy
+	if postedBy == "" {
+		postedBy = actor(ctx)
+	}
+	m.adjustments[p.ID] = append(m.adjustments[p.ID], Adjustment{
+		PeriodID:      p.ID,
+		TransactionID: tx.ID,
+		Date:          tx.Date,
+		Description:   tx.Description,
+		Amount:        amount,
+		PostedBy:      postedBy,
+		RecordedAt:    m.now(),
+	})
+}
+
+// Adjustments returns the adjustments recorded against a period
+func (m *Manager) Adjustments(id string) []Adjustment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Adjustment(nil), m.adjustments[id]...)
+}
+
+// AdjustmentPacket summarises the adjustments made to a period between its
+// soft and hard close
+func (m *Manager) AdjustmentPacket(id string) (*AdjustmentPacket, error) {
+	p, err := m.Period(id)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := &AdjustmentPacket{
+		Period:      *p,
+		Adjustments: m.Adjustments(id),
+		Totals:      make(map[string]money.Money),
+		Generated:   m.now(),
+	}
+	for _, adj := range packet.Adjustments {
+		total, ok := packet.Totals[adj.Amount.Currency]
+		if !ok {
+			total = money.Money{Amount: decimal.Zero, Currency: adj.Amount.Currency}
+		}
+		total.Amount = total.Amount.Add(adj.Amount.Amount)
+		packet.Totals[adj.Amount.Currency] = total
+	}
+	return packet, nil
+}
+
+func (m *Manager) periodFor(date time.Time) *Period {
+	for _, p := range m.periods {
+		if p.Contains(date) {
+			return p
+		}
+	}
+	return nil
+}
+
+// actor identifies the user performing an operation
+func actor(ctx context.Context) string {
+	if id := auth.PrincipalID(ctx); id != "" {
+		return id
+	}
+	return audit.UserID(ctx)
+}
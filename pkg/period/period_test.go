@@ -0,0 +1,330 @@
+package period
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/accrual"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func entry(txType transaction.TransactionType, id string, date time.Time, amount int64) *transaction.Transaction {
+	return &transaction.Transaction{
+		ID:          id,
+		Type:        txType,
+		Date:        date,
+		Description: "Accrue audit fees",
+		Entries: []transaction.Entry{
+			{AccountID: "6100", Amount: usd(amount), Type: transaction.Debit},
+			{AccountID: "2100", Amount: usd(amount), Type: transaction.Credit},
+		},
+	}
+}
+
+func principal(id string, perms ...auth.Permission) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{
+		ID:    id,
+		Roles: []*auth.Role{{Name: id, Permissions: perms}},
+	})
+}
+
+func TestSoftClose(t *testing.T) {
+	january := &Period{
+		ID:    "2024-01",
+		Name:  "January 2024",
+		Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC),
+	}
+	manager := NewManager()
+	require.NoError(t, manager.Define(january))
+	assert.ErrorIs(t, manager.Define(&Period{ID: "overlap", Start: january.End, End: january.End.AddDate(0, 1, 0)}), ErrOverlappingPeriod)
+
+	controller := principal("controller", auth.PeriodClose, auth.TransactionPost, auth.TransactionAdjust)
+	clerk := principal("clerk", auth.TransactionPost)
+	engine := validation.NewBasicValidationEngine()
+	require.NoError(t, engine.RegisterValidator(NewPostingValidator(manager)))
+
+	inJanuary := january.Start.AddDate(0, 0, 30)
+
+	// Open periods accept everything
+	results, err := engine.Validate(clerk, entry(transaction.Journal, "tx-1", inJanuary, 100))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = manager.SoftClose(clerk, january.ID)
+	assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+	soft, err := manager.SoftClose(controller, january.ID)
+	require.NoError(t, err)
+	assert.Equal(t, SoftClosed, soft.Status)
+	assert.Equal(t, "controller", soft.SoftClosedBy)
+
+	t.Run("soft closed", func(t *testing.T) {
+		results, err := engine.Validate(controller, entry(transaction.Journal, "tx-2", inJanuary, 100))
+		var invalid *validation.ValidationError
+		require.ErrorAs(t, err, &invalid)
+		require.Len(t, results, 1)
+		assert.Equal(t, "PERIOD_SOFT_CLOSED", results[0].Code)
+
+		results, err = engine.Validate(clerk, entry(transaction.Adjusting, "adj-0", inJanuary, 100))
+		require.ErrorAs(t, err, &invalid)
+		require.Len(t, results, 1)
+		assert.Equal(t, "PERIOD_ADJUSTMENT_DENIED", results[0].Code)
+
+		// Later periods are not affected
+		results, err = engine.Validate(clerk, entry(transaction.Journal, "tx-3", inJanuary.AddDate(0, 1, 0), 100))
+		require.NoError(t, err)
+		assert.Empty(t, results)
+
+		for i, amount := range []int64{250, 75} {
+			adj := entry(transaction.Adjusting, []string{"adj-1", "adj-2"}[i], inJanuary, amount)
+			results, err := engine.Validate(controller, adj)
+			require.NoError(t, err)
+			assert.Empty(t, results)
+			manager.RecordPosting(controller, adj)
+		}
+		manager.RecordPosting(controller, entry(transaction.Journal, "tx-4", inJanuary.AddDate(0, 1, 0), 10))
+	})
+
+	t.Run("hard closed", func(t *testing.T) {
+		_, err := manager.Reopen(controller, "2024-02")
+		assert.ErrorIs(t, err, ErrPeriodNotFound)
+
+		closed, err := manager.HardClose(controller, january.ID)
+		require.NoError(t, err)
+		assert.Equal(t, Closed, closed.Status)
+		_, err = manager.Reopen(controller, january.ID)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+
+		results, err := engine.Validate(controller, entry(transaction.Adjusting, "adj-3", inJanuary, 100))
+		var invalid *validation.ValidationError
+		require.ErrorAs(t, err, &invalid)
+		require.Len(t, results, 1)
+		assert.Equal(t, "PERIOD_CLOSED", results[0].Code)
+
+		packet, err := manager.AdjustmentPacket(january.ID)
+		require.NoError(t, err)
+		require.Len(t, packet.Adjustments, 2)
+		assert.Equal(t, "adj-1", packet.Adjustments[0].TransactionID)
+		assert.Equal(t, "controller", packet.Adjustments[0].PostedBy)
+		assert.True(t, packet.Totals["USD"].Amount.Equal(decimal.NewFromInt(325)))
+		assert.Equal(t, Closed, packet.Period.Status)
+	})
+}
+
+func TestPostingRecorder(t *testing.T) {
+	manager := NewManager()
+	periods, err := manager.DefineFiscalYear(entity.FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 12}, 2024, nil)
+	require.NoError(t, err)
+	january := periods[0]
+	controller := principal("controller", auth.PeriodClose, auth.TransactionPost, auth.TransactionAdjust)
+	_, err = manager.SoftClose(controller, january.ID)
+	require.NoError(t, err)
+
+	// Postings reach the manager through the processor's events
+	store := memory.NewMemoryStore()
+	bus := event.NewMemoryBus()
+	outbox := event.NewOutbox(store)
+	processor := transaction.NewBasicTransactionProcessor(store)
+	processor.SetOutbox(outbox)
+	recorder := NewPostingRecorder(manager, store)
+	require.NoError(t, recorder.Subscribe(bus))
+
+	post := func(id string, date time.Time, amount int64) {
+		tx := entry(transaction.Adjusting, id, date, amount)
+		tx.Status = transaction.Draft
+		require.NoError(t, transaction.CreateAndPost(controller, store, processor, tx))
+	}
+	inJanuary := january.Start.AddDate(0, 0, 30)
+	post("adj-1", inJanuary, 250)
+	post("adj-2", inJanuary.AddDate(0, 1, 0), 40)
+	_, err = outbox.Dispatch(context.Background(), bus)
+	require.NoError(t, err)
+
+	adjustments := manager.Adjustments(january.ID)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, "adj-1", adjustments[0].TransactionID)
+	assert.Equal(t, "controller", adjustments[0].PostedBy)
+	assert.True(t, adjustments[0].Amount.Amount.Equal(decimal.NewFromInt(250)))
+	assert.Empty(t, manager.Adjustments(periods[1].ID), "open periods keep no log")
+
+	// Redelivered events are recorded once
+	require.NoError(t, recorder.Handle(context.Background(), event.Event{
+		Type: event.TransactionPosted,
+		Data: event.TransactionPostedV1{TransactionID: "adj-1"},
+	}))
+	assert.Len(t, manager.Adjustments(january.ID), 1)
+}
+
+// chart returns a fixed set of accounts for any query
+type chart struct {
+	account.Repository
+	accounts []*account.Account
+}
+
+func (c *chart) Query(ctx context.Context, query interface{}, results interface{}) error {
+	*(results.(*[]*account.Account)) = c.accounts
+	return nil
+}
+
+// poster posts transactions with the library's processor, enforcing the
+// manager's periods
+type poster struct {
+	*transaction.BasicTransactionProcessor
+	manager *Manager
+}
+
+func (p *poster) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	if err := p.manager.CheckPosting(ctx, tx); err != nil {
+		return err
+	}
+	return p.BasicTransactionProcessor.ProcessTransaction(ctx, tx)
+}
+
+func (p *poster) ProcessTransactionBatch(ctx context.Context, txs []*transaction.Transaction) error {
+	for _, tx := range txs {
+		if err := p.manager.CheckPosting(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return p.BasicTransactionProcessor.ProcessTransactionBatch(ctx, txs)
+}
+
+func TestFiscalCalendar(t *testing.T) {
+	manager := NewManager()
+	periods, err := manager.DefineFiscalYear(entity.FiscalCalendar{StartMonth: time.April, PeriodsPerYear: 4}, 2025, nil)
+	require.NoError(t, err)
+	require.Len(t, periods, 4)
+	assert.Equal(t, "FY2025-P01", periods[0].ID)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), periods[0].Start)
+
+	p, err := manager.PeriodFor(time.Date(2025, 2, 14, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "FY2025-P04", p.ID)
+	assert.Equal(t, 4, p.Number)
+
+	_, err = manager.DefineFiscalYear(entity.FiscalCalendar{StartMonth: time.April, PeriodsPerYear: 4}, 2025, nil)
+	assert.ErrorIs(t, err, ErrOverlappingPeriod)
+	assert.Len(t, manager.Periods(), 4)
+
+	var _ PeriodManager = manager
+}
+
+func TestClosePeriod(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager()
+	periods, err := manager.DefineFiscalYear(entity.FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 12}, 2024, nil)
+	require.NoError(t, err)
+	january := periods[0]
+
+	accounts := &chart{accounts: []*account.Account{
+		{ID: "4000", Code: "4000", Type: account.Revenue},
+		{ID: "4900", Code: "4900", Type: account.Revenue},
+		{ID: "6100", Code: "6100", Type: account.Expense},
+	}}
+	balances := map[string]money.Money{"4000": usd(1000), "6100": usd(700)}
+	balanceOf := func(ctx context.Context, accountID string, start, end time.Time) (money.Money, error) {
+		if b, ok := balances[accountID]; ok {
+			return b, nil
+		}
+		return usd(0), nil
+	}
+	store := memory.NewMemoryStore()
+	processor := &poster{BasicTransactionProcessor: transaction.NewBasicTransactionProcessor(store), manager: manager}
+	closer := NewCloser(manager, accounts, balanceOf, store, processor)
+
+	txs, err := closer.ClosePeriod(ctx, january.ID, "3900")
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	closing := txs[0]
+	assert.Equal(t, transaction.Closing, closing.Type)
+	assert.Equal(t, january.End, closing.Date)
+	assert.Equal(t, []transaction.Entry{
+		{AccountID: "4000", Amount: usd(1000), Type: transaction.Debit, Description: "Close 4000 to retained earnings"},
+		{AccountID: "6100", Amount: usd(700), Type: transaction.Credit, Description: "Close 6100 to retained earnings"},
+		{AccountID: "3900", Amount: usd(300), Type: transaction.Credit, Description: "Net income for FY2024 period 1"},
+	}, closing.Entries)
+	stored, err := processor.GetTransaction(ctx, closing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, stored.Status)
+
+	closed, err := manager.Period(january.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Closed, closed.Status)
+
+	// The closed period now rejects postings
+	assert.ErrorIs(t, manager.CheckPosting(ctx, entry(transaction.Journal, "JE-LATE", january.End, 5)), ErrPeriodClosed)
+	_, err = closer.ClosePeriod(ctx, january.ID, "3900")
+	assert.ErrorIs(t, err, ErrInvalidClose)
+
+	t.Run("Net Loss", func(t *testing.T) {
+		balances["6100"] = usd(1500)
+		txs, err := closer.ClosePeriod(ctx, periods[1].ID, "3900")
+		require.NoError(t, err)
+		last := txs[0].Entries[len(txs[0].Entries)-1]
+		assert.Equal(t, transaction.Debit, last.Type)
+		assert.Equal(t, usd(500), last.Amount)
+	})
+
+	t.Run("Requires Permission", func(t *testing.T) {
+		_, err := closer.ClosePeriod(principal("clerk"), periods[2].ID, "3900")
+		assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+	})
+}
+
+func TestClosePeriodAccruals(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager()
+	periods, err := manager.DefineFiscalYear(entity.FiscalCalendar{StartMonth: time.January, PeriodsPerYear: 12}, 2024, nil)
+	require.NoError(t, err)
+	january, february := periods[0], periods[1]
+
+	store := memory.NewMemoryStore()
+	processor := &poster{BasicTransactionProcessor: transaction.NewBasicTransactionProcessor(store), manager: manager}
+	engine := accrual.NewEngine(store, processor)
+	require.NoError(t, engine.Register(&accrual.Definition{
+		ID:              "RENT",
+		Description:     "Office rent",
+		DebitAccountID:  "6100",
+		CreditAccountID: "2100",
+		Amount:          usd(1000),
+		StartDate:       january.End,
+		AutoReverse:     true,
+		Active:          true,
+	}))
+	balanceOf := func(ctx context.Context, accountID string, start, end time.Time) (money.Money, error) {
+		return usd(0), nil
+	}
+	closer := NewCloser(manager, &chart{}, balanceOf, store, processor)
+	closer.SetAccruals(engine)
+
+	_, err = closer.ClosePeriod(ctx, january.ID, "3900")
+	require.NoError(t, err)
+
+	postings, err := engine.Postings(ctx)
+	require.NoError(t, err)
+	require.Len(t, postings, 1)
+	accrued, err := processor.GetTransaction(ctx, postings[0].TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, accrued.Status)
+	assert.Equal(t, january.End, accrued.Date)
+
+	reversal, err := processor.GetTransaction(ctx, postings[0].ReversalID)
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, reversal.Status)
+	assert.Equal(t, february.Start, reversal.Date)
+}
@@ -0,0 +1,55 @@
+package period
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// PostingRecorder passes posted transactions to a manager's RecordPosting,
+// so adjusting entries posted into soft-closed periods reach the adjustment
+// log. It reads the transactions named by posting events from the
+// repository the processor posts to.
+type PostingRecorder struct {
+	manager      *Manager
+	transactions storage.Repository
+}
+
+// NewPostingRecorder creates a recorder for a manager's periods
+func NewPostingRecorder(manager *Manager, transactions storage.Repository) *PostingRecorder {
+	return &PostingRecorder{manager: manager, transactions: transactions}
+}
+
+// Subscribe registers the recorder for the events that post transactions
+func (r *PostingRecorder) Subscribe(bus event.Bus) error {
+	for _, eventType := range []string{event.TransactionPosted, event.TransactionReversed} {
+		if err := bus.Subscribe(eventType, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle implements event.Handler. A reversal is recorded as the posting
+// of the reversing transaction.
+func (r *PostingRecorder) Handle(ctx context.Context, e event.Event) error {
+	var txID string
+	switch data := e.Data.(type) {
+	case event.TransactionPostedV1:
+		txID = data.TransactionID
+	case event.TransactionReversedV1:
+		txID = data.ReversalID
+	default:
+		return fmt.Errorf("unexpected %s payload %T", e.Type, e.Data)
+	}
+
+	var tx transaction.Transaction
+	if err := r.transactions.Read(ctx, txID, &tx); err != nil {
+		return fmt.Errorf("failed to read posted transaction %s: %w", txID, err)
+	}
+	r.manager.RecordPosting(ctx, &tx)
+	return nil
+}
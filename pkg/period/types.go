@@ -0,0 +1,107 @@
+// Package period manages accounting periods and controls which entries may
+// be posted into them as the books move from open through soft close to
+// hard close.
+package period
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var (
+	ErrPeriodNotFound    = errors.New("period not found")
+	ErrInvalidPeriod     = errors.New("invalid period")
+	ErrOverlappingPeriod = errors.New("period overlaps an existing period")
+	ErrInvalidTransition = errors.New("invalid period status transition")
+	ErrPeriodClosed      = errors.New("period is closed")
+	ErrPeriodSoftClosed  = errors.New("period is soft closed")
+	ErrInvalidClose      = errors.New("period cannot be closed")
+)
+
+// Status represents the posting state of a period
+type Status string
+
+const (
+	// Open periods accept all postings
+	Open Status = "OPEN"
+	// SoftClosed periods accept only adjusting entries from authorised users
+	SoftClosed Status = "SOFT_CLOSED"
+	// Closed periods accept no postings
+	Closed Status = "CLOSED"
+)
+
+// PeriodManager defines period lifecycle operations and the posting rules
+// they imply
+type PeriodManager interface {
+	// Define adds an open period
+	Define(p *Period) error
+
+	// Period returns a period by ID
+	Period(id string) (*Period, error)
+
+	// Periods returns all periods ordered by start date
+	Periods() []*Period
+
+	// PeriodFor returns the period containing a date
+	PeriodFor(date time.Time) (*Period, error)
+
+	// SoftClose restricts an open period to adjusting entries
+	SoftClose(ctx context.Context, id string) (*Period, error)
+
+	// Reopen returns a soft-closed period to open
+	Reopen(ctx context.Context, id string) (*Period, error)
+
+	// HardClose blocks all postings into a soft-closed period
+	HardClose(ctx context.Context, id string) (*Period, error)
+
+	// CheckPosting reports whether a transaction may be posted
+	CheckPosting(ctx context.Context, tx *transaction.Transaction) error
+}
+
+// Period is a span of time the books are closed over
+type Period struct {
+	ID     string    `json:"id"`
+	Name   string    `json:"name"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Status Status    `json:"status"`
+	// Position in the fiscal calendar for periods generated from one
+	FiscalYear int `json:"fiscal_year,omitempty"`
+	Number     int `json:"number,omitempty"`
+	// When and by whom the period was soft closed
+	SoftClosedAt *time.Time `json:"soft_closed_at,omitempty"`
+	SoftClosedBy string     `json:"soft_closed_by,omitempty"`
+	// When and by whom the period was hard closed
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+	ClosedBy string     `json:"closed_by,omitempty"`
+}
+
+// Contains reports whether a date falls within the period
+func (p *Period) Contains(date time.Time) bool {
+	return !date.Before(p.Start) && !date.After(p.End)
+}
+
+// Adjustment records an adjusting entry posted while a period was soft closed
+type Adjustment struct {
+	PeriodID      string      `json:"period_id"`
+	TransactionID string      `json:"transaction_id"`
+	Date          time.Time   `json:"date"`
+	Description   string      `json:"description"`
+	Amount        money.Money `json:"amount"`
+	PostedBy      string      `json:"posted_by"`
+	RecordedAt    time.Time   `json:"recorded_at"`
+}
+
+// AdjustmentPacket lists the adjustments made between soft and hard close,
+// for inclusion in the period's audit packet
+type AdjustmentPacket struct {
+	Period      Period       `json:"period"`
+	Adjustments []Adjustment `json:"adjustments"`
+	// Total debits of the adjustments by currency
+	Totals    map[string]money.Money `json:"totals"`
+	Generated time.Time              `json:"generated"`
+}
@@ -0,0 +1,86 @@
+package period
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/johnayoung/finlib/pkg/validation"
+)
+
+// PostingValidator rejects transactions dated in closed periods, and
+// anything other than authorised adjusting entries in soft-closed periods
+type PostingValidator struct {
+	manager *Manager
+}
+
+// NewPostingValidator creates a validator enforcing a manager's periods
+func NewPostingValidator(manager *Manager) *PostingValidator {
+	return &PostingValidator{manager: manager}
+}
+
+// Validate implements validation.Validator
+func (v *PostingValidator) Validate(ctx context.Context, obj interface{}) ([]validation.ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	err := v.manager.CheckPosting(ctx, tx)
+	if err == nil {
+		return nil, nil
+	}
+
+	code := "PERIOD_CLOSED"
+	switch {
+	case errors.Is(err, ErrPeriodSoftClosed):
+		code = "PERIOD_SOFT_CLOSED"
+	case errors.Is(err, auth.ErrPermissionDenied):
+		code = "PERIOD_ADJUSTMENT_DENIED"
+	case !errors.Is(err, ErrPeriodClosed):
+		return nil, err
+	}
+	return []validation.ValidationResult{{
+		Code:     code,
+		Message:  err.Error(),
+		Severity: validation.Error,
+		Field:    "Date",
+	}}, nil
+}
+
+// Supports implements validation.ObjectFilter; only transactions are checked
+func (v *PostingValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements validation.Validator
+func (v *PostingValidator) GetRules() []validation.ValidationRule {
+	return []validation.ValidationRule{
+		{
+			ID:          "PERIOD_CLOSED",
+			Description: "Transactions cannot be posted into a closed period",
+			Severity:    validation.Error,
+			Category:    "PERIOD",
+		},
+		{
+			ID:          "PERIOD_SOFT_CLOSED",
+			Description: "Only adjusting entries can be posted into a soft-closed period",
+			Severity:    validation.Error,
+			Category:    "PERIOD",
+		},
+		{
+			ID:          "PERIOD_ADJUSTMENT_DENIED",
+			Description: "Adjusting entries require the transaction.adjust permission",
+			Severity:    validation.Error,
+			Category:    "PERIOD",
+		},
+	}
+}
+
+// Priority implements validation.Validator; period checks run early
+func (v *PostingValidator) Priority() int {
+	return 10
+}
@@ -0,0 +1,133 @@
+package receivable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/invoice"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// CustomerAging is a customer's line on an aging report
+type CustomerAging struct {
+	CustomerID string `json:"customer_id"`
+	reporting.AgingBuckets
+	// Invoices with an open balance, oldest due first
+	InvoiceIDs []string `json:"invoice_ids"`
+}
+
+// AgingReport lists open receivables by customer and age as of a date
+type AgingReport struct {
+	AsOf      time.Time              `json:"as_of"`
+	Currency  string                 `json:"currency"`
+	Customers []CustomerAging        `json:"customers"`
+	Totals    reporting.AgingBuckets `json:"totals"`
+	// Balance of the receivable accounts in the ledger and its difference
+	// from the aged total, when a report calculator is set
+	LedgerBalance *money.Money     `json:"ledger_balance,omitempty"`
+	Difference    *decimal.Decimal `json:"difference,omitempty"`
+}
+
+// Aging implements Manager.Aging. Balances are those open at the end of the
+// as-of day, so payments and write-offs made later are ignored. With a
+// report calculator set, the aged total is reconciled against the balance
+// of the receivable accounts the invoices were posted to.
+func (m *BasicManager) Aging(ctx context.Context, asOf time.Time, currency string) (*AgingReport, error) {
+	if currency == "" {
+		return nil, fmt.Errorf("currency is required")
+	}
+
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "currency", Operator: "=", Value: currency},
+			{Field: "type", Operator: "=", Value: invoice.StandardInvoice},
+			{Field: "status", Operator: "in", Value: []invoice.InvoiceStatus{invoice.Issued, invoice.PartiallyPaid, invoice.Paid, invoice.WrittenOff}},
+			{Field: "issue_date", Operator: "<=", Value: asOf},
+		},
+		Sort: []storage.Sort{
+			{Field: "due_date", Desc: false},
+		},
+	}
+	var invoices []*invoice.Invoice
+	if err := m.repo.Query(ctx, query, &invoices); err != nil {
+		return nil, fmt.Errorf("error querying invoices: %w", err)
+	}
+	sort.SliceStable(invoices, func(i, j int) bool {
+		return dueDate(invoices[i]).Before(dueDate(invoices[j]))
+	})
+
+	report := BuildAgingReport(invoices, asOf, currency)
+
+	if m.calculator != nil {
+		accounts := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, inv := range invoices {
+			if inv.Currency == currency && !seen[inv.ReceivableAccountID] {
+				seen[inv.ReceivableAccountID] = true
+				accounts = append(accounts, inv.ReceivableAccountID)
+			}
+		}
+
+		ledger := decimal.Zero
+		for _, accountID := range accounts {
+			balance, err := m.calculator.CalculateBalance(ctx, accountID, reporting.ReportPeriod{End: asOf})
+			if err != nil {
+				return nil, fmt.Errorf("error calculating balance of %s: %w", accountID, err)
+			}
+			ledger = ledger.Add(balance.Amount)
+		}
+		difference := ledger.Sub(report.Totals.Total())
+		report.LedgerBalance = &money.Money{Amount: ledger, Currency: currency}
+		report.Difference = &difference
+	}
+	return report, nil
+}
+
+// BuildAgingReport ages the open balances of invoices in a currency as of
+// a date. Customers are listed in order of their first open invoice.
+func BuildAgingReport(invoices []*invoice.Invoice, asOf time.Time, currency string) *AgingReport {
+	report := &AgingReport{
+		AsOf:      asOf,
+		Currency:  currency,
+		Customers: make([]CustomerAging, 0),
+	}
+	index := make(map[string]int)
+	for _, inv := range invoices {
+		if inv.Type != invoice.StandardInvoice || inv.Currency != currency {
+			continue
+		}
+		open := inv.OpenBalanceAt(asOf).Amount
+		if !open.IsPositive() {
+			continue
+		}
+
+		i, ok := index[inv.CustomerID]
+		if !ok {
+			i = len(report.Customers)
+			index[inv.CustomerID] = i
+			report.Customers = append(report.Customers, CustomerAging{CustomerID: inv.CustomerID})
+		}
+		days := int(asOf.Sub(dueDate(inv)).Hours() / 24)
+		report.Customers[i].Add(days, open)
+		report.Customers[i].InvoiceIDs = append(report.Customers[i].InvoiceIDs, inv.ID)
+		report.Totals.Add(days, open)
+	}
+	return report
+}
+
+// dueDate returns when an invoice is due; invoices without a due date are
+// due when issued
+func dueDate(inv *invoice.Invoice) time.Time {
+	if inv.DueDate != nil {
+		return *inv.DueDate
+	}
+	if inv.IssueDate != nil {
+		return *inv.IssueDate
+	}
+	return time.Time{}
+}
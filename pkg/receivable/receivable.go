@@ -0,0 +1,112 @@
+// Package receivable writes off and ages customer invoices. Invoices,
+// credit notes and the receipts that settle them are managed by the invoice
+// package; this package adds bad debt write-offs and aging reports, which
+// reconcile the open items against the receivable accounts in the ledger.
+package receivable
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/invoice"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Manager defines the interface for accounts receivable operations
+type Manager interface {
+	invoice.Manager
+
+	// WriteOff writes off the open balance of an invoice as a bad debt
+	WriteOff(ctx context.Context, id, expenseAccountID string, date time.Time) (*invoice.Invoice, error)
+
+	// Aging groups the open balances of invoices by days past due
+	Aging(ctx context.Context, asOf time.Time, currency string) (*AgingReport, error)
+}
+
+// BasicManager provides a storage-backed implementation of Manager. It
+// embeds the invoice manager, which issues invoices and applies receipts.
+type BasicManager struct {
+	*invoice.BasicManager
+	repo      storage.Repository
+	processor transaction.TransactionProcessor
+	// Optional calculator used to reconcile aging against the ledger
+	calculator reporting.ReportCalculator
+}
+
+// NewBasicManager creates a new BasicManager. A nil number generator numbers
+// invoices sequentially from sequences stored in repo.
+func NewBasicManager(repo storage.Repository, processor transaction.TransactionProcessor, numbers invoice.NumberGenerator) *BasicManager {
+	return &BasicManager{
+		BasicManager: invoice.NewBasicManager(repo, processor, numbers),
+		repo:         repo,
+		processor:    processor,
+	}
+}
+
+// SetCalculator sets the report calculator used to read receivable account
+// balances, so aging reports can be reconciled against the ledger
+func (m *BasicManager) SetCalculator(calculator reporting.ReportCalculator) {
+	m.calculator = calculator
+}
+
+// WriteOff implements Manager.WriteOff. The open balance is debited to the
+// expense account, typically bad debts, and credited to the receivable.
+func (m *BasicManager) WriteOff(ctx context.Context, id, expenseAccountID string, date time.Time) (*invoice.Invoice, error) {
+	if expenseAccountID == "" {
+		return nil, fmt.Errorf("write-off account is required")
+	}
+	inv, err := m.GetInvoice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !inv.IsOpen() {
+		return nil, fmt.Errorf("%w: invoice %s is not open", invoice.ErrInvalidStatus, id)
+	}
+
+	open := inv.OpenBalance()
+	now := time.Now()
+	tx := &transaction.Transaction{
+		ID:          fmt.Sprintf("TX-%s-WO", inv.ID),
+		Type:        transaction.Journal,
+		Status:      transaction.Draft,
+		Date:        date,
+		Description: fmt.Sprintf("Write-off of invoice %s", inv.Number),
+		Entries: []transaction.Entry{
+			{
+				AccountID:   expenseAccountID,
+				Amount:      open,
+				Type:        transaction.Debit,
+				Description: fmt.Sprintf("Bad debt from customer %s", inv.CustomerID),
+			},
+			{
+				AccountID:   inv.ReceivableAccountID,
+				Amount:      open,
+				Type:        transaction.Credit,
+				Description: fmt.Sprintf("Write-off of invoice %s", inv.Number),
+				PartyID:     inv.CustomerID,
+			},
+		},
+		Created:      now,
+		LastModified: now,
+	}
+	if err := transaction.CreateAndPost(ctx, m.repo, m.processor, tx); err != nil {
+		return nil, fmt.Errorf("failed to post write-off journal entry: %w", err)
+	}
+
+	inv.AmountWrittenOff = inv.AmountWrittenOff.Add(open.Amount)
+	inv.Settlements = append(inv.Settlements, invoice.Settlement{
+		Type:          invoice.SettledByWriteOff,
+		Date:          date,
+		Amount:        open.Amount,
+		TransactionID: tx.ID,
+	})
+	inv.Status = invoice.WrittenOff
+	inv.LastModified = now
+	if err := m.repo.Update(ctx, inv); err != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", err)
+	}
+	return inv, nil
+}
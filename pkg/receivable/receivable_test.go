@@ -0,0 +1,193 @@
+package receivable
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/invoice"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProcessor posts transactions with the library's processor and
+// records them
+type recordingProcessor struct {
+	*transaction.BasicTransactionProcessor
+	posted []*transaction.Transaction
+}
+
+func newRecordingProcessor(store *memory.MemoryStore) *recordingProcessor {
+	return &recordingProcessor{BasicTransactionProcessor: transaction.NewBasicTransactionProcessor(store)}
+}
+
+func (p *recordingProcessor) ProcessTransaction(ctx context.Context, tx *transaction.Transaction) error {
+	if err := p.BasicTransactionProcessor.ProcessTransaction(ctx, tx); err != nil {
+		return err
+	}
+	p.posted = append(p.posted, tx)
+	return nil
+}
+
+func (p *recordingProcessor) ProcessTransactionBatch(ctx context.Context, txs []*transaction.Transaction) error {
+	if err := p.BasicTransactionProcessor.ProcessTransactionBatch(ctx, txs); err != nil {
+		return err
+	}
+	p.posted = append(p.posted, txs...)
+	return nil
+}
+
+// ledgerCalculator reports the receivable balance from recorded transactions
+type ledgerCalculator struct {
+	reporting.ReportCalculator
+	processor *recordingProcessor
+}
+
+func (c *ledgerCalculator) CalculateBalance(ctx context.Context, accountID string, period reporting.ReportPeriod) (money.Money, error) {
+	balance := decimal.Zero
+	for _, tx := range c.processor.posted {
+		if tx.Date.After(period.End) {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				continue
+			}
+			if entry.Type == transaction.Debit {
+				balance = balance.Add(entry.Amount.Amount)
+			} else {
+				balance = balance.Sub(entry.Amount.Amount)
+			}
+		}
+	}
+	return money.Money{Amount: balance, Currency: "USD"}, nil
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func newTestInvoice(id, customerID string, amount int64, netDays int) *invoice.Invoice {
+	return &invoice.Invoice{
+		ID:                  id,
+		CustomerID:          customerID,
+		Currency:            "USD",
+		ReceivableAccountID: "1200",
+		Terms:               invoice.Terms{NetDays: netDays},
+		Lines: []invoice.Line{{
+			Description:      "Services",
+			Quantity:         decimal.NewFromInt(1),
+			UnitPrice:        usd(amount),
+			RevenueAccountID: "4000",
+			Tax:              &invoice.TaxCode{Code: "VAT10", Rate: decimal.RequireFromString("0.1"), AccountID: "2200"},
+		}},
+	}
+}
+
+func TestWriteOff(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := newRecordingProcessor(store)
+	manager := NewBasicManager(store, processor, nil)
+
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, manager.CreateInvoice(ctx, newTestInvoice("I1", "CUST1", 300, 30)))
+	_, err := manager.IssueInvoice(ctx, "I1", jan)
+	require.NoError(t, err)
+	_, err = manager.ApplyPayment(ctx, &invoice.Payment{ID: "P1", CustomerID: "CUST1", Date: jan.AddDate(0, 1, 0), Amount: usd(30), CashAccountID: "1000"}, nil)
+	require.NoError(t, err)
+
+	_, err = manager.WriteOff(ctx, "I1", "", jan.AddDate(0, 4, 0))
+	assert.Error(t, err)
+
+	writtenOff, err := manager.WriteOff(ctx, "I1", "6900", jan.AddDate(0, 4, 0))
+	require.NoError(t, err)
+	assert.Equal(t, invoice.WrittenOff, writtenOff.Status)
+	assert.Equal(t, "300", writtenOff.AmountWrittenOff.String())
+	assert.True(t, writtenOff.OpenBalance().IsZero())
+	require.Len(t, writtenOff.Settlements, 2)
+	assert.Equal(t, invoice.SettledByWriteOff, writtenOff.Settlements[1].Type)
+	assert.Equal(t, "300", writtenOff.OpenBalanceAt(jan.AddDate(0, 3, 0)).Amount.String())
+
+	tx, err := processor.GetTransaction(ctx, "TX-I1-WO")
+	require.NoError(t, err)
+	assert.Equal(t, transaction.Posted, tx.Status)
+	assert.Equal(t, "6900", tx.Entries[0].AccountID)
+	assert.Equal(t, transaction.Debit, tx.Entries[0].Type)
+	assert.Equal(t, "1200", tx.Entries[1].AccountID)
+	assert.Equal(t, "CUST1", tx.Entries[1].PartyID)
+
+	open, err := manager.ListOpenInvoices(ctx, "CUST1")
+	require.NoError(t, err)
+	assert.Empty(t, open)
+	_, err = manager.WriteOff(ctx, "I1", "6900", jan.AddDate(0, 4, 0))
+	assert.ErrorIs(t, err, invoice.ErrInvalidStatus)
+}
+
+func TestAging(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := newRecordingProcessor(store)
+	manager := NewBasicManager(store, processor, nil)
+	manager.SetCalculator(&ledgerCalculator{processor: processor})
+
+	asOf := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	invoices := []struct {
+		invoice *invoice.Invoice
+		issued  time.Time
+	}{
+		{newTestInvoice("I1", "CUST1", 100, 30), asOf.AddDate(0, 0, -10)},
+		{newTestInvoice("I2", "CUST1", 200, 30), asOf.AddDate(0, 0, -50)},
+		{newTestInvoice("I3", "CUST2", 300, 35), asOf.AddDate(0, 0, -80)},
+		{newTestInvoice("I4", "CUST2", 400, 45), asOf.AddDate(0, 0, -120)},
+		{newTestInvoice("I5", "CUST3", 500, 50), asOf.AddDate(0, 0, -200)},
+		{newTestInvoice("I6", "CUST3", 600, 30), asOf.AddDate(0, 0, 5)},
+	}
+	for _, item := range invoices {
+		require.NoError(t, manager.CreateInvoice(ctx, item.invoice))
+		_, err := manager.IssueInvoice(ctx, item.invoice.ID, item.issued)
+		require.NoError(t, err)
+	}
+
+	// Settled before the report date, and after it
+	_, err := manager.ApplyPayment(ctx, &invoice.Payment{
+		CustomerID:    "CUST2",
+		Date:          asOf.AddDate(0, 0, -1),
+		Amount:        usd(100),
+		CashAccountID: "1000",
+	}, []invoice.Application{{InvoiceID: "I4", Amount: usd(100)}})
+	require.NoError(t, err)
+	_, err = manager.WriteOff(ctx, "I5", "6900", asOf.AddDate(0, 0, 1))
+	require.NoError(t, err)
+
+	report, err := manager.Aging(ctx, asOf, "USD")
+	require.NoError(t, err)
+	require.Len(t, report.Customers, 3)
+
+	assert.Equal(t, "CUST3", report.Customers[0].CustomerID)
+	assert.Equal(t, "550", report.Customers[0].Over90.String())
+	assert.Equal(t, []string{"I5"}, report.Customers[0].InvoiceIDs)
+
+	cust2 := report.Customers[1]
+	assert.Equal(t, "CUST2", cust2.CustomerID)
+	assert.Equal(t, "330", cust2.Days60.String())
+	assert.Equal(t, "340", cust2.Days90.String())
+
+	cust1 := report.Customers[2]
+	assert.Equal(t, "110", cust1.Current.String())
+	assert.Equal(t, "220", cust1.Days30.String())
+	assert.Equal(t, "330", cust1.Total().String())
+
+	assert.Equal(t, "1550", report.Totals.Total().String())
+	require.NotNil(t, report.LedgerBalance)
+	assert.Equal(t, "1550", report.LedgerBalance.Amount.String())
+	assert.True(t, report.Difference.IsZero())
+
+	_, err = manager.Aging(ctx, asOf, "")
+	assert.Error(t, err)
+}
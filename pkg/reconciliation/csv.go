@@ -0,0 +1,68 @@
+package reconciliation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// ParseCSV reads statement lines in a currency from CSV with a header row.
+// The date (YYYY-MM-DD), amount and description columns are required and
+// reference is optional; amounts are signed, deposits positive.
+func ParseCSV(r io.Reader, currency string) ([]StatementLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalidStatement, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, required := range []string{"date", "amount", "description"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidStatement, required)
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var lines []StatementLine
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidStatement, line, err)
+		}
+		date, err := time.Parse("2006-01-02", field(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid date: %v", ErrInvalidStatement, line, err)
+		}
+		amount, err := decimal.NewFromString(field(record, "amount"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid amount: %v", ErrInvalidStatement, line, err)
+		}
+		lines = append(lines, StatementLine{
+			Date:        date,
+			Amount:      money.Money{Amount: amount, Currency: currency},
+			Description: field(record, "description"),
+			Reference:   field(record, "reference"),
+		})
+	}
+	return lines, nil
+}
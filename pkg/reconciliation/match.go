@@ -0,0 +1,69 @@
+package reconciliation
+
+import (
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/similarity"
+)
+
+// best returns the index of the entry a line matches best under a method,
+// and the description similarity of the pair
+func (r *Reconciler) best(method MatchMethod, line *StatementLine, entries []LedgerEntry) (int, float64, bool) {
+	best, bestDays, bestScore := -1, 0, 0.0
+	for i, entry := range entries {
+		if !entry.Amount.Amount.Equal(line.Amount.Amount) {
+			continue
+		}
+		days := daysApart(line.Date, entry.Date)
+		score := similarity.Score(line.Description, entry.Description)
+
+		switch method {
+		case MatchExact:
+			if days != 0 {
+				continue
+			}
+			if line.Reference != "" && entry.Reference != "" {
+				if !strings.EqualFold(line.Reference, entry.Reference) {
+					continue
+				}
+				// An equal reference beats any description
+				score = 2
+			}
+		case MatchAmountDate:
+			if days > r.config.DateWindow {
+				continue
+			}
+		case MatchFuzzy:
+			if days > r.config.FuzzyWindow || score < r.config.FuzzyThreshold {
+				continue
+			}
+		}
+
+		closer := days < bestDays || (days == bestDays && score > bestScore)
+		if method == MatchFuzzy {
+			closer = score > bestScore || (score == bestScore && days < bestDays)
+		}
+		if best < 0 || closer {
+			best, bestDays, bestScore = i, days, score
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	if bestScore > 1 {
+		bestScore = 1
+	}
+	return best, bestScore, true
+}
+
+// daysApart returns the number of calendar days between two dates
+func daysApart(a, b time.Time) int {
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(dayA.Sub(dayB).Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
@@ -0,0 +1,427 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/similarity"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidStatement  = errors.New("invalid statement")
+	ErrStatementNotFound = errors.New("statement not found")
+	ErrLineNotFound      = errors.New("statement line not found")
+	ErrEntryNotFound     = errors.New("ledger entry not found")
+	ErrAlreadyMatched    = errors.New("already matched")
+)
+
+// Reconciler imports bank statements and reconciles them with the ledger
+// entries of the bank account they belong to
+type Reconciler struct {
+	repo   storage.Repository
+	config Config
+	now    func() time.Time
+}
+
+// NewReconciler creates a reconciler over a repository holding both the
+// statements and the ledger transactions
+func NewReconciler(repo storage.Repository, config Config) *Reconciler {
+	return &Reconciler{
+		repo:   repo,
+		config: config.withDefaults(),
+		now:    time.Now,
+	}
+}
+
+// SetClock sets the clock used to time imports and matches
+func (r *Reconciler) SetClock(now func() time.Time) {
+	r.now = now
+}
+
+// Import stores a statement and its lines, all unmatched. The statement ID
+// defaults to a generated one and the period to the dates of its lines.
+func (r *Reconciler) Import(ctx context.Context, stmt *Statement, lines []StatementLine) error {
+	if stmt == nil || stmt.AccountID == "" {
+		return fmt.Errorf("%w: account is required", ErrInvalidStatement)
+	}
+	currency := stmt.ClosingBalance.Currency
+	if currency == "" {
+		return fmt.Errorf("%w: closing balance currency is required", ErrInvalidStatement)
+	}
+
+	now := r.now()
+	if stmt.ID == "" {
+		stmt.ID = fmt.Sprintf("STMT_%d", now.UnixNano())
+	}
+	stmt.Imported = now
+	stmt.LineIDs = make([]string, 0, len(lines))
+	for i := range lines {
+		line := &lines[i]
+		if line.Amount.Currency != currency {
+			return fmt.Errorf("%w: line %d is in %q, not %s", ErrInvalidStatement, i+1, line.Amount.Currency, currency)
+		}
+		if line.Date.IsZero() {
+			return fmt.Errorf("%w: line %d has no date", ErrInvalidStatement, i+1)
+		}
+		line.ID = fmt.Sprintf("%s-%04d", stmt.ID, i+1)
+		line.StatementID = stmt.ID
+		line.AccountID = stmt.AccountID
+		line.Status = Unmatched
+		line.Match, line.Method, line.Score, line.MatchedAt = nil, "", 0, nil
+		stmt.LineIDs = append(stmt.LineIDs, line.ID)
+
+		if stmt.Start.IsZero() || line.Date.Before(stmt.Start) {
+			stmt.Start = line.Date
+		}
+		if line.Date.After(stmt.End) {
+			stmt.End = line.Date
+		}
+	}
+	if stmt.OpeningBalance.Currency == "" {
+		stmt.OpeningBalance = money.Money{Amount: decimal.Zero, Currency: currency}
+	}
+
+	for i := range lines {
+		if err := r.repo.Create(ctx, &lines[i]); err != nil {
+			return fmt.Errorf("failed to store statement line: %w", err)
+		}
+	}
+	if err := r.repo.Create(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to store statement: %w", err)
+	}
+	return nil
+}
+
+// GetStatement retrieves a statement by ID
+func (r *Reconciler) GetStatement(ctx context.Context, id string) (*Statement, error) {
+	var stmt Statement
+	if err := r.repo.Read(ctx, id, &stmt); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrStatementNotFound, id, err)
+	}
+	return &stmt, nil
+}
+
+// GetLine retrieves a statement line by ID
+func (r *Reconciler) GetLine(ctx context.Context, id string) (*StatementLine, error) {
+	var line StatementLine
+	if err := r.repo.Read(ctx, id, &line); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrLineNotFound, id, err)
+	}
+	return &line, nil
+}
+
+// Lines retrieves the lines of a statement in statement order
+func (r *Reconciler) Lines(ctx context.Context, statementID string) ([]*StatementLine, error) {
+	stmt, err := r.GetStatement(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]*StatementLine, 0, len(stmt.LineIDs))
+	for _, id := range stmt.LineIDs {
+		line, err := r.GetLine(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Match matches the unmatched lines of a statement against the ledger
+// entries of its account not matched to any line yet. Each line is tried
+// in turn for an exact match, then for the same amount within the date
+// window, then for the same amount with a similar description within the
+// fuzzy window; the closest candidate wins.
+func (r *Reconciler) Match(ctx context.Context, statementID string) (*MatchResult, error) {
+	stmt, err := r.GetStatement(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := r.Lines(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := time.Duration(r.config.FuzzyWindow) * 24 * time.Hour
+	entries, err := r.openEntries(ctx, stmt.AccountID, stmt.ClosingBalance.Currency, stmt.End.Add(window))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MatchResult{Matched: make(map[MatchMethod]int)}
+	passes := []MatchMethod{MatchExact, MatchAmountDate, MatchFuzzy}
+	for _, method := range passes {
+		for _, line := range lines {
+			if line.Status == Matched {
+				continue
+			}
+			i, score, ok := r.best(method, line, entries)
+			if !ok {
+				continue
+			}
+			if err := r.match(ctx, line, entries[i], method, score); err != nil {
+				return nil, err
+			}
+			entries = append(entries[:i], entries[i+1:]...)
+			result.Matched[method]++
+		}
+	}
+	for _, line := range lines {
+		if line.Status != Matched {
+			result.Unmatched++
+		}
+	}
+	return result, nil
+}
+
+// MatchManually matches a statement line to an entry of a posted
+// transaction, whatever their amounts and dates
+func (r *Reconciler) MatchManually(ctx context.Context, lineID, txID string, entryIndex int) error {
+	line, err := r.GetLine(ctx, lineID)
+	if err != nil {
+		return err
+	}
+	if line.Status == Matched {
+		return fmt.Errorf("%w: line %s", ErrAlreadyMatched, lineID)
+	}
+
+	var tx transaction.Transaction
+	if err := r.repo.Read(ctx, txID, &tx); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrEntryNotFound, txID, err)
+	}
+	if tx.Status != transaction.Posted || entryIndex < 0 || entryIndex >= len(tx.Entries) ||
+		tx.Entries[entryIndex].AccountID != line.AccountID {
+		return fmt.Errorf("%w: %s entry %d is not a posted entry on %s", ErrEntryNotFound, txID, entryIndex, line.AccountID)
+	}
+	entry := ledgerEntry(&tx, entryIndex)
+
+	matched, err := r.matchedKeys(ctx, line.AccountID)
+	if err != nil {
+		return err
+	}
+	if matched[entry.key()] {
+		return fmt.Errorf("%w: %s entry %d", ErrAlreadyMatched, txID, entryIndex)
+	}
+	return r.match(ctx, line, entry, MatchManual, similarity.Score(line.Description, entry.Description))
+}
+
+// Unmatch returns a statement line to unmatched, freeing its ledger entry
+func (r *Reconciler) Unmatch(ctx context.Context, lineID string) error {
+	line, err := r.GetLine(ctx, lineID)
+	if err != nil {
+		return err
+	}
+	line.Status = Unmatched
+	line.Match, line.Method, line.Score, line.MatchedAt = nil, "", 0, nil
+	if err := r.repo.Update(ctx, line); err != nil {
+		return fmt.Errorf("failed to update statement line: %w", err)
+	}
+	return nil
+}
+
+// LockMatched locks the transactions matched to a statement's lines, so a
+// completed reconciliation cannot be undone by voiding or reversing them.
+// It returns the IDs of the transactions locked.
+func (r *Reconciler) LockMatched(ctx context.Context, statementID string, locker transaction.Locker) ([]string, error) {
+	lines, err := r.Lines(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := make([]string, 0)
+	seen := make(map[string]bool)
+	reason := fmt.Sprintf("reconciled on statement %s", statementID)
+	for _, line := range lines {
+		if line.Status != Matched || line.Match == nil || seen[line.Match.TransactionID] {
+			continue
+		}
+		seen[line.Match.TransactionID] = true
+		if err := locker.LockTransaction(ctx, line.Match.TransactionID, reason); err != nil {
+			return locked, fmt.Errorf("failed to lock transaction %s: %w", line.Match.TransactionID, err)
+		}
+		locked = append(locked, line.Match.TransactionID)
+	}
+	return locked, nil
+}
+
+// Report reconciles a statement's closing balance with the ledger balance
+// of its account at the statement end. Ledger entries not yet on any
+// statement adjust the statement balance; statement lines not yet in the
+// ledger adjust the ledger balance.
+func (r *Reconciler) Report(ctx context.Context, statementID string) (*Report, error) {
+	stmt, err := r.GetStatement(ctx, statementID)
+	if err != nil {
+		return nil, err
+	}
+	currency := stmt.ClosingBalance.Currency
+	zero := money.Money{Amount: decimal.Zero, Currency: currency}
+
+	entries, err := r.entries(ctx, stmt.AccountID, currency, stmt.End)
+	if err != nil {
+		return nil, err
+	}
+	var lines []*StatementLine
+	query := storage.Query{Filters: []storage.Filter{{Field: "account_id", Operator: "=", Value: stmt.AccountID}}}
+	if err := r.repo.Query(ctx, query, &lines); err != nil {
+		return nil, fmt.Errorf("error querying statement lines: %w", err)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+
+	report := &Report{
+		StatementID:        stmt.ID,
+		AccountID:          stmt.AccountID,
+		AsOf:               stmt.End,
+		StatementBalance:   stmt.ClosingBalance,
+		LedgerBalance:      zero,
+		Matched:            make([]StatementLine, 0),
+		UnmatchedLines:     make([]StatementLine, 0),
+		OutstandingEntries: make([]LedgerEntry, 0),
+	}
+
+	matched := make(map[string]bool)
+	unmatched := zero
+	for _, line := range lines {
+		switch {
+		case line.Status == Matched && line.Match != nil:
+			matched[line.Match.key()] = true
+			if line.StatementID == stmt.ID {
+				report.Matched = append(report.Matched, *line)
+			}
+		case !line.Date.After(stmt.End) && line.Amount.Currency == currency:
+			report.UnmatchedLines = append(report.UnmatchedLines, *line)
+			unmatched.Amount = unmatched.Amount.Add(line.Amount.Amount)
+		}
+	}
+
+	outstanding := zero
+	for _, entry := range entries {
+		report.LedgerBalance.Amount = report.LedgerBalance.Amount.Add(entry.Amount.Amount)
+		if !matched[entry.key()] {
+			report.OutstandingEntries = append(report.OutstandingEntries, entry)
+			outstanding.Amount = outstanding.Amount.Add(entry.Amount.Amount)
+		}
+	}
+
+	report.AdjustedStatementBalance = money.Money{Amount: stmt.ClosingBalance.Amount.Add(outstanding.Amount), Currency: currency}
+	report.AdjustedLedgerBalance = money.Money{Amount: report.LedgerBalance.Amount.Add(unmatched.Amount), Currency: currency}
+	report.Difference = money.Money{
+		Amount:   report.AdjustedStatementBalance.Amount.Sub(report.AdjustedLedgerBalance.Amount),
+		Currency: currency,
+	}
+	report.Reconciled = report.Difference.IsZero()
+	return report, nil
+}
+
+// match records a line as matched to a ledger entry
+func (r *Reconciler) match(ctx context.Context, line *StatementLine, entry LedgerEntry, method MatchMethod, score float64) error {
+	now := r.now()
+	line.Status = Matched
+	line.Match = &entry
+	line.Method = method
+	line.Score = score
+	line.MatchedAt = &now
+	if err := r.repo.Update(ctx, line); err != nil {
+		return fmt.Errorf("failed to update statement line: %w", err)
+	}
+	return nil
+}
+
+// entries returns the posted entries on an account in a currency dated up
+// to a time, ordered by date
+func (r *Reconciler) entries(ctx context.Context, accountID, currency string, until time.Time) ([]LedgerEntry, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "status", Operator: "=", Value: transaction.Posted},
+			{Field: "entries.account_id", Operator: "=", Value: accountID},
+			{Field: "date", Operator: "<=", Value: until},
+		},
+		Sort: []storage.Sort{{Field: "date", Desc: false}},
+	}
+	var txs []*transaction.Transaction
+	if err := r.repo.Query(ctx, query, &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	var entries []LedgerEntry
+	for _, tx := range txs {
+		if tx.Status != transaction.Posted || tx.Date.After(until) {
+			continue
+		}
+		for i, entry := range tx.Entries {
+			if entry.AccountID == accountID && entry.Amount.Currency == currency {
+				entries = append(entries, ledgerEntry(tx, i))
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries, nil
+}
+
+// openEntries returns the entries not matched to any statement line yet
+func (r *Reconciler) openEntries(ctx context.Context, accountID, currency string, until time.Time) ([]LedgerEntry, error) {
+	entries, err := r.entries(ctx, accountID, currency, until)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := r.matchedKeys(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	open := entries[:0]
+	for _, entry := range entries {
+		if !matched[entry.key()] {
+			open = append(open, entry)
+		}
+	}
+	return open, nil
+}
+
+// matchedKeys returns the keys of the account's entries matched to lines
+func (r *Reconciler) matchedKeys(ctx context.Context, accountID string) (map[string]bool, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "account_id", Operator: "=", Value: accountID},
+			{Field: "status", Operator: "=", Value: Matched},
+		},
+	}
+	var lines []*StatementLine
+	if err := r.repo.Query(ctx, query, &lines); err != nil {
+		return nil, fmt.Errorf("error querying statement lines: %w", err)
+	}
+	keys := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.Status == Matched && line.Match != nil {
+			keys[line.Match.key()] = true
+		}
+	}
+	return keys, nil
+}
+
+// ledgerEntry describes an entry of a transaction, signed from the
+// account's point of view
+func ledgerEntry(tx *transaction.Transaction, i int) LedgerEntry {
+	entry := tx.Entries[i]
+	amount := entry.Amount
+	if entry.Type == transaction.Credit {
+		amount = amount.Multiply(decimal.NewFromInt(-1))
+	}
+	description := entry.Description
+	if description == "" {
+		description = tx.Description
+	}
+	return LedgerEntry{
+		TransactionID: tx.ID,
+		EntryIndex:    i,
+		Date:          tx.Date,
+		Amount:        amount,
+		Description:   description,
+		Reference:     tx.Reference,
+	}
+}
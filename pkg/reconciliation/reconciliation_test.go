@@ -0,0 +1,135 @@
+package reconciliation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func march(day int) time.Time {
+	return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC)
+}
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+// bankTransaction posts a movement on the bank account 1010 against
+// another account; positive amounts are deposits
+func bankTransaction(id string, day int, amount int64, description, reference string) *transaction.Transaction {
+	bank, other := transaction.Debit, transaction.Credit
+	if amount < 0 {
+		bank, other, amount = other, bank, -amount
+	}
+	return &transaction.Transaction{
+		ID:          id,
+		Status:      transaction.Posted,
+		Date:        march(day),
+		Description: description,
+		Reference:   reference,
+		Entries: []transaction.Entry{
+			{AccountID: "1010", Amount: usd(amount), Type: bank},
+			{AccountID: "6000", Amount: usd(amount), Type: other},
+		},
+	}
+}
+
+const statementCSV = `date,amount,description,reference
+2024-03-01,500,ACME CORP PAYMENT,
+2024-03-05,-120,CHECK,CHK-1002
+2024-03-10,-75,ELECTRICITY CO,
+2024-03-12,-120,OFFICE SUPPLIES LTD,
+2024-03-31,-15,MONTHLY FEE,
+`
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	for _, tx := range []*transaction.Transaction{
+		bankTransaction("TX-1", 1, 500, "Customer payment ACME", ""),
+		bankTransaction("TX-2", 5, -120, "Office supplies", "CHK-1001"),
+		bankTransaction("TX-3", 8, -75, "Electricity bill", ""),
+		bankTransaction("TX-4", 28, 300, "Deposit", ""),
+		bankTransaction("TX-5", 5, -120, "Consulting", "CHK-1002"),
+	} {
+		require.NoError(t, store.Create(ctx, tx))
+	}
+
+	lines, err := ParseCSV(strings.NewReader(statementCSV), "USD")
+	require.NoError(t, err)
+	require.Len(t, lines, 5)
+
+	reconciler := NewReconciler(store, Config{})
+	stmt := &Statement{ID: "STMT-2024-03", AccountID: "1010", ClosingBalance: usd(170)}
+	require.NoError(t, reconciler.Import(ctx, stmt, lines))
+	assert.Equal(t, march(1), stmt.Start)
+	assert.Equal(t, march(31), stmt.End)
+
+	result, err := reconciler.Match(ctx, stmt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[MatchMethod]int{MatchExact: 2, MatchAmountDate: 1, MatchFuzzy: 1}, result.Matched)
+	assert.Equal(t, 1, result.Unmatched)
+
+	stored, err := reconciler.Lines(ctx, stmt.ID)
+	require.NoError(t, err)
+	matches := make(map[string]string)
+	for _, line := range stored {
+		if line.Match != nil {
+			matches[line.Description] = line.Match.TransactionID + " " + string(line.Method)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"ACME CORP PAYMENT":   "TX-1 EXACT",
+		"CHECK":               "TX-5 EXACT",
+		"ELECTRICITY CO":      "TX-3 AMOUNT_DATE",
+		"OFFICE SUPPLIES LTD": "TX-2 FUZZY",
+	}, matches)
+
+	report, err := reconciler.Report(ctx, stmt.ID)
+	require.NoError(t, err)
+	assert.Len(t, report.Matched, 4)
+	require.Len(t, report.UnmatchedLines, 1)
+	assert.Equal(t, "MONTHLY FEE", report.UnmatchedLines[0].Description)
+	require.Len(t, report.OutstandingEntries, 1)
+	assert.Equal(t, "TX-4", report.OutstandingEntries[0].TransactionID)
+	assert.True(t, report.LedgerBalance.Amount.Equal(decimal.NewFromInt(485)))
+	assert.True(t, report.AdjustedStatementBalance.Amount.Equal(decimal.NewFromInt(470)))
+	assert.True(t, report.AdjustedLedgerBalance.Amount.Equal(decimal.NewFromInt(470)))
+	assert.True(t, report.Reconciled)
+
+	t.Run("manual matching", func(t *testing.T) {
+		electricity := stored[2]
+		require.NoError(t, reconciler.Unmatch(ctx, electricity.ID))
+
+		err := reconciler.MatchManually(ctx, electricity.ID, "TX-1", 0)
+		assert.ErrorIs(t, err, ErrAlreadyMatched)
+		err = reconciler.MatchManually(ctx, electricity.ID, "TX-3", 1)
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+
+		require.NoError(t, reconciler.MatchManually(ctx, electricity.ID, "TX-3", 0))
+		line, err := reconciler.GetLine(ctx, electricity.ID)
+		require.NoError(t, err)
+		assert.Equal(t, Matched, line.Status)
+		assert.Equal(t, MatchManual, line.Method)
+
+		err = reconciler.MatchManually(ctx, electricity.ID, "TX-3", 0)
+		assert.ErrorIs(t, err, ErrAlreadyMatched)
+	})
+
+	t.Run("invalid statements", func(t *testing.T) {
+		err := reconciler.Import(ctx, &Statement{AccountID: "1010", ClosingBalance: usd(0)},
+			[]StatementLine{{Date: march(1), Amount: money.Money{Amount: decimal.NewFromInt(1), Currency: "EUR"}}})
+		assert.ErrorIs(t, err, ErrInvalidStatement)
+
+		_, err = ParseCSV(strings.NewReader("date,amount\n2024-03-01,5\n"), "USD")
+		assert.ErrorIs(t, err, ErrInvalidStatement)
+	})
+}
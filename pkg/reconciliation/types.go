@@ -0,0 +1,161 @@
+// Package reconciliation matches bank statement lines against the ledger
+// entries of a bank account and reports the items left outstanding.
+//
+// Amounts are signed from the bank account's point of view: deposits and
+// debits to the ledger account are positive, withdrawals and credits
+// negative.
+package reconciliation
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// LineStatus represents whether a statement line has been matched
+type LineStatus string
+
+const (
+	Unmatched LineStatus = "UNMATCHED"
+	Matched   LineStatus = "MATCHED"
+)
+
+// MatchMethod records how a statement line was matched
+type MatchMethod string
+
+const (
+	// Same amount and date, with equal references where both have one
+	MatchExact MatchMethod = "EXACT"
+	// Same amount within the date window
+	MatchAmountDate MatchMethod = "AMOUNT_DATE"
+	// Same amount within the fuzzy date window and similar descriptions
+	MatchFuzzy MatchMethod = "FUZZY"
+	// Matched by a user
+	MatchManual MatchMethod = "MANUAL"
+)
+
+// Statement is a bank statement imported for a ledger bank account
+type Statement struct {
+	ID string `json:"id"`
+	// Ledger account the statement is reconciled against
+	AccountID string `json:"account_id"`
+	// Period covered by the statement
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Balances reported by the bank
+	OpeningBalance money.Money `json:"opening_balance"`
+	ClosingBalance money.Money `json:"closing_balance"`
+	// Lines in statement order
+	LineIDs  []string  `json:"line_ids"`
+	Imported time.Time `json:"imported"`
+}
+
+// GetID returns the statement identifier
+func (s *Statement) GetID() string { return s.ID }
+
+// StatementLine is a single movement reported by the bank
+type StatementLine struct {
+	ID          string      `json:"id"`
+	StatementID string      `json:"statement_id"`
+	AccountID   string      `json:"account_id"`
+	Date        time.Time   `json:"date"`
+	Amount      money.Money `json:"amount"`
+	Description string      `json:"description"`
+	// Bank or payment reference, such as a check number
+	Reference string     `json:"reference,omitempty"`
+	Status    LineStatus `json:"status"`
+	// Ledger entry the line is matched to
+	Match *LedgerEntry `json:"match,omitempty"`
+	// How the line was matched and, for fuzzy matches, the description
+	// similarity between 0 and 1
+	Method    MatchMethod `json:"method,omitempty"`
+	Score     float64     `json:"score,omitempty"`
+	MatchedAt *time.Time  `json:"matched_at,omitempty"`
+}
+
+// GetID returns the statement line identifier
+func (l *StatementLine) GetID() string { return l.ID }
+
+// LedgerEntry is an entry posted to the reconciled bank account
+type LedgerEntry struct {
+	TransactionID string `json:"transaction_id"`
+	// Position of the entry in the transaction
+	EntryIndex  int         `json:"entry_index"`
+	Date        time.Time   `json:"date"`
+	Amount      money.Money `json:"amount"`
+	Description string      `json:"description"`
+	Reference   string      `json:"reference,omitempty"`
+}
+
+// key identifies the entry among the account's entries
+func (e LedgerEntry) key() string {
+	return e.TransactionID + "#" + strconv.Itoa(e.EntryIndex)
+}
+
+// Config controls how statement lines are matched
+type Config struct {
+	// Days either side of a line's date searched for amount matches
+	DateWindow int
+	// Days either side searched for fuzzy matches
+	FuzzyWindow int
+	// Minimum description similarity, between 0 and 1, of fuzzy matches
+	FuzzyThreshold float64
+}
+
+const (
+	DefaultDateWindow     = 3
+	DefaultFuzzyWindow    = 10
+	DefaultFuzzyThreshold = 0.5
+)
+
+// withDefaults fills unset fields with their defaults
+func (c Config) withDefaults() Config {
+	if c.DateWindow <= 0 {
+		c.DateWindow = DefaultDateWindow
+	}
+	if c.FuzzyWindow <= 0 {
+		c.FuzzyWindow = DefaultFuzzyWindow
+	}
+	if c.FuzzyWindow < c.DateWindow {
+		c.FuzzyWindow = c.DateWindow
+	}
+	if c.FuzzyThreshold <= 0 {
+		c.FuzzyThreshold = DefaultFuzzyThreshold
+	}
+	return c
+}
+
+// MatchResult summarizes a matching run
+type MatchResult struct {
+	// Lines matched by each method
+	Matched map[MatchMethod]int `json:"matched"`
+	// Lines still unmatched after the run
+	Unmatched int `json:"unmatched"`
+}
+
+// Report describes the reconciliation of a statement with the ledger
+type Report struct {
+	StatementID string    `json:"statement_id"`
+	AccountID   string    `json:"account_id"`
+	AsOf        time.Time `json:"as_of"`
+	// Closing balance reported by the bank
+	StatementBalance money.Money `json:"statement_balance"`
+	// Balance of the ledger account at the statement end
+	LedgerBalance money.Money `json:"ledger_balance"`
+	// Matched lines
+	Matched []StatementLine `json:"matched"`
+	// Lines up to the statement end not yet in the ledger, such as bank fees
+	UnmatchedLines []StatementLine `json:"unmatched_lines"`
+	// Ledger entries up to the statement end not yet on a statement, such
+	// as deposits in transit and outstanding checks
+	OutstandingEntries []LedgerEntry `json:"outstanding_entries"`
+	// Statement balance plus the outstanding entries
+	AdjustedStatementBalance money.Money `json:"adjusted_statement_balance"`
+	// Ledger balance plus the unmatched lines
+	AdjustedLedgerBalance money.Money `json:"adjusted_ledger_balance"`
+	// Adjusted statement balance less adjusted ledger balance
+	Difference money.Money `json:"difference"`
+	// Whether the adjusted balances agree
+	Reconciled bool `json:"reconciled"`
+}
@@ -0,0 +1,39 @@
+package reporting
+
+import "github.com/shopspring/decimal"
+
+// AgingBuckets holds open balances grouped by how far past due they are,
+// as used by receivable and payable aging reports
+type AgingBuckets struct {
+	// Not yet due
+	Current decimal.Decimal `json:"current"`
+	// 1 to 30 days past due
+	Days30 decimal.Decimal `json:"days_30"`
+	// 31 to 60 days past due
+	Days60 decimal.Decimal `json:"days_60"`
+	// 61 to 90 days past due
+	Days90 decimal.Decimal `json:"days_90"`
+	// More than 90 days past due
+	Over90 decimal.Decimal `json:"over_90"`
+}
+
+// Add places an amount in the bucket for the days it is past due
+func (b *AgingBuckets) Add(daysPastDue int, amount decimal.Decimal) {
+	switch {
+	case daysPastDue <= 0:
+		b.Current = b.Current.Add(amount)
+	case daysPastDue <= 30:
+		b.Days30 = b.Days30.Add(amount)
+	case daysPastDue <= 60:
+		b.Days60 = b.Days60.Add(amount)
+	case daysPastDue <= 90:
+		b.Days90 = b.Days90.Add(amount)
+	default:
+		b.Over90 = b.Over90.Add(amount)
+	}
+}
+
+// Total returns the sum of all buckets
+func (b AgingBuckets) Total() decimal.Decimal {
+	return b.Current.Add(b.Days30).Add(b.Days60).Add(b.Days90).Add(b.Over90)
+}
@@ -0,0 +1,52 @@
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// DateBasis selects which transaction date places it in a reporting period
+type DateBasis string
+
+const (
+	// PostingDateBasis reports transactions by the date they were entered
+	PostingDateBasis DateBasis = "POSTING"
+	// EffectiveDateBasis reports transactions by the date they take
+	// economic effect, so back-dated adjustments land in the period they
+	// relate to
+	EffectiveDateBasis DateBasis = "EFFECTIVE"
+)
+
+type basisKey struct{}
+
+// WithDateBasis returns a context whose balance calculations use a date basis
+func WithDateBasis(ctx context.Context, basis DateBasis) context.Context {
+	return context.WithValue(ctx, basisKey{}, basis)
+}
+
+// DateBasisFromContext returns the date basis of a context, defaulting to
+// the posting date
+func DateBasisFromContext(ctx context.Context) DateBasis {
+	if basis, ok := ctx.Value(basisKey{}).(DateBasis); ok && basis != "" {
+		return basis
+	}
+	return PostingDateBasis
+}
+
+// dateField returns the transaction field queried for a basis
+func (b DateBasis) dateField() string {
+	if b == EffectiveDateBasis {
+		return "effective_date"
+	}
+	return "date"
+}
+
+// dateOf returns the date of a transaction on a basis
+func (b DateBasis) dateOf(tx *transaction.Transaction) time.Time {
+	if b == EffectiveDateBasis {
+		return tx.EffectiveAt()
+	}
+	return tx.Date
+}
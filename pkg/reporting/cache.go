@@ -0,0 +1,185 @@
+package reporting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/journal"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/shopspring/decimal"
+)
+
+// cacheKey identifies a cached calculation. The scope covers the context
+// entity, journal and date basis, which all change the result.
+type cacheKey struct {
+	accountID string
+	start     string
+	end       string
+	scope     string
+	version   uint64
+}
+
+// CachingCalculator is a ReportCalculator decorator that remembers account
+// balances and changes by account, period, scope and ledger version. The
+// ledger version of an account moves on every event that may change its
+// balance. Events arrive after the posting commits, so a result cached
+// before a posting may still be served until its event is handled; with an
+// asynchronous bus or an outbox that window lasts until delivery. Callers
+// needing read-your-writes call Invalidate or InvalidateAll after posting.
+// Register the calculator on the event bus with Subscribe. Ratios are not
+// cached.
+type CachingCalculator struct {
+	next ReportCalculator
+
+	mu       sync.Mutex
+	global   uint64
+	versions map[string]uint64
+	balances map[cacheKey]money.Money
+	changes  map[cacheKey]*BalanceChange
+}
+
+// NewCachingCalculator wraps a calculator with a cache
+func NewCachingCalculator(next ReportCalculator) *CachingCalculator {
+	return &CachingCalculator{
+		next:     next,
+		versions: make(map[string]uint64),
+		balances: make(map[cacheKey]money.Money),
+		changes:  make(map[cacheKey]*BalanceChange),
+	}
+}
+
+// CalculateBalance implements ReportCalculator.CalculateBalance
+func (c *CachingCalculator) CalculateBalance(ctx context.Context, accountID string, period ReportPeriod) (money.Money, error) {
+	key := c.key(ctx, accountID, period)
+	c.mu.Lock()
+	balance, ok := c.balances[key]
+	c.mu.Unlock()
+	if ok {
+		return balance, nil
+	}
+
+	balance, err := c.next.CalculateBalance(ctx, accountID, period)
+	if err != nil {
+		return balance, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A posting during the calculation moved the version on; the result
+	// may be stale, so it is returned but not kept
+	if key.version == c.versionLocked(accountID) {
+		c.balances[key] = balance
+	}
+	return balance, nil
+}
+
+// CalculateChanges implements ReportCalculator.CalculateChanges. Callers
+// receive their own copy of the change; its movements are shared.
+func (c *CachingCalculator) CalculateChanges(ctx context.Context, accountID string, period ReportPeriod) (*BalanceChange, error) {
+	key := c.key(ctx, accountID, period)
+	c.mu.Lock()
+	cached, ok := c.changes[key]
+	c.mu.Unlock()
+	if ok {
+		result := *cached
+		return &result, nil
+	}
+
+	change, err := c.next.CalculateChanges(ctx, accountID, period)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key.version == c.versionLocked(accountID) {
+		stored := *change
+		c.changes[key] = &stored
+	}
+	return change, nil
+}
+
+// CalculateRatio implements ReportCalculator.CalculateRatio without caching
+func (c *CachingCalculator) CalculateRatio(ctx context.Context, ratio RatioDefinition, period ReportPeriod) (decimal.Decimal, error) {
+	return c.next.CalculateRatio(ctx, ratio, period)
+}
+
+// Invalidate moves the ledger version of accounts on, dropping their cached
+// results
+func (c *CachingCalculator) Invalidate(accountIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range accountIDs {
+		c.versions[id]++
+		for key := range c.balances {
+			if key.accountID == id {
+				delete(c.balances, key)
+			}
+		}
+		for key := range c.changes {
+			if key.accountID == id {
+				delete(c.changes, key)
+			}
+		}
+	}
+}
+
+// InvalidateAll moves the ledger version of every account on, emptying the
+// cache
+func (c *CachingCalculator) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.global++
+	c.balances = make(map[cacheKey]money.Money)
+	c.changes = make(map[cacheKey]*BalanceChange)
+}
+
+// Handle implements event.Handler. Balance updates invalidate their account;
+// posted and voided transactions, which do not name their accounts,
+// invalidate everything.
+func (c *CachingCalculator) Handle(ctx context.Context, e event.Event) error {
+	switch e.Type {
+	case event.AccountBalanceUpdated:
+		if data, ok := e.Data.(event.AccountBalanceUpdatedV1); ok && data.AccountID != "" {
+			c.Invalidate(data.AccountID)
+			return nil
+		}
+		c.InvalidateAll()
+	case event.TransactionPosted, event.TransactionVoided:
+		c.InvalidateAll()
+	}
+	return nil
+}
+
+// Subscribe registers the calculator for the events that invalidate it
+func (c *CachingCalculator) Subscribe(bus event.Bus) error {
+	for _, eventType := range []string{event.AccountBalanceUpdated, event.TransactionPosted, event.TransactionVoided} {
+		if err := bus.Subscribe(eventType, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key builds the cache key of a calculation at the current ledger version
+func (c *CachingCalculator) key(ctx context.Context, accountID string, period ReportPeriod) cacheKey {
+	journalID, _ := journal.FromContext(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cacheKey{
+		accountID: accountID,
+		start:     period.Start.UTC().Format(time.RFC3339Nano),
+		end:       period.End.UTC().Format(time.RFC3339Nano),
+		scope:     tenant.ID(ctx) + "|" + journalID + "|" + string(DateBasisFromContext(ctx)),
+		version:   c.versionLocked(accountID),
+	}
+}
+
+// versionLocked returns the ledger version of an account; the caller holds
+// the lock
+func (c *CachingCalculator) versionLocked(accountID string) uint64 {
+	return c.global + c.versions[accountID]
+}
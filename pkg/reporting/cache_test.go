@@ -0,0 +1,69 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCalculator returns the number of calls made so far as the balance
+type countingCalculator struct {
+	ReportCalculator
+	calls int
+}
+
+func (c *countingCalculator) CalculateBalance(ctx context.Context, accountID string, period ReportPeriod) (money.Money, error) {
+	c.calls++
+	return money.Money{Amount: decimal.NewFromInt(int64(c.calls)), Currency: "USD"}, nil
+}
+
+func (c *countingCalculator) CalculateChanges(ctx context.Context, accountID string, period ReportPeriod) (*BalanceChange, error) {
+	c.calls++
+	return &BalanceChange{NetChange: money.Money{Amount: decimal.NewFromInt(int64(c.calls)), Currency: "USD"}}, nil
+}
+
+func TestCachingCalculator(t *testing.T) {
+	ctx := context.Background()
+	next := &countingCalculator{}
+	cache := NewCachingCalculator(next)
+	bus := event.NewMemoryBus()
+	require.NoError(t, cache.Subscribe(bus))
+
+	period := ReportPeriod{End: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)}
+	balance := func(ctx context.Context, accountID string) int64 {
+		b, err := cache.CalculateBalance(ctx, accountID, period)
+		require.NoError(t, err)
+		return b.Amount.IntPart()
+	}
+
+	assert.Equal(t, int64(1), balance(ctx, "1000"))
+	assert.Equal(t, int64(1), balance(ctx, "1000"), "served from the cache")
+	assert.Equal(t, int64(2), balance(ctx, "2000"))
+	assert.Equal(t, int64(3), balance(tenant.WithTenant(ctx, "acme"), "1000"), "scoped separately")
+	assert.Equal(t, int64(4), balance(WithDateBasis(ctx, EffectiveDateBasis), "1000"), "date basis is part of the key")
+
+	require.NoError(t, bus.Publish(ctx, event.Event{
+		Type: event.AccountBalanceUpdated,
+		Data: event.AccountBalanceUpdatedV1{AccountID: "1000"},
+	}))
+	assert.Equal(t, int64(5), balance(ctx, "1000"))
+	assert.Equal(t, int64(2), balance(ctx, "2000"), "other accounts stay cached")
+
+	change, err := cache.CalculateChanges(ctx, "2000", period)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), change.NetChange.Amount.IntPart())
+
+	require.NoError(t, bus.Publish(ctx, event.Event{Type: event.TransactionPosted}))
+	assert.Equal(t, int64(7), balance(ctx, "2000"))
+	change, err = cache.CalculateChanges(ctx, "2000", period)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), change.NetChange.Amount.IntPart())
+	assert.Equal(t, 8, next.calls)
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/journal"
 	"github.com/johnayoung/finlib/pkg/money"
 	"github.com/johnayoung/finlib/pkg/storage"
 	"github.com/johnayoung/finlib/pkg/transaction"
@@ -17,6 +19,8 @@ type defaultReportCalculator struct {
 	accountStore     account.Repository
 	transactionProc  transaction.TransactionProcessor
 	transactionStore storage.Repository
+	// Optional snapshots used to avoid scanning from the beginning of time
+	snapshots SnapshotStore
 }
 
 // NewReportCalculator creates a new instance of the report calculator
@@ -34,6 +38,11 @@ func NewReportCalculator(
 
 // CalculateBalance computes account balances for reporting
 func (c *defaultReportCalculator) CalculateBalance(ctx context.Context, accountID string, period ReportPeriod) (money.Money, error) {
+	// Balances as of a date can start from the latest snapshot
+	if period.Start.IsZero() && c.useSnapshots(ctx) {
+		return c.getBalanceAtTime(ctx, accountID, period.End)
+	}
+
 	// Get the account
 	var acc account.Account
 	if err := c.accountStore.Read(ctx, accountID, &acc); err != nil {
@@ -47,7 +56,7 @@ func (c *defaultReportCalculator) CalculateBalance(ctx context.Context, accountI
 	}
 
 	// Calculate balance from transactions
-	return c.calculateBalanceFromTransactions(transactions, acc.Type)
+	return c.calculateBalanceFromTransactions(transactions, accountID, acc.Type)
 }
 
 // CalculateChanges computes changes over a period
@@ -71,16 +80,26 @@ func (c *defaultReportCalculator) CalculateChanges(ctx context.Context, accountI
 	}
 
 	// Get movements from transactions
+	basis := DateBasisFromContext(ctx)
 	movements := make([]BalanceMovement, 0, len(transactions))
 	for _, tx := range transactions {
+		var counter []transaction.Entry
+		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				counter = append(counter, entry)
+			}
+		}
 		for _, entry := range tx.Entries {
 			if entry.AccountID == accountID {
 				movements = append(movements, BalanceMovement{
-					Date:        tx.Date,
-					Amount:      entry.Amount,
-					Type:        string(entry.Type),
-					Description: tx.Description,
-					Reference:   tx.ID,
+					Date:           basis.dateOf(tx),
+					Amount:         entry.Amount,
+					Type:           string(entry.Type),
+					Description:    tx.Description,
+					Reference:      tx.ID,
+					Attachments:    tx.Attachments,
+					CounterEntries: counter,
+					Dimensions:     entry.Dimensions,
 				})
 			}
 		}
@@ -131,28 +150,30 @@ func (c *defaultReportCalculator) CalculateRatio(ctx context.Context, ratio Rati
 // Helper functions
 
 func (c *defaultReportCalculator) getTransactionsForPeriod(ctx context.Context, accountID string, period ReportPeriod) ([]*transaction.Transaction, error) {
-	// Create a query to find transactions for the account within the period
+	// Create a query to find transactions for the account within the period,
+	// dated by the context's date basis
+	dateField := DateBasisFromContext(ctx).dateField()
 	query := storage.Query{
 		Filters: []storage.Filter{
 			{Field: "entries.account_id", Operator: "=", Value: accountID},
-			{Field: "date", Operator: ">=", Value: period.Start},
-			{Field: "date", Operator: "<=", Value: period.End},
+			{Field: dateField, Operator: ">=", Value: period.Start},
+			{Field: dateField, Operator: "<=", Value: period.End},
 			{Field: "status", Operator: "=", Value: transaction.Posted},
 		},
 		Sort: []storage.Sort{
-			{Field: "date", Desc: false},
+			{Field: dateField, Desc: false},
 		},
 	}
 
 	var transactions []*transaction.Transaction
-	if err := c.transactionStore.Query(ctx, query, &transactions); err != nil {
+	if err := c.transactionStore.Query(ctx, journal.ScopeQuery(ctx, entity.ScopeQuery(ctx, query)), &transactions); err != nil {
 		return nil, fmt.Errorf("error querying transactions: %w", err)
 	}
 
 	return transactions, nil
 }
 
-func (c *defaultReportCalculator) calculateBalanceFromTransactions(transactions []*transaction.Transaction, accountType account.AccountType) (money.Money, error) {
+func (c *defaultReportCalculator) calculateBalanceFromTransactions(transactions []*transaction.Transaction, accountID string, accountType account.AccountType) (money.Money, error) {
 	if len(transactions) == 0 {
 		return money.Money{Amount: decimal.Zero, Currency: "USD"}, nil
 	}
@@ -163,6 +184,9 @@ func (c *defaultReportCalculator) calculateBalanceFromTransactions(transactions
 
 	for _, tx := range transactions {
 		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				continue
+			}
 			if entry.Amount.Currency != currency {
 				return money.Money{}, fmt.Errorf("mixed currencies in transactions")
 			}
@@ -188,11 +212,24 @@ func (c *defaultReportCalculator) calculateBalanceFromTransactions(transactions
 }
 
 func (c *defaultReportCalculator) getBalanceAtTime(ctx context.Context, accountID string, at time.Time) (money.Money, error) {
-	// Get all transactions up to the specified time
+	// Start from the latest snapshot taken at or before the requested time,
+	// or from the beginning of time when there is none
+	var snapshot *BalanceSnapshot
+	if c.useSnapshots(ctx) {
+		var err error
+		snapshot, err = c.snapshots.LatestSnapshot(ctx, accountID, at)
+		if err != nil {
+			return money.Money{}, fmt.Errorf("error reading snapshot: %w", err)
+		}
+	}
+
 	period := ReportPeriod{
 		Start: time.Time{}, // Beginning of time
 		End:   at,
 	}
+	if snapshot != nil {
+		period.Start = snapshot.AsOf.Add(time.Nanosecond)
+	}
 
 	transactions, err := c.getTransactionsForPeriod(ctx, accountID, period)
 	if err != nil {
@@ -205,7 +242,20 @@ func (c *defaultReportCalculator) getBalanceAtTime(ctx context.Context, accountI
 		return money.Money{}, fmt.Errorf("error reading account: %w", err)
 	}
 
-	return c.calculateBalanceFromTransactions(transactions, acc.Type)
+	delta, err := c.calculateBalanceFromTransactions(transactions, accountID, acc.Type)
+	if err != nil || snapshot == nil {
+		return delta, err
+	}
+	if len(transactions) == 0 {
+		return snapshot.Balance, nil
+	}
+	return snapshot.Balance.Add(delta)
+}
+
+// useSnapshots reports whether snapshots apply; they are taken on the
+// posting date basis
+func (c *defaultReportCalculator) useSnapshots(ctx context.Context) bool {
+	return c.snapshots != nil && DateBasisFromContext(ctx) == PostingDateBasis
 }
 
 func (c *defaultReportCalculator) calculateValue(ctx context.Context, calc Calculation, period ReportPeriod) (decimal.Decimal, error) {
@@ -218,7 +268,12 @@ func (c *defaultReportCalculator) calculateValue(ctx context.Context, calc Calcu
 	// Calculate total for all matching accounts
 	total := decimal.Zero
 	for _, acc := range accounts {
-		balance, err := c.CalculateBalance(ctx, acc.ID, period)
+		var balance money.Money
+		if len(calc.AccountSelector.Dimensions) > 0 {
+			balance, err = c.calculateDimensionBalance(ctx, acc, period, calc.AccountSelector.Dimensions)
+		} else {
+			balance, err = c.CalculateBalance(ctx, acc.ID, period)
+		}
 		if err != nil {
 			return decimal.Zero, fmt.Errorf("error calculating balance for account %s: %w", acc.ID, err)
 		}
@@ -228,6 +283,40 @@ func (c *defaultReportCalculator) calculateValue(ctx context.Context, calc Calcu
 	return total, nil
 }
 
+// calculateDimensionBalance computes an account's balance over the entries
+// matching every dimension filter
+func (c *defaultReportCalculator) calculateDimensionBalance(ctx context.Context, acc *account.Account, period ReportPeriod, filters []DimensionFilter) (money.Money, error) {
+	transactions, err := c.getTransactionsForPeriod(ctx, acc.ID, period)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("error getting transactions: %w", err)
+	}
+
+	matching := make([]*transaction.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		var entries []transaction.Entry
+		for _, entry := range tx.Entries {
+			if entry.AccountID == acc.ID && matchesDimensions(acc.EntryDimensions(entry.Dimensions), filters) {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			filtered := *tx
+			filtered.Entries = entries
+			matching = append(matching, &filtered)
+		}
+	}
+	return c.calculateBalanceFromTransactions(matching, acc.ID, acc.Type)
+}
+
+func matchesDimensions(dims map[string]string, filters []DimensionFilter) bool {
+	for _, f := range filters {
+		if !f.Matches(dims) {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *defaultReportCalculator) getAccountsForSelector(ctx context.Context, selector AccountSelector) ([]*account.Account, error) {
 	// Create a query based on the selector criteria
 	query := storage.Query{
@@ -262,7 +351,7 @@ func (c *defaultReportCalculator) getAccountsForSelector(ctx context.Context, se
 	}
 
 	var accounts []*account.Account
-	if err := c.accountStore.Query(ctx, query, &accounts); err != nil {
+	if err := c.accountStore.Query(ctx, entity.ScopeQuery(ctx, query), &accounts); err != nil {
 		return nil, fmt.Errorf("error querying accounts: %w", err)
 	}
 
@@ -410,3 +410,84 @@ func TestCalculateRatio(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, decimal.NewFromInt(2).Equal(result)) // 1000/500 = 2.00
 }
+
+func TestCalculateRatioByDimension(t *testing.T) {
+	ctx := context.Background()
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	accounts := &chartStore{accounts: []*account.Account{
+		{ID: "6100", Type: account.Expense, Dimensions: map[string]string{account.DimensionDepartment: "sales"}},
+	}}
+	transactionStore := &mockTransactionRepository{}
+	transactionStore.On("Query", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(2).(*[]*transaction.Transaction)) = []*transaction.Transaction{{
+				ID:     "TXN001",
+				Status: transaction.Posted,
+				Entries: []transaction.Entry{
+					// Inherits the account's department
+					{AccountID: "6100", Amount: usd(300), Type: transaction.Debit},
+					{AccountID: "6100", Amount: usd(200), Type: transaction.Debit,
+						Dimensions: map[string]string{account.DimensionDepartment: "engineering"}},
+					{AccountID: "1000", Amount: usd(500), Type: transaction.Credit},
+				},
+			}}
+		}).
+		Return(nil)
+	calculator := NewReportCalculator(accounts, &mockTransactionProcessor{}, transactionStore)
+
+	spend := func(departments ...string) Calculation {
+		return Calculation{AccountSelector: AccountSelector{
+			Types:      []account.AccountType{account.Expense},
+			Dimensions: []DimensionFilter{{Dimension: account.DimensionDepartment, Values: departments}},
+		}}
+	}
+	ratio, err := calculator.CalculateRatio(ctx, RatioDefinition{
+		Numerator:   spend("sales"),
+		Denominator: spend(),
+		Scale:       2,
+	}, ReportPeriod{End: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, "0.6", ratio.String())
+}
+
+func TestCalculateChangesByEffectiveDate(t *testing.T) {
+	entered := time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)
+	effective := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	adjustment := &transaction.Transaction{
+		ID:            "ADJ001",
+		Status:        transaction.Posted,
+		Date:          entered,
+		EffectiveDate: &effective,
+		Entries: []transaction.Entry{
+			{AccountID: "6100", Amount: money.Money{Amount: decimal.NewFromInt(75), Currency: "USD"}, Type: transaction.Debit},
+		},
+	}
+
+	var fields []string
+	transactionStore := &mockTransactionRepository{}
+	transactionStore.On("Query", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			fields = append(fields, args.Get(1).(storage.Query).Filters[1].Field)
+			*(args.Get(2).(*[]*transaction.Transaction)) = []*transaction.Transaction{adjustment}
+		}).
+		Return(nil)
+	accountStore := &mockAccountRepository{}
+	accountStore.On("Read", mock.Anything, "6100", mock.Anything).
+		Return(&account.Account{ID: "6100", Type: account.Expense}, nil)
+	calculator := NewReportCalculator(accountStore, &mockTransactionProcessor{}, transactionStore)
+	march := ReportPeriod{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), End: effective}
+
+	ctx := WithDateBasis(context.Background(), EffectiveDateBasis)
+	changes, err := calculator.CalculateChanges(ctx, "6100", march)
+	assert.NoError(t, err)
+	assert.Equal(t, effective, changes.Movements[0].Date)
+	assert.Equal(t, []string{"effective_date", "effective_date", "effective_date"}, fields)
+
+	fields = nil
+	changes, err = calculator.CalculateChanges(context.Background(), "6100", march)
+	assert.NoError(t, err)
+	assert.Equal(t, entered, changes.Movements[0].Date)
+	assert.Equal(t, "date", fields[0])
+}
@@ -0,0 +1,207 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultShocks are the rate moves exposure sensitivity is quantified for
+var DefaultShocks = []decimal.Decimal{
+	decimal.NewFromFloat(0.01),
+	decimal.NewFromFloat(0.05),
+	decimal.NewFromFloat(0.10),
+}
+
+// ExposureOptions configures a foreign currency exposure report
+type ExposureOptions struct {
+	// Currency exposures are measured against
+	BaseCurrency string
+	// Balances and rates are taken as of this time
+	AsOf time.Time
+	// Account types holding monetary balances; defaults to assets and liabilities
+	AccountTypes []account.AccountType
+	// Relative rate moves to measure, e.g. 0.05 for a 5% strengthening of the
+	// foreign currency; defaults to DefaultShocks
+	Shocks []decimal.Decimal
+}
+
+// ExposureLine is an open foreign currency balance on a single account
+type ExposureLine struct {
+	AccountID   string `json:"account_id"`
+	AccountCode string `json:"account_code"`
+	AccountName string `json:"account_name"`
+	AccountType string `json:"account_type"`
+	// Balance in the account currency; liabilities reduce exposure
+	Balance money.Money     `json:"balance"`
+	Rate    decimal.Decimal `json:"rate"`
+	// Balance converted to the base currency
+	BaseAmount money.Money `json:"base_amount"`
+}
+
+// Sensitivity is the base currency impact of a rate move
+type Sensitivity struct {
+	Shock  decimal.Decimal `json:"shock"`
+	Impact money.Money     `json:"impact"`
+}
+
+// CurrencyExposure aggregates the open balances in one foreign currency
+type CurrencyExposure struct {
+	Currency    string          `json:"currency"`
+	Assets      money.Money     `json:"assets"`
+	Liabilities money.Money     `json:"liabilities"`
+	Net         money.Money     `json:"net"`
+	Rate        decimal.Decimal `json:"rate"`
+	NetBase     money.Money     `json:"net_base"`
+	Sensitivity []Sensitivity   `json:"sensitivity"`
+}
+
+// ExposureReport lists foreign currency balances and their sensitivity to
+// exchange rate moves
+type ExposureReport struct {
+	BaseCurrency string             `json:"base_currency"`
+	AsOf         time.Time          `json:"as_of"`
+	Lines        []ExposureLine     `json:"lines"`
+	Currencies   []CurrencyExposure `json:"currencies"`
+	// Net exposure across all currencies in the base currency
+	TotalNet money.Money `json:"total_net"`
+	// Impact of every foreign currency moving by each shock at once
+	Sensitivity []Sensitivity `json:"sensitivity"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
+
+// ExposureCalculator builds foreign currency exposure reports
+type ExposureCalculator struct {
+	accounts   account.Repository
+	calculator ReportCalculator
+	rates      money.ExchangeRateProvider
+}
+
+// NewExposureCalculator creates an exposure calculator
+func NewExposureCalculator(accounts account.Repository, calculator ReportCalculator, rates money.ExchangeRateProvider) *ExposureCalculator {
+	return &ExposureCalculator{
+		accounts:   accounts,
+		calculator: calculator,
+		rates:      rates,
+	}
+}
+
+// Generate builds an exposure report. Accounts whose balance is zero or in
+// the base currency are omitted.
+func (e *ExposureCalculator) Generate(ctx context.Context, opts ExposureOptions) (*ExposureReport, error) {
+	if opts.BaseCurrency == "" {
+		return nil, fmt.Errorf("base currency is required")
+	}
+	if opts.AsOf.IsZero() {
+		opts.AsOf = time.Now()
+	}
+	if len(opts.AccountTypes) == 0 {
+		opts.AccountTypes = []account.AccountType{account.Asset, account.Liability}
+	}
+	if len(opts.Shocks) == 0 {
+		opts.Shocks = DefaultShocks
+	}
+
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "type", Operator: "in", Value: opts.AccountTypes},
+		},
+	}
+	var accounts []*account.Account
+	if err := e.accounts.Query(ctx, entity.ScopeQuery(ctx, query), &accounts); err != nil {
+		return nil, fmt.Errorf("error querying accounts: %w", err)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+
+	report := &ExposureReport{
+		BaseCurrency: opts.BaseCurrency,
+		AsOf:         opts.AsOf,
+		TotalNet:     zeroMoney(opts.BaseCurrency),
+		GeneratedAt:  time.Now(),
+	}
+	byCurrency := make(map[string]*CurrencyExposure)
+
+	for _, acc := range accounts {
+		balance, err := e.calculator.CalculateBalance(ctx, acc.ID, ReportPeriod{End: opts.AsOf})
+		if err != nil {
+			return nil, fmt.Errorf("error calculating balance for account %s: %w", acc.ID, err)
+		}
+		if balance.IsZero() || balance.Currency == opts.BaseCurrency {
+			continue
+		}
+
+		rate, err := e.rates.Rate(ctx, balance.Currency, opts.BaseCurrency, opts.AsOf)
+		if err != nil {
+			return nil, fmt.Errorf("error converting account %s: %w", acc.ID, err)
+		}
+
+		// Liabilities are a short position in the currency
+		exposure := balance
+		if acc.Type == account.Liability {
+			exposure = exposure.Multiply(decimal.NewFromInt(-1))
+		}
+		report.Lines = append(report.Lines, ExposureLine{
+			AccountID:   acc.ID,
+			AccountCode: acc.Code,
+			AccountName: acc.Name,
+			AccountType: string(acc.Type),
+			Balance:     exposure,
+			Rate:        rate,
+			BaseAmount:  money.Money{Amount: exposure.Amount.Mul(rate).Round(2), Currency: opts.BaseCurrency},
+		})
+
+		ce, ok := byCurrency[balance.Currency]
+		if !ok {
+			ce = &CurrencyExposure{
+				Currency:    balance.Currency,
+				Assets:      zeroMoney(balance.Currency),
+				Liabilities: zeroMoney(balance.Currency),
+				Net:         zeroMoney(balance.Currency),
+				Rate:        rate,
+			}
+			byCurrency[balance.Currency] = ce
+		}
+		if exposure.IsNegative() {
+			ce.Liabilities.Amount = ce.Liabilities.Amount.Sub(exposure.Amount)
+		} else {
+			ce.Assets.Amount = ce.Assets.Amount.Add(exposure.Amount)
+		}
+		ce.Net.Amount = ce.Net.Amount.Add(exposure.Amount)
+	}
+
+	totals := make([]decimal.Decimal, len(opts.Shocks))
+	for _, ce := range byCurrency {
+		ce.NetBase = money.Money{Amount: ce.Net.Amount.Mul(ce.Rate).Round(2), Currency: opts.BaseCurrency}
+		report.TotalNet.Amount = report.TotalNet.Amount.Add(ce.NetBase.Amount)
+		for i, shock := range opts.Shocks {
+			impact := ce.NetBase.Amount.Mul(shock).Round(2)
+			ce.Sensitivity = append(ce.Sensitivity, Sensitivity{
+				Shock:  shock,
+				Impact: money.Money{Amount: impact, Currency: opts.BaseCurrency},
+			})
+			totals[i] = totals[i].Add(impact)
+		}
+		report.Currencies = append(report.Currencies, *ce)
+	}
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	for i, shock := range opts.Shocks {
+		report.Sensitivity = append(report.Sensitivity, Sensitivity{
+			Shock:  shock,
+			Impact: money.Money{Amount: totals[i], Currency: opts.BaseCurrency},
+		})
+	}
+	return report, nil
+}
+
+func zeroMoney(currency string) money.Money {
+	return money.Money{Amount: decimal.Zero, Currency: currency}
+}
@@ -0,0 +1,91 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chartStore returns a fixed chart of accounts for any query
+type chartStore struct {
+	account.Repository
+	accounts []*account.Account
+}
+
+func (s *chartStore) Query(ctx context.Context, query interface{}, results interface{}) error {
+	*(results.(*[]*account.Account)) = append([]*account.Account(nil), s.accounts...)
+	return nil
+}
+
+// balanceCalculator returns fixed balances per account
+type balanceCalculator struct {
+	ReportCalculator
+	balances map[string]money.Money
+}
+
+func (c *balanceCalculator) CalculateBalance(ctx context.Context, accountID string, period ReportPeriod) (money.Money, error) {
+	if balance, ok := c.balances[accountID]; ok {
+		return balance, nil
+	}
+	return money.Money{Amount: decimal.Zero, Currency: "USD"}, nil
+}
+
+func TestExposureCalculator(t *testing.T) {
+	amount := func(value float64, currency string) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(value), Currency: currency}
+	}
+	accounts := &chartStore{accounts: []*account.Account{
+		{ID: "cash-usd", Code: "1000", Name: "Cash USD", Type: account.Asset},
+		{ID: "cash-eur", Code: "1010", Name: "Cash EUR", Type: account.Asset},
+		{ID: "ar-eur", Code: "1200", Name: "Receivables EUR", Type: account.Asset},
+		{ID: "ap-eur", Code: "2000", Name: "Payables EUR", Type: account.Liability},
+		{ID: "ap-gbp", Code: "2010", Name: "Payables GBP", Type: account.Liability},
+		{ID: "ar-jpy", Code: "1210", Name: "Receivables JPY", Type: account.Asset},
+	}}
+	calc := &balanceCalculator{balances: map[string]money.Money{
+		"cash-usd": amount(5000, "USD"),
+		"cash-eur": amount(1000, "EUR"),
+		"ar-eur":   amount(500, "EUR"),
+		"ap-eur":   amount(300, "EUR"),
+		"ap-gbp":   amount(200, "GBP"),
+	}}
+	rates := money.NewRateTable()
+	rates.Set("EUR", "USD", decimal.NewFromFloat(1.1))
+	rates.Set("USD", "GBP", decimal.NewFromFloat(0.8))
+
+	report, err := NewExposureCalculator(accounts, calc, rates).Generate(context.Background(), ExposureOptions{
+		BaseCurrency: "USD",
+		AsOf:         time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Lines, 4)
+	assert.Equal(t, "1010", report.Lines[0].AccountCode)
+	assert.True(t, report.Lines[3].Balance.Amount.Equal(decimal.NewFromInt(-200)))
+	assert.True(t, report.Lines[3].BaseAmount.Amount.Equal(decimal.NewFromInt(-250)))
+
+	require.Len(t, report.Currencies, 2)
+	eur := report.Currencies[0]
+	assert.Equal(t, "EUR", eur.Currency)
+	assert.True(t, eur.Assets.Amount.Equal(decimal.NewFromInt(1500)))
+	assert.True(t, eur.Liabilities.Amount.Equal(decimal.NewFromInt(300)))
+	assert.True(t, eur.NetBase.Amount.Equal(decimal.NewFromInt(1320)))
+	require.Len(t, eur.Sensitivity, 3)
+	assert.True(t, eur.Sensitivity[2].Impact.Amount.Equal(decimal.NewFromInt(132)))
+
+	assert.True(t, report.TotalNet.Amount.Equal(decimal.NewFromInt(1070)))
+	assert.True(t, report.Sensitivity[1].Impact.Amount.Equal(decimal.NewFromFloat(53.5)))
+
+	t.Run("missing rate", func(t *testing.T) {
+		calc.balances["ar-jpy"] = amount(10000, "JPY")
+		defer delete(calc.balances, "ar-jpy")
+		_, err := NewExposureCalculator(accounts, calc, rates).Generate(context.Background(), ExposureOptions{BaseCurrency: "USD"})
+		assert.ErrorIs(t, err, money.ErrRateNotFound)
+	})
+}
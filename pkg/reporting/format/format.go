@@ -0,0 +1,92 @@
+// Package format renders generated reports into output formats for export
+// and delivery to end users.
+package format
+
+import (
+	"errors"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported report format")
+)
+
+// Output formats
+const (
+	JSON   = "JSON"
+	NDJSON = "NDJSON"
+	PDF    = "PDF"
+	XLSX   = "XLSX"
+)
+
+// Option keys accepted by the formatters
+const (
+	// OptionHierarchical nests child lines under their parents instead of
+	// listing every line at the top level (bool)
+	OptionHierarchical = "hierarchical"
+	// OptionComparative includes the comparative period amounts (bool)
+	OptionComparative = "comparative"
+	// OptionIndent pretty-prints JSON output (bool)
+	OptionIndent = "indent"
+	// OptionLocale selects the number format of amounts in rendered
+	// documents, e.g. "de-DE" (string); amounts default to en-US
+	OptionLocale = "locale"
+)
+
+// boolOption reads a boolean option, falling back to a default when it is
+// not set
+func boolOption(opts map[string]interface{}, key string, def bool) bool {
+	if v, ok := opts[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// flattenLines lists report lines depth first with children following their
+// parent. Level and ParentID are filled in from the nesting when unset.
+func flattenLines(lines []*reporting.ReportLine) []*reporting.ReportLine {
+	flat := make([]*reporting.ReportLine, 0, len(lines))
+	var walk func(lines []*reporting.ReportLine, parent *reporting.ReportLine)
+	walk = func(lines []*reporting.ReportLine, parent *reporting.ReportLine) {
+		for _, line := range lines {
+			l := *line
+			l.Children = nil
+			if parent != nil {
+				if l.ParentID == "" {
+					l.ParentID = parent.AccountID
+				}
+				if l.Level <= parent.Level {
+					l.Level = parent.Level + 1
+				}
+			}
+			flat = append(flat, &l)
+			walk(line.Children, &l)
+		}
+	}
+	walk(lines, nil)
+	return flat
+}
+
+// nestLines builds the line hierarchy from ParentID, keeping the order of
+// the flattened lines. Lines whose parent is not in the report stay at the
+// top level.
+func nestLines(lines []*reporting.ReportLine) []*reporting.ReportLine {
+	flat := flattenLines(lines)
+	byID := make(map[string]*reporting.ReportLine, len(flat))
+	for _, line := range flat {
+		if line.AccountID != "" {
+			byID[line.AccountID] = line
+		}
+	}
+
+	roots := make([]*reporting.ReportLine, 0)
+	for _, line := range flat {
+		if parent, ok := byID[line.ParentID]; ok && parent != line {
+			parent.Children = append(parent.Children, line)
+			continue
+		}
+		roots = append(roots, line)
+	}
+	return roots
+}
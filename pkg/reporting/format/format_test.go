@@ -0,0 +1,257 @@
+package format
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func sampleReport() *reporting.Report {
+	previous := usd(900)
+	return &reporting.Report{
+		ID:       "RPT-1",
+		Type:     reporting.BalanceSheet,
+		Title:    "Balance Sheet",
+		Currency: "USD",
+		Period: reporting.ReportPeriod{
+			Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		Lines: []*reporting.ReportLine{
+			{AccountID: "1000", AccountCode: "1000", AccountName: "Current Assets", Amount: usd(1500), PreviousAmount: &previous,
+				Children: []*reporting.ReportLine{
+					{AccountID: "1010", AccountCode: "1010", AccountName: "Cash", Amount: usd(1000)},
+				}},
+			{AccountID: "1200", AccountCode: "1200", AccountName: "Receivables", Amount: usd(500), ParentID: "1000", Level: 1},
+			{AccountID: "2000", AccountCode: "2000", AccountName: "Payables", Amount: usd(300)},
+		},
+		Totals: map[string]money.Money{"assets": usd(1500)},
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	ctx := context.Background()
+	f := NewJSONFormatter()
+	report := sampleReport()
+
+	t.Run("Hierarchical", func(t *testing.T) {
+		out, err := f.FormatReport(ctx, report, "json", nil)
+		require.NoError(t, err)
+
+		var decoded reporting.Report
+		require.NoError(t, json.Unmarshal(out, &decoded))
+		require.Len(t, decoded.Lines, 2)
+		assert.Len(t, decoded.Lines[0].Children, 2)
+		assert.Equal(t, "1200", decoded.Lines[0].Children[1].AccountID)
+		assert.Nil(t, decoded.Lines[0].PreviousAmount)
+		assert.Len(t, report.Lines, 3, "the report itself is not modified")
+	})
+
+	t.Run("Flat with comparatives", func(t *testing.T) {
+		out, err := f.FormatReport(ctx, report, JSON, map[string]interface{}{
+			OptionHierarchical: false,
+			OptionComparative:  true,
+		})
+		require.NoError(t, err)
+
+		var decoded reporting.Report
+		require.NoError(t, json.Unmarshal(out, &decoded))
+		require.Len(t, decoded.Lines, 4)
+		assert.Equal(t, "1010", decoded.Lines[1].AccountID)
+		assert.Equal(t, "1000", decoded.Lines[1].ParentID)
+		assert.Equal(t, 1, decoded.Lines[1].Level)
+		assert.True(t, decoded.Lines[0].PreviousAmount.Amount.Equal(decimal.NewFromInt(900)))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		out, err := f.FormatReport(ctx, report, NDJSON, nil)
+		require.NoError(t, err)
+
+		var records []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records = append(records, record)
+		}
+		require.Len(t, records, 5)
+		assert.Equal(t, RecordHeader, records[0]["record"])
+		assert.Equal(t, "RPT-1", records[0]["id"])
+		assert.NotContains(t, records[0], "lines")
+		assert.Equal(t, RecordLine, records[1]["record"])
+		assert.Equal(t, "Cash", records[2]["account_name"])
+		assert.NotContains(t, records[1], "previous_amount")
+	})
+
+	_, err := f.FormatReport(ctx, report, "YAML", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func sampleStatement() *statements.Statement {
+	asOf := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	section := func(title string, total int64, items ...statements.LineItem) statements.StatementSection {
+		return statements.StatementSection{Title: title, Items: items, Total: usd(total)}
+	}
+	return &statements.Statement{
+		Type:   statements.BalanceSheet,
+		Title:  "Balance Sheet",
+		Entity: "Acme (US) Corp",
+		AsOf:   asOf,
+		Sections: []statements.StatementSection{
+			section("Assets", 1500, statements.LineItem{Label: "Cash", Amount: usd(1000)}, statements.LineItem{Label: "Receivables", Amount: usd(500)}),
+			section("Liabilities", -300, statements.LineItem{Label: "Payables", Amount: usd(-300)}),
+		},
+		Currency: "USD",
+		ComparativePeriod: &statements.Statement{
+			AsOf:     asOf.AddDate(-1, 0, 0),
+			Sections: []statements.StatementSection{section("Assets", 800, statements.LineItem{Label: "Cash", Amount: usd(800)})},
+		},
+	}
+}
+
+// checkPDF verifies the file structure and returns the page count
+func checkPDF(t *testing.T, out []byte) int {
+	s := string(out)
+	require.True(t, strings.HasPrefix(s, "%PDF-1.4"))
+	require.True(t, strings.HasSuffix(s, "%%EOF\n"))
+	i := strings.LastIndex(s, "startxref\n")
+	offset, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s[i+len("startxref\n"):], "%%EOF\n")))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(s[offset:], "xref"), "startxref points at the cross-reference table")
+
+	var count int
+	_, err = fmt.Sscanf(s[strings.Index(s, "/Count "):], "/Count %d", &count)
+	require.NoError(t, err)
+	return count
+}
+
+func TestPDFFormatter(t *testing.T) {
+	ctx := context.Background()
+	f := NewPDFFormatter()
+
+	out, err := f.FormatStatement(ctx, sampleStatement(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, checkPDF(t, out))
+	s := string(out)
+	for _, text := range []string{
+		"(Acme \\(US\\) Corp)",
+		"(Balance Sheet)",
+		"(As of December 31, 2024)",
+		"(Dec 31, 2023)",
+		"(Total Assets)",
+		"(1,500.00 )",
+		"(800.00 )",
+		"(\\(300.00\\))",
+	} {
+		assert.Contains(t, s, text)
+	}
+
+	out, err = f.FormatStatement(ctx, sampleStatement(), map[string]interface{}{OptionComparative: false, OptionLocale: "de-DE"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "Dec 31, 2023")
+	assert.Contains(t, string(out), "(1.500,00 )")
+
+	t.Run("Long reports span pages", func(t *testing.T) {
+		report := sampleReport()
+		for i := 0; i < 80; i++ {
+			report.Lines = append(report.Lines, &reporting.ReportLine{AccountID: fmt.Sprint(5000 + i), AccountName: "Expense", Amount: usd(int64(i))})
+		}
+		out, err := f.FormatReport(ctx, report, "pdf", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, checkPDF(t, out))
+
+		_, err = f.FormatReport(ctx, report, JSON, nil)
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
+
+// readWorkbook returns the parts of a workbook, checking each is well-formed
+func readWorkbook(t *testing.T, out []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err)
+	parts := make(map[string]string)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, file.Name)
+		}
+		parts[file.Name] = string(data)
+	}
+	return parts
+}
+
+func TestXLSXFormatter(t *testing.T) {
+	ctx := context.Background()
+	f := NewXLSXFormatter()
+
+	income := &statements.Statement{
+		Title: "Income Statement",
+		AsOf:  time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		Sections: []statements.StatementSection{{
+			Title: "Revenue",
+			Items: []statements.LineItem{
+				{Label: "Sales", Amount: usd(900), SubItems: []statements.LineItem{{Label: "Online <web>", Amount: usd(400)}}},
+				{Label: "Services", Amount: usd(100)},
+			},
+			Total: usd(1000),
+		}},
+	}
+	out, err := f.FormatStatements(ctx, []*statements.Statement{sampleStatement(), income, sampleStatement()}, nil)
+	require.NoError(t, err)
+
+	parts := readWorkbook(t, out)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Balance Sheet" sheetId="1" r:id="rId1"/>`)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Income Statement" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Balance Sheet (2)" sheetId="3" r:id="rId3"/>`)
+	assert.Contains(t, parts["[Content_Types].xml"], "/xl/worksheets/sheet3.xml")
+
+	balance := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, balance, "Acme (US) Corp")
+	assert.Contains(t, balance, ">Dec 31, 2023<")
+	assert.Contains(t, balance, `<c r="B9" s="5"><f>SUM(B7:B8)</f><v>1500</v></c>`)
+	assert.Contains(t, balance, `<c r="C9" s="5"><f>SUM(C7:C8)</f><v>800</v></c>`)
+	assert.NotContains(t, balance, `r="C8"`, "items missing from the comparative period are blank")
+
+	revenue := parts["xl/worksheets/sheet2.xml"]
+	assert.Contains(t, revenue, "Online &lt;web&gt;")
+	assert.Contains(t, revenue, "<f>SUM(B6,B8)</f>", "sub-items are not summed twice")
+
+	out, err = f.FormatReport(ctx, sampleReport(), XLSX, map[string]interface{}{OptionComparative: false})
+	require.NoError(t, err)
+	sheet := readWorkbook(t, out)["xl/worksheets/sheet1.xml"]
+	assert.NotContains(t, sheet, "<f>", "report totals are not formulas")
+	assert.Contains(t, sheet, ">1010 Cash<")
+
+	_, err = f.FormatReport(ctx, sampleReport(), PDF, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
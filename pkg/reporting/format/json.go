@@ -0,0 +1,145 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+)
+
+// JSONFormatter renders reports as a JSON document or as newline-delimited
+// JSON
+type JSONFormatter struct{}
+
+// NewJSONFormatter creates a JSON formatter
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+// FormatReport implements reporting.ReportFormatter. JSON output nests lines
+// unless OptionHierarchical is false; NDJSON output is always flat. Both
+// leave out comparative amounts unless OptionComparative is set.
+func (f *JSONFormatter) FormatReport(ctx context.Context, report *reporting.Report, format string, opts map[string]interface{}) ([]byte, error) {
+	if report == nil {
+		return nil, fmt.Errorf("report cannot be nil")
+	}
+	comparative := boolOption(opts, OptionComparative, false)
+
+	switch strings.ToUpper(format) {
+	case JSON:
+		out := *report
+		if boolOption(opts, OptionHierarchical, true) {
+			out.Lines = nestLines(report.Lines)
+		} else {
+			out.Lines = flattenLines(report.Lines)
+		}
+		if !comparative {
+			out.Period.Previous = nil
+			stripComparative(out.Lines)
+		}
+
+		if boolOption(opts, OptionIndent, false) {
+			return json.MarshalIndent(&out, "", "  ")
+		}
+		return json.Marshal(&out)
+
+	case NDJSON:
+		var buf bytes.Buffer
+		if err := NewNDJSONWriter(&buf, comparative).WriteReport(report); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// GetSupportedFormats implements reporting.ReportFormatter
+func (f *JSONFormatter) GetSupportedFormats(ctx context.Context) ([]string, error) {
+	return []string{JSON, NDJSON}, nil
+}
+
+// stripComparative removes comparative amounts from lines and their children
+func stripComparative(lines []*reporting.ReportLine) {
+	for _, line := range lines {
+		line.PreviousAmount = nil
+		stripComparative(line.Children)
+	}
+}
+
+// Record types of NDJSON output
+const (
+	RecordHeader = "header"
+	RecordLine   = "line"
+)
+
+// ndjsonHeader is the first record: the report without its lines
+type ndjsonHeader struct {
+	Record string `json:"record"`
+	*reporting.Report
+	Lines []*reporting.ReportLine `json:"lines,omitempty"`
+}
+
+// ndjsonLine is a record per report line
+type ndjsonLine struct {
+	Record string `json:"record"`
+	*reporting.ReportLine
+}
+
+// NDJSONWriter streams a report as newline-delimited JSON: a header record
+// followed by one record per line. Lines can be written as they are
+// produced, so a large general ledger need not be held in memory.
+type NDJSONWriter struct {
+	enc         *json.Encoder
+	comparative bool
+}
+
+// NewNDJSONWriter creates a writer; comparative amounts are written only
+// when comparative is set
+func NewNDJSONWriter(w io.Writer, comparative bool) *NDJSONWriter {
+	return &NDJSONWriter{enc: json.NewEncoder(w), comparative: comparative}
+}
+
+// WriteHeader writes the header record of a report; its lines are not
+// written
+func (w *NDJSONWriter) WriteHeader(report *reporting.Report) error {
+	header := *report
+	if !w.comparative {
+		header.Period.Previous = nil
+	}
+	if err := w.enc.Encode(ndjsonHeader{Record: RecordHeader, Report: &header}); err != nil {
+		return fmt.Errorf("error writing report header: %w", err)
+	}
+	return nil
+}
+
+// WriteLine writes a line record. Children are not included; write them as
+// lines of their own.
+func (w *NDJSONWriter) WriteLine(line *reporting.ReportLine) error {
+	l := *line
+	l.Children = nil
+	if !w.comparative {
+		l.PreviousAmount = nil
+	}
+	if err := w.enc.Encode(ndjsonLine{Record: RecordLine, ReportLine: &l}); err != nil {
+		return fmt.Errorf("error writing report line %s: %w", line.AccountID, err)
+	}
+	return nil
+}
+
+// WriteReport writes the header and every line of a report, children
+// following their parent
+func (w *NDJSONWriter) WriteReport(report *reporting.Report) error {
+	if err := w.WriteHeader(report); err != nil {
+		return err
+	}
+	for _, line := range flattenLines(report.Lines) {
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package format
+
+import (
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+)
+
+// rowStyle controls how a document row is rendered
+type rowStyle int
+
+const (
+	rowHeading rowStyle = iota
+	rowItem
+	rowTotal
+)
+
+// row is a label with one amount per column; nil amounts are left blank
+type row struct {
+	style   rowStyle
+	label   string
+	indent  int
+	amounts []*money.Money
+}
+
+// document is a report or statement laid out for rendering
+type document struct {
+	title   string
+	entity  string
+	period  string
+	columns []string
+	rows    []row
+}
+
+// statementDocument lays out a statement with a column for its period and,
+// when comparative is set and the statement has one, the comparative period
+func statementDocument(stmt *statements.Statement, comparative bool) *document {
+	doc := &document{
+		title:   stmt.Title,
+		entity:  stmt.Entity,
+		period:  periodLabel(stmt.PeriodStart, stmt.AsOf),
+		columns: []string{columnLabel(stmt.AsOf)},
+	}
+
+	var previous map[string]map[string]money.Money
+	var previousTotals map[string]money.Money
+	if comparative && stmt.ComparativePeriod != nil {
+		doc.columns = append(doc.columns, columnLabel(stmt.ComparativePeriod.AsOf))
+		previous = make(map[string]map[string]money.Money)
+		previousTotals = make(map[string]money.Money)
+		for _, section := range stmt.ComparativePeriod.Sections {
+			amounts := make(map[string]money.Money)
+			collectAmounts(section.Items, amounts)
+			previous[section.Title] = amounts
+			previousTotals[section.Title] = section.Total
+		}
+	}
+
+	amountsFor := func(current money.Money, prev money.Money, found bool) []*money.Money {
+		amounts := []*money.Money{&current}
+		if previous != nil {
+			if found {
+				amounts = append(amounts, &prev)
+			} else {
+				amounts = append(amounts, nil)
+			}
+		}
+		return amounts
+	}
+
+	for _, section := range stmt.Sections {
+		doc.rows = append(doc.rows, row{style: rowHeading, label: section.Title})
+
+		var addItems func(items []statements.LineItem, indent int)
+		addItems = func(items []statements.LineItem, indent int) {
+			for _, item := range items {
+				prev, found := previous[section.Title][item.Label]
+				doc.rows = append(doc.rows, row{
+					style:   rowItem,
+					label:   item.Label,
+					indent:  indent,
+					amounts: amountsFor(item.Amount, prev, found),
+				})
+				addItems(item.SubItems, indent+1)
+			}
+		}
+		addItems(section.Items, 1)
+
+		total := row{style: rowTotal, label: "Total " + section.Title}
+		prev, found := previousTotals[section.Title]
+		total.amounts = amountsFor(section.Total, prev, found)
+		doc.rows = append(doc.rows, total)
+	}
+	return doc
+}
+
+// collectAmounts indexes line item amounts by label
+func collectAmounts(items []statements.LineItem, amounts map[string]money.Money) {
+	for _, item := range items {
+		amounts[item.Label] = item.Amount
+		collectAmounts(item.SubItems, amounts)
+	}
+}
+
+// reportDocument lays out a report's lines indented by level, followed by
+// its totals
+func reportDocument(report *reporting.Report, comparative bool) *document {
+	start := &report.Period.Start
+	if report.Period.Start.IsZero() {
+		start = nil
+	}
+	doc := &document{
+		title:   report.Title,
+		entity:  report.EntityID,
+		period:  periodLabel(start, report.Period.End),
+		columns: []string{columnLabel(report.Period.End)},
+	}
+	if comparative && report.Period.Previous != nil {
+		doc.columns = append(doc.columns, columnLabel(report.Period.Previous.End))
+	}
+
+	for _, line := range flattenLines(report.Lines) {
+		label := line.AccountName
+		if line.AccountCode != "" {
+			label = line.AccountCode + " " + label
+		}
+		amount := line.Amount
+		r := row{style: rowItem, label: label, indent: line.Level + 1, amounts: []*money.Money{&amount}}
+		if len(doc.columns) > 1 {
+			r.amounts = append(r.amounts, line.PreviousAmount)
+		}
+		doc.rows = append(doc.rows, r)
+	}
+
+	names := make([]string, 0, len(report.Totals))
+	for name := range report.Totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		total := report.Totals[name]
+		r := row{style: rowTotal, label: name, amounts: []*money.Money{&total}}
+		if len(doc.columns) > 1 {
+			r.amounts = append(r.amounts, nil)
+		}
+		doc.rows = append(doc.rows, r)
+	}
+	return doc
+}
+
+// periodLabel describes the period a document covers
+func periodLabel(start *time.Time, end time.Time) string {
+	if start == nil {
+		return "As of " + end.Format("January 2, 2006")
+	}
+	return "For the period " + start.Format("January 2, 2006") + " to " + end.Format("January 2, 2006")
+}
+
+// columnLabel heads the amount column of a period
+func columnLabel(end time.Time) string {
+	return end.Format("Jan 2, 2006")
+}
+
+// amountFormat returns the accounting number format for the locale option:
+// no symbol and negatives in parentheses
+func amountFormat(opts map[string]interface{}) money.Format {
+	f := money.DefaultFormat
+	if locale, ok := opts[OptionLocale].(string); ok {
+		if lf, ok := money.LocaleFormat(locale); ok {
+			f = lf
+		}
+	}
+	f.Symbol = money.SymbolNone
+	f.Negative = money.NegativeParentheses
+	return f
+}
@@ -0,0 +1,223 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+)
+
+// Page geometry in points
+const (
+	pdfPageWidth  = 612.0 // US Letter
+	pdfPageHeight = 792.0
+	pdfMargin     = 54.0
+	pdfLineHeight = 14.0
+	pdfFontSize   = 10.0
+	pdfIndent     = 12.0
+	pdfColumn     = 100.0 // width of an amount column
+)
+
+// PDFFormatter renders financial statements and reports as PDF documents
+// using the standard Helvetica fonts, so no fonts are embedded
+type PDFFormatter struct{}
+
+// NewPDFFormatter creates a PDF formatter
+func NewPDFFormatter() *PDFFormatter {
+	return &PDFFormatter{}
+}
+
+// FormatStatement renders a balance sheet, income statement or cash flow
+// statement with its comparative period unless OptionComparative is false
+func (f *PDFFormatter) FormatStatement(ctx context.Context, stmt *statements.Statement, opts map[string]interface{}) ([]byte, error) {
+	if stmt == nil {
+		return nil, fmt.Errorf("statement cannot be nil")
+	}
+	return renderPDF(statementDocument(stmt, boolOption(opts, OptionComparative, true)), opts), nil
+}
+
+// FormatReport implements reporting.ReportFormatter
+func (f *PDFFormatter) FormatReport(ctx context.Context, report *reporting.Report, format string, opts map[string]interface{}) ([]byte, error) {
+	if !strings.EqualFold(format, PDF) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if report == nil {
+		return nil, fmt.Errorf("report cannot be nil")
+	}
+	return renderPDF(reportDocument(report, boolOption(opts, OptionComparative, true)), opts), nil
+}
+
+// GetSupportedFormats implements reporting.ReportFormatter
+func (f *PDFFormatter) GetSupportedFormats(ctx context.Context) ([]string, error) {
+	return []string{PDF}, nil
+}
+
+// pdfPage accumulates the content stream of a page
+type pdfPage struct {
+	content strings.Builder
+}
+
+func (p *pdfPage) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+func (p *pdfPage) rule(x1, x2, y float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y, x2, y)
+}
+
+// renderPDF lays the document out on as many pages as needed. Every page
+// repeats the column headings; the first also carries the title block.
+func renderPDF(doc *document, opts map[string]interface{}) []byte {
+	format := amountFormat(opts)
+	right := pdfPageWidth - pdfMargin
+	columnRight := func(i int) float64 {
+		return right - float64(len(doc.columns)-1-i)*pdfColumn
+	}
+
+	var pages []*pdfPage
+	var page *pdfPage
+	y := 0.0
+	newPage := func() {
+		page = &pdfPage{}
+		pages = append(pages, page)
+		y = pdfPageHeight - pdfMargin
+		if len(pages) == 1 {
+			for _, line := range []string{doc.entity, doc.title, doc.period} {
+				if line == "" {
+					continue
+				}
+				page.text("F2", 12, (pdfPageWidth-textWidth(line, 12))/2, y, line)
+				y -= 16
+			}
+			y -= pdfLineHeight / 2
+		}
+		for i, column := range doc.columns {
+			page.text("F2", pdfFontSize, columnRight(i)-textWidth(column, pdfFontSize), y, column)
+		}
+		page.rule(right-float64(len(doc.columns))*pdfColumn+10, right, y-4)
+		y -= pdfLineHeight * 1.5
+	}
+	newPage()
+
+	for _, r := range doc.rows {
+		if y < pdfMargin {
+			newPage()
+		}
+		font := "F1"
+		if r.style != rowItem {
+			font = "F2"
+		}
+		if r.style == rowHeading && y < pdfPageHeight-pdfMargin-2*pdfLineHeight {
+			y -= pdfLineHeight / 2
+		}
+
+		page.text(font, pdfFontSize, pdfMargin+float64(r.indent)*pdfIndent, y, r.label)
+		for i, amount := range r.amounts {
+			if amount == nil {
+				continue
+			}
+			s := amount.Format(format)
+			if !amount.Amount.IsNegative() {
+				s += " " // keep figures aligned with closing parentheses
+			}
+			x := columnRight(i) - textWidth(s, pdfFontSize)
+			if r.style == rowTotal {
+				page.rule(columnRight(i)-pdfColumn+20, columnRight(i), y+pdfFontSize+1)
+			}
+			page.text(font, pdfFontSize, x, y, s)
+		}
+		y -= pdfLineHeight
+	}
+
+	return assemblePDF(pages)
+}
+
+// assemblePDF writes the PDF file structure: catalog, page tree, fonts and
+// one page and content stream per page, followed by the cross-reference
+// table
+func assemblePDF(pages []*pdfPage) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		content := page.content.String()
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal. Characters outside Latin-1
+// cannot be shown with the standard fonts and are replaced.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r > 255:
+			b.WriteByte('?')
+		case r > 126:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are the Helvetica advance widths of the printable ASCII
+// characters in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+	334, 260, 334, 584, // { to ~
+}
+
+// textWidth approximates the width of a string in points
+func textWidth(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += helveticaWidths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
@@ -0,0 +1,276 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+)
+
+// Cell styles defined in xlsxStyles, by index
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleTitle
+	xlsxStyleBold
+	xlsxStyleHeader
+	xlsxStyleAmount
+	xlsxStyleTotal
+	xlsxStyleIndent1
+	xlsxStyleIndent2
+	xlsxStyleIndent3
+)
+
+// XLSXFormatter renders statements and reports as Excel workbooks. Section
+// totals are written as formulas over their line items, with the computed
+// value cached for readers that do not recalculate.
+type XLSXFormatter struct{}
+
+// NewXLSXFormatter creates an XLSX formatter
+func NewXLSXFormatter() *XLSXFormatter {
+	return &XLSXFormatter{}
+}
+
+// FormatStatements renders a reporting package as a workbook with one sheet
+// per statement, including comparative periods unless OptionComparative is
+// false
+func (f *XLSXFormatter) FormatStatements(ctx context.Context, stmts []*statements.Statement, opts map[string]interface{}) ([]byte, error) {
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("at least one statement is required")
+	}
+	docs := make([]*document, len(stmts))
+	for i, stmt := range stmts {
+		if stmt == nil {
+			return nil, fmt.Errorf("statement %d is nil", i)
+		}
+		docs[i] = statementDocument(stmt, boolOption(opts, OptionComparative, true))
+	}
+	return writeWorkbook(docs)
+}
+
+// FormatReport implements reporting.ReportFormatter
+func (f *XLSXFormatter) FormatReport(ctx context.Context, report *reporting.Report, format string, opts map[string]interface{}) ([]byte, error) {
+	if !strings.EqualFold(format, XLSX) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if report == nil {
+		return nil, fmt.Errorf("report cannot be nil")
+	}
+	return writeWorkbook([]*document{reportDocument(report, boolOption(opts, OptionComparative, true))})
+}
+
+// GetSupportedFormats implements reporting.ReportFormatter
+func (f *XLSXFormatter) GetSupportedFormats(ctx context.Context) ([]string, error) {
+	return []string{XLSX}, nil
+}
+
+// writeWorkbook packages a sheet per document
+func writeWorkbook(docs []*document) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name, content string) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, xml.Header+content)
+		return err
+	}
+
+	var overrides, sheets, rels strings.Builder
+	names := make(map[string]bool)
+	for i, doc := range docs {
+		n := i + 1
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheetName(doc.title, n, names)), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(docs)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, doc := range docs {
+		parts = append(parts, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(doc)})
+	}
+
+	for _, part := range parts {
+		if err := write(part.name, part.content); err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", part.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("error closing workbook: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// worksheet writes the title block, column headings and rows of a document.
+// A section total sums the cells of the section's top-level items.
+func worksheet(doc *document) string {
+	var rows strings.Builder
+	n := 0 // number of the row being written
+	addRow := func(cells string) {
+		fmt.Fprintf(&rows, `<row r="%d">%s</row>`, n, cells)
+	}
+	text := func(col int, s string, style int) string {
+		return fmt.Sprintf(`<c r="%s%d" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, columnName(col), n, style, xmlEscape(s))
+	}
+
+	for _, line := range []string{doc.entity, doc.title, doc.period} {
+		if line != "" {
+			n++
+			addRow(text(0, line, xlsxStyleTitle))
+		}
+	}
+	n++
+	addRow("")
+
+	n++
+	var header strings.Builder
+	header.WriteString(text(0, "", xlsxStyleHeader))
+	for i, column := range doc.columns {
+		header.WriteString(text(i+1, column, xlsxStyleHeader))
+	}
+	addRow(header.String())
+
+	// Worksheet rows of the current section's top-level items; totals of
+	// reports without sections are values only
+	var items []int
+	for _, r := range doc.rows {
+		n++
+		var cells strings.Builder
+		switch r.style {
+		case rowHeading:
+			items = []int{}
+			cells.WriteString(text(0, r.label, xlsxStyleBold))
+		case rowItem:
+			style := xlsxStyleIndent1 + min(r.indent, 3) - 1
+			if r.indent == 0 {
+				style = xlsxStyleDefault
+			}
+			cells.WriteString(text(0, r.label, style))
+		case rowTotal:
+			cells.WriteString(text(0, r.label, xlsxStyleBold))
+		}
+
+		for i, amount := range r.amounts {
+			if amount == nil {
+				continue
+			}
+			ref := fmt.Sprintf("%s%d", columnName(i+1), n)
+			if r.style == rowTotal {
+				formula := ""
+				if len(items) > 0 {
+					formula = "<f>" + sumFormula(columnName(i+1), items) + "</f>"
+				}
+				fmt.Fprintf(&cells, `<c r="%s" s="%d">%s<v>%s</v></c>`, ref, xlsxStyleTotal, formula, amount.Amount.String())
+				continue
+			}
+			fmt.Fprintf(&cells, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleAmount, amount.Amount.String())
+		}
+		addRow(cells.String())
+		if r.style == rowItem && r.indent == 1 && items != nil {
+			items = append(items, n)
+		}
+	}
+
+	cols := `<cols><col min="1" max="1" width="45" customWidth="1"/>`
+	if len(doc.columns) > 0 {
+		cols += fmt.Sprintf(`<col min="2" max="%d" width="16" customWidth="1"/>`, len(doc.columns)+1)
+	}
+	cols += `</cols>`
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		cols + `<sheetData>` + rows.String() + `</sheetData></worksheet>`
+}
+
+// sumFormula sums a column over rows, as a range when they are consecutive
+func sumFormula(column string, rows []int) string {
+	if rows[len(rows)-1]-rows[0] == len(rows)-1 {
+		return fmt.Sprintf("SUM(%s%d:%s%d)", column, rows[0], column, rows[len(rows)-1])
+	}
+	refs := make([]string, len(rows))
+	for i, r := range rows {
+		refs[i] = fmt.Sprintf("%s%d", column, r)
+	}
+	return "SUM(" + strings.Join(refs, ",") + ")"
+}
+
+// columnName converts a zero-based column index to its letters
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes a valid, unique sheet name of at most 31 characters
+func sheetName(title string, n int, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", n)
+	}
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	for base, i := name, 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		runes := []rune(base)
+		if len(runes)+len(suffix) > 31 {
+			runes = runes[:31-len(suffix)]
+		}
+		name = string(runes) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xlsxStyles defines the cell styles: amounts use an accounting format with
+// negatives in parentheses and totals are bold with a top border
+const xlsxStyles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.00_);(#,##0.00)"/></numFmts>` +
+	`<fonts count="3"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="14"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="3"><border/><border><top style="thin"/></border><border><bottom style="thin"/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="9">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="2" xfId="0" applyFont="1" applyBorder="1" applyAlignment="1"><alignment horizontal="right"/></xf>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="1" fillId="0" borderId="1" xfId="0" applyNumberFormat="1" applyFont="1" applyBorder="1"/>` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment indent="1"/></xf>` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment indent="2"/></xf>` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment indent="3"/></xf>` +
+	`</cellXfs></styleSheet>`
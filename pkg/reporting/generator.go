@@ -6,7 +6,11 @@ import (
 	"time"
 
 	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/journal"
 	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/tenant"
 )
 
 // defaultReportGenerator implements the ReportGenerator interface
@@ -25,15 +29,40 @@ func NewReportGenerator(calculator ReportCalculator, storage ReportStorage) Repo
 
 // GenerateReport creates a report based on the definition and options
 func (g *defaultReportGenerator) GenerateReport(ctx context.Context, def *ReportDefinition, opts ReportOptions) (*Report, error) {
+	if err := auth.Require(ctx, auth.ReportGenerate); err != nil {
+		return nil, err
+	}
+
 	if err := g.ValidateDefinition(ctx, def); err != nil {
 		return nil, fmt.Errorf("invalid report definition: %w", err)
 	}
 
+	// A tenant-scoped context can't report on another tenant
+	if err := entity.CheckScope(ctx, opts.EntityID); err != nil {
+		return nil, err
+	}
+
+	// Scope all calculations to the requested entity, defaulting to the
+	// context tenant
+	if opts.EntityID == "" {
+		opts.EntityID = tenant.ID(ctx)
+	}
+	if opts.EntityID != "" {
+		ctx = entity.WithEntity(ctx, opts.EntityID)
+	}
+	if opts.JournalID != "" {
+		ctx = journal.WithJournal(ctx, opts.JournalID)
+	}
+	if opts.DateBasis != "" {
+		ctx = WithDateBasis(ctx, opts.DateBasis)
+	}
+
 	report := &Report{
 		ID:          generateReportID(),
 		Type:        def.Type,
 		Title:       def.Name,
 		Period:      opts.Period,
+		EntityID:    opts.EntityID,
 		Currency:    opts.Currency,
 		GeneratedAt: time.Now(),
 		Lines:       make([]*ReportLine, 0),
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+)
+
+// RevenueMTD is revenue recognised from the first of the month to date
+func RevenueMTD(revenueAccountIDs ...string) Definition {
+	return Definition{
+		Name:        "revenue_mtd",
+		Description: "Revenue month to date",
+		Unit:        Amount,
+		Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+			y, m, _ := asOf.Date()
+			period := reporting.ReportPeriod{
+				Start: time.Date(y, m, 1, 0, 0, 0, 0, asOf.Location()),
+				End:   asOf,
+			}
+			return sum(ctx, calc, revenueAccountIDs, period)
+		},
+	}
+}
+
+// BurnRate is the average monthly decrease in cash over the trailing months.
+// A negative burn rate means cash is growing.
+func BurnRate(cashAccountIDs []string, months int) Definition {
+	return Definition{
+		Name:        "burn_rate",
+		Description: fmt.Sprintf("Average monthly cash burn over %d months", months),
+		Unit:        Amount,
+		Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+			return burn(ctx, calc, cashAccountIDs, months, asOf)
+		},
+	}
+}
+
+// Runway is the number of months the current cash balance lasts at the
+// trailing burn rate
+func Runway(cashAccountIDs []string, months int) Definition {
+	return Definition{
+		Name:        "runway",
+		Description: fmt.Sprintf("Months of cash remaining at the %d month burn rate", months),
+		Unit:        Months,
+		Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+			rate, err := burn(ctx, calc, cashAccountIDs, months, asOf)
+			if err != nil {
+				return decimal.Zero, err
+			}
+			if !rate.IsPositive() {
+				return decimal.Zero, fmt.Errorf("%w: cash is not decreasing", ErrNotComputable)
+			}
+			cash, err := sum(ctx, calc, cashAccountIDs, reporting.ReportPeriod{End: asOf})
+			if err != nil {
+				return decimal.Zero, err
+			}
+			return cash.Div(rate).Round(1), nil
+		},
+	}
+}
+
+// DSO is days sales outstanding: the closing receivables balance divided by
+// revenue over the trailing days, expressed in days
+func DSO(receivableAccountIDs, revenueAccountIDs []string, days int) Definition {
+	return Definition{
+		Name:        "dso",
+		Description: fmt.Sprintf("Days sales outstanding over %d days", days),
+		Unit:        Days,
+		Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+			receivables, err := sum(ctx, calc, receivableAccountIDs, reporting.ReportPeriod{End: asOf})
+			if err != nil {
+				return decimal.Zero, err
+			}
+			revenue, err := sum(ctx, calc, revenueAccountIDs, reporting.ReportPeriod{
+				Start: asOf.AddDate(0, 0, -days),
+				End:   asOf,
+			})
+			if err != nil {
+				return decimal.Zero, err
+			}
+			if revenue.IsZero() {
+				return decimal.Zero, fmt.Errorf("%w: no revenue in period", ErrNotComputable)
+			}
+			return receivables.Div(revenue).Mul(decimal.NewFromInt(int64(days))).Round(1), nil
+		},
+	}
+}
+
+// FromRatio exposes a ratio from the calculator's ratio library as a KPI,
+// evaluated over the trailing days
+func FromRatio(ratio reporting.RatioDefinition, days int) Definition {
+	return Definition{
+		Name:        ratio.ID,
+		Description: ratio.Description,
+		Unit:        Ratio,
+		Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+			return calc.CalculateRatio(ctx, ratio, reporting.ReportPeriod{
+				Start: asOf.AddDate(0, 0, -days),
+				End:   asOf,
+			})
+		},
+	}
+}
+
+func burn(ctx context.Context, calc reporting.ReportCalculator, cashAccountIDs []string, months int, asOf time.Time) (decimal.Decimal, error) {
+	if months <= 0 {
+		return decimal.Zero, fmt.Errorf("%w: burn rate needs at least one month", ErrNotComputable)
+	}
+	opening, err := sum(ctx, calc, cashAccountIDs, reporting.ReportPeriod{End: asOf.AddDate(0, -months, 0)})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	closing, err := sum(ctx, calc, cashAccountIDs, reporting.ReportPeriod{End: asOf})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return opening.Sub(closing).Div(decimal.NewFromInt(int64(months))).Round(2), nil
+}
+
+// sum adds the balances of the accounts over the period
+func sum(ctx context.Context, calc reporting.ReportCalculator, accountIDs []string, period reporting.ReportPeriod) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, id := range accountIDs {
+		balance, err := calc.CalculateBalance(ctx, id, period)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("error calculating balance for account %s: %w", id, err)
+		}
+		total = total.Add(balance.Amount)
+	}
+	return total, nil
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type movement struct {
+	date   time.Time
+	amount int64
+}
+
+// fakeCalculator sums dated movements per account
+type fakeCalculator struct {
+	reporting.ReportCalculator
+	movements map[string][]movement
+	calls     int
+}
+
+func (c *fakeCalculator) CalculateBalance(ctx context.Context, accountID string, period reporting.ReportPeriod) (money.Money, error) {
+	c.calls++
+	total := decimal.Zero
+	for _, m := range c.movements[accountID] {
+		if m.date.Before(period.Start) || m.date.After(period.End) {
+			continue
+		}
+		total = total.Add(decimal.NewFromInt(m.amount))
+	}
+	return money.Money{Amount: total, Currency: "USD"}, nil
+}
+
+func (c *fakeCalculator) CalculateRatio(ctx context.Context, ratio reporting.RatioDefinition, period reporting.ReportPeriod) (decimal.Decimal, error) {
+	return decimal.NewFromFloat(1.5), nil
+}
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	calc := &fakeCalculator{movements: map[string][]movement{
+		"cash": {
+			{day(time.January, 1), 10000},
+			{day(time.February, 15), -2000},
+			{day(time.March, 15), -2000},
+			{day(time.April, 15), -2000},
+		},
+		"revenue": {
+			{day(time.March, 20), 3000},
+			{day(time.April, 2), 900},
+			{day(time.April, 10), 600},
+		},
+		"receivables": {
+			{day(time.April, 10), 1000},
+		},
+	}}
+
+	service := NewService(calc, time.Hour)
+	now := day(time.April, 30).Add(12 * time.Hour)
+	service.now = func() time.Time { return now }
+
+	cash := []string{"cash"}
+	require.NoError(t, service.Register(RevenueMTD("revenue")))
+	require.NoError(t, service.Register(BurnRate(cash, 3)))
+	require.NoError(t, service.Register(Runway(cash, 3)))
+	require.NoError(t, service.Register(DSO([]string{"receivables"}, []string{"revenue"}, 30)))
+	require.NoError(t, service.Register(FromRatio(reporting.RatioDefinition{ID: "current_ratio"}, 30)))
+	assert.ErrorIs(t, service.Register(Definition{Name: "broken"}), ErrInvalidKPI)
+	assert.Len(t, service.Names(), 5)
+
+	asOf := day(time.April, 30).Add(9 * time.Hour)
+	tests := map[string]string{
+		"revenue_mtd":   "1500",
+		"burn_rate":     "2000",
+		"runway":        "2",
+		"dso":           "20",
+		"current_ratio": "1.5",
+	}
+	for name, expected := range tests {
+		value, err := service.GetKPI(ctx, name, asOf)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, value.Value.String(), name)
+		assert.Equal(t, day(time.April, 30), value.AsOf)
+		assert.False(t, value.Cached)
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		calls := calc.calls
+		value, err := service.GetKPI(ctx, "revenue_mtd", asOf.Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, value.Cached)
+		assert.Equal(t, calls, calc.calls)
+
+		// Other tenants and days are cached separately
+		_, err = service.GetKPI(tenant.WithTenant(ctx, "acme"), "revenue_mtd", asOf)
+		require.NoError(t, err)
+		assert.Greater(t, calc.calls, calls)
+	})
+
+	t.Run("expiry and invalidation", func(t *testing.T) {
+		calls := calc.calls
+		now = now.Add(2 * time.Hour)
+		value, err := service.GetKPI(ctx, "revenue_mtd", asOf)
+		require.NoError(t, err)
+		assert.False(t, value.Cached)
+		assert.Greater(t, calc.calls, calls)
+
+		service.Invalidate("revenue_mtd")
+		value, err = service.GetKPI(ctx, "revenue_mtd", asOf)
+		require.NoError(t, err)
+		assert.False(t, value.Cached)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		service.Invalidate()
+		require.NoError(t, service.Refresh(ctx, asOf))
+		for name := range tests {
+			value, err := service.GetKPI(ctx, name, asOf)
+			require.NoError(t, err)
+			assert.True(t, value.Cached, name)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := service.GetKPI(ctx, "ebitda", asOf)
+		assert.ErrorIs(t, err, ErrUnknownKPI)
+
+		_, err = service.GetKPI(ctx, "runway", day(time.January, 31))
+		assert.ErrorIs(t, err, ErrNotComputable)
+
+		// A KPI that cannot be computed doesn't keep the others cold
+		require.NoError(t, service.Register(Definition{
+			Name: "backlog",
+			Compute: func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error) {
+				return decimal.Zero, ErrNotComputable
+			},
+		}))
+		err = service.Refresh(ctx, asOf)
+		assert.ErrorIs(t, err, ErrNotComputable)
+		for name := range tests {
+			value, err := service.GetKPI(ctx, name, asOf)
+			require.NoError(t, err)
+			assert.True(t, value.Cached, name)
+		}
+	})
+}
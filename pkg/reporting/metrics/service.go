@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// DefaultTTL is how long computed KPIs are cached when no TTL is configured
+const DefaultTTL = 15 * time.Minute
+
+// Service computes registered KPIs and caches the results per tenant and day
+type Service struct {
+	mu          sync.RWMutex
+	calculator  reporting.ReportCalculator
+	definitions map[string]Definition
+	cache       map[cacheKey]*Value
+	ttl         time.Duration
+	now         func() time.Time
+}
+
+type cacheKey struct {
+	tenant string
+	name   string
+	day    string
+}
+
+// NewService creates a KPI service over a report calculator. A zero ttl uses
+// DefaultTTL.
+func NewService(calculator reporting.ReportCalculator, ttl time.Duration) *Service {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Service{
+		calculator:  calculator,
+		definitions: make(map[string]Definition),
+		cache:       make(map[cacheKey]*Value),
+		ttl:         ttl,
+		now:         time.Now,
+	}
+}
+
+// Register adds or replaces a KPI definition and drops its cached values
+func (s *Service) Register(def Definition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.definitions[def.Name] = def
+	s.invalidate(def.Name)
+	return nil
+}
+
+// Names returns the registered KPI names in sorted order
+func (s *Service) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.definitions))
+	for name := range s.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetKPI returns the named KPI as of the end of the given day, computing it
+// only when no fresh value is cached
+func (s *Service) GetKPI(ctx context.Context, name string, asOf time.Time) (*Value, error) {
+	s.mu.RLock()
+	def, ok := s.definitions[name]
+	cached := s.cache[keyFor(ctx, name, asOf)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKPI, name)
+	}
+
+	if cached != nil && s.now().Sub(cached.ComputedAt) < s.ttlFor(def) {
+		value := *cached
+		value.Cached = true
+		return &value, nil
+	}
+	return s.compute(ctx, def, asOf)
+}
+
+// Refresh recomputes every registered KPI for the given day. It is intended
+// to be called on a schedule so dashboards always read warm values. A KPI
+// that fails, such as one that is not computable for the day, does not stop
+// the others; the failures are returned joined.
+func (s *Service) Refresh(ctx context.Context, asOf time.Time) error {
+	var errs []error
+	for _, name := range s.Names() {
+		s.mu.RLock()
+		def, ok := s.definitions[name]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if _, err := s.compute(ctx, def, asOf); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run refreshes all KPIs for the current day every interval until the
+// context is cancelled
+func (s *Service) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Individual KPI failures are retried on the next tick
+		_ = s.Refresh(ctx, s.now())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Invalidate drops cached values for the named KPIs, or for all KPIs when no
+// names are given. Call it after posting entries that affect the KPIs.
+func (s *Service) Invalidate(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(names) == 0 {
+		s.cache = make(map[cacheKey]*Value)
+		return
+	}
+	for _, name := range names {
+		s.invalidate(name)
+	}
+}
+
+func (s *Service) invalidate(name string) {
+	for key := range s.cache {
+		if key.name == name {
+			delete(s.cache, key)
+		}
+	}
+}
+
+func (s *Service) compute(ctx context.Context, def Definition, asOf time.Time) (*Value, error) {
+	day := startOfDay(asOf)
+	result, err := def.Compute(ctx, s.calculator, endOfDay(asOf))
+	if err != nil {
+		return nil, fmt.Errorf("error computing %s: %w", def.Name, err)
+	}
+
+	value := &Value{
+		Name:       def.Name,
+		Value:      result,
+		Unit:       def.Unit,
+		AsOf:       day,
+		ComputedAt: s.now(),
+	}
+
+	s.mu.Lock()
+	s.cache[keyFor(ctx, def.Name, asOf)] = value
+	s.mu.Unlock()
+
+	copied := *value
+	return &copied, nil
+}
+
+func (s *Service) ttlFor(def Definition) time.Duration {
+	if def.TTL > 0 {
+		return def.TTL
+	}
+	return s.ttl
+}
+
+func keyFor(ctx context.Context, name string, asOf time.Time) cacheKey {
+	return cacheKey{
+		tenant: tenant.ID(ctx),
+		name:   name,
+		day:    asOf.Format("2006-01-02"),
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func endOfDay(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
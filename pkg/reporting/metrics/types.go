@@ -0,0 +1,66 @@
+// Package metrics computes named key performance indicators from the report
+// calculator and caches them so dashboards can read them without generating
+// full reports.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownKPI    = errors.New("unknown KPI")
+	ErrInvalidKPI    = errors.New("invalid KPI definition")
+	ErrNotComputable = errors.New("KPI cannot be computed")
+)
+
+// Unit describes how a KPI value should be read
+type Unit string
+
+const (
+	Amount Unit = "AMOUNT"
+	Days   Unit = "DAYS"
+	Months Unit = "MONTHS"
+	Ratio  Unit = "RATIO"
+)
+
+// ComputeFunc calculates a KPI as of the end of the given day
+type ComputeFunc func(ctx context.Context, calc reporting.ReportCalculator, asOf time.Time) (decimal.Decimal, error)
+
+// Definition describes a named KPI
+type Definition struct {
+	Name        string
+	Description string
+	Unit        Unit
+	// How long a computed value stays fresh; zero uses the service default
+	TTL     time.Duration
+	Compute ComputeFunc
+}
+
+// Validate checks that the definition can be registered
+func (d Definition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidKPI)
+	}
+	if d.Compute == nil {
+		return fmt.Errorf("%w: %s has no compute function", ErrInvalidKPI, d.Name)
+	}
+	return nil
+}
+
+// Value is a computed KPI
+type Value struct {
+	Name  string          `json:"name"`
+	Value decimal.Decimal `json:"value"`
+	Unit  Unit            `json:"unit"`
+	// Day the KPI was computed for
+	AsOf       time.Time `json:"as_of"`
+	ComputedAt time.Time `json:"computed_at"`
+	// Whether the value was served from the cache
+	Cached bool `json:"cached"`
+}
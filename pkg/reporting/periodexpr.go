@@ -0,0 +1,71 @@
+package reporting
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPeriodExpression = errors.New("invalid period expression")
+)
+
+// ParsePeriod resolves a relative period expression against a time. The
+// supported expressions are "today", "yesterday", "this week", "last week",
+// "this month", "last month", "month to date", "this quarter", "last
+// quarter", "quarter to date", "this year", "last year", "year to date" and
+// "last N days". Weeks start on Monday, and "last N days" ends yesterday.
+// Period ends are inclusive, one nanosecond before the next period starts.
+func ParsePeriod(expr string, now time.Time) (ReportPeriod, error) {
+	e := strings.Join(strings.Fields(strings.ToLower(expr)), " ")
+	y, m, d := now.Date()
+	loc := now.Location()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	week := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	month := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	quarter := time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, loc)
+	year := time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+
+	span := func(start, next time.Time) ReportPeriod {
+		return ReportPeriod{Start: start, End: next.Add(-time.Nanosecond)}
+	}
+
+	switch e {
+	case "today":
+		return span(today, today.AddDate(0, 0, 1)), nil
+	case "yesterday":
+		return span(today.AddDate(0, 0, -1), today), nil
+	case "this week":
+		return span(week, week.AddDate(0, 0, 7)), nil
+	case "last week":
+		return span(week.AddDate(0, 0, -7), week), nil
+	case "this month":
+		return span(month, month.AddDate(0, 1, 0)), nil
+	case "last month":
+		return span(month.AddDate(0, -1, 0), month), nil
+	case "month to date":
+		return ReportPeriod{Start: month, End: now}, nil
+	case "this quarter":
+		return span(quarter, quarter.AddDate(0, 3, 0)), nil
+	case "last quarter":
+		return span(quarter.AddDate(0, -3, 0), quarter), nil
+	case "quarter to date":
+		return ReportPeriod{Start: quarter, End: now}, nil
+	case "this year":
+		return span(year, year.AddDate(1, 0, 0)), nil
+	case "last year":
+		return span(year.AddDate(-1, 0, 0), year), nil
+	case "year to date":
+		return ReportPeriod{Start: year, End: now}, nil
+	}
+
+	if fields := strings.Fields(e); len(fields) == 3 && fields[0] == "last" && fields[2] == "days" {
+		n, err := strconv.Atoi(fields[1])
+		if err == nil && n > 0 {
+			return span(today.AddDate(0, 0, -n), today), nil
+		}
+	}
+	return ReportPeriod{}, fmt.Errorf("%w: %q", ErrInvalidPeriodExpression, expr)
+}
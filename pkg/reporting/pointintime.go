@@ -0,0 +1,186 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// BalanceSnapshot records an account balance at a point in time. A snapshot
+// covers every posted transaction dated at or before AsOf.
+type BalanceSnapshot struct {
+	AccountID string      `json:"account_id"`
+	AsOf      time.Time   `json:"as_of"`
+	Balance   money.Money `json:"balance"`
+	TakenAt   time.Time   `json:"taken_at"`
+}
+
+// SnapshotStore persists balance snapshots
+type SnapshotStore interface {
+	// SaveSnapshot stores a snapshot, replacing any snapshot of the same
+	// account at the same time
+	SaveSnapshot(ctx context.Context, snapshot *BalanceSnapshot) error
+
+	// LatestSnapshot returns the most recent snapshot of an account taken at
+	// or before a time, or nil when there is none
+	LatestSnapshot(ctx context.Context, accountID string, at time.Time) (*BalanceSnapshot, error)
+
+	// DeleteSnapshotsAfter removes snapshots of an account taken after a
+	// time, e.g. when a back-dated transaction invalidates them
+	DeleteSnapshotsAfter(ctx context.Context, accountID string, after time.Time) error
+}
+
+// MemorySnapshotStore provides an in-memory implementation of SnapshotStore
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string][]*BalanceSnapshot // per account, ordered by AsOf
+}
+
+// NewMemorySnapshotStore creates a new in-memory snapshot store
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string][]*BalanceSnapshot)}
+}
+
+// SaveSnapshot implements SnapshotStore.SaveSnapshot
+func (s *MemorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot *BalanceSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.snapshots[snapshot.AccountID]
+	i := sort.Search(len(list), func(i int) bool { return !list[i].AsOf.Before(snapshot.AsOf) })
+	if i < len(list) && list[i].AsOf.Equal(snapshot.AsOf) {
+		list[i] = snapshot
+		return nil
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = snapshot
+	s.snapshots[snapshot.AccountID] = list
+	return nil
+}
+
+// LatestSnapshot implements SnapshotStore.LatestSnapshot
+func (s *MemorySnapshotStore) LatestSnapshot(ctx context.Context, accountID string, at time.Time) (*BalanceSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.snapshots[accountID]
+	i := sort.Search(len(list), func(i int) bool { return list[i].AsOf.After(at) })
+	if i == 0 {
+		return nil, nil
+	}
+	return list[i-1], nil
+}
+
+// DeleteSnapshotsAfter implements SnapshotStore.DeleteSnapshotsAfter
+func (s *MemorySnapshotStore) DeleteSnapshotsAfter(ctx context.Context, accountID string, after time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.snapshots[accountID]
+	i := sort.Search(len(list), func(i int) bool { return list[i].AsOf.After(after) })
+	s.snapshots[accountID] = list[:i]
+	return nil
+}
+
+// PointInTimeCalculator is a ReportCalculator that reconstructs balances at
+// any historical time from the latest snapshot plus the transactions posted
+// since, instead of scanning from the beginning of time. Snapshots are
+// taken periodically, typically at each period close, with TakeSnapshots.
+type PointInTimeCalculator struct {
+	*defaultReportCalculator
+}
+
+// NewPointInTimeCalculator creates a calculator backed by a snapshot store
+func NewPointInTimeCalculator(
+	accountStore account.Repository,
+	transactionProc transaction.TransactionProcessor,
+	transactionStore storage.Repository,
+	snapshots SnapshotStore,
+) *PointInTimeCalculator {
+	return &PointInTimeCalculator{
+		defaultReportCalculator: &defaultReportCalculator{
+			accountStore:     accountStore,
+			transactionProc:  transactionProc,
+			transactionStore: transactionStore,
+			snapshots:        snapshots,
+		},
+	}
+}
+
+// BalanceAt returns the balance of an account at a point in time
+func (c *PointInTimeCalculator) BalanceAt(ctx context.Context, accountID string, at time.Time) (money.Money, error) {
+	return c.getBalanceAtTime(ctx, accountID, at)
+}
+
+// TakeSnapshots records the balances of accounts at a point in time. Each
+// balance is itself built from the previous snapshot, so taking snapshots
+// at regular intervals only reads the transactions of each interval.
+func (c *PointInTimeCalculator) TakeSnapshots(ctx context.Context, at time.Time, accountIDs []string) ([]*BalanceSnapshot, error) {
+	snapshots := make([]*BalanceSnapshot, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		balance, err := c.getBalanceAtTime(ctx, accountID, at)
+		if err != nil {
+			return snapshots, fmt.Errorf("error calculating balance for account %s: %w", accountID, err)
+		}
+
+		snapshot := &BalanceSnapshot{
+			AccountID: accountID,
+			AsOf:      at,
+			Balance:   balance,
+			TakenAt:   time.Now(),
+		}
+		if err := c.snapshots.SaveSnapshot(ctx, snapshot); err != nil {
+			return snapshots, fmt.Errorf("error saving snapshot for account %s: %w", accountID, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// TakePeriodEndSnapshots records balances at each period end in turn, so
+// every period only reads its own transactions. Without account IDs every
+// account in the context entity is snapshotted.
+func (c *PointInTimeCalculator) TakePeriodEndSnapshots(ctx context.Context, periodEnds []time.Time, accountIDs []string) ([]*BalanceSnapshot, error) {
+	if len(accountIDs) == 0 {
+		var accounts []*account.Account
+		if err := c.accountStore.Query(ctx, entity.ScopeQuery(ctx, storage.Query{}), &accounts); err != nil {
+			return nil, fmt.Errorf("error querying accounts: %w", err)
+		}
+		for _, acc := range accounts {
+			accountIDs = append(accountIDs, acc.ID)
+		}
+	}
+
+	ends := append([]time.Time(nil), periodEnds...)
+	sort.Slice(ends, func(i, j int) bool { return ends[i].Before(ends[j]) })
+
+	snapshots := make([]*BalanceSnapshot, 0, len(ends)*len(accountIDs))
+	for _, end := range ends {
+		taken, err := c.TakeSnapshots(ctx, end, accountIDs)
+		snapshots = append(snapshots, taken...)
+		if err != nil {
+			return snapshots, err
+		}
+	}
+	return snapshots, nil
+}
+
+// Invalidate discards snapshots made stale by a transaction dated at or
+// before them, such as a back-dated adjustment
+func (c *PointInTimeCalculator) Invalidate(ctx context.Context, tx *transaction.Transaction) error {
+	for _, entry := range tx.Entries {
+		if err := c.snapshots.DeleteSnapshotsAfter(ctx, entry.AccountID, tx.Date.Add(-time.Nanosecond)); err != nil {
+			return fmt.Errorf("error invalidating snapshots for account %s: %w", entry.AccountID, err)
+		}
+	}
+	return nil
+}
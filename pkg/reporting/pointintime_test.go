@@ -0,0 +1,167 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// datedTransactionStore applies the calculator's date filters and counts
+// the transactions it returns
+type datedTransactionStore struct {
+	storage.Repository
+	txs     []*transaction.Transaction
+	scanned int
+}
+
+func (s *datedTransactionStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	out := results.(*[]*transaction.Transaction)
+	for _, tx := range s.txs {
+		matches := true
+		for _, f := range query.Filters {
+			bound, ok := f.Value.(time.Time)
+			if !ok || f.Field != "date" {
+				continue
+			}
+			if (f.Operator == ">=" && tx.Date.Before(bound)) || (f.Operator == "<=" && tx.Date.After(bound)) {
+				matches = false
+			}
+		}
+		if matches {
+			*out = append(*out, tx)
+			s.scanned++
+		}
+	}
+	return nil
+}
+
+// staticAccountStore returns a single asset account
+type staticAccountStore struct {
+	account.Repository
+}
+
+func (s *staticAccountStore) Read(ctx context.Context, id string, entity interface{}) error {
+	*(entity.(*account.Account)) = account.Account{ID: id, Type: account.Asset}
+	return nil
+}
+
+func TestPointInTimeCalculator(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &datedTransactionStore{}
+	for day := 0; day < 90; day++ {
+		amount := money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}
+		store.txs = append(store.txs, &transaction.Transaction{
+			ID:     "TX",
+			Status: transaction.Posted,
+			Date:   start.AddDate(0, 0, day),
+			Entries: []transaction.Entry{
+				{AccountID: "1000", Amount: amount, Type: transaction.Debit},
+				{AccountID: "4000", Amount: amount, Type: transaction.Credit},
+			},
+		})
+	}
+
+	snapshots := NewMemorySnapshotStore()
+	calculator := NewPointInTimeCalculator(&staticAccountStore{}, nil, store, snapshots)
+
+	endOfJanuary := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+	endOfFebruary := time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)
+	_, err := calculator.TakeSnapshots(ctx, endOfJanuary, []string{"1000"})
+	require.NoError(t, err)
+	_, err = calculator.TakeSnapshots(ctx, endOfFebruary, []string{"1000"})
+	require.NoError(t, err)
+
+	t.Run("balance from snapshot plus deltas", func(t *testing.T) {
+		store.scanned = 0
+		balance, err := calculator.BalanceAt(ctx, "1000", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, "650", balance.Amount.String()) // 65 days of 10
+		assert.Equal(t, 5, store.scanned)
+	})
+
+	t.Run("calculator interface uses snapshots", func(t *testing.T) {
+		balance, err := calculator.CalculateBalance(ctx, "1000", ReportPeriod{End: endOfFebruary})
+		require.NoError(t, err)
+		assert.Equal(t, "600", balance.Amount.String())
+	})
+
+	t.Run("balance before any snapshot", func(t *testing.T) {
+		balance, err := calculator.BalanceAt(ctx, "1000", time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, "100", balance.Amount.String())
+	})
+
+	t.Run("back-dated transactions invalidate later snapshots", func(t *testing.T) {
+		require.NoError(t, calculator.Invalidate(ctx, &transaction.Transaction{
+			Date:    time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC),
+			Entries: []transaction.Entry{{AccountID: "1000"}},
+		}))
+		latest, err := snapshots.LatestSnapshot(ctx, "1000", endOfFebruary)
+		require.NoError(t, err)
+		require.NotNil(t, latest)
+		assert.Equal(t, endOfJanuary, latest.AsOf)
+	})
+}
+
+func TestRepositorySnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &datedTransactionStore{}
+	for day := 0; day < 60; day++ {
+		amount := money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}
+		store.txs = append(store.txs, &transaction.Transaction{
+			ID:     "TX",
+			Status: transaction.Posted,
+			Date:   start.AddDate(0, 0, day),
+			Entries: []transaction.Entry{
+				{AccountID: "1000", Amount: amount, Type: transaction.Debit},
+				{AccountID: "4000", Amount: amount, Type: transaction.Credit},
+			},
+		})
+	}
+
+	snapshots := NewRepositorySnapshotStore(memory.NewMemoryStore())
+	calculator := NewPointInTimeCalculator(&staticAccountStore{}, nil, store, snapshots)
+
+	endOfJanuary := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+	endOfFebruary := time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)
+	taken, err := calculator.TakePeriodEndSnapshots(ctx, []time.Time{endOfFebruary, endOfJanuary}, []string{"1000"})
+	require.NoError(t, err)
+	require.Len(t, taken, 2)
+	assert.Equal(t, "310", taken[0].Balance.Amount.String())
+	assert.Equal(t, "600", taken[1].Balance.Amount.String())
+
+	latest, err := snapshots.LatestSnapshot(ctx, "1000", endOfFebruary.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, endOfFebruary, latest.AsOf)
+	assert.Equal(t, "600", latest.Balance.Amount.String())
+
+	// Saving again replaces the snapshot
+	latest.Balance.Amount = decimal.NewFromInt(1)
+	require.NoError(t, snapshots.SaveSnapshot(ctx, latest))
+	replaced, err := snapshots.LatestSnapshot(ctx, "1000", endOfFebruary)
+	require.NoError(t, err)
+	assert.Equal(t, "1", replaced.Balance.Amount.String())
+
+	require.NoError(t, snapshots.DeleteSnapshotsAfter(ctx, "1000", endOfJanuary))
+	latest, err = snapshots.LatestSnapshot(ctx, "1000", endOfFebruary)
+	require.NoError(t, err)
+	assert.Equal(t, endOfJanuary, latest.AsOf)
+
+	none, err := snapshots.LatestSnapshot(ctx, "2000", endOfFebruary)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
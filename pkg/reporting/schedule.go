@@ -0,0 +1,312 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("report schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid report schedule")
+)
+
+// Frequency is the interval between runs of a report schedule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// next returns the run after t
+func (f Frequency) next(t time.Time) time.Time {
+	switch f {
+	case Daily:
+		return t.AddDate(0, 0, 1)
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+// Schedule describes a report generated and delivered on a recurring basis
+type Schedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Stored report definition to generate
+	DefinitionID string `json:"definition_id"`
+	// Relative period expression resolved at each run, see ParsePeriod
+	Period string `json:"period"`
+	// Output format passed to the formatter, e.g. "PDF"
+	Format        string                 `json:"format"`
+	FormatOptions map[string]interface{} `json:"format_options,omitempty"`
+	// Where the artifact should be delivered, e.g. an email address or
+	// bucket path; delivery is left to the caller
+	Destination string     `json:"destination"`
+	EntityID    string     `json:"entity_id,omitempty"`
+	Currency    string     `json:"currency,omitempty"`
+	Frequency   Frequency  `json:"frequency"`
+	NextRun     time.Time  `json:"next_run"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	Active      bool       `json:"active"`
+	Created     time.Time  `json:"created"`
+}
+
+// GetID returns the schedule identifier
+func (s *Schedule) GetID() string { return s.ID }
+
+// Artifact is a formatted report produced by a scheduled run
+type Artifact struct {
+	ScheduleID  string       `json:"schedule_id"`
+	ReportID    string       `json:"report_id"`
+	Period      ReportPeriod `json:"period"`
+	Format      string       `json:"format"`
+	Destination string       `json:"destination"`
+	Content     []byte       `json:"content"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// ScheduleStore persists report schedules
+type ScheduleStore interface {
+	// SaveSchedule stores a schedule, replacing any schedule with the same ID
+	SaveSchedule(ctx context.Context, schedule *Schedule) error
+
+	// GetSchedule retrieves a schedule by ID
+	GetSchedule(ctx context.Context, id string) (*Schedule, error)
+
+	// DeleteSchedule removes a schedule by ID
+	DeleteSchedule(ctx context.Context, id string) error
+
+	// ListSchedules retrieves all stored schedules
+	ListSchedules(ctx context.Context) ([]*Schedule, error)
+}
+
+// MemoryScheduleStore provides an in-memory implementation of ScheduleStore
+type MemoryScheduleStore struct {
+	mu        sync.RWMutex
+	schedules map[string]*Schedule
+}
+
+// NewMemoryScheduleStore creates a new in-memory schedule store
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{schedules: make(map[string]*Schedule)}
+}
+
+// SaveSchedule implements ScheduleStore.SaveSchedule
+func (s *MemoryScheduleStore) SaveSchedule(ctx context.Context, schedule *Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *schedule
+	s.schedules[schedule.ID] = &stored
+	return nil
+}
+
+// GetSchedule implements ScheduleStore.GetSchedule
+func (s *MemoryScheduleStore) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	result := *schedule
+	return &result, nil
+}
+
+// DeleteSchedule implements ScheduleStore.DeleteSchedule
+func (s *MemoryScheduleStore) DeleteSchedule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	delete(s.schedules, id)
+	return nil
+}
+
+// ListSchedules implements ScheduleStore.ListSchedules
+func (s *MemoryScheduleStore) ListSchedules(ctx context.Context) ([]*Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	results := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		result := *schedule
+		results = append(results, &result)
+	}
+	return results, nil
+}
+
+// ReportScheduler generates reports on their schedules. Each run publishes
+// a report.generated or report.failed event.
+type ReportScheduler struct {
+	schedules ScheduleStore
+	generator ReportGenerator
+	formatter ReportFormatter
+	publisher event.Publisher
+}
+
+// NewReportScheduler creates a report scheduler. The publisher may be nil.
+func NewReportScheduler(schedules ScheduleStore, generator ReportGenerator, formatter ReportFormatter, publisher event.Publisher) *ReportScheduler {
+	return &ReportScheduler{
+		schedules: schedules,
+		generator: generator,
+		formatter: formatter,
+		publisher: publisher,
+	}
+}
+
+// AddSchedule validates and stores a new schedule. A schedule without a
+// next run time is due immediately.
+func (s *ReportScheduler) AddSchedule(ctx context.Context, schedule *Schedule) error {
+	if err := validateSchedule(schedule); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if schedule.ID == "" {
+		schedule.ID = fmt.Sprintf("SCH_%d", now.UnixNano())
+	}
+	if schedule.NextRun.IsZero() {
+		schedule.NextRun = now
+	}
+	schedule.Created = now
+
+	if err := s.schedules.SaveSchedule(ctx, schedule); err != nil {
+		return fmt.Errorf("failed to store schedule: %w", err)
+	}
+	return nil
+}
+
+// RemoveSchedule deletes a schedule
+func (s *ReportScheduler) RemoveSchedule(ctx context.Context, id string) error {
+	return s.schedules.DeleteSchedule(ctx, id)
+}
+
+// RunDue runs every active schedule whose next run is at or before now, in
+// next-run order, and returns the artifacts produced. A schedule that missed
+// several runs runs once. Failed runs do not stop the others; their errors
+// are returned together after every due schedule has run.
+func (s *ReportScheduler) RunDue(ctx context.Context, now time.Time) ([]*Artifact, error) {
+	schedules, err := s.schedules.ListSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying schedules: %w", err)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].NextRun.Before(schedules[j].NextRun) })
+
+	artifacts := make([]*Artifact, 0)
+	var errs []error
+	for _, schedule := range schedules {
+		if !schedule.Active || schedule.NextRun.After(now) {
+			continue
+		}
+
+		artifact, runErr := s.run(ctx, schedule, now)
+		if runErr != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %w", schedule.ID, runErr))
+			s.publish(ctx, event.ReportFailed, schedule, "", runErr, now)
+		} else {
+			artifacts = append(artifacts, artifact)
+			s.publish(ctx, event.ReportGenerated, schedule, artifact.ReportID, nil, now)
+		}
+
+		ran := now
+		schedule.LastRun = &ran
+		for !schedule.NextRun.After(now) {
+			schedule.NextRun = schedule.Frequency.next(schedule.NextRun)
+		}
+		if err := s.schedules.SaveSchedule(ctx, schedule); err != nil {
+			errs = append(errs, fmt.Errorf("failed to store schedule %s: %w", schedule.ID, err))
+		}
+	}
+	return artifacts, errors.Join(errs...)
+}
+
+// run generates and formats the report of a schedule
+func (s *ReportScheduler) run(ctx context.Context, schedule *Schedule, now time.Time) (*Artifact, error) {
+	period, err := ParsePeriod(schedule.Period, now)
+	if err != nil {
+		return nil, err
+	}
+	def, err := s.generator.LoadDefinition(ctx, schedule.DefinitionID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading report definition: %w", err)
+	}
+
+	report, err := s.generator.GenerateReport(ctx, def, ReportOptions{
+		Period:        period,
+		EntityID:      schedule.EntityID,
+		Currency:      schedule.Currency,
+		Format:        schedule.Format,
+		FormatOptions: schedule.FormatOptions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error generating report: %w", err)
+	}
+	content, err := s.formatter.FormatReport(ctx, report, schedule.Format, schedule.FormatOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting report: %w", err)
+	}
+
+	return &Artifact{
+		ScheduleID:  schedule.ID,
+		ReportID:    report.ID,
+		Period:      period,
+		Format:      schedule.Format,
+		Destination: schedule.Destination,
+		Content:     content,
+		GeneratedAt: now,
+	}, nil
+}
+
+// publish raises the outcome event of a run. Publishing failures are not
+// reported, so the run result stands.
+func (s *ReportScheduler) publish(ctx context.Context, eventType string, schedule *Schedule, reportID string, runErr error, now time.Time) {
+	if s.publisher == nil {
+		return
+	}
+	data := event.ReportRunV1{
+		ScheduleID:  schedule.ID,
+		ReportID:    reportID,
+		Format:      schedule.Format,
+		Destination: schedule.Destination,
+	}
+	if runErr != nil {
+		data.Error = runErr.Error()
+	}
+	_ = s.publisher.Publish(ctx, event.WithTenant(ctx, event.Event{
+		ID:        fmt.Sprintf("%s-%s-%d", eventType, schedule.ID, now.UnixNano()),
+		Type:      eventType,
+		Timestamp: now,
+		Source:    "reporting",
+		Data:      data,
+	}))
+}
+
+func validateSchedule(schedule *Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("%w: schedule cannot be nil", ErrInvalidSchedule)
+	}
+	if schedule.DefinitionID == "" {
+		return fmt.Errorf("%w: report definition is required", ErrInvalidSchedule)
+	}
+	if schedule.Format == "" {
+		return fmt.Errorf("%w: format is required", ErrInvalidSchedule)
+	}
+	switch schedule.Frequency {
+	case Daily, Weekly, Monthly:
+	default:
+		return fmt.Errorf("%w: unknown frequency %q", ErrInvalidSchedule, schedule.Frequency)
+	}
+	if _, err := ParsePeriod(schedule.Period, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGenerator serves one stored definition and echoes the period
+type stubGenerator struct {
+	ReportGenerator
+	periods []ReportPeriod
+}
+
+func (g *stubGenerator) LoadDefinition(ctx context.Context, id string) (*ReportDefinition, error) {
+	if id != "pnl" {
+		return nil, fmt.Errorf("definition %s not found", id)
+	}
+	return &ReportDefinition{ID: id, Type: IncomeStatement, Name: "P&L"}, nil
+}
+
+func (g *stubGenerator) GenerateReport(ctx context.Context, def *ReportDefinition, opts ReportOptions) (*Report, error) {
+	g.periods = append(g.periods, opts.Period)
+	return &Report{ID: "R" + fmt.Sprint(len(g.periods)), Title: def.Name, Period: opts.Period}, nil
+}
+
+type stubFormatter struct {
+	ReportFormatter
+}
+
+func (f *stubFormatter) FormatReport(ctx context.Context, report *Report, format string, opts map[string]interface{}) ([]byte, error) {
+	return []byte(format + ":" + report.Title), nil
+}
+
+type recordingPublisher struct {
+	events []event.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e event.Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, time.UTC) // a Thursday
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	for expr, want := range map[string][2]time.Time{
+		"last month":   {date(2024, 2, 1), date(2024, 3, 1)},
+		"This  Week":   {date(2024, 3, 11), date(2024, 3, 18)},
+		"last quarter": {date(2023, 10, 1), date(2024, 1, 1)},
+		"this year":    {date(2024, 1, 1), date(2025, 1, 1)},
+		"last 7 days":  {date(2024, 3, 7), date(2024, 3, 14)},
+		"yesterday":    {date(2024, 3, 13), date(2024, 3, 14)},
+	} {
+		period, err := ParsePeriod(expr, now)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want[0], period.Start, expr)
+		assert.Equal(t, want[1].Add(-time.Nanosecond), period.End, expr)
+	}
+
+	period, err := ParsePeriod("month to date", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, period.End)
+
+	_, err = ParsePeriod("next fortnight", now)
+	assert.ErrorIs(t, err, ErrInvalidPeriodExpression)
+}
+
+func TestReportScheduler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryScheduleStore()
+	generator := &stubGenerator{}
+	publisher := &recordingPublisher{}
+	scheduler := NewReportScheduler(store, generator, &stubFormatter{}, publisher)
+
+	first := time.Date(2024, 2, 1, 6, 0, 0, 0, time.UTC)
+	require.NoError(t, scheduler.AddSchedule(ctx, &Schedule{
+		ID: "monthly-pnl", DefinitionID: "pnl", Period: "last month", Format: "PDF",
+		Destination: "cfo@example.com", Frequency: Monthly, NextRun: first, Active: true,
+	}))
+	require.NoError(t, scheduler.AddSchedule(ctx, &Schedule{
+		ID: "broken", DefinitionID: "missing", Period: "yesterday", Format: "PDF",
+		Frequency: Daily, NextRun: first, Active: true,
+	}))
+	assert.ErrorIs(t, scheduler.AddSchedule(ctx, &Schedule{DefinitionID: "pnl", Format: "PDF", Frequency: Daily, Period: "someday"}), ErrInvalidSchedule)
+
+	artifacts, err := scheduler.RunDue(ctx, first.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, artifacts, "nothing is due yet")
+
+	now := time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)
+	artifacts, err = scheduler.RunDue(ctx, now)
+	assert.Error(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, "PDF:P&L", string(artifacts[0].Content))
+	assert.Equal(t, "cfo@example.com", artifacts[0].Destination)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), artifacts[0].Period.Start)
+
+	require.Len(t, publisher.events, 2)
+	types := []string{publisher.events[0].Type, publisher.events[1].Type}
+	assert.ElementsMatch(t, []string{event.ReportGenerated, event.ReportFailed}, types)
+
+	// A schedule that missed runs moves past now and runs once
+	stored, err := store.GetSchedule(ctx, "monthly-pnl")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC), stored.NextRun)
+	assert.Equal(t, now, *stored.LastRun)
+	broken, err := store.GetSchedule(ctx, "broken")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC), broken.NextRun)
+}
@@ -0,0 +1,125 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// GetID returns the storage identifier of the snapshot, derived from its
+// account and time
+func (s *BalanceSnapshot) GetID() string {
+	return "snapshot:" + s.AccountID + ":" + s.AsOf.UTC().Format(time.RFC3339Nano)
+}
+
+// snapshotIndex lists the times an account has snapshots at, so the latest
+// snapshot can be found with reads by ID alone
+type snapshotIndex struct {
+	AccountID string      `json:"account_id"`
+	AsOf      []time.Time `json:"as_of"`
+}
+
+func (i *snapshotIndex) GetID() string { return "snapshot-index:" + i.AccountID }
+
+// RepositorySnapshotStore persists balance snapshots in a storage
+// repository. Each account keeps an index of its snapshot times next to the
+// snapshots, so no queries are needed.
+type RepositorySnapshotStore struct {
+	mu   sync.Mutex
+	repo storage.Repository
+}
+
+// NewRepositorySnapshotStore creates a snapshot store backed by a repository
+func NewRepositorySnapshotStore(repo storage.Repository) *RepositorySnapshotStore {
+	return &RepositorySnapshotStore{repo: repo}
+}
+
+// SaveSnapshot implements SnapshotStore.SaveSnapshot
+func (s *RepositorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot *BalanceSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.readIndex(ctx, snapshot.AccountID)
+	stored := *snapshot
+	i := sort.Search(len(index.AsOf), func(i int) bool { return !index.AsOf[i].Before(snapshot.AsOf) })
+	if i < len(index.AsOf) && index.AsOf[i].Equal(snapshot.AsOf) {
+		if err := s.repo.Update(ctx, &stored); err != nil {
+			return fmt.Errorf("failed to store snapshot: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.repo.Create(ctx, &stored); err != nil {
+		return fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	index.AsOf = append(index.AsOf, time.Time{})
+	copy(index.AsOf[i+1:], index.AsOf[i:])
+	index.AsOf[i] = snapshot.AsOf
+	return s.writeIndex(ctx, index, exists)
+}
+
+// LatestSnapshot implements SnapshotStore.LatestSnapshot
+func (s *RepositorySnapshotStore) LatestSnapshot(ctx context.Context, accountID string, at time.Time) (*BalanceSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, _ := s.readIndex(ctx, accountID)
+	i := sort.Search(len(index.AsOf), func(i int) bool { return index.AsOf[i].After(at) })
+	if i == 0 {
+		return nil, nil
+	}
+
+	snapshot := &BalanceSnapshot{AccountID: accountID, AsOf: index.AsOf[i-1]}
+	if err := s.repo.Read(ctx, snapshot.GetID(), snapshot); err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// DeleteSnapshotsAfter implements SnapshotStore.DeleteSnapshotsAfter
+func (s *RepositorySnapshotStore) DeleteSnapshotsAfter(ctx context.Context, accountID string, after time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.readIndex(ctx, accountID)
+	i := sort.Search(len(index.AsOf), func(i int) bool { return index.AsOf[i].After(after) })
+	if i == len(index.AsOf) {
+		return nil
+	}
+	for _, asOf := range index.AsOf[i:] {
+		snapshot := &BalanceSnapshot{AccountID: accountID, AsOf: asOf}
+		if err := s.repo.Delete(ctx, snapshot.GetID()); err != nil {
+			return fmt.Errorf("failed to delete snapshot: %w", err)
+		}
+	}
+	index.AsOf = index.AsOf[:i]
+	return s.writeIndex(ctx, index, exists)
+}
+
+// readIndex returns the snapshot index of an account and whether it is
+// stored; a missing index is empty
+func (s *RepositorySnapshotStore) readIndex(ctx context.Context, accountID string) (*snapshotIndex, bool) {
+	index := &snapshotIndex{AccountID: accountID}
+	if err := s.repo.Read(ctx, index.GetID(), index); err != nil {
+		return &snapshotIndex{AccountID: accountID}, false
+	}
+	return index, true
+}
+
+func (s *RepositorySnapshotStore) writeIndex(ctx context.Context, index *snapshotIndex, exists bool) error {
+	stored := *index
+	var err error
+	if exists {
+		err = s.repo.Update(ctx, &stored)
+	} else {
+		err = s.repo.Create(ctx, &stored)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store snapshot index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// StatementLine is a single movement on an account statement
+type StatementLine struct {
+	Date        time.Time   `json:"date"`
+	Description string      `json:"description"`
+	Reference   string      `json:"reference"`
+	Debit       money.Money `json:"debit"`
+	Credit      money.Money `json:"credit"`
+	// Running balance after the movement, positive on the account's normal side
+	Balance money.Money `json:"balance"`
+}
+
+// AccountStatementReport lists the movements on one account over a period
+// between its opening and closing balances, e.g. for sending to a customer
+// or vendor
+type AccountStatementReport struct {
+	Type           ReportType      `json:"type"`
+	AccountID      string          `json:"account_id"`
+	AccountCode    string          `json:"account_code"`
+	AccountName    string          `json:"account_name"`
+	Period         ReportPeriod    `json:"period"`
+	Currency       string          `json:"currency"`
+	OpeningBalance money.Money     `json:"opening_balance"`
+	Lines          []StatementLine `json:"lines"`
+	TotalDebits    money.Money     `json:"total_debits"`
+	TotalCredits   money.Money     `json:"total_credits"`
+	ClosingBalance money.Money     `json:"closing_balance"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+}
+
+// StatementGenerator builds account statements
+type StatementGenerator struct {
+	accounts   account.Repository
+	calculator ReportCalculator
+}
+
+// NewStatementGenerator creates an account statement generator
+func NewStatementGenerator(accounts account.Repository, calculator ReportCalculator) *StatementGenerator {
+	return &StatementGenerator{
+		accounts:   accounts,
+		calculator: calculator,
+	}
+}
+
+// Generate builds the statement of an account for a period. Movements are
+// listed in date order with a running balance. The opening balance is
+// derived from the closing balance so that it excludes every movement shown.
+func (g *StatementGenerator) Generate(ctx context.Context, accountID string, period ReportPeriod) (*AccountStatementReport, error) {
+	if err := auth.Require(ctx, auth.ReportGenerate); err != nil {
+		return nil, err
+	}
+	if accountID == "" {
+		return nil, fmt.Errorf("account ID is required")
+	}
+	if period.End.IsZero() {
+		period.End = time.Now()
+	}
+	if period.End.Before(period.Start) {
+		return nil, fmt.Errorf("period end %s is before start %s", period.End.Format(time.RFC3339), period.Start.Format(time.RFC3339))
+	}
+
+	var acc account.Account
+	if err := g.accounts.Read(ctx, accountID, &acc); err != nil {
+		return nil, fmt.Errorf("error reading account: %w", err)
+	}
+
+	change, err := g.calculator.CalculateChanges(ctx, accountID, period)
+	if err != nil {
+		return nil, fmt.Errorf("error calculating changes: %w", err)
+	}
+
+	currency := change.ClosingBalance.Currency
+	movements := append([]BalanceMovement(nil), change.Movements...)
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].Date.Before(movements[j].Date) })
+
+	net := decimal.Zero
+	for _, m := range movements {
+		if m.Amount.Currency != currency {
+			return nil, fmt.Errorf("movement %s is in %s, statement currency is %s", m.Reference, m.Amount.Currency, currency)
+		}
+		net = net.Add(m.Signed(acc.Type))
+	}
+
+	report := &AccountStatementReport{
+		Type:           AccountStatement,
+		AccountID:      acc.ID,
+		AccountCode:    acc.Code,
+		AccountName:    acc.Name,
+		Period:         period,
+		Currency:       currency,
+		OpeningBalance: money.Money{Amount: change.ClosingBalance.Amount.Sub(net), Currency: currency},
+		Lines:          make([]StatementLine, 0, len(movements)),
+		TotalDebits:    zeroMoney(currency),
+		TotalCredits:   zeroMoney(currency),
+		ClosingBalance: change.ClosingBalance,
+		GeneratedAt:    time.Now(),
+	}
+
+	running := report.OpeningBalance.Amount
+	for _, m := range movements {
+		running = running.Add(m.Signed(acc.Type))
+		line := StatementLine{
+			Date:        m.Date,
+			Description: m.Description,
+			Reference:   m.Reference,
+			Debit:       zeroMoney(currency),
+			Credit:      zeroMoney(currency),
+			Balance:     money.Money{Amount: running, Currency: currency},
+		}
+		if m.Type == string(transaction.Debit) {
+			line.Debit = m.Amount
+			report.TotalDebits.Amount = report.TotalDebits.Amount.Add(m.Amount.Amount)
+		} else {
+			line.Credit = m.Amount
+			report.TotalCredits.Amount = report.TotalCredits.Amount.Add(m.Amount.Amount)
+		}
+		report.Lines = append(report.Lines, line)
+	}
+
+	return report, nil
+}
+
+// Signed returns the movement amount as it changes a balance kept on the
+// normal side of an account of the given type
+func (m BalanceMovement) Signed(accountType account.AccountType) decimal.Decimal {
+	debitNormal := accountType == account.Asset || accountType == account.Expense
+	if (m.Type == string(transaction.Debit)) == debitNormal {
+		return m.Amount.Amount
+	}
+	return m.Amount.Amount.Neg()
+}
@@ -0,0 +1,67 @@
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementGenerator(t *testing.T) {
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	posted := func(id string, date time.Time, amount int64, entryType transaction.EntryType, description string) *transaction.Transaction {
+		other := transaction.Credit
+		if entryType == transaction.Credit {
+			other = transaction.Debit
+		}
+		return &transaction.Transaction{
+			ID:          id,
+			Status:      transaction.Posted,
+			Date:        date,
+			Description: description,
+			Entries: []transaction.Entry{
+				{AccountID: "1200", Amount: usd(amount), Type: entryType},
+				{AccountID: "4000", Amount: usd(amount), Type: other},
+			},
+		}
+	}
+	store := &datedTransactionStore{txs: []*transaction.Transaction{
+		posted("INV-1", time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC), 500, transaction.Debit, "Invoice 1"),
+		posted("INV-2", time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC), 300, transaction.Debit, "Invoice 2"),
+		posted("PMT-1", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 500, transaction.Credit, "Payment, thank you"),
+		posted("INV-3", time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC), 50, transaction.Debit, "Invoice 3"),
+	}}
+	generator := NewStatementGenerator(&staticAccountStore{}, NewReportCalculator(&staticAccountStore{}, nil, store))
+
+	statement, err := generator.Generate(context.Background(), "1200", ReportPeriod{
+		Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, AccountStatement, statement.Type)
+	assert.Equal(t, "USD", statement.Currency)
+	assert.True(t, statement.OpeningBalance.Amount.Equal(decimal.NewFromInt(500)))
+	require.Len(t, statement.Lines, 2)
+	assert.Equal(t, "PMT-1", statement.Lines[0].Reference)
+	assert.True(t, statement.Lines[0].Credit.Amount.Equal(decimal.NewFromInt(500)))
+	assert.True(t, statement.Lines[0].Balance.Amount.IsZero())
+	assert.Equal(t, "Invoice 2", statement.Lines[1].Description)
+	assert.True(t, statement.Lines[1].Balance.Amount.Equal(decimal.NewFromInt(300)))
+	assert.True(t, statement.TotalDebits.Amount.Equal(decimal.NewFromInt(300)))
+	assert.True(t, statement.TotalCredits.Amount.Equal(decimal.NewFromInt(500)))
+	assert.True(t, statement.ClosingBalance.Amount.Equal(decimal.NewFromInt(300)))
+
+	_, err = generator.Generate(context.Background(), "1200", ReportPeriod{
+		Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package statements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+var (
+	ErrClassificationNotFound = errors.New("cash flow classification not found")
+	ErrInvalidClassification  = errors.New("invalid cash flow classification")
+)
+
+// GetID returns the storage ID of the classification, which is distinct
+// from the ID of the account it classifies
+func (c *CashFlowClassification) GetID() string { return classificationID(c.AccountID) }
+
+func classificationID(accountID string) string {
+	return "cashflow:" + accountID
+}
+
+// CodeRange classifies accounts whose code falls between From and To
+// inclusive. Codes are compared as strings, so ranges should use codes of
+// the same length.
+type CodeRange struct {
+	From        string
+	To          string
+	Category    CashFlowCategory
+	Subcategory string
+	// Whether accounts in the range are working capital
+	IsWorkingCapital bool
+}
+
+// Contains reports whether a code falls within the range
+func (r CodeRange) Contains(code string) bool {
+	return code >= r.From && code <= r.To
+}
+
+// CashFlowClassifier maps accounts to cash flow categories. Classifications
+// set explicitly are persisted; other accounts fall back to the first
+// matching code range and then to a default inferred from the account:
+// cash is unclassified, revenue, expenses and current assets and liabilities
+// are operating, other assets are investing and other liabilities and
+// equity are financing.
+type CashFlowClassifier struct {
+	store  storage.Repository
+	ranges []CodeRange
+}
+
+// NewCashFlowClassifier creates a classifier persisting classifications in
+// the store, which may be nil to use only the code ranges and defaults
+func NewCashFlowClassifier(store storage.Repository, ranges []CodeRange) *CashFlowClassifier {
+	return &CashFlowClassifier{
+		store:  store,
+		ranges: ranges,
+	}
+}
+
+// Set stores the classification of an account, replacing any existing one
+func (c *CashFlowClassifier) Set(ctx context.Context, classification CashFlowClassification) error {
+	if classification.AccountID == "" {
+		return fmt.Errorf("%w: account ID is required", ErrInvalidClassification)
+	}
+	switch classification.Category {
+	case Operating, Investing, Financing, Unclassified:
+	default:
+		return fmt.Errorf("%w: unknown category %q", ErrInvalidClassification, classification.Category)
+	}
+	if c.store == nil {
+		return fmt.Errorf("classifier has no store")
+	}
+
+	var existing CashFlowClassification
+	if err := c.store.Read(ctx, classificationID(classification.AccountID), &existing); err == nil {
+		if err := c.store.Update(ctx, &classification); err != nil {
+			return fmt.Errorf("failed to update cash flow classification: %w", err)
+		}
+		return nil
+	}
+	if err := c.store.Create(ctx, &classification); err != nil {
+		return fmt.Errorf("failed to store cash flow classification: %w", err)
+	}
+	return nil
+}
+
+// Get returns the stored classification of an account
+func (c *CashFlowClassifier) Get(ctx context.Context, accountID string) (*CashFlowClassification, error) {
+	if c.store == nil {
+		return nil, fmt.Errorf("%w: %s", ErrClassificationNotFound, accountID)
+	}
+	var classification CashFlowClassification
+	if err := c.store.Read(ctx, classificationID(accountID), &classification); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrClassificationNotFound, accountID, err)
+	}
+	return &classification, nil
+}
+
+// Remove deletes the stored classification of an account so that it falls
+// back to the defaults
+func (c *CashFlowClassifier) Remove(ctx context.Context, accountID string) error {
+	if c.store == nil {
+		return fmt.Errorf("%w: %s", ErrClassificationNotFound, accountID)
+	}
+	if err := c.store.Delete(ctx, classificationID(accountID)); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrClassificationNotFound, accountID, err)
+	}
+	return nil
+}
+
+// Classify returns the classification of an account
+func (c *CashFlowClassifier) Classify(ctx context.Context, acc *account.Account) (CashFlowClassification, error) {
+	if stored, err := c.Get(ctx, acc.ID); err == nil {
+		return *stored, nil
+	}
+
+	for _, r := range c.ranges {
+		if acc.Code != "" && r.Contains(acc.Code) {
+			return CashFlowClassification{
+				AccountID:        acc.ID,
+				Category:         r.Category,
+				Subcategory:      r.Subcategory,
+				IsWorkingCapital: r.IsWorkingCapital,
+			}, nil
+		}
+	}
+	return DefaultClassification(acc), nil
+}
+
+// DefaultClassification infers the classification of an account from its
+// type and balance sheet flags
+func DefaultClassification(acc *account.Account) CashFlowClassification {
+	classification := CashFlowClassification{AccountID: acc.ID}
+	switch {
+	case acc.IsCash():
+		classification.Category = Unclassified
+	case acc.Type == account.Revenue || acc.Type == account.Expense:
+		classification.Category = Operating
+	case (acc.Type == account.Asset || acc.Type == account.Liability) && acc.IsCurrent():
+		classification.Category = Operating
+		classification.IsWorkingCapital = true
+	case acc.Type == account.Asset:
+		classification.Category = Investing
+	default:
+		classification.Category = Financing
+	}
+	return classification
+}
@@ -0,0 +1,312 @@
+package statements
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// Metadata keys written on consolidated statements
+const (
+	// EntitiesKey lists the IDs of the consolidated entities
+	EntitiesKey = "entities"
+	// TranslationRatesKey maps entity IDs to the rate their statement was
+	// translated at
+	TranslationRatesKey = "translation_rates"
+	// EliminationsKey lists the Elimination of each removed line
+	EliminationsKey = "eliminations"
+)
+
+// RateBasis selects the exchange rate foreign entities are translated at
+type RateBasis string
+
+const (
+	// ClosingRate is the rate at the statement date
+	ClosingRate RateBasis = "CLOSING"
+	// AverageRate is the mean of the rates at the start and end of the period
+	AverageRate RateBasis = "AVERAGE"
+)
+
+// EliminationRule removes intercompany balances from consolidated
+// statements. Lines made up only of the rule's accounts are eliminated;
+// typically a rule pairs the receivable in one entity with the payable in
+// the other.
+type EliminationRule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	AccountIDs  []string `json:"account_ids"`
+}
+
+// Elimination records a line removed by an elimination rule
+type Elimination struct {
+	RuleID   string      `json:"rule_id"`
+	EntityID string      `json:"entity_id"`
+	Section  string      `json:"section"`
+	Label    string      `json:"label"`
+	Amount   money.Money `json:"amount"`
+}
+
+// ConsolidationOptions controls how entity statements are combined
+type ConsolidationOptions struct {
+	// Reporting currency of the consolidated statement
+	Currency string
+	// Name shown as the statement entity, e.g. the group name
+	GroupName string
+	// Options used to generate each entity statement; the currency is
+	// always the entity's base currency and comparatives are not generated
+	Statement StatementOptions
+	// Rate basis for balance sheets; defaults to ClosingRate
+	BalanceSheetRate RateBasis
+	// Rate basis for income and cash flow statements; defaults to
+	// AverageRate
+	PeriodRate RateBasis
+	// Fixed translation rates by entity ID, overriding the rate provider
+	Rates map[string]decimal.Decimal
+}
+
+// Consolidator merges the statements of several entities into group
+// statements
+type Consolidator struct {
+	generator *Generator
+	rates     money.ExchangeRateProvider
+	rules     []EliminationRule
+}
+
+// NewConsolidator creates a consolidator generating entity statements with
+// a generator and translating foreign entities with a rate provider. The
+// provider may be nil when every foreign entity has a fixed rate.
+func NewConsolidator(generator *Generator, rates money.ExchangeRateProvider) *Consolidator {
+	return &Consolidator{
+		generator: generator,
+		rates:     rates,
+	}
+}
+
+// AddEliminationRule registers an intercompany elimination rule
+func (c *Consolidator) AddEliminationRule(rule EliminationRule) error {
+	if rule.ID == "" {
+		return fmt.Errorf("elimination rule ID is required")
+	}
+	if len(rule.AccountIDs) == 0 {
+		return fmt.Errorf("elimination rule %s has no accounts", rule.ID)
+	}
+	c.rules = append(c.rules, rule)
+	return nil
+}
+
+// ConsolidateBalanceSheet combines the balance sheets of entities at a date
+func (c *Consolidator) ConsolidateBalanceSheet(ctx context.Context, entities []*entity.Entity, asOf time.Time, opts ConsolidationOptions) (*Statement, error) {
+	basis := opts.BalanceSheetRate
+	if basis == "" {
+		basis = ClosingRate
+	}
+	return c.consolidate(ctx, entities, asOf, asOf, basis, opts, func(ctx context.Context, so StatementOptions) (*Statement, error) {
+		return c.generator.GenerateBalanceSheet(ctx, asOf, so)
+	})
+}
+
+// ConsolidateIncomeStatement combines the income statements of entities
+// over a period
+func (c *Consolidator) ConsolidateIncomeStatement(ctx context.Context, entities []*entity.Entity, periodStart, periodEnd time.Time, opts ConsolidationOptions) (*Statement, error) {
+	basis := opts.PeriodRate
+	if basis == "" {
+		basis = AverageRate
+	}
+	return c.consolidate(ctx, entities, periodStart, periodEnd, basis, opts, func(ctx context.Context, so StatementOptions) (*Statement, error) {
+		return c.generator.GenerateIncomeStatement(ctx, periodStart, periodEnd, so)
+	})
+}
+
+// ConsolidateCashFlow combines the cash flow statements of entities over a
+// period
+func (c *Consolidator) ConsolidateCashFlow(ctx context.Context, entities []*entity.Entity, periodStart, periodEnd time.Time, opts ConsolidationOptions) (*Statement, error) {
+	basis := opts.PeriodRate
+	if basis == "" {
+		basis = AverageRate
+	}
+	return c.consolidate(ctx, entities, periodStart, periodEnd, basis, opts, func(ctx context.Context, so StatementOptions) (*Statement, error) {
+		return c.generator.GenerateCashFlow(ctx, periodStart, periodEnd, so)
+	})
+}
+
+// consolidate generates each entity's statement in its own scope,
+// translates it to the reporting currency and merges the lines by section
+// title and label, leaving out eliminated lines
+func (c *Consolidator) consolidate(
+	ctx context.Context,
+	entities []*entity.Entity,
+	start, end time.Time,
+	basis RateBasis,
+	opts ConsolidationOptions,
+	generate func(ctx context.Context, so StatementOptions) (*Statement, error),
+) (*Statement, error) {
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("no entities to consolidate")
+	}
+	if opts.Currency == "" {
+		return nil, fmt.Errorf("reporting currency is required")
+	}
+
+	eliminated := make(map[string]string)
+	for _, rule := range c.rules {
+		for _, id := range rule.AccountIDs {
+			eliminated[id] = rule.ID
+		}
+	}
+
+	var result *Statement
+	ids := make([]string, 0, len(entities))
+	rates := make(map[string]string, len(entities))
+	eliminations := make([]Elimination, 0)
+
+	for _, e := range entities {
+		so := opts.Statement
+		so.Currency = e.BaseCurrency
+		so.IncludeComparative = false
+
+		stmt, err := generate(entity.WithEntity(ctx, e.ID), so)
+		if err != nil {
+			return nil, fmt.Errorf("error generating statement for entity %s: %w", e.ID, err)
+		}
+		rate, err := c.translationRate(ctx, e, opts, basis, start, end)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, e.ID)
+		rates[e.ID] = rate.String()
+
+		if result == nil {
+			result = &Statement{
+				Type:        stmt.Type,
+				Title:       "Consolidated " + stmt.Title,
+				Entity:      opts.GroupName,
+				AsOf:        stmt.AsOf,
+				PeriodStart: stmt.PeriodStart,
+				Currency:    opts.Currency,
+				Sections:    make([]StatementSection, 0, len(stmt.Sections)),
+			}
+		}
+
+		for _, section := range stmt.Sections {
+			target := findSection(result, section.Title, opts.Currency)
+			for _, item := range translateItems(section.Items, rate, opts.Currency) {
+				if ruleID, ok := eliminatedBy(item, eliminated); ok {
+					eliminations = append(eliminations, Elimination{
+						RuleID:   ruleID,
+						EntityID: e.ID,
+						Section:  section.Title,
+						Label:    item.Label,
+						Amount:   item.Amount,
+					})
+					continue
+				}
+				target.Items = mergeItem(target.Items, item)
+				target.Total.Amount = target.Total.Amount.Add(item.Amount.Amount)
+			}
+		}
+	}
+
+	result.Metadata = map[string]interface{}{
+		EntitiesKey:         ids,
+		TranslationRatesKey: rates,
+		EliminationsKey:     eliminations,
+	}
+	return result, nil
+}
+
+// translationRate returns the rate an entity's statement is translated at
+func (c *Consolidator) translationRate(ctx context.Context, e *entity.Entity, opts ConsolidationOptions, basis RateBasis, start, end time.Time) (decimal.Decimal, error) {
+	if e.BaseCurrency == opts.Currency {
+		return decimal.NewFromInt(1), nil
+	}
+	if rate, ok := opts.Rates[e.ID]; ok {
+		return rate, nil
+	}
+	if c.rates == nil {
+		return decimal.Zero, fmt.Errorf("%w: %s/%s for entity %s", money.ErrRateNotFound, e.BaseCurrency, opts.Currency, e.ID)
+	}
+
+	closing, err := c.rates.Rate(ctx, e.BaseCurrency, opts.Currency, end)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error translating entity %s: %w", e.ID, err)
+	}
+	if basis != AverageRate || !start.Before(end) {
+		return closing, nil
+	}
+	opening, err := c.rates.Rate(ctx, e.BaseCurrency, opts.Currency, start)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("error translating entity %s: %w", e.ID, err)
+	}
+	return opening.Add(closing).Div(decimal.NewFromInt(2)), nil
+}
+
+// findSection returns the section of a statement with a title, adding an
+// empty one when missing
+func findSection(stmt *Statement, title, currency string) *StatementSection {
+	for i := range stmt.Sections {
+		if stmt.Sections[i].Title == title {
+			return &stmt.Sections[i]
+		}
+	}
+	stmt.Sections = append(stmt.Sections, StatementSection{
+		Title: title,
+		Items: make([]LineItem, 0),
+		Total: money.Money{Amount: decimal.Zero, Currency: currency},
+	})
+	return &stmt.Sections[len(stmt.Sections)-1]
+}
+
+// translateItems converts line items and their sub-items at a rate
+func translateItems(items []LineItem, rate decimal.Decimal, currency string) []LineItem {
+	translated := make([]LineItem, len(items))
+	for i, item := range items {
+		item.Amount = money.Money{Amount: item.Amount.Amount.Mul(rate), Currency: currency}.RoundToCurrency()
+		item.AccountIDs = append([]string(nil), item.AccountIDs...)
+		if item.SubItems != nil {
+			item.SubItems = translateItems(item.SubItems, rate, currency)
+		}
+		translated[i] = item
+	}
+	return translated
+}
+
+// eliminatedBy returns the rule eliminating a line, if all of its accounts
+// are covered by elimination rules
+func eliminatedBy(item LineItem, eliminated map[string]string) (string, bool) {
+	if len(item.AccountIDs) == 0 {
+		return "", false
+	}
+	ruleID := ""
+	for _, id := range item.AccountIDs {
+		rule, ok := eliminated[id]
+		if !ok {
+			return "", false
+		}
+		if ruleID == "" {
+			ruleID = rule
+		}
+	}
+	return ruleID, true
+}
+
+// mergeItem adds a line item to a list, combining it with the line of the
+// same label
+func mergeItem(items []LineItem, item LineItem) []LineItem {
+	for i := range items {
+		if items[i].Label != item.Label {
+			continue
+		}
+		existing := &items[i]
+		existing.Amount.Amount = existing.Amount.Amount.Add(item.Amount.Amount)
+		existing.AccountIDs = append(existing.AccountIDs, item.AccountIDs...)
+		for _, sub := range item.SubItems {
+			existing.SubItems = mergeItem(existing.SubItems, sub)
+		}
+		return items
+	}
+	return append(items, item)
+}
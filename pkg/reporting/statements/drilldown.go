@@ -0,0 +1,113 @@
+package statements
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+)
+
+// Metadata keys written on line items when drill-down is enabled
+const (
+	// DrillDownKey holds the IDs of the first transactions behind a line
+	DrillDownKey = "transactions"
+	// DrillDownCountKey holds the number of transactions behind a line
+	DrillDownCountKey = "transaction_count"
+)
+
+// DefaultDrillDownLimit caps the transaction IDs attached to a line item
+const DefaultDrillDownLimit = 50
+
+// DrillDownOptions controls the transaction IDs attached to line items
+type DrillDownOptions struct {
+	// Attach the contributing transaction IDs to balance sheet and income
+	// statement lines
+	Enabled bool
+	// Maximum IDs attached per line; defaults to DefaultDrillDownLimit.
+	// Use Generator.DrillDown to page through the rest.
+	Limit int
+}
+
+// DrillDownPage is a page of the transactions behind a line item, in date
+// order
+type DrillDownPage struct {
+	TransactionIDs []string `json:"transaction_ids"`
+	Offset         int      `json:"offset"`
+	Total          int      `json:"total"`
+}
+
+// DrillDown returns a page of the transactions contributing to a line item
+// over a period. Balance sheet lines cover everything up to the statement
+// date, so pass a period with a zero start.
+func (g *Generator) DrillDown(ctx context.Context, item LineItem, period reporting.ReportPeriod, offset, limit int) (*DrillDownPage, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid page offset %d or limit %d", offset, limit)
+	}
+
+	movements := make([]reporting.BalanceMovement, 0)
+	for _, id := range item.AccountIDs {
+		changes, err := g.calculator.CalculateChanges(ctx, id, period)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating changes for account %s: %w", id, err)
+		}
+		movements = append(movements, changes.Movements...)
+	}
+
+	ids := transactionIDs(movements)
+	page := &DrillDownPage{TransactionIDs: make([]string, 0), Offset: offset, Total: len(ids)}
+	if offset < len(ids) {
+		end := offset + limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		page.TransactionIDs = append(page.TransactionIDs, ids[offset:end]...)
+	}
+	return page, nil
+}
+
+// attachDrillDown records the transactions behind a line item in its
+// metadata, capped at the drill-down limit
+func attachDrillDown(item *LineItem, movements []reporting.BalanceMovement, opts DrillDownOptions) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultDrillDownLimit
+	}
+
+	ids := transactionIDs(movements)
+	if item.Metadata == nil {
+		item.Metadata = make(map[string]interface{})
+	}
+	item.Metadata[DrillDownCountKey] = len(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	item.Metadata[DrillDownKey] = ids
+}
+
+// transactionIDs lists the distinct transactions of movements ordered by
+// date, then ID
+func transactionIDs(movements []reporting.BalanceMovement) []string {
+	dates := make(map[string]time.Time, len(movements))
+	for _, m := range movements {
+		if m.Reference == "" {
+			continue
+		}
+		if d, ok := dates[m.Reference]; !ok || m.Date.Before(d) {
+			dates[m.Reference] = m.Date
+		}
+	}
+
+	ids := make([]string, 0, len(dates))
+	for id := range dates {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if !dates[ids[i]].Equal(dates[ids[j]]) {
+			return dates[ids[i]].Before(dates[ids[j]])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
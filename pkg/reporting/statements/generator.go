@@ -3,11 +3,16 @@ package statements
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/johnayoung/finlib/pkg/account"
 	"github.com/johnayoung/finlib/pkg/money"
 	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
 	"github.com/shopspring/decimal"
 )
 
@@ -15,6 +20,10 @@ import (
 type Generator struct {
 	calculator reporting.ReportCalculator
 	accounts   account.Repository
+
+	mu         sync.RWMutex
+	nonCash    map[string]NonCashClassification
+	classifier *CashFlowClassifier
 }
 
 // NewGenerator creates a new statement generator
@@ -22,7 +31,46 @@ func NewGenerator(calculator reporting.ReportCalculator, accounts account.Reposi
 	return &Generator{
 		calculator: calculator,
 		accounts:   accounts,
+		nonCash:    make(map[string]NonCashClassification),
+		classifier: NewCashFlowClassifier(nil, nil),
+	}
+}
+
+// SetClassifier sets the classifier assigning accounts to cash flow
+// categories; by default categories are inferred from the accounts
+func (g *Generator) SetClassifier(classifier *CashFlowClassifier) {
+	g.classifier = classifier
+}
+
+// RegisterNonCash marks an account as a non-cash item, such as depreciation
+// expense, whose period change is added back to net income in the indirect
+// method. Registering an account again replaces its classification.
+func (g *Generator) RegisterNonCash(c NonCashClassification) error {
+	if c.AccountID == "" {
+		return fmt.Errorf("account ID is required")
+	}
+	if _, ok := nonCashLabels[c.Type]; !ok {
+		return fmt.Errorf("unknown non-cash type %q", c.Type)
 	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nonCash[c.AccountID] = c
+	return nil
+}
+
+// isNonCash reports whether an account is registered as a non-cash item
+func (g *Generator) isNonCash(accountID string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.nonCash[accountID]
+	return ok
+}
+
+// accountsOfType returns the example account used to query accounts of a
+// type, restricted to the context tenant
+func accountsOfType(ctx context.Context, accountType account.AccountType) account.Account {
+	return account.Account{Type: accountType, EntityID: tenant.ID(ctx)}
 }
 
 // GenerateBalanceSheet creates a balance sheet statement
@@ -68,6 +116,7 @@ func (g *Generator) GenerateBalanceSheet(ctx context.Context, asOf time.Time, op
 			return nil, fmt.Errorf("error generating comparative balance sheet: %w", err)
 		}
 		stmt.ComparativePeriod = comparative
+		ApplyVariance(stmt, opts.Variance)
 	}
 
 	return stmt, nil
@@ -112,6 +161,7 @@ func (g *Generator) GenerateIncomeStatement(ctx context.Context, periodStart, pe
 			return nil, fmt.Errorf("error generating comparative income statement: %w", err)
 		}
 		stmt.ComparativePeriod = comparative
+		ApplyVariance(stmt, opts.Variance)
 	}
 
 	return stmt, nil
@@ -151,14 +201,14 @@ func (g *Generator) GenerateCashFlow(ctx context.Context, periodStart, periodEnd
 
 	if cfOpts.Method == Indirect {
 		// Generate operating activities section using indirect method
-		operatingSection, err := g.generateOperatingCashFlowIndirect(ctx, period, opts)
+		operatingSection, err := g.generateOperatingCashFlowIndirect(ctx, period, opts, cfOpts)
 		if err != nil {
 			return nil, fmt.Errorf("error generating operating activities section: %w", err)
 		}
 		stmt.Sections = append(stmt.Sections, operatingSection)
 	} else {
 		// Generate operating activities section using direct method
-		operatingSection, err := g.generateOperatingCashFlowDirect(ctx, period, opts)
+		operatingSection, err := g.generateOperatingCashFlowDirect(ctx, period, opts, cfOpts)
 		if err != nil {
 			return nil, fmt.Errorf("error generating operating activities section: %w", err)
 		}
@@ -166,14 +216,14 @@ func (g *Generator) GenerateCashFlow(ctx context.Context, periodStart, periodEnd
 	}
 
 	// Generate investing activities section
-	investingSection, err := g.generateInvestingCashFlow(ctx, period, opts)
+	investingSection, err := g.generateInvestingCashFlow(ctx, period, opts, cfOpts)
 	if err != nil {
 		return nil, fmt.Errorf("error generating investing activities section: %w", err)
 	}
 	stmt.Sections = append(stmt.Sections, investingSection)
 
 	// Generate financing activities section
-	financingSection, err := g.generateFinancingCashFlow(ctx, period, opts)
+	financingSection, err := g.generateFinancingCashFlow(ctx, period, opts, cfOpts)
 	if err != nil {
 		return nil, fmt.Errorf("error generating financing activities section: %w", err)
 	}
@@ -192,6 +242,7 @@ func (g *Generator) GenerateCashFlow(ctx context.Context, periodStart, periodEnd
 			return nil, fmt.Errorf("error generating comparative cash flow statement: %w", err)
 		}
 		stmt.ComparativePeriod = comparative
+		ApplyVariance(stmt, opts.Variance)
 	}
 
 	return stmt, nil
@@ -207,7 +258,7 @@ func (g *Generator) generateBalanceSheetSection(ctx context.Context, title strin
 
 	// Get all accounts of this type
 	accounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: accountType}, &accounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
 		return section, fmt.Errorf("error querying accounts: %w", err)
 	}
 
@@ -225,6 +276,13 @@ func (g *Generator) generateBalanceSheetSection(ctx context.Context, title strin
 				Amount:     balance,
 				AccountIDs: []string{acc.ID},
 			}
+			if opts.DrillDown.Enabled {
+				changes, err := g.calculator.CalculateChanges(ctx, acc.ID, reporting.ReportPeriod{End: asOf})
+				if err != nil {
+					return section, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+				}
+				attachDrillDown(&item, changes.Movements, opts.DrillDown)
+			}
 			section.Items = append(section.Items, item)
 			total = total.Add(balance.Amount)
 		}
@@ -242,7 +300,7 @@ func (g *Generator) generateIncomeStatementSection(ctx context.Context, title st
 
 	// Get all accounts of this type
 	accounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: accountType}, &accounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
 		return section, fmt.Errorf("error querying accounts: %w", err)
 	}
 
@@ -260,6 +318,9 @@ func (g *Generator) generateIncomeStatementSection(ctx context.Context, title st
 				Amount:     changes.NetChange,
 				AccountIDs: []string{acc.ID},
 			}
+			if opts.DrillDown.Enabled {
+				attachDrillDown(&item, changes.Movements, opts.DrillDown)
+			}
 			section.Items = append(section.Items, item)
 			total = total.Add(changes.NetChange.Amount)
 		}
@@ -269,7 +330,7 @@ func (g *Generator) generateIncomeStatementSection(ctx context.Context, title st
 	return section, nil
 }
 
-func (g *Generator) generateOperatingCashFlowIndirect(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions) (StatementSection, error) {
+func (g *Generator) generateOperatingCashFlowIndirect(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) (StatementSection, error) {
 	section := StatementSection{
 		Title: "Operating Activities",
 		Items: make([]LineItem, 0),
@@ -278,10 +339,10 @@ func (g *Generator) generateOperatingCashFlowIndirect(ctx context.Context, perio
 	// Start with net income
 	revenueAccounts := make([]*account.Account, 0)
 	expenseAccounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Revenue}, &revenueAccounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, account.Revenue), &revenueAccounts); err != nil {
 		return section, fmt.Errorf("error querying revenue accounts: %w", err)
 	}
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Expense}, &expenseAccounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, account.Expense), &expenseAccounts); err != nil {
 		return section, fmt.Errorf("error querying expense accounts: %w", err)
 	}
 
@@ -313,14 +374,14 @@ func (g *Generator) generateOperatingCashFlowIndirect(ctx context.Context, perio
 	})
 
 	// Add back non-cash expenses
-	nonCashAdjustments, err := g.calculateNonCashAdjustments(ctx, period)
+	nonCashAdjustments, err := g.calculateNonCashAdjustments(ctx, period, opts)
 	if err != nil {
 		return section, fmt.Errorf("error calculating non-cash adjustments: %w", err)
 	}
 	section.Items = append(section.Items, nonCashAdjustments...)
 
 	// Add changes in working capital
-	workingCapitalChanges, err := g.calculateWorkingCapitalChanges(ctx, period)
+	workingCapitalChanges, err := g.calculateWorkingCapitalChanges(ctx, period, opts, cfOpts)
 	if err != nil {
 		return section, fmt.Errorf("error calculating working capital changes: %w", err)
 	}
@@ -336,25 +397,23 @@ func (g *Generator) generateOperatingCashFlowIndirect(ctx context.Context, perio
 	return section, nil
 }
 
-func (g *Generator) generateOperatingCashFlowDirect(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions) (StatementSection, error) {
+func (g *Generator) generateOperatingCashFlowDirect(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) (StatementSection, error) {
 	section := StatementSection{
 		Title: "Operating Activities",
 		Items: make([]LineItem, 0),
 	}
 
-	// Calculate cash receipts from customers
-	receipts, err := g.calculateCashReceipts(ctx, period)
+	// Trace operating cash through the counter-entries of cash accounts
+	flows, err := g.traceOperatingCash(ctx, period, cfOpts)
 	if err != nil {
-		return section, fmt.Errorf("error calculating cash receipts: %w", err)
+		return section, fmt.Errorf("error tracing operating cash: %w", err)
 	}
-	section.Items = append(section.Items, receipts...)
+
+	// Calculate cash receipts from customers
+	section.Items = append(section.Items, g.calculateCashReceipts(flows, opts)...)
 
 	// Calculate cash payments
-	payments, err := g.calculateCashPayments(ctx, period)
-	if err != nil {
-		return section, fmt.Errorf("error calculating cash payments: %w", err)
-	}
-	section.Items = append(section.Items, payments...)
+	section.Items = append(section.Items, g.calculateCashPayments(flows, opts)...)
 
 	// Calculate section total
 	total := decimal.Zero
@@ -366,71 +425,53 @@ func (g *Generator) generateOperatingCashFlowDirect(ctx context.Context, period
 	return section, nil
 }
 
-func (g *Generator) generateInvestingCashFlow(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions) (StatementSection, error) {
-	section := StatementSection{
-		Title: "Investing Activities",
-		Items: make([]LineItem, 0),
-	}
-
-	// Get all accounts classified as investing activities
-	accounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Asset}, &accounts); err != nil {
-		return section, fmt.Errorf("error querying investing accounts: %w", err)
-	}
-
-	// Calculate changes for each investing account
-	total := decimal.Zero
-	for _, acc := range accounts {
-		// TODO: Add logic to determine if this is an investing account
-		changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
-		if err != nil {
-			return section, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
-		}
-
-		if !changes.NetChange.Amount.IsZero() || opts.DetailLevel == "detailed" {
-			item := LineItem{
-				Label:      acc.Name,
-				Amount:     changes.NetChange,
-				AccountIDs: []string{acc.ID},
-			}
-			section.Items = append(section.Items, item)
-			total = total.Add(changes.NetChange.Amount)
-		}
-	}
+func (g *Generator) generateInvestingCashFlow(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) (StatementSection, error) {
+	return g.generateClassifiedCashFlow(ctx, "Investing Activities", Investing, period, opts, cfOpts)
+}
 
-	section.Total = money.Money{Amount: total, Currency: opts.Currency}
-	return section, nil
+func (g *Generator) generateFinancingCashFlow(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) (StatementSection, error) {
+	return g.generateClassifiedCashFlow(ctx, "Financing Activities", Financing, period, opts, cfOpts)
 }
 
-func (g *Generator) generateFinancingCashFlow(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions) (StatementSection, error) {
+// generateClassifiedCashFlow lists the changes of the balance sheet accounts
+// classified in a category, other than registered non-cash items
+func (g *Generator) generateClassifiedCashFlow(ctx context.Context, title string, category CashFlowCategory, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) (StatementSection, error) {
 	section := StatementSection{
-		Title: "Financing Activities",
+		Title: title,
 		Items: make([]LineItem, 0),
 	}
 
-	// Get all accounts classified as financing activities
-	accounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Liability}, &accounts); err != nil {
-		return section, fmt.Errorf("error querying financing accounts: %w", err)
-	}
-
-	// Calculate changes for each financing account
+	overrides := classificationsByAccount(cfOpts.Classifications)
 	total := decimal.Zero
-	for _, acc := range accounts {
-		// TODO: Add logic to determine if this is a financing account
-		changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
-		if err != nil {
-			return section, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+	for _, accountType := range []account.AccountType{account.Asset, account.Liability, account.Equity} {
+		accounts := make([]*account.Account, 0)
+		if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
+			return section, fmt.Errorf("error querying %s accounts: %w", strings.ToLower(string(category)), err)
 		}
 
-		if !changes.NetChange.Amount.IsZero() || opts.DetailLevel == "detailed" {
-			item := LineItem{
-				Label:      acc.Name,
-				Amount:     changes.NetChange,
-				AccountIDs: []string{acc.ID},
+		// Calculate changes for each account in the category
+		for _, acc := range accounts {
+			classification, err := g.classify(ctx, acc, overrides)
+			if err != nil {
+				return section, err
+			}
+			if classification.Category != category || classification.IsWorkingCapital || g.isNonCash(acc.ID) {
+				continue
+			}
+			changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
+			if err != nil {
+				return section, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+			}
+
+			if !changes.NetChange.Amount.IsZero() || opts.DetailLevel == "detailed" {
+				item := LineItem{
+					Label:      acc.Name,
+					Amount:     changes.NetChange,
+					AccountIDs: []string{acc.ID},
+				}
+				section.Items = append(section.Items, item)
+				total = total.Add(changes.NetChange.Amount)
 			}
-			section.Items = append(section.Items, item)
-			total = total.Add(changes.NetChange.Amount)
 		}
 	}
 
@@ -441,10 +482,10 @@ func (g *Generator) generateFinancingCashFlow(ctx context.Context, period report
 func (g *Generator) calculateNetIncome(ctx context.Context, period reporting.ReportPeriod) (money.Money, error) {
 	revenueAccounts := make([]*account.Account, 0)
 	expenseAccounts := make([]*account.Account, 0)
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Revenue}, &revenueAccounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, account.Revenue), &revenueAccounts); err != nil {
 		return money.Money{}, fmt.Errorf("error querying revenue accounts: %w", err)
 	}
-	if err := g.accounts.Query(ctx, account.Account{Type: account.Expense}, &expenseAccounts); err != nil {
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, account.Expense), &expenseAccounts); err != nil {
 		return money.Money{}, fmt.Errorf("error querying expense accounts: %w", err)
 	}
 
@@ -473,46 +514,332 @@ func (g *Generator) calculateNetIncome(ctx context.Context, period reporting.Rep
 	return money.Money{Amount: netIncome, Currency: "USD"}, nil
 }
 
-func (g *Generator) calculateNonCashAdjustments(ctx context.Context, period reporting.ReportPeriod) ([]LineItem, error) {
-	items := make([]LineItem, 0)
-	// TODO: Implement non-cash adjustments calculation
-	// This should include:
-	// - Depreciation and amortization
-	// - Stock-based compensation
-	// - Gains/losses on asset sales
-	// - Other non-cash items
-	return items, nil
+// nonCashLabels are the default line labels of non-cash items
+var nonCashLabels = map[NonCashType]string{
+	Depreciation:      "Depreciation",
+	Amortization:      "Amortization",
+	StockCompensation: "Stock-based compensation",
+	AssetSaleGainLoss: "(Gain)/loss on sale of assets",
+	OtherNonCash:      "Other non-cash items",
 }
 
-func (g *Generator) calculateWorkingCapitalChanges(ctx context.Context, period reporting.ReportPeriod) ([]LineItem, error) {
+// nonCashOrder is the order non-cash lines are listed in
+var nonCashOrder = []NonCashType{Depreciation, Amortization, StockCompensation, AssetSaleGainLoss, OtherNonCash}
+
+// calculateNonCashAdjustments adds back the period change of every
+// registered non-cash account, one line per label. Expenses are added back
+// and income such as a gain on sale is deducted; balance sheet accounts such
+// as accumulated depreciation are adjusted as in working capital.
+func (g *Generator) calculateNonCashAdjustments(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions) ([]LineItem, error) {
 	items := make([]LineItem, 0)
-	// TODO: Implement working capital changes calculation
-	// This should include changes in:
-	// - Accounts receivable
-	// - Inventory
-	// - Accounts payable
-	// - Other current assets and liabilities
+
+	g.mu.RLock()
+	registered := make(map[string]NonCashClassification, len(g.nonCash))
+	for id, c := range g.nonCash {
+		registered[id] = c
+	}
+	g.mu.RUnlock()
+	if len(registered) == 0 {
+		return items, nil
+	}
+
+	byLabel := make(map[NonCashType]map[string]*LineItem)
+	labels := make(map[NonCashType][]string)
+	for _, accountType := range []account.AccountType{account.Expense, account.Revenue, account.Asset, account.Liability, account.Equity} {
+		accounts := make([]*account.Account, 0)
+		if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
+			return nil, fmt.Errorf("error querying non-cash accounts: %w", err)
+		}
+
+		for _, acc := range accounts {
+			c, ok := registered[acc.ID]
+			if !ok {
+				continue
+			}
+			changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
+			if err != nil {
+				return nil, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+			}
+
+			amount := changes.NetChange.Amount
+			if accountType == account.Revenue || accountType == account.Asset {
+				amount = amount.Neg()
+			}
+
+			label := c.Label
+			if label == "" {
+				label = nonCashLabels[c.Type]
+			}
+			if byLabel[c.Type] == nil {
+				byLabel[c.Type] = make(map[string]*LineItem)
+			}
+			item, ok := byLabel[c.Type][label]
+			if !ok {
+				item = &LineItem{Label: label, Amount: money.Money{Amount: decimal.Zero, Currency: opts.Currency}}
+				byLabel[c.Type][label] = item
+				labels[c.Type] = append(labels[c.Type], label)
+			}
+			item.Amount.Amount = item.Amount.Amount.Add(amount)
+			item.AccountIDs = append(item.AccountIDs, acc.ID)
+		}
+	}
+
+	for _, t := range nonCashOrder {
+		for _, label := range labels[t] {
+			item := byLabel[t][label]
+			if !item.Amount.Amount.IsZero() || opts.DetailLevel == "detailed" {
+				items = append(items, *item)
+			}
+		}
+	}
 	return items, nil
 }
 
-func (g *Generator) calculateCashReceipts(ctx context.Context, period reporting.ReportPeriod) ([]LineItem, error) {
+// calculateWorkingCapitalChanges adjusts net income for the movement in
+// current assets and liabilities other than cash. An increase in a current
+// asset uses cash and an increase in a current liability provides it. The
+// changes are listed per account when ShowWorkingCapital is set and as a
+// single line otherwise.
+func (g *Generator) calculateWorkingCapitalChanges(ctx context.Context, period reporting.ReportPeriod, opts StatementOptions, cfOpts CashFlowOptions) ([]LineItem, error) {
 	items := make([]LineItem, 0)
-	// TODO: Implement cash receipts calculation
-	// This should include:
-	// - Cash received from customers
-	// - Interest received
-	// - Other operating receipts
+	overrides := classificationsByAccount(cfOpts.Classifications)
+
+	total := decimal.Zero
+	accountIDs := make([]string, 0)
+	for _, accountType := range []account.AccountType{account.Asset, account.Liability} {
+		accounts := make([]*account.Account, 0)
+		if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
+			return nil, fmt.Errorf("error querying working capital accounts: %w", err)
+		}
+
+		for _, acc := range accounts {
+			classification, err := g.classify(ctx, acc, overrides)
+			if err != nil {
+				return nil, err
+			}
+			if !classification.IsWorkingCapital || g.isNonCash(acc.ID) {
+				continue
+			}
+			changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
+			if err != nil {
+				return nil, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+			}
+
+			amount := changes.NetChange.Amount
+			if accountType == account.Asset {
+				amount = amount.Neg()
+			}
+			if amount.IsZero() && opts.DetailLevel != "detailed" {
+				continue
+			}
+
+			total = total.Add(amount)
+			accountIDs = append(accountIDs, acc.ID)
+			if cfOpts.ShowWorkingCapital {
+				items = append(items, LineItem{
+					Label:      "Change in " + acc.Name,
+					Amount:     money.Money{Amount: amount, Currency: opts.Currency},
+					AccountIDs: []string{acc.ID},
+				})
+			}
+		}
+	}
+
+	if !cfOpts.ShowWorkingCapital && len(accountIDs) > 0 {
+		items = append(items, LineItem{
+			Label:      "Changes in working capital",
+			Amount:     money.Money{Amount: total, Currency: opts.Currency},
+			AccountIDs: accountIDs,
+		})
+	}
 	return items, nil
 }
 
-func (g *Generator) calculateCashPayments(ctx context.Context, period reporting.ReportPeriod) ([]LineItem, error) {
+// classificationsByAccount indexes cash flow classifications by account
+func classificationsByAccount(classifications []CashFlowClassification) map[string]CashFlowClassification {
+	byAccount := make(map[string]CashFlowClassification, len(classifications))
+	for _, c := range classifications {
+		byAccount[c.AccountID] = c
+	}
+	return byAccount
+}
+
+// classify returns the classification of an account for one statement: an
+// override from the statement options, else the generator's classifier
+func (g *Generator) classify(ctx context.Context, acc *account.Account, overrides map[string]CashFlowClassification) (CashFlowClassification, error) {
+	if c, ok := overrides[acc.ID]; ok {
+		return c, nil
+	}
+	c, err := g.classifier.Classify(ctx, acc)
+	if err != nil {
+		return c, fmt.Errorf("error classifying account %s: %w", acc.ID, err)
+	}
+	return c, nil
+}
+
+// operatingCash holds the operating cash receipts and payments of a period
+// by subcategory, with the accounts they were traced to
+type operatingCash struct {
+	receipts        map[string]decimal.Decimal
+	payments        map[string]decimal.Decimal
+	receiptAccounts map[string][]string
+	paymentAccounts map[string][]string
+}
+
+// add records a cash flow traced to an account
+func (c *operatingCash) add(receipt bool, subcategory, accountID string, amount decimal.Decimal) {
+	amounts, accounts := c.payments, c.paymentAccounts
+	if receipt {
+		amounts, accounts = c.receipts, c.receiptAccounts
+	}
+	amounts[subcategory] = amounts[subcategory].Add(amount)
+	for _, id := range accounts[subcategory] {
+		if id == accountID {
+			return
+		}
+	}
+	accounts[subcategory] = append(accounts[subcategory], accountID)
+}
+
+// traceOperatingCash follows every movement of a cash account to the
+// counter-entries on the other side of its transaction. A movement is shared
+// among those entries in proportion to their amounts; shares going to other
+// cash accounts are transfers and shares going to investing or financing
+// accounts are left to those sections.
+func (g *Generator) traceOperatingCash(ctx context.Context, period reporting.ReportPeriod, cfOpts CashFlowOptions) (*operatingCash, error) {
+	overrides := classificationsByAccount(cfOpts.Classifications)
+	byID := make(map[string]*account.Account)
+	cash := make([]*account.Account, 0)
+	for _, accountType := range []account.AccountType{account.Asset, account.Liability, account.Equity, account.Revenue, account.Expense} {
+		accounts := make([]*account.Account, 0)
+		if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
+			return nil, fmt.Errorf("error querying accounts: %w", err)
+		}
+		for _, acc := range accounts {
+			byID[acc.ID] = acc
+			if acc.IsCash() {
+				cash = append(cash, acc)
+			}
+		}
+	}
+
+	flows := &operatingCash{
+		receipts:        make(map[string]decimal.Decimal),
+		payments:        make(map[string]decimal.Decimal),
+		receiptAccounts: make(map[string][]string),
+		paymentAccounts: make(map[string][]string),
+	}
+	for _, acc := range cash {
+		changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+		}
+
+		for _, m := range changes.Movements {
+			receipt := m.Type == string(transaction.Debit)
+			total := decimal.Zero
+			for _, e := range m.CounterEntries {
+				if (e.Type == transaction.Debit) != receipt {
+					total = total.Add(e.Amount.Amount)
+				}
+			}
+			if total.IsZero() {
+				continue
+			}
+
+			for _, e := range m.CounterEntries {
+				if (e.Type == transaction.Debit) == receipt {
+					continue
+				}
+				counter := byID[e.AccountID]
+				if counter == nil || counter.IsCash() {
+					continue
+				}
+				classification, err := g.classify(ctx, counter, overrides)
+				if err != nil {
+					return nil, err
+				}
+				if classification.Category != Operating {
+					continue
+				}
+				subcategory := classification.Subcategory
+				if subcategory == "" && receipt {
+					subcategory = SubcategoryCustomers
+				} else if subcategory == "" {
+					subcategory = SubcategorySuppliers
+				}
+				share := e.Amount.Amount
+				if !total.Equal(m.Amount.Amount) {
+					share = share.Mul(m.Amount.Amount).Div(total).Round(m.Amount.Scale())
+				}
+				flows.add(receipt, subcategory, counter.ID, share)
+			}
+		}
+	}
+	return flows, nil
+}
+
+// directLabels are the line labels of direct-method receipts and payments
+// by subcategory, in the order they are listed
+var directLabels = []struct {
+	subcategory string
+	receipt     string
+	payment     string
+}{
+	{SubcategoryCustomers, "Cash received from customers", "Refunds paid to customers"},
+	{SubcategorySuppliers, "Refunds received from suppliers", "Cash paid to suppliers"},
+	{SubcategoryEmployees, "Cash received from employees", "Cash paid to employees"},
+	{SubcategoryInterest, "Interest received", "Interest paid"},
+	{SubcategoryTaxes, "Tax refunds received", "Taxes paid"},
+	{SubcategoryOther, "Other operating receipts", "Other operating payments"},
+}
+
+// directItems lists traced amounts in label order followed by custom
+// subcategories alphabetically
+func directItems(amounts map[string]decimal.Decimal, accounts map[string][]string, receipt bool, sign decimal.Decimal, currency string) []LineItem {
 	items := make([]LineItem, 0)
-	// TODO: Implement cash payments calculation
-	// This should include:
-	// - Payments to suppliers
-	// - Payments to employees
-	// - Interest paid
-	// - Taxes paid
-	// - Other operating payments
-	return items, nil
+	listed := make(map[string]bool)
+	appendItem := func(subcategory, label string) {
+		listed[subcategory] = true
+		amount, ok := amounts[subcategory]
+		if !ok {
+			return
+		}
+		items = append(items, LineItem{
+			Label:      label,
+			Amount:     money.Money{Amount: amount.Mul(sign), Currency: currency},
+			AccountIDs: accounts[subcategory],
+			Metadata:   map[string]interface{}{"subcategory": subcategory},
+		})
+	}
+
+	for _, l := range directLabels {
+		label := l.payment
+		if receipt {
+			label = l.receipt
+		}
+		appendItem(l.subcategory, label)
+	}
+	custom := make([]string, 0)
+	for subcategory := range amounts {
+		if !listed[subcategory] {
+			custom = append(custom, subcategory)
+		}
+	}
+	sort.Strings(custom)
+	for _, subcategory := range custom {
+		appendItem(subcategory, subcategory)
+	}
+	return items
+}
+
+// calculateCashReceipts lists operating cash received by subcategory
+func (g *Generator) calculateCashReceipts(flows *operatingCash, opts StatementOptions) []LineItem {
+	return directItems(flows.receipts, flows.receiptAccounts, true, decimal.NewFromInt(1), opts.Currency)
+}
+
+// calculateCashPayments lists operating cash paid by subcategory as
+// negative amounts
+func (g *Generator) calculateCashPayments(flows *operatingCash, opts StatementOptions) []LineItem {
+	return directItems(flows.payments, flows.paymentAccounts, false, decimal.NewFromInt(-1), opts.Currency)
 }
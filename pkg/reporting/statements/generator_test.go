@@ -6,8 +6,11 @@ import (
 	"time"
 
 	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/entity"
 	"github.com/johnayoung/finlib/pkg/money"
 	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -303,6 +306,8 @@ func TestGenerateCashFlow(t *testing.T) {
 			result := args.Get(2).(*[]*account.Account)
 			*result = mockLiabilities
 		}).Return(mockLiabilities, nil)
+	accounts.On("Query", ctx, account.Account{Type: account.Equity}, &[]*account.Account{}).
+		Return([]*account.Account{}, nil)
 
 	// Mock changes for accounts
 	calculator.On("CalculateChanges", ctx, "4001", period).
@@ -368,3 +373,549 @@ func TestGenerateCashFlow(t *testing.T) {
 	accounts.AssertExpectations(t)
 	calculator.AssertExpectations(t)
 }
+
+func TestWorkingCapitalChanges(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	g := NewGenerator(calculator, accounts)
+
+	periodStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+	current := map[string]interface{}{account.CurrentKey: true}
+
+	byType := map[account.AccountType][]*account.Account{
+		account.Revenue: {{ID: "4001", Name: "Sales", Type: account.Revenue}},
+		account.Expense: {},
+		account.Asset: {
+			{ID: "1000", Name: "Cash", Type: account.Asset, MetaData: map[string]interface{}{account.CurrentKey: true, account.CashKey: true}},
+			{ID: "1200", Name: "Accounts Receivable", Type: account.Asset, MetaData: current},
+			{ID: "1300", Name: "Prepaid Rent", Type: account.Asset},
+			{ID: "1500", Name: "Equipment", Type: account.Asset},
+		},
+		account.Liability: {
+			{ID: "2000", Name: "Accounts Payable", Type: account.Liability, MetaData: current},
+			{ID: "2500", Name: "Bank Loan", Type: account.Liability},
+		},
+		account.Equity: {},
+	}
+	for accountType, list := range byType {
+		list := list
+		accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*[]*account.Account)) = list
+			}).Return(nil, nil)
+	}
+	changes := map[string]int64{"4001": 10000, "1000": 6500, "1200": 4000, "1300": 1000, "1500": 2000, "2000": 1500, "2500": 0}
+	for id, amount := range changes {
+		calculator.On("CalculateChanges", ctx, id, period).
+			Return(&reporting.BalanceChange{NetChange: money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}}, nil)
+	}
+
+	classifications := []CashFlowClassification{{AccountID: "1300", Category: Operating, IsWorkingCapital: true}}
+	stmt, err := g.GenerateCashFlow(ctx, periodStart, periodEnd, StatementOptions{
+		Currency: "USD",
+		FormatOptions: map[string]interface{}{
+			"classifications":      classifications,
+			"show_working_capital": true,
+		},
+	})
+	assert.NoError(t, err)
+
+	operating := stmt.Sections[0]
+	assert.Len(t, operating.Items, 4)
+	assert.Equal(t, "Change in Accounts Receivable", operating.Items[1].Label)
+	assert.Equal(t, decimal.NewFromInt(-4000), operating.Items[1].Amount.Amount)
+	assert.Equal(t, "Change in Prepaid Rent", operating.Items[2].Label)
+	assert.Equal(t, decimal.NewFromInt(1500), operating.Items[3].Amount.Amount)
+	assert.Equal(t, decimal.NewFromInt(6500), operating.Total.Amount) // 10000 - 4000 - 1000 + 1500
+
+	// Working capital accounts are not investing or financing flows
+	for _, item := range append(stmt.Sections[1].Items, stmt.Sections[2].Items...) {
+		assert.NotContains(t, []string{"1200", "1300", "2000"}, item.AccountIDs[0])
+	}
+
+	stmt, err = g.GenerateCashFlow(ctx, periodStart, periodEnd, StatementOptions{
+		Currency:      "USD",
+		FormatOptions: map[string]interface{}{"classifications": classifications},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, stmt.Sections[0].Items, 2)
+	assert.Equal(t, "Changes in working capital", stmt.Sections[0].Items[1].Label)
+	assert.Equal(t, []string{"1200", "1300", "2000"}, stmt.Sections[0].Items[1].AccountIDs)
+	assert.Equal(t, decimal.NewFromInt(-3500), stmt.Sections[0].Items[1].Amount.Amount)
+}
+
+func TestNonCashAdjustments(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	g := NewGenerator(calculator, accounts)
+
+	periodStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+
+	byType := map[account.AccountType][]*account.Account{
+		account.Revenue: {
+			{ID: "4000", Name: "Sales", Type: account.Revenue},
+			{ID: "4900", Name: "Gain on Sale of Equipment", Type: account.Revenue},
+		},
+		account.Expense: {
+			{ID: "6000", Name: "Rent", Type: account.Expense},
+			{ID: "6800", Name: "Depreciation Expense", Type: account.Expense},
+			{ID: "6810", Name: "Amortization Expense", Type: account.Expense},
+		},
+		account.Asset:     {{ID: "1590", Name: "Accumulated Amortization", Type: account.Asset}},
+		account.Liability: {},
+		account.Equity:    {{ID: "3100", Name: "Additional Paid-in Capital", Type: account.Equity}},
+	}
+	for accountType, list := range byType {
+		list := list
+		accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*[]*account.Account)) = list
+			}).Return(nil, nil)
+	}
+	changes := map[string]int64{"4000": 50000, "4900": 2000, "6000": 12000, "6800": 3000, "6810": 500, "1590": -700, "3100": 1200}
+	for id, amount := range changes {
+		calculator.On("CalculateChanges", ctx, id, period).
+			Return(&reporting.BalanceChange{NetChange: money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}}, nil)
+	}
+
+	assert.NoError(t, g.RegisterNonCash(NonCashClassification{AccountID: "6800", Type: Depreciation}))
+	assert.NoError(t, g.RegisterNonCash(NonCashClassification{AccountID: "6810", Type: Amortization}))
+	assert.NoError(t, g.RegisterNonCash(NonCashClassification{AccountID: "1590", Type: Amortization, Label: "Amortization of intangibles"}))
+	assert.NoError(t, g.RegisterNonCash(NonCashClassification{AccountID: "3100", Type: StockCompensation}))
+	assert.NoError(t, g.RegisterNonCash(NonCashClassification{AccountID: "4900", Type: AssetSaleGainLoss}))
+	assert.Error(t, g.RegisterNonCash(NonCashClassification{AccountID: "6000", Type: "RENT"}))
+
+	stmt, err := g.GenerateCashFlow(ctx, periodStart, periodEnd, StatementOptions{Currency: "USD"})
+	assert.NoError(t, err)
+
+	items := stmt.Sections[0].Items
+	assert.Len(t, items, 6)
+	assert.Equal(t, decimal.NewFromInt(36500), items[0].Amount.Amount) // 52000 - 15500
+	assert.Equal(t, "Depreciation", items[1].Label)
+	assert.Equal(t, decimal.NewFromInt(3000), items[1].Amount.Amount)
+	assert.Equal(t, "Amortization", items[2].Label)
+	assert.Equal(t, "Amortization of intangibles", items[3].Label)
+	assert.Equal(t, decimal.NewFromInt(700), items[3].Amount.Amount)
+	assert.Equal(t, "Stock-based compensation", items[4].Label)
+	assert.Equal(t, decimal.NewFromInt(-2000), items[5].Amount.Amount)
+	assert.Equal(t, decimal.NewFromInt(39900), stmt.Sections[0].Total.Amount)
+
+	// Non-cash balance sheet accounts are not investing flows
+	assert.Empty(t, stmt.Sections[1].Items)
+}
+
+func TestDirectMethodCashFlow(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	g := NewGenerator(calculator, accounts)
+
+	periodStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	cashFlag := map[string]interface{}{account.CashKey: true}
+
+	byType := map[account.AccountType][]*account.Account{
+		account.Asset: {
+			{ID: "1000", Name: "Operating Cash", Type: account.Asset, MetaData: cashFlag},
+			{ID: "1010", Name: "Savings", Type: account.Asset, MetaData: cashFlag},
+			{ID: "1200", Name: "Accounts Receivable", Type: account.Asset, MetaData: map[string]interface{}{account.CurrentKey: true}},
+			{ID: "1500", Name: "Equipment", Type: account.Asset},
+		},
+		account.Liability: {{ID: "2300", Name: "Taxes Payable", Type: account.Liability, MetaData: map[string]interface{}{account.CurrentKey: true}}},
+		account.Equity:    {},
+		account.Revenue:   {{ID: "4000", Name: "Sales", Type: account.Revenue}},
+		account.Expense: {
+			{ID: "6000", Name: "Rent", Type: account.Expense},
+			{ID: "6100", Name: "Wages", Type: account.Expense},
+			{ID: "7000", Name: "Interest Expense", Type: account.Expense},
+		},
+	}
+	for accountType, list := range byType {
+		list := list
+		accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*[]*account.Account)) = list
+			}).Return(nil, nil)
+	}
+
+	entry := func(accountID string, amount int64, entryType transaction.EntryType) transaction.Entry {
+		return transaction.Entry{AccountID: accountID, Amount: usd(amount), Type: entryType}
+	}
+	movement := func(amount int64, entryType transaction.EntryType, counter ...transaction.Entry) reporting.BalanceMovement {
+		return reporting.BalanceMovement{Amount: usd(amount), Type: string(entryType), CounterEntries: counter}
+	}
+	calculator.On("CalculateChanges", ctx, "1000", period).Return(&reporting.BalanceChange{Movements: []reporting.BalanceMovement{
+		movement(800, transaction.Debit, entry("1200", 800, transaction.Credit)),
+		movement(200, transaction.Debit, entry("4000", 200, transaction.Credit)),
+		movement(1000, transaction.Credit, entry("6000", 600, transaction.Debit), entry("6100", 400, transaction.Debit)),
+		movement(300, transaction.Credit, entry("2300", 300, transaction.Debit)),
+		movement(50, transaction.Credit, entry("7000", 50, transaction.Debit)),
+		movement(5000, transaction.Credit, entry("1500", 5000, transaction.Debit)),
+		movement(700, transaction.Credit, entry("1010", 700, transaction.Debit)),
+	}}, nil)
+	calculator.On("CalculateChanges", ctx, "1010", period).Return(&reporting.BalanceChange{Movements: []reporting.BalanceMovement{
+		movement(700, transaction.Debit, entry("1000", 700, transaction.Credit)),
+	}}, nil)
+
+	// Investing and financing sections are generated as before
+	for _, id := range []string{"1200", "1500", "2300"} {
+		calculator.On("CalculateChanges", ctx, id, period).Return(&reporting.BalanceChange{NetChange: usd(0)}, nil)
+	}
+
+	stmt, err := g.GenerateCashFlow(ctx, periodStart, periodEnd, StatementOptions{
+		Currency: "USD",
+		FormatOptions: map[string]interface{}{
+			"method": string(Direct),
+			"classifications": []CashFlowClassification{
+				{AccountID: "6100", Category: Operating, Subcategory: SubcategoryEmployees},
+				{AccountID: "7000", Category: Operating, Subcategory: SubcategoryInterest},
+				{AccountID: "2300", Category: Operating, Subcategory: SubcategoryTaxes, IsWorkingCapital: true},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	items := stmt.Sections[0].Items
+	assert.Len(t, items, 5)
+	assert.Equal(t, "Cash received from customers", items[0].Label)
+	assert.Equal(t, decimal.NewFromInt(1000), items[0].Amount.Amount)
+	assert.Equal(t, []string{"1200", "4000"}, items[0].AccountIDs)
+	assert.Equal(t, "Cash paid to suppliers", items[1].Label)
+	assert.Equal(t, decimal.NewFromInt(-600), items[1].Amount.Amount)
+	assert.Equal(t, "Cash paid to employees", items[2].Label)
+	assert.Equal(t, decimal.NewFromInt(-400), items[2].Amount.Amount)
+	assert.Equal(t, "Interest paid", items[3].Label)
+	assert.Equal(t, "Taxes paid", items[4].Label)
+	// Equipment purchases and transfers between cash accounts are excluded
+	assert.Equal(t, decimal.NewFromInt(-350), stmt.Sections[0].Total.Amount)
+}
+
+func TestCashFlowClassifier(t *testing.T) {
+	ctx := context.Background()
+	classifier := NewCashFlowClassifier(memory.NewMemoryStore(), []CodeRange{
+		{From: "1700", To: "1799", Category: Operating},
+	})
+
+	classify := func(acc *account.Account) CashFlowClassification {
+		c, err := classifier.Classify(ctx, acc)
+		assert.NoError(t, err)
+		return c
+	}
+	current := map[string]interface{}{account.CurrentKey: true}
+	assert.Equal(t, Unclassified, classify(&account.Account{ID: "1000", Type: account.Asset, MetaData: map[string]interface{}{account.CashKey: true}}).Category)
+	assert.Equal(t, Operating, classify(&account.Account{ID: "4000", Type: account.Revenue}).Category)
+	assert.True(t, classify(&account.Account{ID: "1200", Type: account.Asset, MetaData: current}).IsWorkingCapital)
+	assert.Equal(t, Investing, classify(&account.Account{ID: "1500", Code: "1500", Type: account.Asset}).Category)
+	assert.Equal(t, Financing, classify(&account.Account{ID: "2500", Type: account.Liability}).Category)
+	assert.Equal(t, Financing, classify(&account.Account{ID: "3000", Type: account.Equity}).Category)
+
+	deferred := &account.Account{ID: "1710", Code: "1710", Type: account.Asset}
+	assert.Equal(t, Operating, classify(deferred).Category, "code range applies before the type default")
+
+	assert.NoError(t, classifier.Set(ctx, CashFlowClassification{AccountID: "1710", Category: Investing}))
+	assert.NoError(t, classifier.Set(ctx, CashFlowClassification{AccountID: "1710", Category: Financing, Subcategory: "leases"}))
+	assert.Equal(t, "leases", classify(deferred).Subcategory)
+	assert.NoError(t, classifier.Remove(ctx, "1710"))
+	assert.Equal(t, Operating, classify(deferred).Category)
+	_, err := classifier.Get(ctx, "1710")
+	assert.ErrorIs(t, err, ErrClassificationNotFound)
+	assert.ErrorIs(t, classifier.Set(ctx, CashFlowClassification{AccountID: "1710", Category: "OTHER"}), ErrInvalidClassification)
+
+	t.Run("Statement sections", func(t *testing.T) {
+		calculator := new(mockReportCalculator)
+		accounts := new(mockAccountRepository)
+		g := NewGenerator(calculator, accounts)
+		g.SetClassifier(classifier)
+		assert.NoError(t, classifier.Set(ctx, CashFlowClassification{AccountID: "2600", Category: Investing}))
+
+		periodStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		periodEnd := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+		period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+		byType := map[account.AccountType][]*account.Account{
+			account.Revenue: {},
+			account.Expense: {},
+			account.Asset: {
+				{ID: "1000", Name: "Cash", Type: account.Asset, MetaData: map[string]interface{}{account.CashKey: true}},
+				{ID: "1500", Code: "1500", Name: "Equipment", Type: account.Asset},
+				{ID: "1710", Code: "1710", Name: "Deferred Costs", Type: account.Asset},
+			},
+			account.Liability: {
+				{ID: "2500", Name: "Bank Loan", Type: account.Liability},
+				{ID: "2600", Name: "Deposits Held for Equipment", Type: account.Liability},
+			},
+			account.Equity: {{ID: "3000", Name: "Share Capital", Type: account.Equity}},
+		}
+		for accountType, list := range byType {
+			list := list
+			accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+				Run(func(args mock.Arguments) {
+					*(args.Get(2).(*[]*account.Account)) = list
+				}).Return(nil, nil)
+		}
+		for id, amount := range map[string]int64{"1500": -4000, "2500": 2500, "2600": 300, "3000": 10000} {
+			calculator.On("CalculateChanges", ctx, id, period).
+				Return(&reporting.BalanceChange{NetChange: money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}}, nil)
+		}
+
+		stmt, err := g.GenerateCashFlow(ctx, periodStart, periodEnd, StatementOptions{Currency: "USD"})
+		assert.NoError(t, err)
+
+		investing, financing := stmt.Sections[1], stmt.Sections[2]
+		assert.Len(t, investing.Items, 2)
+		assert.Equal(t, "Equipment", investing.Items[0].Label)
+		assert.Equal(t, "Deposits Held for Equipment", investing.Items[1].Label)
+		assert.Len(t, financing.Items, 2)
+		assert.Equal(t, "Share Capital", financing.Items[1].Label)
+		assert.Equal(t, decimal.NewFromInt(12500), financing.Total.Amount)
+		calculator.AssertNotCalled(t, "CalculateChanges", ctx, "1000", period)
+	})
+}
+
+func TestApplyVariance(t *testing.T) {
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	stmt := &Statement{
+		Sections: []StatementSection{
+			{Title: "Revenue", Items: []LineItem{
+				{Label: "Sales", Amount: usd(1200)},
+				{Label: "Services", Amount: usd(505)},
+				{Label: "Licensing", Amount: usd(50)},
+			}, Total: usd(1755)},
+		},
+		ComparativePeriod: &Statement{
+			Sections: []StatementSection{
+				{Title: "Revenue", Items: []LineItem{
+					{Label: "Sales", Amount: usd(1000)},
+					{Label: "Services", Amount: usd(500)},
+				}, Total: usd(1500)},
+			},
+		},
+	}
+
+	ApplyVariance(stmt, VarianceThresholds{Amount: decimal.NewFromInt(1000), Percent: decimal.NewFromInt(10)})
+
+	items := stmt.Sections[0].Items
+	sales := items[0].Metadata[VarianceKey].(Variance)
+	assert.Equal(t, decimal.NewFromInt(200), sales.Amount.Amount)
+	assert.Equal(t, "20", sales.Percent.String())
+	assert.True(t, sales.Significant)
+
+	services := items[1].Metadata[VarianceKey].(Variance)
+	assert.Equal(t, "1", services.Percent.String())
+	assert.False(t, services.Significant)
+
+	licensing := items[2].Metadata[VarianceKey].(Variance)
+	assert.Nil(t, licensing.Percent)
+	assert.True(t, licensing.Significant, "a line new in the period is a significant swing")
+
+	total := stmt.Sections[0].Variance
+	assert.Equal(t, decimal.NewFromInt(255), total.Amount.Amount)
+	assert.Equal(t, "17", total.Percent.String())
+	assert.Equal(t, []string{"Revenue: Sales", "Revenue: Licensing", "Revenue: Total"}, stmt.Metadata[SignificantVariancesKey])
+
+	t.Run("Thresholds disabled", func(t *testing.T) {
+		v := NewVariance(usd(-300), usd(-100), VarianceThresholds{})
+		assert.Equal(t, "-200", v.Percent.String())
+		assert.False(t, v.Significant)
+	})
+}
+
+func TestConsolidation(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	consolidator := NewConsolidator(NewGenerator(calculator, accounts), nil)
+	assert.NoError(t, consolidator.AddEliminationRule(EliminationRule{ID: "IC-1", AccountIDs: []string{"US-1300", "UK-2300"}}))
+
+	byEntity := map[string]map[account.AccountType][]*account.Account{
+		"US": {
+			account.Asset: {
+				{ID: "US-1000", Name: "Cash", Type: account.Asset},
+				{ID: "US-1300", Name: "Due from UK", Type: account.Asset},
+			},
+			account.Liability: {},
+			account.Equity:    {{ID: "US-3000", Name: "Share Capital", Type: account.Equity}},
+		},
+		"UK": {
+			account.Asset:     {{ID: "UK-1000", Name: "Cash", Type: account.Asset}},
+			account.Liability: {{ID: "UK-2300", Name: "Due to US", Type: account.Liability}},
+			account.Equity:    {{ID: "UK-3000", Name: "Share Capital", Type: account.Equity}},
+		},
+	}
+	for entityID, types := range byEntity {
+		for accountType, list := range types {
+			list := list
+			accounts.On("Query", mock.Anything, account.Account{Type: accountType, EntityID: entityID}, mock.Anything).
+				Run(func(args mock.Arguments) {
+					*(args.Get(2).(*[]*account.Account)) = list
+				}).Return(nil, nil)
+		}
+	}
+	balances := map[string]money.Money{
+		"US-1000": {Amount: decimal.NewFromInt(1000), Currency: "USD"},
+		"US-1300": {Amount: decimal.NewFromInt(500), Currency: "USD"},
+		"US-3000": {Amount: decimal.NewFromInt(1500), Currency: "USD"},
+		"UK-1000": {Amount: decimal.NewFromInt(800), Currency: "GBP"},
+		"UK-2300": {Amount: decimal.NewFromInt(400), Currency: "GBP"},
+		"UK-3000": {Amount: decimal.NewFromInt(400), Currency: "GBP"},
+	}
+	for id, balance := range balances {
+		calculator.On("CalculateBalance", mock.Anything, id, mock.Anything).Return(balance, nil)
+	}
+
+	asOf := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	entities := []*entity.Entity{
+		{ID: "US", BaseCurrency: "USD"},
+		{ID: "UK", BaseCurrency: "GBP"},
+	}
+	stmt, err := consolidator.ConsolidateBalanceSheet(ctx, entities, asOf, ConsolidationOptions{
+		Currency:  "USD",
+		GroupName: "Group",
+		Rates:     map[string]decimal.Decimal{"UK": decimal.NewFromFloat(1.25)},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Consolidated Balance Sheet", stmt.Title)
+	assert.Equal(t, "Group", stmt.Entity)
+	assets, liabilities, equity := stmt.Sections[0], stmt.Sections[1], stmt.Sections[2]
+	assert.Len(t, assets.Items, 1)
+	assert.Equal(t, "Cash", assets.Items[0].Label)
+	assert.Equal(t, []string{"US-1000", "UK-1000"}, assets.Items[0].AccountIDs)
+	assert.True(t, assets.Total.Amount.Equal(decimal.NewFromInt(2000)))
+	assert.Empty(t, liabilities.Items)
+	assert.True(t, equity.Total.Amount.Equal(decimal.NewFromInt(2000)))
+	assert.Equal(t, "USD", equity.Total.Currency)
+
+	eliminations := stmt.Metadata[EliminationsKey].([]Elimination)
+	assert.Len(t, eliminations, 2)
+	assert.Equal(t, "UK", eliminations[1].EntityID)
+	assert.True(t, eliminations[1].Amount.Amount.Equal(decimal.NewFromInt(500)), "eliminated at the translated amount")
+	assert.Equal(t, "1.25", stmt.Metadata[TranslationRatesKey].(map[string]string)["UK"])
+
+	t.Run("Missing rate", func(t *testing.T) {
+		_, err := consolidator.ConsolidateBalanceSheet(ctx, entities, asOf, ConsolidationOptions{Currency: "USD"})
+		assert.ErrorIs(t, err, money.ErrRateNotFound)
+	})
+}
+
+func TestSegmentIncomeStatement(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	g := NewGenerator(calculator, accounts)
+
+	periodStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+
+	byType := map[account.AccountType][]*account.Account{
+		account.Revenue: {{ID: "4000", Name: "Sales", Type: account.Revenue}},
+		account.Expense: {{ID: "6000", Name: "Salaries", Type: account.Expense,
+			Dimensions: map[string]string{account.DimensionDepartment: "ops"}}},
+	}
+	for accountType, list := range byType {
+		list := list
+		accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*[]*account.Account)) = list
+			}).Return(nil, nil)
+	}
+
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	dept := func(name string) map[string]string {
+		return map[string]string{account.DimensionDepartment: name}
+	}
+	calculator.On("CalculateChanges", ctx, "4000", period).Return(&reporting.BalanceChange{Movements: []reporting.BalanceMovement{
+		{Amount: usd(1000), Type: string(transaction.Credit), Dimensions: dept("sales")},
+		{Amount: usd(400), Type: string(transaction.Credit), Dimensions: dept("ops")},
+		{Amount: usd(100), Type: string(transaction.Debit), Dimensions: dept("sales")},
+		{Amount: usd(50), Type: string(transaction.Credit)},
+	}}, nil)
+	calculator.On("CalculateChanges", ctx, "6000", period).Return(&reporting.BalanceChange{Movements: []reporting.BalanceMovement{
+		{Amount: usd(300), Type: string(transaction.Debit)},
+		{Amount: usd(200), Type: string(transaction.Debit), Dimensions: dept("sales")},
+	}}, nil)
+
+	stmt, err := g.GenerateSegmentIncomeStatement(ctx, account.DimensionDepartment, periodStart, periodEnd, StatementOptions{Currency: "USD"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"ops", "sales", UnassignedSegment}, stmt.Segments)
+	sales := stmt.Sections[0].Lines[0]
+	assert.Equal(t, "900", sales.Amounts["sales"].Amount.String())
+	assert.Equal(t, "50", sales.Amounts[UnassignedSegment].Amount.String())
+	assert.Equal(t, "1350", sales.Total.Amount.String())
+
+	salaries := stmt.Sections[1].Lines[0]
+	assert.Equal(t, "300", salaries.Amounts["ops"].Amount.String(), "entries inherit the account's department")
+	assert.True(t, salaries.Amounts[UnassignedSegment].Amount.IsZero())
+
+	assert.Equal(t, "100", stmt.NetIncome["ops"].Amount.String())
+	assert.Equal(t, "700", stmt.NetIncome["sales"].Amount.String())
+	assert.Equal(t, "50", stmt.NetIncome[UnassignedSegment].Amount.String())
+	assert.Equal(t, "850", stmt.TotalNetIncome.Amount.String())
+}
+
+func TestDrillDown(t *testing.T) {
+	ctx := context.Background()
+	calculator := new(mockReportCalculator)
+	accounts := new(mockAccountRepository)
+	g := NewGenerator(calculator, accounts)
+
+	asOf := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	for accountType, list := range map[account.AccountType][]*account.Account{
+		account.Asset:     {{ID: "1000", Name: "Cash", Type: account.Asset}},
+		account.Liability: {},
+		account.Equity:    {},
+	} {
+		list := list
+		accounts.On("Query", ctx, account.Account{Type: accountType}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*[]*account.Account)) = list
+			}).Return(nil, nil)
+	}
+
+	usd := money.Money{Amount: decimal.NewFromInt(100), Currency: "USD"}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	calculator.On("CalculateBalance", ctx, "1000", reporting.ReportPeriod{End: asOf}).Return(usd, nil)
+	calculator.On("CalculateChanges", ctx, "1000", reporting.ReportPeriod{End: asOf}).Return(&reporting.BalanceChange{
+		Movements: []reporting.BalanceMovement{
+			{Date: day(3), Reference: "TX-3"},
+			{Date: day(1), Reference: "TX-1"},
+			{Date: day(3), Reference: "TX-2"},
+			{Date: day(1), Reference: "TX-1"}, // second entry of the same transaction
+			{Date: day(9), Reference: "TX-9"},
+		},
+	}, nil)
+
+	stmt, err := g.GenerateBalanceSheet(ctx, asOf, StatementOptions{
+		Currency:  "USD",
+		DrillDown: DrillDownOptions{Enabled: true, Limit: 2},
+	})
+	assert.NoError(t, err)
+
+	cash := stmt.Sections[0].Items[0]
+	assert.Equal(t, []string{"TX-1", "TX-2"}, cash.Metadata[DrillDownKey])
+	assert.Equal(t, 4, cash.Metadata[DrillDownCountKey])
+
+	page, err := g.DrillDown(ctx, cash, reporting.ReportPeriod{End: asOf}, 2, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TX-3", "TX-9"}, page.TransactionIDs)
+	assert.Equal(t, 4, page.Total)
+
+	page, err = g.DrillDown(ctx, cash, reporting.ReportPeriod{End: asOf}, 10, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, page.TransactionIDs)
+}
@@ -0,0 +1,177 @@
+package statements
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/shopspring/decimal"
+)
+
+// UnassignedSegment is the segment of entries without a value for the
+// reported dimension
+const UnassignedSegment = "UNASSIGNED"
+
+// SegmentLine is a statement line split into one amount per segment
+type SegmentLine struct {
+	Label      string                 `json:"label"`
+	AccountIDs []string               `json:"account_ids"`
+	Amounts    map[string]money.Money `json:"amounts"`
+	// Consolidated amount across all segments
+	Total money.Money `json:"total"`
+}
+
+// SegmentSection is a section of a segment statement with its totals per
+// segment
+type SegmentSection struct {
+	Title  string                 `json:"title"`
+	Lines  []SegmentLine          `json:"lines"`
+	Totals map[string]money.Money `json:"totals"`
+	Total  money.Money            `json:"total"`
+}
+
+// SegmentStatement is a columnar income statement with a column per value
+// of a dimension, such as department or project, and a consolidated column
+type SegmentStatement struct {
+	Type        StatementType `json:"type"`
+	Title       string        `json:"title"`
+	Dimension   string        `json:"dimension"`
+	AsOf        time.Time     `json:"as_of"`
+	PeriodStart *time.Time    `json:"period_start,omitempty"`
+	Currency    string        `json:"currency"`
+	// Segment columns in order; UnassignedSegment comes last when present
+	Segments []string         `json:"segments"`
+	Sections []SegmentSection `json:"sections"`
+	// Net income per segment and consolidated
+	NetIncome      map[string]money.Money `json:"net_income"`
+	TotalNetIncome money.Money            `json:"total_net_income"`
+}
+
+// GenerateSegmentIncomeStatement creates an income statement split by the
+// values of a dimension. Each entry counts towards the segment of its own
+// dimension tag, falling back to its account's tag and then to
+// UnassignedSegment.
+func (g *Generator) GenerateSegmentIncomeStatement(ctx context.Context, dimension string, periodStart, periodEnd time.Time, opts StatementOptions) (*SegmentStatement, error) {
+	if dimension == "" {
+		return nil, fmt.Errorf("dimension is required")
+	}
+
+	stmt := &SegmentStatement{
+		Type:        IncomeStatement,
+		Title:       "Income Statement by " + dimension,
+		Dimension:   dimension,
+		AsOf:        periodEnd,
+		PeriodStart: &periodStart,
+		Currency:    opts.Currency,
+		Sections:    make([]SegmentSection, 0, 2),
+	}
+
+	period := reporting.ReportPeriod{Start: periodStart, End: periodEnd}
+	segments := make(map[string]bool)
+
+	revenue, err := g.generateSegmentSection(ctx, "Revenue", account.Revenue, dimension, period, opts, segments)
+	if err != nil {
+		return nil, fmt.Errorf("error generating revenue section: %w", err)
+	}
+	expenses, err := g.generateSegmentSection(ctx, "Expenses", account.Expense, dimension, period, opts, segments)
+	if err != nil {
+		return nil, fmt.Errorf("error generating expense section: %w", err)
+	}
+	stmt.Sections = append(stmt.Sections, revenue, expenses)
+
+	stmt.Segments = make([]string, 0, len(segments))
+	for segment := range segments {
+		stmt.Segments = append(stmt.Segments, segment)
+	}
+	sort.Slice(stmt.Segments, func(i, j int) bool {
+		a, b := stmt.Segments[i], stmt.Segments[j]
+		if (a == UnassignedSegment) != (b == UnassignedSegment) {
+			return b == UnassignedSegment
+		}
+		return a < b
+	})
+
+	stmt.NetIncome = make(map[string]money.Money, len(stmt.Segments))
+	for _, segment := range stmt.Segments {
+		net := revenue.Totals[segment].Amount.Sub(expenses.Totals[segment].Amount)
+		stmt.NetIncome[segment] = money.Money{Amount: net, Currency: opts.Currency}
+	}
+	stmt.TotalNetIncome = money.Money{Amount: revenue.Total.Amount.Sub(expenses.Total.Amount), Currency: opts.Currency}
+
+	// Give every line and total a column for every segment
+	for i := range stmt.Sections {
+		section := &stmt.Sections[i]
+		for _, segment := range stmt.Segments {
+			for j := range section.Lines {
+				if _, ok := section.Lines[j].Amounts[segment]; !ok {
+					section.Lines[j].Amounts[segment] = money.Money{Amount: decimal.Zero, Currency: opts.Currency}
+				}
+			}
+			if _, ok := section.Totals[segment]; !ok {
+				section.Totals[segment] = money.Money{Amount: decimal.Zero, Currency: opts.Currency}
+			}
+		}
+	}
+
+	return stmt, nil
+}
+
+// generateSegmentSection splits the period movements of each account of a
+// type by segment, recording every segment seen
+func (g *Generator) generateSegmentSection(ctx context.Context, title string, accountType account.AccountType, dimension string, period reporting.ReportPeriod, opts StatementOptions, segments map[string]bool) (SegmentSection, error) {
+	section := SegmentSection{
+		Title:  title,
+		Lines:  make([]SegmentLine, 0),
+		Totals: make(map[string]money.Money),
+		Total:  money.Money{Amount: decimal.Zero, Currency: opts.Currency},
+	}
+
+	accounts := make([]*account.Account, 0)
+	if err := g.accounts.Query(ctx, accountsOfType(ctx, accountType), &accounts); err != nil {
+		return section, fmt.Errorf("error querying accounts: %w", err)
+	}
+
+	for _, acc := range accounts {
+		changes, err := g.calculator.CalculateChanges(ctx, acc.ID, period)
+		if err != nil {
+			return section, fmt.Errorf("error calculating changes for account %s: %w", acc.ID, err)
+		}
+
+		line := SegmentLine{
+			Label:      acc.Name,
+			AccountIDs: []string{acc.ID},
+			Amounts:    make(map[string]money.Money),
+			Total:      money.Money{Amount: decimal.Zero, Currency: opts.Currency},
+		}
+		for _, m := range changes.Movements {
+			segment := acc.EntryDimensions(m.Dimensions)[dimension]
+			if segment == "" {
+				segment = UnassignedSegment
+			}
+			amount := m.Signed(acc.Type)
+			line.Amounts[segment] = addAmount(line.Amounts[segment], amount, opts.Currency)
+			line.Total.Amount = line.Total.Amount.Add(amount)
+		}
+
+		if line.Total.Amount.IsZero() && len(line.Amounts) == 0 && opts.DetailLevel != "detailed" {
+			continue
+		}
+		for segment, amount := range line.Amounts {
+			segments[segment] = true
+			section.Totals[segment] = addAmount(section.Totals[segment], amount.Amount, opts.Currency)
+		}
+		section.Total.Amount = section.Total.Amount.Add(line.Total.Amount)
+		section.Lines = append(section.Lines, line)
+	}
+
+	return section, nil
+}
+
+// addAmount adds to an amount that may not be set yet
+func addAmount(m money.Money, amount decimal.Decimal, currency string) money.Money {
+	return money.Money{Amount: m.Amount.Add(amount), Currency: currency}
+}
@@ -36,6 +36,10 @@ type StatementSection struct {
 	Items []LineItem `json:"items"`
 	// Total for this section
 	Total money.Money `json:"total"`
+
+	// Variance of the total against the comparative period, set by
+	// ApplyVariance
+	Variance *Variance `json:"variance,omitempty"`
 }
 
 // Statement represents a financial statement
@@ -74,6 +78,13 @@ type StatementOptions struct {
 	AccountGroupings map[string][]string
 	// Custom formatting options
 	FormatOptions map[string]interface{}
+
+	// Thresholds for flagging significant variances against the
+	// comparative period
+	Variance VarianceThresholds
+
+	// Transaction IDs attached to line items for drill-down
+	DrillDown DrillDownOptions
 }
 
 // CashFlowCategory represents the category of cash flow
@@ -86,6 +97,17 @@ const (
 	Unclassified CashFlowCategory = "UNCLASSIFIED"
 )
 
+// Operating subcategories the direct method groups cash receipts and
+// payments by; any other subcategory is listed under its own name
+const (
+	SubcategoryCustomers = "customers"
+	SubcategorySuppliers = "suppliers"
+	SubcategoryEmployees = "employees"
+	SubcategoryInterest  = "interest"
+	SubcategoryTaxes     = "taxes"
+	SubcategoryOther     = "other"
+)
+
 // CashFlowMethod represents the method used to calculate operating cash flow
 type CashFlowMethod string
 
@@ -106,6 +128,29 @@ type CashFlowClassification struct {
 	IsWorkingCapital bool `json:"is_working_capital,omitempty"`
 }
 
+// NonCashType identifies an item added back to net income because it
+// affected income without moving cash
+type NonCashType string
+
+const (
+	Depreciation      NonCashType = "DEPRECIATION"
+	Amortization      NonCashType = "AMORTIZATION"
+	StockCompensation NonCashType = "STOCK_COMPENSATION"
+	AssetSaleGainLoss NonCashType = "ASSET_SALE_GAIN_LOSS"
+	OtherNonCash      NonCashType = "OTHER_NON_CASH"
+)
+
+// NonCashClassification marks an account whose period change is a non-cash
+// adjustment in the indirect method
+type NonCashClassification struct {
+	// Account ID this classification applies to
+	AccountID string `json:"account_id"`
+	// Kind of non-cash item
+	Type NonCashType `json:"type"`
+	// Optional label replacing the default for the type
+	Label string `json:"label,omitempty"`
+}
+
 // CashFlowOptions represents options specific to cash flow statement generation
 type CashFlowOptions struct {
 	// Method to use for calculating operating cash flow
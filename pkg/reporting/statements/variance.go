@@ -0,0 +1,133 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// Metadata keys written by ApplyVariance
+const (
+	// VarianceKey holds the Variance of a line item
+	VarianceKey = "variance"
+	// SignificantVariancesKey lists the significant swings of a statement
+	// as "Section: Label", with "Section: Total" for section totals
+	SignificantVariancesKey = "significant_variances"
+)
+
+// VarianceThresholds decide when a variance is a significant swing. A
+// variance is significant when it reaches either threshold; zero disables a
+// threshold.
+type VarianceThresholds struct {
+	// Absolute change in the statement currency
+	Amount decimal.Decimal
+	// Change as a percentage of the comparative amount, e.g. 10 for 10%
+	Percent decimal.Decimal
+}
+
+// Variance compares an amount with the same line of the comparative period
+type Variance struct {
+	Previous money.Money `json:"previous"`
+	// Current less previous
+	Amount money.Money `json:"amount"`
+	// Amount as a percentage of the previous amount; nil when the previous
+	// amount is zero
+	Percent     *decimal.Decimal `json:"percent,omitempty"`
+	Significant bool             `json:"significant"`
+}
+
+// NewVariance computes the variance of current against previous
+func NewVariance(current, previous money.Money, thresholds VarianceThresholds) Variance {
+	diff := current.Amount.Sub(previous.Amount)
+	v := Variance{
+		Previous: previous,
+		Amount:   money.Money{Amount: diff, Currency: current.Currency},
+	}
+	if !previous.Amount.IsZero() {
+		percent := diff.Div(previous.Amount.Abs()).Mul(decimal.NewFromInt(100)).Round(2)
+		v.Percent = &percent
+	}
+
+	if !thresholds.Amount.IsZero() && diff.Abs().GreaterThanOrEqual(thresholds.Amount) {
+		v.Significant = true
+	}
+	if !thresholds.Percent.IsZero() && !diff.IsZero() {
+		// Any change from zero is an unbounded swing
+		if v.Percent == nil || v.Percent.Abs().GreaterThanOrEqual(thresholds.Percent) {
+			v.Significant = true
+		}
+	}
+	return v
+}
+
+// ApplyVariance compares a statement with its comparative period. Each line
+// item gets its Variance in metadata under VarianceKey, matched to the
+// comparative line of the same label in the section of the same title; lines
+// new in the period are compared with zero. Each section gets the variance
+// of its total, and the significant swings are listed in the statement
+// metadata. Statements without a comparative period are left unchanged.
+func ApplyVariance(stmt *Statement, thresholds VarianceThresholds) {
+	if stmt == nil || stmt.ComparativePeriod == nil {
+		return
+	}
+
+	previous := make(map[string]StatementSection)
+	for _, section := range stmt.ComparativePeriod.Sections {
+		previous[section.Title] = section
+	}
+
+	significant := make([]string, 0)
+	for i := range stmt.Sections {
+		section := &stmt.Sections[i]
+		prevSection, found := previous[section.Title]
+		prevAmounts := make(map[string]money.Money)
+		if found {
+			collectItemAmounts(prevSection.Items, prevAmounts)
+		}
+
+		var apply func(items []LineItem)
+		apply = func(items []LineItem) {
+			for j := range items {
+				item := &items[j]
+				prev, ok := prevAmounts[item.Label]
+				if !ok {
+					prev = money.Money{Amount: decimal.Zero, Currency: item.Amount.Currency}
+				}
+				v := NewVariance(item.Amount, prev, thresholds)
+				if item.Metadata == nil {
+					item.Metadata = make(map[string]interface{})
+				}
+				item.Metadata[VarianceKey] = v
+				if v.Significant {
+					significant = append(significant, fmt.Sprintf("%s: %s", section.Title, item.Label))
+				}
+				apply(item.SubItems)
+			}
+		}
+		apply(section.Items)
+
+		prevTotal := prevSection.Total
+		if !found {
+			prevTotal = money.Money{Amount: decimal.Zero, Currency: section.Total.Currency}
+		}
+		v := NewVariance(section.Total, prevTotal, thresholds)
+		section.Variance = &v
+		if v.Significant {
+			significant = append(significant, fmt.Sprintf("%s: Total", section.Title))
+		}
+	}
+
+	if stmt.Metadata == nil {
+		stmt.Metadata = make(map[string]interface{})
+	}
+	stmt.Metadata[SignificantVariancesKey] = significant
+}
+
+// collectItemAmounts indexes line item amounts by label
+func collectItemAmounts(items []LineItem, amounts map[string]money.Money) {
+	for _, item := range items {
+		amounts[item.Label] = item.Amount
+		collectItemAmounts(item.SubItems, amounts)
+	}
+}
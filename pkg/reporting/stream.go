@@ -0,0 +1,280 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/journal"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// Detail keys of streamed report lines
+const (
+	// DetailKind is LineOpening, LineEntry or LineClosing
+	DetailKind      = "kind"
+	DetailDate      = "date"
+	DetailReference = "reference"
+	DetailEntryType = "entry_type"
+	// DetailBalance is the running balance after an entry
+	DetailBalance = "balance"
+	// DetailError is set on the last line of a stream that failed
+	DetailError = "error"
+)
+
+// Kinds of streamed report lines
+const (
+	LineOpening = "opening"
+	LineEntry   = "entry"
+	LineClosing = "closing"
+)
+
+// ParameterAccountIDs names the report option parameter listing the
+// accounts to stream ([]string); required for account statements
+const ParameterAccountIDs = "account_ids"
+
+// DefaultStreamPageSize is the number of transactions read per query
+const DefaultStreamPageSize = 500
+
+// ReportStreamer produces report lines one at a time instead of building
+// the whole report
+type ReportStreamer interface {
+	// GenerateReportStream starts sending the lines of a report on the
+	// returned channel, which is closed when the report is complete
+	GenerateReportStream(ctx context.Context, def *ReportDefinition, opts ReportOptions) (<-chan *ReportLine, error)
+}
+
+// StreamGenerator streams general ledger and account statement reports.
+// Transactions are read a page at a time, so memory use does not grow with
+// the size of the ledger.
+type StreamGenerator struct {
+	accounts     account.Repository
+	transactions storage.Repository
+	calculator   ReportCalculator
+	pageSize     int
+}
+
+// NewStreamGenerator creates a streaming report generator. The calculator
+// supplies opening balances; a PointInTimeCalculator keeps those cheap on
+// long histories. A page size of zero uses DefaultStreamPageSize.
+func NewStreamGenerator(accounts account.Repository, transactions storage.Repository, calculator ReportCalculator, pageSize int) *StreamGenerator {
+	if pageSize <= 0 {
+		pageSize = DefaultStreamPageSize
+	}
+	return &StreamGenerator{
+		accounts:     accounts,
+		transactions: transactions,
+		calculator:   calculator,
+		pageSize:     pageSize,
+	}
+}
+
+// LineError returns the error carried by the last line of a failed stream
+func LineError(line *ReportLine) error {
+	if line == nil {
+		return nil
+	}
+	if msg, ok := line.Details[DetailError].(string); ok {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// GenerateReportStream implements ReportStreamer. For each account, in code
+// order, it sends an opening balance line, one line per posted entry in
+// date order with the running balance, and a closing balance line. Entry
+// lines are children of the account (Level 1, ParentID set). Errors after
+// the stream has started end it with a line whose details carry
+// DetailError; see LineError. Cancelling the context stops the stream.
+func (g *StreamGenerator) GenerateReportStream(ctx context.Context, def *ReportDefinition, opts ReportOptions) (<-chan *ReportLine, error) {
+	if err := auth.Require(ctx, auth.ReportGenerate); err != nil {
+		return nil, err
+	}
+	if def == nil {
+		return nil, fmt.Errorf("report definition cannot be nil")
+	}
+	if def.Type != GeneralLedger && def.Type != AccountStatement {
+		return nil, fmt.Errorf("report type %s cannot be streamed", def.Type)
+	}
+
+	// A tenant-scoped context can't report on another tenant
+	if err := entity.CheckScope(ctx, opts.EntityID); err != nil {
+		return nil, err
+	}
+	if opts.EntityID == "" {
+		opts.EntityID = tenant.ID(ctx)
+	}
+	if opts.EntityID != "" {
+		ctx = entity.WithEntity(ctx, opts.EntityID)
+	}
+	if opts.JournalID != "" {
+		ctx = journal.WithJournal(ctx, opts.JournalID)
+	}
+	if opts.DateBasis != "" {
+		ctx = WithDateBasis(ctx, opts.DateBasis)
+	}
+
+	accounts, err := g.streamAccounts(ctx, def, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(chan *ReportLine)
+	go func() {
+		defer close(lines)
+		send := func(line *ReportLine) bool {
+			select {
+			case lines <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, acc := range accounts {
+			if err := g.streamAccount(ctx, acc, opts.Period, send); err != nil {
+				if ctx.Err() == nil {
+					send(&ReportLine{AccountID: acc.ID, Details: map[string]interface{}{DetailError: err.Error()}})
+				}
+				return
+			}
+		}
+	}()
+	return lines, nil
+}
+
+// streamAccounts returns the accounts of a streamed report in code order:
+// the accounts named in the options, or else those of the types listed in
+// the definition sections
+func (g *StreamGenerator) streamAccounts(ctx context.Context, def *ReportDefinition, opts ReportOptions) ([]*account.Account, error) {
+	accounts := make([]*account.Account, 0)
+	if ids, ok := opts.Parameters[ParameterAccountIDs].([]string); ok && len(ids) > 0 {
+		for _, id := range ids {
+			var acc account.Account
+			if err := g.accounts.Read(ctx, id, &acc); err != nil {
+				return nil, fmt.Errorf("error reading account %s: %w", id, err)
+			}
+			accounts = append(accounts, &acc)
+		}
+	} else {
+		if def.Type == AccountStatement {
+			return nil, fmt.Errorf("account statements require the %s parameter", ParameterAccountIDs)
+		}
+		types := make([]account.AccountType, 0)
+		for _, section := range def.Sections {
+			types = append(types, section.AccountTypes...)
+		}
+		query := storage.Query{}
+		if len(types) > 0 {
+			query.Filters = []storage.Filter{{Field: "type", Operator: "in", Value: types}}
+		}
+		if err := g.accounts.Query(ctx, entity.ScopeQuery(ctx, query), &accounts); err != nil {
+			return nil, fmt.Errorf("error querying accounts: %w", err)
+		}
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+	return accounts, nil
+}
+
+// streamAccount sends the lines of one account, reading its transactions a
+// page at a time
+func (g *StreamGenerator) streamAccount(ctx context.Context, acc *account.Account, period ReportPeriod, send func(*ReportLine) bool) error {
+	opening := money.Money{Amount: decimal.Zero}
+	if !period.Start.IsZero() {
+		var err error
+		opening, err = g.calculator.CalculateBalance(ctx, acc.ID, ReportPeriod{End: period.Start.Add(-time.Nanosecond)})
+		if err != nil {
+			return fmt.Errorf("error calculating opening balance: %w", err)
+		}
+	}
+	currency := opening.Currency
+	if !send(&ReportLine{
+		AccountID:   acc.ID,
+		AccountCode: acc.Code,
+		AccountName: acc.Name,
+		Amount:      opening,
+		Details:     map[string]interface{}{DetailKind: LineOpening},
+	}) {
+		return ctx.Err()
+	}
+
+	basis := DateBasisFromContext(ctx)
+	dateField := basis.dateField()
+	filters := []storage.Filter{
+		{Field: "entries.account_id", Operator: "=", Value: acc.ID},
+		{Field: "status", Operator: "=", Value: transaction.Posted},
+	}
+	if !period.Start.IsZero() {
+		filters = append(filters, storage.Filter{Field: dateField, Operator: ">=", Value: period.Start})
+	}
+	if !period.End.IsZero() {
+		filters = append(filters, storage.Filter{Field: dateField, Operator: "<=", Value: period.End})
+	}
+
+	balance := opening.Amount
+	for offset := int64(0); ; offset += int64(g.pageSize) {
+		query := storage.Query{
+			Filters:    filters,
+			Sort:       []storage.Sort{{Field: dateField}, {Field: "id"}},
+			Pagination: &storage.Pagination{Offset: offset, Limit: int64(g.pageSize)},
+		}
+		var page []*transaction.Transaction
+		if err := g.transactions.Query(ctx, journal.ScopeQuery(ctx, entity.ScopeQuery(ctx, query)), &page); err != nil {
+			return fmt.Errorf("error querying transactions: %w", err)
+		}
+
+		for _, tx := range page {
+			for _, entry := range tx.Entries {
+				if entry.AccountID != acc.ID {
+					continue
+				}
+				if currency == "" {
+					currency = entry.Amount.Currency
+				}
+				balance = balance.Add(BalanceMovement{Amount: entry.Amount, Type: string(entry.Type)}.Signed(acc.Type))
+				if !send(&ReportLine{
+					AccountID:   acc.ID,
+					AccountCode: acc.Code,
+					AccountName: tx.Description,
+					Amount:      entry.Amount,
+					Level:       1,
+					ParentID:    acc.ID,
+					Details: map[string]interface{}{
+						DetailKind:      LineEntry,
+						DetailDate:      basis.dateOf(tx),
+						DetailReference: tx.ID,
+						DetailEntryType: string(entry.Type),
+						DetailBalance:   money.Money{Amount: balance, Currency: currency},
+					},
+				}) {
+					return ctx.Err()
+				}
+			}
+		}
+		if len(page) < g.pageSize {
+			break
+		}
+	}
+
+	if !send(&ReportLine{
+		AccountID:   acc.ID,
+		AccountCode: acc.Code,
+		AccountName: acc.Name,
+		Amount:      money.Money{Amount: balance, Currency: currency},
+		Level:       1,
+		ParentID:    acc.ID,
+		Details:     map[string]interface{}{DetailKind: LineClosing},
+	}) {
+		return ctx.Err()
+	}
+	return nil
+}
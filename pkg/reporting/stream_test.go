@@ -0,0 +1,98 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedTransactionStore serves transactions of one account page by page
+// and records the page sizes requested
+type pagedTransactionStore struct {
+	storage.Repository
+	txs   []*transaction.Transaction
+	pages []int64
+}
+
+func (s *pagedTransactionStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	page := query.Pagination
+	s.pages = append(s.pages, page.Limit)
+	end := page.Offset + page.Limit
+	if end > int64(len(s.txs)) {
+		end = int64(len(s.txs))
+	}
+	if page.Offset < end {
+		*(results.(*[]*transaction.Transaction)) = s.txs[page.Offset:end]
+	}
+	return nil
+}
+
+func TestStreamGenerator(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &pagedTransactionStore{}
+	for i := 0; i < 25; i++ {
+		entryType := transaction.Debit
+		if i%5 == 4 {
+			entryType = transaction.Credit
+		}
+		store.txs = append(store.txs, &transaction.Transaction{
+			ID:          fmt.Sprintf("TX-%02d", i),
+			Status:      transaction.Posted,
+			Date:        start.AddDate(0, 0, i),
+			Description: "Sale",
+			Entries: []transaction.Entry{
+				{AccountID: "1200", Amount: money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}, Type: entryType},
+				{AccountID: "4000", Amount: money.Money{Amount: decimal.NewFromInt(10), Currency: "USD"}, Type: transaction.Credit},
+			},
+		})
+	}
+
+	generator := NewStreamGenerator(&staticAccountStore{}, store, nil, 10)
+	lines, err := generator.GenerateReportStream(ctx, &ReportDefinition{Type: AccountStatement}, ReportOptions{
+		Parameters: map[string]interface{}{ParameterAccountIDs: []string{"1200"}},
+	})
+	require.NoError(t, err)
+
+	var received []*ReportLine
+	for line := range lines {
+		require.NoError(t, LineError(line))
+		received = append(received, line)
+	}
+	require.Len(t, received, 27)
+	assert.Equal(t, LineOpening, received[0].Details[DetailKind])
+	assert.Equal(t, "TX-00", received[1].Details[DetailReference])
+	assert.Equal(t, 1, received[1].Level)
+	assert.Equal(t, "30", received[5].Details[DetailBalance].(money.Money).Amount.String(), "three debits and a credit")
+	closing := received[26]
+	assert.Equal(t, LineClosing, closing.Details[DetailKind])
+	assert.Equal(t, "150", closing.Amount.Amount.String())
+	assert.Equal(t, []int64{10, 10, 10}, store.pages)
+
+	t.Run("Unsupported report", func(t *testing.T) {
+		_, err := generator.GenerateReportStream(ctx, &ReportDefinition{Type: BalanceSheet}, ReportOptions{})
+		assert.Error(t, err)
+		_, err = generator.GenerateReportStream(ctx, &ReportDefinition{Type: AccountStatement}, ReportOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		lines, err := generator.GenerateReportStream(cancelCtx, &ReportDefinition{Type: GeneralLedger}, ReportOptions{
+			Parameters: map[string]interface{}{ParameterAccountIDs: []string{"1200"}},
+		})
+		require.NoError(t, err)
+		<-lines
+		cancel()
+		for range lines {
+		}
+	})
+}
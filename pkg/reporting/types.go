@@ -12,6 +12,7 @@ import (
 
 	"github.com/johnayoung/finlib/pkg/account"
 	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/transaction"
 	"github.com/shopspring/decimal"
 )
 
@@ -49,15 +50,18 @@ type PeriodHandling struct {
 // ReportPeriod represents a time period for financial reporting, supporting
 // comparative analysis through the Previous field.
 type ReportPeriod struct {
-	Start    time.Time     // Start of reporting period
-	End      time.Time     // End of reporting period
-	Previous *ReportPeriod // Optional previous period for comparisons
+	Start    time.Time     `json:"start"`              // Start of reporting period
+	End      time.Time     `json:"end"`                // End of reporting period
+	Previous *ReportPeriod `json:"previous,omitempty"` // Optional previous period for comparisons
 }
 
 // ReportOptions defines configuration options for report generation, allowing
 // customization of output format, currency handling, and other parameters.
 type ReportOptions struct {
 	Period        ReportPeriod           // Time period for the report
+	EntityID      string                 // Legal entity the report is scoped to
+	JournalID     string                 // Journal book the report is scoped to
+	DateBasis     DateBasis              // Posting or effective date; defaults to posting
 	Currency      string                 // Currency for the report
 	ShowCents     bool                   // Whether to include cents/decimal places
 	Format        string                 // Report format (e.g., CSV, JSON)
@@ -73,6 +77,32 @@ type AccountSelector struct {
 	Categories []string              // Account categories to include
 	Tags       []string              // Account tags to include
 	Expression string                // Custom selection logic
+	Dimensions []DimensionFilter     // Entry dimensions to include
+}
+
+// DimensionFilter restricts calculations to entries tagged with one of the
+// given values of a dimension. Entries inherit the dimensions of their
+// account unless they set their own.
+type DimensionFilter struct {
+	Dimension string   // Dimension name, e.g. account.DimensionDepartment
+	Values    []string // Accepted values; empty accepts any tagged entry
+}
+
+// Matches reports whether a set of dimensions satisfies the filter
+func (f DimensionFilter) Matches(dims map[string]string) bool {
+	value, ok := dims[f.Dimension]
+	if !ok || value == "" {
+		return false
+	}
+	if len(f.Values) == 0 {
+		return true
+	}
+	for _, accepted := range f.Values {
+		if accepted == value {
+			return true
+		}
+	}
+	return false
 }
 
 // AccountFilter defines criteria for filtering accounts based on various
@@ -110,30 +140,31 @@ type PeriodAdjustment struct {
 // ReportLine represents a single line in a report, supporting hierarchical
 // structure and comparative amounts.
 type ReportLine struct {
-	AccountID      string                 // Account identifier
-	AccountCode    string                 // Account code
-	AccountName    string                 // Account name
-	Amount         money.Money            // Primary amount
-	PreviousAmount *money.Money           // Comparative amount
-	Details        map[string]interface{} // Calculation details
-	Level          int                    // Hierarchical level
-	ParentID       string                 // Parent line identifier
-	Children       []*ReportLine          // Child lines
+	AccountID      string                 `json:"account_id"`                // Account identifier
+	AccountCode    string                 `json:"account_code"`              // Account code
+	AccountName    string                 `json:"account_name"`              // Account name
+	Amount         money.Money            `json:"amount"`                    // Primary amount
+	PreviousAmount *money.Money           `json:"previous_amount,omitempty"` // Comparative amount
+	Details        map[string]interface{} `json:"details,omitempty"`         // Calculation details
+	Level          int                    `json:"level"`                     // Hierarchical level
+	ParentID       string                 `json:"parent_id,omitempty"`       // Parent line identifier
+	Children       []*ReportLine          `json:"children,omitempty"`        // Child lines
 }
 
 // Report represents a generated financial report, containing both the report
 // content and metadata about its generation.
 type Report struct {
-	ID          string                 // Report identifier
-	Type        ReportType             // Type of report
-	Title       string                 // Report title
-	Period      ReportPeriod           // Time period
-	Currency    string                 // Report currency
-	GeneratedAt time.Time              // Generation timestamp
-	GeneratedBy string                 // User or process that generated the report
-	Lines       []*ReportLine          // Report content
-	Totals      map[string]money.Money // Section/report totals
-	Metadata    map[string]interface{} // Additional metadata
+	ID          string                 `json:"id"`                     // Report identifier
+	Type        ReportType             `json:"type"`                   // Type of report
+	Title       string                 `json:"title"`                  // Report title
+	Period      ReportPeriod           `json:"period"`                 // Time period
+	EntityID    string                 `json:"entity_id,omitempty"`    // Legal entity the report covers
+	Currency    string                 `json:"currency"`               // Report currency
+	GeneratedAt time.Time              `json:"generated_at"`           // Generation timestamp
+	GeneratedBy string                 `json:"generated_by,omitempty"` // User or process that generated the report
+	Lines       []*ReportLine          `json:"lines"`                  // Report content
+	Totals      map[string]money.Money `json:"totals"`                 // Section/report totals
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`     // Additional metadata
 }
 
 // ReportDefinition defines the structure and calculations for a report,
@@ -223,6 +254,12 @@ type BalanceMovement struct {
 	Type        string // "DEBIT", "CREDIT"
 	Description string
 	Reference   string
+	// Supporting documents of the transaction
+	Attachments []transaction.Attachment
+	// Entries of the transaction posted to other accounts
+	CounterEntries []transaction.Entry
+	// Dimension tags of the entry itself, before account defaults apply
+	Dimensions map[string]string
 }
 
 // RatioDefinition defines how to calculate a financial ratio.
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/johnayoung/finlib/pkg/account"
+)
+
+var accountTypes = map[account.AccountType]bool{
+	account.Asset:     true,
+	account.Liability: true,
+	account.Equity:    true,
+	account.Revenue:   true,
+	account.Expense:   true,
+}
+
+// accountFromRequest decodes an account from the request body, defaulting the
+// status to active
+func accountFromRequest(r *http.Request) (*account.Account, error) {
+	var acc account.Account
+	if err := decodeBody(r, &acc); err != nil {
+		return nil, err
+	}
+	if !accountTypes[acc.Type] {
+		return nil, fmt.Errorf("%w: %q", account.ErrInvalidAccountType, acc.Type)
+	}
+	if acc.Status == "" {
+		acc.Status = account.Active
+	}
+	return &acc, nil
+}
+
+func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request) {
+	page, err := ParsePagination(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	filters := make(map[string]interface{})
+	for _, field := range []string{"type", "status", "entity_id"} {
+		if v := r.URL.Query().Get(field); v != "" {
+			filters[field] = v
+		}
+	}
+
+	accounts, err := h.accounts.ListAccounts(r.Context(), filters)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	// Order by code so pages are stable across requests
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Code < accounts[j].Code })
+
+	writeJSON(w, http.StatusOK, Paginate(accounts, page))
+}
+
+func (h *Handler) createAccount(w http.ResponseWriter, r *http.Request) {
+	acc, err := accountFromRequest(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err := h.accounts.CreateAccount(r.Context(), acc); err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, acc)
+}
+
+func (h *Handler) getAccount(w http.ResponseWriter, r *http.Request) {
+	acc, err := h.accounts.GetAccount(r.Context(), r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, acc)
+}
+
+func (h *Handler) updateAccount(w http.ResponseWriter, r *http.Request) {
+	acc, err := accountFromRequest(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if acc.ID == "" {
+		acc.ID = id
+	}
+	if acc.ID != id {
+		WriteError(w, fmt.Errorf("%w: account id %q does not match path", ErrBadRequest, acc.ID))
+		return
+	}
+
+	if err := h.accounts.UpdateAccount(r.Context(), acc); err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, acc)
+}
+
+func (h *Handler) getAccountBalance(w http.ResponseWriter, r *http.Request) {
+	balance, err := h.accounts.GetAccountBalance(r.Context(), r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balance)
+}
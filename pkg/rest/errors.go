@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	finerrors "github.com/johnayoung/finlib/pkg/errors"
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+var (
+	// ErrBadRequest marks malformed requests
+	ErrBadRequest = errors.New("bad request")
+	// ErrNotFound marks requests for resources that do not exist
+	ErrNotFound = errors.New("not found")
+)
+
+// ErrorBody is the JSON body returned for failed requests
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes a failed request
+type ErrorDetail struct {
+	// Stable machine-readable code, e.g. "not_found"
+	Code string `json:"code"`
+	// Human-readable description
+	Message string `json:"message"`
+}
+
+// StatusFor maps a domain error to an HTTP status code
+func StatusFor(err error) int {
+	var lockErr *storage.OptimisticLockError
+	var finErr *finerrors.FinancialError
+
+	switch {
+	case errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound), errors.Is(err, account.ErrAccountNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrPermissionDenied), errors.Is(err, storage.ErrTenantMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, account.ErrInvalidAccountType), errors.Is(err, account.ErrInvalidAccountCode):
+		return http.StatusBadRequest
+	case errors.Is(err, account.ErrAccountLocked), errors.Is(err, account.ErrInvalidOperation),
+		errors.Is(err, storage.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.As(err, &lockErr):
+		return http.StatusConflict
+	case errors.As(err, &finErr):
+		switch finErr.Category {
+		case finerrors.ValidationError:
+			return http.StatusBadRequest
+		case finerrors.BusinessError, finerrors.ConcurrencyError:
+			return http.StatusConflict
+		case finerrors.SecurityError:
+			return http.StatusForbidden
+		}
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codeFor returns the error code reported for an HTTP status
+func codeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	default:
+		return "internal"
+	}
+}
+
+// WriteError writes an error response with the status mapped from err
+func WriteError(w http.ResponseWriter, err error) {
+	status := StatusFor(err)
+	writeJSON(w, status, ErrorBody{Error: ErrorDetail{Code: codeFor(status), Message: err.Error()}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,174 @@
+// Package rest exposes accounts, transactions, statements and reports as JSON
+// REST endpoints. The Handler can be mounted into any http.ServeMux, e.g.
+//
+//	mux.Handle("/ledger/", http.StripPrefix("/ledger", rest.NewHandler(...)))
+//
+// and serves its own OpenAPI document at /openapi.json.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// StatementGenerator produces financial statements; it is satisfied by
+// *statements.Generator
+type StatementGenerator interface {
+	GenerateBalanceSheet(ctx context.Context, asOf time.Time, opts statements.StatementOptions) (*statements.Statement, error)
+	GenerateIncomeStatement(ctx context.Context, periodStart, periodEnd time.Time, opts statements.StatementOptions) (*statements.Statement, error)
+	GenerateCashFlow(ctx context.Context, periodStart, periodEnd time.Time, opts statements.StatementOptions) (*statements.Statement, error)
+}
+
+// route describes a single endpoint; the route table drives both request
+// routing and the generated OpenAPI document
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	tag         string
+	params      []param
+	request     string
+	response    string
+	status      int
+	paginated   bool
+	handle      http.HandlerFunc
+}
+
+// param describes a path or query parameter
+type param struct {
+	name     string
+	in       string
+	required bool
+	format   string
+}
+
+// Handler serves the REST API
+type Handler struct {
+	mux          *http.ServeMux
+	routes       []route
+	accounts     account.AccountManager
+	processor    transaction.TransactionProcessor
+	transactions storage.Repository
+	reports      reporting.ReportGenerator
+	statements   StatementGenerator
+}
+
+// NewHandler creates a REST handler backed by the given components.
+// Submitted transactions are created in transactions before they are
+// posted, so it must be the repository the processor posts to.
+func NewHandler(accounts account.AccountManager, processor transaction.TransactionProcessor, transactions storage.Repository, reports reporting.ReportGenerator, stmts StatementGenerator) *Handler {
+	h := &Handler{
+		mux:          http.NewServeMux(),
+		accounts:     accounts,
+		processor:    processor,
+		transactions: transactions,
+		reports:      reports,
+		statements:   stmts,
+	}
+
+	h.routes = h.buildRoutes()
+	for _, rt := range h.routes {
+		h.mux.HandleFunc(rt.method+" "+rt.path, rt.handle)
+	}
+	h.mux.HandleFunc("GET /openapi.json", h.serveOpenAPI)
+	return h
+}
+
+// ServeHTTP implements http.Handler. Changes made by a request are
+// attributed to it: the X-Request-ID header and the client address are
+// added to the context actor, so stores record them on audit entries.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	actor := storage.ActorFromContext(r.Context())
+	if actor.Source == "" {
+		actor.Source = "api"
+	}
+	if actor.RequestID == "" {
+		actor.RequestID = r.Header.Get("X-Request-ID")
+	}
+	if actor.IPAddress == "" {
+		actor.IPAddress = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			actor.IPAddress = host
+		}
+	}
+	h.mux.ServeHTTP(w, r.WithContext(storage.WithActor(r.Context(), actor)))
+}
+
+func (h *Handler) buildRoutes() []route {
+	id := param{name: "id", in: "path", required: true}
+	pagination := []param{{name: "offset", in: "query", format: "int64"}, {name: "limit", in: "query", format: "int64"}}
+	period := []param{{name: "start", in: "query", required: true, format: "date"}, {name: "end", in: "query", required: true, format: "date"}, {name: "currency", in: "query"}}
+
+	return []route{
+		{method: "GET", path: "/accounts", operationID: "listAccounts", summary: "List accounts", tag: "accounts",
+			params:   append([]param{{name: "type", in: "query"}, {name: "status", in: "query"}, {name: "entity_id", in: "query"}}, pagination...),
+			response: "Account", paginated: true, handle: h.listAccounts},
+		{method: "POST", path: "/accounts", operationID: "createAccount", summary: "Create an account", tag: "accounts",
+			request: "Account", response: "Account", status: http.StatusCreated, handle: h.createAccount},
+		{method: "GET", path: "/accounts/{id}", operationID: "getAccount", summary: "Get an account", tag: "accounts",
+			params: []param{id}, response: "Account", handle: h.getAccount},
+		{method: "PUT", path: "/accounts/{id}", operationID: "updateAccount", summary: "Update an account", tag: "accounts",
+			params: []param{id}, request: "Account", response: "Account", handle: h.updateAccount},
+		{method: "GET", path: "/accounts/{id}/balance", operationID: "getAccountBalance", summary: "Get an account balance", tag: "accounts",
+			params: []param{id}, response: "AccountBalance", handle: h.getAccountBalance},
+
+		{method: "POST", path: "/transactions", operationID: "postTransaction", summary: "Post a transaction", tag: "transactions",
+			request: "Transaction", response: "Transaction", status: http.StatusCreated, handle: h.postTransaction},
+		{method: "GET", path: "/transactions/{id}", operationID: "getTransaction", summary: "Get a transaction", tag: "transactions",
+			params: []param{id}, response: "Transaction", handle: h.getTransaction},
+		{method: "POST", path: "/transactions/{id}/void", operationID: "voidTransaction", summary: "Void a posted transaction", tag: "transactions",
+			params: []param{id}, request: "ReasonRequest", response: "Transaction", handle: h.voidTransaction},
+		{method: "POST", path: "/transactions/{id}/reverse", operationID: "reverseTransaction", summary: "Reverse a posted transaction", tag: "transactions",
+			params: []param{id}, request: "ReasonRequest", response: "Transaction", status: http.StatusCreated, handle: h.reverseTransaction},
+
+		{method: "GET", path: "/statements/balance-sheet", operationID: "getBalanceSheet", summary: "Generate a balance sheet", tag: "statements",
+			params:   []param{{name: "as_of", in: "query", required: true, format: "date"}, {name: "currency", in: "query"}},
+			response: "Statement", handle: h.balanceSheet},
+		{method: "GET", path: "/statements/income-statement", operationID: "getIncomeStatement", summary: "Generate an income statement", tag: "statements",
+			params: period, response: "Statement", handle: h.incomeStatement},
+		{method: "GET", path: "/statements/cash-flow", operationID: "getCashFlowStatement", summary: "Generate a cash flow statement", tag: "statements",
+			params: period, response: "Statement", handle: h.cashFlow},
+
+		{method: "GET", path: "/reports/types", operationID: "listReportTypes", summary: "List available report types", tag: "reports",
+			response: "ReportTypes", handle: h.reportTypes},
+		{method: "POST", path: "/reports", operationID: "generateReport", summary: "Generate a report from a stored definition", tag: "reports",
+			request: "GenerateReportRequest", response: "Report", handle: h.generateReport},
+	}
+}
+
+// decodeBody decodes a JSON request body
+func decodeBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid request body: %v", ErrBadRequest, err)
+	}
+	return nil
+}
+
+// parseDate reads a required date query parameter in YYYY-MM-DD or RFC 3339 form
+func parseDate(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, fmt.Errorf("%w: %s is required", ErrBadRequest, name)
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s %q", ErrBadRequest, name, v)
+	}
+	return t, nil
+}
@@ -0,0 +1,233 @@
+package rest
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// APIVersion is the version reported in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// schemaTypes names the Go types referenced by the route table
+var schemaTypes = map[string]reflect.Type{
+	"Account":               reflect.TypeOf(account.Account{}),
+	"AccountBalance":        reflect.TypeOf(account.Balance{}),
+	"Transaction":           reflect.TypeOf(transaction.Transaction{}),
+	"ReasonRequest":         reflect.TypeOf(ReasonRequest{}),
+	"Statement":             reflect.TypeOf(statements.Statement{}),
+	"ReportTypes":           reflect.TypeOf(ReportTypes{}),
+	"GenerateReportRequest": reflect.TypeOf(GenerateReportRequest{}),
+	"Report":                reflect.TypeOf(reporting.Report{}),
+	"PageInfo":              reflect.TypeOf(PageInfo{}),
+	"Error":                 reflect.TypeOf(ErrorBody{}),
+}
+
+// schemaBuilder derives JSON schemas from Go types using their json tags.
+// Named struct types become components and are referenced by name, which
+// also handles recursive types such as report lines.
+type schemaBuilder struct {
+	names      map[reflect.Type]string
+	components map[string]interface{}
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	b := &schemaBuilder{
+		names:      make(map[reflect.Type]string),
+		components: make(map[string]interface{}),
+	}
+	for name, t := range schemaTypes {
+		b.names[t] = name
+	}
+	for _, t := range schemaTypes {
+		b.ref(t)
+	}
+	return b
+}
+
+// ref returns a reference to the component for a named struct type,
+// building the component on first use
+func (b *schemaBuilder) ref(t reflect.Type) map[string]interface{} {
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name()
+		b.names[t] = name
+	}
+	if _, built := b.components[name]; !built {
+		// Reserve the name before recursing so self references terminate
+		b.components[name] = nil
+		b.components[name] = b.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(decimal.Decimal{}):
+		return map[string]interface{}{"type": "string", "format": "decimal"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.ref(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object builds an object schema from a struct's exported, json-tagged fields
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	obj := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// OpenAPI returns the OpenAPI 3 document describing the handler's routes
+func (h *Handler) OpenAPI() map[string]interface{} {
+	b := newSchemaBuilder()
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content":     jsonContent(b.ref(schemaTypes["Error"])),
+	}
+
+	paths := make(map[string]interface{})
+	for _, rt := range h.routes {
+		op := map[string]interface{}{
+			"operationId": rt.operationID,
+			"summary":     rt.summary,
+			"tags":        []string{rt.tag},
+		}
+
+		var params []interface{}
+		for _, p := range rt.params {
+			params = append(params, map[string]interface{}{
+				"name":     p.name,
+				"in":       p.in,
+				"required": p.required,
+				"schema":   paramSchema(p.format),
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.request != "" {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(b.ref(schemaTypes[rt.request])),
+			}
+		}
+
+		response := b.ref(schemaTypes[rt.response])
+		if rt.paginated {
+			response = map[string]interface{}{
+				"type":     "object",
+				"required": []string{"data", "pagination"},
+				"properties": map[string]interface{}{
+					"data":       map[string]interface{}{"type": "array", "items": response},
+					"pagination": b.ref(schemaTypes["PageInfo"]),
+				},
+			}
+		}
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		op["responses"] = map[string]interface{}{
+			strconv.Itoa(status): map[string]interface{}{
+				"description": http.StatusText(status),
+				"content":     jsonContent(response),
+			},
+			"default": errorResponse,
+		}
+
+		item, ok := paths[rt.path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "finlib ledger API",
+			"version": APIVersion,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": b.components},
+	}
+}
+
+func (h *Handler) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.OpenAPI())
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// paramSchema returns the schema for a path or query parameter format
+func paramSchema(format string) map[string]interface{} {
+	switch format {
+	case "int64":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "date":
+		return map[string]interface{}{"type": "string", "format": "date"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+const (
+	// DefaultPageSize is used when a request does not specify a limit
+	DefaultPageSize = 50
+	// MaxPageSize caps the limit a request may ask for
+	MaxPageSize = 500
+)
+
+// Page is the envelope returned by list endpoints
+type Page struct {
+	Data       interface{} `json:"data"`
+	Pagination PageInfo    `json:"pagination"`
+}
+
+// PageInfo describes the slice of results returned
+type PageInfo struct {
+	Offset int64 `json:"offset"`
+	Limit  int64 `json:"limit"`
+	Total  int64 `json:"total"`
+}
+
+// ParsePagination reads the offset and limit query parameters
+func ParsePagination(r *http.Request) (storage.Pagination, error) {
+	p := storage.Pagination{Offset: 0, Limit: DefaultPageSize}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("%w: invalid offset %q", ErrBadRequest, v)
+		}
+		p.Offset = offset
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 {
+			return p, fmt.Errorf("%w: invalid limit %q", ErrBadRequest, v)
+		}
+		if limit > MaxPageSize {
+			limit = MaxPageSize
+		}
+		p.Limit = limit
+	}
+	return p, nil
+}
+
+// Paginate returns the requested page of items
+func Paginate[T any](items []T, p storage.Pagination) Page {
+	total := int64(len(items))
+	start := p.Offset
+	if start > total {
+		start = total
+	}
+	end := start + p.Limit
+	if end > total {
+		end = total
+	}
+
+	return Page{
+		Data:       items[start:end],
+		Pagination: PageInfo{Offset: p.Offset, Limit: p.Limit, Total: total},
+	}
+}
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/reporting/statements"
+)
+
+// ReportTypes is the response of the report types endpoint
+type ReportTypes struct {
+	Types []reporting.ReportType `json:"types"`
+}
+
+// GenerateReportRequest is the body accepted by the generate report endpoint
+type GenerateReportRequest struct {
+	// Identifier of a stored report definition
+	DefinitionID string `json:"definition_id"`
+	// Reporting period
+	Period reporting.ReportPeriod `json:"period"`
+	// Optional comparative period
+	PreviousPeriod *reporting.ReportPeriod `json:"previous_period,omitempty"`
+	// Optional legal entity filter
+	EntityID string `json:"entity_id,omitempty"`
+	// Optional report currency
+	Currency string `json:"currency,omitempty"`
+}
+
+func statementOptions(r *http.Request) statements.StatementOptions {
+	return statements.StatementOptions{Currency: r.URL.Query().Get("currency")}
+}
+
+func (h *Handler) balanceSheet(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseDate(r, "as_of")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	stmt, err := h.statements.GenerateBalanceSheet(r.Context(), asOf, statementOptions(r))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stmt)
+}
+
+func (h *Handler) incomeStatement(w http.ResponseWriter, r *http.Request) {
+	h.periodStatement(w, r, h.statements.GenerateIncomeStatement)
+}
+
+func (h *Handler) cashFlow(w http.ResponseWriter, r *http.Request) {
+	h.periodStatement(w, r, h.statements.GenerateCashFlow)
+}
+
+// periodStatement serves a statement covering the start and end query
+// parameters
+func (h *Handler) periodStatement(w http.ResponseWriter, r *http.Request, generate func(ctx context.Context, start, end time.Time, opts statements.StatementOptions) (*statements.Statement, error)) {
+	start, err := parseDate(r, "start")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	end, err := parseDate(r, "end")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if end.Before(start) {
+		WriteError(w, fmt.Errorf("%w: end is before start", ErrBadRequest))
+		return
+	}
+
+	stmt, err := generate(r.Context(), start, end, statementOptions(r))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stmt)
+}
+
+func (h *Handler) reportTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := h.reports.GetReportTypes(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ReportTypes{Types: types})
+}
+
+func (h *Handler) generateReport(w http.ResponseWriter, r *http.Request) {
+	var req GenerateReportRequest
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if req.DefinitionID == "" {
+		WriteError(w, fmt.Errorf("%w: definition_id is required", ErrBadRequest))
+		return
+	}
+
+	def, err := h.reports.LoadDefinition(r.Context(), req.DefinitionID)
+	if err != nil {
+		WriteError(w, fmt.Errorf("%w: %v", ErrNotFound, err))
+		return
+	}
+
+	opts := reporting.ReportOptions{
+		Period:   req.Period,
+		EntityID: req.EntityID,
+		Currency: req.Currency,
+	}
+	if req.PreviousPeriod != nil {
+		opts.Period.Previous = req.PreviousPeriod
+	}
+
+	report, err := h.reports.GenerateReport(r.Context(), def, opts)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
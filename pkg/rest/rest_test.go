@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapAccounts is an in-memory AccountManager
+type mapAccounts struct {
+	account.AccountManager
+	accounts map[string]*account.Account
+}
+
+func (m *mapAccounts) CreateAccount(ctx context.Context, acc *account.Account) error {
+	m.accounts[acc.ID] = acc
+	return nil
+}
+
+func (m *mapAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	acc, ok := m.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", account.ErrAccountNotFound, id)
+	}
+	return acc, nil
+}
+
+func (m *mapAccounts) ListAccounts(ctx context.Context, filters map[string]interface{}) ([]*account.Account, error) {
+	var accounts []*account.Account
+	for _, acc := range m.accounts {
+		if filters["type"] == nil || filters["type"] == string(acc.Type) {
+			accounts = append(accounts, acc)
+		}
+	}
+	return accounts, nil
+}
+
+func newTestHandler() (*Handler, *memory.MemoryStore) {
+	store := memory.NewMemoryStore()
+	h := NewHandler(
+		&mapAccounts{accounts: make(map[string]*account.Account)},
+		transaction.NewBasicTransactionProcessor(store),
+		store, nil, nil,
+	)
+	return h, store
+}
+
+func do(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAccounts(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for _, code := range []string{"2000", "1000", "1100"} {
+		body := fmt.Sprintf(`{"id":%q,"code":%q,"name":"Account %s","type":"ASSET"}`, code, code, code)
+		w := do(h, httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(body)))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := do(h, httptest.NewRequest(http.MethodGet, "/accounts/1000", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var acc account.Account
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &acc))
+	assert.Equal(t, account.Active, acc.Status)
+
+	w = do(h, httptest.NewRequest(http.MethodGet, "/accounts?limit=2&offset=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Data       []account.Account `json:"data"`
+		Pagination PageInfo          `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, "1100", page.Data[0].Code)
+	assert.Equal(t, PageInfo{Offset: 1, Limit: 2, Total: 3}, page.Pagination)
+
+	w = do(h, httptest.NewRequest(http.MethodGet, "/accounts/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var errBody ErrorBody
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errBody))
+	assert.Equal(t, "not_found", errBody.Error.Code)
+
+	w = do(h, httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`{"id":"X","type":"BOGUS"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(h, httptest.NewRequest(http.MethodGet, "/accounts?limit=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPostTransaction(t *testing.T) {
+	body := []byte(`{"id":"TX1","description":"Sale","entries":[
+		{"account_id":"1000","amount":{"amount":"100","currency":"USD"},"type":"DEBIT","description":"cash"},
+		{"account_id":"4000","amount":{"amount":"100","currency":"USD"},"type":"CREDIT","description":"revenue"}]}`)
+
+	t.Run("posted", func(t *testing.T) {
+		h, store := newTestHandler()
+		w := do(h, httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body)))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var tx transaction.Transaction
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tx))
+		assert.Equal(t, transaction.Posted, tx.Status)
+		assert.Equal(t, transaction.Journal, tx.Type)
+
+		var stored transaction.Transaction
+		require.NoError(t, store.Read(context.Background(), "TX1", &stored))
+		assert.Equal(t, transaction.Posted, stored.Status)
+	})
+
+	t.Run("existing IDs are a conflict", func(t *testing.T) {
+		h, store := newTestHandler()
+		w := do(h, httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body)))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		overwrite := bytes.ReplaceAll(body, []byte(`"100"`), []byte(`"999"`))
+		w = do(h, httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(overwrite)))
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+		var stored transaction.Transaction
+		require.NoError(t, store.Read(context.Background(), "TX1", &stored))
+		assert.Equal(t, transaction.Posted, stored.Status)
+		assert.Equal(t, "100", stored.Entries[0].Amount.Amount.String())
+	})
+
+	t.Run("permission errors are mapped", func(t *testing.T) {
+		auditor := &auth.Principal{ID: "auditor", Roles: []*auth.Role{{Name: "auditor", Permissions: []auth.Permission{auth.ReportGenerate}}}}
+		r := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(body))
+		r = r.WithContext(auth.WithPrincipal(r.Context(), auditor))
+
+		h, store := newTestHandler()
+		w := do(h, r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var stored transaction.Transaction
+		assert.ErrorIs(t, store.Read(context.Background(), "TX1", &stored), storage.ErrNotFound)
+	})
+}
+
+func TestStatusFor(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, StatusFor(auth.ErrUnauthenticated))
+	assert.Equal(t, http.StatusConflict, StatusFor(fmt.Errorf("wrapped: %w", account.ErrAccountLocked)))
+	assert.Equal(t, http.StatusConflict, StatusFor(fmt.Errorf("failed to store transaction: %w", storage.ErrAlreadyExists)))
+	assert.Equal(t, http.StatusConflict, StatusFor(&storage.OptimisticLockError{}))
+	assert.Equal(t, http.StatusInternalServerError, StatusFor(fmt.Errorf("boom")))
+}
+
+func TestOpenAPI(t *testing.T) {
+	h, _ := newTestHandler()
+	w := do(h, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	for _, rt := range h.routes {
+		op, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]
+		if assert.True(t, ok, "%s %s", rt.method, rt.path) {
+			assert.Equal(t, rt.operationID, op["operationId"])
+		}
+	}
+
+	// Recursive report lines resolve to a component reference
+	line := doc.Components.Schemas["ReportLine"]
+	require.NotNil(t, line)
+	children := line["properties"].(map[string]interface{})["children"].(map[string]interface{})
+	assert.Equal(t, "#/components/schemas/ReportLine", children["items"].(map[string]interface{})["$ref"])
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// ReasonRequest is the body accepted by the void and reverse endpoints
+type ReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// transactionFromRequest decodes a transaction submitted for posting. Status
+// and lifecycle timestamps are managed by the processor and are reset here.
+func transactionFromRequest(r *http.Request) (*transaction.Transaction, error) {
+	var tx transaction.Transaction
+	if err := decodeBody(r, &tx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if tx.Type == "" {
+		tx.Type = transaction.Journal
+	}
+	if tx.Date.IsZero() {
+		tx.Date = now
+	}
+	tx.Status = transaction.Draft
+	tx.Created = now
+	tx.LastModified = now
+	tx.PostedAt = nil
+	tx.VoidedAt = nil
+	tx.VoidReason = ""
+	tx.ReversedAt = nil
+	tx.ReversalID = ""
+
+	for i, entry := range tx.Entries {
+		if entry.Type != transaction.Debit && entry.Type != transaction.Credit {
+			return nil, fmt.Errorf("%w: entry %d: invalid entry type %q", ErrBadRequest, i, entry.Type)
+		}
+	}
+	return &tx, nil
+}
+
+// postTransaction creates and posts a new transaction. Submitting the ID of
+// a stored transaction is a conflict, so posted transactions are never
+// overwritten.
+func (h *Handler) postTransaction(w http.ResponseWriter, r *http.Request) {
+	tx, err := transactionFromRequest(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err := transaction.CreateAndPost(r.Context(), h.transactions, h.processor, tx); err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, tx)
+}
+
+func (h *Handler) getTransaction(w http.ResponseWriter, r *http.Request) {
+	tx, err := h.processor.GetTransaction(r.Context(), r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tx)
+}
+
+func (h *Handler) voidTransaction(w http.ResponseWriter, r *http.Request) {
+	var req ReasonRequest
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := h.processor.VoidTransaction(r.Context(), id, req.Reason); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	tx, err := h.processor.GetTransaction(r.Context(), id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tx)
+}
+
+// reverseTransaction reverses a posted transaction and returns the reversing
+// transaction
+func (h *Handler) reverseTransaction(w http.ResponseWriter, r *http.Request) {
+	var req ReasonRequest
+	if err := decodeBody(r, &req); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := h.processor.ReverseTransaction(r.Context(), id, req.Reason); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	original, err := h.processor.GetTransaction(r.Context(), id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	reversal, err := h.processor.GetTransaction(r.Context(), original.ReversalID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, reversal)
+}
@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// ColdStorage persists archive bundles outside the ledger store
+type ColdStorage interface {
+	// Write stores a bundle
+	Write(ctx context.Context, bundle *Bundle) error
+
+	// Read retrieves a bundle by ID
+	Read(ctx context.Context, id string) (*Bundle, error)
+}
+
+// Checksum returns the digest of a set of archived transactions
+func Checksum(txs []*transaction.Transaction) (string, error) {
+	data, err := json.Marshal(txs)
+	if err != nil {
+		return "", fmt.Errorf("error encoding transactions: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify checks that a bundle's transactions match its checksum
+func (b *Bundle) Verify() error {
+	sum, err := Checksum(b.Transactions)
+	if err != nil {
+		return err
+	}
+	if sum != b.Checksum {
+		return fmt.Errorf("%w: %s: checksum mismatch", ErrBundleCorrupt, b.ID)
+	}
+	return nil
+}
+
+// DirStorage keeps each bundle as a JSON file in a directory, suitable for
+// exporting to object storage or tape
+type DirStorage struct {
+	dir string
+}
+
+// NewDirStorage creates cold storage in a directory, creating it if needed
+func NewDirStorage(dir string) (*DirStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating archive directory: %w", err)
+	}
+	return &DirStorage{dir: dir}, nil
+}
+
+// Write implements ColdStorage.Write. Bundles are written to a temporary
+// file and renamed so a partially written bundle is never visible.
+func (s *DirStorage) Write(ctx context.Context, bundle *Bundle) error {
+	path, err := s.path(bundle.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("error encoding bundle %s: %w", bundle.ID, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("error writing bundle %s: %w", bundle.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing bundle %s: %w", bundle.ID, err)
+	}
+	return nil
+}
+
+// Read implements ColdStorage.Read
+func (s *DirStorage) Read(ctx context.Context, id string) (*Bundle, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrBundleNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle %s: %w", id, err)
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBundleCorrupt, id, err)
+	}
+	return &bundle, nil
+}
+
+func (s *DirStorage) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid bundle ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
@@ -0,0 +1,167 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Job applies retention policies to the transactions in a store
+type Job struct {
+	transactions storage.Repository
+	cold         ColdStorage
+	policies     []Policy
+}
+
+// NewJob creates a retention job. Every policy is validated up front so an
+// unsafe policy can never run.
+func NewJob(transactions storage.Repository, cold ColdStorage, policies []Policy) (*Job, error) {
+	for _, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return nil, err
+		}
+		if policy.Action == Archive && cold == nil {
+			return nil, fmt.Errorf("%w: %s: archive policies require cold storage", ErrInvalidPolicy, policy.Name)
+		}
+	}
+	return &Job{
+		transactions: transactions,
+		cold:         cold,
+		policies:     policies,
+	}, nil
+}
+
+// Run applies every policy as of the given time. Archived transactions are
+// only removed from the store once their bundle has been written and read
+// back successfully.
+func (j *Job) Run(ctx context.Context, now time.Time) (*RunReport, error) {
+	report := &RunReport{Started: time.Now()}
+
+	for _, policy := range j.policies {
+		cutoff := policy.Cutoff(now)
+		selected, err := j.selectTransactions(ctx, policy, cutoff)
+		if err != nil {
+			return report, err
+		}
+
+		result := PolicyResult{Policy: policy.Name, Action: policy.Action, Selected: len(selected)}
+		if len(selected) > 0 {
+			switch policy.Action {
+			case Archive:
+				err = j.archive(ctx, policy, cutoff, selected, &result)
+			case Purge:
+				err = j.purge(ctx, selected, &result)
+			}
+		}
+		report.Results = append(report.Results, result)
+		if err != nil {
+			return report, fmt.Errorf("retention policy %s failed: %w", policy.Name, err)
+		}
+	}
+
+	report.Completed = time.Now()
+	return report, nil
+}
+
+// Restore returns the transactions of an archived bundle to the store
+func (j *Job) Restore(ctx context.Context, bundleID string) (int, error) {
+	if j.cold == nil {
+		return 0, fmt.Errorf("no cold storage configured")
+	}
+
+	bundle, err := j.cold.Read(ctx, bundleID)
+	if err != nil {
+		return 0, err
+	}
+	if err := bundle.Verify(); err != nil {
+		return 0, err
+	}
+
+	for i, tx := range bundle.Transactions {
+		if err := j.transactions.Create(ctx, tx); err != nil {
+			return i, fmt.Errorf("failed to restore transaction %s: %w", tx.ID, err)
+		}
+	}
+	return len(bundle.Transactions), nil
+}
+
+// selectTransactions returns the transactions a policy applies to
+func (j *Job) selectTransactions(ctx context.Context, policy Policy, cutoff time.Time) ([]*transaction.Transaction, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "status", Operator: "IN", Value: policy.Statuses},
+			{Field: "date", Operator: "<", Value: cutoff},
+		},
+		Sort: []storage.Sort{{Field: "date", Desc: false}},
+	}
+
+	var txs []*transaction.Transaction
+	if err := j.transactions.Query(ctx, entity.ScopeQuery(ctx, query), &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	// Re-check every record so a store that ignores filters can never cause
+	// the wrong transactions to be removed
+	selected := make([]*transaction.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if policy.applies(tx, cutoff) {
+			selected = append(selected, tx)
+		}
+	}
+	return selected, nil
+}
+
+func (j *Job) archive(ctx context.Context, policy Policy, cutoff time.Time, txs []*transaction.Transaction, result *PolicyResult) error {
+	checksum, err := Checksum(txs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	bundle := &Bundle{
+		ID:           fmt.Sprintf("%s-%s", policy.Name, now.UTC().Format("20060102T150405.000000000")),
+		Policy:       policy.Name,
+		Cutoff:       cutoff,
+		Created:      now,
+		Transactions: txs,
+		Checksum:     checksum,
+	}
+	if err := j.cold.Write(ctx, bundle); err != nil {
+		return fmt.Errorf("error writing archive bundle: %w", err)
+	}
+
+	// Read the bundle back before deleting anything
+	stored, err := j.cold.Read(ctx, bundle.ID)
+	if err != nil {
+		return fmt.Errorf("error verifying archive bundle: %w", err)
+	}
+	if err := stored.Verify(); err != nil {
+		return err
+	}
+	if len(stored.Transactions) != len(txs) {
+		return fmt.Errorf("%w: %s: expected %d transactions, found %d", ErrBundleCorrupt, bundle.ID, len(txs), len(stored.Transactions))
+	}
+	result.BundleID = bundle.ID
+
+	for _, tx := range txs {
+		if err := j.transactions.Delete(ctx, tx.ID); err != nil {
+			return fmt.Errorf("failed to remove archived transaction %s: %w", tx.ID, err)
+		}
+		result.Archived++
+	}
+	return nil
+}
+
+func (j *Job) purge(ctx context.Context, txs []*transaction.Transaction, result *PolicyResult) error {
+	for _, tx := range txs {
+		if err := j.transactions.Delete(ctx, tx.ID); err != nil {
+			return fmt.Errorf("failed to purge transaction %s: %w", tx.ID, err)
+		}
+		result.Purged++
+	}
+	return nil
+}
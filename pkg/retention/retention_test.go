@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapStore keeps transactions in a map and ignores query filters
+type mapStore struct {
+	storage.Repository
+	txs map[string]*transaction.Transaction
+}
+
+func (s *mapStore) Create(ctx context.Context, entity interface{}) error {
+	tx := entity.(*transaction.Transaction)
+	if _, exists := s.txs[tx.ID]; exists {
+		return fmt.Errorf("entity already exists: %s", tx.ID)
+	}
+	s.txs[tx.ID] = tx
+	return nil
+}
+
+func (s *mapStore) Delete(ctx context.Context, id string) error {
+	delete(s.txs, id)
+	return nil
+}
+
+func (s *mapStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	out := results.(*[]*transaction.Transaction)
+	for _, tx := range s.txs {
+		*out = append(*out, tx)
+	}
+	return nil
+}
+
+func TestPolicyValidation(t *testing.T) {
+	for _, policy := range DefaultPolicies(7) {
+		assert.NoError(t, policy.Validate())
+	}
+
+	unsafe := Policy{Name: "purge-posted", Action: Purge, Statuses: []transaction.TransactionStatus{transaction.Posted}, Years: 10}
+	assert.ErrorIs(t, unsafe.Validate(), ErrInvalidPolicy)
+	assert.ErrorIs(t, Policy{Name: "no-age", Action: Archive, Statuses: []transaction.TransactionStatus{transaction.Posted}}.Validate(), ErrInvalidPolicy)
+
+	_, err := NewJob(&mapStore{}, nil, []Policy{unsafe})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	_, err = NewJob(&mapStore{}, nil, DefaultPolicies(7))
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+}
+
+func TestJob(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	store := &mapStore{txs: map[string]*transaction.Transaction{
+		"OLD-POSTED":  {ID: "OLD-POSTED", Status: transaction.Posted, Date: now.AddDate(-8, 0, 0)},
+		"OLD-VOIDED":  {ID: "OLD-VOIDED", Status: transaction.Voided, Date: now.AddDate(-9, 0, 0)},
+		"NEW-POSTED":  {ID: "NEW-POSTED", Status: transaction.Posted, Date: now.AddDate(-1, 0, 0)},
+		"OLD-DRAFT":   {ID: "OLD-DRAFT", Status: transaction.Draft, Date: now.AddDate(0, 0, -120)},
+		"NEW-DRAFT":   {ID: "NEW-DRAFT", Status: transaction.Draft, Date: now.AddDate(0, 0, -10)},
+		"OLD-PENDING": {ID: "OLD-PENDING", Status: transaction.Pending, Date: now.AddDate(-10, 0, 0)},
+	}}
+
+	dir := t.TempDir()
+	cold, err := NewDirStorage(dir)
+	require.NoError(t, err)
+	job, err := NewJob(store, cold, DefaultPolicies(7))
+	require.NoError(t, err)
+
+	report, err := job.Run(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, 2, report.Results[0].Archived)
+	assert.Equal(t, 1, report.Results[1].Purged)
+	assert.Len(t, store.txs, 3)
+	assert.Contains(t, store.txs, "OLD-PENDING")
+
+	bundleID := report.Results[0].BundleID
+	require.NotEmpty(t, bundleID)
+
+	t.Run("restore", func(t *testing.T) {
+		restored, err := job.Restore(ctx, bundleID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, restored)
+		assert.Equal(t, transaction.Posted, store.txs["OLD-POSTED"].Status)
+	})
+
+	t.Run("corrupt bundles are rejected", func(t *testing.T) {
+		bundle, err := cold.Read(ctx, bundleID)
+		require.NoError(t, err)
+		bundle.Transactions[0].Description = "tampered"
+		data, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, bundleID+".json"), data, 0o640))
+
+		_, err = job.Restore(ctx, bundleID)
+		assert.ErrorIs(t, err, ErrBundleCorrupt)
+
+		_, err = job.Restore(ctx, "missing")
+		assert.ErrorIs(t, err, ErrBundleNotFound)
+	})
+}
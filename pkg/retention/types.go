@@ -0,0 +1,142 @@
+// Package retention applies data retention policies to the ledger. Old
+// transactions are moved to cold storage archives from which they can be
+// restored; only unposted drafts are ever purged outright, so posted data is
+// never deleted without an archived copy.
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var (
+	ErrInvalidPolicy  = errors.New("invalid retention policy")
+	ErrBundleNotFound = errors.New("archive bundle not found")
+	ErrBundleCorrupt  = errors.New("archive bundle corrupt")
+)
+
+// Action is what a policy does with the transactions it selects
+type Action string
+
+const (
+	// Archive moves transactions to cold storage and removes them from the store
+	Archive Action = "ARCHIVE"
+	// Purge deletes transactions without keeping a copy; drafts only
+	Purge Action = "PURGE"
+)
+
+// Policy selects transactions by status and age and applies an action to them
+type Policy struct {
+	// Name identifying the policy in run reports
+	Name string `json:"name"`
+	// Action applied to matching transactions
+	Action Action `json:"action"`
+	// Statuses the policy applies to
+	Statuses []transaction.TransactionStatus `json:"statuses"`
+	// Age after which a transaction is selected, measured from its date
+	Years  int `json:"years,omitempty"`
+	Months int `json:"months,omitempty"`
+	Days   int `json:"days,omitempty"`
+}
+
+// Validate checks that a policy is well formed and safe. Purge policies may
+// only select draft transactions.
+func (p Policy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPolicy)
+	}
+	if len(p.Statuses) == 0 {
+		return fmt.Errorf("%w: %s: at least one status is required", ErrInvalidPolicy, p.Name)
+	}
+	if p.Years < 0 || p.Months < 0 || p.Days < 0 || p.Years+p.Months+p.Days == 0 {
+		return fmt.Errorf("%w: %s: a positive retention age is required", ErrInvalidPolicy, p.Name)
+	}
+
+	switch p.Action {
+	case Archive:
+	case Purge:
+		for _, status := range p.Statuses {
+			if status != transaction.Draft {
+				return fmt.Errorf("%w: %s: only drafts may be purged, %s transactions must be archived", ErrInvalidPolicy, p.Name, status)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %s: unknown action %q", ErrInvalidPolicy, p.Name, p.Action)
+	}
+	return nil
+}
+
+// Cutoff returns the date before which transactions are selected
+func (p Policy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(-p.Years, -p.Months, -p.Days)
+}
+
+// applies reports whether a transaction is selected by the policy
+func (p Policy) applies(tx *transaction.Transaction, cutoff time.Time) bool {
+	if !tx.Date.Before(cutoff) {
+		return false
+	}
+	for _, status := range p.Statuses {
+		if tx.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPolicies returns a typical retention schedule: posted and voided
+// transactions are archived after the given number of years and drafts are
+// purged after 90 days
+func DefaultPolicies(archiveAfterYears int) []Policy {
+	return []Policy{
+		{
+			Name:     "archive-closed-years",
+			Action:   Archive,
+			Statuses: []transaction.TransactionStatus{transaction.Posted, transaction.Voided},
+			Years:    archiveAfterYears,
+		},
+		{
+			Name:     "purge-stale-drafts",
+			Action:   Purge,
+			Statuses: []transaction.TransactionStatus{transaction.Draft},
+			Days:     90,
+		},
+	}
+}
+
+// Bundle is a set of transactions archived together by one policy run
+type Bundle struct {
+	// Unique identifier of the bundle
+	ID string `json:"id"`
+	// Policy that archived the transactions
+	Policy string `json:"policy"`
+	// Cutoff date the policy applied
+	Cutoff time.Time `json:"cutoff"`
+	// When the bundle was written
+	Created time.Time `json:"created"`
+	// Archived transactions
+	Transactions []*transaction.Transaction `json:"transactions"`
+	// Digest of the archived transactions, checked on read
+	Checksum string `json:"checksum"`
+}
+
+// PolicyResult reports what a single policy did during a run
+type PolicyResult struct {
+	Policy   string `json:"policy"`
+	Action   Action `json:"action"`
+	Selected int    `json:"selected"`
+	Archived int    `json:"archived"`
+	Purged   int    `json:"purged"`
+	// Archive bundle written, for archive policies that selected transactions
+	BundleID string `json:"bundle_id,omitempty"`
+}
+
+// RunReport is the result of a retention job run
+type RunReport struct {
+	Results   []PolicyResult `json:"results"`
+	Started   time.Time      `json:"started"`
+	Completed time.Time      `json:"completed"`
+}
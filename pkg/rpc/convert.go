@@ -0,0 +1,280 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/rpc/ledgerpb"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	accountTypes = map[account.AccountType]ledgerpb.AccountType{
+		account.Asset:     ledgerpb.AccountType_ACCOUNT_TYPE_ASSET,
+		account.Liability: ledgerpb.AccountType_ACCOUNT_TYPE_LIABILITY,
+		account.Equity:    ledgerpb.AccountType_ACCOUNT_TYPE_EQUITY,
+		account.Revenue:   ledgerpb.AccountType_ACCOUNT_TYPE_REVENUE,
+		account.Expense:   ledgerpb.AccountType_ACCOUNT_TYPE_EXPENSE,
+	}
+	accountStatuses = map[account.AccountStatus]ledgerpb.AccountStatus{
+		account.Active:   ledgerpb.AccountStatus_ACCOUNT_STATUS_ACTIVE,
+		account.Inactive: ledgerpb.AccountStatus_ACCOUNT_STATUS_INACTIVE,
+		account.Closed:   ledgerpb.AccountStatus_ACCOUNT_STATUS_CLOSED,
+		account.Frozen:   ledgerpb.AccountStatus_ACCOUNT_STATUS_FROZEN,
+	}
+	entryTypes = map[transaction.EntryType]ledgerpb.EntryType{
+		transaction.Debit:  ledgerpb.EntryType_ENTRY_TYPE_DEBIT,
+		transaction.Credit: ledgerpb.EntryType_ENTRY_TYPE_CREDIT,
+	}
+	transactionTypes = map[transaction.TransactionType]ledgerpb.TransactionType{
+		transaction.Journal:  ledgerpb.TransactionType_TRANSACTION_TYPE_JOURNAL,
+		transaction.Transfer: ledgerpb.TransactionType_TRANSACTION_TYPE_TRANSFER,
+		transaction.Reversal: ledgerpb.TransactionType_TRANSACTION_TYPE_REVERSAL,
+	}
+	transactionStatuses = map[transaction.TransactionStatus]ledgerpb.TransactionStatus{
+		transaction.Draft:   ledgerpb.TransactionStatus_TRANSACTION_STATUS_DRAFT,
+		transaction.Pending: ledgerpb.TransactionStatus_TRANSACTION_STATUS_PENDING,
+		transaction.Posted:  ledgerpb.TransactionStatus_TRANSACTION_STATUS_POSTED,
+		transaction.Voided:  ledgerpb.TransactionStatus_TRANSACTION_STATUS_VOIDED,
+	}
+)
+
+// reverseLookup finds the domain value mapped to a protobuf enum value
+func reverseLookup[K comparable, V comparable](m map[K]V, v V) (K, bool) {
+	for k, candidate := range m {
+		if candidate == v {
+			return k, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+func moneyToProto(m money.Money) *ledgerpb.Money {
+	return &ledgerpb.Money{Amount: m.Amount.String(), Currency: m.Currency}
+}
+
+func moneyFromProto(m *ledgerpb.Money) (money.Money, error) {
+	if m == nil {
+		return money.Money{}, fmt.Errorf("amount is required")
+	}
+	amount, err := decimal.NewFromString(m.Amount)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("invalid amount %q: %w", m.Amount, err)
+	}
+	return money.Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timePtrToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func accountToProto(acc *account.Account) *ledgerpb.Account {
+	pb := &ledgerpb.Account{
+		Id:           acc.ID,
+		Code:         acc.Code,
+		Name:         acc.Name,
+		Type:         accountTypes[acc.Type],
+		Status:       accountStatuses[acc.Status],
+		EntityId:     acc.EntityID,
+		Created:      timeToProto(acc.Created),
+		LastModified: timeToProto(acc.LastModified),
+	}
+	if acc.ParentID != nil {
+		pb.ParentId = *acc.ParentID
+	}
+	if acc.Balance != nil {
+		pb.Balance = moneyToProto(*acc.Balance)
+	}
+	return pb
+}
+
+func accountFromProto(pb *ledgerpb.Account) (*account.Account, error) {
+	if pb == nil {
+		return nil, fmt.Errorf("account is required")
+	}
+
+	accType, ok := reverseLookup(accountTypes, pb.Type)
+	if !ok {
+		return nil, fmt.Errorf("invalid account type %s", pb.Type)
+	}
+	acc := &account.Account{
+		ID:       pb.Id,
+		Code:     pb.Code,
+		Name:     pb.Name,
+		Type:     accType,
+		Status:   account.Active,
+		EntityID: pb.EntityId,
+	}
+	if pb.Status != ledgerpb.AccountStatus_ACCOUNT_STATUS_UNSPECIFIED {
+		status, ok := reverseLookup(accountStatuses, pb.Status)
+		if !ok {
+			return nil, fmt.Errorf("invalid account status %s", pb.Status)
+		}
+		acc.Status = status
+	}
+	if pb.ParentId != "" {
+		parentID := pb.ParentId
+		acc.ParentID = &parentID
+	}
+	if pb.Balance != nil {
+		balance, err := moneyFromProto(pb.Balance)
+		if err != nil {
+			return nil, err
+		}
+		acc.Balance = &balance
+	}
+	return acc, nil
+}
+
+func transactionToProto(tx *transaction.Transaction) *ledgerpb.Transaction {
+	pb := &ledgerpb.Transaction{
+		Id:           tx.ID,
+		Type:         transactionTypes[tx.Type],
+		Status:       transactionStatuses[tx.Status],
+		Date:         timeToProto(tx.Date),
+		Description:  tx.Description,
+		CreatedBy:    tx.CreatedBy,
+		EntityId:     tx.EntityID,
+		PostedAt:     timePtrToProto(tx.PostedAt),
+		VoidedAt:     timePtrToProto(tx.VoidedAt),
+		VoidReason:   tx.VoidReason,
+		ReversalId:   tx.ReversalID,
+		ReversedFrom: tx.ReversedFrom,
+	}
+	for _, entry := range tx.Entries {
+		pb.Entries = append(pb.Entries, &ledgerpb.Entry{
+			AccountId:   entry.AccountID,
+			Amount:      moneyToProto(entry.Amount),
+			Type:        entryTypes[entry.Type],
+			Description: entry.Description,
+			PartyId:     entry.PartyID,
+		})
+	}
+	return pb
+}
+
+// transactionFromProto converts a transaction submitted for posting. Only
+// client-settable fields are read; status and timestamps are managed by the
+// processor.
+func transactionFromProto(pb *ledgerpb.Transaction) (*transaction.Transaction, error) {
+	if pb == nil {
+		return nil, fmt.Errorf("transaction is required")
+	}
+
+	txType := transaction.Journal
+	if pb.Type != ledgerpb.TransactionType_TRANSACTION_TYPE_UNSPECIFIED {
+		t, ok := reverseLookup(transactionTypes, pb.Type)
+		if !ok {
+			return nil, fmt.Errorf("invalid transaction type %s", pb.Type)
+		}
+		txType = t
+	}
+
+	now := time.Now()
+	tx := &transaction.Transaction{
+		ID:           pb.Id,
+		Type:         txType,
+		Status:       transaction.Draft,
+		Date:         timeFromProto(pb.Date),
+		Description:  pb.Description,
+		CreatedBy:    pb.CreatedBy,
+		EntityID:     pb.EntityId,
+		Created:      now,
+		LastModified: now,
+	}
+	if tx.Date.IsZero() {
+		tx.Date = now
+	}
+
+	for i, e := range pb.Entries {
+		entryType, ok := reverseLookup(entryTypes, e.Type)
+		if !ok {
+			return nil, fmt.Errorf("entry %d: invalid entry type %s", i, e.Type)
+		}
+		amount, err := moneyFromProto(e.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		tx.Entries = append(tx.Entries, transaction.Entry{
+			AccountID:   e.AccountId,
+			Amount:      amount,
+			Type:        entryType,
+			Description: e.Description,
+			PartyID:     e.PartyId,
+		})
+	}
+	return tx, nil
+}
+
+func periodToProto(p reporting.ReportPeriod) *ledgerpb.ReportPeriod {
+	return &ledgerpb.ReportPeriod{Start: timeToProto(p.Start), End: timeToProto(p.End)}
+}
+
+func periodFromProto(pb *ledgerpb.ReportPeriod) reporting.ReportPeriod {
+	if pb == nil {
+		return reporting.ReportPeriod{}
+	}
+	return reporting.ReportPeriod{Start: timeFromProto(pb.Start), End: timeFromProto(pb.End)}
+}
+
+func reportLineToProto(line *reporting.ReportLine) *ledgerpb.ReportLine {
+	pb := &ledgerpb.ReportLine{
+		AccountId:   line.AccountID,
+		AccountCode: line.AccountCode,
+		AccountName: line.AccountName,
+		Amount:      moneyToProto(line.Amount),
+		Level:       int32(line.Level),
+		ParentId:    line.ParentID,
+	}
+	if line.PreviousAmount != nil {
+		pb.PreviousAmount = moneyToProto(*line.PreviousAmount)
+	}
+	for _, child := range line.Children {
+		pb.Children = append(pb.Children, reportLineToProto(child))
+	}
+	return pb
+}
+
+func reportToProto(report *reporting.Report) *ledgerpb.Report {
+	pb := &ledgerpb.Report{
+		Id:          report.ID,
+		Type:        string(report.Type),
+		Title:       report.Title,
+		Period:      periodToProto(report.Period),
+		EntityId:    report.EntityID,
+		Currency:    report.Currency,
+		GeneratedAt: timeToProto(report.GeneratedAt),
+		GeneratedBy: report.GeneratedBy,
+		Totals:      make(map[string]*ledgerpb.Money, len(report.Totals)),
+	}
+	for _, line := range report.Lines {
+		pb.Lines = append(pb.Lines, reportLineToProto(line))
+	}
+	for key, total := range report.Totals {
+		pb.Totals[key] = moneyToProto(total)
+	}
+	return pb
+}
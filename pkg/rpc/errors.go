@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	finerrors "github.com/johnayoung/finlib/pkg/errors"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps a domain error to a gRPC status error
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Error(codeFor(err), err.Error())
+}
+
+func codeFor(err error) codes.Code {
+	var lockErr *storage.OptimisticLockError
+	var finErr *finerrors.FinancialError
+
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, auth.ErrPermissionDenied), errors.Is(err, storage.ErrTenantMismatch):
+		return codes.PermissionDenied
+	case errors.Is(err, account.ErrAccountNotFound):
+		return codes.NotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, account.ErrInvalidAccountType), errors.Is(err, account.ErrInvalidAccountCode):
+		return codes.InvalidArgument
+	case errors.Is(err, account.ErrAccountLocked), errors.Is(err, account.ErrInvalidOperation):
+		return codes.FailedPrecondition
+	case errors.As(err, &lockErr):
+		return codes.Aborted
+	case errors.As(err, &finErr):
+		switch finErr.Category {
+		case finerrors.ValidationError:
+			return codes.InvalidArgument
+		case finerrors.BusinessError:
+			return codes.FailedPrecondition
+		case finerrors.SecurityError:
+			return codes.PermissionDenied
+		case finerrors.ConcurrencyError:
+			return codes.Aborted
+		}
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
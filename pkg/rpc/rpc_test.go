@@ -0,0 +1,197 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/rpc/ledgerpb"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// mapAccounts is an in-memory AccountManager
+type mapAccounts struct {
+	account.AccountManager
+	accounts map[string]*account.Account
+}
+
+func (m *mapAccounts) CreateAccount(ctx context.Context, acc *account.Account) error {
+	m.accounts[acc.ID] = acc
+	return nil
+}
+
+func (m *mapAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	acc, ok := m.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", account.ErrAccountNotFound, id)
+	}
+	return acc, nil
+}
+
+func (m *mapAccounts) ListAccounts(ctx context.Context, filters map[string]interface{}) ([]*account.Account, error) {
+	var accounts []*account.Account
+	for _, acc := range m.accounts {
+		if filters["type"] == nil || filters["type"] == string(acc.Type) {
+			accounts = append(accounts, acc)
+		}
+	}
+	return accounts, nil
+}
+
+func newTestClient(t *testing.T, interceptor grpc.UnaryServerInterceptor) (*grpc.ClientConn, *memory.MemoryStore) {
+	listener := bufconn.Listen(1 << 20)
+	store := memory.NewMemoryStore()
+	processor := transaction.NewBasicTransactionProcessor(store)
+
+	var opts []grpc.ServerOption
+	if interceptor != nil {
+		opts = append(opts, grpc.UnaryInterceptor(interceptor))
+	}
+	server := grpc.NewServer(opts...)
+	NewServer(&mapAccounts{accounts: make(map[string]*account.Account)}, processor, store, nil).Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, store
+}
+
+func TestAccountService(t *testing.T) {
+	ctx := context.Background()
+	conn, _ := newTestClient(t, nil)
+	client := ledgerpb.NewAccountServiceClient(conn)
+
+	created, err := client.CreateAccount(ctx, &ledgerpb.CreateAccountRequest{Account: &ledgerpb.Account{
+		Id:      "1000",
+		Code:    "1000",
+		Name:    "Cash",
+		Type:    ledgerpb.AccountType_ACCOUNT_TYPE_ASSET,
+		Balance: &ledgerpb.Money{Amount: "10.50", Currency: "USD"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, ledgerpb.AccountStatus_ACCOUNT_STATUS_ACTIVE, created.Status)
+
+	fetched, err := client.GetAccount(ctx, &ledgerpb.GetAccountRequest{Id: "1000"})
+	require.NoError(t, err)
+	assert.Equal(t, "Cash", fetched.Name)
+	assert.Equal(t, "10.5", fetched.Balance.Amount)
+
+	_, err = client.GetAccount(ctx, &ledgerpb.GetAccountRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.CreateAccount(ctx, &ledgerpb.CreateAccountRequest{Account: &ledgerpb.Account{Id: "X"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	listed, err := client.ListAccounts(ctx, &ledgerpb.ListAccountsRequest{Filters: map[string]string{"type": "ASSET"}})
+	require.NoError(t, err)
+	assert.Len(t, listed.Accounts, 1)
+}
+
+func TestTransactionService(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	request := &ledgerpb.PostTransactionRequest{Transaction: &ledgerpb.Transaction{
+		Id:          "TX1",
+		Date:        timestamppb.New(date),
+		Description: "Sale",
+		Entries: []*ledgerpb.Entry{
+			{AccountId: "1000", Amount: &ledgerpb.Money{Amount: "100", Currency: "USD"}, Type: ledgerpb.EntryType_ENTRY_TYPE_DEBIT},
+			{AccountId: "4000", Amount: &ledgerpb.Money{Amount: "100", Currency: "USD"}, Type: ledgerpb.EntryType_ENTRY_TYPE_CREDIT},
+		},
+	}}
+
+	t.Run("post and fetch", func(t *testing.T) {
+		conn, store := newTestClient(t, nil)
+		client := ledgerpb.NewTransactionServiceClient(conn)
+
+		posted, err := client.PostTransaction(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, ledgerpb.TransactionStatus_TRANSACTION_STATUS_POSTED, posted.Status)
+
+		var stored transaction.Transaction
+		require.NoError(t, store.Read(ctx, "TX1", &stored))
+		assert.Equal(t, transaction.Posted, stored.Status)
+		assert.Equal(t, transaction.Journal, stored.Type)
+		assert.True(t, date.Equal(stored.Date))
+
+		fetched, err := client.GetTransaction(ctx, &ledgerpb.GetTransactionRequest{Id: "TX1"})
+		require.NoError(t, err)
+		require.Len(t, fetched.Entries, 2)
+		assert.Equal(t, ledgerpb.EntryType_ENTRY_TYPE_CREDIT, fetched.Entries[1].Type)
+	})
+
+	t.Run("existing IDs are refused", func(t *testing.T) {
+		conn, store := newTestClient(t, nil)
+		client := ledgerpb.NewTransactionServiceClient(conn)
+
+		_, err := client.PostTransaction(ctx, request)
+		require.NoError(t, err)
+
+		overwrite := &ledgerpb.PostTransactionRequest{Transaction: &ledgerpb.Transaction{
+			Id: "TX1",
+			Entries: []*ledgerpb.Entry{
+				{AccountId: "1000", Amount: &ledgerpb.Money{Amount: "999", Currency: "USD"}, Type: ledgerpb.EntryType_ENTRY_TYPE_DEBIT},
+				{AccountId: "4000", Amount: &ledgerpb.Money{Amount: "999", Currency: "USD"}, Type: ledgerpb.EntryType_ENTRY_TYPE_CREDIT},
+			},
+		}}
+		_, err = client.PostTransaction(ctx, overwrite)
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		var stored transaction.Transaction
+		require.NoError(t, store.Read(ctx, "TX1", &stored))
+		assert.Equal(t, transaction.Posted, stored.Status)
+		assert.Equal(t, "100", stored.Entries[0].Amount.Amount.String())
+	})
+
+	t.Run("invalid amount", func(t *testing.T) {
+		conn, _ := newTestClient(t, nil)
+		client := ledgerpb.NewTransactionServiceClient(conn)
+
+		_, err := client.PostTransaction(ctx, &ledgerpb.PostTransactionRequest{Transaction: &ledgerpb.Transaction{
+			Id:      "TX2",
+			Entries: []*ledgerpb.Entry{{AccountId: "1000", Amount: &ledgerpb.Money{Amount: "abc"}, Type: ledgerpb.EntryType_ENTRY_TYPE_DEBIT}},
+		}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("permission errors are mapped", func(t *testing.T) {
+		auditor := &auth.Principal{ID: "auditor", Roles: []*auth.Role{{Name: "auditor", Permissions: []auth.Permission{auth.ReportGenerate}}}}
+		interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(auth.WithPrincipal(ctx, auditor), req)
+		}
+		conn, store := newTestClient(t, interceptor)
+		client := ledgerpb.NewTransactionServiceClient(conn)
+
+		_, err := client.PostTransaction(ctx, request)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		var stored transaction.Transaction
+		assert.ErrorIs(t, store.Read(ctx, "TX1", &stored), storage.ErrNotFound)
+	})
+}
+
+func TestCodeFor(t *testing.T) {
+	assert.Equal(t, codes.Unauthenticated, codeFor(auth.ErrUnauthenticated))
+	assert.Equal(t, codes.FailedPrecondition, codeFor(fmt.Errorf("wrapped: %w", account.ErrAccountLocked)))
+	assert.Equal(t, codes.AlreadyExists, codeFor(fmt.Errorf("failed to store transaction: %w", storage.ErrAlreadyExists)))
+	assert.Equal(t, codes.Unknown, codeFor(fmt.Errorf("boom")))
+}
@@ -0,0 +1,190 @@
+// Package rpc exposes finlib's account, transaction and reporting interfaces
+// as gRPC services so non-Go services can use finlib as a ledger service.
+// The protobuf definitions live in proto/finlib/v1.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/johnayoung/finlib --go-grpc_out=../.. --go-grpc_opt=module=github.com/johnayoung/finlib finlib/v1/ledger.proto
+
+import (
+	"context"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/rpc/ledgerpb"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the ledger gRPC services on top of the existing
+// account, transaction and reporting interfaces
+type Server struct {
+	ledgerpb.UnimplementedAccountServiceServer
+	ledgerpb.UnimplementedTransactionServiceServer
+	ledgerpb.UnimplementedReportServiceServer
+
+	accounts     account.AccountManager
+	processor    transaction.TransactionProcessor
+	transactions storage.Repository
+	reports      reporting.ReportGenerator
+}
+
+// NewServer creates a gRPC server backed by the given components. Posted
+// transactions are created in transactions before they are posted, so it
+// must be the repository the processor posts to.
+func NewServer(accounts account.AccountManager, processor transaction.TransactionProcessor, transactions storage.Repository, reports reporting.ReportGenerator) *Server {
+	return &Server{
+		accounts:     accounts,
+		processor:    processor,
+		transactions: transactions,
+		reports:      reports,
+	}
+}
+
+// Register registers all ledger services with a gRPC server
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	ledgerpb.RegisterAccountServiceServer(registrar, s)
+	ledgerpb.RegisterTransactionServiceServer(registrar, s)
+	ledgerpb.RegisterReportServiceServer(registrar, s)
+}
+
+// CreateAccount implements AccountService.CreateAccount
+func (s *Server) CreateAccount(ctx context.Context, req *ledgerpb.CreateAccountRequest) (*ledgerpb.Account, error) {
+	acc, err := accountFromProto(req.GetAccount())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.accounts.CreateAccount(ctx, acc); err != nil {
+		return nil, toStatus(err)
+	}
+	return accountToProto(acc), nil
+}
+
+// GetAccount implements AccountService.GetAccount
+func (s *Server) GetAccount(ctx context.Context, req *ledgerpb.GetAccountRequest) (*ledgerpb.Account, error) {
+	acc, err := s.accounts.GetAccount(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return accountToProto(acc), nil
+}
+
+// UpdateAccount implements AccountService.UpdateAccount
+func (s *Server) UpdateAccount(ctx context.Context, req *ledgerpb.UpdateAccountRequest) (*ledgerpb.Account, error) {
+	acc, err := accountFromProto(req.GetAccount())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.accounts.UpdateAccount(ctx, acc); err != nil {
+		return nil, toStatus(err)
+	}
+	return accountToProto(acc), nil
+}
+
+// ListAccounts implements AccountService.ListAccounts
+func (s *Server) ListAccounts(ctx context.Context, req *ledgerpb.ListAccountsRequest) (*ledgerpb.ListAccountsResponse, error) {
+	filters := make(map[string]interface{}, len(req.GetFilters()))
+	for field, value := range req.GetFilters() {
+		filters[field] = value
+	}
+
+	accounts, err := s.accounts.ListAccounts(ctx, filters)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &ledgerpb.ListAccountsResponse{}
+	for _, acc := range accounts {
+		resp.Accounts = append(resp.Accounts, accountToProto(acc))
+	}
+	return resp, nil
+}
+
+// GetAccountBalance implements AccountService.GetAccountBalance
+func (s *Server) GetAccountBalance(ctx context.Context, req *ledgerpb.GetAccountBalanceRequest) (*ledgerpb.AccountBalance, error) {
+	balance, err := s.accounts.GetAccountBalance(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ledgerpb.AccountBalance{
+		AccountId:         balance.AccountID,
+		AsOf:              timeToProto(balance.AsOf),
+		Balance:           &ledgerpb.Money{Amount: balance.Amount, Currency: balance.Currency},
+		LastTransactionId: balance.LastTransactionID,
+	}, nil
+}
+
+// PostTransaction implements TransactionService.PostTransaction. The ID of
+// a stored transaction is refused with AlreadyExists, so posted
+// transactions are never overwritten.
+func (s *Server) PostTransaction(ctx context.Context, req *ledgerpb.PostTransactionRequest) (*ledgerpb.Transaction, error) {
+	tx, err := transactionFromProto(req.GetTransaction())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := transaction.CreateAndPost(ctx, s.transactions, s.processor, tx); err != nil {
+		return nil, toStatus(err)
+	}
+	return transactionToProto(tx), nil
+}
+
+// GetTransaction implements TransactionService.GetTransaction
+func (s *Server) GetTransaction(ctx context.Context, req *ledgerpb.GetTransactionRequest) (*ledgerpb.Transaction, error) {
+	tx, err := s.processor.GetTransaction(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return transactionToProto(tx), nil
+}
+
+// VoidTransaction implements TransactionService.VoidTransaction
+func (s *Server) VoidTransaction(ctx context.Context, req *ledgerpb.VoidTransactionRequest) (*ledgerpb.Transaction, error) {
+	if err := s.processor.VoidTransaction(ctx, req.GetId(), req.GetReason()); err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetTransaction(ctx, &ledgerpb.GetTransactionRequest{Id: req.GetId()})
+}
+
+// ReverseTransaction implements TransactionService.ReverseTransaction and
+// returns the reversing transaction
+func (s *Server) ReverseTransaction(ctx context.Context, req *ledgerpb.ReverseTransactionRequest) (*ledgerpb.Transaction, error) {
+	if err := s.processor.ReverseTransaction(ctx, req.GetId(), req.GetReason()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	original, err := s.processor.GetTransaction(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return s.GetTransaction(ctx, &ledgerpb.GetTransactionRequest{Id: original.ReversalID})
+}
+
+// GenerateReport implements ReportService.GenerateReport
+func (s *Server) GenerateReport(ctx context.Context, req *ledgerpb.GenerateReportRequest) (*ledgerpb.Report, error) {
+	if req.GetDefinitionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "definition_id is required")
+	}
+
+	def, err := s.reports.LoadDefinition(ctx, req.GetDefinitionId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	opts := reporting.ReportOptions{
+		Period:   periodFromProto(req.GetPeriod()),
+		EntityID: req.GetEntityId(),
+		Currency: req.GetCurrency(),
+	}
+	if req.GetPreviousPeriod() != nil {
+		previous := periodFromProto(req.GetPreviousPeriod())
+		opts.Period.Previous = &previous
+	}
+
+	report, err := s.reports.GenerateReport(ctx, def, opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return reportToProto(report), nil
+}
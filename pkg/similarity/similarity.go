@@ -0,0 +1,51 @@
+// Package similarity scores how alike two free-text descriptions are, for
+// matching bank statement lines to ledger entries and spotting duplicate
+// transactions. It has no dependencies so that any module can use it.
+package similarity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Score scores how alike two descriptions are, from 0 for nothing in
+// common to 1 for the same text ignoring case, punctuation and spacing. It
+// is the Dice coefficient of the descriptions' letter pairs.
+func Score(a, b string) float64 {
+	pairsA, pairsB := bigrams(a), bigrams(b)
+	if len(pairsA) == 0 || len(pairsB) == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int, len(pairsA))
+	for _, pair := range pairsA {
+		counts[pair]++
+	}
+	shared := 0
+	for _, pair := range pairsB {
+		if counts[pair] > 0 {
+			counts[pair]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(pairsA)+len(pairsB))
+}
+
+// bigrams returns the adjacent letter pairs of each word of a text,
+// lowercased and without punctuation
+func bigrams(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var pairs []string
+	for _, word := range words {
+		runes := []rune(word)
+		if len(runes) == 1 {
+			pairs = append(pairs, word)
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			pairs = append(pairs, string(runes[i:i+2]))
+		}
+	}
+	return pairs
+}
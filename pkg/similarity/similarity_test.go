@@ -0,0 +1,14 @@
+package similarity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 1.0, Score("Office Supplies", "office-supplies"))
+	assert.Equal(t, 0.0, Score("", "office"))
+	assert.Greater(t, Score("OFFICE SUPPLIES LTD", "Office supplies"), 0.7)
+	assert.Less(t, Score("Electricity", "Consulting"), 0.3)
+}
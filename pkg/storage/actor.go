@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+)
+
+// Actor identifies who is making a change and the request it is part of.
+// Stores record the context actor on every audit entry and stamp it on
+// Attributed entities.
+type Actor struct {
+	// User or service performing the operation
+	UserID string
+	// Originating system or channel (e.g., "api", "import", "batch")
+	Source string
+	// Free-form justification recorded with the change
+	Reason string
+	// ID of the request that made the change, for correlating logs
+	RequestID string
+	// Network address the request came from
+	IPAddress string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor making changes
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by a context. When no user is
+// set explicitly the authenticated principal, if any, is used.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.UserID == "" {
+		actor.UserID = auth.PrincipalID(ctx)
+	}
+	return actor
+}
+
+// Metadata returns the actor's request details as audit entry metadata
+func (a Actor) Metadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for key, value := range map[string]string{
+		"source":     a.Source,
+		"reason":     a.Reason,
+		"request_id": a.RequestID,
+		"ip_address": a.IPAddress,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// Attributed is implemented by entities recording who created and last
+// modified them
+type Attributed interface {
+	GetCreatedBy() string
+	SetCreatedBy(userID string)
+	SetModifiedBy(userID string)
+}
+
+// StampActor records the context actor as the last modifier of an
+// Attributed entity and, on creation, as its creator unless one is set
+func StampActor(ctx context.Context, entity interface{}, created bool) {
+	attributed, ok := entity.(Attributed)
+	if !ok {
+		return
+	}
+	userID := ActorFromContext(ctx).UserID
+	if created && attributed.GetCreatedBy() == "" {
+		attributed.SetCreatedBy(userID)
+	}
+	attributed.SetModifiedBy(userID)
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// EncryptTag is the struct tag marking entity fields encrypted at rest,
+// as in `json:"description" encrypt:"true"`. Tagged fields may be strings
+// or maps with string keys.
+const EncryptTag = "encrypt"
+
+// encryptedPrefix marks a string holding ciphertext
+const encryptedPrefix = "enc:v1:"
+
+// encryptedKey is the only key of a map whose contents are encrypted
+const encryptedKey = "_encrypted"
+
+// EncryptionProvider encrypts and decrypts field values, typically with a
+// key held in a KMS or HSM. The context carries the caller and tenant, so
+// providers may use per-tenant keys.
+type EncryptionProvider interface {
+	// Encrypt returns the ciphertext of a value
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+
+	// Decrypt returns the plaintext of a value Encrypt returned
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// AESProvider is an EncryptionProvider using AES-GCM with a random nonce
+// stored before each ciphertext
+type AESProvider struct {
+	aead cipher.AEAD
+}
+
+// NewAESProvider creates an AES-GCM provider from a 16, 24 or 32 byte key
+func NewAESProvider(key []byte) (*AESProvider, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESProvider{aead: aead}, nil
+}
+
+// Encrypt implements EncryptionProvider.Encrypt
+func (p *AESProvider) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return p.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements EncryptionProvider.Decrypt
+func (p *AESProvider) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	size := p.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return p.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+}
+
+// EncryptedRepository wraps any Repository so the tagged fields of
+// entities are encrypted before they reach it and decrypted when read
+// back. Callers' entities are never modified with ciphertext: writes store
+// an encrypted copy. Values stored before encryption was enabled are read
+// as they are. Encrypted fields can't be filtered, sorted or searched on.
+type EncryptedRepository struct {
+	repo     Repository
+	provider EncryptionProvider
+}
+
+// NewEncryptedRepository wraps a repository with field encryption
+func NewEncryptedRepository(repo Repository, provider EncryptionProvider) *EncryptedRepository {
+	return &EncryptedRepository{repo: repo, provider: provider}
+}
+
+// Create implements Repository.Create
+func (r *EncryptedRepository) Create(ctx context.Context, entity interface{}) error {
+	encrypted, err := r.encrypt(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Create(ctx, encrypted); err != nil {
+		return err
+	}
+	copyVersion(encrypted, entity)
+	return nil
+}
+
+// Read implements Repository.Read
+func (r *EncryptedRepository) Read(ctx context.Context, id string, entity interface{}) error {
+	if err := r.repo.Read(ctx, id, entity); err != nil {
+		return err
+	}
+	return r.decryptInPlace(ctx, entity)
+}
+
+// Update implements Repository.Update
+func (r *EncryptedRepository) Update(ctx context.Context, entity interface{}) error {
+	encrypted, err := r.encrypt(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Update(ctx, encrypted); err != nil {
+		return err
+	}
+	copyVersion(encrypted, entity)
+	return nil
+}
+
+// Delete implements Repository.Delete
+func (r *EncryptedRepository) Delete(ctx context.Context, id string) error {
+	return r.repo.Delete(ctx, id)
+}
+
+// Query implements Repository.Query
+func (r *EncryptedRepository) Query(ctx context.Context, query Query, results interface{}) error {
+	if err := r.repo.Query(ctx, query, results); err != nil {
+		return err
+	}
+	return r.decryptInPlace(ctx, results)
+}
+
+// Count implements Repository.Count
+func (r *EncryptedRepository) Count(ctx context.Context, query Query) (int64, error) {
+	return r.repo.Count(ctx, query)
+}
+
+// Unwrap returns the wrapped repository
+func (r *EncryptedRepository) Unwrap() Repository {
+	return r.repo
+}
+
+// encrypt returns a copy of an entity with its tagged fields encrypted, or
+// the entity itself when it has none
+func (r *EncryptedRepository) encrypt(ctx context.Context, entity interface{}) (interface{}, error) {
+	v := reflect.ValueOf(entity)
+	if !v.IsValid() || !hasEncryptedFields(v.Type()) {
+		return entity, nil
+	}
+	c := &cipherWalk{ctx: ctx, provider: r.provider, encrypt: true}
+	copied, err := c.walk(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt entity: %w", err)
+	}
+	return copied.Interface(), nil
+}
+
+// decryptInPlace decrypts the tagged fields of the value a pointer points to
+func (r *EncryptedRepository) decryptInPlace(ctx context.Context, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || !hasEncryptedFields(v.Type()) {
+		return nil
+	}
+	c := &cipherWalk{ctx: ctx, provider: r.provider}
+	decrypted, err := c.walk(v.Elem())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt entity: %w", err)
+	}
+	v.Elem().Set(decrypted)
+	return nil
+}
+
+// copyVersion passes the version a store set on the encrypted copy back to
+// the caller's entity
+func copyVersion(from, to interface{}) {
+	if from == to {
+		return
+	}
+	if source, ok := from.(Versioned); ok {
+		if target, ok := to.(Versioned); ok {
+			target.SetVersion(source.GetVersion())
+		}
+	}
+}
+
+// cipherWalk copies a value, encrypting or decrypting the tagged fields
+// found along the way. Only the parts of the value leading to tagged
+// fields are copied; the rest is shared.
+type cipherWalk struct {
+	ctx      context.Context
+	provider EncryptionProvider
+	encrypt  bool
+}
+
+func (c *cipherWalk) walk(v reflect.Value) (reflect.Value, error) {
+	if !hasEncryptedFields(v.Type()) {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := c.walk(v.Elem())
+		if err != nil {
+			return v, err
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(elem)
+		return copied, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := c.walk(v.Index(i))
+			if err != nil {
+				return v, err
+			}
+			copied.Index(i).Set(elem)
+		}
+		return copied, nil
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			var value reflect.Value
+			var err error
+			if field.Tag.Get(EncryptTag) == "true" {
+				value, err = c.field(v.Field(i))
+			} else {
+				value, err = c.walk(v.Field(i))
+			}
+			if err != nil {
+				return v, fmt.Errorf("%s: %w", field.Name, err)
+			}
+			copied.Field(i).Set(value)
+		}
+		return copied, nil
+	}
+	return v, nil
+}
+
+// field encrypts or decrypts the value of a tagged field
+func (c *cipherWalk) field(v reflect.Value) (reflect.Value, error) {
+	switch {
+	case v.Kind() == reflect.String:
+		text, err := c.text(v.String())
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(text).Convert(v.Type()), nil
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			return v, nil
+		}
+		if c.encrypt {
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return v, err
+			}
+			text, err := c.text(string(data))
+			if err != nil {
+				return v, err
+			}
+			sealed := reflect.MakeMap(v.Type())
+			elem := reflect.ValueOf(text)
+			if !elem.Type().AssignableTo(v.Type().Elem()) {
+				if !elem.Type().ConvertibleTo(v.Type().Elem()) {
+					return v, fmt.Errorf("fields of type %s cannot be encrypted", v.Type())
+				}
+				elem = elem.Convert(v.Type().Elem())
+			}
+			sealed.SetMapIndex(reflect.ValueOf(encryptedKey).Convert(v.Type().Key()), elem)
+			return sealed, nil
+		}
+		if v.Len() != 1 {
+			return v, nil
+		}
+		sealed := v.MapIndex(reflect.ValueOf(encryptedKey).Convert(v.Type().Key()))
+		if !sealed.IsValid() {
+			return v, nil
+		}
+		if sealed.Kind() == reflect.Interface {
+			sealed = sealed.Elem()
+		}
+		if sealed.Kind() != reflect.String {
+			return v, nil
+		}
+		text, err := c.text(sealed.String())
+		if err != nil {
+			return v, err
+		}
+		opened := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(text), opened.Interface()); err != nil {
+			return v, err
+		}
+		return opened.Elem(), nil
+	}
+	return v, fmt.Errorf("fields of type %s cannot be encrypted", v.Type())
+}
+
+// text encrypts or decrypts a string. Empty strings are stored as they are,
+// and strings without the ciphertext prefix are read as plaintext.
+func (c *cipherWalk) text(s string) (string, error) {
+	if c.encrypt {
+		if s == "" {
+			return s, nil
+		}
+		ciphertext, err := c.provider.Encrypt(c.ctx, []byte(s))
+		if err != nil {
+			return "", err
+		}
+		return encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+	}
+
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return s, nil
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := c.provider.Decrypt(c.ctx, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// encryptedTypes caches whether types contain tagged fields
+var encryptedTypes sync.Map
+
+// hasEncryptedFields reports whether a type has tagged fields, directly or
+// through pointers, slices and nested structs
+func hasEncryptedFields(t reflect.Type) bool {
+	if known, ok := encryptedTypes.Load(t); ok {
+		return known.(bool)
+	}
+	found := inspectFields(t, make(map[reflect.Type]bool))
+	encryptedTypes.Store(t, found)
+	return found
+}
+
+// inspectFields looks for tagged fields, skipping types already being
+// inspected so recursive types terminate
+func inspectFields(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return inspectFields(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get(EncryptTag) == "true" || inspectFields(field.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedRepository(t *testing.T) {
+	ctx := context.Background()
+	provider, err := storage.NewAESProvider([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	store := memory.NewMemoryStore()
+	repo := storage.NewEncryptedRepository(store, provider)
+
+	tx := &transaction.Transaction{
+		ID:          "TX1",
+		Description: "Settlement with Acme",
+		Reference:   "INV-1001",
+		Entries: []transaction.Entry{
+			{AccountID: "cash", Description: "Wire from Acme"},
+			{AccountID: "receivables"},
+		},
+		Attachments: []transaction.Attachment{{ID: "A1", Name: "acme-invoice.pdf"}},
+	}
+	require.NoError(t, repo.Create(ctx, tx))
+	assert.Equal(t, "Settlement with Acme", tx.Description, "the caller's entity stays plaintext")
+	assert.Equal(t, int64(1), tx.Version)
+
+	// The store only sees ciphertext in the tagged fields
+	var stored transaction.Transaction
+	require.NoError(t, store.Read(ctx, "TX1", &stored))
+	assert.True(t, strings.HasPrefix(stored.Description, "enc:v1:"))
+	assert.True(t, strings.HasPrefix(stored.Entries[0].Description, "enc:v1:"))
+	assert.Empty(t, stored.Entries[1].Description)
+	assert.True(t, strings.HasPrefix(stored.Attachments[0].Name, "enc:v1:"))
+	assert.Equal(t, "INV-1001", stored.Reference)
+
+	var read transaction.Transaction
+	require.NoError(t, repo.Read(ctx, "TX1", &read))
+	assert.Equal(t, "Settlement with Acme", read.Description)
+	assert.Equal(t, "Wire from Acme", read.Entries[0].Description)
+	assert.Equal(t, "acme-invoice.pdf", read.Attachments[0].Name)
+
+	read.Description = "Settlement with Acme Corp"
+	require.NoError(t, repo.Update(ctx, &read))
+	assert.Equal(t, int64(2), read.Version)
+
+	// Maps are sealed whole
+	acc := &account.Account{ID: "cash", MetaData: map[string]interface{}{account.CashKey: true, "bank": "First National"}}
+	require.NoError(t, repo.Create(ctx, acc))
+	var storedAcc account.Account
+	require.NoError(t, store.Read(ctx, "cash", &storedAcc))
+	assert.Len(t, storedAcc.MetaData, 1)
+	assert.NotContains(t, storedAcc.MetaData, "bank")
+
+	var readAcc account.Account
+	require.NoError(t, repo.Read(ctx, "cash", &readAcc))
+	assert.Equal(t, acc.MetaData, readAcc.MetaData)
+
+	// Values stored before encryption was enabled read as they are
+	require.NoError(t, store.Create(ctx, &transaction.Transaction{ID: "TX0", Description: "Opening balance"}))
+	var legacy transaction.Transaction
+	require.NoError(t, repo.Read(ctx, "TX0", &legacy))
+	assert.Equal(t, "Opening balance", legacy.Description)
+
+	other, err := storage.NewAESProvider([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	assert.Error(t, storage.NewEncryptedRepository(store, other).Read(ctx, "TX1", &read), "wrong key")
+}
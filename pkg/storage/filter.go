@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ToDocument converts an entity to its JSON form, the document filters are
+// evaluated against
+func ToDocument(entity interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("entity %T does not encode to an object: %w", entity, err)
+	}
+	return doc, nil
+}
+
+// FieldValues returns the values at a dotted path of a document. Paths
+// descend through arrays, so entries.account_id yields the account of
+// every entry.
+func FieldValues(doc interface{}, path string) []interface{} {
+	if path == "" {
+		if list, ok := doc.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{doc}
+	}
+	head, rest, _ := strings.Cut(path, ".")
+	switch t := doc.(type) {
+	case map[string]interface{}:
+		if child, ok := t[head]; ok {
+			return FieldValues(child, rest)
+		}
+	case []interface{}:
+		values := make([]interface{}, 0)
+		for _, item := range t {
+			values = append(values, FieldValues(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// Matches reports whether a document satisfies the filter: whether any
+// value at the field compares to the filter value as the operator requires.
+// Filter values are compared in their JSON form; times compare
+// chronologically. Unknown operators match nothing.
+func (f Filter) Matches(doc map[string]interface{}) bool {
+	values := FieldValues(doc, f.Field)
+	want := jsonValue(f.Value)
+
+	switch strings.ToLower(f.Operator) {
+	case "!=":
+		return !Filter{Field: f.Field, Operator: "=", Value: f.Value}.Matches(doc)
+	case "in":
+		list, ok := want.([]interface{})
+		if !ok {
+			list = []interface{}{want}
+		}
+		for _, v := range values {
+			for _, w := range list {
+				if CompareValues(v, w) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var accept func(int) bool
+	switch f.Operator {
+	case "=":
+		accept = func(c int) bool { return c == 0 }
+	case ">":
+		accept = func(c int) bool { return c > 0 }
+	case ">=":
+		accept = func(c int) bool { return c >= 0 }
+	case "<":
+		accept = func(c int) bool { return c < 0 }
+	case "<=":
+		accept = func(c int) bool { return c <= 0 }
+	default:
+		return false
+	}
+	for _, v := range values {
+		if v != nil && accept(CompareValues(v, want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareValues orders two JSON values: timestamps chronologically,
+// numbers numerically and anything else by its text
+func CompareValues(a, b interface{}) int {
+	sa, aString := a.(string)
+	sb, bString := b.(string)
+	if aString && bString {
+		if ta, err := time.Parse(time.RFC3339Nano, sa); err == nil {
+			if tb, err := time.Parse(time.RFC3339Nano, sb); err == nil {
+				return ta.Compare(tb)
+			}
+		}
+		return strings.Compare(sa, sb)
+	}
+	if fa, ok := a.(float64); ok {
+		if fb, ok := b.(float64); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// jsonValue returns the JSON form of a filter value
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, string, float64, bool:
+		return t
+	case *time.Time:
+		if t == nil {
+			return nil
+		}
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return list
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return v
+	}
+	return decoded
+}
@@ -0,0 +1,236 @@
+package kv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// indexTime is the sortable form of indexed timestamps: UTC with a fixed
+// number of fractional digits
+const indexTime = "2006-01-02T15:04:05.000000000Z"
+
+// match is an entity found by a query
+type match struct {
+	id   string
+	data []byte
+	doc  map[string]interface{}
+}
+
+// find returns the entities of a type matching every filter and the
+// deleted mode. The first filter an index can answer narrows the
+// candidates; without one the whole type is scanned.
+func (s *Store) find(tx Tx, entityType string, filters []storage.Filter, deleted storage.DeletedMode) ([]match, error) {
+	ids, indexed, err := s.candidates(tx, entityType, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]match, 0)
+	if !indexed {
+		err := tx.Scan([]byte(docPrefix+entityType+sep), func(key, value []byte) error {
+			m, ok, err := matchRecord(key, value, filters, deleted)
+			if ok {
+				matches = append(matches, m)
+			}
+			return err
+		})
+		return matches, err
+	}
+
+	for _, id := range ids {
+		key := docKey(entityType, id)
+		value, err := tx.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading entity: %w", err)
+		}
+		if value == nil {
+			continue
+		}
+		m, ok, err := matchRecord(key, value, filters, deleted)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// candidates returns the IDs an index finds for the filters, in ID order,
+// and whether an index was used
+func (s *Store) candidates(tx Tx, entityType string, filters []storage.Filter) ([]string, bool, error) {
+	for _, f := range filters {
+		if !s.isIndexed(entityType, f.Field) {
+			continue
+		}
+		prefix := indexPrefix + entityType + sep + f.Field + sep
+		found := make(map[string]bool)
+		collect := func(p string, accept func(value string) bool) error {
+			return tx.Scan([]byte(p), func(key, id []byte) error {
+				value, _, _ := strings.Cut(strings.TrimPrefix(string(key), prefix), sep)
+				if accept == nil || accept(value) {
+					found[string(id)] = true
+				}
+				return nil
+			})
+		}
+
+		var err error
+		switch op := strings.ToLower(f.Operator); op {
+		case "=":
+			err = collect(prefix+lookupValue(f.Value)+sep, nil)
+		case "in":
+			for _, v := range listOf(f.Value) {
+				if err = collect(prefix+lookupValue(v)+sep, nil); err != nil {
+					break
+				}
+			}
+		case ">", ">=", "<", "<=":
+			// Indexed values are text, so ranges only use the index for
+			// timestamps, whose indexed form sorts chronologically
+			if !isTime(f.Value) {
+				continue
+			}
+			bound := storage.Filter{Field: "v", Operator: op, Value: f.Value}
+			err = collect(prefix, func(value string) bool {
+				return bound.Matches(map[string]interface{}{"v": value})
+			})
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("error reading index: %w", err)
+		}
+
+		ids := make([]string, 0, len(found))
+		for id := range found {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return ids, true, nil
+	}
+	return nil, false, nil
+}
+
+// writeIndex adds or removes the index entries of an entity
+func (s *Store) writeIndex(tx Tx, entityType, id string, fields []string, data []byte, add bool) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	for _, field := range fields {
+		for _, v := range storage.FieldValues(doc, field) {
+			value, ok := indexValue(v)
+			if !ok {
+				continue
+			}
+			key := []byte(indexPrefix + entityType + sep + field + sep + value + sep + id)
+			var err error
+			if add {
+				err = tx.Put(key, []byte(id))
+			} else {
+				err = tx.Delete(key)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to index entity: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) isIndexed(entityType, field string) bool {
+	for _, f := range s.indexes[entityType] {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRecord decodes a stored record and checks it against the filters
+// and the deleted mode
+func matchRecord(key, value []byte, filters []storage.Filter, deleted storage.DeletedMode) (match, bool, error) {
+	var rec record
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return match{}, false, fmt.Errorf("failed to decode entity: %w", err)
+	}
+	m := match{data: rec.Data}
+	if !deleted.Matches(rec.DeletedAt != nil) {
+		return m, false, nil
+	}
+	if i := strings.LastIndex(string(key), sep); i >= 0 {
+		m.id = string(key[i+1:])
+	}
+	if err := json.Unmarshal(rec.Data, &m.doc); err != nil {
+		return match{}, false, fmt.Errorf("failed to decode entity: %w", err)
+	}
+	for _, f := range filters {
+		if !f.Matches(m.doc) {
+			return m, false, nil
+		}
+	}
+	return m, true, nil
+}
+
+// indexValue renders a JSON scalar as it is stored in index keys
+func indexValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ts.UTC().Format(indexTime), true
+		}
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(t), true
+	}
+	return "", false
+}
+
+// lookupValue renders a filter value as it is stored in index keys
+func lookupValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Sprint(v)
+	}
+	value, _ := indexValue(decoded)
+	return value
+}
+
+func listOf(v interface{}) []interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return []interface{}{v}
+	}
+	var list []interface{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return []interface{}{v}
+	}
+	return list
+}
+
+func isTime(v interface{}) bool {
+	switch t := v.(type) {
+	case time.Time:
+		return true
+	case *time.Time:
+		return t != nil
+	}
+	return false
+}
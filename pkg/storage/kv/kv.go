@@ -0,0 +1,321 @@
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrClosed = errors.New("key-value store closed")
+
+// KV is an embedded, ordered key-value store with serialized writes. It is
+// small enough to wrap BoltDB (one bucket, cursors for Scan) or Badger
+// (prefix iterators); FileKV is a dependency-free implementation.
+type KV interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx Tx) error) error
+
+	// Update runs fn in a read-write transaction, committed when fn
+	// returns nil and discarded otherwise
+	Update(fn func(tx Tx) error) error
+
+	// Close releases the store
+	Close() error
+}
+
+// Tx is a key-value transaction. Reads see the transaction's own writes.
+type Tx interface {
+	// Get returns the value of a key, or nil if it is not set
+	Get(key []byte) ([]byte, error)
+
+	// Put sets the value of a key
+	Put(key, value []byte) error
+
+	// Delete removes a key
+	Delete(key []byte) error
+
+	// Scan calls fn for each key with the prefix, in key order, stopping at
+	// the first error
+	Scan(prefix []byte, fn func(key, value []byte) error) error
+}
+
+// FileKV keeps its data in memory and appends every committed transaction
+// to a log file, which is replayed on open. A transaction cut short by a
+// crash is dropped whole. Compact rewrites the log to the live data.
+type FileKV struct {
+	mu     sync.RWMutex
+	data   map[string][]byte
+	file   *os.File
+	path   string
+	closed bool
+}
+
+// logRecord is one committed transaction in the log. A nil value deletes
+// its key.
+type logRecord struct {
+	Keys   [][]byte `json:"k"`
+	Values [][]byte `json:"v"`
+}
+
+// OpenFileKV opens or creates a log-backed store at path
+func OpenFileKV(path string) (*FileKV, error) {
+	kv := &FileKV{data: make(map[string][]byte), path: path}
+	if err := kv.replay(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	kv.file = file
+	return kv, nil
+}
+
+// NewMemoryKV creates a store without a log, for tests and caches
+func NewMemoryKV() *FileKV {
+	return &FileKV{data: make(map[string][]byte)}
+}
+
+// View implements KV.View
+func (kv *FileKV) View(fn func(tx Tx) error) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+	if kv.closed {
+		return ErrClosed
+	}
+	return fn(&fileTx{kv: kv})
+}
+
+// Update implements KV.Update. Writes are buffered until fn returns, then
+// logged and synced before they become visible.
+func (kv *FileKV) Update(fn func(tx Tx) error) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.closed {
+		return ErrClosed
+	}
+
+	tx := &fileTx{kv: kv, writes: make(map[string][]byte), writable: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	if kv.file != nil {
+		record := logRecord{}
+		for _, k := range tx.order {
+			record.Keys = append(record.Keys, []byte(k))
+			record.Values = append(record.Values, tx.writes[k])
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction: %w", err)
+		}
+		if _, err := kv.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write log: %w", err)
+		}
+		if err := kv.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log: %w", err)
+		}
+	}
+	for k, v := range tx.writes {
+		if v == nil {
+			delete(kv.data, k)
+		} else {
+			kv.data[k] = v
+		}
+	}
+	return nil
+}
+
+// Compact rewrites the log so it holds only the live data
+func (kv *FileKV) Compact() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.closed {
+		return ErrClosed
+	}
+	if kv.file == nil {
+		return nil
+	}
+
+	tmp := kv.path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	record := logRecord{}
+	for _, k := range sortedKeys(kv.data, "") {
+		record.Keys = append(record.Keys, []byte(k))
+		record.Values = append(record.Values, kv.data[k])
+	}
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write compacted log: %w", err)
+	}
+
+	if err := os.Rename(tmp, kv.path); err != nil {
+		return fmt.Errorf("failed to replace log: %w", err)
+	}
+	kv.file.Close()
+	kv.file, err = os.OpenFile(kv.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		kv.closed = true
+		return fmt.Errorf("failed to reopen %s: %w", kv.path, err)
+	}
+	return nil
+}
+
+// Close implements KV.Close
+func (kv *FileKV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.closed {
+		return nil
+	}
+	kv.closed = true
+	if kv.file != nil {
+		return kv.file.Close()
+	}
+	return nil
+}
+
+// replay loads the log. A final line without a newline is a transaction
+// interrupted mid-write and is ignored.
+func (kv *FileKV) replay() error {
+	content, err := os.ReadFile(kv.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", kv.path, err)
+	}
+
+	complete := content
+	if i := bytes.LastIndexByte(content, '\n'); i < len(content)-1 {
+		complete = content[:i+1]
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(complete))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(complete)+1)
+	for line := 1; scanner.Scan(); line++ {
+		var record logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("corrupt log %s at line %d: %w", kv.path, line, err)
+		}
+		for i, k := range record.Keys {
+			if i < len(record.Values) && record.Values[i] != nil {
+				kv.data[string(k)] = record.Values[i]
+			} else {
+				delete(kv.data, string(k))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", kv.path, err)
+	}
+	if len(complete) < len(content) {
+		// Drop the torn write so later appends start on a fresh line
+		if err := os.Truncate(kv.path, int64(len(complete))); err != nil {
+			return fmt.Errorf("failed to repair %s: %w", kv.path, err)
+		}
+	}
+	return nil
+}
+
+// fileTx is a FileKV transaction. Writes are kept in order so the log
+// replays them as made.
+type fileTx struct {
+	kv       *FileKV
+	writes   map[string][]byte
+	order    []string
+	writable bool
+}
+
+func (tx *fileTx) Get(key []byte) ([]byte, error) {
+	if v, ok := tx.writes[string(key)]; ok {
+		return v, nil
+	}
+	return tx.kv.data[string(key)], nil
+}
+
+func (tx *fileTx) Put(key, value []byte) error {
+	if !tx.writable {
+		return fmt.Errorf("transaction is read-only")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	tx.set(string(key), append([]byte(nil), value...))
+	return nil
+}
+
+func (tx *fileTx) Delete(key []byte) error {
+	if !tx.writable {
+		return fmt.Errorf("transaction is read-only")
+	}
+	tx.set(string(key), nil)
+	return nil
+}
+
+func (tx *fileTx) set(key string, value []byte) {
+	if _, ok := tx.writes[key]; !ok {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = value
+}
+
+func (tx *fileTx) Scan(prefix []byte, fn func(key, value []byte) error) error {
+	p := string(prefix)
+	keys := sortedKeys(tx.kv.data, p)
+	if len(tx.writes) > 0 {
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			seen[k] = true
+		}
+		for k, v := range tx.writes {
+			if v != nil && !seen[k] && strings.HasPrefix(k, p) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+	}
+	for _, k := range keys {
+		v, _ := tx.Get([]byte(k))
+		if v == nil {
+			continue
+		}
+		if err := fn([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(data map[string][]byte, prefix string) []string {
+	keys := make([]string, 0)
+	for k := range data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,674 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var (
+	ErrNotFound        = storage.ErrNotFound
+	ErrBatchRolledBack = errors.New("batch rolled back")
+)
+
+// Key layout. Parts are separated by sep, which IDs and indexed values must
+// not contain.
+const (
+	sep = "\x00"
+	// d/type/id -> record
+	docPrefix = "d" + sep
+	// k/id/type -> type, for lookups by ID alone
+	keyPrefix = "k" + sep
+	// i/type/field/value/id -> id
+	indexPrefix = "i" + sep
+	// a/sequence -> audit entry
+	auditPrefix = "a" + sep
+	// h -> last audit entry
+	auditHead = "h"
+)
+
+// record is a stored entity with its version information
+type record struct {
+	Version    int64           `json:"version"`
+	ModifiedAt time.Time       `json:"modified_at"`
+	ModifiedBy string          `json:"modified_by"`
+	DeletedAt  *time.Time      `json:"deleted_at,omitempty"`
+	DeletedBy  string          `json:"deleted_by,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Index is a secondary index on a field of an entity type
+type Index struct {
+	// Entity type as formatted by %T, e.g. "*account.Account"
+	Type string
+	// Dotted JSON field; paths through arrays index every element
+	Field string
+}
+
+// DefaultIndexes covers the lookups of the report calculator: accounts by
+// type and transactions by entry account, date and effective date
+func DefaultIndexes() []Index {
+	accounts := entityType(&account.Account{})
+	transactions := entityType(&transaction.Transaction{})
+	return []Index{
+		{Type: accounts, Field: "type"},
+		{Type: transactions, Field: "entries.account_id"},
+		{Type: transactions, Field: "date"},
+		{Type: transactions, Field: "effective_date"},
+	}
+}
+
+// Store implements the storage interfaces over an embedded key-value
+// store. Entities are kept as JSON with secondary indexes maintained in the
+// same transaction, so a small deployment gets durable, indexed storage
+// without a database server. Queries use an index when a filter on an
+// indexed field allows it and scan the entity type otherwise; every filter
+// is then checked against the entity's JSON form.
+type Store struct {
+	db      KV
+	indexes map[string][]string
+	signer  audit.Signer
+}
+
+type txKey struct{}
+
+// NewStore creates a store with the default indexes
+func NewStore(db KV) *Store {
+	s := &Store{db: db, indexes: make(map[string][]string)}
+	for _, idx := range DefaultIndexes() {
+		s.indexes[idx.Type] = append(s.indexes[idx.Type], idx.Field)
+	}
+	return s
+}
+
+// SetSigner makes the store sign the hash of every new audit entry, so the
+// chain can be checked with audit.VerifySignatures
+func (s *Store) SetSigner(signer audit.Signer) {
+	s.signer = signer
+}
+
+// AddIndex adds a secondary index and builds it for the entities already
+// stored. Add indexes while setting the store up, before it is shared.
+func (s *Store) AddIndex(ctx context.Context, idx Index) error {
+	if s.isIndexed(idx.Type, idx.Field) {
+		return nil
+	}
+	err := s.update(ctx, func(tx Tx) error {
+		return tx.Scan([]byte(docPrefix+idx.Type+sep), func(key, value []byte) error {
+			var rec record
+			if err := json.Unmarshal(value, &rec); err != nil {
+				return fmt.Errorf("failed to decode entity: %w", err)
+			}
+			id := strings.TrimPrefix(string(key), docPrefix+idx.Type+sep)
+			return s.writeIndex(tx, idx.Type, id, []string{idx.Field}, rec.Data, true)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.indexes[idx.Type] = append(s.indexes[idx.Type], idx.Field)
+	return nil
+}
+
+// WithTransaction runs fn in one key-value transaction: store calls made
+// with the context fn receives commit or roll back together
+func (s *Store) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(Tx); ok {
+		return fn(ctx)
+	}
+	return s.db.Update(func(tx Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Create implements Repository.Create
+func (s *Store) Create(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	if id == "" {
+		return fmt.Errorf("entity ID cannot be empty")
+	}
+	storage.StampActor(ctx, entity, true)
+	restore := storage.SetVersion(entity, 1)
+	data, err := json.Marshal(entity)
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to encode entity: %w", err)
+	}
+
+	entityType := entityType(entity)
+	err = s.update(ctx, func(tx Tx) error {
+		existing, err := tx.Get(docKey(entityType, id))
+		if err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+		if existing != nil {
+			return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
+		}
+		if err := s.writeRecord(ctx, tx, entityType, id, record{Version: 1, Data: data}); err != nil {
+			return err
+		}
+		if err := tx.Put([]byte(keyPrefix+id+sep+entityType), []byte(entityType)); err != nil {
+			return fmt.Errorf("failed to store entity: %w", err)
+		}
+		if err := s.writeIndex(tx, entityType, id, s.indexes[entityType], data, true); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, tx, entityType, id, "CREATE", nil, data)
+	})
+	if err != nil {
+		restore()
+	}
+	return err
+}
+
+// Read implements Repository.Read
+func (s *Store) Read(ctx context.Context, id string, entity interface{}) error {
+	return s.view(ctx, func(tx Tx) error {
+		rec, err := readRecord(tx, entityType(entity), id)
+		if err != nil {
+			return err
+		}
+		if rec.DeletedAt != nil {
+			return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+		}
+		if err := json.Unmarshal(rec.Data, entity); err != nil {
+			return fmt.Errorf("failed to decode entity: %w", err)
+		}
+		return nil
+	})
+}
+
+// Update implements Repository.Update. Entities with a GetVersion method
+// are only updated at the version they were read at.
+func (s *Store) Update(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	entityType := entityType(entity)
+	restore := func() {}
+	err := s.update(ctx, func(tx Tx) error {
+		rec, err := readRecord(tx, entityType, id)
+		if err != nil {
+			return err
+		}
+		if rec.DeletedAt != nil {
+			return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+		}
+		if err := storage.CheckVersion(entity, entityType, id, rec.Version); err != nil {
+			return err
+		}
+		storage.StampActor(ctx, entity, false)
+		restore = storage.SetVersion(entity, rec.Version+1)
+		data, err := json.Marshal(entity)
+		if err != nil {
+			return fmt.Errorf("failed to encode entity: %w", err)
+		}
+
+		fields := s.indexes[entityType]
+		if err := s.writeIndex(tx, entityType, id, fields, rec.Data, false); err != nil {
+			return err
+		}
+		if err := s.writeRecord(ctx, tx, entityType, id, record{Version: rec.Version + 1, Data: data}); err != nil {
+			return err
+		}
+		if err := s.writeIndex(tx, entityType, id, fields, data, true); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, tx, entityType, id, "UPDATE", rec.Data, data)
+	})
+	if err != nil {
+		restore()
+	}
+	return err
+}
+
+// Delete implements Repository.Delete. Like the memory store it removes the
+// first entity with the ID, whatever its type.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, func(tx Tx) error {
+		entityType, err := typeOf(tx, id)
+		if err != nil {
+			return err
+		}
+		rec, err := readRecord(tx, entityType, id)
+		if err != nil {
+			return err
+		}
+		if err := s.writeIndex(tx, entityType, id, s.indexes[entityType], rec.Data, false); err != nil {
+			return err
+		}
+		if err := tx.Delete(docKey(entityType, id)); err != nil {
+			return fmt.Errorf("failed to delete entity: %w", err)
+		}
+		if err := tx.Delete([]byte(keyPrefix + id + sep + entityType)); err != nil {
+			return fmt.Errorf("failed to delete entity: %w", err)
+		}
+		return s.recordAudit(ctx, tx, entityType, id, "DELETE", rec.Data, nil)
+	})
+}
+
+// SoftDelete implements SoftDeleteRepository.SoftDelete. The entity and
+// its index entries are kept, and it keeps its version.
+func (s *Store) SoftDelete(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, true)
+}
+
+// Restore implements SoftDeleteRepository.Restore
+func (s *Store) Restore(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, id string, deleted bool) error {
+	return s.update(ctx, func(tx Tx) error {
+		entityType, err := typeOf(tx, id)
+		if err != nil {
+			return err
+		}
+		rec, err := readRecord(tx, entityType, id)
+		if err != nil {
+			return err
+		}
+		if deleted && rec.DeletedAt != nil {
+			return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+		}
+		if !deleted && rec.DeletedAt == nil {
+			return fmt.Errorf("entity is not deleted: %s", id)
+		}
+
+		next := record{Version: rec.Version, Data: rec.Data}
+		operation := "RESTORE"
+		oldState, newState := []byte(nil), []byte(rec.Data)
+		if deleted {
+			now := time.Now().UTC()
+			next.DeletedAt = &now
+			next.DeletedBy = audit.CallerFromContext(ctx).UserID
+			operation = "SOFT_DELETE"
+			oldState, newState = rec.Data, nil
+		}
+		if err := s.writeRecord(ctx, tx, entityType, id, next); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, tx, entityType, id, operation, oldState, newState)
+	})
+}
+
+// Query implements Repository.Query. Results must be a pointer to a slice
+// of entities or entity pointers.
+func (s *Store) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	entityType := entityType(reflect.New(structType).Interface())
+
+	var matches []match
+	err := s.view(ctx, func(tx Tx) error {
+		var err error
+		matches, err = s.find(tx, entityType, query.Filters, query.Deleted)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sortMatches(matches, query.Sort)
+	matches = paginate(matches, query.Pagination)
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, len(matches))
+	for _, m := range matches {
+		item := reflect.New(structType)
+		if err := json.Unmarshal(m.data, item.Interface()); err != nil {
+			return fmt.Errorf("failed to decode entity: %w", err)
+		}
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	slice.Elem().Set(list)
+	return nil
+}
+
+// Count implements Repository.Count. An "entity_type" filter, holding a
+// sample entity such as &account.Account{}, restricts the count to one
+// type; without it entities of every type are counted.
+func (s *Store) Count(ctx context.Context, query storage.Query) (int64, error) {
+	entityType := ""
+	filters := make([]storage.Filter, 0, len(query.Filters))
+	for _, f := range query.Filters {
+		if f.Field == "entity_type" {
+			entityType = typeName(f.Value)
+			continue
+		}
+		filters = append(filters, f)
+	}
+
+	var count int64
+	err := s.view(ctx, func(tx Tx) error {
+		if entityType != "" {
+			matches, err := s.find(tx, entityType, filters, query.Deleted)
+			count = int64(len(matches))
+			return err
+		}
+		return tx.Scan([]byte(docPrefix), func(key, value []byte) error {
+			_, ok, err := matchRecord(key, value, filters, query.Deleted)
+			if ok {
+				count++
+			}
+			return err
+		})
+	})
+	return count, err
+}
+
+// BatchExecute implements BatchRepository.BatchExecute. The batch runs in
+// one key-value transaction: if any item fails, nothing is written, the
+// failing item reports its error and every other item ErrBatchRolledBack.
+func (s *Store) BatchExecute(ctx context.Context, items []storage.BatchItem) []storage.BatchResult {
+	results := make([]storage.BatchResult, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+		if item.ID == "" && item.Entity != nil {
+			results[i].ID = entityID(item.Entity)
+		}
+	}
+
+	failed := -1
+	restore := storage.KeepVersions(items)
+	err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		for i, item := range items {
+			var err error
+			switch item.Operation {
+			case storage.BatchCreate:
+				err = s.Create(ctx, item.Entity)
+			case storage.BatchUpdate:
+				err = s.Update(ctx, item.Entity)
+			case storage.BatchDelete:
+				err = s.Delete(ctx, item.ID)
+			default:
+				err = fmt.Errorf("unknown batch operation %q", item.Operation)
+			}
+			if err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		restore()
+	}
+	for i := range results {
+		switch {
+		case err == nil:
+			results[i].Success = true
+		case i == failed:
+			results[i].Error = err
+		case failed < 0:
+			// The commit itself failed
+			results[i].Error = err
+		default:
+			results[i].Error = ErrBatchRolledBack
+		}
+	}
+	return results
+}
+
+// GetVersionInfo implements AuditableRepository.GetVersionInfo
+func (s *Store) GetVersionInfo(ctx context.Context, entityID string) (*storage.VersionInfo, error) {
+	var info *storage.VersionInfo
+	err := s.view(ctx, func(tx Tx) error {
+		entityType, err := typeOf(tx, entityID)
+		if err != nil {
+			return err
+		}
+		rec, err := readRecord(tx, entityType, entityID)
+		if err != nil {
+			return err
+		}
+		info = &storage.VersionInfo{
+			Version:    rec.Version,
+			ModifiedAt: rec.ModifiedAt,
+			ModifiedBy: rec.ModifiedBy,
+			DeletedAt:  rec.DeletedAt,
+			DeletedBy:  rec.DeletedBy,
+		}
+		return nil
+	})
+	return info, err
+}
+
+// GetAuditTrail implements AuditableRepository.GetAuditTrail
+func (s *Store) GetAuditTrail(ctx context.Context, entityID string) ([]storage.AuditEntry, error) {
+	return s.QueryAudit(ctx, storage.AuditQuery{EntityID: entityID})
+}
+
+// QueryAuditTrail implements AuditableRepository.QueryAuditTrail
+func (s *Store) QueryAuditTrail(ctx context.Context, filter storage.AuditFilter) (*storage.AuditPage, error) {
+	entries, err := s.QueryAudit(ctx, filter.AuditQuery)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PageAudit(entries, filter.Pagination), nil
+}
+
+// QueryAudit implements AuditLogRepository.QueryAudit
+func (s *Store) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	entries := make([]storage.AuditEntry, 0)
+	err := s.view(ctx, func(tx Tx) error {
+		return tx.Scan([]byte(auditPrefix), func(key, value []byte) error {
+			var entry storage.AuditEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return fmt.Errorf("failed to decode audit entry: %w", err)
+			}
+			if query.Matches(entry) {
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// recordAudit appends an entry to the audit chain in the current
+// transaction. Writes are serialized, so the chain stays linear.
+func (s *Store) recordAudit(ctx context.Context, tx Tx, entityType, entityID, operation string, oldState, newState []byte) error {
+	var previous *storage.AuditEntry
+	head, err := tx.Get([]byte(auditHead))
+	if err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	if head != nil {
+		previous = &storage.AuditEntry{}
+		if err := json.Unmarshal(head, previous); err != nil {
+			return fmt.Errorf("failed to decode audit entry: %w", err)
+		}
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	now := time.Now().UTC()
+	entry := storage.AuditEntry{
+		ID:         fmt.Sprintf("audit_%d", now.UnixNano()),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Operation:  operation,
+		UserID:     caller.UserID,
+		Timestamp:  now,
+		Metadata:   caller.Metadata(),
+	}
+	if oldState != nil {
+		entry.PreviousState = json.RawMessage(oldState)
+	}
+	if newState != nil {
+		entry.NewState = json.RawMessage(newState)
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
+		}
+		entry.Metadata[tenant.MetadataKey] = id
+	}
+	if err := audit.SealSigned(ctx, &entry, previous, s.signer); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	if err := tx.Put([]byte(fmt.Sprintf("%s%020d", auditPrefix, entry.Sequence)), data); err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	if err := tx.Put([]byte(auditHead), data); err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	return nil
+}
+
+// writeRecord stores a record, stamped with the time and author of the change
+func (s *Store) writeRecord(ctx context.Context, tx Tx, entityType, id string, rec record) error {
+	rec.ModifiedAt = time.Now().UTC()
+	rec.ModifiedBy = audit.CallerFromContext(ctx).UserID
+	encoded, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode entity: %w", err)
+	}
+	if err := tx.Put(docKey(entityType, id), encoded); err != nil {
+		return fmt.Errorf("failed to store entity: %w", err)
+	}
+	return nil
+}
+
+func readRecord(tx Tx, entityType, id string) (*record, error) {
+	value, err := tx.Get(docKey(entityType, id))
+	if err != nil {
+		return nil, fmt.Errorf("error reading entity: %w", err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	var rec record
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode entity: %w", err)
+	}
+	return &rec, nil
+}
+
+// typeOf returns the first entity type, in name order, stored with an ID
+func typeOf(tx Tx, id string) (string, error) {
+	entityType := ""
+	stop := errors.New("stop")
+	err := tx.Scan([]byte(keyPrefix+id+sep), func(key, value []byte) error {
+		entityType = string(value)
+		return stop
+	})
+	if err != nil && err != stop {
+		return "", fmt.Errorf("error reading entity: %w", err)
+	}
+	if entityType == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return entityType, nil
+}
+
+// update runs fn in the context's transaction or a new one
+func (s *Store) update(ctx context.Context, fn func(tx Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(Tx); ok {
+		return fn(tx)
+	}
+	return s.db.Update(fn)
+}
+
+// view runs fn in the context's transaction or a new read-only one
+func (s *Store) view(ctx context.Context, fn func(tx Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(Tx); ok {
+		return fn(tx)
+	}
+	return s.db.View(fn)
+}
+
+func docKey(entityType, id string) []byte {
+	return []byte(docPrefix + entityType + sep + id)
+}
+
+func entityType(entity interface{}) string {
+	return fmt.Sprintf("%T", entity)
+}
+
+// typeName returns the entity type of a value passed in a filter, which
+// may be a sample entity or the type name itself
+func typeName(v interface{}) string {
+	if name, ok := v.(string); ok {
+		return name
+	}
+	return entityType(v)
+}
+
+func entityID(entity interface{}) string {
+	if e, ok := entity.(interface{ GetID() string }); ok {
+		return e.GetID()
+	}
+	return ""
+}
+
+// sortMatches orders query results by the sort fields, then by ID
+func sortMatches(matches []match, sorts []storage.Sort) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, s := range sorts {
+			c := compareField(matches[i], matches[j], s.Field)
+			if c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		return matches[i].id < matches[j].id
+	})
+}
+
+func compareField(a, b match, field string) int {
+	if field == "id" {
+		return strings.Compare(a.id, b.id)
+	}
+	va, vb := storage.FieldValues(a.doc, field), storage.FieldValues(b.doc, field)
+	switch {
+	case len(va) == 0 && len(vb) == 0:
+		return 0
+	case len(va) == 0:
+		return -1
+	case len(vb) == 0:
+		return 1
+	}
+	return storage.CompareValues(va[0], vb[0])
+}
+
+func paginate(matches []match, p *storage.Pagination) []match {
+	if p == nil {
+		return matches
+	}
+	if p.Offset > 0 {
+		if p.Offset >= int64(len(matches)) {
+			return matches[:0]
+		}
+		matches = matches[p.Offset:]
+	}
+	if p.Limit > 0 && p.Limit < int64(len(matches)) {
+		matches = matches[:p.Limit]
+	}
+	return matches
+}
@@ -0,0 +1,220 @@
+package kv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time checks of the interfaces the store implements
+var (
+	_ storage.AuditableRepository  = (*Store)(nil)
+	_ storage.AuditLogRepository   = (*Store)(nil)
+	_ storage.BatchRepository      = (*Store)(nil)
+	_ storage.SoftDeleteRepository = (*Store)(nil)
+)
+
+func testTransaction(id string, date time.Time, status transaction.TransactionStatus, accountID string) *transaction.Transaction {
+	amount := money.Money{Amount: decimal.NewFromInt(100), Currency: "USD"}
+	return &transaction.Transaction{
+		ID:     id,
+		Status: status,
+		Date:   date,
+		Entries: []transaction.Entry{
+			{AccountID: accountID, Amount: amount, Type: transaction.Debit},
+			{AccountID: "revenue", Amount: amount, Type: transaction.Credit},
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice"})
+	path := filepath.Join(t.TempDir(), "ledger.log")
+	db, err := OpenFileKV(path)
+	require.NoError(t, err)
+	store := NewStore(db)
+
+	jan := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 15, 0, 0, 0, 0, time.FixedZone("EST", -5*3600))
+
+	t.Run("CRUD", func(t *testing.T) {
+		cash := &account.Account{ID: "cash", Code: "1000", Name: "Cash", Type: account.Asset}
+		require.NoError(t, store.Create(ctx, cash))
+		require.NoError(t, store.Create(ctx, &account.Account{ID: "loan", Code: "2000", Name: "Loan", Type: account.Liability}))
+		assert.Error(t, store.Create(ctx, cash))
+
+		var read account.Account
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		assert.Equal(t, "Cash", read.Name)
+		assert.ErrorIs(t, store.Read(ctx, "missing", &read), ErrNotFound)
+
+		read.Type = account.Liability
+		require.NoError(t, store.Update(ctx, &read))
+		info, err := store.GetVersionInfo(ctx, "cash")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), info.Version)
+		assert.Equal(t, "alice", info.ModifiedBy)
+
+		// The type index follows the update
+		var assets, liabilities []*account.Account
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "type", Operator: "=", Value: account.Asset}}}, &assets))
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "type", Operator: "=", Value: account.Liability}}}, &liabilities))
+		assert.Empty(t, assets)
+		assert.Len(t, liabilities, 2)
+
+		require.NoError(t, store.Delete(ctx, "loan"))
+		assert.ErrorIs(t, store.Delete(ctx, "loan"), ErrNotFound)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		require.NoError(t, store.Create(ctx, testTransaction("TX1", jan, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX2", feb, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX3", feb, transaction.Draft, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX4", feb, transaction.Posted, "bank")))
+
+		var found []*transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Filters: []storage.Filter{
+				{Field: "date", Operator: ">=", Value: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+				{Field: "entries.account_id", Operator: "=", Value: "cash"},
+				{Field: "status", Operator: "=", Value: transaction.Posted},
+			},
+		}, &found))
+		require.Len(t, found, 1)
+		assert.Equal(t, "TX2", found[0].ID)
+		assert.True(t, found[0].Date.Equal(feb))
+
+		var page []transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Sort:       []storage.Sort{{Field: "date", Desc: true}},
+			Pagination: &storage.Pagination{Offset: 1, Limit: 2},
+		}, &page))
+		require.Len(t, page, 2)
+		assert.Equal(t, "TX3", page[0].ID)
+		assert.Equal(t, "TX4", page[1].ID)
+
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{
+			{Field: "entity_type", Value: &transaction.Transaction{}},
+			{Field: "entries.account_id", Operator: "in", Value: []string{"bank", "cash"}},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+
+		require.NoError(t, store.AddIndex(ctx, Index{Type: entityType(&transaction.Transaction{}), Field: "status"}))
+		var drafts []*transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "status", Operator: "=", Value: transaction.Draft}}}, &drafts))
+		require.Len(t, drafts, 1)
+		assert.Equal(t, "TX3", drafts[0].ID)
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		require.NoError(t, store.SoftDelete(ctx, "TX3"))
+		assert.ErrorIs(t, store.Read(ctx, "TX3", &transaction.Transaction{}), storage.ErrDeleted)
+
+		byAccount := []storage.Filter{{Field: "entries.account_id", Operator: "=", Value: "cash"}}
+		for mode, want := range map[storage.DeletedMode]int{
+			storage.ExcludeDeleted: 2,
+			storage.IncludeDeleted: 3,
+			storage.OnlyDeleted:    1,
+		} {
+			var found []*transaction.Transaction
+			require.NoError(t, store.Query(ctx, storage.Query{Filters: byAccount, Deleted: mode}, &found))
+			assert.Len(t, found, want, "mode %q", mode)
+		}
+		info, err := store.GetVersionInfo(ctx, "TX3")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", info.DeletedBy)
+
+		require.NoError(t, store.Restore(ctx, "TX3"))
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{{Field: "entity_type", Value: &transaction.Transaction{}}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		results := store.BatchExecute(ctx, []storage.BatchItem{
+			{Operation: storage.BatchCreate, Entity: testTransaction("TX5", feb, transaction.Posted, "cash")},
+			{Operation: storage.BatchDelete, ID: "missing"},
+		})
+		assert.False(t, results[0].Success)
+		assert.ErrorIs(t, results[0].Error, ErrBatchRolledBack)
+		assert.ErrorIs(t, results[1].Error, ErrNotFound)
+		assert.ErrorIs(t, store.Read(ctx, "TX5", &transaction.Transaction{}), ErrNotFound)
+
+		results = store.BatchExecute(ctx, []storage.BatchItem{
+			{Operation: storage.BatchCreate, Entity: testTransaction("TX5", feb, transaction.Posted, "cash")},
+			{Operation: storage.BatchDelete, ID: "TX4"},
+		})
+		assert.True(t, results[0].Success)
+		assert.True(t, results[1].Success)
+	})
+
+	t.Run("Durability", func(t *testing.T) {
+		entries, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+		require.NoError(t, audit.VerifyChain(entries))
+		require.NoError(t, db.Close())
+
+		// A write torn by a crash is dropped on reopen
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"k":["ZA==`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		db, err = OpenFileKV(path)
+		require.NoError(t, err)
+		store = NewStore(db)
+		var found []*transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "entries.account_id", Operator: "=", Value: "cash"}}}, &found))
+		assert.Len(t, found, 4)
+
+		require.NoError(t, db.Compact())
+		require.NoError(t, store.Create(ctx, testTransaction("TX6", jan, transaction.Posted, "bank")))
+		require.NoError(t, db.Close())
+
+		db, err = OpenFileKV(path)
+		require.NoError(t, err)
+		defer db.Close()
+		store = NewStore(db)
+		reopened, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+		assert.Len(t, reopened, len(entries)+1)
+		assert.NoError(t, audit.VerifyChain(reopened))
+		assert.NoError(t, store.Read(ctx, "TX6", &transaction.Transaction{}))
+	})
+}
+
+func TestFileKVRollback(t *testing.T) {
+	db := NewMemoryKV()
+	require.NoError(t, db.Update(func(tx Tx) error { return tx.Put([]byte("a"), []byte("1")) }))
+	err := db.Update(func(tx Tx) error {
+		require.NoError(t, tx.Put([]byte("b"), []byte("2")))
+		require.NoError(t, tx.Delete([]byte("a")))
+		v, _ := tx.Get([]byte("b"))
+		assert.Equal(t, []byte("2"), v)
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	assert.Error(t, db.View(func(tx Tx) error {
+		keys := make([]string, 0)
+		require.NoError(t, tx.Scan(nil, func(key, value []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		}))
+		assert.Equal(t, []string{"a"}, keys)
+		return tx.Put([]byte("c"), []byte("3"))
+	}), "views are read-only")
+}
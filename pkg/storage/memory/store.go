@@ -3,29 +3,39 @@ package memory
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 	
+	"github.com/johnayoung/finlib/pkg/audit"
 	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
 )
 
 // MemoryStore provides an in-memory implementation of the storage interfaces
 type MemoryStore struct {
 	sync.RWMutex
 	data    map[string]map[string]interface{}
-	audit   map[string][]storage.AuditEntry
-	version map[string]int64
+	audit   []storage.AuditEntry
+	version map[string]storage.VersionInfo
+	signer  audit.Signer
 }
 
 // NewMemoryStore creates a new memory store instance
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		data:    make(map[string]map[string]interface{}),
-		audit:   make(map[string][]storage.AuditEntry),
-		version: make(map[string]int64),
+		audit:   make([]storage.AuditEntry, 0),
+		version: make(map[string]storage.VersionInfo),
 	}
 }
 
+// SetSigner makes the store sign the hash of every new audit entry, so the
+// chain can be checked with audit.VerifySignatures
+func (s *MemoryStore) SetSigner(signer audit.Signer) {
+	s.signer = signer
+}
+
 // Create implements Repository.Create
 func (s *MemoryStore) Create(ctx context.Context, entity interface{}) error {
 	s.Lock()
@@ -43,17 +53,21 @@ func (s *MemoryStore) Create(ctx context.Context, entity interface{}) error {
 	}
 
 	if _, exists := s.data[entityType][id]; exists {
-		return fmt.Errorf("entity already exists: %s", id)
+		return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
 	}
 
-	s.data[entityType][id] = entity
-	s.version[id] = 1
-	s.recordAudit(entityType, id, "CREATE", nil, entity)
+	storage.StampActor(ctx, entity, true)
+	storage.SetVersion(entity, 1)
+	stored := cloneEntity(entity)
+	s.data[entityType][id] = stored
+	s.setVersion(ctx, id, 1)
+	s.recordAudit(ctx, entityType, id, "CREATE", nil, stored)
 
 	return nil
 }
 
-// Read implements Repository.Read
+// Read implements Repository.Read. The entity receives a deep copy of the
+// stored one, so changing it never changes the store.
 func (s *MemoryStore) Read(ctx context.Context, id string, entity interface{}) error {
 	s.RLock()
 	defer s.RUnlock()
@@ -61,12 +75,15 @@ func (s *MemoryStore) Read(ctx context.Context, id string, entity interface{}) e
 	entityType := getEntityType(entity)
 	
 	if s.data[entityType] == nil {
-		return fmt.Errorf("entity type not found: %s", entityType)
+		return fmt.Errorf("%w: no %s entities", storage.ErrNotFound, entityType)
 	}
 
 	stored, exists := s.data[entityType][id]
 	if !exists {
-		return fmt.Errorf("entity not found: %s", id)
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, id)
+	}
+	if s.version[id].DeletedAt != nil {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
 	}
 
 	copyEntity(stored, entity)
@@ -82,31 +99,30 @@ func (s *MemoryStore) Update(ctx context.Context, entity interface{}) error {
 	id := getEntityID(entity)
 
 	if s.data[entityType] == nil {
-		return fmt.Errorf("entity type not found: %s", entityType)
+		return fmt.Errorf("%w: no %s entities", storage.ErrNotFound, entityType)
 	}
 
 	old, exists := s.data[entityType][id]
 	if !exists {
-		return fmt.Errorf("entity not found: %s", id)
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, id)
+	}
+	if s.version[id].DeletedAt != nil {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
 	}
 
 	// Handle optimistic locking
-	if versioned, ok := entity.(interface{ GetVersion() int64 }); ok {
-		currentVersion := s.version[id]
-		if versioned.GetVersion() != currentVersion {
-			return &storage.OptimisticLockError{
-				EntityType:       entityType,
-				EntityID:        id,
-				CurrentVersion:  currentVersion,
-				ExpectedVersion: versioned.GetVersion(),
-			}
-		}
+	current := s.version[id].Version
+	if err := storage.CheckVersion(entity, entityType, id, current); err != nil {
+		return err
 	}
 
 	// Update version after successful validation
-	s.version[id]++
-	s.data[entityType][id] = entity
-	s.recordAudit(entityType, id, "UPDATE", old, entity)
+	storage.StampActor(ctx, entity, false)
+	storage.SetVersion(entity, current+1)
+	stored := cloneEntity(entity)
+	s.setVersion(ctx, id, current+1)
+	s.data[entityType][id] = stored
+	s.recordAudit(ctx, entityType, id, "UPDATE", old, stored)
 
 	return nil
 }
@@ -119,32 +135,229 @@ func (s *MemoryStore) Delete(ctx context.Context, id string) error {
 	for entityType, entities := range s.data {
 		if stored, exists := entities[id]; exists {
 			delete(entities, id)
-			s.recordAudit(entityType, id, "DELETE", stored, nil)
+			delete(s.version, id)
+			s.recordAudit(ctx, entityType, id, "DELETE", stored, nil)
 			return nil
 		}
 	}
 
-	return fmt.Errorf("entity not found: %s", id)
+	return fmt.Errorf("%w: %s", storage.ErrNotFound, id)
+}
+
+// SoftDelete implements SoftDeleteRepository.SoftDelete. The entity keeps
+// its version, so it can be updated from that version once restored.
+func (s *MemoryStore) SoftDelete(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entityType, stored, exists := s.find(id)
+	if !exists {
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, id)
+	}
+	info := s.version[id]
+	if info.DeletedAt != nil {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+	}
+
+	s.setVersion(ctx, id, info.Version)
+	info = s.version[id]
+	info.DeletedAt = &info.ModifiedAt
+	info.DeletedBy = info.ModifiedBy
+	s.version[id] = info
+	s.recordAudit(ctx, entityType, id, "SOFT_DELETE", stored, nil)
+
+	return nil
+}
+
+// Restore implements SoftDeleteRepository.Restore
+func (s *MemoryStore) Restore(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entityType, stored, exists := s.find(id)
+	if !exists {
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, id)
+	}
+	info := s.version[id]
+	if info.DeletedAt == nil {
+		return fmt.Errorf("entity is not deleted: %s", id)
+	}
+
+	s.setVersion(ctx, id, info.Version)
+	s.recordAudit(ctx, entityType, id, "RESTORE", nil, stored)
+
+	return nil
 }
 
-// Query implements Repository.Query
+// Query implements Repository.Query. Results must be a pointer to a slice
+// of entities or entity pointers; each result is a copy of the stored
+// entity.
 func (s *MemoryStore) Query(ctx context.Context, query storage.Query, results interface{}) error {
 	s.RLock()
 	defer s.RUnlock()
 
-	// Implementation would filter and sort based on query parameters
-	// For simplicity, this is a basic implementation
-	return fmt.Errorf("not implemented")
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	sample := reflect.New(structType).Interface()
+
+	hits, err := s.match(getEntityType(sample), query)
+	if err != nil {
+		return err
+	}
+	storage.RankHits(hits, query.Sort)
+	hits = storage.PageHits(hits, query.Pagination)
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, len(hits))
+	for _, hit := range hits {
+		item := reflect.New(structType)
+		copyEntity(s.data[getEntityType(sample)][hit.ID], item.Interface())
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	slice.Elem().Set(list)
+	return nil
 }
 
-// Count implements Repository.Count
+// Count implements Repository.Count. An "entity_type" filter, holding a
+// sample entity such as &account.Account{}, restricts the count to one
+// type; without it entities of every type are counted.
 func (s *MemoryStore) Count(ctx context.Context, query storage.Query) (int64, error) {
 	s.RLock()
 	defer s.RUnlock()
 
-	// Implementation would count based on query parameters
-	// For simplicity, this is a basic implementation
-	return 0, fmt.Errorf("not implemented")
+	var count int64
+	for entityType := range s.data {
+		for _, f := range query.Filters {
+			if f.Field == "entity_type" && getEntityType(f.Value) != entityType {
+				entityType = ""
+			}
+		}
+		if entityType == "" {
+			continue
+		}
+		hits, err := s.match(entityType, query)
+		if err != nil {
+			return 0, err
+		}
+		count += int64(len(hits))
+	}
+	return count, nil
+}
+
+// match returns the entities of a type satisfying a query's filters and
+// deleted mode, unsorted
+func (s *MemoryStore) match(entityType string, query storage.Query) ([]storage.SearchHit, error) {
+	hits := make([]storage.SearchHit, 0)
+	for id, stored := range s.data[entityType] {
+		if !query.Deleted.Matches(s.version[id].DeletedAt != nil) {
+			continue
+		}
+		doc, err := storage.ToDocument(stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query entities: %w", err)
+		}
+		if matchesAll(doc, query.Filters) {
+			hits = append(hits, storage.SearchHit{ID: id, Doc: doc})
+		}
+	}
+	return hits, nil
+}
+
+// Search implements SearchableRepository.Search. Results must be a pointer
+// to a slice of searchable entities or entity pointers. Every word of the
+// query must occur; results are ordered by relevance unless a sort is given.
+func (s *MemoryStore) Search(ctx context.Context, options storage.SearchOptions, results interface{}) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	sample := reflect.New(structType).Interface()
+
+	hits, err := s.search(sample, options)
+	if err != nil {
+		return err
+	}
+	hits = storage.PageHits(hits, options.Pagination)
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, len(hits))
+	for _, hit := range hits {
+		item := reflect.New(structType)
+		copyEntity(s.data[getEntityType(sample)][hit.ID], item.Interface())
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	slice.Elem().Set(list)
+	return nil
+}
+
+// SearchCount implements SearchableRepository.SearchCount. The options must
+// include an "entity_type" filter holding a sample of the searched entity.
+func (s *MemoryStore) SearchCount(ctx context.Context, options storage.SearchOptions) (int64, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, f := range options.Filters {
+		if f.Field == "entity_type" {
+			hits, err := s.search(f.Value, options)
+			return int64(len(hits)), err
+		}
+	}
+	return 0, fmt.Errorf("search count needs an entity_type filter")
+}
+
+// search scores the live entities of a sample's type against a search
+func (s *MemoryStore) search(sample interface{}, options storage.SearchOptions) ([]storage.SearchHit, error) {
+	searchable, ok := sample.(storage.Searchable)
+	if !ok {
+		return nil, fmt.Errorf("entity type %T is not searchable", sample)
+	}
+	terms := storage.SearchTerms(options.Query)
+	fields := searchable.SearchFields()
+
+	matches, err := s.match(getEntityType(sample), storage.Query{Filters: options.Filters})
+	if err != nil {
+		return nil, err
+	}
+	hits := matches[:0]
+	for _, hit := range matches {
+		if score, ok := storage.SearchScore(hit.Doc, terms, fields); ok {
+			hit.Score = score
+			hits = append(hits, hit)
+		}
+	}
+	storage.RankHits(hits, options.Sort)
+	return hits, nil
+}
+
+// GetVersionInfo implements AuditableRepository.GetVersionInfo
+func (s *MemoryStore) GetVersionInfo(ctx context.Context, entityID string) (*storage.VersionInfo, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	info, exists := s.version[entityID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, entityID)
+	}
+	return &info, nil
 }
 
 // GetAuditTrail implements AuditableRepository.GetAuditTrail
@@ -152,51 +365,201 @@ func (s *MemoryStore) GetAuditTrail(ctx context.Context, entityID string) ([]sto
 	s.RLock()
 	defer s.RUnlock()
 
-	if trail, exists := s.audit[entityID]; exists {
-		return trail, nil
+	var trail []storage.AuditEntry
+	for _, entry := range s.audit {
+		if entry.EntityID == entityID {
+			trail = append(trail, entry)
+		}
+	}
+
+	return trail, nil
+}
+
+// QueryAuditTrail implements AuditableRepository.QueryAuditTrail
+func (s *MemoryStore) QueryAuditTrail(ctx context.Context, filter storage.AuditFilter) (*storage.AuditPage, error) {
+	entries, err := s.QueryAudit(ctx, filter.AuditQuery)
+	if err != nil {
+		return nil, err
 	}
+	return storage.PageAudit(entries, filter.Pagination), nil
+}
 
-	return nil, nil
+// QueryAudit implements AuditLogRepository.QueryAudit
+func (s *MemoryStore) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	entries := make([]storage.AuditEntry, 0)
+	for _, entry := range s.audit {
+		if query.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *MemoryStore) setVersion(ctx context.Context, id string, version int64) {
+	s.version[id] = storage.VersionInfo{
+		Version:    version,
+		ModifiedAt: time.Now(),
+		ModifiedBy: audit.UserID(ctx),
+	}
 }
 
-func (s *MemoryStore) recordAudit(entityType, entityID, operation string, oldState, newState interface{}) {
+func (s *MemoryStore) recordAudit(ctx context.Context, entityType, entityID, operation string, oldState, newState interface{}) {
+	caller := audit.CallerFromContext(ctx)
 	entry := storage.AuditEntry{
 		ID:            fmt.Sprintf("audit_%d", time.Now().UnixNano()),
 		EntityType:    entityType,
 		EntityID:      entityID,
 		Operation:     operation,
+		UserID:        caller.UserID,
 		Timestamp:     time.Now(),
 		PreviousState: oldState,
 		NewState:      newState,
+		Metadata:      caller.Metadata(),
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
+		}
+		entry.Metadata[tenant.MetadataKey] = id
 	}
 
-	if s.audit[entityID] == nil {
-		s.audit[entityID] = make([]storage.AuditEntry, 0)
+	// Link the entry to the end of the store's audit chain
+	var previous *storage.AuditEntry
+	if n := len(s.audit); n > 0 {
+		previous = &s.audit[n-1]
 	}
-	s.audit[entityID] = append(s.audit[entityID], entry)
+	// Entries whose signing fails are kept unsigned, which
+	// audit.VerifySignatures reports
+	_ = audit.SealSigned(ctx, &entry, previous, s.signer)
+
+	s.audit = append(s.audit, entry)
 }
 
 // Helper functions
 
+// matchesAll reports whether a document satisfies every filter, ignoring
+// the "entity_type" pseudo-filter
+func matchesAll(doc map[string]interface{}, filters []storage.Filter) bool {
+	for _, f := range filters {
+		if f.Field != "entity_type" && !f.Matches(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the first entity with the ID, whatever its type
+func (s *MemoryStore) find(id string) (string, interface{}, bool) {
+	for entityType, entities := range s.data {
+		if stored, exists := entities[id]; exists {
+			return entityType, stored, true
+		}
+	}
+	return "", nil, false
+}
+
+// getEntityType returns the type entities are stored under, the same for
+// an entity and a pointer to it
 func getEntityType(entity interface{}) string {
-	// In a real implementation, this would use reflection or type assertions
-	// to get the entity type name
-	return fmt.Sprintf("%T", entity)
+	t := reflect.TypeOf(entity)
+	if t != nil && t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	return fmt.Sprint(t)
 }
 
+// getEntityID returns the ID of an entity from its GetID method or, for
+// plain structs, its string ID field
 func getEntityID(entity interface{}) string {
-	// In a real implementation, this would use reflection or type assertions
-	// to get the entity ID
 	if e, ok := entity.(interface{ GetID() string }); ok {
 		return e.GetID()
 	}
+	v := reflect.Indirect(reflect.ValueOf(entity))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if id := v.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.String {
+		return id.String()
+	}
 	return ""
 }
 
+// cloneEntity returns a pointer to a deep copy of an entity, so the store
+// never shares state with callers
+func cloneEntity(entity interface{}) interface{} {
+	v := reflect.ValueOf(entity)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	copied := reflect.New(v.Type())
+	copied.Elem().Set(deepCopy(v))
+	return copied.Interface()
+}
+
+// copyEntity deep-copies a stored entity into the value dst points to
 func copyEntity(src, dst interface{}) {
-	// In a real implementation, this would use reflection or type assertions
-	// to copy the entity data
-	if copier, ok := dst.(interface{ CopyFrom(interface{}) error }); ok {
-		_ = copier.CopyFrom(src)
+	target := reflect.ValueOf(dst)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return
+	}
+	source := reflect.Indirect(reflect.ValueOf(src))
+	if source.Type() != target.Elem().Type() {
+		return
+	}
+	target.Elem().Set(deepCopy(source))
+}
+
+// deepCopy copies a value through pointers, slices, maps, interfaces and
+// exported struct fields. Unexported fields are copied as they are, which
+// suits value types such as time.Time and decimal.Decimal.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(deepCopy(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(deepCopy(v.Elem()))
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				copied.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return copied
 	}
+	return v
 }
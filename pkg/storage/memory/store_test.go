@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/johnayoung/finlib/pkg/audit"
 	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
 )
 
 // TestEntity is a simple entity for testing
@@ -18,12 +20,6 @@ type TestEntity struct {
 func (e *TestEntity) GetID() string      { return e.id }
 func (e *TestEntity) GetVersion() int64  { return e.version }
 func (e *TestEntity) SetVersion(v int64) { e.version = v }
-func (e *TestEntity) CopyFrom(src interface{}) error {
-	if s, ok := src.(*TestEntity); ok {
-		*e = *s
-	}
-	return nil
-}
 
 // SimpleEntity is a non-versioned entity for testing
 type SimpleEntity struct {
@@ -32,12 +28,6 @@ type SimpleEntity struct {
 }
 
 func (e *SimpleEntity) GetID() string { return e.id }
-func (e *SimpleEntity) CopyFrom(src interface{}) error {
-	if s, ok := src.(*SimpleEntity); ok {
-		*e = *s
-	}
-	return nil
-}
 
 func TestMemoryStore(t *testing.T) {
 	ctx := context.Background()
@@ -101,6 +91,50 @@ func TestMemoryStore(t *testing.T) {
 		assert.Equal(t, "updated", updated.data)
 	})
 
+	t.Run("Version Info", func(t *testing.T) {
+		ctx := audit.WithCaller(ctx, audit.Caller{UserID: "alice"})
+		entity := &TestEntity{}
+		assert.NoError(t, store.Read(ctx, "test1", entity))
+		assert.Equal(t, int64(2), entity.version, "reads carry the stored version")
+
+		entity.data = "read-modify-write"
+		assert.NoError(t, store.Update(ctx, entity))
+		assert.Equal(t, int64(3), entity.version)
+
+		info, err := store.GetVersionInfo(ctx, "test1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), info.Version)
+		assert.Equal(t, "alice", info.ModifiedBy)
+
+		_, err = store.GetVersionInfo(ctx, "nonexistent")
+		assert.Error(t, err)
+	})
+
+	t.Run("Soft Delete", func(t *testing.T) {
+		entity := &TestEntity{id: "soft1", data: "kept"}
+		assert.NoError(t, store.Create(ctx, entity))
+		assert.NoError(t, store.SoftDelete(ctx, "soft1"))
+		assert.ErrorIs(t, store.SoftDelete(ctx, "soft1"), storage.ErrDeleted)
+		assert.ErrorIs(t, store.Read(ctx, "soft1", &TestEntity{}), storage.ErrDeleted)
+		assert.ErrorIs(t, store.Update(ctx, entity), storage.ErrDeleted)
+
+		info, err := store.GetVersionInfo(ctx, "soft1")
+		assert.NoError(t, err)
+		assert.NotNil(t, info.DeletedAt)
+
+		assert.NoError(t, store.Restore(ctx, "soft1"))
+		assert.Error(t, store.Restore(ctx, "soft1"))
+		restored := &TestEntity{}
+		assert.NoError(t, store.Read(ctx, "soft1", restored))
+		assert.Equal(t, "kept", restored.data)
+		assert.NoError(t, store.Update(ctx, restored))
+
+		trail, err := store.GetAuditTrail(ctx, "soft1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"CREATE", "SOFT_DELETE", "RESTORE", "UPDATE"},
+			[]string{trail[0].Operation, trail[1].Operation, trail[2].Operation, trail[3].Operation})
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		err := store.Delete(ctx, "test1")
 		assert.NoError(t, err)
@@ -196,3 +230,172 @@ func TestConcurrency(t *testing.T) {
 		assert.True(t, errorCount > 0)
 	})
 }
+
+func TestMemoryStoreSearch(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, tx := range []*transaction.Transaction{
+		{ID: "TX1", Status: transaction.Posted, Description: "Office rent", Reference: "INV-1001"},
+		{ID: "TX2", Status: transaction.Posted, Description: "Invoice INV-1001 settlement",
+			Entries: []transaction.Entry{{AccountID: "cash", Description: "Rent paid"}}},
+		{ID: "TX3", Status: transaction.Draft, Description: "Rent for March"},
+		{ID: "TX4", Status: transaction.Posted, Description: "Utilities"},
+	} {
+		assert.NoError(t, store.Create(ctx, tx))
+	}
+
+	ids := func(txs []*transaction.Transaction) []string {
+		found := make([]string, len(txs))
+		for i, tx := range txs {
+			found[i] = tx.ID
+		}
+		return found
+	}
+
+	// A match in the reference outranks one in the description
+	var found []*transaction.Transaction
+	assert.NoError(t, store.Search(ctx, storage.SearchOptions{Query: "inv-1001"}, &found))
+	assert.Equal(t, []string{"TX1", "TX2"}, ids(found))
+
+	// Entry descriptions are searched too, and every word must match
+	assert.NoError(t, store.Search(ctx, storage.SearchOptions{Query: "rent"}, &found))
+	assert.Equal(t, []string{"TX1", "TX3", "TX2"}, ids(found))
+	assert.NoError(t, store.Search(ctx, storage.SearchOptions{Query: "rent march"}, &found))
+	assert.Equal(t, []string{"TX3"}, ids(found))
+
+	options := storage.SearchOptions{
+		Query:   "rent",
+		Filters: []storage.Filter{{Field: "status", Operator: "=", Value: transaction.Posted}},
+		Sort:    []storage.Sort{{Field: "id", Desc: true}},
+	}
+	assert.NoError(t, store.Search(ctx, options, &found))
+	assert.Equal(t, []string{"TX2", "TX1"}, ids(found))
+
+	options.Filters = append(options.Filters, storage.Filter{Field: "entity_type", Value: &transaction.Transaction{}})
+	count, err := store.SearchCount(ctx, options)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.NoError(t, store.SoftDelete(ctx, "TX1"))
+	assert.NoError(t, store.Search(ctx, storage.SearchOptions{Query: "inv 1001"}, &found))
+	assert.Equal(t, []string{"TX2"}, ids(found))
+
+	assert.Error(t, store.Search(ctx, storage.SearchOptions{Query: "x"}, &[]*TestEntity{}), "not searchable")
+}
+
+// plainEntity has an ID field but none of the optional entity methods
+type plainEntity struct {
+	ID   string
+	Tags map[string]string
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	t.Run("Plain Structs", func(t *testing.T) {
+		assert.NoError(t, store.Create(ctx, &plainEntity{ID: "P1", Tags: map[string]string{"team": "ops"}}))
+		var read plainEntity
+		assert.NoError(t, store.Read(ctx, "P1", &read))
+		assert.Equal(t, "ops", read.Tags["team"])
+
+		read.Tags["team"] = "sales"
+		var again plainEntity
+		assert.NoError(t, store.Read(ctx, "P1", &again))
+		assert.Equal(t, "ops", again.Tags["team"], "reads don't alias the stored entity")
+	})
+
+	t.Run("No Aliasing", func(t *testing.T) {
+		tx := &transaction.Transaction{
+			ID:          "TX1",
+			Description: "Office rent",
+			Entries:     []transaction.Entry{{AccountID: "cash", Description: "Rent paid"}},
+		}
+		assert.NoError(t, store.Create(ctx, tx))
+		tx.Description = "Changed after create"
+		tx.Entries[0].AccountID = "bank"
+
+		var read transaction.Transaction
+		assert.NoError(t, store.Read(ctx, "TX1", &read))
+		assert.Equal(t, "Office rent", read.Description)
+		assert.Equal(t, "cash", read.Entries[0].AccountID)
+		assert.Equal(t, int64(1), read.Version)
+
+		read.Entries[0].AccountID = "receivables"
+		var again transaction.Transaction
+		assert.NoError(t, store.Read(ctx, "TX1", &again))
+		assert.Equal(t, "cash", again.Entries[0].AccountID)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		for _, tx := range []*transaction.Transaction{
+			{ID: "TX2", Status: transaction.Posted, Entries: []transaction.Entry{{AccountID: "cash"}}},
+			{ID: "TX3", Status: transaction.Draft, Entries: []transaction.Entry{{AccountID: "cash"}}},
+			{ID: "TX4", Status: transaction.Posted, Entries: []transaction.Entry{{AccountID: "bank"}}},
+		} {
+			assert.NoError(t, store.Create(ctx, tx))
+		}
+
+		var cash []*transaction.Transaction
+		assert.NoError(t, store.Query(ctx, storage.Query{
+			Filters: []storage.Filter{{Field: "entries.account_id", Operator: "=", Value: "cash"}},
+			Sort:    []storage.Sort{{Field: "id", Desc: true}},
+		}, &cash))
+		if assert.Len(t, cash, 3) {
+			assert.Equal(t, "TX3", cash[0].ID)
+			assert.Equal(t, "TX1", cash[2].ID)
+		}
+
+		var page []transaction.Transaction
+		assert.NoError(t, store.Query(ctx, storage.Query{Pagination: &storage.Pagination{Offset: 1, Limit: 2}}, &page))
+		if assert.Len(t, page, 2) {
+			assert.Equal(t, "TX2", page[0].ID)
+			assert.Equal(t, "TX3", page[1].ID)
+		}
+
+		assert.NoError(t, store.SoftDelete(ctx, "TX3"))
+		byType := storage.Filter{Field: "entity_type", Value: &transaction.Transaction{}}
+		for mode, want := range map[storage.DeletedMode]int64{
+			storage.ExcludeDeleted: 3,
+			storage.IncludeDeleted: 4,
+			storage.OnlyDeleted:    1,
+		} {
+			count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{byType}, Deleted: mode})
+			assert.NoError(t, err)
+			assert.Equal(t, want, count, "mode %q", mode)
+		}
+
+		count, err := store.Count(ctx, storage.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), count, "every type is counted without an entity_type filter")
+	})
+}
+
+func TestMemoryStoreActor(t *testing.T) {
+	store := NewMemoryStore()
+	alice := storage.WithActor(context.Background(), storage.Actor{UserID: "alice", Source: "api", RequestID: "req-1", IPAddress: "10.0.0.1"})
+	bob := storage.WithActor(context.Background(), storage.Actor{UserID: "bob"})
+
+	tx := &transaction.Transaction{ID: "TX1"}
+	assert.NoError(t, store.Create(alice, tx))
+	assert.Equal(t, "alice", tx.CreatedBy)
+	assert.Equal(t, "alice", tx.ModifiedBy)
+
+	assert.NoError(t, store.Update(bob, tx))
+	var read transaction.Transaction
+	assert.NoError(t, store.Read(context.Background(), "TX1", &read))
+	assert.Equal(t, "alice", read.CreatedBy)
+	assert.Equal(t, "bob", read.ModifiedBy)
+
+	trail, err := store.GetAuditTrail(context.Background(), "TX1")
+	assert.NoError(t, err)
+	if assert.Len(t, trail, 2) {
+		assert.Equal(t, "alice", trail[0].UserID)
+		assert.Equal(t, map[string]interface{}{"source": "api", "request_id": "req-1", "ip_address": "10.0.0.1"}, trail[0].Metadata)
+		assert.Equal(t, "bob", trail[1].UserID)
+		assert.Nil(t, trail[1].Metadata)
+	}
+	info, err := store.GetVersionInfo(context.Background(), "TX1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", info.ModifiedBy)
+}
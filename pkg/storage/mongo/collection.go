@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNoDocuments is returned by Collection.FindOne when nothing matches
+	ErrNoDocuments = errors.New("no documents in result")
+	// ErrDuplicateKey is returned by Collection.InsertOne when the _id or
+	// another unique index key is taken
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// Document is a MongoDB document or filter. Values are strings, numbers,
+// booleans, time.Time, nested Documents and []interface{}.
+type Document map[string]interface{}
+
+// SortField orders find results by a field; Direction is 1 or -1
+type SortField struct {
+	Field     string
+	Direction int
+}
+
+// FindOptions shapes the results of Collection.Find
+type FindOptions struct {
+	Sort  []SortField
+	Skip  int64
+	Limit int64
+}
+
+// IndexKey is a field of an index; Order is 1 or -1
+type IndexKey struct {
+	Field string
+	Order int
+}
+
+// Index describes a collection index
+type Index struct {
+	Name   string
+	Keys   []IndexKey
+	Unique bool
+}
+
+// Collection is the subset of a MongoDB collection the store uses. The store
+// does not depend on a driver: wrap a driver collection, e.g. the official
+// driver's *mongo.Collection, translating Documents to bson.D and the
+// driver's no-documents and duplicate-key errors to ErrNoDocuments and
+// ErrDuplicateKey.
+type Collection interface {
+	// InsertOne inserts a document
+	InsertOne(ctx context.Context, doc Document) error
+
+	// FindOne returns the first document matching the filter
+	FindOne(ctx context.Context, filter Document) (Document, error)
+
+	// ReplaceOne replaces the first document matching the filter and
+	// returns the number of documents matched
+	ReplaceOne(ctx context.Context, filter Document, doc Document) (int64, error)
+
+	// DeleteOne deletes the first document matching the filter and returns
+	// the number of documents deleted
+	DeleteOne(ctx context.Context, filter Document) (int64, error)
+
+	// Find returns the documents matching the filter
+	Find(ctx context.Context, filter Document, opts FindOptions) ([]Document, error)
+
+	// CountDocuments returns the number of documents matching the filter
+	CountDocuments(ctx context.Context, filter Document) (int64, error)
+
+	// CreateIndexes creates indexes that do not exist yet
+	CreateIndexes(ctx context.Context, indexes []Index) error
+}
+
+// Database opens the collections of a database
+type Database interface {
+	Collection(name string) Collection
+}
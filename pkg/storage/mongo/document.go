@@ -0,0 +1,199 @@
+package mongo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// Fields the store adds to entity documents. Entity fields keep their JSON
+// names, so filters and indexes address them directly.
+const (
+	fieldID       = "_id"
+	fieldType     = "_type"
+	fieldKey      = "_key"
+	fieldVersion  = "_version"
+	fieldModified = "_modified_at"
+	fieldModifier = "_modified_by"
+	// Set on soft-deleted documents, with when and by whom
+	fieldDeleted   = "_deleted"
+	fieldDeletedAt = "_deleted_at"
+	fieldDeleter   = "_deleted_by"
+)
+
+// operators maps filter operators to MongoDB query operators
+var operators = map[string]string{
+	"=":  "$eq",
+	"!=": "$ne",
+	">":  "$gt",
+	">=": "$gte",
+	"<":  "$lt",
+	"<=": "$lte",
+	"in": "$in",
+}
+
+// encode converts an entity to a document through its JSON form. Strings
+// holding RFC 3339 timestamps become times, so date ranges compare
+// chronologically.
+func encode(entity interface{}) (Document, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	doc, ok := convert(raw, true).(Document)
+	if !ok {
+		return nil, fmt.Errorf("entity %T does not encode to an object", entity)
+	}
+	return doc, nil
+}
+
+// decode fills an entity from a document, ignoring the store's own fields
+func decode(doc Document, entity interface{}) error {
+	data, err := json.Marshal(copyFields(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, entity)
+}
+
+// convert turns decoded JSON into document values
+func convert(v interface{}, parseTimes bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		doc := make(Document, len(t))
+		for k, item := range t {
+			doc[k] = convert(item, parseTimes)
+		}
+		return doc
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, item := range t {
+			list[i] = convert(item, parseTimes)
+		}
+		return list
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n
+		}
+		f, _ := t.Float64()
+		return f
+	case string:
+		if parseTimes && strings.Contains(t, "T") {
+			if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+				return ts
+			}
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// filterValue converts a filter value to the form it is stored in
+func filterValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+		return nil
+	case string, bool, int64, float64, nil:
+		return t
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = filterValue(rv.Index(i).Interface())
+		}
+		return list
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return v
+	}
+	return convert(raw, true)
+}
+
+// filter translates query filters into a MongoDB filter. Fields are dotted
+// paths, which MongoDB matches through arrays, so entries.account_id finds
+// transactions with an entry on the account.
+func filter(entityType string, filters []storage.Filter, deleted storage.DeletedMode) (Document, error) {
+	doc := Document{}
+	if entityType != "" {
+		doc[fieldType] = entityType
+	}
+	switch deleted {
+	case storage.ExcludeDeleted:
+		// Documents stored before soft deletes lack the field, which $ne
+		// matches
+		doc[fieldDeleted] = Document{"$ne": true}
+	case storage.OnlyDeleted:
+		doc[fieldDeleted] = true
+	}
+	for _, f := range filters {
+		op, ok := operators[strings.ToLower(f.Operator)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported operator %q on field %s", f.Operator, f.Field)
+		}
+		field := f.Field
+		if field == "" || strings.HasPrefix(field, "_") || strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("invalid field %q", f.Field)
+		}
+		if field == "id" {
+			field = fieldKey
+		}
+
+		value := filterValue(f.Value)
+		if op == "$in" {
+			if _, ok := value.([]interface{}); !ok {
+				value = []interface{}{value}
+			}
+		}
+		conditions, ok := doc[field].(Document)
+		if !ok {
+			conditions = Document{}
+			doc[field] = conditions
+		}
+		conditions[op] = value
+	}
+	return doc, nil
+}
+
+// sortFields translates query sorts, falling back to key order so pages are
+// stable
+func sortFields(sorts []storage.Sort) []SortField {
+	fields := make([]SortField, 0, len(sorts)+1)
+	for _, s := range sorts {
+		field := s.Field
+		if field == "id" {
+			field = fieldKey
+		}
+		direction := 1
+		if s.Desc {
+			direction = -1
+		}
+		fields = append(fields, SortField{Field: field, Direction: direction})
+	}
+	return append(fields, SortField{Field: fieldKey, Direction: 1})
+}
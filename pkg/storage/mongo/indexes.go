@@ -0,0 +1,71 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Collection names used by the store
+const (
+	AccountsCollection     = "accounts"
+	TransactionsCollection = "transactions"
+	EntitiesCollection     = "entities"
+	AuditCollection        = "audit_entries"
+)
+
+// AccountIndexes covers account lookups by ID, type and code
+func AccountIndexes() []Index {
+	return []Index{
+		{Name: "key", Keys: []IndexKey{{Field: fieldKey, Order: 1}}},
+		{Name: "type_code", Keys: []IndexKey{{Field: fieldType, Order: 1}, {Field: "type", Order: 1}, {Field: "code", Order: 1}}},
+		{Name: "legal_entity_type", Keys: []IndexKey{{Field: fieldType, Order: 1}, {Field: "entity_id", Order: 1}, {Field: "type", Order: 1}}},
+	}
+}
+
+// TransactionIndexes covers the queries of the report calculator: the posted
+// transactions with an entry on an account over a date range, by transaction
+// date or effective date
+func TransactionIndexes() []Index {
+	return []Index{
+		{Name: "key", Keys: []IndexKey{{Field: fieldKey, Order: 1}}},
+		{Name: "account_status_date", Keys: []IndexKey{
+			{Field: "entries.account_id", Order: 1}, {Field: "status", Order: 1}, {Field: "date", Order: 1},
+		}},
+		{Name: "account_status_effective_date", Keys: []IndexKey{
+			{Field: "entries.account_id", Order: 1}, {Field: "status", Order: 1}, {Field: "effective_date", Order: 1},
+		}},
+		{Name: "status_date", Keys: []IndexKey{{Field: "status", Order: 1}, {Field: "date", Order: 1}}},
+	}
+}
+
+// EntityIndexes covers lookups of other entities by ID
+func EntityIndexes() []Index {
+	return []Index{
+		{Name: "key", Keys: []IndexKey{{Field: fieldKey, Order: 1}}},
+	}
+}
+
+// AuditIndexes covers audit trail lookups by entity
+func AuditIndexes() []Index {
+	return []Index{
+		{Name: "entity_sequence", Keys: []IndexKey{{Field: "entity_id", Order: 1}, {Field: "_id", Order: 1}}},
+	}
+}
+
+// EnsureIndexes creates the indexes of every collection of the store
+func EnsureIndexes(ctx context.Context, db Database) error {
+	for _, c := range []struct {
+		name    string
+		indexes []Index
+	}{
+		{AccountsCollection, AccountIndexes()},
+		{TransactionsCollection, TransactionIndexes()},
+		{EntitiesCollection, EntityIndexes()},
+		{AuditCollection, AuditIndexes()},
+	} {
+		if err := db.Collection(c.name).CreateIndexes(ctx, c.indexes); err != nil {
+			return fmt.Errorf("failed to create indexes on %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,583 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+var ErrNotFound = storage.ErrNotFound
+
+// maxAuditRetries bounds the attempts to append to the audit chain when
+// concurrent writers take the same sequence
+const maxAuditRetries = 5
+
+// Store is a MongoDB implementation of the storage interfaces. Entities are
+// stored with their JSON field names, accounts and transactions in their
+// own collections and everything else in the entities collection, keyed by
+// Go type and ID. Audit entries are keyed by their chain sequence, so
+// concurrent writers cannot fork the chain. Writes are not transactional;
+// an entity and its audit entry are written one after the other. Call
+// EnsureIndexes once to create the indexes the report calculator relies on.
+// Soft-deleted entities keep their documents, flagged deleted.
+type Store struct {
+	db          Database
+	collections map[string]string
+	signer      audit.Signer
+}
+
+// NewStore creates a store on a database
+func NewStore(db Database) *Store {
+	return &Store{
+		db: db,
+		collections: map[string]string{
+			entityType(&account.Account{}):         AccountsCollection,
+			entityType(&transaction.Transaction{}): TransactionsCollection,
+		},
+	}
+}
+
+// SetSigner makes the store sign the hash of every new audit entry, so the
+// chain can be checked with audit.VerifySignatures
+func (s *Store) SetSigner(signer audit.Signer) {
+	s.signer = signer
+}
+
+// Create implements Repository.Create
+func (s *Store) Create(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	if id == "" {
+		return fmt.Errorf("entity ID cannot be empty")
+	}
+	storage.StampActor(ctx, entity, true)
+	restore := storage.SetVersion(entity, 1)
+	doc, err := encode(entity)
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to encode entity: %w", err)
+	}
+
+	entityType := entityType(entity)
+	state := copyFields(doc)
+	s.stamp(ctx, doc, entityType, id, 1)
+	if err := s.collection(entityType).InsertOne(ctx, doc); err != nil {
+		restore()
+		if errors.Is(err, ErrDuplicateKey) {
+			return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
+		}
+		return fmt.Errorf("failed to store entity: %w", err)
+	}
+	return s.recordAudit(ctx, entityType, id, "CREATE", nil, state)
+}
+
+// Read implements Repository.Read
+func (s *Store) Read(ctx context.Context, id string, entity interface{}) error {
+	entityType := entityType(entity)
+	doc, err := s.collection(entityType).FindOne(ctx, Document{fieldID: documentID(entityType, id)})
+	if errors.Is(err, ErrNoDocuments) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading entity: %w", err)
+	}
+	if isDeleted(doc) {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+	}
+	if err := decode(doc, entity); err != nil {
+		return fmt.Errorf("failed to decode entity: %w", err)
+	}
+	return nil
+}
+
+// Update implements Repository.Update. Entities with a GetVersion method
+// are only updated at the version they were read at.
+func (s *Store) Update(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	entityType := entityType(entity)
+	coll := s.collection(entityType)
+	key := documentID(entityType, id)
+	old, err := coll.FindOne(ctx, Document{fieldID: key})
+	if errors.Is(err, ErrNoDocuments) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading entity: %w", err)
+	}
+	if isDeleted(old) {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+	}
+
+	version := versionOf(old)
+	if err := storage.CheckVersion(entity, entityType, id, version); err != nil {
+		return err
+	}
+	storage.StampActor(ctx, entity, false)
+	restore := storage.SetVersion(entity, version+1)
+	doc, err := encode(entity)
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to encode entity: %w", err)
+	}
+
+	state := copyFields(doc)
+	s.stamp(ctx, doc, entityType, id, version+1)
+	// Matching on the version read catches writers that raced past it
+	matched, err := coll.ReplaceOne(ctx, Document{fieldID: key, fieldVersion: version}, doc)
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to store entity: %w", err)
+	}
+	if matched == 0 {
+		restore()
+		return &storage.OptimisticLockError{EntityType: entityType, EntityID: id, ExpectedVersion: version}
+	}
+	return s.recordAudit(ctx, entityType, id, "UPDATE", copyFields(old), state)
+}
+
+// Delete implements Repository.Delete. Like the memory store it removes the
+// first entity with the ID, whatever its type.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	coll, old, err := s.findByKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := coll.DeleteOne(ctx, Document{fieldID: old[fieldID]})
+	if err != nil {
+		return fmt.Errorf("failed to delete entity: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	entityType, _ := old[fieldType].(string)
+	return s.recordAudit(ctx, entityType, id, "DELETE", copyFields(old), nil)
+}
+
+// SoftDelete implements SoftDeleteRepository.SoftDelete. The document is
+// kept and flagged deleted, and it keeps its version.
+func (s *Store) SoftDelete(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, true)
+}
+
+// Restore implements SoftDeleteRepository.Restore
+func (s *Store) Restore(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, id string, deleted bool) error {
+	coll, old, err := s.findByKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if deleted && isDeleted(old) {
+		return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+	}
+	if !deleted && !isDeleted(old) {
+		return fmt.Errorf("entity is not deleted: %s", id)
+	}
+
+	doc := make(Document, len(old))
+	for k, v := range old {
+		doc[k] = v
+	}
+	operation := "RESTORE"
+	oldState, newState := Document(nil), copyFields(old)
+	if deleted {
+		doc[fieldDeleted] = true
+		doc[fieldDeletedAt] = time.Now().UTC().Truncate(time.Millisecond)
+		doc[fieldDeleter] = audit.CallerFromContext(ctx).UserID
+		operation = "SOFT_DELETE"
+		oldState, newState = copyFields(old), nil
+	} else {
+		delete(doc, fieldDeleted)
+		delete(doc, fieldDeletedAt)
+		delete(doc, fieldDeleter)
+	}
+
+	matched, err := coll.ReplaceOne(ctx, Document{fieldID: old[fieldID], fieldVersion: versionOf(old)}, doc)
+	if err != nil {
+		return fmt.Errorf("failed to store entity: %w", err)
+	}
+	entityType, _ := old[fieldType].(string)
+	if matched == 0 {
+		return &storage.OptimisticLockError{EntityType: entityType, EntityID: id, ExpectedVersion: versionOf(old)}
+	}
+	return s.recordAudit(ctx, entityType, id, operation, oldState, newState)
+}
+
+// findByKey returns the first document with the ID, whatever its type, and
+// its collection
+func (s *Store) findByKey(ctx context.Context, id string) (Collection, Document, error) {
+	for _, name := range []string{AccountsCollection, TransactionsCollection, EntitiesCollection} {
+		coll := s.db.Collection(name)
+		doc, err := coll.FindOne(ctx, Document{fieldKey: id})
+		if errors.Is(err, ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading entity: %w", err)
+		}
+		return coll, doc, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Query implements Repository.Query. Results must be a pointer to a slice
+// of entities or entity pointers.
+func (s *Store) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	entityType := entityType(reflect.New(structType).Interface())
+
+	where, err := filter(entityType, query.Filters, query.Deleted)
+	if err != nil {
+		return err
+	}
+	opts := FindOptions{Sort: sortFields(query.Sort)}
+	if query.Pagination != nil {
+		opts.Skip = query.Pagination.Offset
+		opts.Limit = query.Pagination.Limit
+	}
+	docs, err := s.collection(entityType).Find(ctx, where, opts)
+	if err != nil {
+		return fmt.Errorf("error querying entities: %w", err)
+	}
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, len(docs))
+	for _, doc := range docs {
+		item := reflect.New(structType)
+		if err := decode(doc, item.Interface()); err != nil {
+			return fmt.Errorf("failed to decode entity: %w", err)
+		}
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	slice.Elem().Set(list)
+	return nil
+}
+
+// Count implements Repository.Count. An "entity_type" filter, holding a
+// sample entity such as &account.Account{}, restricts the count to one
+// type; without it documents of every type are counted.
+func (s *Store) Count(ctx context.Context, query storage.Query) (int64, error) {
+	names := []string{AccountsCollection, TransactionsCollection, EntitiesCollection}
+	entityType := ""
+	filters := make([]storage.Filter, 0, len(query.Filters))
+	for _, f := range query.Filters {
+		if f.Field == "entity_type" {
+			entityType = typeName(f.Value)
+			names = []string{s.collectionName(entityType)}
+			continue
+		}
+		filters = append(filters, f)
+	}
+	where, err := filter(entityType, filters, query.Deleted)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, name := range names {
+		count, err := s.db.Collection(name).CountDocuments(ctx, where)
+		if err != nil {
+			return 0, fmt.Errorf("error counting entities: %w", err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// GetVersionInfo implements AuditableRepository.GetVersionInfo
+func (s *Store) GetVersionInfo(ctx context.Context, entityID string) (*storage.VersionInfo, error) {
+	for _, name := range []string{AccountsCollection, TransactionsCollection, EntitiesCollection} {
+		doc, err := s.db.Collection(name).FindOne(ctx, Document{fieldKey: entityID})
+		if errors.Is(err, ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading version: %w", err)
+		}
+		info := &storage.VersionInfo{Version: versionOf(doc)}
+		info.ModifiedAt, _ = doc[fieldModified].(time.Time)
+		info.ModifiedBy, _ = doc[fieldModifier].(string)
+		if deletedAt, ok := doc[fieldDeletedAt].(time.Time); ok {
+			info.DeletedAt = &deletedAt
+			info.DeletedBy, _ = doc[fieldDeleter].(string)
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, entityID)
+}
+
+// GetAuditTrail implements AuditableRepository.GetAuditTrail
+func (s *Store) GetAuditTrail(ctx context.Context, entityID string) ([]storage.AuditEntry, error) {
+	return s.QueryAudit(ctx, storage.AuditQuery{EntityID: entityID})
+}
+
+// QueryAudit implements AuditLogRepository.QueryAudit
+func (s *Store) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	return s.findAudit(ctx, auditFilter(query), FindOptions{})
+}
+
+// QueryAuditTrail implements AuditableRepository.QueryAuditTrail
+func (s *Store) QueryAuditTrail(ctx context.Context, filter storage.AuditFilter) (*storage.AuditPage, error) {
+	where := auditFilter(filter.AuditQuery)
+	total, err := s.db.Collection(AuditCollection).CountDocuments(ctx, where)
+	if err != nil {
+		return nil, fmt.Errorf("error counting audit entries: %w", err)
+	}
+	var opts FindOptions
+	if p := filter.Pagination; p != nil {
+		opts.Skip, opts.Limit = p.Offset, p.Limit
+	}
+	entries, err := s.findAudit(ctx, where, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.AuditPage{Entries: entries, Total: total}, nil
+}
+
+// auditFilter builds the filter of the audit entries matching a query
+func auditFilter(query storage.AuditQuery) Document {
+	where := Document{}
+	for field, value := range map[string]string{
+		"entity_type": query.EntityType,
+		"entity_id":   query.EntityID,
+		"user_id":     query.UserID,
+		"operation":   query.Operation,
+	} {
+		if value != "" {
+			where[field] = value
+		}
+	}
+	if query.From != nil || query.To != nil {
+		timestamp := Document{}
+		if query.From != nil {
+			timestamp["$gte"] = *query.From
+		}
+		if query.To != nil {
+			timestamp["$lte"] = *query.To
+		}
+		where["timestamp"] = timestamp
+	}
+	return where
+}
+
+// findAudit returns the audit entries matching a filter in chain order
+func (s *Store) findAudit(ctx context.Context, where Document, opts FindOptions) ([]storage.AuditEntry, error) {
+	opts.Sort = []SortField{{Field: fieldID, Direction: 1}}
+	docs, err := s.db.Collection(AuditCollection).Find(ctx, where, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit entries: %w", err)
+	}
+	entries := make([]storage.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		entries = append(entries, auditEntry(doc))
+	}
+	return entries, nil
+}
+
+// recordAudit appends an entry to the audit chain. The sequence is the
+// document key, so when a concurrent writer takes it first the entry is
+// resealed on the new end of the chain.
+func (s *Store) recordAudit(ctx context.Context, entityType, entityID, operation string, oldState, newState Document) error {
+	coll := s.db.Collection(AuditCollection)
+	caller := audit.CallerFromContext(ctx)
+	for attempt := 0; attempt < maxAuditRetries; attempt++ {
+		var previous *storage.AuditEntry
+		last, err := coll.Find(ctx, Document{}, FindOptions{Sort: []SortField{{Field: fieldID, Direction: -1}}, Limit: 1})
+		if err != nil {
+			return fmt.Errorf("error reading audit log: %w", err)
+		}
+		if len(last) > 0 {
+			entry := auditEntry(last[0])
+			previous = &entry
+		}
+
+		// MongoDB keeps milliseconds; the sealed timestamp must survive
+		// the round trip
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		entry := storage.AuditEntry{
+			ID:         fmt.Sprintf("audit_%d", now.UnixNano()),
+			EntityType: entityType,
+			EntityID:   entityID,
+			Operation:  operation,
+			UserID:     caller.UserID,
+			Timestamp:  now,
+			Metadata:   caller.Metadata(),
+		}
+		if oldState != nil {
+			entry.PreviousState = oldState
+		}
+		if newState != nil {
+			entry.NewState = newState
+		}
+		if id, ok := tenant.FromContext(ctx); ok {
+			if entry.Metadata == nil {
+				entry.Metadata = make(map[string]interface{})
+			}
+			entry.Metadata[tenant.MetadataKey] = id
+		}
+		if err := audit.SealSigned(ctx, &entry, previous, s.signer); err != nil {
+			return err
+		}
+
+		err = coll.InsertOne(ctx, auditDocument(entry))
+		if errors.Is(err, ErrDuplicateKey) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store audit entry: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to store audit entry: sequence contended %d times", maxAuditRetries)
+}
+
+func auditDocument(entry storage.AuditEntry) Document {
+	doc := Document{
+		fieldID:          entry.Sequence,
+		"id":             entry.ID,
+		"entity_type":    entry.EntityType,
+		"entity_id":      entry.EntityID,
+		"operation":      entry.Operation,
+		"user_id":        entry.UserID,
+		"timestamp":      entry.Timestamp,
+		"previous_state": entry.PreviousState,
+		"new_state":      entry.NewState,
+		"state_hash":     entry.StateHash,
+		"previous_hash":  entry.PreviousHash,
+		"hash":           entry.Hash,
+	}
+	if entry.Signature != "" {
+		doc["signature"] = entry.Signature
+	}
+	if entry.Metadata != nil {
+		doc["metadata"] = Document(entry.Metadata)
+	}
+	return doc
+}
+
+func auditEntry(doc Document) storage.AuditEntry {
+	entry := storage.AuditEntry{}
+	entry.Sequence = toInt64(doc[fieldID])
+	entry.ID, _ = doc["id"].(string)
+	entry.EntityType, _ = doc["entity_type"].(string)
+	entry.EntityID, _ = doc["entity_id"].(string)
+	entry.Operation, _ = doc["operation"].(string)
+	entry.UserID, _ = doc["user_id"].(string)
+	if ts, ok := doc["timestamp"].(time.Time); ok {
+		entry.Timestamp = ts.UTC()
+	}
+	entry.PreviousState = doc["previous_state"]
+	entry.NewState = doc["new_state"]
+	entry.StateHash, _ = doc["state_hash"].(string)
+	entry.PreviousHash, _ = doc["previous_hash"].(string)
+	entry.Hash, _ = doc["hash"].(string)
+	entry.Signature, _ = doc["signature"].(string)
+	switch metadata := doc["metadata"].(type) {
+	case Document:
+		entry.Metadata = metadata
+	case map[string]interface{}:
+		entry.Metadata = metadata
+	}
+	return entry
+}
+
+// stamp adds the store's fields to an entity document
+func (s *Store) stamp(ctx context.Context, doc Document, entityType, id string, version int64) {
+	doc[fieldID] = documentID(entityType, id)
+	doc[fieldType] = entityType
+	doc[fieldKey] = id
+	doc[fieldVersion] = version
+	doc[fieldModified] = time.Now().UTC().Truncate(time.Millisecond)
+	doc[fieldModifier] = audit.CallerFromContext(ctx).UserID
+}
+
+func (s *Store) collection(entityType string) Collection {
+	return s.db.Collection(s.collectionName(entityType))
+}
+
+func (s *Store) collectionName(entityType string) string {
+	if name, ok := s.collections[entityType]; ok {
+		return name
+	}
+	return EntitiesCollection
+}
+
+// documentID keys a document by type and ID, so entities of different
+// types may share an ID in the entities collection
+func documentID(entityType, id string) string {
+	return entityType + "/" + id
+}
+
+// copyFields returns the entity fields of a document, without the store's
+func copyFields(doc Document) Document {
+	fields := make(Document, len(doc))
+	for k, v := range doc {
+		if !strings.HasPrefix(k, "_") {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+func isDeleted(doc Document) bool {
+	deleted, _ := doc[fieldDeleted].(bool)
+	return deleted
+}
+
+func versionOf(doc Document) int64 {
+	return toInt64(doc[fieldVersion])
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+func entityType(entity interface{}) string {
+	return fmt.Sprintf("%T", entity)
+}
+
+// typeName returns the entity type of a value passed in a filter, which
+// may be a sample entity or the type name itself
+func typeName(v interface{}) string {
+	if name, ok := v.(string); ok {
+		return name
+	}
+	return entityType(v)
+}
+
+func entityID(entity interface{}) string {
+	if e, ok := entity.(interface{ GetID() string }); ok {
+		return e.GetID()
+	}
+	return ""
+}
@@ -0,0 +1,378 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time checks of the interfaces the store implements
+var (
+	_ storage.AuditableRepository  = (*Store)(nil)
+	_ storage.AuditLogRepository   = (*Store)(nil)
+	_ storage.SoftDeleteRepository = (*Store)(nil)
+)
+
+// memDatabase is an in-memory Database supporting the filters the store
+// produces: equality and comparison operators on dotted paths
+type memDatabase struct {
+	mu          sync.Mutex
+	collections map[string]*memCollection
+	indexes     map[string][]Index
+}
+
+func newMemDatabase() *memDatabase {
+	return &memDatabase{collections: make(map[string]*memCollection), indexes: make(map[string][]Index)}
+}
+
+func (d *memDatabase) Collection(name string) Collection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.collections[name] == nil {
+		d.collections[name] = &memCollection{db: d, name: name}
+	}
+	return d.collections[name]
+}
+
+type memCollection struct {
+	db   *memDatabase
+	name string
+	docs []Document
+}
+
+func (c *memCollection) InsertOne(ctx context.Context, doc Document) error {
+	for _, d := range c.docs {
+		if d[fieldID] == doc[fieldID] {
+			return ErrDuplicateKey
+		}
+	}
+	c.docs = append(c.docs, doc)
+	return nil
+}
+
+func (c *memCollection) FindOne(ctx context.Context, filter Document) (Document, error) {
+	for _, d := range c.docs {
+		if matches(d, filter) {
+			return d, nil
+		}
+	}
+	return nil, ErrNoDocuments
+}
+
+func (c *memCollection) ReplaceOne(ctx context.Context, filter Document, doc Document) (int64, error) {
+	for i, d := range c.docs {
+		if matches(d, filter) {
+			c.docs[i] = doc
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *memCollection) DeleteOne(ctx context.Context, filter Document) (int64, error) {
+	for i, d := range c.docs {
+		if matches(d, filter) {
+			c.docs = append(c.docs[:i], c.docs[i+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *memCollection) Find(ctx context.Context, filter Document, opts FindOptions) ([]Document, error) {
+	found := make([]Document, 0)
+	for _, d := range c.docs {
+		if matches(d, filter) {
+			found = append(found, d)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		for _, s := range opts.Sort {
+			a, b := lookup(found[i], s.Field), lookup(found[j], s.Field)
+			if len(a) == 0 || len(b) == 0 || compare(a[0], b[0]) == 0 {
+				continue
+			}
+			return compare(a[0], b[0])*s.Direction < 0
+		}
+		return false
+	})
+	if opts.Skip > 0 {
+		if opts.Skip > int64(len(found)) {
+			opts.Skip = int64(len(found))
+		}
+		found = found[opts.Skip:]
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(found)) {
+		found = found[:opts.Limit]
+	}
+	return found, nil
+}
+
+func (c *memCollection) CountDocuments(ctx context.Context, filter Document) (int64, error) {
+	found, err := c.Find(ctx, filter, FindOptions{})
+	return int64(len(found)), err
+}
+
+func (c *memCollection) CreateIndexes(ctx context.Context, indexes []Index) error {
+	c.db.indexes[c.name] = append(c.db.indexes[c.name], indexes...)
+	return nil
+}
+
+func matches(doc, filter Document) bool {
+	for field, condition := range filter {
+		values := lookup(doc, field)
+		ops, ok := condition.(Document)
+		if !ok {
+			ops = Document{"$eq": condition}
+		}
+		for op, want := range ops {
+			if !matchesAny(values, op, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesAny(values []interface{}, op string, want interface{}) bool {
+	if op == "$ne" {
+		return !matchesAny(values, "$eq", want)
+	}
+	for _, v := range values {
+		switch op {
+		case "$in":
+			for _, w := range want.([]interface{}) {
+				if compare(v, w) == 0 {
+					return true
+				}
+			}
+		case "$eq":
+			if compare(v, want) == 0 {
+				return true
+			}
+		case "$gt":
+			if compare(v, want) > 0 {
+				return true
+			}
+		case "$gte":
+			if compare(v, want) >= 0 {
+				return true
+			}
+		case "$lt":
+			if compare(v, want) < 0 {
+				return true
+			}
+		case "$lte":
+			if compare(v, want) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup returns the values at a dotted path, descending through arrays
+func lookup(v interface{}, path string) []interface{} {
+	if path == "" {
+		if list, ok := v.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{v}
+	}
+	head, rest, _ := strings.Cut(path, ".")
+	switch t := v.(type) {
+	case Document:
+		if child, ok := t[head]; ok {
+			return lookup(child, rest)
+		}
+	case []interface{}:
+		values := make([]interface{}, 0)
+		for _, item := range t {
+			values = append(values, lookup(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+func compare(a, b interface{}) int {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	if ia, ok := a.(int64); ok {
+		if ib, ok := b.(int64); ok {
+			return int(ia - ib)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func TestStore(t *testing.T) {
+	ctx := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice"})
+	db := newMemDatabase()
+	store := NewStore(db)
+	require.NoError(t, EnsureIndexes(ctx, db))
+	assert.Len(t, db.indexes[TransactionsCollection], 4)
+
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	tx := func(id string, date time.Time, status transaction.TransactionStatus, accountID string) *transaction.Transaction {
+		return &transaction.Transaction{
+			ID:     id,
+			Status: status,
+			Date:   date,
+			Entries: []transaction.Entry{
+				{AccountID: accountID, Amount: usd(100), Type: transaction.Debit},
+				{AccountID: "revenue", Amount: usd(100), Type: transaction.Credit},
+			},
+		}
+	}
+	jan := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 15, 0, 0, 0, 0, time.FixedZone("EST", -5*3600))
+
+	t.Run("CRUD", func(t *testing.T) {
+		cash := &account.Account{ID: "cash", Code: "1000", Name: "Cash", Type: account.Asset}
+		require.NoError(t, store.Create(ctx, cash))
+		assert.Error(t, store.Create(ctx, cash))
+
+		var read account.Account
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		assert.Equal(t, "Cash", read.Name)
+		assert.ErrorIs(t, store.Read(ctx, "missing", &read), ErrNotFound)
+
+		read.Name = "Cash at bank"
+		require.NoError(t, store.Update(ctx, &read))
+		info, err := store.GetVersionInfo(ctx, "cash")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), info.Version)
+		assert.Equal(t, "alice", info.ModifiedBy)
+
+		require.NoError(t, store.Delete(ctx, "cash"))
+		assert.ErrorIs(t, store.Delete(ctx, "cash"), ErrNotFound)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		require.NoError(t, store.Create(ctx, tx("TX1", jan, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, tx("TX2", feb, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, tx("TX3", feb, transaction.Draft, "cash")))
+		require.NoError(t, store.Create(ctx, tx("TX4", feb, transaction.Posted, "bank")))
+
+		var found []*transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Filters: []storage.Filter{
+				{Field: "entries.account_id", Operator: "=", Value: "cash"},
+				{Field: "status", Operator: "=", Value: transaction.Posted},
+				{Field: "date", Operator: ">=", Value: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		}, &found))
+		require.Len(t, found, 1)
+		assert.Equal(t, "TX2", found[0].ID)
+		assert.True(t, found[0].Entries[0].Amount.Amount.Equal(decimal.NewFromInt(100)))
+		assert.True(t, found[0].Date.Equal(feb))
+
+		var page []transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Sort:       []storage.Sort{{Field: "date", Desc: true}},
+			Pagination: &storage.Pagination{Offset: 1, Limit: 2},
+		}, &page))
+		require.Len(t, page, 2)
+		assert.Equal(t, "TX3", page[0].ID)
+		assert.Equal(t, "TX4", page[1].ID)
+
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{
+			{Field: "entity_type", Value: &transaction.Transaction{}},
+			{Field: "status", Operator: "in", Value: []transaction.TransactionStatus{transaction.Draft}},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		require.NoError(t, store.SoftDelete(ctx, "TX3"))
+		assert.ErrorIs(t, store.Read(ctx, "TX3", &transaction.Transaction{}), storage.ErrDeleted)
+		assert.ErrorIs(t, store.Update(ctx, tx("TX3", feb, transaction.Posted, "cash")), storage.ErrDeleted)
+		assert.ErrorIs(t, store.SoftDelete(ctx, "TX3"), storage.ErrDeleted)
+
+		byAccount := []storage.Filter{{Field: "entries.account_id", Operator: "=", Value: "cash"}}
+		for mode, want := range map[storage.DeletedMode]int{
+			storage.ExcludeDeleted: 2,
+			storage.IncludeDeleted: 3,
+			storage.OnlyDeleted:    1,
+		} {
+			var found []*transaction.Transaction
+			require.NoError(t, store.Query(ctx, storage.Query{Filters: byAccount, Deleted: mode}, &found))
+			assert.Len(t, found, want, "mode %q", mode)
+		}
+		info, err := store.GetVersionInfo(ctx, "TX3")
+		require.NoError(t, err)
+		require.NotNil(t, info.DeletedAt)
+		assert.Equal(t, "alice", info.DeletedBy)
+
+		require.NoError(t, store.Restore(ctx, "TX3"))
+		assert.Error(t, store.Restore(ctx, "TX3"))
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{{Field: "entity_type", Value: &transaction.Transaction{}}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+
+		trail, err := store.GetAuditTrail(ctx, "TX3")
+		require.NoError(t, err)
+		require.Len(t, trail, 3)
+		assert.Equal(t, []string{"CREATE", "SOFT_DELETE", "RESTORE"}, []string{trail[0].Operation, trail[1].Operation, trail[2].Operation})
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		trail, err := store.GetAuditTrail(ctx, "cash")
+		require.NoError(t, err)
+		require.Len(t, trail, 3)
+		assert.Equal(t, []string{"CREATE", "UPDATE", "DELETE"}, []string{trail[0].Operation, trail[1].Operation, trail[2].Operation})
+		assert.Equal(t, "alice", trail[0].UserID)
+
+		entries, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 9)
+		assert.NoError(t, audit.VerifyChain(entries))
+	})
+}
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc, err := filter("*account.Account", []storage.Filter{
+		{Field: "type", Operator: "IN", Value: []account.AccountType{account.Asset}},
+		{Field: "date", Operator: ">=", Value: start},
+		{Field: "date", Operator: "<=", Value: &start},
+		{Field: "id", Operator: "!=", Value: "cash"},
+	}, storage.ExcludeDeleted)
+	require.NoError(t, err)
+	assert.Equal(t, Document{
+		fieldType:    "*account.Account",
+		fieldDeleted: Document{"$ne": true},
+		"type":       Document{"$in": []interface{}{"ASSET"}},
+		"date":       Document{"$gte": start, "$lte": start},
+		fieldKey:     Document{"$ne": "cash"},
+	}, doc)
+
+	doc, err = filter("", nil, storage.IncludeDeleted)
+	require.NoError(t, err)
+	assert.Empty(t, doc)
+
+	_, err = filter("", []storage.Filter{{Field: "$where", Operator: "=", Value: "1"}}, storage.ExcludeDeleted)
+	assert.Error(t, err)
+	_, err = filter("", []storage.Filter{{Field: "type", Operator: "like", Value: "x"}}, storage.ExcludeDeleted)
+	assert.Error(t, err)
+}
@@ -0,0 +1,33 @@
+// Package postgres stores entities in PostgreSQL. It is the sqlstore
+// backend with the PostgreSQL dialect, so both share one implementation of
+// documents, queries, search, batches and the audit trail.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/johnayoung/finlib/pkg/storage/sqlstore"
+)
+
+var (
+	ErrNotFound        = sqlstore.ErrNotFound
+	ErrBatchRolledBack = sqlstore.ErrBatchRolledBack
+)
+
+// Store is a sqlstore.Store on a PostgreSQL database
+type Store = sqlstore.Store
+
+// Tx is a database transaction started by Store.BeginTransaction
+type Tx = sqlstore.Tx
+
+// NewStore creates a store on an open PostgreSQL database. Run Migrate
+// before first use.
+func NewStore(db *sql.DB) *Store {
+	return sqlstore.NewStore(db, sqlstore.Postgres)
+}
+
+// Migrate applies the store's migrations the database has not seen yet
+func Migrate(ctx context.Context, db *sql.DB) error {
+	return sqlstore.Migrate(ctx, db, sqlstore.Postgres)
+}
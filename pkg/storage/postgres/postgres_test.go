@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/sqlstore"
+	"github.com/johnayoung/finlib/pkg/storage/sqlstore/sqltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time checks of the interfaces the store implements
+var (
+	_ storage.AuditableRepository  = (*Store)(nil)
+	_ storage.AuditLogRepository   = (*Store)(nil)
+	_ storage.BatchRepository      = (*Store)(nil)
+	_ storage.SearchableRepository = (*Store)(nil)
+	_ storage.TransactionManager   = (*Store)(nil)
+	_ reporting.ReportStorage      = (*Store)(nil)
+)
+
+func TestNewStore(t *testing.T) {
+	assert.NotNil(t, NewStore(nil))
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, fake := sqltest.Open()
+	require.NoError(t, Migrate(ctx, db))
+	assert.Len(t, fake.Rows("schema_migrations"), len(sqlstore.Migrations))
+
+	// Statements use PostgreSQL types and placeholders
+	statements := strings.Join(fake.Statements(), "\n")
+	assert.Contains(t, statements, "recorded_at TIMESTAMPTZ NOT NULL")
+	assert.Contains(t, statements, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)")
+
+	require.NoError(t, Migrate(ctx, db))
+	assert.Len(t, fake.Rows("schema_migrations"), len(sqlstore.Migrations))
+}
+
+func TestBatchExecute(t *testing.T) {
+	ctx := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice"})
+	db, fake := sqltest.Open()
+	require.NoError(t, Migrate(ctx, db))
+	store := NewStore(db)
+
+	cash := &account.Account{ID: "cash", Code: "1000", Name: "Cash", Type: account.Asset}
+	require.NoError(t, store.Create(ctx, cash))
+
+	// The duplicate fails after the other items were written, and takes
+	// them down with it
+	rolledBack := fake.Rollbacks()
+	update := &account.Account{ID: "cash", Code: "1000", Name: "Petty cash", Type: account.Asset, Version: 1}
+	results := store.BatchExecute(ctx, []storage.BatchItem{
+		{Operation: storage.BatchCreate, Entity: &account.Account{ID: "loan", Code: "2000", Name: "Loan", Type: account.Liability}},
+		{Operation: storage.BatchUpdate, Entity: update},
+		{Operation: storage.BatchCreate, Entity: &account.Account{ID: "cash", Code: "1001", Name: "Duplicate", Type: account.Asset}},
+	})
+	assert.ErrorIs(t, results[0].Error, ErrBatchRolledBack)
+	assert.ErrorIs(t, results[1].Error, ErrBatchRolledBack)
+	assert.ErrorIs(t, results[2].Error, storage.ErrAlreadyExists)
+	assert.Equal(t, int64(1), update.Version, "versions are restored")
+	assert.Equal(t, rolledBack+1, fake.Rollbacks())
+	assert.ErrorIs(t, store.Read(ctx, "loan", &account.Account{}), ErrNotFound)
+	var read account.Account
+	require.NoError(t, store.Read(ctx, "cash", &read))
+	assert.Equal(t, "Cash", read.Name)
+	entries, err := store.QueryAudit(ctx, storage.AuditQuery{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// A database error part way also rolls the batch back
+	fake.FailOn("INSERT INTO document_values", errors.New("connection reset"))
+	results = store.BatchExecute(ctx, []storage.BatchItem{
+		{Operation: storage.BatchDelete, ID: "cash"},
+		{Operation: storage.BatchCreate, Entity: &account.Account{ID: "loan", Code: "2000", Name: "Loan", Type: account.Liability}},
+	})
+	assert.ErrorIs(t, results[0].Error, ErrBatchRolledBack)
+	assert.ErrorContains(t, results[1].Error, "connection reset")
+	require.NoError(t, store.Read(ctx, "cash", &read))
+	fake.FailOn("INSERT INTO document_values", nil)
+
+	results = store.BatchExecute(ctx, []storage.BatchItem{
+		{Operation: storage.BatchCreate, Entity: &account.Account{ID: "loan", Code: "2000", Name: "Loan", Type: account.Liability}},
+		{Operation: storage.BatchUpdate, Entity: update},
+	})
+	for _, result := range results {
+		assert.True(t, result.Success)
+	}
+	require.NoError(t, store.Read(ctx, "cash", &read))
+	assert.Equal(t, "Petty cash", read.Name)
+	assert.Equal(t, int64(2), read.Version)
+
+	entries, err = store.QueryAudit(ctx, storage.AuditQuery{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.NoError(t, audit.VerifyChain(entries))
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchField is a document field searched by full-text queries. Matches
+// in fields with a higher weight rank a result higher.
+type SearchField struct {
+	// Dotted JSON path; paths through arrays search every element
+	Path   string
+	Weight float64
+}
+
+// Searchable is implemented by entities that support full-text search,
+// naming the fields searched
+type Searchable interface {
+	SearchFields() []SearchField
+}
+
+// SearchTerms splits a search query into lower-case words. Words are runs
+// of letters and digits, so "INV-1001" searches for "inv" and "1001".
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchScore returns the relevance of a document to the search terms:
+// each occurrence of a term counts the weight of the field it is in. A
+// document scores zero unless every term occurs in it; with no terms every
+// document scores zero and matches.
+func SearchScore(doc map[string]interface{}, terms []string, fields []SearchField) (float64, bool) {
+	if len(terms) == 0 {
+		return 0, true
+	}
+
+	counts := make(map[string]float64, len(terms))
+	for _, field := range fields {
+		for _, v := range FieldValues(doc, field.Path) {
+			text, ok := v.(string)
+			if !ok {
+				continue
+			}
+			for _, word := range SearchTerms(text) {
+				counts[word] += field.Weight
+			}
+		}
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		if counts[term] == 0 {
+			return 0, false
+		}
+		score += counts[term]
+	}
+	return score, true
+}
+
+// SearchHit is a document found by a search with its relevance score
+type SearchHit struct {
+	ID    string
+	Score float64
+	Doc   map[string]interface{}
+}
+
+// RankHits orders search hits by the given sort fields or, without any, by
+// descending score, breaking ties by ID
+func RankHits(hits []SearchHit, sorts []Sort) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		for _, s := range sorts {
+			if c := compareField(hits[i].Doc, hits[j].Doc, s.Field); c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		if len(sorts) == 0 && hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
+
+// compareField orders two documents by the first value at a field, those
+// without one first
+func compareField(a, b map[string]interface{}, field string) int {
+	va, vb := FieldValues(a, field), FieldValues(b, field)
+	switch {
+	case len(va) == 0 && len(vb) == 0:
+		return 0
+	case len(va) == 0:
+		return -1
+	case len(vb) == 0:
+		return 1
+	}
+	return CompareValues(va[0], vb[0])
+}
+
+// PageHits returns the hits on the requested page
+func PageHits(hits []SearchHit, p *Pagination) []SearchHit {
+	if p == nil {
+		return hits
+	}
+	if p.Offset >= int64(len(hits)) {
+		return hits[:0]
+	}
+	hits = hits[p.Offset:]
+	if p.Limit > 0 && p.Limit < int64(len(hits)) {
+		hits = hits[:p.Limit]
+	}
+	return hits
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDeleted is returned when reading or updating a soft-deleted entity
+var ErrDeleted = errors.New("entity is deleted")
+
+// DeletedMode selects how a query treats soft-deleted entities
+type DeletedMode string
+
+const (
+	// ExcludeDeleted leaves soft-deleted entities out
+	ExcludeDeleted DeletedMode = ""
+	// IncludeDeleted matches entities whether deleted or not
+	IncludeDeleted DeletedMode = "include"
+	// OnlyDeleted matches soft-deleted entities alone
+	OnlyDeleted DeletedMode = "only"
+)
+
+// Matches reports whether an entity that is or isn't deleted is selected
+func (m DeletedMode) Matches(deleted bool) bool {
+	switch m {
+	case IncludeDeleted:
+		return true
+	case OnlyDeleted:
+		return deleted
+	}
+	return !deleted
+}
+
+// SoftDeleteRepository keeps deleted entities so they stay available to
+// audits and can be restored. A soft-deleted entity can't be read or
+// updated and is left out of queries unless they ask for it; Delete still
+// removes an entity for good.
+type SoftDeleteRepository interface {
+	Repository
+
+	// SoftDelete marks an entity deleted, recording when and by whom
+	SoftDelete(ctx context.Context, id string) error
+
+	// Restore undoes a soft delete
+	Restore(ctx context.Context, id string) error
+}
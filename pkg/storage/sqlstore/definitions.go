@@ -0,0 +1,85 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/reporting"
+)
+
+// SaveDefinition implements reporting.ReportStorage.SaveDefinition,
+// replacing any definition with the same ID
+func (s *Store) SaveDefinition(ctx context.Context, def *reporting.ReportDefinition) error {
+	if def == nil || def.ID == "" {
+		return fmt.Errorf("report definition ID cannot be empty")
+	}
+	data, err := json.Marshal(def)
+	if err != nil {
+		return fmt.Errorf("failed to encode report definition: %w", err)
+	}
+	if _, err := s.executor(ctx).ExecContext(ctx,
+		s.dialect.Upsert("report_definitions", []string{"id", "data", "updated_at"}, 1),
+		def.ID, string(data), time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to store report definition: %w", err)
+	}
+	return nil
+}
+
+// LoadDefinition implements reporting.ReportStorage.LoadDefinition
+func (s *Store) LoadDefinition(ctx context.Context, id string) (*reporting.ReportDefinition, error) {
+	var data string
+	err := s.executor(ctx).QueryRowContext(ctx, "SELECT data FROM report_definitions WHERE id = "+s.dialect.Placeholder(1), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading report definition: %w", err)
+	}
+	def := &reporting.ReportDefinition{}
+	if err := json.Unmarshal([]byte(data), def); err != nil {
+		return nil, fmt.Errorf("failed to decode report definition: %w", err)
+	}
+	return def, nil
+}
+
+// ListDefinitions implements reporting.ReportStorage.ListDefinitions
+func (s *Store) ListDefinitions(ctx context.Context) ([]*reporting.ReportDefinition, error) {
+	rows, err := s.executor(ctx).QueryContext(ctx, "SELECT data FROM report_definitions ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error querying report definitions: %w", err)
+	}
+	defer rows.Close()
+
+	defs := make([]*reporting.ReportDefinition, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("error reading report definition: %w", err)
+		}
+		def := &reporting.ReportDefinition{}
+		if err := json.Unmarshal([]byte(data), def); err != nil {
+			return nil, fmt.Errorf("failed to decode report definition: %w", err)
+		}
+		defs = append(defs, def)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying report definitions: %w", err)
+	}
+	return defs, nil
+}
+
+// DeleteDefinition implements reporting.ReportStorage.DeleteDefinition
+func (s *Store) DeleteDefinition(ctx context.Context, id string) error {
+	result, err := s.executor(ctx).ExecContext(ctx, "DELETE FROM report_definitions WHERE id = "+s.dialect.Placeholder(1), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete report definition: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnType is a portable column type rendered by a dialect
+type ColumnType int
+
+const (
+	// KeyColumn holds identifiers and indexed values
+	KeyColumn ColumnType = iota
+	// TextColumn holds JSON documents of any length
+	TextColumn
+	// TimeColumn holds timestamps
+	TimeColumn
+	// IntColumn holds 64-bit integers
+	IntColumn
+)
+
+// Dialect describes the SQL differences between databases that the store
+// depends on
+type Dialect interface {
+	// Name identifies the dialect
+	Name() string
+
+	// Placeholder returns the placeholder of the nth (1-based) argument
+	Placeholder(n int) string
+
+	// Upsert returns a statement inserting a row, or updating its other
+	// columns when a row with the same keys exists. Arguments are the
+	// column values in order; keys must be a prefix of columns.
+	Upsert(table string, columns []string, keys int) string
+
+	// Paginate returns the clause following ORDER BY that limits the rows
+	// returned, given the placeholders of the limit and offset
+	Paginate(limit, offset string) string
+
+	// ColumnType returns the type name of a column
+	ColumnType(t ColumnType) string
+}
+
+// Postgres is the dialect of PostgreSQL, used by the postgres package
+var Postgres Dialect = postgresDialect{}
+
+// MySQL is the dialect of MySQL and MariaDB
+var MySQL Dialect = mysqlDialect{}
+
+// SQLServer is the dialect of Microsoft SQL Server
+var SQLServer Dialect = sqlServerDialect{}
+
+// SQLite is the dialect of SQLite
+var SQLite Dialect = sqliteDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string             { return "postgres" }
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (d postgresDialect) Upsert(table string, columns []string, keys int) string {
+	return onConflictUpsert(d, table, columns, keys)
+}
+
+func (postgresDialect) Paginate(limit, offset string) string {
+	return fmt.Sprintf(" LIMIT %s OFFSET %s", limit, offset)
+}
+
+func (postgresDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case KeyColumn, TextColumn:
+		return "TEXT"
+	case TimeColumn:
+		return "TIMESTAMPTZ"
+	default:
+		return "BIGINT"
+	}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string           { return "mysql" }
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+func (d mysqlDialect) Upsert(table string, columns []string, keys int) string {
+	updates := make([]string, 0, len(columns)-keys)
+	for _, c := range columns[keys:] {
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", c, c))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		table, strings.Join(columns, ", "), placeholders(d, 1, len(columns)), strings.Join(updates, ", "))
+}
+
+func (mysqlDialect) Paginate(limit, offset string) string {
+	return fmt.Sprintf(" LIMIT %s OFFSET %s", limit, offset)
+}
+
+func (mysqlDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case KeyColumn:
+		// Short enough for utf8mb4 index keys
+		return "VARCHAR(191)"
+	case TextColumn:
+		return "LONGTEXT"
+	case TimeColumn:
+		return "DATETIME(6)"
+	default:
+		return "BIGINT"
+	}
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string             { return "sqlserver" }
+func (sqlServerDialect) Placeholder(n int) string { return fmt.Sprintf("@p%d", n) }
+
+func (d sqlServerDialect) Upsert(table string, columns []string, keys int) string {
+	sources := make([]string, len(columns))
+	for i, c := range columns {
+		sources[i] = fmt.Sprintf("%s AS %s", d.Placeholder(i+1), c)
+	}
+	matches := make([]string, keys)
+	for i, c := range columns[:keys] {
+		matches[i] = fmt.Sprintf("target.%s = source.%s", c, c)
+	}
+	updates := make([]string, 0, len(columns)-keys)
+	for _, c := range columns[keys:] {
+		updates = append(updates, fmt.Sprintf("%s = source.%s", c, c))
+	}
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = "source." + c
+	}
+	return fmt.Sprintf("MERGE INTO %s AS target USING (SELECT %s) AS source ON %s"+
+		" WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);",
+		table, strings.Join(sources, ", "), strings.Join(matches, " AND "),
+		strings.Join(updates, ", "), strings.Join(columns, ", "), strings.Join(values, ", "))
+}
+
+func (sqlServerDialect) Paginate(limit, offset string) string {
+	return fmt.Sprintf(" OFFSET %s ROWS FETCH NEXT %s ROWS ONLY", offset, limit)
+}
+
+func (sqlServerDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case KeyColumn:
+		return "NVARCHAR(255)"
+	case TextColumn:
+		return "NVARCHAR(MAX)"
+	case TimeColumn:
+		return "DATETIME2"
+	default:
+		return "BIGINT"
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string           { return "sqlite" }
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (d sqliteDialect) Upsert(table string, columns []string, keys int) string {
+	return onConflictUpsert(d, table, columns, keys)
+}
+
+func (sqliteDialect) Paginate(limit, offset string) string {
+	return fmt.Sprintf(" LIMIT %s OFFSET %s", limit, offset)
+}
+
+func (sqliteDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case KeyColumn, TextColumn:
+		return "TEXT"
+	case TimeColumn:
+		return "TIMESTAMP"
+	default:
+		return "INTEGER"
+	}
+}
+
+// onConflictUpsert renders the INSERT ... ON CONFLICT upsert shared by
+// PostgreSQL and SQLite
+func onConflictUpsert(d Dialect, table string, columns []string, keys int) string {
+	updates := make([]string, 0, len(columns)-keys)
+	for _, c := range columns[keys:] {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", c, c))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		table, strings.Join(columns, ", "), placeholders(d, 1, len(columns)),
+		strings.Join(columns[:keys], ", "), strings.Join(updates, ", "))
+}
+
+// placeholders returns count comma-separated placeholders starting at the
+// nth argument
+func placeholders(d Dialect, n, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = d.Placeholder(n + i)
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxIndexedValue is the length indexed values are truncated to, so they
+// fit a KeyColumn on every dialect. Filters on longer values do not match.
+const MaxIndexedValue = 191
+
+// indexTime is the sortable form of indexed timestamps: UTC with a fixed
+// number of fractional digits
+const indexTime = "2006-01-02T15:04:05.000000000Z"
+
+// indexedValue is one row of the value index
+type indexedValue struct {
+	path  string
+	value string
+}
+
+// flatten lists the scalar values of a JSON document by dotted path. Array
+// elements share their array's path, so a filter on entries.account_id
+// matches any entry.
+func flatten(data []byte) ([]indexedValue, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := make([]indexedValue, 0)
+	seen := make(map[indexedValue]bool)
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				child := k
+				if path != "" {
+					child = path + "." + k
+				}
+				walk(child, t[k])
+			}
+		case []interface{}:
+			for _, item := range t {
+				walk(path, item)
+			}
+		case nil:
+		default:
+			if path == "" {
+				return
+			}
+			iv := indexedValue{path: path, value: indexValue(t)}
+			if !seen[iv] {
+				seen[iv] = true
+				values = append(values, iv)
+			}
+		}
+	}
+	walk("", doc)
+	return values, nil
+}
+
+// indexValue renders a scalar the way it is stored in the value index.
+// Timestamps are normalized so they compare in time order.
+func indexValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			s = ts.UTC().Format(indexTime)
+		}
+	case time.Time:
+		s = t.UTC().Format(indexTime)
+	case *time.Time:
+		if t != nil {
+			s = t.UTC().Format(indexTime)
+		}
+	case bool:
+		s = strconv.FormatBool(t)
+	case float64:
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.String:
+			// Typed strings such as account types
+			return indexValue(rv.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(rv.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(rv.Uint(), 10)
+		case reflect.Float32:
+			s = strconv.FormatFloat(rv.Float(), 'f', -1, 32)
+		default:
+			data, _ := json.Marshal(v)
+			var decoded interface{}
+			if json.Unmarshal(data, &decoded) == nil {
+				if _, scalar := decoded.(string); scalar {
+					return indexValue(decoded)
+				}
+			}
+			s = string(data)
+		}
+	}
+	return truncate(s)
+}
+
+// truncate cuts a value to MaxIndexedValue bytes on a rune boundary
+func truncate(s string) string {
+	if len(s) <= MaxIndexedValue {
+		return s
+	}
+	s = s[:MaxIndexedValue]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
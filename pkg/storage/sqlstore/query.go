@@ -0,0 +1,151 @@
+package sqlstore
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// fieldPattern matches valid dotted filter and sort fields
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// comparisons maps filter operators to SQL comparisons
+var comparisons = map[string]string{
+	"=":  "=",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+}
+
+// builder accumulates the text and positional arguments of a statement
+type builder struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+// arg adds an argument and returns its placeholder
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return b.dialect.Placeholder(len(b.args))
+}
+
+// where translates query filters into conditions on the documents table,
+// aliased d. Fields other than id are looked up in the value index.
+func (b *builder) where(filters []storage.Filter) (string, error) {
+	conditions := make([]string, 0, len(filters))
+	for _, f := range filters {
+		condition, err := b.condition(f)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, condition)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+func (b *builder) condition(f storage.Filter) (string, error) {
+	if !fieldPattern.MatchString(f.Field) {
+		return "", fmt.Errorf("invalid field %q", f.Field)
+	}
+	op := strings.ToLower(f.Operator)
+
+	column := "v.value"
+	if f.Field == "id" {
+		column = "d.id"
+	}
+
+	var comparison string
+	negate := false
+	switch op {
+	case "in":
+		list := reflect.ValueOf(f.Value)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			list = reflect.ValueOf([]interface{}{f.Value})
+		}
+		if list.Len() == 0 {
+			return "1 = 0", nil
+		}
+		items := make([]string, list.Len())
+		for i := range items {
+			items[i] = b.arg(b.value(f.Field, list.Index(i).Interface()))
+		}
+		comparison = fmt.Sprintf("%s IN (%s)", column, strings.Join(items, ", "))
+	case "!=":
+		comparison = fmt.Sprintf("%s = %s", column, b.arg(b.value(f.Field, f.Value)))
+		negate = true
+	default:
+		cmp, ok := comparisons[op]
+		if !ok {
+			return "", fmt.Errorf("unsupported operator %q on field %s", f.Operator, f.Field)
+		}
+		comparison = fmt.Sprintf("%s %s %s", column, cmp, b.arg(b.value(f.Field, f.Value)))
+	}
+
+	if f.Field == "id" {
+		if negate {
+			return "NOT (" + comparison + ")", nil
+		}
+		return comparison, nil
+	}
+
+	// The path placeholder comes after the value placeholders in the text,
+	// so it is added last to keep positional dialects in order
+	condition := fmt.Sprintf("EXISTS (SELECT 1 FROM document_values v WHERE v.entity_type = d.entity_type"+
+		" AND v.id = d.id AND %s AND v.path = %s)", comparison, b.arg(f.Field))
+	if negate {
+		condition = "NOT " + condition
+	}
+	return condition, nil
+}
+
+// value converts a filter value to its indexed form; IDs are compared as
+// given
+func (b *builder) value(field string, v interface{}) string {
+	if field == "id" {
+		return fmt.Sprint(v)
+	}
+	return indexValue(v)
+}
+
+// orderBy translates sort fields into an ORDER BY clause, falling back to
+// ID order so pages are stable
+func (b *builder) orderBy(sorts []storage.Sort) (string, error) {
+	terms := make([]string, 0, len(sorts)+1)
+	for _, s := range sorts {
+		if !fieldPattern.MatchString(s.Field) {
+			return "", fmt.Errorf("invalid field %q", s.Field)
+		}
+		term := "d.id"
+		if s.Field != "id" {
+			term = fmt.Sprintf("(SELECT MIN(v.value) FROM document_values v WHERE v.entity_type = d.entity_type"+
+				" AND v.id = d.id AND v.path = %s)", b.arg(s.Field))
+		}
+		if s.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	terms = append(terms, "d.id")
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// limit translates pagination into the dialect's paging clause
+func (b *builder) limit(p *storage.Pagination) string {
+	if p == nil || (p.Limit <= 0 && p.Offset <= 0) {
+		return ""
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	offset := p.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return b.dialect.Paginate(b.arg(limit), b.arg(offset))
+}
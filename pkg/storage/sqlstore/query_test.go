@@ -0,0 +1,132 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Compile-time checks of the interfaces the store implements
+var (
+	_ storage.AuditableRepository  = (*Store)(nil)
+	_ storage.AuditLogRepository   = (*Store)(nil)
+	_ storage.BatchRepository      = (*Store)(nil)
+	_ storage.SearchableRepository = (*Store)(nil)
+	_ storage.SoftDeleteRepository = (*Store)(nil)
+	_ storage.TransactionManager   = (*Store)(nil)
+	_ reporting.ReportStorage      = (*Store)(nil)
+)
+
+func TestDialects(t *testing.T) {
+	columns := []string{"id", "data", "updated_at"}
+
+	assert.Equal(t, "INSERT INTO report_definitions (id, data, updated_at) VALUES ($1, $2, $3)"+
+		" ON CONFLICT (id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at",
+		Postgres.Upsert("report_definitions", columns, 1))
+	assert.Equal(t, "INSERT INTO report_definitions (id, data, updated_at) VALUES (?, ?, ?)"+
+		" ON DUPLICATE KEY UPDATE data = VALUES(data), updated_at = VALUES(updated_at)",
+		MySQL.Upsert("report_definitions", columns, 1))
+	assert.Equal(t, "MERGE INTO report_definitions AS target USING (SELECT @p1 AS id, @p2 AS data, @p3 AS updated_at) AS source"+
+		" ON target.id = source.id WHEN MATCHED THEN UPDATE SET data = source.data, updated_at = source.updated_at"+
+		" WHEN NOT MATCHED THEN INSERT (id, data, updated_at) VALUES (source.id, source.data, source.updated_at);",
+		SQLServer.Upsert("report_definitions", columns, 1))
+	assert.Equal(t, "INSERT INTO report_definitions (id, data, updated_at) VALUES (?, ?, ?)"+
+		" ON CONFLICT (id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at",
+		SQLite.Upsert("report_definitions", columns, 1))
+
+	assert.Equal(t, " OFFSET @p2 ROWS FETCH NEXT @p1 ROWS ONLY", SQLServer.Paginate("@p1", "@p2"))
+	assert.Equal(t, "CREATE TABLE audit_head (id BIGINT NOT NULL, seq BIGINT NOT NULL, PRIMARY KEY (id))",
+		createTable(MySQL, "audit_head", "PRIMARY KEY (id)", "id", IntColumn, "seq", IntColumn))
+
+	for _, m := range Migrations {
+		assert.NotEmpty(t, m.Statements(SQLServer), m.Name)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	values, err := flatten([]byte(`{
+		"id": "TX1",
+		"date": "2024-01-15T10:00:00+02:00",
+		"entries": [
+			{"account_id": "cash", "amount": {"amount": "100", "currency": "USD"}},
+			{"account_id": "revenue", "amount": {"amount": "100", "currency": "USD"}}
+		],
+		"posted": true,
+		"reversal_id": null
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []indexedValue{
+		{"date", "2024-01-15T08:00:00.000000000Z"},
+		{"entries.account_id", "cash"},
+		{"entries.amount.amount", "100"},
+		{"entries.amount.currency", "USD"},
+		{"entries.account_id", "revenue"},
+		{"id", "TX1"},
+		{"posted", "true"},
+	}, values)
+
+	long := make([]byte, MaxIndexedValue+10)
+	for i := range long {
+		long[i] = 'x'
+	}
+	assert.Len(t, indexValue(string(long)), MaxIndexedValue)
+}
+
+func TestQueryBuilder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("EST", -5*3600))
+
+	t.Run("Positional", func(t *testing.T) {
+		b := &builder{dialect: MySQL}
+		statement, err := selectStatement(b, "d.data", "*transaction.Transaction", []storage.Filter{
+			{Field: "entries.account_id", Operator: "=", Value: "cash"},
+			{Field: "date", Operator: ">=", Value: start},
+			{Field: "type", Operator: "in", Value: []account.AccountType{account.Asset, account.Liability}},
+			{Field: "status", Operator: "!=", Value: "VOID"},
+		}, storage.ExcludeDeleted)
+		require.NoError(t, err)
+		order, err := b.orderBy([]storage.Sort{{Field: "date", Desc: true}})
+		require.NoError(t, err)
+		statement += order + b.limit(&storage.Pagination{Offset: 10})
+
+		exists := "EXISTS (SELECT 1 FROM document_values v WHERE v.entity_type = d.entity_type AND v.id = d.id AND "
+		assert.Equal(t, "SELECT d.data FROM documents d WHERE 1 = 1 AND d.entity_type = ? AND d.deleted_at IS NULL"+
+			" AND "+exists+"v.value = ? AND v.path = ?)"+
+			" AND "+exists+"v.value >= ? AND v.path = ?)"+
+			" AND "+exists+"v.value IN (?, ?) AND v.path = ?)"+
+			" AND NOT "+exists+"v.value = ? AND v.path = ?)"+
+			" ORDER BY (SELECT MIN(v.value) FROM document_values v WHERE v.entity_type = d.entity_type AND v.id = d.id AND v.path = ?) DESC, d.id"+
+			" LIMIT ? OFFSET ?", statement)
+		assert.Equal(t, []interface{}{
+			"*transaction.Transaction",
+			"cash", "entries.account_id",
+			"2024-01-01T05:00:00.000000000Z", "date",
+			"ASSET", "LIABILITY", "type",
+			"VOID", "status",
+			"date",
+			int64(9223372036854775807), int64(10),
+		}, b.args)
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		b := &builder{dialect: SQLServer}
+		where, err := b.where([]storage.Filter{{Field: "id", Operator: "in", Value: []string{"a", "b"}}})
+		require.NoError(t, err)
+		assert.Equal(t, "d.id IN (@p1, @p2)", where)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		b := &builder{dialect: Postgres}
+		_, err := b.where([]storage.Filter{{Field: "type) OR (1=1", Operator: "=", Value: "x"}})
+		assert.Error(t, err)
+		_, err = b.where([]storage.Filter{{Field: "type", Operator: "like", Value: "x"}})
+		assert.Error(t, err)
+		_, err = b.orderBy([]storage.Sort{{Field: "code;"}})
+		assert.Error(t, err)
+	})
+}
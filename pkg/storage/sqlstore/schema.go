@@ -0,0 +1,170 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Migration is a versioned schema change. Statements are rendered for a
+// dialect and run one at a time, since not every driver accepts several
+// statements per call.
+type Migration struct {
+	Version    int
+	Name       string
+	Statements func(d Dialect) []string
+}
+
+// Migrations lists the schema changes of the store in the order they are
+// applied
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_documents",
+		Statements: func(d Dialect) []string {
+			return []string{
+				createTable(d, "documents", "PRIMARY KEY (entity_type, id)",
+					"entity_type", KeyColumn, "id", KeyColumn, "version", IntColumn, "data", TextColumn,
+					"created_at", TimeColumn, "updated_at", TimeColumn, "updated_by", KeyColumn),
+				"CREATE INDEX documents_id_idx ON documents (id)",
+				createTable(d, "document_values", "",
+					"entity_type", KeyColumn, "id", KeyColumn, "path", KeyColumn, "value", KeyColumn),
+				"CREATE INDEX document_values_lookup_idx ON document_values (entity_type, path, value)",
+				"CREATE INDEX document_values_document_idx ON document_values (entity_type, id)",
+			}
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_audit_entries",
+		Statements: func(d Dialect) []string {
+			return []string{
+				createTable(d, "audit_entries", "PRIMARY KEY (seq)",
+					"seq", IntColumn, "id", KeyColumn, "entity_type", KeyColumn, "entity_id", KeyColumn,
+					"operation", KeyColumn, "user_id", KeyColumn, "recorded_at", TimeColumn,
+					"previous_state", TextColumn, "new_state", TextColumn, "metadata", TextColumn,
+					"state_hash", KeyColumn, "previous_hash", KeyColumn, "hash", KeyColumn),
+				"CREATE INDEX audit_entries_entity_idx ON audit_entries (entity_id)",
+				// Writers update the single head row first, which serializes
+				// appends to the chain on every database
+				createTable(d, "audit_head", "PRIMARY KEY (id)", "id", IntColumn, "seq", IntColumn),
+				"INSERT INTO audit_head (id, seq) VALUES (1, 0)",
+			}
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_report_definitions",
+		Statements: func(d Dialect) []string {
+			return []string{
+				createTable(d, "report_definitions", "PRIMARY KEY (id)",
+					"id", KeyColumn, "data", TextColumn, "updated_at", TimeColumn),
+			}
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_audit_signatures",
+		Statements: func(d Dialect) []string {
+			return []string{addColumn(d, "audit_entries", "signature", KeyColumn)}
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_soft_deletes",
+		Statements: func(d Dialect) []string {
+			return []string{
+				addNullColumn(d, "documents", "deleted_at", TimeColumn),
+				addColumn(d, "documents", "deleted_by", KeyColumn),
+			}
+		},
+	},
+}
+
+// Migrate applies the migrations the database has not seen yet, each in its
+// own transaction, and records them in schema_migrations
+func Migrate(ctx context.Context, db *sql.DB, d Dialect) error {
+	applied := make(map[int]bool)
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		// The first run creates the table
+		if _, err := db.ExecContext(ctx, createTable(d, "schema_migrations", "PRIMARY KEY (version)",
+			"version", IntColumn, "name", KeyColumn)); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+	} else {
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				rows.Close()
+				return fmt.Errorf("error reading migrations: %w", err)
+			}
+			applied[version] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error reading migrations: %w", err)
+		}
+	}
+
+	for _, m := range Migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, d, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, d Dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	statements := append(m.Statements(d),
+		fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%s)", placeholders(d, 1, 2)))
+	for i, statement := range statements {
+		var args []interface{}
+		if i == len(statements)-1 {
+			args = []interface{}{m.Version, m.Name}
+		}
+		if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// createTable renders a CREATE TABLE statement from name and type pairs
+func createTable(d Dialect, table, constraint string, columns ...interface{}) string {
+	defs := make([]string, 0, len(columns)/2+1)
+	for i := 0; i+1 < len(columns); i += 2 {
+		defs = append(defs, fmt.Sprintf("%s %s NOT NULL", columns[i], d.ColumnType(columns[i+1].(ColumnType))))
+	}
+	if constraint != "" {
+		defs = append(defs, constraint)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(defs, ", "))
+}
+
+// addColumn renders an ALTER TABLE statement adding a column that defaults
+// to the empty string
+func addColumn(d Dialect, table, column string, t ColumnType) string {
+	return fmt.Sprintf("ALTER TABLE %s %s %s %s NOT NULL DEFAULT ''", table, addKeyword(d), column, d.ColumnType(t))
+}
+
+// addNullColumn renders an ALTER TABLE statement adding a nullable column
+func addNullColumn(d Dialect, table, column string, t ColumnType) string {
+	return fmt.Sprintf("ALTER TABLE %s %s %s %s NULL", table, addKeyword(d), column, d.ColumnType(t))
+}
+
+func addKeyword(d Dialect) string {
+	if d.Name() == "sqlserver" {
+		return "ADD"
+	}
+	return "ADD COLUMN"
+}
@@ -0,0 +1,115 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// Search implements SearchableRepository.Search. Full-text search differs
+// from one database to the next, so filters are applied in SQL and the
+// documents left are scored in Go by storage.SearchScore over the entity's
+// search fields. Every word of the query must occur; results are ordered by
+// relevance unless a sort is given. Results must be a pointer to a slice of
+// searchable entities or entity pointers.
+func (s *Store) Search(ctx context.Context, options storage.SearchOptions, results interface{}) error {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	hits, data, err := s.search(ctx, reflect.New(structType).Interface(), options)
+	if err != nil {
+		return err
+	}
+	hits = storage.PageHits(hits, options.Pagination)
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, len(hits))
+	for _, hit := range hits {
+		item := reflect.New(structType)
+		if err := json.Unmarshal(data[hit.ID], item.Interface()); err != nil {
+			return fmt.Errorf("failed to decode entity: %w", err)
+		}
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	slice.Elem().Set(list)
+	return nil
+}
+
+// SearchCount implements SearchableRepository.SearchCount. The options must
+// include an "entity_type" filter holding a sample of the searched entity,
+// such as &transaction.Transaction{}.
+func (s *Store) SearchCount(ctx context.Context, options storage.SearchOptions) (int64, error) {
+	var sample interface{}
+	for _, f := range options.Filters {
+		if f.Field == "entity_type" {
+			sample = f.Value
+		}
+	}
+	if sample == nil {
+		return 0, fmt.Errorf("search count needs an entity_type filter")
+	}
+	hits, _, err := s.search(ctx, sample, options)
+	return int64(len(hits)), err
+}
+
+// search returns the ranked hits of a search and the stored data of each
+// by ID
+func (s *Store) search(ctx context.Context, entity interface{}, options storage.SearchOptions) ([]storage.SearchHit, map[string][]byte, error) {
+	searchable, ok := entity.(storage.Searchable)
+	if !ok {
+		return nil, nil, fmt.Errorf("entity type %T is not searchable", entity)
+	}
+	filters := make([]storage.Filter, 0, len(options.Filters))
+	for _, f := range options.Filters {
+		if f.Field != "entity_type" {
+			filters = append(filters, f)
+		}
+	}
+
+	b := &builder{dialect: s.dialect}
+	statement, err := selectStatement(b, "d.id, d.data", entityType(entity), filters, storage.ExcludeDeleted)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := s.executor(ctx).QueryContext(ctx, statement, b.args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error searching entities: %w", err)
+	}
+	defer rows.Close()
+
+	terms := storage.SearchTerms(options.Query)
+	fields := searchable.SearchFields()
+	hits := make([]storage.SearchHit, 0)
+	data := make(map[string][]byte)
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, nil, fmt.Errorf("error reading entity: %w", err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode entity: %w", err)
+		}
+		if score, ok := storage.SearchScore(doc, terms, fields); ok {
+			hits = append(hits, storage.SearchHit{ID: id, Score: score, Doc: doc})
+			data[id] = []byte(raw)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error searching entities: %w", err)
+	}
+	storage.RankHits(hits, options.Sort)
+	return hits, data, nil
+}
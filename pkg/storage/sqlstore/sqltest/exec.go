@@ -0,0 +1,793 @@
+package sqltest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// table holds the rows of a table in insertion order
+type table struct {
+	columns  []string
+	key      []string
+	defaults map[string]driver.Value
+	rows     []row
+}
+
+type row map[string]driver.Value
+
+func (r row) copy() row {
+	c := make(row, len(r))
+	for k, v := range r {
+		c[k] = v
+	}
+	return c
+}
+
+func (t *table) copy() *table {
+	c := &table{columns: t.columns, key: t.key, defaults: t.defaults, rows: make([]row, len(t.rows))}
+	for i, r := range t.rows {
+		c.rows[i] = r.copy()
+	}
+	return c
+}
+
+func (t *table) hasColumn(name string) bool {
+	for _, c := range t.columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func copyTables(tables map[string]*table) map[string]*table {
+	c := make(map[string]*table, len(tables))
+	for name, t := range tables {
+		c[name] = t.copy()
+	}
+	return c
+}
+
+// result is the outcome of a statement
+type result struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// env is what statements run against
+type env struct {
+	tables map[string]*table
+	args   []driver.Value
+}
+
+func (e *env) table(name string) (*table, error) {
+	t, ok := e.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("sqltest: no such table: %s", name)
+	}
+	return t, nil
+}
+
+// scope binds a row to a table name or alias while expressions are
+// evaluated. Outer scopes serve correlated subqueries.
+type scope struct {
+	name  string
+	row   row
+	outer *scope
+}
+
+type statement interface {
+	exec(e *env) (*result, error)
+}
+
+type noop struct{}
+
+func (noop) exec(e *env) (*result, error) {
+	return &result{}, nil
+}
+
+type createTable struct {
+	name    string
+	columns []string
+	key     []string
+}
+
+func (s *createTable) exec(e *env) (*result, error) {
+	if _, ok := e.tables[s.name]; ok {
+		return nil, fmt.Errorf("sqltest: table %s already exists", s.name)
+	}
+	e.tables[s.name] = &table{columns: s.columns, key: s.key, defaults: make(map[string]driver.Value)}
+	return &result{}, nil
+}
+
+type addColumn struct {
+	table  string
+	column string
+	value  driver.Value
+}
+
+func (s *addColumn) exec(e *env) (*result, error) {
+	t, err := e.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+	if t.hasColumn(s.column) {
+		return nil, fmt.Errorf("sqltest: column %s already exists", s.column)
+	}
+	t.columns = append(append([]string(nil), t.columns...), s.column)
+	defaults := make(map[string]driver.Value, len(t.defaults)+1)
+	for k, v := range t.defaults {
+		defaults[k] = v
+	}
+	defaults[s.column] = s.value
+	t.defaults = defaults
+	for _, r := range t.rows {
+		r[s.column] = s.value
+	}
+	return &result{}, nil
+}
+
+type assignment struct {
+	column string
+	value  expr
+}
+
+type insert struct {
+	table   string
+	columns []string
+	values  []expr
+	// Assignments of an ON CONFLICT DO UPDATE clause
+	upsert []assignment
+}
+
+func (s *insert) exec(e *env) (*result, error) {
+	t, err := e.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+	r := make(row, len(t.columns))
+	for _, c := range t.columns {
+		r[c] = t.defaults[c]
+	}
+	for i, c := range s.columns {
+		if !t.hasColumn(c) {
+			return nil, fmt.Errorf("sqltest: no such column: %s", c)
+		}
+		v, err := s.values[i].eval(e, nil)
+		if err != nil {
+			return nil, err
+		}
+		r[c] = v
+	}
+
+	if existing := t.find(r); existing != nil {
+		if s.upsert == nil {
+			return nil, fmt.Errorf("%w in %s", ErrDuplicateKey, s.table)
+		}
+		sc := &scope{name: s.table, row: existing, outer: &scope{name: "excluded", row: r}}
+		for _, set := range s.upsert {
+			v, err := set.value.eval(e, sc)
+			if err != nil {
+				return nil, err
+			}
+			existing[set.column] = v
+		}
+		return &result{affected: 1}, nil
+	}
+	t.rows = append(t.rows, r)
+	return &result{affected: 1}, nil
+}
+
+// find returns the row with the same primary key as r
+func (t *table) find(r row) row {
+	if len(t.key) == 0 {
+		return nil
+	}
+	for _, existing := range t.rows {
+		same := true
+		for _, k := range t.key {
+			if c, ok := compare(existing[k], r[k]); !ok || c != 0 {
+				same = false
+				break
+			}
+		}
+		if same {
+			return existing
+		}
+	}
+	return nil
+}
+
+type update struct {
+	table string
+	sets  []assignment
+	where expr
+}
+
+func (s *update) exec(e *env) (*result, error) {
+	t, err := e.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+	res := &result{}
+	for _, r := range t.rows {
+		sc := &scope{name: s.table, row: r}
+		if ok, err := matches(s.where, e, sc); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		// Every assignment sees the row as it was
+		values := make([]driver.Value, len(s.sets))
+		for i, set := range s.sets {
+			if values[i], err = set.value.eval(e, sc); err != nil {
+				return nil, err
+			}
+		}
+		for i, set := range s.sets {
+			r[set.column] = values[i]
+		}
+		res.affected++
+	}
+	return res, nil
+}
+
+type deleteRows struct {
+	table string
+	where expr
+}
+
+func (s *deleteRows) exec(e *env) (*result, error) {
+	t, err := e.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+	res := &result{}
+	kept := make([]row, 0, len(t.rows))
+	for _, r := range t.rows {
+		ok, err := matches(s.where, e, &scope{name: s.table, row: r})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res.affected++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.rows = kept
+	return res, nil
+}
+
+type orderTerm struct {
+	value expr
+	desc  bool
+}
+
+type selectRows struct {
+	items  []expr
+	table  string
+	alias  string
+	where  expr
+	order  []orderTerm
+	limit  expr
+	offset expr
+}
+
+func (s *selectRows) exec(e *env) (*result, error) {
+	return s.run(e, nil)
+}
+
+// run selects rows, with outer as the scope of a correlated subquery
+func (s *selectRows) run(e *env, outer *scope) (*result, error) {
+	t, err := e.table(s.table)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make([]*scope, 0)
+	for _, r := range t.rows {
+		sc := &scope{name: s.alias, row: r, outer: outer}
+		ok, err := matches(s.where, e, sc)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			scopes = append(scopes, sc)
+		}
+	}
+
+	res := &result{columns: make([]string, len(s.items))}
+	for i, item := range s.items {
+		res.columns[i] = item.name()
+	}
+
+	aggregated := false
+	for _, item := range s.items {
+		if _, ok := item.(aggregate); ok {
+			aggregated = true
+		}
+	}
+	if aggregated {
+		values := make([]driver.Value, len(s.items))
+		for i, item := range s.items {
+			fn, ok := item.(aggregate)
+			if !ok {
+				return nil, fmt.Errorf("sqltest: cannot mix aggregates and columns without GROUP BY")
+			}
+			if values[i], err = fn.over(e, scopes); err != nil {
+				return nil, err
+			}
+		}
+		res.rows = [][]driver.Value{values}
+		return res, nil
+	}
+
+	if len(s.order) > 0 {
+		keys := make([][]driver.Value, len(scopes))
+		for i, sc := range scopes {
+			keys[i] = make([]driver.Value, len(s.order))
+			for j, term := range s.order {
+				if keys[i][j], err = term.value.eval(e, sc); err != nil {
+					return nil, err
+				}
+			}
+		}
+		index := make([]int, len(scopes))
+		for i := range index {
+			index[i] = i
+		}
+		sort.SliceStable(index, func(a, b int) bool {
+			for j, term := range s.order {
+				c := order(keys[index[a]][j], keys[index[b]][j])
+				if c == 0 {
+					continue
+				}
+				if term.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		sorted := make([]*scope, len(scopes))
+		for i, n := range index {
+			sorted[i] = scopes[n]
+		}
+		scopes = sorted
+	}
+
+	if s.offset != nil {
+		n, err := count(s.offset, e)
+		if err != nil {
+			return nil, err
+		}
+		scopes = scopes[min(n, int64(len(scopes))):]
+	}
+	if s.limit != nil {
+		n, err := count(s.limit, e)
+		if err != nil {
+			return nil, err
+		}
+		scopes = scopes[:min(n, int64(len(scopes)))]
+	}
+
+	for _, sc := range scopes {
+		values := make([]driver.Value, len(s.items))
+		for i, item := range s.items {
+			if values[i], err = item.eval(e, sc); err != nil {
+				return nil, err
+			}
+		}
+		res.rows = append(res.rows, values)
+	}
+	return res, nil
+}
+
+// count evaluates a LIMIT or OFFSET
+func count(x expr, e *env) (int64, error) {
+	v, err := x.eval(e, nil)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("sqltest: bad row count %v", v)
+	}
+	return n, nil
+}
+
+// matches reports whether a row satisfies a condition; no condition
+// matches every row
+func matches(where expr, e *env, sc *scope) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	v, err := where.eval(e, sc)
+	if err != nil {
+		return false, err
+	}
+	return v == true, nil
+}
+
+// expr is an expression evaluated against a row. Conditions evaluate to
+// true, false or nil for unknown.
+type expr interface {
+	eval(e *env, sc *scope) (driver.Value, error)
+	// name is the column name the expression gets in a result
+	name() string
+}
+
+type literal struct {
+	value driver.Value
+}
+
+func (l literal) eval(*env, *scope) (driver.Value, error) { return l.value, nil }
+func (l literal) name() string                            { return fmt.Sprint(l.value) }
+
+type param int
+
+func (p param) eval(e *env, _ *scope) (driver.Value, error) {
+	if int(p) >= len(e.args) {
+		return nil, fmt.Errorf("sqltest: missing argument %d", int(p)+1)
+	}
+	return e.args[p], nil
+}
+
+func (p param) name() string { return fmt.Sprintf("$%d", int(p)+1) }
+
+type star struct{}
+
+func (star) eval(*env, *scope) (driver.Value, error) {
+	return nil, fmt.Errorf("sqltest: * is only supported in COUNT(*)")
+}
+
+func (star) name() string { return "*" }
+
+type columnRef struct {
+	qualifier string
+	column    string
+}
+
+func (c columnRef) name() string { return c.column }
+
+func (c columnRef) eval(_ *env, sc *scope) (driver.Value, error) {
+	for ; sc != nil; sc = sc.outer {
+		if c.qualifier != "" && c.qualifier != sc.name {
+			continue
+		}
+		if v, ok := sc.row[c.column]; ok {
+			return v, nil
+		}
+		if c.qualifier != "" {
+			break
+		}
+	}
+	if c.qualifier != "" {
+		return nil, fmt.Errorf("sqltest: no such column: %s.%s", c.qualifier, c.column)
+	}
+	return nil, fmt.Errorf("sqltest: no such column: %s", c.column)
+}
+
+type arithmetic struct {
+	op          byte
+	left, right expr
+}
+
+func (a arithmetic) name() string { return "?column?" }
+
+func (a arithmetic) eval(e *env, sc *scope) (driver.Value, error) {
+	l, err := a.left.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	r, err := a.right.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	x, ok1 := l.(int64)
+	y, ok2 := r.(int64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("sqltest: arithmetic on %T and %T", l, r)
+	}
+	if a.op == '-' {
+		return x - y, nil
+	}
+	return x + y, nil
+}
+
+type comparison struct {
+	op          string
+	left, right expr
+}
+
+func (c comparison) name() string { return "?column?" }
+
+func (c comparison) eval(e *env, sc *scope) (driver.Value, error) {
+	l, err := c.left.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.right.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := compare(l, r)
+	if !ok {
+		return nil, nil
+	}
+	switch c.op {
+	case "=":
+		return n == 0, nil
+	case "<>", "!=":
+		return n != 0, nil
+	case "<":
+		return n < 0, nil
+	case "<=":
+		return n <= 0, nil
+	case ">":
+		return n > 0, nil
+	default:
+		return n >= 0, nil
+	}
+}
+
+type logical struct {
+	or          bool
+	left, right expr
+}
+
+func (l logical) name() string { return "?column?" }
+
+func (l logical) eval(e *env, sc *scope) (driver.Value, error) {
+	x, err := l.left.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	if l.or && x == true {
+		return true, nil
+	}
+	if !l.or && x == false {
+		return false, nil
+	}
+	y, err := l.right.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case x == nil || y == nil:
+		if l.or && y == true {
+			return true, nil
+		}
+		if !l.or && y == false {
+			return false, nil
+		}
+		return nil, nil
+	default:
+		return y, nil
+	}
+}
+
+type not struct {
+	value expr
+}
+
+func (n not) name() string { return "?column?" }
+
+func (n not) eval(e *env, sc *scope) (driver.Value, error) {
+	v, err := n.value.eval(e, sc)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("sqltest: NOT of %T", v)
+	}
+	return !b, nil
+}
+
+type isNull struct {
+	value expr
+	not   bool
+}
+
+func (n isNull) name() string { return "?column?" }
+
+func (n isNull) eval(e *env, sc *scope) (driver.Value, error) {
+	v, err := n.value.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != n.not, nil
+}
+
+type in struct {
+	value expr
+	list  []expr
+}
+
+func (n in) name() string { return "?column?" }
+
+func (n in) eval(e *env, sc *scope) (driver.Value, error) {
+	v, err := n.value.eval(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range n.list {
+		x, err := item.eval(e, sc)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := compare(v, x); ok && c == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type exists struct {
+	query *selectRows
+}
+
+func (x exists) name() string { return "exists" }
+
+func (x exists) eval(e *env, sc *scope) (driver.Value, error) {
+	res, err := x.query.run(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	return len(res.rows) > 0, nil
+}
+
+// subquery is a scalar subquery
+type subquery struct {
+	query *selectRows
+}
+
+func (q subquery) name() string { return "?column?" }
+
+func (q subquery) eval(e *env, sc *scope) (driver.Value, error) {
+	res, err := q.query.run(e, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(res.rows) == 0:
+		return nil, nil
+	case len(res.rows) > 1 || len(res.rows[0]) != 1:
+		return nil, fmt.Errorf("sqltest: subquery returned more than one value")
+	}
+	return res.rows[0][0], nil
+}
+
+type aggregate struct {
+	fn  string
+	arg expr
+}
+
+func (a aggregate) name() string { return strings.ToLower(a.fn) }
+
+func (a aggregate) eval(*env, *scope) (driver.Value, error) {
+	return nil, fmt.Errorf("sqltest: %s outside a select list", a.fn)
+}
+
+// over computes the aggregate over the selected rows
+func (a aggregate) over(e *env, scopes []*scope) (driver.Value, error) {
+	if a.fn == "COUNT" {
+		if _, ok := a.arg.(star); ok {
+			return int64(len(scopes)), nil
+		}
+	}
+	var acc driver.Value
+	n := int64(0)
+	for _, sc := range scopes {
+		v, err := a.arg.eval(e, sc)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		n++
+		switch {
+		case acc == nil:
+			acc = v
+		case a.fn == "MIN" && order(v, acc) < 0:
+			acc = v
+		case a.fn == "MAX" && order(v, acc) > 0:
+			acc = v
+		}
+	}
+	if a.fn == "COUNT" {
+		return n, nil
+	}
+	return acc, nil
+}
+
+// compare orders two values of compatible types. It reports false when
+// either is NULL, which SQL comparisons treat as unknown.
+func compare(a, b driver.Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := a.([]byte); ok {
+		a = string(x)
+	}
+	if y, ok := b.([]byte); ok {
+		b = string(y)
+	}
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		case float64:
+			return sign(float64(x) - y), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return sign(x - float64(y)), true
+		case float64:
+			return sign(x - y), true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case !x:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	}
+	// Values of different types compare by their text
+	return bytes.Compare([]byte(fmt.Sprint(a)), []byte(fmt.Sprint(b))), true
+}
+
+// order sorts values with NULL first
+func order(a, b driver.Value) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compare(a, b)
+	return c
+}
+
+func sign(f float64) int {
+	switch {
+	case f < 0:
+		return -1
+	case f > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,771 @@
+package sqltest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	identToken tokenKind = iota
+	numberToken
+	stringToken
+	paramToken
+	symbolToken
+	endToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// Index of the argument a placeholder refers to
+	param int
+}
+
+// tokenize splits a statement into tokens, numbering placeholders
+func tokenize(query string) ([]token, error) {
+	tokens := make([]token, 0)
+	next := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isLetter(c):
+			j := i
+			for j < len(query) && (isLetter(query[j]) || isDigit(query[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: identToken, text: query[i:j]})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: numberToken, text: query[i:j]})
+			i = j
+		case c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(query) {
+					return nil, fmt.Errorf("sqltest: unterminated string in %q", query)
+				}
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(query[j])
+				j++
+			}
+			tokens = append(tokens, token{kind: stringToken, text: b.String()})
+			i = j + 1
+		case c == '?':
+			tokens = append(tokens, token{kind: paramToken, text: "?", param: next})
+			next++
+			i++
+		case c == '$' || c == '@':
+			j := i + 1
+			if c == '@' && j < len(query) && query[j] == 'p' {
+				j++
+			}
+			k := j
+			for k < len(query) && isDigit(query[k]) {
+				k++
+			}
+			n, err := strconv.Atoi(query[j:k])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("sqltest: bad placeholder in %q", query)
+			}
+			tokens = append(tokens, token{kind: paramToken, text: query[i:k], param: n - 1})
+			i = k
+		default:
+			if i+1 < len(query) {
+				switch two := query[i : i+2]; two {
+				case "<=", ">=", "<>", "!=":
+					tokens = append(tokens, token{kind: symbolToken, text: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("(),.*+-=<>;", rune(c)) {
+				return nil, fmt.Errorf("sqltest: unexpected %q in %q", c, query)
+			}
+			tokens = append(tokens, token{kind: symbolToken, text: string(c)})
+			i++
+		}
+	}
+	return append(tokens, token{kind: endToken}), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser reads a statement from its tokens
+type parser struct {
+	query  string
+	tokens []token
+	pos    int
+}
+
+// parse reads a statement and the number of arguments it takes
+func parse(query string) (statement, int, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	params := 0
+	for _, t := range tokens {
+		if t.kind == paramToken {
+			params = max(params, t.param+1)
+		}
+	}
+	p := &parser{query: query, tokens: tokens}
+	var stmt statement
+	switch {
+	case p.keyword("CREATE"):
+		stmt, err = p.create()
+	case p.keyword("ALTER"):
+		stmt, err = p.alter()
+	case p.keyword("INSERT"):
+		stmt, err = p.insert()
+	case p.keyword("UPDATE"):
+		stmt, err = p.update()
+	case p.keyword("DELETE"):
+		stmt, err = p.delete()
+	case p.peekKeyword("SELECT"):
+		stmt, err = p.selectStatement()
+	default:
+		err = p.errorf("unsupported statement")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	p.symbol(";")
+	if p.peek().kind != endToken {
+		return nil, 0, p.errorf("unexpected %q", p.peek().text)
+	}
+	return stmt, params, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("sqltest: %s in %q", fmt.Sprintf(format, args...), p.query)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) peekKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == identToken && strings.EqualFold(t.text, word)
+}
+
+// keyword consumes the next token if it is the keyword
+func (p *parser) keyword(word string) bool {
+	if p.peekKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is the symbol
+func (p *parser) symbol(s string) bool {
+	t := p.peek()
+	if t.kind == symbolToken && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.keyword(word) {
+		return p.errorf("expected %s", word)
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(s string) error {
+	if !p.symbol(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.kind != identToken {
+		return "", p.errorf("expected a name")
+	}
+	p.pos++
+	return strings.ToLower(t.text), nil
+}
+
+// identList reads a parenthesized list of names
+func (p *parser) identList() ([]string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.symbol(")") {
+			return names, nil
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// skipType skips a column type and its constraints, up to the comma or
+// parenthesis ending the definition
+func (p *parser) skipType() {
+	depth := 0
+	for {
+		t := p.peek()
+		if t.kind == endToken {
+			return
+		}
+		if t.kind == symbolToken {
+			switch t.text {
+			case "(":
+				depth++
+			case ")":
+				if depth == 0 {
+					return
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					return
+				}
+			}
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) create() (statement, error) {
+	if p.keyword("INDEX") || p.keyword("UNIQUE") {
+		// Indexes don't change results
+		p.pos = len(p.tokens) - 1
+		return noop{}, nil
+	}
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &createTable{name: name}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.keyword("PRIMARY") {
+			if err := p.expectKeyword("KEY"); err != nil {
+				return nil, err
+			}
+			if stmt.key, err = p.identList(); err != nil {
+				return nil, err
+			}
+		} else {
+			column, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, column)
+			p.skipType()
+		}
+		if p.symbol(")") {
+			return stmt, nil
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) alter() (statement, error) {
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	table, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("ADD"); err != nil {
+		return nil, err
+	}
+	p.keyword("COLUMN")
+	column, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &addColumn{table: table, column: column}
+	for p.peek().kind != endToken {
+		if p.keyword("DEFAULT") {
+			value, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			lit, ok := value.(literal)
+			if !ok {
+				return nil, p.errorf("defaults must be literals")
+			}
+			stmt.value = lit.value
+			continue
+		}
+		p.advance()
+	}
+	return stmt, nil
+}
+
+func (p *parser) insert() (statement, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	table, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &insert{table: table}
+	if stmt.columns, err = p.identList(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	if stmt.values, err = p.exprList(); err != nil {
+		return nil, err
+	}
+	if len(stmt.values) != len(stmt.columns) {
+		return nil, p.errorf("%d values for %d columns", len(stmt.values), len(stmt.columns))
+	}
+	if p.keyword("ON") {
+		if err := p.expectKeyword("CONFLICT"); err != nil {
+			return nil, err
+		}
+		if _, err := p.identList(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("DO"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("UPDATE"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("SET"); err != nil {
+			return nil, err
+		}
+		if stmt.upsert, err = p.assignments(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) update() (statement, error) {
+	table, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	stmt := &update{table: table}
+	if stmt.sets, err = p.assignments(); err != nil {
+		return nil, err
+	}
+	if p.keyword("WHERE") {
+		if stmt.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) assignments() ([]assignment, error) {
+	sets := make([]assignment, 0)
+	for {
+		column, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		value, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, assignment{column: column, value: value})
+		if !p.symbol(",") {
+			return sets, nil
+		}
+	}
+}
+
+func (p *parser) delete() (statement, error) {
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &deleteRows{table: table}
+	if p.keyword("WHERE") {
+		if stmt.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) selectStatement() (*selectRows, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt := &selectRows{}
+	for {
+		item, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		stmt.items = append(stmt.items, item)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	stmt.alias = stmt.table
+	if p.keyword("AS") || (p.peek().kind == identToken && !p.reserved()) {
+		if stmt.alias, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("WHERE") {
+		if stmt.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			value, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			term := orderTerm{value: value}
+			if p.keyword("DESC") {
+				term.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			stmt.order = append(stmt.order, term)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	// LIMIT n OFFSET m, or SQL Server's OFFSET m ROWS FETCH NEXT n ROWS ONLY
+	if p.keyword("LIMIT") {
+		if stmt.limit, err = p.primary(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("OFFSET") {
+		if stmt.offset, err = p.primary(); err != nil {
+			return nil, err
+		}
+		if p.keyword("ROWS") {
+			if err := p.expectKeyword("FETCH"); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("NEXT"); err != nil {
+				return nil, err
+			}
+			if stmt.limit, err = p.primary(); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("ROWS"); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("ONLY"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// reserved reports whether the next token is a keyword that may follow a
+// table name
+func (p *parser) reserved() bool {
+	for _, word := range []string{"WHERE", "ORDER", "LIMIT", "OFFSET"} {
+		if p.peekKeyword(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprList reads expressions up to a closing parenthesis
+func (p *parser) exprList() ([]expr, error) {
+	list := make([]expr, 0)
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if p.symbol(")") {
+			return list, nil
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) expr() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.keyword("NOT") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	}
+	return p.predicate()
+}
+
+func (p *parser) predicate() (expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == symbolToken {
+		switch t.text {
+		case "=", "<>", "!=", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			return comparison{op: t.text, left: left, right: right}, nil
+		}
+	}
+	if p.keyword("IS") {
+		negate := p.keyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return isNull{value: left, not: negate}, nil
+	}
+	negate := p.keyword("NOT")
+	if p.keyword("IN") {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		list, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		var e expr = in{value: left, list: list}
+		if negate {
+			e = not{e}
+		}
+		return e, nil
+	}
+	if negate {
+		return nil, p.errorf("expected IN after NOT")
+	}
+	return left, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.symbol("+"):
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			left = arithmetic{op: '+', left: left, right: right}
+		case p.symbol("-"):
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			left = arithmetic{op: '-', left: left, right: right}
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case numberToken:
+		p.pos++
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %s", t.text)
+		}
+		return literal{n}, nil
+	case stringToken:
+		p.pos++
+		return literal{t.text}, nil
+	case paramToken:
+		p.pos++
+		return param(t.param), nil
+	case symbolToken:
+		if p.symbol("*") {
+			return star{}, nil
+		}
+		if !p.symbol("(") {
+			return nil, p.errorf("unexpected %q", t.text)
+		}
+		if p.peekKeyword("SELECT") {
+			sub, err := p.selectStatement()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return subquery{sub}, nil
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case identToken:
+		switch {
+		case p.keyword("NULL"):
+			return literal{nil}, nil
+		case p.keyword("TRUE"):
+			return literal{true}, nil
+		case p.keyword("FALSE"):
+			return literal{false}, nil
+		case p.keyword("EXISTS"):
+			if err := p.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			sub, err := p.selectStatement()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return exists{sub}, nil
+		}
+		name, _ := p.ident()
+		if p.symbol("(") {
+			fn := aggregate{fn: strings.ToUpper(name)}
+			switch fn.fn {
+			case "COUNT", "MIN", "MAX":
+			default:
+				return nil, p.errorf("unsupported function %s", name)
+			}
+			arg, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			fn.arg = arg
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return fn, nil
+		}
+		if p.symbol(".") {
+			column, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			return columnRef{qualifier: name, column: column}, nil
+		}
+		return columnRef{column: name}, nil
+	}
+	return nil, p.errorf("unexpected end of statement")
+}
@@ -0,0 +1,262 @@
+// Package sqltest provides an in-memory database/sql driver for testing
+// the SQL stores without a database server. It understands the portable
+// subset of SQL the sqlstore package issues: CREATE TABLE and ALTER TABLE
+// ADD COLUMN, single-table INSERT, UPDATE, DELETE and SELECT with AND-ed
+// conditions, correlated EXISTS subqueries, ORDER BY on columns and LIMIT
+// and OFFSET. Placeholders may be written ?, $n or @pn.
+//
+// Each transaction works on a snapshot of the database that replaces it on
+// commit, so the fake is meant for tests that don't write concurrently.
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrDuplicateKey is returned when an insert repeats a primary key
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// DB is an in-memory database
+type DB struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	// Statements containing a key fail with its error
+	failures map[string]error
+	// Every statement run, in order
+	log []string
+	// Transactions committed and rolled back
+	commits, rollbacks int
+}
+
+// Open creates an empty database and a *sql.DB connected to it
+func Open() (*sql.DB, *DB) {
+	db := &DB{tables: make(map[string]*table), failures: make(map[string]error)}
+	return sql.OpenDB(connector{db: db}), db
+}
+
+// FailOn makes every statement containing text fail with err. A nil err
+// clears the failure.
+func (db *DB) FailOn(text string, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if err == nil {
+		delete(db.failures, text)
+		return
+	}
+	db.failures[text] = err
+}
+
+// Statements returns the statements run so far
+func (db *DB) Statements() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.log...)
+}
+
+// Commits returns the number of transactions committed
+func (db *DB) Commits() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.commits
+}
+
+// Rollbacks returns the number of transactions rolled back
+func (db *DB) Rollbacks() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.rollbacks
+}
+
+// Rows returns the committed rows of a table as column maps
+func (db *DB) Rows(name string) []map[string]driver.Value {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, ok := db.tables[name]
+	if !ok {
+		return nil
+	}
+	rows := make([]map[string]driver.Value, len(t.rows))
+	for i, r := range t.rows {
+		rows[i] = r.copy()
+	}
+	return rows
+}
+
+// run executes a statement against a set of tables
+func (db *DB) run(tables map[string]*table, query string, args []driver.NamedValue) (*result, error) {
+	db.mu.Lock()
+	db.log = append(db.log, query)
+	for text, err := range db.failures {
+		if strings.Contains(query, text) {
+			db.mu.Unlock()
+			return nil, err
+		}
+	}
+	db.mu.Unlock()
+
+	stmt, params, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	// Databases reject arguments the statement has no placeholder for
+	if len(args) != params {
+		return nil, fmt.Errorf("sqltest: %q takes %d arguments, got %d", query, params, len(args))
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return stmt.exec(&env{tables: tables, args: values})
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, fmt.Errorf("sqltest: open databases with sqltest.Open")
+}
+
+// conn runs statements on the committed tables, or on the snapshot of its
+// transaction
+type conn struct {
+	db       *DB
+	snapshot map[string]*table
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.snapshot != nil {
+		return nil, fmt.Errorf("sqltest: transaction already open")
+	}
+	c.db.mu.Lock()
+	c.snapshot = copyTables(c.db.tables)
+	c.db.mu.Unlock()
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: res.columns, values: res.rows}, nil
+}
+
+func (c *conn) exec(query string, args []driver.NamedValue) (*result, error) {
+	if c.snapshot != nil {
+		return c.db.run(c.snapshot, query, args)
+	}
+	// Outside a transaction each statement commits on its own
+	c.db.mu.Lock()
+	tables := copyTables(c.db.tables)
+	c.db.mu.Unlock()
+	res, err := c.db.run(tables, query, args)
+	if err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	c.db.tables = tables
+	c.db.mu.Unlock()
+	return res, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	db := t.conn.db
+	db.mu.Lock()
+	db.tables = t.conn.snapshot
+	db.commits++
+	db.mu.Unlock()
+	t.conn.snapshot = nil
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	db := t.conn.db
+	db.mu.Lock()
+	db.rollbacks++
+	db.mu.Unlock()
+	t.conn.snapshot = nil
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return values
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
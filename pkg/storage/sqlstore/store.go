@@ -0,0 +1,735 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+var (
+	ErrNotFound        = storage.ErrNotFound
+	ErrBatchRolledBack = errors.New("batch rolled back")
+)
+
+// executor is satisfied by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// Store implements the storage interfaces over any database/sql driver.
+// Entities are stored as JSON documents keyed by Go type and ID, and their
+// scalar values are copied to an index table that filters and sorts run
+// against, so only portable SQL is needed. The dialect covers the rest.
+// Soft-deleted entities keep their rows, marked with when and by whom.
+// Run Migrate with the same dialect before first use.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	signer  audit.Signer
+}
+
+// NewStore creates a store on an open database
+func NewStore(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// SetSigner makes the store sign the hash of every new audit entry, so the
+// chain can be checked with audit.VerifySignatures
+func (s *Store) SetSigner(signer audit.Signer) {
+	s.signer = signer
+}
+
+// Tx is a database transaction started by BeginTransaction
+type Tx struct {
+	tx *sql.Tx
+}
+
+// Context returns a context whose store operations run in the transaction
+func (t *Tx) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, t.tx)
+}
+
+// Commit implements storage.Transaction.Commit
+func (t *Tx) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+// Rollback implements storage.Transaction.Rollback
+func (t *Tx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback()
+}
+
+// BeginTransaction implements TransactionManager.BeginTransaction. Store
+// operations join the transaction when given the context from Tx.Context.
+func (s *Store) BeginTransaction(ctx context.Context) (storage.Transaction, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{tx: tx}, nil
+}
+
+// WithTransaction implements TransactionManager.WithTransaction. The
+// transaction commits when fn succeeds and rolls back when it fails or
+// panics. Calls nested in fn join the outer transaction.
+func (s *Store) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// Create implements Repository.Create
+func (s *Store) Create(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	if id == "" {
+		return fmt.Errorf("entity ID cannot be empty")
+	}
+	storage.StampActor(ctx, entity, true)
+	restore := storage.SetVersion(entity, 1)
+	data, err := json.Marshal(entity)
+	if err != nil {
+		restore()
+		return fmt.Errorf("failed to encode entity: %w", err)
+	}
+
+	entityType := entityType(entity)
+	err = s.WithTransaction(ctx, func(ctx context.Context) error {
+		exec := s.executor(ctx)
+		if _, err := s.readDocument(ctx, exec, entityType, id); err == nil {
+			return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, id)
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if _, err := exec.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO documents (entity_type, id, version, data, created_at, updated_at, updated_by) VALUES (%s)",
+			placeholders(s.dialect, 1, 7)),
+			entityType, id, int64(1), string(data), now, now, audit.CallerFromContext(ctx).UserID); err != nil {
+			return fmt.Errorf("failed to store entity: %w", err)
+		}
+		if err := s.index(ctx, exec, entityType, id, data); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, exec, entityType, id, "CREATE", nil, data)
+	})
+	if err != nil {
+		restore()
+	}
+	return err
+}
+
+// Read implements Repository.Read
+func (s *Store) Read(ctx context.Context, id string, entity interface{}) error {
+	doc, err := s.readLive(ctx, s.executor(ctx), entityType(entity), id)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(doc.data, entity); err != nil {
+		return fmt.Errorf("failed to decode entity: %w", err)
+	}
+	return nil
+}
+
+// Update implements Repository.Update. Entities with a GetVersion method
+// are only updated at the version they were read at.
+func (s *Store) Update(ctx context.Context, entity interface{}) error {
+	id := entityID(entity)
+	entityType := entityType(entity)
+	restore := func() {}
+	err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		exec := s.executor(ctx)
+		doc, err := s.readLive(ctx, exec, entityType, id)
+		if err != nil {
+			return err
+		}
+		version, old := doc.version, doc.data
+		if err := storage.CheckVersion(entity, entityType, id, version); err != nil {
+			return err
+		}
+		storage.StampActor(ctx, entity, false)
+		restore = storage.SetVersion(entity, version+1)
+		data, err := json.Marshal(entity)
+		if err != nil {
+			return fmt.Errorf("failed to encode entity: %w", err)
+		}
+
+		// The version check in the statement catches writers that
+		// raced past the read above
+		b := &builder{dialect: s.dialect}
+		result, err := exec.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE documents SET version = %s, data = %s, updated_at = %s, updated_by = %s WHERE entity_type = %s AND id = %s AND version = %s",
+			b.arg(version+1), b.arg(string(data)), b.arg(time.Now().UTC()), b.arg(audit.CallerFromContext(ctx).UserID),
+			b.arg(entityType), b.arg(id), b.arg(version)), b.args...)
+		if err != nil {
+			return fmt.Errorf("failed to store entity: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return &storage.OptimisticLockError{EntityType: entityType, EntityID: id, ExpectedVersion: version}
+		}
+		if err := s.index(ctx, exec, entityType, id, data); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, exec, entityType, id, "UPDATE", old, data)
+	})
+	if err != nil {
+		restore()
+	}
+	return err
+}
+
+// Delete implements Repository.Delete. Like the memory store it removes the
+// first entity with the ID, whatever its type.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.WithTransaction(ctx, func(ctx context.Context) error {
+		exec := s.executor(ctx)
+		entityType, err := s.typeOf(ctx, exec, id)
+		if err != nil {
+			return err
+		}
+		doc, err := s.readDocument(ctx, exec, entityType, id)
+		if err != nil {
+			return err
+		}
+
+		where := fmt.Sprintf(" WHERE entity_type = %s AND id = %s", s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+		if _, err := exec.ExecContext(ctx, "DELETE FROM documents"+where, entityType, id); err != nil {
+			return fmt.Errorf("failed to delete entity: %w", err)
+		}
+		if _, err := exec.ExecContext(ctx, "DELETE FROM document_values"+where, entityType, id); err != nil {
+			return fmt.Errorf("failed to delete entity: %w", err)
+		}
+		return s.recordAudit(ctx, exec, entityType, id, "DELETE", doc.data, nil)
+	})
+}
+
+// SoftDelete implements SoftDeleteRepository.SoftDelete. The row and its
+// indexed values are kept, and the entity keeps its version.
+func (s *Store) SoftDelete(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, true)
+}
+
+// Restore implements SoftDeleteRepository.Restore
+func (s *Store) Restore(ctx context.Context, id string) error {
+	return s.setDeleted(ctx, id, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, id string, deleted bool) error {
+	return s.WithTransaction(ctx, func(ctx context.Context) error {
+		exec := s.executor(ctx)
+		entityType, err := s.typeOf(ctx, exec, id)
+		if err != nil {
+			return err
+		}
+		doc, err := s.readDocument(ctx, exec, entityType, id)
+		if err != nil {
+			return err
+		}
+		if deleted && doc.deletedAt != nil {
+			return fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+		}
+		if !deleted && doc.deletedAt == nil {
+			return fmt.Errorf("entity is not deleted: %s", id)
+		}
+
+		b := &builder{dialect: s.dialect}
+		var set string
+		operation := "RESTORE"
+		oldState, newState := []byte(nil), doc.data
+		if deleted {
+			set = fmt.Sprintf("deleted_at = %s, deleted_by = %s", b.arg(time.Now().UTC()), b.arg(audit.CallerFromContext(ctx).UserID))
+			operation = "SOFT_DELETE"
+			oldState, newState = doc.data, nil
+		} else {
+			set = "deleted_at = NULL, deleted_by = " + b.arg("")
+		}
+		result, err := exec.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE documents SET %s WHERE entity_type = %s AND id = %s AND version = %s",
+			set, b.arg(entityType), b.arg(id), b.arg(doc.version)), b.args...)
+		if err != nil {
+			return fmt.Errorf("failed to store entity: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return &storage.OptimisticLockError{EntityType: entityType, EntityID: id, ExpectedVersion: doc.version}
+		}
+		return s.recordAudit(ctx, exec, entityType, id, operation, oldState, newState)
+	})
+}
+
+// Query implements Repository.Query. Results must be a pointer to a slice
+// of entities or entity pointers.
+func (s *Store) Query(ctx context.Context, query storage.Query, results interface{}) error {
+	slice := reflect.ValueOf(results)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results must be a pointer to a slice, got %T", results)
+	}
+	elemType := slice.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	b := &builder{dialect: s.dialect}
+	statement, err := selectStatement(b, "d.data", entityType(reflect.New(structType).Interface()), query.Filters, query.Deleted)
+	if err != nil {
+		return err
+	}
+	order, err := b.orderBy(query.Sort)
+	if err != nil {
+		return err
+	}
+	statement += order + b.limit(query.Pagination)
+
+	rows, err := s.executor(ctx).QueryContext(ctx, statement, b.args...)
+	if err != nil {
+		return fmt.Errorf("error querying entities: %w", err)
+	}
+	defer rows.Close()
+
+	list := reflect.MakeSlice(slice.Elem().Type(), 0, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("error reading entity: %w", err)
+		}
+		item := reflect.New(structType)
+		if err := json.Unmarshal([]byte(data), item.Interface()); err != nil {
+			return fmt.Errorf("failed to decode entity: %w", err)
+		}
+		if elemType.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		list = reflect.Append(list, item)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error querying entities: %w", err)
+	}
+	slice.Elem().Set(list)
+	return nil
+}
+
+// Count implements Repository.Count. An "entity_type" filter, holding a
+// sample entity such as &account.Account{}, restricts the count to one
+// type; without it documents of every type are counted.
+func (s *Store) Count(ctx context.Context, query storage.Query) (int64, error) {
+	entityType := ""
+	filters := make([]storage.Filter, 0, len(query.Filters))
+	for _, f := range query.Filters {
+		if f.Field == "entity_type" {
+			entityType = typeName(f.Value)
+			continue
+		}
+		filters = append(filters, f)
+	}
+
+	b := &builder{dialect: s.dialect}
+	statement, err := selectStatement(b, "COUNT(*)", entityType, filters, query.Deleted)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := s.executor(ctx).QueryRowContext(ctx, statement, b.args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting entities: %w", err)
+	}
+	return count, nil
+}
+
+// BatchExecute implements BatchRepository.BatchExecute. The batch runs in
+// one database transaction: if any item fails, nothing is written, the
+// failing item reports its error and every other item ErrBatchRolledBack.
+func (s *Store) BatchExecute(ctx context.Context, items []storage.BatchItem) []storage.BatchResult {
+	results := make([]storage.BatchResult, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+		if item.ID == "" && item.Entity != nil {
+			results[i].ID = entityID(item.Entity)
+		}
+	}
+
+	failed := -1
+	restore := storage.KeepVersions(items)
+	err := s.WithTransaction(ctx, func(ctx context.Context) error {
+		for i, item := range items {
+			var err error
+			switch item.Operation {
+			case storage.BatchCreate:
+				err = s.Create(ctx, item.Entity)
+			case storage.BatchUpdate:
+				err = s.Update(ctx, item.Entity)
+			case storage.BatchDelete:
+				err = s.Delete(ctx, item.ID)
+			default:
+				err = fmt.Errorf("unknown batch operation %q", item.Operation)
+			}
+			if err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		restore()
+	}
+	for i := range results {
+		switch {
+		case err == nil:
+			results[i].Success = true
+		case i == failed:
+			results[i].Error = err
+		case failed < 0:
+			// The commit itself failed
+			results[i].Error = err
+		default:
+			results[i].Error = ErrBatchRolledBack
+		}
+	}
+	return results
+}
+
+// GetVersionInfo implements AuditableRepository.GetVersionInfo
+func (s *Store) GetVersionInfo(ctx context.Context, entityID string) (*storage.VersionInfo, error) {
+	info := &storage.VersionInfo{}
+	var deletedAt sql.NullTime
+	err := s.executor(ctx).QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT version, updated_at, updated_by, deleted_at, deleted_by FROM documents WHERE id = %s ORDER BY entity_type",
+		s.dialect.Placeholder(1)), entityID).Scan(&info.Version, &info.ModifiedAt, &info.ModifiedBy, &deletedAt, &info.DeletedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, entityID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading version: %w", err)
+	}
+	if deletedAt.Valid {
+		at := deletedAt.Time.UTC()
+		info.DeletedAt = &at
+	}
+	return info, nil
+}
+
+// GetAuditTrail implements AuditableRepository.GetAuditTrail
+func (s *Store) GetAuditTrail(ctx context.Context, entityID string) ([]storage.AuditEntry, error) {
+	return s.QueryAudit(ctx, storage.AuditQuery{EntityID: entityID})
+}
+
+// QueryAudit implements AuditLogRepository.QueryAudit
+func (s *Store) QueryAudit(ctx context.Context, query storage.AuditQuery) ([]storage.AuditEntry, error) {
+	b := &builder{dialect: s.dialect}
+	statement := auditStatement(b, auditColumns, query) + " ORDER BY seq"
+	return s.queryAudit(ctx, statement, b.args)
+}
+
+// QueryAuditTrail implements AuditableRepository.QueryAuditTrail. The
+// page is selected in SQL, with a second statement counting every match.
+func (s *Store) QueryAuditTrail(ctx context.Context, filter storage.AuditFilter) (*storage.AuditPage, error) {
+	page := &storage.AuditPage{}
+	b := &builder{dialect: s.dialect}
+	if err := s.executor(ctx).QueryRowContext(ctx, auditStatement(b, "COUNT(*)", filter.AuditQuery), b.args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("error counting audit entries: %w", err)
+	}
+
+	b = &builder{dialect: s.dialect}
+	statement := auditStatement(b, auditColumns, filter.AuditQuery) + " ORDER BY seq" + b.limit(filter.Pagination)
+	entries, err := s.queryAudit(ctx, statement, b.args)
+	if err != nil {
+		return nil, err
+	}
+	page.Entries = entries
+	return page, nil
+}
+
+// auditStatement builds a SELECT of the audit entries matching a query
+func auditStatement(b *builder, columns string, query storage.AuditQuery) string {
+	statement := "SELECT " + columns + " FROM audit_entries WHERE 1 = 1"
+	for _, c := range []struct{ column, value string }{
+		{"entity_type", query.EntityType},
+		{"entity_id", query.EntityID},
+		{"user_id", query.UserID},
+		{"operation", query.Operation},
+	} {
+		if c.value != "" {
+			statement += fmt.Sprintf(" AND %s = %s", c.column, b.arg(c.value))
+		}
+	}
+	if query.From != nil {
+		statement += " AND recorded_at >= " + b.arg(query.From.UTC())
+	}
+	if query.To != nil {
+		statement += " AND recorded_at <= " + b.arg(query.To.UTC())
+	}
+	return statement
+}
+
+// queryAudit runs a SELECT of audit entries
+func (s *Store) queryAudit(ctx context.Context, statement string, args []interface{}) ([]storage.AuditEntry, error) {
+	rows, err := s.executor(ctx).QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]storage.AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+const auditColumns = "seq, id, entity_type, entity_id, operation, user_id, recorded_at, previous_state, new_state, metadata, state_hash, previous_hash, hash, signature"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAuditEntry reads an audit row. Absent states and metadata are stored
+// as empty strings.
+func scanAuditEntry(row scanner) (*storage.AuditEntry, error) {
+	var entry storage.AuditEntry
+	var previous, next, metadata string
+	if err := row.Scan(&entry.Sequence, &entry.ID, &entry.EntityType, &entry.EntityID, &entry.Operation,
+		&entry.UserID, &entry.Timestamp, &previous, &next, &metadata,
+		&entry.StateHash, &entry.PreviousHash, &entry.Hash, &entry.Signature); err != nil {
+		return nil, err
+	}
+	entry.Timestamp = entry.Timestamp.UTC()
+	if previous != "" {
+		entry.PreviousState = json.RawMessage(previous)
+	}
+	if next != "" {
+		entry.NewState = json.RawMessage(next)
+	}
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &entry.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
+
+// recordAudit appends an entry to the audit chain in the current
+// transaction. Bumping the head row first locks it until the transaction
+// ends, which keeps the chain linear under concurrent writers.
+func (s *Store) recordAudit(ctx context.Context, exec executor, entityType, entityID, operation string, oldState, newState []byte) error {
+	if _, err := exec.ExecContext(ctx, "UPDATE audit_head SET seq = seq + 1 WHERE id = 1"); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	b := &builder{dialect: s.dialect}
+	previous, err := scanAuditEntry(exec.QueryRowContext(ctx, "SELECT "+auditColumns+
+		" FROM audit_entries ORDER BY seq DESC"+b.limit(&storage.Pagination{Limit: 1}), b.args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		previous = nil
+	} else if err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	// Timestamps are kept at microsecond precision, which every dialect
+	// stores, so the sealed hash survives the round trip
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	entry := storage.AuditEntry{
+		ID:         fmt.Sprintf("audit_%d", now.UnixNano()),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Operation:  operation,
+		UserID:     caller.UserID,
+		Timestamp:  now,
+		Metadata:   caller.Metadata(),
+	}
+	if oldState != nil {
+		entry.PreviousState = json.RawMessage(oldState)
+	}
+	if newState != nil {
+		entry.NewState = json.RawMessage(newState)
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
+		}
+		entry.Metadata[tenant.MetadataKey] = id
+	}
+	if err := audit.SealSigned(ctx, &entry, previous, s.signer); err != nil {
+		return err
+	}
+
+	metadata := ""
+	if entry.Metadata != nil {
+		encoded, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit metadata: %w", err)
+		}
+		metadata = string(encoded)
+	}
+	if _, err := exec.ExecContext(ctx, fmt.Sprintf("INSERT INTO audit_entries (%s) VALUES (%s)",
+		auditColumns, placeholders(s.dialect, 1, 14)),
+		entry.Sequence, entry.ID, entry.EntityType, entry.EntityID, entry.Operation, entry.UserID, entry.Timestamp,
+		string(oldState), string(newState), metadata,
+		entry.StateHash, entry.PreviousHash, entry.Hash, entry.Signature); err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	return nil
+}
+
+// document is a stored entity
+type document struct {
+	version   int64
+	data      []byte
+	deletedAt *time.Time
+}
+
+// readDocument returns a stored entity, soft-deleted or not
+func (s *Store) readDocument(ctx context.Context, exec executor, entityType, id string) (*document, error) {
+	var doc document
+	var data string
+	var deletedAt sql.NullTime
+	err := exec.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT version, data, deleted_at FROM documents WHERE entity_type = %s AND id = %s",
+		s.dialect.Placeholder(1), s.dialect.Placeholder(2)), entityType, id).Scan(&doc.version, &data, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading entity: %w", err)
+	}
+	doc.data = []byte(data)
+	if deletedAt.Valid {
+		doc.deletedAt = &deletedAt.Time
+	}
+	return &doc, nil
+}
+
+// readLive returns a stored entity that is not soft-deleted
+func (s *Store) readLive(ctx context.Context, exec executor, entityType, id string) (*document, error) {
+	doc, err := s.readDocument(ctx, exec, entityType, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.deletedAt != nil {
+		return nil, fmt.Errorf("%w: %s", storage.ErrDeleted, id)
+	}
+	return doc, nil
+}
+
+// typeOf returns the type of the first entity with the ID
+func (s *Store) typeOf(ctx context.Context, exec executor, id string) (string, error) {
+	var entityType string
+	err := exec.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT entity_type FROM documents WHERE id = %s ORDER BY entity_type", s.dialect.Placeholder(1)),
+		id).Scan(&entityType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading entity: %w", err)
+	}
+	return entityType, nil
+}
+
+// index replaces the indexed values of a document
+func (s *Store) index(ctx context.Context, exec executor, entityType, id string, data []byte) error {
+	values, err := flatten(data)
+	if err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	if _, err := exec.ExecContext(ctx, fmt.Sprintf("DELETE FROM document_values WHERE entity_type = %s AND id = %s",
+		s.dialect.Placeholder(1), s.dialect.Placeholder(2)), entityType, id); err != nil {
+		return fmt.Errorf("failed to index entity: %w", err)
+	}
+	insert := fmt.Sprintf("INSERT INTO document_values (entity_type, id, path, value) VALUES (%s)", placeholders(s.dialect, 1, 4))
+	for _, v := range values {
+		if _, err := exec.ExecContext(ctx, insert, entityType, id, v.path, v.value); err != nil {
+			return fmt.Errorf("failed to index entity: %w", err)
+		}
+	}
+	return nil
+}
+
+// selectStatement builds a SELECT of the given columns over the documents
+// matching the filters, restricted to one entity type unless it is empty
+func selectStatement(b *builder, columns, entityType string, filters []storage.Filter, deleted storage.DeletedMode) (string, error) {
+	statement := fmt.Sprintf("SELECT %s FROM documents d WHERE 1 = 1", columns)
+	if entityType != "" {
+		statement += " AND d.entity_type = " + b.arg(entityType)
+	}
+	switch deleted {
+	case storage.ExcludeDeleted:
+		statement += " AND d.deleted_at IS NULL"
+	case storage.OnlyDeleted:
+		statement += " AND d.deleted_at IS NOT NULL"
+	}
+	conditions, err := b.where(filters)
+	if err != nil {
+		return "", err
+	}
+	if conditions != "" {
+		statement += " AND " + conditions
+	}
+	return statement, nil
+}
+
+// executor returns the transaction bound to the context, or the database
+func (s *Store) executor(ctx context.Context) executor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+func entityType(entity interface{}) string {
+	return fmt.Sprintf("%T", entity)
+}
+
+// typeName returns the entity type of a value passed in a filter, which
+// may be a sample entity or the type name itself
+func typeName(v interface{}) string {
+	if name, ok := v.(string); ok {
+		return name
+	}
+	return entityType(v)
+}
+
+func entityID(entity interface{}) string {
+	if e, ok := entity.(interface{ GetID() string }); ok {
+		return e.GetID()
+	}
+	return ""
+}
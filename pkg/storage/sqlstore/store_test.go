@@ -0,0 +1,291 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/reporting"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/sqlstore/sqltest"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTransaction(id string, date time.Time, status transaction.TransactionStatus, accountID string) *transaction.Transaction {
+	amount := money.Money{Amount: decimal.NewFromInt(100), Currency: "USD"}
+	return &transaction.Transaction{
+		ID:          id,
+		Status:      status,
+		Date:        date,
+		Description: "Invoice " + id,
+		Entries: []transaction.Entry{
+			{AccountID: accountID, Amount: amount, Type: transaction.Debit},
+			{AccountID: "revenue", Amount: amount, Type: transaction.Credit},
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, fake := sqltest.Open()
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	versions := fake.Rows("schema_migrations")
+	require.Len(t, versions, len(Migrations))
+	assert.Equal(t, "add_soft_deletes", versions[len(versions)-1]["name"])
+
+	// A second run finds nothing to apply
+	before := len(fake.Statements())
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	assert.Len(t, fake.Statements(), before+1)
+
+	// A failed migration leaves no trace
+	db, fake = sqltest.Open()
+	fake.FailOn("ADD COLUMN deleted_by", errors.New("disk full"))
+	assert.ErrorContains(t, Migrate(ctx, db, SQLite), "migration 5 (add_soft_deletes) failed")
+	assert.Len(t, fake.Rows("schema_migrations"), 4)
+	fake.FailOn("ADD COLUMN deleted_by", nil)
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	assert.Len(t, fake.Rows("schema_migrations"), len(Migrations))
+}
+
+func TestStore(t *testing.T) {
+	ctx := audit.WithCaller(context.Background(), audit.Caller{UserID: "alice"})
+	db, fake := sqltest.Open()
+	require.NoError(t, Migrate(ctx, db, SQLite))
+	store := NewStore(db, SQLite)
+
+	jan := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CRUD", func(t *testing.T) {
+		cash := &account.Account{ID: "cash", Code: "1000", Name: "Cash", Type: account.Asset}
+		require.NoError(t, store.Create(ctx, cash))
+		assert.Equal(t, int64(1), cash.Version)
+		require.NoError(t, store.Create(ctx, &account.Account{ID: "loan", Code: "2000", Name: "Loan", Type: account.Liability}))
+		assert.ErrorIs(t, store.Create(ctx, cash), storage.ErrAlreadyExists)
+
+		var read account.Account
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		assert.Equal(t, "Cash", read.Name)
+		assert.ErrorIs(t, store.Read(ctx, "missing", &read), ErrNotFound)
+
+		read.Type = account.Liability
+		require.NoError(t, store.Update(ctx, &read))
+		assert.Equal(t, int64(2), read.Version)
+		info, err := store.GetVersionInfo(ctx, "cash")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), info.Version)
+		assert.Equal(t, "alice", info.ModifiedBy)
+
+		// The type index follows the update
+		var assets, liabilities []*account.Account
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "type", Operator: "=", Value: account.Asset}}}, &assets))
+		require.NoError(t, store.Query(ctx, storage.Query{Filters: []storage.Filter{{Field: "type", Operator: "=", Value: account.Liability}}}, &liabilities))
+		assert.Empty(t, assets)
+		assert.Len(t, liabilities, 2)
+
+		require.NoError(t, store.Delete(ctx, "loan"))
+		assert.ErrorIs(t, store.Delete(ctx, "loan"), ErrNotFound)
+	})
+
+	t.Run("VersionConflict", func(t *testing.T) {
+		var first, second account.Account
+		require.NoError(t, store.Read(ctx, "cash", &first))
+		require.NoError(t, store.Read(ctx, "cash", &second))
+
+		first.Name = "Petty cash"
+		require.NoError(t, store.Update(ctx, &first))
+
+		second.Name = "Cash on hand"
+		err := store.Update(ctx, &second)
+		var conflict *storage.OptimisticLockError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "cash", conflict.EntityID)
+		assert.Equal(t, int64(2), second.Version, "a rejected update keeps the version read")
+
+		var read account.Account
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		assert.Equal(t, "Petty cash", read.Name)
+		assert.Equal(t, int64(3), read.Version)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		entries, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+
+		// A write whose audit entry fails is undone with it
+		fake.FailOn("INSERT INTO audit_entries", errors.New("disk full"))
+		savings := &account.Account{ID: "savings", Code: "1100", Name: "Savings", Type: account.Asset}
+		assert.ErrorContains(t, store.Create(ctx, savings), "disk full")
+		assert.Equal(t, int64(0), savings.Version)
+		var read account.Account
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		read.Name = "Cash"
+		assert.Error(t, store.Update(ctx, &read))
+		fake.FailOn("INSERT INTO audit_entries", nil)
+
+		assert.ErrorIs(t, store.Read(ctx, "savings", &account.Account{}), ErrNotFound)
+		require.NoError(t, store.Read(ctx, "cash", &read))
+		assert.Equal(t, "Petty cash", read.Name)
+		assert.Equal(t, int64(3), read.Version)
+		after, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+		assert.Len(t, after, len(entries))
+		assert.Len(t, fake.Rows("audit_entries"), len(entries))
+		assert.Equal(t, int64(len(entries)), fake.Rows("audit_head")[0]["seq"])
+
+		// Work done through a transaction is discarded on rollback
+		tx, err := store.BeginTransaction(ctx)
+		require.NoError(t, err)
+		txCtx := tx.(*Tx).Context(ctx)
+		require.NoError(t, store.Create(txCtx, savings))
+		require.NoError(t, store.Read(txCtx, "savings", &account.Account{}))
+		require.NoError(t, tx.Rollback(ctx))
+		assert.ErrorIs(t, store.Read(ctx, "savings", &account.Account{}), ErrNotFound)
+
+		// A failing callback rolls back everything it wrote
+		err = store.WithTransaction(ctx, func(ctx context.Context) error {
+			require.NoError(t, store.Create(ctx, &account.Account{ID: "savings", Code: "1100", Name: "Savings", Type: account.Asset}))
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorIs(t, store.Read(ctx, "savings", &account.Account{}), ErrNotFound)
+		for _, v := range fake.Rows("document_values") {
+			assert.NotEqual(t, "savings", v["id"])
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		require.NoError(t, store.Create(ctx, testTransaction("TX1", jan, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX2", feb, transaction.Posted, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX3", feb, transaction.Draft, "cash")))
+		require.NoError(t, store.Create(ctx, testTransaction("TX4", feb, transaction.Posted, "bank")))
+
+		var found []*transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Filters: []storage.Filter{
+				{Field: "date", Operator: ">=", Value: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+				{Field: "entries.account_id", Operator: "=", Value: "cash"},
+				{Field: "status", Operator: "=", Value: transaction.Posted},
+			},
+		}, &found))
+		require.Len(t, found, 1)
+		assert.Equal(t, "TX2", found[0].ID)
+
+		var page []transaction.Transaction
+		require.NoError(t, store.Query(ctx, storage.Query{
+			Sort:       []storage.Sort{{Field: "date", Desc: true}},
+			Pagination: &storage.Pagination{Offset: 1, Limit: 2},
+		}, &page))
+		require.Len(t, page, 2)
+		assert.Equal(t, "TX3", page[0].ID)
+		assert.Equal(t, "TX4", page[1].ID)
+
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{
+			{Field: "entity_type", Value: &transaction.Transaction{}},
+			{Field: "status", Operator: "!=", Value: transaction.Draft},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		var hits []*transaction.Transaction
+		require.NoError(t, store.Search(ctx, storage.SearchOptions{Query: "invoice tx2"}, &hits))
+		require.Len(t, hits, 1)
+		assert.Equal(t, "TX2", hits[0].ID)
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		require.NoError(t, store.SoftDelete(ctx, "TX3"))
+		assert.ErrorIs(t, store.Read(ctx, "TX3", &transaction.Transaction{}), storage.ErrDeleted)
+		assert.ErrorIs(t, store.SoftDelete(ctx, "TX3"), storage.ErrDeleted)
+
+		byAccount := []storage.Filter{{Field: "entries.account_id", Operator: "=", Value: "cash"}}
+		for mode, want := range map[storage.DeletedMode]int{
+			storage.ExcludeDeleted: 2,
+			storage.IncludeDeleted: 3,
+			storage.OnlyDeleted:    1,
+		} {
+			var found []*transaction.Transaction
+			require.NoError(t, store.Query(ctx, storage.Query{Filters: byAccount, Deleted: mode}, &found))
+			assert.Len(t, found, want, "mode %q", mode)
+		}
+		info, err := store.GetVersionInfo(ctx, "TX3")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", info.DeletedBy)
+		assert.NotNil(t, info.DeletedAt)
+
+		require.NoError(t, store.Restore(ctx, "TX3"))
+		count, err := store.Count(ctx, storage.Query{Filters: []storage.Filter{{Field: "entity_type", Value: &transaction.Transaction{}}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), count)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		commits := fake.Commits()
+		results := store.BatchExecute(ctx, []storage.BatchItem{
+			{Operation: storage.BatchCreate, Entity: testTransaction("TX5", feb, transaction.Posted, "cash")},
+			{Operation: storage.BatchDelete, ID: "missing"},
+		})
+		assert.False(t, results[0].Success)
+		assert.ErrorIs(t, results[0].Error, ErrBatchRolledBack)
+		assert.ErrorIs(t, results[1].Error, ErrNotFound)
+		assert.ErrorIs(t, store.Read(ctx, "TX5", &transaction.Transaction{}), ErrNotFound)
+		assert.Equal(t, commits, fake.Commits())
+
+		results = store.BatchExecute(ctx, []storage.BatchItem{
+			{Operation: storage.BatchCreate, Entity: testTransaction("TX5", feb, transaction.Posted, "cash")},
+			{Operation: storage.BatchDelete, ID: "TX4"},
+		})
+		assert.True(t, results[0].Success)
+		assert.True(t, results[1].Success)
+		assert.Equal(t, commits+1, fake.Commits(), "the batch commits once")
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		entries, err := store.QueryAudit(ctx, storage.AuditQuery{})
+		require.NoError(t, err)
+		require.NoError(t, audit.VerifyChain(entries))
+		assert.Len(t, fake.Rows("audit_entries"), len(entries))
+		for i, entry := range entries {
+			assert.Equal(t, int64(i+1), entry.Sequence)
+			assert.Equal(t, "alice", entry.UserID)
+		}
+
+		trail, err := store.GetAuditTrail(ctx, "cash")
+		require.NoError(t, err)
+		operations := make([]string, len(trail))
+		for i, entry := range trail {
+			operations[i] = entry.Operation
+		}
+		assert.Equal(t, []string{"CREATE", "UPDATE", "UPDATE"}, operations)
+
+		deletes, err := store.QueryAuditTrail(ctx, storage.AuditFilter{
+			AuditQuery: storage.AuditQuery{EntityType: entityType(&transaction.Transaction{})},
+			Pagination: &storage.Pagination{Offset: 4, Limit: 2},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(8), deletes.Total)
+		require.Len(t, deletes.Entries, 2)
+		assert.Equal(t, "RESTORE", deletes.Entries[1].Operation)
+	})
+	t.Run("Definitions", func(t *testing.T) {
+		require.NoError(t, store.SaveDefinition(ctx, &reporting.ReportDefinition{ID: "pnl", Name: "Profit"}))
+		require.NoError(t, store.SaveDefinition(ctx, &reporting.ReportDefinition{ID: "pnl", Name: "Profit and loss"}))
+		def, err := store.LoadDefinition(ctx, "pnl")
+		require.NoError(t, err)
+		assert.Equal(t, "Profit and loss", def.Name)
+		defs, err := store.ListDefinitions(ctx)
+		require.NoError(t, err)
+		assert.Len(t, defs, 1)
+		require.NoError(t, store.DeleteDefinition(ctx, "pnl"))
+		assert.ErrorIs(t, store.DeleteDefinition(ctx, "pnl"), ErrNotFound)
+	})
+}
@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// ErrTenantMismatch is returned when a tenant-scoped context reads or
+// writes an entity owned by another tenant
+var ErrTenantMismatch = errors.New("tenant mismatch")
+
+// tenantField is the document field holding an entity's tenant
+const tenantField = "entity_id"
+
+// TenantScoped is implemented by entities owned by a tenant. The tenant is
+// stored in the "entity_id" document field.
+type TenantScoped interface {
+	GetTenantID() string
+	SetTenantID(id string)
+}
+
+// TenantRepository wraps any Repository so that a context scoped with
+// tenant.WithTenant never reads or writes another tenant's entities:
+// creates and updates are stamped with the context tenant, reads and writes
+// of other tenants' entities fail with ErrTenantMismatch, and queries and
+// counts are filtered to the tenant. Stored entities without a tenant belong
+// to unscoped contexts only, which pass straight through. Transactions,
+// audit trails and search are passed to the wrapped repository, which
+// fails with ErrNotSupported where it lacks them; scoped contexts see the
+// audit entries and search results of their tenant only.
+type TenantRepository struct {
+	repo  Repository
+	types []reflect.Type
+}
+
+// NewTenantRepository wraps a repository with tenant scoping. Delete only
+// has an ID, so it looks the entity up among the given samples of the
+// tenant-scoped types stored, such as &account.Account{}.
+func NewTenantRepository(repo Repository, entities ...TenantScoped) *TenantRepository {
+	types := make([]reflect.Type, 0, len(entities))
+	for _, e := range entities {
+		types = append(types, reflect.TypeOf(e).Elem())
+	}
+	return &TenantRepository{repo: repo, types: types}
+}
+
+// Create implements Repository.Create
+func (r *TenantRepository) Create(ctx context.Context, entity interface{}) error {
+	if err := stampTenant(ctx, entity); err != nil {
+		return err
+	}
+	return r.repo.Create(ctx, entity)
+}
+
+// Read implements Repository.Read. The entity is read into a fresh value
+// first, so the caller's entity never holds another tenant's data.
+func (r *TenantRepository) Read(ctx context.Context, id string, entity interface{}) error {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return r.repo.Read(ctx, id, entity)
+	}
+	if _, ok := entity.(TenantScoped); !ok {
+		return r.repo.Read(ctx, id, entity)
+	}
+	stored, err := r.readScoped(ctx, id, reflect.TypeOf(entity).Elem())
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(entity).Elem().Set(reflect.ValueOf(stored).Elem())
+	return nil
+}
+
+// Update implements Repository.Update. Both the entity and the stored
+// version it replaces must belong to the context tenant; an entity without
+// a tenant keeps the stored one.
+func (r *TenantRepository) Update(ctx context.Context, entity interface{}) error {
+	scoped, ok := entity.(TenantScoped)
+	if _, scopedCtx := tenant.FromContext(ctx); !scopedCtx || !ok {
+		return r.repo.Update(ctx, entity)
+	}
+	if err := CheckTenant(ctx, scoped.GetTenantID()); err != nil {
+		return err
+	}
+	stored, err := r.readScoped(ctx, entityID(entity), reflect.TypeOf(entity).Elem())
+	if err != nil {
+		return err
+	}
+	if scoped.GetTenantID() == "" {
+		scoped.SetTenantID(stored.(TenantScoped).GetTenantID())
+	}
+	return r.repo.Update(ctx, entity)
+}
+
+// Delete implements Repository.Delete. In a scoped context the entity is
+// looked up among the registered types and refused when it belongs to
+// another tenant; IDs of no registered type are deleted as they are.
+func (r *TenantRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := tenant.FromContext(ctx); ok {
+		for _, t := range r.types {
+			_, err := r.readScoped(ctx, id, t)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, ErrTenantMismatch) || errors.Is(err, ErrDeleted) {
+				return err
+			}
+		}
+	}
+	return r.repo.Delete(ctx, id)
+}
+
+// Query implements Repository.Query
+func (r *TenantRepository) Query(ctx context.Context, query Query, results interface{}) error {
+	if scopedResults(results) {
+		query = ScopeQuery(ctx, query)
+	}
+	return r.repo.Query(ctx, query, results)
+}
+
+// Count implements Repository.Count. Counts of an "entity_type" that is not
+// tenant-scoped are left unfiltered.
+func (r *TenantRepository) Count(ctx context.Context, query Query) (int64, error) {
+	scoped := true
+	for _, f := range query.Filters {
+		if f.Field == "entity_type" {
+			_, scoped = f.Value.(TenantScoped)
+		}
+	}
+	if scoped {
+		query = ScopeQuery(ctx, query)
+	}
+	return r.repo.Count(ctx, query)
+}
+
+// BeginTransaction implements TransactionManager.BeginTransaction
+func (r *TenantRepository) BeginTransaction(ctx context.Context) (Transaction, error) {
+	manager, ok := r.repo.(TransactionManager)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T has no transactions", ErrNotSupported, r.repo)
+	}
+	return manager.BeginTransaction(ctx)
+}
+
+// WithTransaction implements TransactionManager.WithTransaction. It uses
+// the wrapped repository's WithTransaction even when that repository can't
+// begin transactions on their own; without either, fn runs directly, as it
+// does for callers that find no TransactionManager.
+func (r *TenantRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	manager, ok := r.repo.(interface {
+		WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	})
+	if !ok {
+		return fn(ctx)
+	}
+	return manager.WithTransaction(ctx, fn)
+}
+
+// GetAuditTrail implements AuditableRepository.GetAuditTrail
+func (r *TenantRepository) GetAuditTrail(ctx context.Context, entityID string) ([]AuditEntry, error) {
+	repo, err := r.auditable()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := repo.GetAuditTrail(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	return scopeAudit(ctx, entries), nil
+}
+
+// GetVersionInfo implements AuditableRepository.GetVersionInfo
+func (r *TenantRepository) GetVersionInfo(ctx context.Context, entityID string) (*VersionInfo, error) {
+	repo, err := r.auditable()
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetVersionInfo(ctx, entityID)
+}
+
+// QueryAuditTrail implements AuditableRepository.QueryAuditTrail. Scoped
+// contexts read every matching entry and page through their tenant's.
+func (r *TenantRepository) QueryAuditTrail(ctx context.Context, filter AuditFilter) (*AuditPage, error) {
+	repo, err := r.auditable()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return repo.QueryAuditTrail(ctx, filter)
+	}
+
+	all := filter
+	all.Pagination = nil
+	page, err := repo.QueryAuditTrail(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	entries := scopeAudit(ctx, page.Entries)
+	scoped := &AuditPage{Entries: entries, Total: int64(len(entries))}
+	if p := filter.Pagination; p != nil {
+		start := min(int(max(p.Offset, 0)), len(entries))
+		end := len(entries)
+		if p.Limit > 0 {
+			end = min(start+int(p.Limit), end)
+		}
+		scoped.Entries = entries[start:end]
+	}
+	return scoped, nil
+}
+
+// QueryAudit implements AuditLogRepository.QueryAudit
+func (r *TenantRepository) QueryAudit(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	repo, ok := r.repo.(AuditLogRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T has no audit log", ErrNotSupported, r.repo)
+	}
+	entries, err := repo.QueryAudit(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return scopeAudit(ctx, entries), nil
+}
+
+// Search implements SearchableRepository.Search
+func (r *TenantRepository) Search(ctx context.Context, options SearchOptions, results interface{}) error {
+	repo, err := r.searchable()
+	if err != nil {
+		return err
+	}
+	if scopedResults(results) {
+		options.Filters = ScopeQuery(ctx, Query{Filters: options.Filters}).Filters
+	}
+	return repo.Search(ctx, options, results)
+}
+
+// SearchCount implements SearchableRepository.SearchCount. Like Count, it
+// is filtered to the tenant unless the "entity_type" is not tenant-scoped.
+func (r *TenantRepository) SearchCount(ctx context.Context, options SearchOptions) (int64, error) {
+	repo, err := r.searchable()
+	if err != nil {
+		return 0, err
+	}
+	scoped := true
+	for _, f := range options.Filters {
+		if f.Field == "entity_type" {
+			_, scoped = f.Value.(TenantScoped)
+		}
+	}
+	if scoped {
+		options.Filters = ScopeQuery(ctx, Query{Filters: options.Filters}).Filters
+	}
+	return repo.SearchCount(ctx, options)
+}
+
+// Unwrap returns the wrapped repository
+func (r *TenantRepository) Unwrap() Repository {
+	return r.repo
+}
+
+func (r *TenantRepository) auditable() (AuditableRepository, error) {
+	repo, ok := r.repo.(AuditableRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T has no audit trail", ErrNotSupported, r.repo)
+	}
+	return repo, nil
+}
+
+func (r *TenantRepository) searchable() (SearchableRepository, error) {
+	repo, ok := r.repo.(SearchableRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T has no search", ErrNotSupported, r.repo)
+	}
+	return repo, nil
+}
+
+// scopeAudit keeps the audit entries recorded for the context tenant. In
+// unscoped contexts every entry is kept.
+func scopeAudit(ctx context.Context, entries []AuditEntry) []AuditEntry {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return entries
+	}
+	scoped := make([]AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if owner, _ := tenant.FromMetadata(entry.Metadata); owner == id {
+			scoped = append(scoped, entry)
+		}
+	}
+	return scoped
+}
+
+// readScoped reads an entity of a type into a new value and checks that it
+// belongs to the context tenant
+func (r *TenantRepository) readScoped(ctx context.Context, id string, t reflect.Type) (interface{}, error) {
+	stored := reflect.New(t).Interface()
+	if err := r.repo.Read(ctx, id, stored); err != nil {
+		return nil, err
+	}
+	if scoped, ok := stored.(TenantScoped); ok {
+		if err := CheckStoredTenant(ctx, scoped.GetTenantID()); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+	}
+	return stored, nil
+}
+
+// stampTenant sets the context tenant on an entity without one and rejects
+// entities owned by another tenant
+func stampTenant(ctx context.Context, entity interface{}) error {
+	id, ok := tenant.FromContext(ctx)
+	scoped, isScoped := entity.(TenantScoped)
+	if !ok || !isScoped {
+		return nil
+	}
+	if err := CheckTenant(ctx, scoped.GetTenantID()); err != nil {
+		return err
+	}
+	scoped.SetTenantID(id)
+	return nil
+}
+
+// CheckTenant verifies that an entity being written may be stored under the
+// context tenant. Entities without a tenant are allowed, as they take the
+// context tenant when stored.
+func CheckTenant(ctx context.Context, entityTenant string) error {
+	if entityTenant == "" {
+		return nil
+	}
+	return CheckStoredTenant(ctx, entityTenant)
+}
+
+// CheckStoredTenant verifies that a stored entity belongs to the context
+// tenant. Like ScopeQuery, it hides stored entities without a tenant from
+// scoped contexts.
+func CheckStoredTenant(ctx context.Context, entityTenant string) error {
+	id, ok := tenant.FromContext(ctx)
+	if !ok || entityTenant == id {
+		return nil
+	}
+	if entityTenant == "" {
+		return fmt.Errorf("%w: entity belongs to no tenant, context is scoped to %s", ErrTenantMismatch, id)
+	}
+	return fmt.Errorf("%w: entity belongs to %s, context is scoped to %s", ErrTenantMismatch, entityTenant, id)
+}
+
+// ScopeQuery filters a query to the context tenant, if any. Unscoped
+// contexts return the query unchanged.
+func ScopeQuery(ctx context.Context, query Query) Query {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return query
+	}
+	scoped := query
+	scoped.Filters = append(append([]Filter(nil), query.Filters...), Filter{
+		Field:    tenantField,
+		Operator: "=",
+		Value:    id,
+	})
+	return scoped
+}
+
+// scopedResults reports whether query results are tenant-scoped entities
+func scopedResults(results interface{}) bool {
+	t := reflect.TypeOf(results)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return false
+	}
+	elem := t.Elem().Elem()
+	if elem.Kind() != reflect.Ptr {
+		elem = reflect.PtrTo(elem)
+	}
+	return elem.Implements(reflect.TypeOf((*TenantScoped)(nil)).Elem())
+}
+
+// entityID returns the ID of an entity with a GetID method
+func entityID(entity interface{}) string {
+	if e, ok := entity.(interface{ GetID() string }); ok {
+		return e.GetID()
+	}
+	return ""
+}
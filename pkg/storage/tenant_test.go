@@ -0,0 +1,164 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/account"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/kv"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+}
+
+func TestTenantRepository(t *testing.T) {
+	store := kv.NewStore(kv.NewMemoryKV())
+	repo := storage.NewTenantRepository(store, &account.Account{}, &transaction.Transaction{})
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	// Creates are stamped with the context tenant
+	cash := &account.Account{ID: "cash", Code: "1000", Name: "Cash", Type: account.Asset}
+	require.NoError(t, repo.Create(acme, cash))
+	assert.Equal(t, "acme", cash.EntityID)
+	require.NoError(t, repo.Create(globex, &account.Account{ID: "bank", Code: "1010", Name: "Bank", Type: account.Asset}))
+	require.NoError(t, repo.Create(context.Background(), &account.Account{ID: "shared", Code: "9000", Name: "Suspense", Type: account.Asset}))
+	assert.ErrorIs(t, repo.Create(acme, &account.Account{ID: "x", Code: "1", EntityID: "globex"}), storage.ErrTenantMismatch)
+
+	t.Run("Read", func(t *testing.T) {
+		var read account.Account
+		require.NoError(t, repo.Read(acme, "cash", &read))
+		assert.Equal(t, "Cash", read.Name)
+
+		var foreign account.Account
+		assert.ErrorIs(t, repo.Read(acme, "bank", &foreign), storage.ErrTenantMismatch)
+		assert.Empty(t, foreign.Name, "another tenant's data never reaches the caller")
+		// Entities without a tenant are hidden from scoped reads as they are
+		// from scoped queries
+		assert.ErrorIs(t, repo.Read(acme, "shared", &account.Account{}), storage.ErrTenantMismatch)
+		assert.NoError(t, repo.Read(context.Background(), "shared", &account.Account{}))
+		assert.NoError(t, repo.Read(context.Background(), "bank", &account.Account{}))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		var bank account.Account
+		require.NoError(t, repo.Read(globex, "bank", &bank))
+		bank.Name = "Hijacked"
+		assert.ErrorIs(t, repo.Update(acme, &bank), storage.ErrTenantMismatch)
+
+		// Relabelling the entity doesn't get past the stored tenant
+		bank.EntityID = "acme"
+		assert.ErrorIs(t, repo.Update(acme, &bank), storage.ErrTenantMismatch)
+
+		var read account.Account
+		require.NoError(t, repo.Read(globex, "bank", &read))
+		assert.Equal(t, "Bank", read.Name)
+
+		// Clearing the tenant keeps the stored one
+		read.EntityID = ""
+		read.Name = "Operating"
+		require.NoError(t, repo.Update(globex, &read))
+		assert.Equal(t, "globex", read.EntityID)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		var accounts []*account.Account
+		require.NoError(t, repo.Query(acme, storage.Query{}, &accounts))
+		require.Len(t, accounts, 1)
+		assert.Equal(t, "cash", accounts[0].ID)
+
+		count, err := repo.Count(globex, storage.Query{Filters: []storage.Filter{{Field: "entity_type", Value: &account.Account{}}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = repo.Count(context.Background(), storage.Query{Filters: []storage.Filter{{Field: "entity_type", Value: &account.Account{}}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Delete(globex, "cash"), storage.ErrTenantMismatch)
+		require.NoError(t, repo.Read(acme, "cash", &account.Account{}))
+		require.NoError(t, repo.Delete(acme, "cash"))
+		assert.Error(t, repo.Read(acme, "cash", &account.Account{}))
+	})
+}
+
+func TestTenantRepositoryForwarding(t *testing.T) {
+	store := kv.NewStore(kv.NewMemoryKV())
+	repo := storage.NewTenantRepository(store, &account.Account{}, &transaction.Transaction{})
+	acme := tenant.WithTenant(context.Background(), "acme")
+
+	var (
+		_ storage.TransactionManager   = repo
+		_ storage.AuditableRepository  = repo
+		_ storage.AuditLogRepository   = repo
+		_ storage.SearchableRepository = repo
+	)
+
+	t.Run("Batches are atomic", func(t *testing.T) {
+		processor := transaction.NewBasicTransactionProcessor(repo)
+		draft := func(id string) *transaction.Transaction {
+			return &transaction.Transaction{
+				ID:     id,
+				Type:   transaction.Journal,
+				Status: transaction.Draft,
+				Date:   time.Now(),
+				Entries: []transaction.Entry{
+					{AccountID: "cash", Amount: usd(100), Type: transaction.Debit},
+					{AccountID: "revenue", Amount: usd(100), Type: transaction.Credit},
+				},
+			}
+		}
+		first := draft("TX1")
+		require.NoError(t, repo.Create(acme, first))
+
+		// The second draft was never stored, so the batch fails after the
+		// first posting is written
+		err := processor.ProcessTransactionBatch(acme, []*transaction.Transaction{first, draft("TX2")})
+		require.Error(t, err)
+
+		var stored transaction.Transaction
+		require.NoError(t, repo.Read(acme, "TX1", &stored))
+		assert.Equal(t, transaction.Draft, stored.Status)
+		assert.Equal(t, int64(1), stored.Version, "the posting was rolled back, not undone")
+	})
+
+	t.Run("Audit is scoped", func(t *testing.T) {
+		globex := tenant.WithTenant(context.Background(), "globex")
+		require.NoError(t, repo.Create(globex, &account.Account{ID: "bank", Code: "1010", Name: "Bank", Type: account.Asset}))
+
+		trail, err := repo.GetAuditTrail(acme, "TX1")
+		require.NoError(t, err)
+		require.Len(t, trail, 1)
+		assert.Equal(t, "CREATE", trail[0].Operation)
+
+		trail, err = repo.GetAuditTrail(acme, "bank")
+		require.NoError(t, err)
+		assert.Empty(t, trail)
+
+		page, err := repo.QueryAuditTrail(globex, storage.AuditFilter{Pagination: &storage.Pagination{Limit: 10}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), page.Total)
+		require.Len(t, page.Entries, 1)
+		assert.Equal(t, "bank", page.Entries[0].EntityID)
+
+		entries, err := repo.QueryAudit(context.Background(), storage.AuditQuery{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := repo.BeginTransaction(acme)
+		assert.ErrorIs(t, err, storage.ErrNotSupported)
+	})
+}
@@ -2,9 +2,23 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// ErrNotFound is returned, wrapped, by every backend when an entity does
+// not exist
+var ErrNotFound = errors.New("entity not found")
+
+// ErrAlreadyExists is returned, wrapped, by every backend when an entity
+// is created with the ID of a stored one
+var ErrAlreadyExists = errors.New("entity already exists")
+
+// ErrNotSupported is returned by repository wrappers when the repository
+// they wrap lacks an optional capability
+var ErrNotSupported = errors.New("operation not supported by the repository")
+
 // Filter represents a query filter condition
 type Filter struct {
 	Field    string
@@ -29,6 +43,8 @@ type Query struct {
 	Filters    []Filter
 	Sort       []Sort
 	Pagination *Pagination
+	// Whether soft-deleted entities are matched; they are left out by default
+	Deleted DeletedMode
 }
 
 // AuditEntry represents an audit log entry
@@ -42,6 +58,83 @@ type AuditEntry struct {
 	PreviousState interface{}
 	NewState      interface{}
 	Metadata      map[string]interface{}
+
+	// Position of the entry in the store's audit chain
+	Sequence int64
+	// Digest of the new state captured when the entry was recorded
+	StateHash string
+	// Hash of the preceding entry in the chain
+	PreviousHash string
+	// Hash sealing this entry and its link to the previous one
+	Hash string
+	// Signature of Hash by the store's audit signer, if it has one
+	Signature string
+}
+
+// AuditQuery selects audit entries. Empty fields match any entry.
+type AuditQuery struct {
+	EntityType string
+	EntityID   string
+	UserID     string
+	Operation  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// Matches reports whether an audit entry satisfies the query
+func (q AuditQuery) Matches(entry AuditEntry) bool {
+	if q.EntityType != "" && entry.EntityType != q.EntityType {
+		return false
+	}
+	if q.EntityID != "" && entry.EntityID != q.EntityID {
+		return false
+	}
+	if q.UserID != "" && entry.UserID != q.UserID {
+		return false
+	}
+	if q.Operation != "" && entry.Operation != q.Operation {
+		return false
+	}
+	if q.From != nil && entry.Timestamp.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && entry.Timestamp.After(*q.To) {
+		return false
+	}
+	return true
+}
+
+// AuditFilter selects a page of audit entries
+type AuditFilter struct {
+	AuditQuery
+	Pagination *Pagination
+}
+
+// AuditPage is a page of audit entries in chain order
+type AuditPage struct {
+	Entries []AuditEntry
+	// Number of entries matching the filter on all pages
+	Total int64
+}
+
+// PageAudit returns the requested page of the audit entries matching a
+// filter, for stores that filter their log in memory
+func PageAudit(entries []AuditEntry, p *Pagination) *AuditPage {
+	page := &AuditPage{Entries: entries, Total: int64(len(entries))}
+	if p == nil {
+		return page
+	}
+	if p.Offset >= page.Total {
+		page.Entries = entries[:0]
+		return page
+	}
+	if p.Offset > 0 {
+		page.Entries = page.Entries[p.Offset:]
+	}
+	if p.Limit > 0 && p.Limit < int64(len(page.Entries)) {
+		page.Entries = page.Entries[:p.Limit]
+	}
+	return page
 }
 
 // VersionInfo represents entity version information
@@ -49,6 +142,9 @@ type VersionInfo struct {
 	Version    int64
 	ModifiedAt time.Time
 	ModifiedBy string
+	// Set while the entity is soft-deleted
+	DeletedAt *time.Time
+	DeletedBy string
 }
 
 // OptimisticLockError indicates a version conflict
@@ -60,7 +156,11 @@ type OptimisticLockError struct {
 }
 
 func (e *OptimisticLockError) Error() string {
-	return "optimistic lock error: version mismatch"
+	if e.EntityID == "" {
+		return "optimistic lock error: version mismatch"
+	}
+	return fmt.Sprintf("optimistic lock error: version mismatch on %s (expected %d, current %d)",
+		e.EntityID, e.ExpectedVersion, e.CurrentVersion)
 }
 
 // Transaction represents a database transaction
@@ -111,6 +211,18 @@ type AuditableRepository interface {
 
 	// GetVersionInfo retrieves version information for an entity
 	GetVersionInfo(ctx context.Context, entityID string) (*VersionInfo, error)
+
+	// QueryAuditTrail retrieves a page of the audit entries matching a
+	// filter, across all entities, in chain order
+	QueryAuditTrail(ctx context.Context, filter AuditFilter) (*AuditPage, error)
+}
+
+// AuditLogRepository exposes the full, ordered audit log of a store
+type AuditLogRepository interface {
+	Repository
+
+	// QueryAudit retrieves audit entries in chain order
+	QueryAudit(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
 }
 
 // SearchOptions represents search parameters
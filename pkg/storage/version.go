@@ -0,0 +1,53 @@
+package storage
+
+// Versioned is implemented by entities that carry the version they were
+// stored at. Stores set the version on every write, so an entity read back
+// can only be updated while nobody else has updated it in between.
+type Versioned interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// CheckVersion returns an OptimisticLockError when an entity was read at a
+// version other than current. Entities at version zero were not read from
+// the store and are not checked.
+func CheckVersion(entity interface{}, entityType, id string, current int64) error {
+	versioned, ok := entity.(interface{ GetVersion() int64 })
+	if !ok || versioned.GetVersion() == 0 || versioned.GetVersion() == current {
+		return nil
+	}
+	return &OptimisticLockError{
+		EntityType:      entityType,
+		EntityID:        id,
+		CurrentVersion:  current,
+		ExpectedVersion: versioned.GetVersion(),
+	}
+}
+
+// SetVersion sets the version of a Versioned entity and returns a function
+// restoring the previous one, for writes that fail
+func SetVersion(entity interface{}, version int64) (restore func()) {
+	versioned, ok := entity.(Versioned)
+	if !ok {
+		return func() {}
+	}
+	previous := versioned.GetVersion()
+	versioned.SetVersion(version)
+	return func() { versioned.SetVersion(previous) }
+}
+
+// KeepVersions returns a function restoring the current versions of the
+// batch entities, for batches that are rolled back
+func KeepVersions(items []BatchItem) (restore func()) {
+	restores := make([]func(), 0, len(items))
+	for _, item := range items {
+		if versioned, ok := item.Entity.(Versioned); ok {
+			restores = append(restores, SetVersion(versioned, versioned.GetVersion()))
+		}
+	}
+	return func() {
+		for _, r := range restores {
+			r()
+		}
+	}
+}
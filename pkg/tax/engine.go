@@ -0,0 +1,137 @@
+package tax
+
+import (
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// Engine adds tax lines to transactions
+type Engine struct {
+	registry *Registry
+}
+
+// NewEngine creates an engine calculating with the codes of a registry
+func NewEngine(registry *Registry) *Engine {
+	return &Engine{registry: registry}
+}
+
+// Apply calculates the tax of the entries tagged with a tax code, using the
+// rate in effect on the transaction's effective date. Credited entries are
+// sales and carry output tax; debited entries are purchases and carry input
+// tax, unless the entry's tax_direction dimension says otherwise, as for
+// credit notes. Each adds a tax line on its own side, posted to the code's
+// output or input account:
+//   - Inclusive amounts are reduced to their net, so the transaction stays
+//     balanced.
+//   - Exclusive amounts are kept and the tax is added to the single
+//     untagged entry on the other side, such as the receivable or payable.
+//
+// Input tax without an input account is not recoverable and is added to
+// the cost instead. Entries whose tax has been calculated are marked, so
+// applying the engine again changes nothing.
+func (e *Engine) Apply(tx *transaction.Transaction) error {
+	date := tx.Date
+	if tx.EffectiveDate != nil {
+		date = *tx.EffectiveDate
+	}
+
+	entries := make([]transaction.Entry, 0, len(tx.Entries))
+	taxLines := make([]transaction.Entry, 0)
+	grossUp := make(map[transaction.EntryType]decimal.Decimal)
+	for _, entry := range tx.Entries {
+		code := entry.Dimensions[DimensionCode]
+		if code == "" || entry.Dimensions[DimensionRole] != "" {
+			entries = append(entries, entry)
+			continue
+		}
+		c, err := e.registry.Lookup(code, date)
+		if err != nil {
+			return err
+		}
+
+		calc := c.Calculate(entry.Amount)
+		direction := Direction(entry.Dimensions[DimensionDirection])
+		if direction == "" {
+			direction = Output
+			if entry.Type == transaction.Debit {
+				direction = Input
+			}
+		}
+		accountID := c.OutputAccountID
+		if direction == Input {
+			accountID = c.InputAccountID
+		}
+
+		base := entry
+		base.Dimensions = copyDimensions(entry.Dimensions)
+		base.Dimensions[DimensionRole] = RoleBase
+		base.Amount = calc.Net
+		if accountID == "" {
+			// Tax that cannot be reclaimed is part of the cost
+			base.Amount = calc.Gross
+		} else if !calc.Tax.IsZero() {
+			taxLines = append(taxLines, transaction.Entry{
+				AccountID:   accountID,
+				Amount:      calc.Tax,
+				Type:        entry.Type,
+				Description: fmt.Sprintf("%s on %s", taxName(c), entry.Description),
+				PartyID:     entry.PartyID,
+				Dimensions: map[string]string{
+					DimensionCode:         c.Code,
+					DimensionRole:         RoleTax,
+					DimensionDirection:    string(direction),
+					DimensionJurisdiction: c.Jurisdiction,
+					DimensionBase:         calc.Net.Amount.StringFixed(calc.Net.Scale()),
+					DimensionRate:         c.Rate.String(),
+				},
+			})
+		}
+		entries = append(entries, base)
+
+		if !c.Inclusive && !calc.Tax.IsZero() {
+			side := entry.Type.Reverse()
+			grossUp[side] = grossUp[side].Add(calc.Tax.Amount)
+		}
+	}
+
+	for _, side := range []transaction.EntryType{transaction.Debit, transaction.Credit} {
+		amount, ok := grossUp[side]
+		if !ok {
+			continue
+		}
+		counter := -1
+		for i, entry := range entries {
+			if entry.Type == side && entry.Dimensions[DimensionCode] == "" {
+				if counter >= 0 {
+					return fmt.Errorf("%w: several %s entries could carry the tax", ErrNoCounterEntry, side)
+				}
+				counter = i
+			}
+		}
+		if counter < 0 {
+			return fmt.Errorf("%w: no untagged %s entry", ErrNoCounterEntry, side)
+		}
+		entries[counter].Amount.Amount = entries[counter].Amount.Amount.Add(amount)
+	}
+
+	tx.Entries = append(entries, taxLines...)
+	return nil
+}
+
+// taxName returns the display name of a code
+func taxName(c Code) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Code
+}
+
+func copyDimensions(dims map[string]string) map[string]string {
+	out := make(map[string]string, len(dims)+1)
+	for k, v := range dims {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// ReportLine totals the tax of one code, rate and currency over a period
+type ReportLine struct {
+	Jurisdiction string          `json:"jurisdiction"`
+	Code         string          `json:"code"`
+	Rate         decimal.Decimal `json:"rate"`
+	Currency     string          `json:"currency"`
+	// Net sales and the output tax charged on them
+	OutputBase decimal.Decimal `json:"output_base"`
+	OutputTax  decimal.Decimal `json:"output_tax"`
+	// Net purchases and the input tax reclaimable on them
+	InputBase decimal.Decimal `json:"input_base"`
+	InputTax  decimal.Decimal `json:"input_tax"`
+}
+
+// JurisdictionTotal is the tax owed to one jurisdiction in one currency
+type JurisdictionTotal struct {
+	Jurisdiction string          `json:"jurisdiction"`
+	Currency     string          `json:"currency"`
+	OutputTax    decimal.Decimal `json:"output_tax"`
+	InputTax     decimal.Decimal `json:"input_tax"`
+	// Output less input tax; negative when a refund is due
+	NetPayable decimal.Decimal `json:"net_payable"`
+}
+
+// Report is a tax liability report, the figures of a VAT or GST return
+type Report struct {
+	Start         time.Time           `json:"start"`
+	End           time.Time           `json:"end"`
+	Lines         []ReportLine        `json:"lines"`
+	Jurisdictions []JurisdictionTotal `json:"jurisdictions"`
+}
+
+// Reporter builds tax liability reports from posted transactions
+type Reporter struct {
+	repo storage.Repository
+}
+
+// NewReporter creates a reporter reading transactions from a repository
+func NewReporter(repo storage.Repository) *Reporter {
+	return &Reporter{repo: repo}
+}
+
+// Liability totals the tax lines of transactions posted with dates in a
+// period, optionally for one jurisdiction only. Tax lines on the unusual
+// side, such as those of reversals and credit notes, reduce the totals.
+func (r *Reporter) Liability(ctx context.Context, start, end time.Time, jurisdiction string) (*Report, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "status", Operator: "=", Value: transaction.Posted},
+			{Field: "date", Operator: ">=", Value: start},
+			{Field: "date", Operator: "<=", Value: end},
+			{Field: "entries.dimensions." + DimensionRole, Operator: "=", Value: RoleTax},
+		},
+	}
+	var txs []*transaction.Transaction
+	if err := r.repo.Query(ctx, query, &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	type key struct {
+		code, rate, currency string
+	}
+	lines := make(map[key]*ReportLine)
+	for _, tx := range txs {
+		if tx.Status != transaction.Posted || tx.Date.Before(start) || tx.Date.After(end) {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			dims := entry.Dimensions
+			if dims[DimensionRole] != RoleTax {
+				continue
+			}
+			if jurisdiction != "" && dims[DimensionJurisdiction] != jurisdiction {
+				continue
+			}
+			base, err := decimal.NewFromString(dims[DimensionBase])
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s has a tax line without a base: %w", tx.ID, err)
+			}
+			rate, err := decimal.NewFromString(dims[DimensionRate])
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s has a tax line without a rate: %w", tx.ID, err)
+			}
+
+			k := key{code: dims[DimensionCode], rate: rate.String(), currency: entry.Amount.Currency}
+			line, ok := lines[k]
+			if !ok {
+				line = &ReportLine{
+					Jurisdiction: dims[DimensionJurisdiction],
+					Code:         k.code,
+					Rate:         rate,
+					Currency:     k.currency,
+				}
+				lines[k] = line
+			}
+
+			amount := entry.Amount.Amount
+			switch Direction(dims[DimensionDirection]) {
+			case Output:
+				if entry.Type == transaction.Debit {
+					amount, base = amount.Neg(), base.Neg()
+				}
+				line.OutputBase = line.OutputBase.Add(base)
+				line.OutputTax = line.OutputTax.Add(amount)
+			case Input:
+				if entry.Type == transaction.Credit {
+					amount, base = amount.Neg(), base.Neg()
+				}
+				line.InputBase = line.InputBase.Add(base)
+				line.InputTax = line.InputTax.Add(amount)
+			}
+		}
+	}
+
+	report := &Report{
+		Start:         start,
+		End:           end,
+		Lines:         make([]ReportLine, 0, len(lines)),
+		Jurisdictions: make([]JurisdictionTotal, 0),
+	}
+	for _, line := range lines {
+		report.Lines = append(report.Lines, *line)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i], report.Lines[j]
+		if a.Jurisdiction != b.Jurisdiction {
+			return a.Jurisdiction < b.Jurisdiction
+		}
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Rate.LessThan(b.Rate)
+	})
+
+	for _, line := range report.Lines {
+		n := len(report.Jurisdictions)
+		if n == 0 || report.Jurisdictions[n-1].Jurisdiction != line.Jurisdiction || report.Jurisdictions[n-1].Currency != line.Currency {
+			report.Jurisdictions = append(report.Jurisdictions, JurisdictionTotal{Jurisdiction: line.Jurisdiction, Currency: line.Currency})
+			n++
+		}
+		total := &report.Jurisdictions[n-1]
+		total.OutputTax = total.OutputTax.Add(line.OutputTax)
+		total.InputTax = total.InputTax.Add(line.InputTax)
+		total.NetPayable = total.OutputTax.Sub(total.InputTax)
+	}
+	return report, nil
+}
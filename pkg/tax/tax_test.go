@@ -0,0 +1,212 @@
+package tax
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rateChange = time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+func gbp(amount string) money.Money {
+	return money.Money{Amount: decimal.RequireFromString(amount), Currency: "GBP"}
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Code{
+		Code: "VAT", Name: "VAT", Jurisdiction: "GB", Rate: decimal.RequireFromString("0.2"),
+		OutputAccountID: "2200", InputAccountID: "1400", EffectiveTo: rateChange,
+	}))
+	require.NoError(t, registry.Register(Code{
+		Code: "VAT", Name: "VAT", Jurisdiction: "GB", Rate: decimal.RequireFromString("0.175"),
+		OutputAccountID: "2200", InputAccountID: "1400", EffectiveFrom: rateChange,
+	}))
+	require.NoError(t, registry.Register(Code{
+		Code: "VAT-INC", Jurisdiction: "GB", Rate: decimal.RequireFromString("0.2"), Inclusive: true,
+		OutputAccountID: "2200", InputAccountID: "1400",
+	}))
+	require.NoError(t, registry.Register(Code{
+		Code: "CA-SALES", Jurisdiction: "US-CA", Rate: decimal.RequireFromString("0.0725"),
+		OutputAccountID: "2210",
+	}))
+	return registry
+}
+
+func taxed(code string) map[string]string {
+	return map[string]string{DimensionCode: code}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	c, err := registry.Lookup("VAT", rateChange.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, "0.2", c.Rate.String())
+	c, err = registry.Lookup("VAT", rateChange)
+	require.NoError(t, err)
+	assert.Equal(t, "0.175", c.Rate.String())
+
+	_, err = registry.Lookup("GST", rateChange)
+	assert.ErrorIs(t, err, ErrUnknownCode)
+
+	err = registry.Register(Code{Code: "VAT", Jurisdiction: "GB", Rate: decimal.NewFromInt(0), OutputAccountID: "2200", EffectiveFrom: rateChange.AddDate(1, 0, 0)})
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	err = registry.Register(Code{Code: "X", Rate: decimal.NewFromInt(0), OutputAccountID: "2200"})
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	assert.Len(t, registry.Codes("GB"), 3)
+	assert.Len(t, registry.Codes(""), 4)
+
+	calc := Code{Rate: decimal.RequireFromString("0.2"), Inclusive: true}.Calculate(gbp("100"))
+	assert.Equal(t, "16.67", calc.Tax.Amount.String())
+	assert.Equal(t, "83.33", calc.Net.Amount.String())
+}
+
+func TestEngineApply(t *testing.T) {
+	engine := NewEngine(newTestRegistry(t))
+	june := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	// Exclusive output tax grosses up the receivable
+	sale := &transaction.Transaction{
+		ID:   "TX1",
+		Date: june,
+		Entries: []transaction.Entry{
+			{AccountID: "1200", Amount: gbp("150"), Type: transaction.Debit, PartyID: "CUST1"},
+			{AccountID: "4000", Amount: gbp("100"), Type: transaction.Credit, Description: "Consulting", Dimensions: taxed("VAT")},
+			{AccountID: "4100", Amount: gbp("50"), Type: transaction.Credit, Description: "Support", Dimensions: taxed("VAT")},
+		},
+	}
+	require.NoError(t, engine.Apply(sale))
+	require.Len(t, sale.Entries, 5)
+	assert.Equal(t, "180", sale.Entries[0].Amount.Amount.String())
+	assert.Equal(t, "100", sale.Entries[1].Amount.Amount.String())
+	assert.Equal(t, RoleBase, sale.Entries[1].Dimensions[DimensionRole])
+	assert.Equal(t, "2200", sale.Entries[3].AccountID)
+	assert.Equal(t, transaction.Credit, sale.Entries[3].Type)
+	assert.Equal(t, "20", sale.Entries[3].Amount.Amount.String())
+	assert.Equal(t, string(Output), sale.Entries[3].Dimensions[DimensionDirection])
+	assert.Equal(t, "100.00", sale.Entries[3].Dimensions[DimensionBase])
+	assert.Equal(t, "VAT on Consulting", sale.Entries[3].Description)
+
+	result, err := (&transaction.BasicValidator{}).Validate(context.Background(), sale)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+
+	// Applying again changes nothing
+	require.NoError(t, engine.Apply(sale))
+	assert.Len(t, sale.Entries, 5)
+
+	// Inclusive input tax is split out of the expense
+	purchase := &transaction.Transaction{
+		ID:   "TX2",
+		Date: june,
+		Entries: []transaction.Entry{
+			{AccountID: "6000", Amount: gbp("120"), Type: transaction.Debit, Dimensions: taxed("VAT-INC")},
+			{AccountID: "1000", Amount: gbp("120"), Type: transaction.Credit},
+		},
+	}
+	require.NoError(t, engine.Apply(purchase))
+	require.Len(t, purchase.Entries, 3)
+	assert.Equal(t, "100", purchase.Entries[0].Amount.Amount.String())
+	assert.Equal(t, "1400", purchase.Entries[2].AccountID)
+	assert.Equal(t, transaction.Debit, purchase.Entries[2].Type)
+	assert.Equal(t, "20", purchase.Entries[2].Amount.Amount.String())
+
+	// Sales tax without an input account is added to the cost of purchases
+	cost := &transaction.Transaction{
+		ID:   "TX3",
+		Date: june,
+		Entries: []transaction.Entry{
+			{AccountID: "6000", Amount: gbp("100"), Type: transaction.Debit, Dimensions: taxed("CA-SALES")},
+			{AccountID: "2000", Amount: gbp("100"), Type: transaction.Credit},
+		},
+	}
+	require.NoError(t, engine.Apply(cost))
+	require.Len(t, cost.Entries, 2)
+	assert.Equal(t, "107.25", cost.Entries[0].Amount.Amount.String())
+	assert.Equal(t, "107.25", cost.Entries[1].Amount.Amount.String())
+
+	ambiguous := &transaction.Transaction{
+		Date: june,
+		Entries: []transaction.Entry{
+			{AccountID: "1200", Amount: gbp("50"), Type: transaction.Debit},
+			{AccountID: "1210", Amount: gbp("50"), Type: transaction.Debit},
+			{AccountID: "4000", Amount: gbp("100"), Type: transaction.Credit, Dimensions: taxed("VAT")},
+		},
+	}
+	assert.ErrorIs(t, engine.Apply(ambiguous), ErrNoCounterEntry)
+}
+
+func TestReporterLiability(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	engine := NewEngine(newTestRegistry(t))
+
+	post := func(id string, date time.Time, entries ...transaction.Entry) {
+		tx := &transaction.Transaction{ID: id, Type: transaction.Journal, Status: transaction.Posted, Date: date, Entries: entries}
+		require.NoError(t, engine.Apply(tx))
+		require.NoError(t, store.Create(ctx, tx))
+	}
+	june := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC)
+
+	post("S1", june,
+		transaction.Entry{AccountID: "1200", Amount: gbp("1000"), Type: transaction.Debit},
+		transaction.Entry{AccountID: "4000", Amount: gbp("1000"), Type: transaction.Credit, Dimensions: taxed("VAT")},
+	)
+	post("S2", july,
+		transaction.Entry{AccountID: "1200", Amount: gbp("200"), Type: transaction.Debit},
+		transaction.Entry{AccountID: "4000", Amount: gbp("200"), Type: transaction.Credit, Dimensions: taxed("VAT")},
+	)
+	post("P1", july,
+		transaction.Entry{AccountID: "6000", Amount: gbp("60"), Type: transaction.Debit, Dimensions: taxed("VAT-INC")},
+		transaction.Entry{AccountID: "1000", Amount: gbp("60"), Type: transaction.Credit},
+	)
+	// A credit note reverses part of the June sale
+	post("C1", july,
+		transaction.Entry{AccountID: "4000", Amount: gbp("100"), Type: transaction.Debit, Dimensions: map[string]string{
+			DimensionCode:      "VAT",
+			DimensionDirection: string(Output),
+		}},
+		transaction.Entry{AccountID: "1200", Amount: gbp("100"), Type: transaction.Credit},
+	)
+	// Outside the period
+	post("S3", july.AddDate(0, 2, 0),
+		transaction.Entry{AccountID: "1200", Amount: gbp("500"), Type: transaction.Debit},
+		transaction.Entry{AccountID: "4000", Amount: gbp("500"), Type: transaction.Credit, Dimensions: taxed("VAT")},
+	)
+
+	reporter := NewReporter(store)
+	report, err := reporter.Liability(ctx, june.AddDate(0, 0, -14), july.AddDate(0, 0, 16), "")
+	require.NoError(t, err)
+	require.Len(t, report.Lines, 3)
+
+	assert.Equal(t, "VAT", report.Lines[0].Code)
+	assert.Equal(t, "0.175", report.Lines[0].Rate.String())
+	assert.Equal(t, "100", report.Lines[0].OutputBase.String())
+	assert.Equal(t, "17.5", report.Lines[0].OutputTax.String())
+	assert.Equal(t, "0.2", report.Lines[1].Rate.String())
+	assert.Equal(t, "200", report.Lines[1].OutputTax.String())
+	assert.Equal(t, "VAT-INC", report.Lines[2].Code)
+	assert.Equal(t, "50", report.Lines[2].InputBase.String())
+	assert.Equal(t, "10", report.Lines[2].InputTax.String())
+
+	require.Len(t, report.Jurisdictions, 1)
+	gb := report.Jurisdictions[0]
+	assert.Equal(t, "GB", gb.Jurisdiction)
+	assert.Equal(t, "217.5", gb.OutputTax.String())
+	assert.Equal(t, "10", gb.InputTax.String())
+	assert.Equal(t, "207.5", gb.NetPayable.String())
+
+	report, err = reporter.Liability(ctx, june.AddDate(0, 0, -14), july.AddDate(0, 0, 16), "US-CA")
+	require.NoError(t, err)
+	assert.Empty(t, report.Lines)
+}
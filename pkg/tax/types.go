@@ -0,0 +1,205 @@
+// Package tax calculates sales taxes such as VAT and GST. Tax codes carry a
+// rate, the jurisdiction levying the tax and the accounts it is posted to;
+// an Engine adds tax lines to transactions whose entries are tagged with a
+// code, and a Reporter totals the tax collected and paid per jurisdiction
+// for tax returns.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownCode    = errors.New("unknown tax code")
+	ErrInvalidCode    = errors.New("invalid tax code")
+	ErrNoCounterEntry = errors.New("no counter entry to carry the tax")
+)
+
+// Entry dimensions used to tag taxable entries and the tax lines added for
+// them
+const (
+	// Tax code applying to an entry
+	DimensionCode = "tax_code"
+	// Role of a tagged entry, RoleBase or RoleTax, set once tax is calculated
+	DimensionRole = "tax_role"
+	// Direction of a tax line, output or input; may be set on a tagged entry
+	// whose side does not tell
+	DimensionDirection = "tax_direction"
+	// Jurisdiction of a tax line
+	DimensionJurisdiction = "tax_jurisdiction"
+	// Net amount a tax line was calculated on
+	DimensionBase = "tax_base"
+	// Rate a tax line was calculated at
+	DimensionRate = "tax_rate"
+)
+
+// Roles of tagged entries
+const (
+	RoleBase = "base"
+	RoleTax  = "tax"
+)
+
+// Direction tells tax charged on sales from tax paid on purchases
+type Direction string
+
+const (
+	// Output tax is charged to customers and owed to the authority
+	Output Direction = "OUTPUT"
+	// Input tax is paid to suppliers and may be reclaimed
+	Input Direction = "INPUT"
+)
+
+// Code defines a tax rate levied by a jurisdiction. A code may be
+// registered several times with consecutive effective periods as its rate
+// changes.
+type Code struct {
+	// Code identifying the tax (e.g., "GB-VAT20")
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Authority levying the tax, such as a country or state (e.g., "GB", "US-CA")
+	Jurisdiction string `json:"jurisdiction"`
+	// Rate applied to the net amount (e.g., 0.2 for 20%)
+	Rate decimal.Decimal `json:"rate"`
+	// Whether tagged amounts include the tax rather than exclude it
+	Inclusive bool `json:"inclusive"`
+	// Liability account output tax on sales is credited to
+	OutputAccountID string `json:"output_account_id"`
+	// Account input tax on purchases is debited to; without one, input tax
+	// is not recoverable and is added to the cost
+	InputAccountID string `json:"input_account_id,omitempty"`
+	// Period the rate applies to; zero times leave it open-ended
+	EffectiveFrom time.Time `json:"effective_from"`
+	EffectiveTo   time.Time `json:"effective_to"`
+}
+
+// Covers returns true if the code's rate applies on a date
+func (c Code) Covers(date time.Time) bool {
+	if !c.EffectiveFrom.IsZero() && date.Before(c.EffectiveFrom) {
+		return false
+	}
+	return c.EffectiveTo.IsZero() || date.Before(c.EffectiveTo)
+}
+
+// Validate checks that the code can be used for calculation
+func (c Code) Validate() error {
+	switch {
+	case c.Code == "":
+		return fmt.Errorf("%w: code is required", ErrInvalidCode)
+	case c.Jurisdiction == "":
+		return fmt.Errorf("%w: %s has no jurisdiction", ErrInvalidCode, c.Code)
+	case c.Rate.IsNegative():
+		return fmt.Errorf("%w: %s has a negative rate", ErrInvalidCode, c.Code)
+	case c.OutputAccountID == "":
+		return fmt.Errorf("%w: %s has no output tax account", ErrInvalidCode, c.Code)
+	case !c.EffectiveTo.IsZero() && !c.EffectiveTo.After(c.EffectiveFrom):
+		return fmt.Errorf("%w: %s ends before it starts", ErrInvalidCode, c.Code)
+	}
+	return nil
+}
+
+// Calculate splits an amount into its net and tax parts. Inclusive codes
+// treat the amount as gross; exclusive ones as net. Tax is rounded to the
+// currency's minor units.
+func (c Code) Calculate(amount money.Money) Calculation {
+	if c.Inclusive {
+		divisor := decimal.NewFromInt(1).Add(c.Rate)
+		tax := money.Money{Amount: amount.Amount.Mul(c.Rate).Div(divisor), Currency: amount.Currency}.RoundToCurrency()
+		net := money.Money{Amount: amount.Amount.Sub(tax.Amount), Currency: amount.Currency}
+		return Calculation{Net: net, Tax: tax, Gross: amount}
+	}
+	tax := money.Money{Amount: amount.Amount.Mul(c.Rate), Currency: amount.Currency}.RoundToCurrency()
+	gross := money.Money{Amount: amount.Amount.Add(tax.Amount), Currency: amount.Currency}
+	return Calculation{Net: amount, Tax: tax, Gross: gross}
+}
+
+// Calculation is the result of applying a tax code to an amount
+type Calculation struct {
+	Net   money.Money `json:"net"`
+	Tax   money.Money `json:"tax"`
+	Gross money.Money `json:"gross"`
+}
+
+// Registry holds the tax codes known to the system
+type Registry struct {
+	mu    sync.RWMutex
+	codes map[string][]Code
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{codes: make(map[string][]Code)}
+}
+
+// Register adds a tax code. Registering a code again adds a rate for
+// another effective period, which may not overlap those already known.
+func (r *Registry) Register(c Code) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.codes[c.Code] {
+		if overlaps(existing, c) {
+			return fmt.Errorf("%w: %s already has a rate for the period", ErrInvalidCode, c.Code)
+		}
+	}
+	versions := append(r.codes[c.Code], c)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].EffectiveFrom.Before(versions[j].EffectiveFrom)
+	})
+	r.codes[c.Code] = versions
+	return nil
+}
+
+// Lookup returns the version of a tax code in effect on a date
+func (r *Registry) Lookup(code string, date time.Time) (Code, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions, ok := r.codes[code]
+	if !ok {
+		return Code{}, fmt.Errorf("%w: %s", ErrUnknownCode, code)
+	}
+	for _, c := range versions {
+		if c.Covers(date) {
+			return c, nil
+		}
+	}
+	return Code{}, fmt.Errorf("%w: %s has no rate on %s", ErrUnknownCode, code, date.Format("2006-01-02"))
+}
+
+// Codes returns every registered version of the codes of a jurisdiction,
+// or of all jurisdictions when it is empty, sorted by code and start
+func (r *Registry) Codes(jurisdiction string) []Code {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	codes := make([]Code, 0)
+	for _, versions := range r.codes {
+		for _, c := range versions {
+			if jurisdiction == "" || c.Jurisdiction == jurisdiction {
+				codes = append(codes, c)
+			}
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].Code != codes[j].Code {
+			return codes[i].Code < codes[j].Code
+		}
+		return codes[i].EffectiveFrom.Before(codes[j].EffectiveFrom)
+	})
+	return codes
+}
+
+// overlaps returns true if two codes' effective periods share a day
+func overlaps(a, b Code) bool {
+	aEndsFirst := !a.EffectiveTo.IsZero() && !a.EffectiveTo.After(b.EffectiveFrom)
+	bEndsFirst := !b.EffectiveTo.IsZero() && !b.EffectiveTo.After(a.EffectiveFrom)
+	return !aEndsFirst && !bEndsFirst
+}
@@ -0,0 +1,41 @@
+// Package tenant defines the single convention for carrying the tenant a
+// request acts for on its context. In finlib a tenant is a legal entity, so
+// the tenant ID is the entity ID used on accounts, transactions and reports.
+// Storage scoping, validator selection, event metadata and report generation
+// all read the tenant through this package. It has no dependencies so that
+// any module can honour it.
+package tenant
+
+import (
+	"context"
+)
+
+// MetadataKey is the key under which the tenant is recorded in event and
+// audit metadata
+const MetadataKey = "tenant_id"
+
+type contextKey struct{}
+
+// WithTenant returns a context scoped to a tenant. An empty ID leaves the
+// context unscoped.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant a context is scoped to, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ID returns the tenant a context is scoped to, or an empty string
+func ID(ctx context.Context) string {
+	id, _ := FromContext(ctx)
+	return id
+}
+
+// FromMetadata returns the tenant recorded in metadata, if any
+func FromMetadata(metadata map[string]interface{}) (string, bool) {
+	id, ok := metadata[MetadataKey].(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,63 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the events and contexts it receives
+type recordingHandler struct {
+	events  []event.Event
+	tenants []string
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, e event.Event) error {
+	h.events = append(h.events, e)
+	h.tenants = append(h.tenants, tenant.ID(ctx))
+	return nil
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := tenant.FromContext(ctx)
+	assert.False(t, ok)
+	assert.Empty(t, tenant.ID(tenant.WithTenant(ctx, "")))
+
+	scoped := tenant.WithTenant(ctx, "E1")
+	assert.Equal(t, "E1", tenant.ID(scoped))
+
+	// Entity scoping shares the same convention
+	id, ok := entity.FromContext(scoped)
+	assert.True(t, ok)
+	assert.Equal(t, "E1", id)
+	assert.Equal(t, "E2", tenant.ID(entity.WithEntity(ctx, "E2")))
+}
+
+func TestEventMetadata(t *testing.T) {
+	bus := event.NewMemoryBus()
+	handler := &recordingHandler{}
+	require.NoError(t, bus.Subscribe(event.TransactionPosted, handler))
+
+	original := event.Event{Type: event.TransactionPosted, Metadata: map[string]interface{}{"source": "api"}}
+	require.NoError(t, bus.Publish(tenant.WithTenant(context.Background(), "E1"), original))
+	require.NoError(t, bus.Publish(context.Background(), event.Event{
+		Type:     event.TransactionPosted,
+		Metadata: map[string]interface{}{tenant.MetadataKey: "E2"},
+	}))
+
+	require.Len(t, handler.events, 2)
+	id, ok := event.TenantID(handler.events[0])
+	assert.True(t, ok)
+	assert.Equal(t, "E1", id)
+	assert.Equal(t, "api", handler.events[0].Metadata["source"])
+	assert.NotContains(t, original.Metadata, tenant.MetadataKey)
+
+	// Handlers run in the tenant recorded on the event
+	assert.Equal(t, []string{"E1", "E2"}, handler.tenants)
+}
@@ -0,0 +1,192 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+)
+
+var (
+	ErrDocumentNotFound   = errors.New("document not found")
+	ErrAttachmentTampered = errors.New("document does not match attachment hash")
+)
+
+// AttachmentType classifies a supporting document
+type AttachmentType string
+
+const (
+	AttachmentReceipt  AttachmentType = "RECEIPT"
+	AttachmentInvoice  AttachmentType = "INVOICE"
+	AttachmentContract AttachmentType = "CONTRACT"
+	AttachmentOther    AttachmentType = "OTHER"
+)
+
+// Attachment links a supporting document to a transaction
+type Attachment struct {
+	ID   string         `json:"id"`
+	Type AttachmentType `json:"type"`
+	// Location of the document in its DocumentStore
+	URI string `json:"uri"`
+	// Hex-encoded SHA-256 of the document contents
+	Hash        string    `json:"hash"`
+	Name        string    `json:"name,omitempty" encrypt:"true"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	AttachedBy  string    `json:"attached_by,omitempty"`
+	AttachedAt  time.Time `json:"attached_at"`
+}
+
+// DocumentStore persists the contents of supporting documents
+type DocumentStore interface {
+	// Put stores a document and returns an attachment describing it
+	Put(ctx context.Context, name string, attachmentType AttachmentType, contentType string, r io.Reader) (*Attachment, error)
+
+	// Get opens the document an attachment refers to
+	Get(ctx context.Context, uri string) (io.ReadCloser, error)
+
+	// Delete removes a document
+	Delete(ctx context.Context, uri string) error
+}
+
+// HashDocument returns the hex-encoded SHA-256 of a document
+func HashDocument(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// VerifyAttachment checks that the stored document still matches the hash
+// recorded on the attachment
+func VerifyAttachment(ctx context.Context, store DocumentStore, att Attachment) error {
+	r, err := store.Get(ctx, att.URI)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hash, _, err := HashDocument(r)
+	if err != nil {
+		return fmt.Errorf("failed to read document %s: %w", att.URI, err)
+	}
+	if hash != att.Hash {
+		return fmt.Errorf("%w: %s", ErrAttachmentTampered, att.ID)
+	}
+	return nil
+}
+
+// AttachDocument links a stored document to a transaction. Attachments may
+// be added in any status, including after posting, though locked
+// transactions need a lock override; the change is recorded in the store's
+// audit trail.
+func (p *BasicTransactionProcessor) AttachDocument(ctx context.Context, txID string, att Attachment) error {
+	if att.ID == "" || att.URI == "" || att.Hash == "" {
+		return fmt.Errorf("attachment ID, URI and hash are required")
+	}
+
+	tx, err := p.GetTransaction(ctx, txID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range tx.Attachments {
+		if existing.ID == att.ID {
+			return fmt.Errorf("attachment %s is already linked to transaction %s", att.ID, txID)
+		}
+	}
+	if ctx, err = p.checkLock(ctx, tx, "attach"); err != nil {
+		return err
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	if att.AttachedBy == "" {
+		att.AttachedBy = caller.UserID
+	}
+	if att.AttachedAt.IsZero() {
+		att.AttachedAt = time.Now()
+	}
+	if caller.Reason == "" {
+		caller.Reason = fmt.Sprintf("attached %s %s", att.Type, att.ID)
+	}
+
+	tx.Attachments = append(tx.Attachments, att)
+	tx.LastModified = time.Now()
+	if err := p.repo.Update(audit.WithCaller(ctx, caller), tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+	return nil
+}
+
+// MemoryDocumentStore keeps documents in memory
+type MemoryDocumentStore struct {
+	mu        sync.RWMutex
+	documents map[string][]byte
+	sequence  int64
+}
+
+// NewMemoryDocumentStore creates an empty in-memory document store
+func NewMemoryDocumentStore() *MemoryDocumentStore {
+	return &MemoryDocumentStore{documents: make(map[string][]byte)}
+}
+
+// Put implements DocumentStore.Put
+func (s *MemoryDocumentStore) Put(ctx context.Context, name string, attachmentType AttachmentType, contentType string, r io.Reader) (*Attachment, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	hash, size, err := HashDocument(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	id := fmt.Sprintf("DOC-%06d", s.sequence)
+	uri := "memory://" + id
+	s.documents[uri] = data
+
+	return &Attachment{
+		ID:          id,
+		Type:        attachmentType,
+		URI:         uri,
+		Hash:        hash,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+	}, nil
+}
+
+// Get implements DocumentStore.Get
+func (s *MemoryDocumentStore) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.documents[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, uri)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete implements DocumentStore.Delete
+func (s *MemoryDocumentStore) Delete(ctx context.Context, uri string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.documents[uri]; !ok {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, uri)
+	}
+	delete(s.documents, uri)
+	return nil
+}
@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrDimensionMissing    = errors.New("entry is missing a required dimension")
+	ErrDimensionUnbalanced = errors.New("transaction is not balanced within dimension")
+)
+
+// DimensionRule requires debits to equal credits within each value of a
+// dimension, such as each fund in fund accounting, not just in total
+type DimensionRule struct {
+	// Dimension that must balance (e.g., "fund")
+	Dimension string
+	// Interfund receivable debited in a value that is owed by the others
+	DueFromAccountID string
+	// Interfund payable credited in a value that owes the others
+	DueToAccountID string
+}
+
+// Imbalances returns the net debit (positive) or credit (negative) for each
+// value of the dimension that does not balance
+func (r DimensionRule) Imbalances(tx *Transaction) (map[string]decimal.Decimal, error) {
+	net := make(map[string]decimal.Decimal)
+	for i, entry := range tx.Entries {
+		value := entry.Dimensions[r.Dimension]
+		if value == "" {
+			return nil, fmt.Errorf("%w: entry %d has no %s", ErrDimensionMissing, i, r.Dimension)
+		}
+		if entry.Type == Debit {
+			net[value] = net[value].Add(entry.Amount.Amount)
+		} else {
+			net[value] = net[value].Sub(entry.Amount.Amount)
+		}
+	}
+	for value, amount := range net {
+		if amount.IsZero() {
+			delete(net, value)
+		}
+	}
+	return net, nil
+}
+
+// Check reports whether the transaction balances within every value of the
+// dimension
+func (r DimensionRule) Check(tx *Transaction) error {
+	imbalances, err := r.Imbalances(tx)
+	if err != nil {
+		return err
+	}
+	if len(imbalances) == 0 {
+		return nil
+	}
+
+	values := sortedKeys(imbalances)
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%s %s", value, imbalances[value].String())
+	}
+	return fmt.Errorf("%w %s: %s", ErrDimensionUnbalanced, r.Dimension, strings.Join(parts, ", "))
+}
+
+// Balance appends interfund entries so that each value of the dimension
+// balances. Values with surplus debits credit the due-to account and values
+// with surplus credits debit the due-from account.
+func (r DimensionRule) Balance(tx *Transaction) error {
+	if r.DueFromAccountID == "" || r.DueToAccountID == "" {
+		return fmt.Errorf("interfund due-to and due-from accounts are required to balance %s", r.Dimension)
+	}
+	imbalances, err := r.Imbalances(tx)
+	if err != nil {
+		return err
+	}
+	if len(imbalances) == 0 {
+		return nil
+	}
+
+	currency := tx.Entries[0].Amount.Currency
+	for _, value := range sortedKeys(imbalances) {
+		amount := imbalances[value]
+		entry := Entry{
+			Amount:      money.Money{Amount: amount.Abs(), Currency: currency},
+			Description: fmt.Sprintf("Interfund balancing for %s %s", r.Dimension, value),
+			Dimensions:  map[string]string{r.Dimension: value},
+		}
+		if amount.IsPositive() {
+			entry.AccountID, entry.Type = r.DueToAccountID, Credit
+		} else {
+			entry.AccountID, entry.Type = r.DueFromAccountID, Debit
+		}
+		tx.Entries = append(tx.Entries, entry)
+	}
+	return nil
+}
+
+// dimensionSignature renders dimensions in a stable order so entries can be
+// compared by account and dimensions
+func dimensionSignature(dimensions map[string]string) string {
+	if len(dimensions) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "|%s=%s", k, dimensions[k])
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]decimal.Decimal) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
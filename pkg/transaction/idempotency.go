@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused for a
+// different transaction
+var ErrIdempotencyConflict = errors.New("idempotency key reused with a different transaction")
+
+// IdempotencyRecord remembers which transaction was posted under a key
+type IdempotencyRecord struct {
+	ID            string    `json:"id"`
+	Key           string    `json:"key"`
+	TransactionID string    `json:"transaction_id"`
+	Fingerprint   string    `json:"fingerprint"`
+	Created       time.Time `json:"created"`
+}
+
+// GetID returns the record identifier
+func (r *IdempotencyRecord) GetID() string { return r.ID }
+
+// IdempotencyRecordID returns the storage ID of the record for a key. Keys
+// are scoped to the context entity.
+func IdempotencyRecordID(ctx context.Context, key string) string {
+	if id, ok := entity.FromContext(ctx); ok {
+		return fmt.Sprintf("idempotency:%s:%s", id, key)
+	}
+	return "idempotency:" + key
+}
+
+// fingerprint digests the parts of a transaction a client controls, so a
+// retried request can be told apart from a different one reusing the key
+func fingerprint(tx *Transaction) string {
+	data, _ := json.Marshal(struct {
+		Type        TransactionType
+		Date        time.Time
+		Description string
+		Entries     []Entry
+	}{tx.Type, tx.Date.UTC(), tx.Description, tx.Entries})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replay loads the transaction previously posted under the transaction's
+// idempotency key into tx. It reports false when the key has not been used;
+// other errors reading the key are returned, so a failing store never lets
+// a retry post twice.
+func (p *BasicTransactionProcessor) replay(ctx context.Context, tx *Transaction) (bool, error) {
+	var record IdempotencyRecord
+	if err := p.repo.Read(ctx, IdempotencyRecordID(ctx, tx.IdempotencyKey), &record); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	if record.Fingerprint != fingerprint(tx) {
+		return false, fmt.Errorf("%w: %s", ErrIdempotencyConflict, tx.IdempotencyKey)
+	}
+
+	previous, err := p.GetTransaction(ctx, record.TransactionID)
+	if err != nil {
+		return false, err
+	}
+	*tx = *previous
+	return true, nil
+}
+
+// remember records the transaction posted under its idempotency key
+func (p *BasicTransactionProcessor) remember(ctx context.Context, tx *Transaction, digest string) error {
+	record := &IdempotencyRecord{
+		ID:            IdempotencyRecordID(ctx, tx.IdempotencyKey),
+		Key:           tx.IdempotencyKey,
+		TransactionID: tx.ID,
+		Fingerprint:   digest,
+		Created:       time.Now(),
+	}
+	if err := p.repo.Create(ctx, record); err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	return nil
+}
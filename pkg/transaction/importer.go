@@ -0,0 +1,424 @@
+package transaction
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+)
+
+// ErrInvalidImport is returned when an import file cannot be read at all,
+// such as a CSV file missing a required column
+var ErrInvalidImport = errors.New("invalid import file")
+
+// Codes of import row errors, alongside the codes of validation errors
+const (
+	ErrCodeMissingField         = "MISSING_FIELD"
+	ErrCodeInvalidField         = "INVALID_FIELD"
+	ErrCodeDuplicateTransaction = "DUPLICATE_TRANSACTION"
+	ErrCodeValidationFailed     = "VALIDATION_FAILED"
+)
+
+// ImportColumns are the columns of an import CSV file, matched against the
+// header case-insensitively. Each row is one entry; rows sharing a
+// transaction ID form one transaction, which takes its date, type,
+// description, reference and entity from its first row.
+var ImportColumns = []string{
+	"transaction_id", "date", "type", "description", "reference", "entity_id",
+	"account_id", "side", "amount", "currency", "memo", "party_id",
+}
+
+// requiredImportColumns must appear in the header of an import CSV file
+var requiredImportColumns = []string{"transaction_id", "date", "account_id", "side", "amount", "currency"}
+
+// ImportRowError describes a problem with a row of an import file. Rows are
+// file lines in CSV files, counting the header, and 1-based positions in
+// JSON arrays.
+type ImportRowError struct {
+	Row           int    `json:"row"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Code          string `json:"code"`
+	Field         string `json:"field,omitempty"`
+	Message       string `json:"message"`
+}
+
+// ImportReport describes the outcome of an import
+type ImportReport struct {
+	// Rows read and the transactions they formed
+	Rows         int `json:"rows"`
+	Transactions int `json:"transactions"`
+	// Transactions that passed validation, were rejected and were posted
+	Valid    int `json:"valid"`
+	Rejected int `json:"rejected"`
+	Posted   int `json:"posted"`
+	// Valid transactions balanced to the suspense account
+	Suspense int `json:"suspense,omitempty"`
+	// Problems that rejected a transaction, in row order
+	Errors []ImportRowError `json:"errors,omitempty"`
+	// Validation warnings on transactions that were accepted anyway
+	Warnings []ImportRowError `json:"warnings,omitempty"`
+	// IDs of the posted transactions
+	PostedIDs []string `json:"posted_ids,omitempty"`
+}
+
+// HasErrors reports whether any transaction was rejected
+func (r *ImportReport) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// importRecord is a transaction being imported with the rows it came from
+type importRecord struct {
+	tx *Transaction
+	// Row of each entry, and of the transaction when it has no entries
+	rows   []int
+	first  int
+	failed bool
+}
+
+// rowOf returns the row a validation error field such as Entries[2].Amount
+// refers to, or the transaction's first row
+func (r *importRecord) rowOf(field string) int {
+	var i int
+	if _, err := fmt.Sscanf(field, "Entries[%d]", &i); err == nil && i >= 0 && i < len(r.rows) {
+		return r.rows[i]
+	}
+	return r.first
+}
+
+// Importer loads transactions in bulk from CSV or JSON files. Every
+// transaction is validated by the processor and any further validators,
+// and all problems are collected into a per-row report instead of stopping
+// at the first. The transactions that pass are then posted as a single
+// batch, so either all of them are posted or none are.
+type Importer struct {
+	processor  TransactionProcessor
+	validators []Validator
+	// Whether any rejected transaction prevents the others from posting
+	allOrNothing bool
+	// Account unbalanced transactions are balanced to, if any
+	suspenseAccountID string
+}
+
+// NewImporter creates an importer posting through a processor
+func NewImporter(processor TransactionProcessor) *Importer {
+	return &Importer{processor: processor}
+}
+
+// AddValidator adds a validator run on every transaction after the
+// processor's own validation, e.g. a validation engine adapted with
+// validation.NewEngineValidator
+func (i *Importer) AddValidator(validator Validator) {
+	i.validators = append(i.validators, validator)
+}
+
+// SetSuspenseAccount makes the importer balance transactions whose only
+// problem is that debits and credits differ to a suspense account instead
+// of rejecting them. Such transactions are flagged with Suspense, reported
+// as warnings and listed by SuspenseItems until reclassified. An empty ID
+// turns the mode off.
+func (i *Importer) SetSuspenseAccount(accountID string) {
+	i.suspenseAccountID = accountID
+}
+
+// SetAllOrNothing sets whether a single rejected transaction prevents the
+// whole file from posting
+func (i *Importer) SetAllOrNothing(allOrNothing bool) {
+	i.allOrNothing = allOrNothing
+}
+
+// ImportCSV imports transactions from a CSV file with a header naming
+// ImportColumns. Rows that cannot be read reject their transaction and are
+// described in the report; an error is only returned when the file cannot
+// be read or posting fails.
+func (i *Importer) ImportCSV(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	columns := make(map[string]int, len(header))
+	for n, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidImport, name)
+		}
+	}
+
+	report := &ImportReport{}
+	records := make([]*importRecord, 0)
+	byID := make(map[string]*importRecord)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		report.Rows++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			report.Errors = append(report.Errors, ImportRowError{Row: parseErr.StartLine, Code: ErrCodeInvalidField, Message: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if n, ok := columns[name]; ok && n < len(record) {
+				return strings.TrimSpace(record[n])
+			}
+			return ""
+		}
+		id := field("transaction_id")
+		if id == "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: line, Code: ErrCodeMissingField, Field: "transaction_id", Message: "transaction_id is required"})
+			continue
+		}
+
+		rec, ok := byID[id]
+		if !ok {
+			tx := &Transaction{
+				ID:          id,
+				Type:        TransactionType(strings.ToUpper(field("type"))),
+				Status:      Draft,
+				Description: field("description"),
+				Reference:   field("reference"),
+				EntityID:    field("entity_id"),
+			}
+			rec = &importRecord{tx: tx, first: line}
+			if date, err := time.Parse("2006-01-02", field("date")); err == nil {
+				tx.Date = date
+			} else {
+				rec.reject(report, line, ErrCodeInvalidField, "date", fmt.Sprintf("invalid date %q", field("date")))
+			}
+			byID[id] = rec
+			records = append(records, rec)
+		}
+
+		entry := Entry{
+			AccountID:   field("account_id"),
+			Type:        EntryType(strings.ToUpper(field("side"))),
+			Description: field("memo"),
+			PartyID:     field("party_id"),
+		}
+		if entry.AccountID == "" {
+			rec.reject(report, line, ErrCodeMissingField, "account_id", "account_id is required")
+		}
+		if entry.Type != Debit && entry.Type != Credit {
+			rec.reject(report, line, ErrCodeInvalidField, "side", fmt.Sprintf("side must be DEBIT or CREDIT, got %q", field("side")))
+		}
+		amount, err := money.Parse(field("amount"), strings.ToUpper(field("currency")))
+		if err != nil {
+			rec.reject(report, line, ErrCodeInvalidAmount, "amount", err.Error())
+		}
+		entry.Amount = amount
+		rec.tx.Entries = append(rec.tx.Entries, entry)
+		rec.rows = append(rec.rows, line)
+	}
+
+	return i.finish(ctx, report, records)
+}
+
+// ImportJSON imports transactions from a JSON array of transactions. The
+// array is decoded one element at a time, so large files are not held in
+// memory twice. Elements that cannot be decoded are described in the
+// report; an error is only returned when the file is not a JSON array or
+// posting fails.
+func (i *Importer) ImportJSON(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("%w: expected a JSON array of transactions", ErrInvalidImport)
+	}
+
+	report := &ImportReport{}
+	records := make([]*importRecord, 0)
+	seen := make(map[string]bool)
+	for decoder.More() {
+		report.Rows++
+		row := report.Rows
+		tx := &Transaction{}
+		if err := decoder.Decode(tx); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, row, err)
+			}
+			report.Errors = append(report.Errors, ImportRowError{Row: row, TransactionID: tx.ID, Code: ErrCodeInvalidField, Field: typeErr.Field, Message: err.Error()})
+			continue
+		}
+		if tx.ID == "" {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, Code: ErrCodeMissingField, Field: "id", Message: "id is required"})
+			continue
+		}
+		if seen[tx.ID] {
+			report.Errors = append(report.Errors, ImportRowError{Row: row, TransactionID: tx.ID, Code: ErrCodeDuplicateTransaction, Field: "id", Message: fmt.Sprintf("transaction %s appears more than once", tx.ID)})
+			continue
+		}
+		seen[tx.ID] = true
+
+		if tx.Status == "" {
+			tx.Status = Draft
+		}
+		rec := &importRecord{tx: tx, first: row, rows: make([]int, len(tx.Entries))}
+		for n := range rec.rows {
+			rec.rows[n] = row
+		}
+		records = append(records, rec)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	return i.finish(ctx, report, records)
+}
+
+// reject records a problem with a row of a transaction, which will not be
+// validated or posted
+func (r *importRecord) reject(report *ImportReport, row int, code, field, message string) {
+	r.failed = true
+	report.Errors = append(report.Errors, ImportRowError{Row: row, TransactionID: r.tx.ID, Code: code, Field: field, Message: message})
+}
+
+// finish validates the transactions read from a file and posts the valid
+// ones as one batch
+func (i *Importer) finish(ctx context.Context, report *ImportReport, records []*importRecord) (*ImportReport, error) {
+	now := time.Now()
+	valid := make([]*Transaction, 0, len(records))
+	for _, rec := range records {
+		report.Transactions++
+		if rec.failed {
+			report.Rejected++
+			continue
+		}
+		if rec.tx.Status != Draft && rec.tx.Status != Pending {
+			rec.reject(report, rec.first, ErrCodeInvalidStatus, "status", fmt.Sprintf("cannot import a %s transaction", rec.tx.Status))
+			report.Rejected++
+			continue
+		}
+		if rec.tx.Type == "" {
+			rec.tx.Type = Journal
+		}
+		if rec.tx.Created.IsZero() {
+			rec.tx.Created = now
+		}
+		if rec.tx.LastModified.IsZero() {
+			rec.tx.LastModified = now
+		}
+
+		if err := i.balanceToSuspense(ctx, report, rec); err != nil {
+			rec.reject(report, rec.first, ErrCodeValidationFailed, "", fmt.Sprintf("failed to validate transaction: %v", err))
+			report.Rejected++
+			continue
+		}
+		if i.validate(ctx, report, rec) {
+			if rec.tx.Suspense {
+				report.Suspense++
+			}
+			report.Valid++
+			valid = append(valid, rec.tx)
+		} else {
+			report.Rejected++
+		}
+	}
+	sortRowErrors(report.Errors)
+	sortRowErrors(report.Warnings)
+
+	if len(valid) == 0 || (i.allOrNothing && report.HasErrors()) {
+		return report, nil
+	}
+	if err := i.processor.ProcessTransactionBatch(ctx, valid); err != nil {
+		return report, fmt.Errorf("failed to post %d imported transactions: %w", len(valid), err)
+	}
+	report.Posted = len(valid)
+	for _, tx := range valid {
+		report.PostedIDs = append(report.PostedIDs, tx.ID)
+	}
+	return report, nil
+}
+
+// validate runs the processor's and the added validators on a transaction,
+// recording their errors and warnings, and reports whether it passed
+func (i *Importer) validate(ctx context.Context, report *ImportReport, rec *importRecord) bool {
+	validators := append([]Validator{validatorFunc(i.processor.ValidateTransaction)}, i.validators...)
+	passed := true
+	for _, validator := range validators {
+		result, err := validator.Validate(ctx, rec.tx)
+		if err != nil {
+			rec.reject(report, rec.first, ErrCodeValidationFailed, "", fmt.Sprintf("failed to validate transaction: %v", err))
+			return false
+		}
+		for _, e := range result.Errors {
+			rec.reject(report, rec.rowOf(e.Field), e.Code, e.Field, e.Message)
+		}
+		for _, w := range result.Warnings {
+			report.Warnings = append(report.Warnings, ImportRowError{Row: rec.rowOf(w.Field), TransactionID: rec.tx.ID, Code: w.Code, Field: w.Field, Message: w.Message})
+		}
+		if !result.Valid {
+			passed = false
+		}
+	}
+	return passed && !rec.failed
+}
+
+// balanceToSuspense adds an entry to the suspense account to a transaction
+// that is valid except for being unbalanced, when suspense mode is on
+func (i *Importer) balanceToSuspense(ctx context.Context, report *ImportReport, rec *importRecord) error {
+	if i.suspenseAccountID == "" {
+		return nil
+	}
+	result, err := i.processor.ValidateTransaction(ctx, rec.tx)
+	if err != nil {
+		return err
+	}
+	if result.Valid {
+		return nil
+	}
+	for _, e := range result.Errors {
+		if e.Code != ErrCodeUnbalanced {
+			return nil
+		}
+	}
+
+	entry, ok := suspenseEntry(rec.tx, i.suspenseAccountID)
+	if !ok {
+		return nil
+	}
+	rec.tx.Entries = append(rec.tx.Entries, entry)
+	rec.rows = append(rec.rows, rec.first)
+	rec.tx.Suspense = true
+	report.Warnings = append(report.Warnings, ImportRowError{
+		Row:           rec.first,
+		TransactionID: rec.tx.ID,
+		Code:          ErrCodeSuspenseBalanced,
+		Field:         fmt.Sprintf("Entries[%d]", len(rec.tx.Entries)-1),
+		Message:       fmt.Sprintf("unbalanced by %s, balanced with a %s to suspense account %s", entry.Amount, strings.ToLower(string(entry.Type)), i.suspenseAccountID),
+	})
+	return nil
+}
+
+// validatorFunc adapts a validation function to Validator
+type validatorFunc func(ctx context.Context, tx *Transaction) (*ValidationResult, error)
+
+func (f validatorFunc) Validate(ctx context.Context, tx *Transaction) (*ValidationResult, error) {
+	return f(ctx, tx)
+}
+
+// sortRowErrors orders row errors by row, keeping the order of errors
+// within a row
+func sortRowErrors(errs []ImportRowError) {
+	sort.SliceStable(errs, func(a, b int) bool { return errs[a].Row < errs[b].Row })
+}
@@ -0,0 +1,184 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// draftingProcessor stores imported transactions as drafts before posting
+// them, as an application would before handing them to the processor
+type draftingProcessor struct {
+	*BasicTransactionProcessor
+	repo storage.Repository
+}
+
+func (p *draftingProcessor) ProcessTransactionBatch(ctx context.Context, txs []*Transaction) error {
+	for _, tx := range txs {
+		if err := p.repo.Create(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return p.BasicTransactionProcessor.ProcessTransactionBatch(ctx, txs)
+}
+
+// warnLargeAmounts warns about entries of 1000 or more
+type warnLargeAmounts struct{}
+
+func (warnLargeAmounts) Validate(ctx context.Context, tx *Transaction) (*ValidationResult, error) {
+	result := &ValidationResult{Valid: true}
+	for i, entry := range tx.Entries {
+		if entry.Amount.Amount.IntPart() >= 1000 {
+			result.Warnings = append(result.Warnings, ValidationError{Code: "LARGE_AMOUNT", Message: "large amount", Field: fmt.Sprintf("Entries[%d].Amount", i)})
+		}
+	}
+	return result, nil
+}
+
+func newTestImporter() (*Importer, storage.Repository) {
+	store := memory.NewMemoryStore()
+	return NewImporter(&draftingProcessor{BasicTransactionProcessor: NewBasicTransactionProcessor(store), repo: store}), store
+}
+
+func TestImporter_CSV(t *testing.T) {
+	ctx := context.Background()
+	importer, store := newTestImporter()
+	importer.AddValidator(warnLargeAmounts{})
+
+	file := strings.Join([]string{
+		"Transaction_ID,Date,Description,Account_ID,Side,Amount,Currency,Memo",
+		"T1,2025-01-15,Rent,6100,debit,1500.00,USD,January",
+		"T1,2025-01-15,Rent,1000,credit,1500.00,USD,",
+		"T2,2025-01-16,Supplies,6200,DEBIT,40.00,USD,",
+		"T2,2025-01-16,Supplies,1000,CREDIT,45.00,USD,",
+		"T3,2025-01-17,Fees,6300,DEBIT,12.50,USD,",
+		"T3,2025-01-17,Fees,1000,SIDEWAYS,12.50,USD,",
+		"T4,2025-13-01,Bad date,6300,DEBIT,1.00,USD,",
+		"T4,2025-13-01,Bad date,1000,CREDIT,1.00,USD,",
+		",2025-01-18,No ID,1000,CREDIT,1.00,USD,",
+		"T5,2025-01-18,Interest,1000,DEBIT,3.00,USD,",
+		"T5,2025-01-18,Interest,4900,CREDIT,3.00,USD,",
+	}, "\n")
+
+	report, err := importer.ImportCSV(ctx, strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, 11, report.Rows)
+	assert.Equal(t, 5, report.Transactions)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, 3, report.Rejected)
+	assert.Equal(t, 2, report.Posted)
+	assert.Equal(t, []string{"T1", "T5"}, report.PostedIDs)
+
+	require.Len(t, report.Errors, 4)
+	assert.Equal(t, ImportRowError{Row: 4, TransactionID: "T2", Code: ErrCodeUnbalanced, Message: report.Errors[0].Message}, report.Errors[0])
+	assert.Equal(t, 7, report.Errors[1].Row)
+	assert.Equal(t, "side", report.Errors[1].Field)
+	assert.Equal(t, 8, report.Errors[2].Row)
+	assert.Equal(t, "date", report.Errors[2].Field)
+	assert.Equal(t, 10, report.Errors[3].Row)
+	assert.Equal(t, ErrCodeMissingField, report.Errors[3].Code)
+
+	require.Len(t, report.Warnings, 2)
+	assert.Equal(t, 2, report.Warnings[0].Row)
+	assert.Equal(t, 3, report.Warnings[1].Row)
+
+	var stored Transaction
+	require.NoError(t, store.Read(ctx, "T1", &stored))
+	assert.Equal(t, Posted, stored.Status)
+	assert.Equal(t, "January", stored.Entries[0].Description)
+	assert.Error(t, store.Read(ctx, "T2", &stored))
+
+	_, err = importer.ImportCSV(ctx, strings.NewReader("transaction_id,date,amount\n"))
+	assert.ErrorIs(t, err, ErrInvalidImport)
+}
+
+func TestImporter_JSON(t *testing.T) {
+	ctx := context.Background()
+	importer, store := newTestImporter()
+	importer.SetAllOrNothing(true)
+
+	valid := `{"id":"J1","date":"2025-02-01T00:00:00Z","description":"Sale","entries":[
+		{"account_id":"1100","amount":{"amount":"250","currency":"USD"},"type":"DEBIT"},
+		{"account_id":"4000","amount":{"amount":"250","currency":"USD"},"type":"CREDIT"}]}`
+	duplicateAccount := `{"id":"J2","date":"2025-02-02T00:00:00Z","entries":[
+		{"account_id":"1100","amount":{"amount":"5","currency":"USD"},"type":"DEBIT"},
+		{"account_id":"1100","amount":{"amount":"5","currency":"USD"},"type":"CREDIT"}]}`
+	file := "[" + valid + "," + duplicateAccount + `,{"id":"J1"},{"id":"J3","entries":"none"}]`
+
+	report, err := importer.ImportJSON(ctx, strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, 2, report.Transactions)
+	assert.Equal(t, 1, report.Valid)
+	assert.Zero(t, report.Posted, "a rejected transaction holds back the whole file")
+	require.Len(t, report.Errors, 3)
+	assert.Equal(t, ImportRowError{Row: 2, TransactionID: "J2", Code: ErrCodeDuplicateAccount, Field: "Entries[1].AccountID", Message: report.Errors[0].Message}, report.Errors[0])
+	assert.Equal(t, ErrCodeDuplicateTransaction, report.Errors[1].Code)
+	assert.Equal(t, 4, report.Errors[2].Row)
+	assert.Equal(t, ErrCodeInvalidField, report.Errors[2].Code)
+
+	importer.SetAllOrNothing(false)
+	report, err = importer.ImportJSON(ctx, strings.NewReader("["+valid+"]"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Posted)
+	var stored Transaction
+	require.NoError(t, store.Read(ctx, "J1", &stored))
+	assert.Equal(t, Posted, stored.Status)
+
+	_, err = importer.ImportJSON(ctx, strings.NewReader(`{"id":"J1"}`))
+	assert.ErrorIs(t, err, ErrInvalidImport)
+}
+
+func TestImporter_Suspense(t *testing.T) {
+	ctx := context.Background()
+	importer, store := newTestImporter()
+	importer.SetSuspenseAccount("9999")
+
+	file := strings.Join([]string{
+		"transaction_id,date,description,account_id,side,amount,currency",
+		"T1,2025-01-16,Supplies,6200,DEBIT,40.00,USD",
+		"T1,2025-01-16,Supplies,1000,CREDIT,45.00,USD",
+		"T2,2025-01-17,Single entry,6200,DEBIT,10.00,USD",
+		"T3,2025-01-18,Balanced,6200,DEBIT,10.00,USD",
+		"T3,2025-01-18,Balanced,1000,CREDIT,10.00,USD",
+	}, "\n")
+	report, err := importer.ImportCSV(ctx, strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Posted)
+	assert.Equal(t, 1, report.Suspense)
+	require.NotEmpty(t, report.Errors)
+	for _, e := range report.Errors {
+		assert.Equal(t, "T2", e.TransactionID, "only transactions that are merely unbalanced go to suspense")
+	}
+	require.Len(t, report.Warnings, 1)
+	assert.Equal(t, ErrCodeSuspenseBalanced, report.Warnings[0].Code)
+	assert.Equal(t, 2, report.Warnings[0].Row)
+
+	processor := NewBasicTransactionProcessor(store)
+	asOf := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	open, err := processor.SuspenseItems(ctx, "9999", asOf)
+	require.NoError(t, err)
+	require.Len(t, open.Items, 1)
+	item := open.Items[0]
+	assert.Equal(t, "T1", item.TransactionID)
+	assert.Equal(t, Debit, item.Side)
+	assert.Equal(t, "5", item.Outstanding.Amount.String())
+	assert.Equal(t, 15, item.AgeDays)
+	assert.Equal(t, "5", open.Totals["USD"].String())
+
+	// Reclassifying the item clears it
+	reclass := NewReclassification(item, "9999", "6210", asOf)
+	require.NoError(t, store.Create(ctx, reclass))
+	require.NoError(t, processor.ProcessTransaction(ctx, reclass))
+	open, err = processor.SuspenseItems(ctx, "9999", asOf)
+	require.NoError(t, err)
+	assert.Empty(t, open.Items)
+	assert.Empty(t, open.Totals)
+}
@@ -0,0 +1,188 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// ErrTransactionLocked is returned when a locked transaction would be
+// changed without an override
+var ErrTransactionLocked = errors.New("transaction is locked")
+
+// LockOverride records a change made to a locked transaction
+type LockOverride struct {
+	// Operation overridden, such as "void" or "reverse"
+	Operation string    `json:"operation"`
+	Reason    string    `json:"reason"`
+	By        string    `json:"by"`
+	At        time.Time `json:"at"`
+}
+
+// Locker locks posted transactions once they have been relied on, for
+// example by a completed reconciliation or a closed period
+type Locker interface {
+	// LockTransaction locks a posted transaction
+	LockTransaction(ctx context.Context, txID string, reason string) error
+	// LockPeriod locks the posted transactions of the context entity dated
+	// within a date range and returns their IDs
+	LockPeriod(ctx context.Context, start, end time.Time, reason string) ([]string, error)
+}
+
+type overrideKey struct{}
+
+// WithLockOverride returns a context allowing locked transactions to be
+// voided, reversed or updated. The override is only honored for principals
+// holding auth.TransactionUnlock, and its reason is recorded on the
+// transaction and in the audit trail.
+func WithLockOverride(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, overrideKey{}, reason)
+}
+
+// lockOverride returns the override reason carried by a context, if any
+func lockOverride(ctx context.Context) (string, bool) {
+	reason, ok := ctx.Value(overrideKey{}).(string)
+	return reason, ok
+}
+
+// IsLocked reports whether the transaction is locked
+func (t *Transaction) IsLocked() bool {
+	return t.LockedAt != nil
+}
+
+// checkLock lets an operation on a locked transaction go ahead only under
+// an override by a principal allowed to unlock transactions. The override
+// is recorded on the transaction, and the returned context carries its
+// reason into the audit trail.
+func (p *BasicTransactionProcessor) checkLock(ctx context.Context, tx *Transaction, operation string) (context.Context, error) {
+	if !tx.IsLocked() {
+		return ctx, nil
+	}
+	reason, ok := lockOverride(ctx)
+	if !ok {
+		return ctx, fmt.Errorf("%w: %s was locked: %s", ErrTransactionLocked, tx.ID, tx.LockReason)
+	}
+	if reason == "" {
+		return ctx, fmt.Errorf("%w: overriding the lock on %s requires a reason", ErrTransactionLocked, tx.ID)
+	}
+	if err := auth.RequireAuthenticated(ctx, auth.TransactionUnlock); err != nil {
+		return ctx, fmt.Errorf("overriding the lock on %s: %w", tx.ID, err)
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	tx.LockOverrides = append(tx.LockOverrides, LockOverride{
+		Operation: operation,
+		Reason:    reason,
+		By:        caller.UserID,
+		At:        time.Now(),
+	})
+	caller.Reason = fmt.Sprintf("lock override (%s): %s", operation, reason)
+	return audit.WithCaller(ctx, caller), nil
+}
+
+// LockTransaction implements Locker.LockTransaction. Locking a locked
+// transaction keeps the original lock.
+func (p *BasicTransactionProcessor) LockTransaction(ctx context.Context, txID string, reason string) error {
+	tx, err := p.GetTransaction(ctx, txID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
+	return p.lock(ctx, tx, reason)
+}
+
+// LockPeriod implements Locker.LockPeriod
+func (p *BasicTransactionProcessor) LockPeriod(ctx context.Context, start, end time.Time, reason string) ([]string, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "date", Operator: ">=", Value: start},
+			{Field: "date", Operator: "<=", Value: end},
+			{Field: "status", Operator: "=", Value: Posted},
+		},
+		Sort: []storage.Sort{{Field: "date", Desc: false}},
+	}
+	var txs []*Transaction
+	if err := p.repo.Query(ctx, entity.ScopeQuery(ctx, query), &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	locked := make([]string, 0, len(txs))
+	err := p.atomically(ctx, func(ctx context.Context) error {
+		for _, tx := range txs {
+			if tx.IsLocked() {
+				continue
+			}
+			if err := p.lock(ctx, tx, reason); err != nil {
+				return err
+			}
+			locked = append(locked, tx.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locked, nil
+}
+
+// UnlockTransaction removes the lock from a transaction. It requires a
+// principal holding auth.TransactionUnlock and a reason, both recorded in
+// the audit trail.
+func (p *BasicTransactionProcessor) UnlockTransaction(ctx context.Context, txID string, reason string) error {
+	if err := auth.RequireAuthenticated(ctx, auth.TransactionUnlock); err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required to unlock a transaction")
+	}
+
+	tx, err := p.GetTransaction(ctx, txID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
+	if !tx.IsLocked() {
+		return nil
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	caller.Reason = fmt.Sprintf("unlocked: %s", reason)
+	tx.LockOverrides = append(tx.LockOverrides, LockOverride{
+		Operation: "unlock",
+		Reason:    reason,
+		By:        caller.UserID,
+		At:        time.Now(),
+	})
+	tx.LockedAt, tx.LockedBy, tx.LockReason = nil, "", ""
+	tx.LastModified = time.Now()
+	if err := p.repo.Update(audit.WithCaller(ctx, caller), tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+	return nil
+}
+
+// lock marks a posted transaction locked and stores it
+func (p *BasicTransactionProcessor) lock(ctx context.Context, tx *Transaction, reason string) error {
+	if tx.Status != Posted {
+		return fmt.Errorf("only posted transactions can be locked")
+	}
+	if tx.IsLocked() {
+		return nil
+	}
+
+	caller := audit.CallerFromContext(ctx)
+	caller.Reason = fmt.Sprintf("locked: %s", reason)
+	now := time.Now()
+	tx.LockedAt = &now
+	tx.LockedBy = caller.UserID
+	tx.LockReason = reason
+	tx.LastModified = now
+	if err := p.repo.Update(audit.WithCaller(ctx, caller), tx); err != nil {
+		return fmt.Errorf("failed to store transaction %s: %w", tx.ID, err)
+	}
+	return nil
+}
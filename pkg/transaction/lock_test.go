@@ -0,0 +1,78 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTransactionProcessor_Locking(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	post := func(id string, date time.Time) {
+		tx := NewTestTransaction()
+		tx.ID = id
+		tx.Date = date
+		require.NoError(t, store.Create(ctx, tx))
+		require.NoError(t, processor.ProcessTransaction(ctx, tx))
+	}
+	jan := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	post("T1", jan)
+	post("T2", jan.AddDate(0, 0, 10))
+	post("T3", jan.AddDate(0, 1, 0))
+
+	locked, err := processor.LockPeriod(ctx, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), "period 2025-01 closed")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"T1", "T2"}, locked)
+	require.NoError(t, processor.LockTransaction(ctx, "T3", "reconciled"))
+
+	stored, err := processor.GetTransaction(ctx, "T1")
+	require.NoError(t, err)
+	assert.True(t, stored.IsLocked())
+	assert.Equal(t, "period 2025-01 closed", stored.LockReason)
+
+	// Locked transactions are refused without an override
+	assert.ErrorIs(t, processor.VoidTransaction(ctx, "T1", "duplicate"), ErrTransactionLocked)
+	assert.ErrorIs(t, processor.ReverseTransaction(ctx, "T2", "error"), ErrTransactionLocked)
+	_, err = processor.ReversePartial(ctx, "T2", PartialReversal{})
+	assert.ErrorIs(t, err, ErrTransactionLocked)
+
+	registry, err := auth.NewRegistry(auth.DefaultRoles()...)
+	require.NoError(t, err)
+	accountant, err := registry.NewPrincipal("alice", "Alice", "accountant")
+	require.NoError(t, err)
+	controller, err := registry.NewPrincipal("carol", "Carol", "controller")
+	require.NoError(t, err)
+
+	// An override needs a reason and a principal allowed to unlock
+	assert.ErrorIs(t, processor.VoidTransaction(WithLockOverride(ctx, "duplicate entry"), "T1", "duplicate"), auth.ErrUnauthenticated)
+	asAccountant := auth.WithPrincipal(WithLockOverride(ctx, "duplicate entry"), accountant)
+	assert.ErrorIs(t, processor.VoidTransaction(asAccountant, "T1", "duplicate"), auth.ErrPermissionDenied)
+	asController := auth.WithPrincipal(ctx, controller)
+	assert.ErrorIs(t, processor.VoidTransaction(WithLockOverride(asController, ""), "T1", "duplicate"), ErrTransactionLocked)
+
+	require.NoError(t, processor.VoidTransaction(WithLockOverride(asController, "duplicate entry"), "T1", "duplicate"))
+	stored, err = processor.GetTransaction(ctx, "T1")
+	require.NoError(t, err)
+	assert.Equal(t, Voided, stored.Status)
+	require.Len(t, stored.LockOverrides, 1)
+	assert.Equal(t, LockOverride{Operation: "void", Reason: "duplicate entry", By: "carol", At: stored.LockOverrides[0].At}, stored.LockOverrides[0])
+
+	trail, err := store.GetAuditTrail(ctx, "T1")
+	require.NoError(t, err)
+	last := trail[len(trail)-1]
+	assert.Equal(t, "carol", last.UserID)
+	assert.Equal(t, "lock override (void): duplicate entry", last.Metadata["reason"])
+
+	// Unlocking is restricted the same way and lifts the lock
+	assert.ErrorIs(t, processor.UnlockTransaction(auth.WithPrincipal(ctx, accountant), "T3", "rematch"), auth.ErrPermissionDenied)
+	require.NoError(t, processor.UnlockTransaction(asController, "T3", "rematch"))
+	require.NoError(t, processor.VoidTransaction(ctx, "T3", "rematched"))
+}
@@ -0,0 +1,184 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// ErrOverReversal is returned when a reversal would reverse more of an entry
+// than remains outstanding
+var ErrOverReversal = errors.New("reversal exceeds the outstanding amount")
+
+// PartialReversal selects the part of a posting to reverse
+type PartialReversal struct {
+	Reason string
+	// Indexes of the original entries to reverse; empty selects all
+	Entries []int
+	// Share of the selected entries to reverse, between 0 and 1; zero
+	// reverses them in full
+	Percentage decimal.Decimal
+}
+
+// ReversePartial posts a reversal of part of a transaction, such as a
+// partial refund. Each side of the selected entries is scaled by the
+// percentage and rounded to the currency without breaking the balance. The
+// original keeps running totals of what has been reversed and is marked as
+// reversed once nothing is outstanding. The reversal is created and posted
+// with the updated original and a TransactionReversed event in one storage
+// transaction.
+func (p *BasicTransactionProcessor) ReversePartial(ctx context.Context, txID string, opts PartialReversal) (*Transaction, error) {
+	if err := auth.Require(ctx, auth.TransactionReverse); err != nil {
+		return nil, err
+	}
+
+	origTx, err := p.GetTransaction(ctx, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
+	if origTx.Status != Posted {
+		return nil, fmt.Errorf("only posted transactions can be reversed")
+	}
+	if origTx.ReversedAt != nil {
+		return nil, fmt.Errorf("transaction is already reversed")
+	}
+	if ctx, err = p.checkLock(ctx, origTx, "reverse"); err != nil {
+		return nil, err
+	}
+
+	pct := opts.Percentage
+	if pct.IsZero() {
+		pct = decimal.NewFromInt(1)
+	}
+	if pct.IsNegative() || pct.GreaterThan(decimal.NewFromInt(1)) {
+		return nil, fmt.Errorf("reversal percentage must be between 0 and 1, got %s", pct)
+	}
+
+	selected := opts.Entries
+	if len(selected) == 0 {
+		selected = make([]int, len(origTx.Entries))
+		for i := range selected {
+			selected[i] = i
+		}
+	}
+	for _, i := range selected {
+		if i < 0 || i >= len(origTx.Entries) {
+			return nil, fmt.Errorf("entry %d does not exist in transaction %s", i, txID)
+		}
+	}
+
+	amounts, err := reversalAmounts(origTx.Entries, selected, pct)
+	if err != nil {
+		return nil, err
+	}
+
+	reversed := outstandingReversed(origTx)
+	for _, i := range selected {
+		total := reversed[i].Add(amounts[i].Amount)
+		if total.GreaterThan(origTx.Entries[i].Amount.Amount) {
+			return nil, fmt.Errorf("%w: entry %d of %s", ErrOverReversal, i, txID)
+		}
+	}
+
+	now := time.Now()
+	reversalTx := newReversal(origTx,
+		fmt.Sprintf("REV-%s-%d", origTx.ID, len(origTx.PartialReversalIDs)+1),
+		fmt.Sprintf("Partial reversal of %s: %s", origTx.ID, opts.Reason), now)
+	for _, i := range selected {
+		entry := origTx.Entries[i]
+		if amounts[i].IsZero() {
+			continue
+		}
+		reversalTx.Entries = append(reversalTx.Entries, Entry{
+			AccountID:   entry.AccountID,
+			Amount:      amounts[i],
+			Type:        entry.Type.Reverse(),
+			Description: fmt.Sprintf("Reversal of: %s", entry.Description),
+			PartyID:     entry.PartyID,
+			Dimensions:  entry.Dimensions,
+		})
+	}
+
+	// Link the reversal back to the original and track what is left
+	origTx.ReversedAmounts = make([]decimal.Decimal, len(origTx.Entries))
+	outstanding := false
+	for i, entry := range origTx.Entries {
+		origTx.ReversedAmounts[i] = reversed[i]
+		if amount, ok := amounts[i]; ok {
+			origTx.ReversedAmounts[i] = origTx.ReversedAmounts[i].Add(amount.Amount)
+		}
+		if origTx.ReversedAmounts[i].LessThan(entry.Amount.Amount) {
+			outstanding = true
+		}
+	}
+	origTx.PartialReversalIDs = append(origTx.PartialReversalIDs, reversalTx.ID)
+	if !outstanding {
+		origTx.ReversedAt = &now
+		origTx.ReversalID = reversalTx.ID
+	}
+	origTx.LastModified = now
+
+	// Each partial reversal has its own event, keyed by the reversal
+	if err := p.postReversal(ctx, origTx, reversalTx, reversalTx, opts.Reason); err != nil {
+		return nil, err
+	}
+	return reversalTx, nil
+}
+
+// outstandingReversed returns how much of each entry has been reversed
+func outstandingReversed(tx *Transaction) []decimal.Decimal {
+	reversed := make([]decimal.Decimal, len(tx.Entries))
+	for i := range reversed {
+		if i < len(tx.ReversedAmounts) {
+			reversed[i] = tx.ReversedAmounts[i]
+		}
+	}
+	return reversed
+}
+
+// reversalAmounts scales the selected entries by pct. The debit and credit
+// sides are each rounded as a whole and allocated across their entries in
+// proportion to the original amounts, so balanced selections stay balanced.
+func reversalAmounts(entries []Entry, selected []int, pct decimal.Decimal) (map[int]money.Money, error) {
+	amounts := make(map[int]money.Money, len(selected))
+	for _, side := range []EntryType{Debit, Credit} {
+		var indexes []int
+		var ratios []int
+		total := decimal.Zero
+		var currency string
+		for _, i := range selected {
+			entry := entries[i]
+			if entry.Type != side {
+				continue
+			}
+			indexes = append(indexes, i)
+			ratios = append(ratios, int(entry.Amount.Amount.Shift(entry.Amount.Scale()).IntPart()))
+			total = total.Add(entry.Amount.Amount)
+			currency = entry.Amount.Currency
+		}
+		if len(indexes) == 0 {
+			continue
+		}
+
+		share := money.Money{Amount: total.Mul(pct), Currency: currency}.RoundToCurrency()
+		if pct.Equal(decimal.NewFromInt(1)) {
+			for _, i := range indexes {
+				amounts[i] = entries[i].Amount
+			}
+			continue
+		}
+		parts, err := share.Allocate(ratios)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate reversal: %w", err)
+		}
+		for n, i := range indexes {
+			amounts[i] = parts[n]
+		}
+	}
+	return amounts, nil
+}
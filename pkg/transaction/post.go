@@ -0,0 +1,47 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// CreateAndPost stores a new draft transaction and posts it. Processors
+// post a transaction by updating the stored draft, so transactions built
+// in memory, such as the journal entries of invoices or closings, must be
+// created first. The draft is removed again when posting fails.
+func CreateAndPost(ctx context.Context, repo storage.Repository, processor TransactionProcessor, tx *Transaction) error {
+	if err := repo.Create(ctx, tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+	if err := processor.ProcessTransaction(ctx, tx); err != nil {
+		_ = repo.Delete(ctx, tx.ID)
+		return err
+	}
+	return nil
+}
+
+// CreateAndPostBatch stores new draft transactions and posts them in one
+// batch, so either all of them are posted or none. The drafts are removed
+// again when the batch fails.
+func CreateAndPostBatch(ctx context.Context, repo storage.Repository, processor TransactionProcessor, txs []*Transaction) error {
+	created := make([]string, 0, len(txs))
+	undo := func() {
+		for _, id := range created {
+			_ = repo.Delete(ctx, id)
+		}
+	}
+	for _, tx := range txs {
+		if err := repo.Create(ctx, tx); err != nil {
+			undo()
+			return fmt.Errorf("failed to store transaction %s: %w", tx.ID, err)
+		}
+		created = append(created, tx.ID)
+	}
+	if err := processor.ProcessTransactionBatch(ctx, txs); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
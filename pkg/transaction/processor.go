@@ -3,8 +3,14 @@ package transaction
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
 	"github.com/johnayoung/finlib/pkg/money"
 	"github.com/johnayoung/finlib/pkg/storage"
 )
@@ -17,6 +23,7 @@ const (
 	ErrCodeMixedCurrencies     = "MIXED_CURRENCIES"
 	ErrCodeInvalidAmount       = "INVALID_AMOUNT"
 	ErrCodeDuplicateAccount    = "DUPLICATE_ACCOUNT"
+	ErrCodeInvalidScale        = "INVALID_SCALE"
 )
 
 // TransactionProcessor handles the processing of financial transactions
@@ -95,6 +102,16 @@ func (v *BasicValidator) Validate(ctx context.Context, tx *Transaction) (*Valida
 			})
 		}
 
+		// Check the amount fits the currency's minor units
+		if _, known := money.LookupCurrency(entry.Amount.Currency); known && !entry.Amount.ConformsToScale() {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Code:    ErrCodeInvalidScale,
+				Message: fmt.Sprintf("Entry amount has more decimal places than %s allows", entry.Amount.Currency),
+				Field:   fmt.Sprintf("Entries[%d].Amount", i),
+			})
+		}
+
 		// Check for currency consistency
 		if i == 0 {
 			currency = entry.Amount.Currency
@@ -109,8 +126,9 @@ func (v *BasicValidator) Validate(ctx context.Context, tx *Transaction) (*Valida
 			})
 		}
 
-		// Track account usage
-		if seenAccounts[entry.AccountID] {
+		// Track account usage; an account may appear once per set of dimensions
+		key := entry.AccountID + dimensionSignature(entry.Dimensions)
+		if seenAccounts[key] {
 			result.Valid = false
 			result.Errors = append(result.Errors, ValidationError{
 				Code:    ErrCodeDuplicateAccount,
@@ -118,7 +136,7 @@ func (v *BasicValidator) Validate(ctx context.Context, tx *Transaction) (*Valida
 				Field:   fmt.Sprintf("Entries[%d].AccountID", i),
 			})
 		}
-		seenAccounts[entry.AccountID] = true
+		seenAccounts[key] = true
 
 		// Update totals
 		if entry.Type == Debit {
@@ -154,14 +172,67 @@ func (v *BasicValidator) Validate(ctx context.Context, tx *Transaction) (*Valida
 type BasicTransactionProcessor struct {
 	validator Validator
 	repo      storage.Repository
+	// Makes batch postings atomic when the backend supports transactions
+	txManager storage.TransactionManager
+	// Receives posted, voided and reversed events with each state change
+	outbox *event.Outbox
+	// Serialises postings that carry an idempotency key
+	idempotency sync.Mutex
+	// Numbers transactions as they are posted, when set
+	sequences SequenceProvider
+	numbering NumberFormat
 }
 
-// NewBasicTransactionProcessor creates a new BasicTransactionProcessor
+// NewBasicTransactionProcessor creates a new BasicTransactionProcessor. A
+// repository that is also a storage.TransactionManager is used as one.
 func NewBasicTransactionProcessor(repo storage.Repository) *BasicTransactionProcessor {
-	return &BasicTransactionProcessor{
+	p := &BasicTransactionProcessor{
 		validator: &BasicValidator{},
 		repo:      repo,
 	}
+	if manager, ok := repo.(storage.TransactionManager); ok {
+		p.txManager = manager
+	}
+	return p
+}
+
+// SetSequenceProvider makes the processor number transactions as they are
+// posted, reserving each number in the same storage transaction as the
+// posting. With a provider storing sequences in the processor's repository
+// and a backend supporting transactions, numbering is gap-free: a failed
+// posting gives its number back. Transactions that already have a number
+// keep it.
+func (p *BasicTransactionProcessor) SetSequenceProvider(provider SequenceProvider, format NumberFormat) {
+	p.sequences = provider
+	p.numbering = format
+}
+
+// number assigns a transaction the next number of its sequence
+func (p *BasicTransactionProcessor) number(ctx context.Context, tx *Transaction) error {
+	if p.sequences == nil || tx.Number != "" {
+		return nil
+	}
+	key, _ := p.numbering.key(tx)
+	n, err := p.sequences.Next(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to number transaction %s: %w", tx.ID, err)
+	}
+	tx.Number = p.numbering.Format(tx, n)
+	return nil
+}
+
+// SetTransactionManager sets the manager batch postings run under. Without
+// one, a failed batch is rolled back on a best-effort basis.
+func (p *BasicTransactionProcessor) SetTransactionManager(manager storage.TransactionManager) {
+	p.txManager = manager
+}
+
+// SetOutbox makes the processor add TransactionPosted, TransactionVoided and
+// TransactionReversed events to an outbox. The outbox should write to the
+// processor's repository: each event is then stored in the same storage
+// transaction as the change it describes when the backend supports them.
+func (p *BasicTransactionProcessor) SetOutbox(outbox *event.Outbox) {
+	p.outbox = outbox
 }
 
 // ValidateTransaction implements TransactionProcessor.ValidateTransaction
@@ -169,8 +240,28 @@ func (p *BasicTransactionProcessor) ValidateTransaction(ctx context.Context, tx
 	return p.validator.Validate(ctx, tx)
 }
 
-// ProcessTransaction implements TransactionProcessor.ProcessTransaction
+// ProcessTransaction implements TransactionProcessor.ProcessTransaction.
+// When the transaction carries an idempotency key that was already used,
+// the previously posted transaction is loaded into tx and nothing is posted.
+// A transaction read at an older version than the stored one is rejected
+// with a wrapped *storage.OptimisticLockError.
 func (p *BasicTransactionProcessor) ProcessTransaction(ctx context.Context, tx *Transaction) error {
+	if err := auth.Require(ctx, auth.TransactionPost); err != nil {
+		return err
+	}
+
+	var digest string
+	if tx.IdempotencyKey != "" {
+		p.idempotency.Lock()
+		defer p.idempotency.Unlock()
+
+		replayed, err := p.replay(ctx, tx)
+		if err != nil || replayed {
+			return err
+		}
+		digest = fingerprint(tx)
+	}
+
 	// Validate the transaction
 	result, err := p.ValidateTransaction(ctx, tx)
 	if err != nil {
@@ -185,18 +276,47 @@ func (p *BasicTransactionProcessor) ProcessTransaction(ctx context.Context, tx *
 		return fmt.Errorf("transaction must be in Draft or Pending status to process")
 	}
 
+	// Scope the transaction to the context entity
+	if err := entity.CheckScope(ctx, tx.EntityID); err != nil {
+		return err
+	}
+	before := *tx
+	if entityID, ok := entity.FromContext(ctx); ok {
+		tx.EntityID = entityID
+	}
+	if tx.CreatedBy == "" {
+		tx.CreatedBy = audit.UserID(ctx)
+	}
+	if tx.EffectiveDate == nil {
+		effective := tx.Date
+		tx.EffectiveDate = &effective
+	}
+
 	// Update transaction status and timestamps
 	now := time.Now()
 	tx.Status = Posted
 	tx.PostedAt = &now
 	tx.LastModified = now
 
-	// Store the transaction
-	err = p.repo.Update(ctx, tx)
+	// Store the transaction together with its posting event
+	err = p.atomically(ctx, func(ctx context.Context) error {
+		if err := p.number(ctx, tx); err != nil {
+			return err
+		}
+		if err := p.repo.Update(ctx, tx); err != nil {
+			return fmt.Errorf("failed to store transaction: %w", err)
+		}
+		if tx.IdempotencyKey != "" {
+			if err := p.remember(ctx, tx, digest); err != nil {
+				return err
+			}
+		}
+		return p.record(ctx, event.TransactionPosted, tx, posted(tx, before.Status))
+	})
 	if err != nil {
-		return fmt.Errorf("failed to store transaction: %w", err)
+		*tx = before
+		return err
 	}
-
 	return nil
 }
 
@@ -205,6 +325,9 @@ func (p *BasicTransactionProcessor) ProcessTransactionBatch(ctx context.Context,
 	if len(txs) == 0 {
 		return nil
 	}
+	if err := auth.Require(ctx, auth.TransactionPost); err != nil {
+		return err
+	}
 
 	// Pre-validate all transactions
 	for _, tx := range txs {
@@ -220,23 +343,74 @@ func (p *BasicTransactionProcessor) ProcessTransactionBatch(ctx context.Context,
 		if tx.Status != Draft && tx.Status != Pending {
 			return fmt.Errorf("transaction %s must be in Draft or Pending status to process", tx.ID)
 		}
+		if err := entity.CheckScope(ctx, tx.EntityID); err != nil {
+			return fmt.Errorf("transaction %s: %w", tx.ID, err)
+		}
 	}
 
 	// Update all transaction statuses and timestamps
 	now := time.Now()
-	for _, tx := range txs {
+	previous := make([]Transaction, len(txs))
+	for i, tx := range txs {
+		previous[i] = *tx
+		if entityID, ok := entity.FromContext(ctx); ok {
+			tx.EntityID = entityID
+		}
+		if tx.CreatedBy == "" {
+			tx.CreatedBy = audit.UserID(ctx)
+		}
 		tx.Status = Posted
 		tx.PostedAt = &now
 		tx.LastModified = now
+		if tx.EffectiveDate == nil {
+			effective := tx.Date
+			tx.EffectiveDate = &effective
+		}
 	}
 
-	// Store all transactions
-	// Note: The atomicity of the batch operation depends on the repository implementation
-	for _, tx := range txs {
-		err := p.repo.Update(ctx, tx)
+	// Store all transactions in one storage transaction when possible, so
+	// the backend discards the whole batch on failure
+	if p.txManager != nil {
+		err := p.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+			for i, tx := range txs {
+				if err := p.number(ctx, tx); err != nil {
+					return err
+				}
+				if err := p.repo.Update(ctx, tx); err != nil {
+					return fmt.Errorf("failed to store transaction %s: %w", tx.ID, err)
+				}
+				if err := p.record(ctx, event.TransactionPosted, tx, posted(tx, previous[i].Status)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			for i, tx := range txs {
+				tx.Status = previous[i].Status
+				tx.PostedAt = previous[i].PostedAt
+				tx.LastModified = previous[i].LastModified
+				tx.EffectiveDate = previous[i].EffectiveDate
+				tx.Version = previous[i].Version
+				tx.EntityID = previous[i].EntityID
+				tx.CreatedBy = previous[i].CreatedBy
+				tx.Number = previous[i].Number
+			}
+			return err
+		}
+		return nil
+	}
+
+	for i, tx := range txs {
+		err := p.number(ctx, tx)
+		if err == nil {
+			err = p.repo.Update(ctx, tx)
+		}
+		if err == nil {
+			err = p.record(ctx, event.TransactionPosted, tx, posted(tx, previous[i].Status))
+		}
 		if err != nil {
-			// If any transaction fails, we should roll back the entire batch
-			// This is a simplified rollback - in a real system, we'd need proper transaction support
+			// Without transaction support, undo the stored postings one by one
 			for _, rtx := range txs {
 				if rtx.Status == Posted {
 					rtx.Status = Draft
@@ -252,13 +426,18 @@ func (p *BasicTransactionProcessor) ProcessTransactionBatch(ctx context.Context,
 	return nil
 }
 
-// GetTransaction implements TransactionProcessor.GetTransaction
+// GetTransaction implements TransactionProcessor.GetTransaction. Transactions
+// of another entity than the context's are refused, which also keeps them
+// from being voided or reversed.
 func (p *BasicTransactionProcessor) GetTransaction(ctx context.Context, txID string) (*Transaction, error) {
 	var tx Transaction
 	err := p.repo.Read(ctx, txID, &tx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
 	}
+	if err := entity.CheckStored(ctx, tx.EntityID); err != nil {
+		return nil, err
+	}
 	return &tx, nil
 }
 
@@ -294,6 +473,10 @@ func (p *BasicTransactionProcessor) GetTransactionSummary(ctx context.Context, t
 
 // VoidTransaction implements TransactionProcessor.VoidTransaction
 func (p *BasicTransactionProcessor) VoidTransaction(ctx context.Context, txID string, reason string) error {
+	if err := auth.Require(ctx, auth.TransactionVoid); err != nil {
+		return err
+	}
+
 	// Retrieve the transaction
 	tx, err := p.GetTransaction(ctx, txID)
 	if err != nil {
@@ -307,6 +490,9 @@ func (p *BasicTransactionProcessor) VoidTransaction(ctx context.Context, txID st
 	if tx.VoidedAt != nil {
 		return fmt.Errorf("transaction is already voided")
 	}
+	if ctx, err = p.checkLock(ctx, tx, "void"); err != nil {
+		return err
+	}
 
 	// Update transaction status
 	now := time.Now()
@@ -315,17 +501,21 @@ func (p *BasicTransactionProcessor) VoidTransaction(ctx context.Context, txID st
 	tx.VoidReason = reason
 	tx.LastModified = now
 
-	// Store the updated transaction
-	err = p.repo.Update(ctx, tx)
-	if err != nil {
-		return fmt.Errorf("failed to store voided transaction: %w", err)
-	}
-
-	return nil
+	// Store the updated transaction together with its void event
+	return p.atomically(ctx, func(ctx context.Context) error {
+		if err := p.repo.Update(ctx, tx); err != nil {
+			return fmt.Errorf("failed to store voided transaction: %w", err)
+		}
+		return p.record(ctx, event.TransactionVoided, tx, event.TransactionVoidedV1{TransactionID: tx.ID, Reason: reason})
+	})
 }
 
 // ReverseTransaction implements TransactionProcessor.ReverseTransaction
 func (p *BasicTransactionProcessor) ReverseTransaction(ctx context.Context, txID string, reason string) error {
+	if err := auth.Require(ctx, auth.TransactionReverse); err != nil {
+		return err
+	}
+
 	// Retrieve the original transaction
 	origTx, err := p.GetTransaction(ctx, txID)
 	if err != nil {
@@ -339,36 +529,39 @@ func (p *BasicTransactionProcessor) ReverseTransaction(ctx context.Context, txID
 	if origTx.ReversedAt != nil {
 		return fmt.Errorf("transaction is already reversed")
 	}
+	if ctx, err = p.checkLock(ctx, origTx, "reverse"); err != nil {
+		return err
+	}
 
 	// Create reversal transaction
 	now := time.Now()
-	reversalTx := &Transaction{
-		ID:           fmt.Sprintf("REV-%s", origTx.ID), // Prefix with REV for clarity
-		Type:         Reversal,
-		Status:       Draft,
-		Date:         now,
-		Description:  fmt.Sprintf("Reversal of %s: %s", origTx.ID, reason),
-		Entries:      make([]Entry, len(origTx.Entries)),
-		CreatedBy:    origTx.CreatedBy,
-		Created:      now,
-		LastModified: now,
-		ReversedFrom: origTx.ID,
-	}
+	reversalTx := newReversal(origTx, fmt.Sprintf("REV-%s", origTx.ID), fmt.Sprintf("Reversal of %s: %s", origTx.ID, reason), now)
+	reversalTx.Entries = make([]Entry, len(origTx.Entries))
 
 	// Create reversed entries (swap debits and credits)
 	for i, entry := range origTx.Entries {
 		reversalTx.Entries[i] = Entry{
-			AccountID:    entry.AccountID,
-			Amount:       entry.Amount,
+			AccountID:   entry.AccountID,
+			Amount:      entry.Amount,
 			Type:        entry.Type.Reverse(), // Swap debit/credit
 			Description: fmt.Sprintf("Reversal of: %s", entry.Description),
+			PartyID:     entry.PartyID,
+			Dimensions:  entry.Dimensions,
 		}
 	}
 
-	// Process the reversal transaction
-	err = p.ProcessTransaction(ctx, reversalTx)
-	if err != nil {
-		return fmt.Errorf("failed to process reversal transaction: %w", err)
+	// Only the outstanding amounts remain after partial reversals
+	if len(origTx.PartialReversalIDs) > 0 {
+		reversed := outstandingReversed(origTx)
+		remaining := reversalTx.Entries[:0]
+		for i, entry := range reversalTx.Entries {
+			entry.Amount.Amount = entry.Amount.Amount.Sub(reversed[i])
+			if !entry.Amount.IsZero() {
+				remaining = append(remaining, entry)
+			}
+		}
+		reversalTx.Entries = remaining
+		reversalTx.ID = fmt.Sprintf("REV-%s-%d", origTx.ID, len(origTx.PartialReversalIDs)+1)
 	}
 
 	// Update original transaction
@@ -376,11 +569,75 @@ func (p *BasicTransactionProcessor) ReverseTransaction(ctx context.Context, txID
 	origTx.ReversalID = reversalTx.ID
 	origTx.LastModified = now
 
-	// Store the updated original transaction
-	err = p.repo.Update(ctx, origTx)
-	if err != nil {
-		return fmt.Errorf("failed to update original transaction: %w", err)
+	return p.postReversal(ctx, origTx, reversalTx, origTx, reason)
+}
+
+// newReversal builds the draft reversing a transaction; callers add the
+// reversed entries
+func newReversal(origTx *Transaction, id, description string, now time.Time) *Transaction {
+	return &Transaction{
+		ID:           id,
+		Type:         Reversal,
+		Status:       Draft,
+		Date:         now,
+		Description:  description,
+		CreatedBy:    origTx.CreatedBy,
+		EntityID:     origTx.EntityID,
+		Created:      now,
+		LastModified: now,
+		ReversedFrom: origTx.ID,
 	}
+}
 
-	return nil
+// postReversal creates and posts a reversal, stores the original it
+// reverses and records the reversal event, keyed by the given transaction,
+// in one storage transaction
+func (p *BasicTransactionProcessor) postReversal(ctx context.Context, origTx, reversalTx, key *Transaction, reason string) error {
+	return p.atomically(ctx, func(ctx context.Context) error {
+		if err := CreateAndPost(ctx, p.repo, p, reversalTx); err != nil {
+			return fmt.Errorf("failed to process reversal transaction: %w", err)
+		}
+		if err := p.repo.Update(ctx, origTx); err != nil {
+			return fmt.Errorf("failed to update original transaction: %w", err)
+		}
+		return p.record(ctx, event.TransactionReversed, key, event.TransactionReversedV1{
+			TransactionID: origTx.ID,
+			ReversalID:    reversalTx.ID,
+			Reason:        reason,
+		})
+	})
+}
+
+// atomically runs fn in a storage transaction when the processor has a
+// transaction manager, and directly otherwise
+func (p *BasicTransactionProcessor) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.txManager == nil {
+		return fn(ctx)
+	}
+	return p.txManager.WithTransaction(ctx, fn)
+}
+
+// record adds an event describing a change to a transaction to the outbox,
+// if one is set. Event IDs are derived from the transaction, so a change is
+// never recorded twice.
+func (p *BasicTransactionProcessor) record(ctx context.Context, eventType string, tx *Transaction, data event.Payload) error {
+	if p.outbox == nil {
+		return nil
+	}
+	return p.outbox.Add(ctx, event.Event{
+		ID:        fmt.Sprintf("%s-%s", tx.ID, strings.TrimPrefix(eventType, "transaction.")),
+		Type:      eventType,
+		Timestamp: tx.LastModified,
+		Source:    "transaction",
+		Data:      data,
+	})
+}
+
+// posted returns the payload of a transaction's posting event
+func posted(tx *Transaction, previous TransactionStatus) event.Payload {
+	return event.TransactionPostedV1{
+		TransactionID: tx.ID,
+		OldStatus:     string(previous),
+		NewStatus:     string(tx.Status),
+	}
 }
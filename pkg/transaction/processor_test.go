@@ -3,11 +3,17 @@ package transaction
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/johnayoung/finlib/pkg/audit"
+	"github.com/johnayoung/finlib/pkg/auth"
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/event"
 	"github.com/johnayoung/finlib/pkg/money"
 	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -256,6 +262,57 @@ func TestBasicTransactionProcessor_ProcessTransaction(t *testing.T) {
 	}
 }
 
+func TestBasicTransactionProcessor_Authorization(t *testing.T) {
+	auditor := &auth.Principal{ID: "auditor", Roles: []*auth.Role{{Name: "auditor", Permissions: []auth.Permission{auth.ReportGenerate}}}}
+	clerk := &auth.Principal{ID: "clerk", Roles: []*auth.Role{{Name: "clerk", Permissions: []auth.Permission{auth.TransactionPost}}}}
+
+	t.Run("denied without permission", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		processor := NewBasicTransactionProcessor(mockRepo)
+
+		ctx := auth.WithPrincipal(context.Background(), auditor)
+		err := processor.ProcessTransaction(ctx, NewTestTransaction())
+		assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+
+		err = processor.VoidTransaction(ctx, "TX001", "test")
+		assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("records principal as creator", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*transaction.Transaction")).Return(nil)
+		processor := NewBasicTransactionProcessor(mockRepo)
+
+		tx := NewTestTransaction()
+		tx.CreatedBy = ""
+		err := processor.ProcessTransaction(auth.WithPrincipal(context.Background(), clerk), tx)
+		assert.NoError(t, err)
+		assert.Equal(t, "clerk", tx.CreatedBy)
+	})
+
+	t.Run("records principal as creator of batches", func(t *testing.T) {
+		store := memory.NewMemoryStore()
+		processor := NewBasicTransactionProcessor(store)
+
+		unattributed, attributed := NewTestTransaction(), NewTestTransaction()
+		unattributed.CreatedBy = ""
+		attributed.ID = "TX002"
+		for _, tx := range []*Transaction{unattributed, attributed} {
+			assert.NoError(t, store.Create(context.Background(), tx))
+		}
+		err := processor.ProcessTransactionBatch(auth.WithPrincipal(context.Background(), clerk), []*Transaction{unattributed, attributed})
+		assert.NoError(t, err)
+
+		stored, err := processor.GetTransaction(context.Background(), unattributed.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "clerk", stored.CreatedBy)
+		stored, err = processor.GetTransaction(context.Background(), attributed.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "test-user", stored.CreatedBy)
+	})
+}
+
 func TestBasicTransactionProcessor_GetTransactionSummary(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -546,7 +603,10 @@ func TestBasicTransactionProcessor_ReverseTransaction(t *testing.T) {
 					}).
 					Return(nil)
 
-				// Setup for ProcessTransaction (creating reversal)
+				// Setup for creating and posting the reversal
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(tx *Transaction) bool {
+					return tx.Type == Reversal && tx.ReversedFrom == "TX001"
+				})).Return(nil)
 				repo.On("Update", mock.Anything, mock.MatchedBy(func(tx *Transaction) bool {
 					return tx.Type == Reversal && tx.ReversedFrom == "TX001"
 				})).Return(nil)
@@ -614,3 +674,385 @@ func TestBasicTransactionProcessor_ReverseTransaction(t *testing.T) {
 		})
 	}
 }
+
+func TestBasicTransactionProcessor_AttachDocument(t *testing.T) {
+	ctx := context.Background()
+	docs := NewMemoryDocumentStore()
+	att, err := docs.Put(ctx, "receipt.pdf", AttachmentReceipt, "application/pdf", strings.NewReader("receipt contents"))
+	assert.NoError(t, err)
+	assert.Len(t, att.Hash, 64)
+	assert.NoError(t, VerifyAttachment(ctx, docs, *att))
+
+	repo := new(MockRepository)
+	repo.On("Read", mock.Anything, "TX001", mock.AnythingOfType("*transaction.Transaction")).
+		Run(func(args mock.Arguments) {
+			tx := args.Get(2).(*Transaction)
+			*tx = *NewTestTransaction()
+			tx.Status = Posted
+		}).
+		Return(nil)
+	repo.On("Update", mock.MatchedBy(func(ctx context.Context) bool {
+		return audit.CallerFromContext(ctx).Reason == "attached RECEIPT DOC-000001"
+	}), mock.MatchedBy(func(tx *Transaction) bool {
+		return len(tx.Attachments) == 1 && tx.Attachments[0].URI == att.URI && !tx.Attachments[0].AttachedAt.IsZero()
+	})).Return(nil)
+
+	processor := NewBasicTransactionProcessor(repo)
+	assert.NoError(t, processor.AttachDocument(ctx, "TX001", *att))
+	repo.AssertExpectations(t)
+
+	assert.Error(t, processor.AttachDocument(ctx, "TX001", Attachment{ID: "DOC-2"}))
+
+	// Changing the stored document breaks the link
+	docs.documents[att.URI] = []byte("altered")
+	assert.ErrorIs(t, VerifyAttachment(ctx, docs, *att), ErrAttachmentTampered)
+	assert.NoError(t, docs.Delete(ctx, att.URI))
+	assert.ErrorIs(t, VerifyAttachment(ctx, docs, *att), ErrDocumentNotFound)
+}
+
+func TestBasicTransactionProcessor_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	first := NewTestTransaction()
+	first.IdempotencyKey = "client-request-1"
+	assert.NoError(t, store.Create(ctx, first))
+	assert.NoError(t, processor.ProcessTransaction(ctx, first))
+
+	// A client retrying after a timeout sends the same request again
+	retry := NewTestTransaction()
+	retry.ID = "TX002"
+	retry.Date = first.Date
+	retry.IdempotencyKey = "client-request-1"
+	assert.NoError(t, processor.ProcessTransaction(ctx, retry))
+	assert.Equal(t, "TX001", retry.ID)
+	assert.Equal(t, Posted, retry.Status)
+	assert.Equal(t, first.PostedAt.Unix(), retry.PostedAt.Unix())
+
+	_, err := processor.GetTransaction(ctx, "TX002")
+	assert.Error(t, err, "the retry is not stored")
+
+	conflict := NewTestTransaction()
+	conflict.ID = "TX003"
+	conflict.Description = "Something else"
+	conflict.IdempotencyKey = "client-request-1"
+	assert.ErrorIs(t, processor.ProcessTransaction(ctx, conflict), ErrIdempotencyConflict)
+
+	// A key that cannot be looked up is not taken as unused
+	failing := NewBasicTransactionProcessor(&unreadableKeys{MemoryStore: store})
+	again := NewTestTransaction()
+	again.ID = "TX004"
+	again.IdempotencyKey = "client-request-2"
+	assert.NoError(t, store.Create(ctx, again))
+	assert.Error(t, failing.ProcessTransaction(ctx, again))
+	stored, err := processor.GetTransaction(ctx, "TX004")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Draft, stored.Status)
+	}
+}
+
+// unreadableKeys is a store failing to read idempotency records
+type unreadableKeys struct {
+	*memory.MemoryStore
+}
+
+func (s *unreadableKeys) Read(ctx context.Context, id string, entity interface{}) error {
+	if _, ok := entity.(*IdempotencyRecord); ok {
+		return fmt.Errorf("store unavailable")
+	}
+	return s.MemoryStore.Read(ctx, id, entity)
+}
+
+// mapStore is a storage.Repository keeping transactions in a map
+type mapStore struct {
+	storage.Repository
+	txs map[string]Transaction
+}
+
+func (s *mapStore) Read(ctx context.Context, id string, entity interface{}) error {
+	tx, ok := s.txs[id]
+	if !ok {
+		return fmt.Errorf("transaction not found")
+	}
+	*(entity.(*Transaction)) = tx
+	return nil
+}
+
+func (s *mapStore) Create(ctx context.Context, entity interface{}) error {
+	tx := entity.(*Transaction)
+	if _, ok := s.txs[tx.ID]; ok {
+		return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, tx.ID)
+	}
+	s.txs[tx.ID] = *tx
+	return nil
+}
+
+func (s *mapStore) Update(ctx context.Context, entity interface{}) error {
+	tx := entity.(*Transaction)
+	if _, ok := s.txs[tx.ID]; !ok {
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, tx.ID)
+	}
+	s.txs[tx.ID] = *tx
+	return nil
+}
+
+func (s *mapStore) Delete(ctx context.Context, id string) error {
+	delete(s.txs, id)
+	return nil
+}
+
+func TestBasicTransactionProcessor_ReversePartial(t *testing.T) {
+	ctx := context.Background()
+	usd := func(amount string) money.Money {
+		return money.Money{Amount: decimal.RequireFromString(amount), Currency: "USD"}
+	}
+	sale := Transaction{
+		ID:     "SALE1",
+		Type:   Journal,
+		Status: Posted,
+		Entries: []Entry{
+			{AccountID: "1000", Amount: usd("100"), Type: Debit, Description: "Cash"},
+			{AccountID: "4000", Amount: usd("90"), Type: Credit, Description: "Sales"},
+			{AccountID: "2300", Amount: usd("10"), Type: Credit, Description: "Sales tax"},
+		},
+	}
+	store := memory.NewMemoryStore()
+	assert.NoError(t, store.Create(ctx, &sale))
+	processor := NewBasicTransactionProcessor(store)
+	outbox := event.NewOutbox(memory.NewMemoryStore())
+	processor.SetOutbox(outbox)
+
+	refund, err := processor.ReversePartial(ctx, "SALE1", PartialReversal{
+		Reason:     "partial refund",
+		Percentage: decimal.RequireFromString("0.3333"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "REV-SALE1-1", refund.ID)
+	assert.Equal(t, "SALE1", refund.ReversedFrom)
+	assert.Equal(t, Credit, refund.Entries[0].Type)
+	assert.Equal(t, "33.33", refund.Entries[0].Amount.Amount.StringFixed(2))
+	assert.Equal(t, "30.00", refund.Entries[1].Amount.Amount.StringFixed(2))
+	assert.Equal(t, "3.33", refund.Entries[2].Amount.Amount.StringFixed(2))
+
+	original, err := processor.GetTransaction(ctx, "SALE1")
+	assert.NoError(t, err)
+	assert.Nil(t, original.ReversedAt)
+	assert.Equal(t, []string{"REV-SALE1-1"}, original.PartialReversalIDs)
+
+	// An unbalanced selection cannot be reversed on its own
+	_, err = processor.ReversePartial(ctx, "SALE1", PartialReversal{Entries: []int{0, 1}})
+	assert.Error(t, err)
+
+	_, err = processor.ReversePartial(ctx, "SALE1", PartialReversal{Percentage: decimal.RequireFromString("0.7")})
+	assert.ErrorIs(t, err, ErrOverReversal)
+
+	stored, err := processor.GetTransaction(ctx, "REV-SALE1-1")
+	assert.NoError(t, err)
+	assert.Equal(t, Posted, stored.Status)
+
+	// A full reversal afterwards only reverses what is left
+	assert.NoError(t, processor.ReverseTransaction(ctx, "SALE1", "cancelled"))
+	rest, err := processor.GetTransaction(ctx, "REV-SALE1-2")
+	assert.NoError(t, err)
+	assert.Equal(t, Posted, rest.Status)
+	assert.Equal(t, "66.67", rest.Entries[0].Amount.Amount.StringFixed(2))
+	assert.Equal(t, "60.00", rest.Entries[1].Amount.Amount.StringFixed(2))
+	assert.Equal(t, "6.67", rest.Entries[2].Amount.Amount.StringFixed(2))
+	original, err = processor.GetTransaction(ctx, "SALE1")
+	assert.NoError(t, err)
+	assert.NotNil(t, original.ReversedAt)
+	assert.Equal(t, "REV-SALE1-2", original.ReversalID)
+
+	pending, err := outbox.Pending(ctx)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(pending))
+	for _, m := range pending {
+		ids = append(ids, m.Event.ID)
+	}
+	assert.ElementsMatch(t, []string{"REV-SALE1-1-posted", "REV-SALE1-1-reversed", "REV-SALE1-2-posted", "SALE1-reversed"}, ids)
+}
+
+func TestBasicTransactionProcessor_ReverseDimensions(t *testing.T) {
+	ctx := context.Background()
+	usd := money.Money{Amount: decimal.NewFromInt(50), Currency: "USD"}
+	payroll := Transaction{
+		ID:     "PAY1",
+		Type:   Journal,
+		Status: Posted,
+		Entries: []Entry{
+			{AccountID: "6000", Amount: usd, Type: Debit, Dimensions: map[string]string{"department": "sales"}},
+			{AccountID: "6000", Amount: usd, Type: Debit, Dimensions: map[string]string{"department": "support"}},
+			{AccountID: "1000", Amount: money.Money{Amount: decimal.NewFromInt(100), Currency: "USD"}, Type: Credit},
+		},
+	}
+	store := memory.NewMemoryStore()
+	assert.NoError(t, store.Create(ctx, &payroll))
+	processor := NewBasicTransactionProcessor(store)
+
+	assert.NoError(t, processor.ReverseTransaction(ctx, "PAY1", "wrong period"))
+	reversal, err := processor.GetTransaction(ctx, "REV-PAY1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"department": "sales"}, reversal.Entries[0].Dimensions)
+	assert.Equal(t, map[string]string{"department": "support"}, reversal.Entries[1].Dimensions)
+	assert.Equal(t, Credit, reversal.Entries[0].Type)
+}
+
+// txMapStore is a mapStore whose writes inside WithTransaction are
+// discarded when the function fails
+type txMapStore struct {
+	mapStore
+	failID string
+}
+
+func (s *txMapStore) Update(ctx context.Context, entity interface{}) error {
+	if entity.(*Transaction).ID == s.failID {
+		return assert.AnError
+	}
+	return s.mapStore.Update(ctx, entity)
+}
+
+func (s *txMapStore) BeginTransaction(ctx context.Context) (storage.Transaction, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (s *txMapStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	committed := s.txs
+	s.txs = make(map[string]Transaction, len(committed))
+	for id, tx := range committed {
+		s.txs[id] = tx
+	}
+	if err := fn(ctx); err != nil {
+		s.txs = committed
+		return err
+	}
+	return nil
+}
+
+func TestBasicTransactionProcessor_ProcessTransactionBatchAtomic(t *testing.T) {
+	batch := func() []*Transaction {
+		second := NewTestTransaction()
+		second.ID = "TX002"
+		return []*Transaction{NewTestTransaction(), second}
+	}
+	store := &txMapStore{mapStore: mapStore{txs: make(map[string]Transaction)}, failID: "TX002"}
+	processor := NewBasicTransactionProcessor(store)
+
+	txs := batch()
+	for _, tx := range txs {
+		assert.NoError(t, store.Create(context.Background(), tx))
+	}
+	err := processor.ProcessTransactionBatch(context.Background(), txs)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, Draft, store.txs["TX001"].Status, "the failed batch leaves nothing posted")
+	for _, tx := range txs {
+		assert.Equal(t, Draft, tx.Status)
+		assert.Nil(t, tx.PostedAt)
+		assert.Nil(t, tx.EffectiveDate)
+	}
+
+	store.failID = ""
+	assert.NoError(t, processor.ProcessTransactionBatch(context.Background(), txs))
+	assert.Len(t, store.txs, 2)
+	assert.Equal(t, Posted, store.txs["TX002"].Status)
+}
+
+func TestBasicTransactionProcessor_OptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	tx := NewTestTransaction()
+	assert.NoError(t, store.Create(ctx, tx))
+	stale := *tx
+	assert.NoError(t, processor.ProcessTransaction(ctx, tx))
+	assert.Equal(t, int64(2), tx.Version)
+
+	// A second client still holding the draft it read earlier
+	var lockErr *storage.OptimisticLockError
+	err := processor.ProcessTransaction(ctx, &stale)
+	if assert.ErrorAs(t, err, &lockErr) {
+		assert.Equal(t, int64(1), lockErr.ExpectedVersion)
+		assert.Equal(t, int64(2), lockErr.CurrentVersion)
+	}
+
+	info, err := store.GetVersionInfo(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), info.Version)
+}
+
+func TestBasicTransactionProcessor_TenantScope(t *testing.T) {
+	acme := entity.WithEntity(context.Background(), "acme")
+	globex := entity.WithEntity(context.Background(), "globex")
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	tx := NewTestTransaction()
+	assert.NoError(t, store.Create(globex, tx))
+	assert.NoError(t, processor.ProcessTransaction(globex, tx))
+	assert.Equal(t, "globex", tx.EntityID)
+
+	_, err := processor.GetTransaction(acme, tx.ID)
+	assert.ErrorIs(t, err, entity.ErrEntityMismatch)
+	assert.ErrorIs(t, processor.VoidTransaction(acme, tx.ID, "not ours"), entity.ErrEntityMismatch)
+	assert.ErrorIs(t, processor.ReverseTransaction(acme, tx.ID, "not ours"), entity.ErrEntityMismatch)
+
+	other := NewTestTransaction()
+	other.ID = "TX002"
+	other.EntityID = "globex"
+	assert.ErrorIs(t, processor.ProcessTransactionBatch(acme, []*Transaction{other}), entity.ErrEntityMismatch)
+}
+
+// eventCounter counts the events it receives
+type eventCounter struct {
+	count int
+}
+
+func (h *eventCounter) Handle(ctx context.Context, e event.Event) error {
+	h.count++
+	return nil
+}
+
+func TestBasicTransactionProcessor_Outbox(t *testing.T) {
+	ctx := context.Background()
+	store := &txMapStore{mapStore: mapStore{txs: make(map[string]Transaction)}}
+	outbox := event.NewOutbox(memory.NewMemoryStore())
+	processor := NewBasicTransactionProcessor(store)
+	processor.SetOutbox(outbox)
+
+	first := NewTestTransaction()
+	second := NewTestTransaction()
+	second.ID = "TX002"
+	assert.NoError(t, CreateAndPost(ctx, store, processor, first))
+	assert.NoError(t, CreateAndPost(ctx, store, processor, second))
+	assert.NoError(t, processor.VoidTransaction(ctx, "TX002", "duplicate"))
+	assert.NoError(t, processor.ReverseTransaction(ctx, "TX001", "cancelled"))
+
+	pending, err := outbox.Pending(ctx)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(pending))
+	for _, m := range pending {
+		ids = append(ids, m.Event.ID)
+	}
+	assert.ElementsMatch(t, []string{"TX001-posted", "TX002-posted", "TX002-voided", "REV-TX001-posted", "TX001-reversed"}, ids)
+
+	bus := event.NewMemoryBus()
+	posted := &eventCounter{}
+	assert.NoError(t, bus.Subscribe(event.TransactionPosted, posted))
+	dispatched, err := outbox.Dispatch(ctx, bus)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, dispatched)
+	assert.Equal(t, 3, posted.count)
+
+	dispatched, err = outbox.Dispatch(ctx, bus)
+	assert.NoError(t, err)
+	assert.Zero(t, dispatched, "dispatched events are not published again")
+
+	// A posting whose event cannot be stored is rolled back
+	assert.NoError(t, outbox.Add(ctx, event.Event{ID: "TX003-posted", Type: event.TransactionPosted}))
+	third := NewTestTransaction()
+	third.ID = "TX003"
+	assert.Error(t, CreateAndPost(ctx, store, processor, third))
+	assert.Equal(t, Draft, third.Status)
+	assert.NotContains(t, store.txs, "TX003")
+}
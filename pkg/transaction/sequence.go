@@ -0,0 +1,174 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/storage"
+)
+
+// ErrSequenceConflict is returned when a sequence number could not be
+// reserved because other postings kept taking it first
+var ErrSequenceConflict = errors.New("sequence conflict")
+
+// SequenceKey identifies a numbering sequence. Each ledger, an entity's
+// journal, numbers its transactions separately; formats may further split
+// sequences by transaction type and year.
+type SequenceKey struct {
+	EntityID  string          `json:"entity_id,omitempty"`
+	JournalID string          `json:"journal_id,omitempty"`
+	Type      TransactionType `json:"type,omitempty"`
+	Year      int             `json:"year,omitempty"`
+}
+
+// String returns the key as a storage identifier
+func (k SequenceKey) String() string {
+	return fmt.Sprintf("SEQ/%s/%s/%s/%d", k.EntityID, k.JournalID, k.Type, k.Year)
+}
+
+// SequenceProvider reserves sequential numbers
+type SequenceProvider interface {
+	// Next reserves and returns the next number of a sequence, starting at 1
+	Next(ctx context.Context, key SequenceKey) (int64, error)
+}
+
+// NumberFormat controls how posted transactions are numbered
+type NumberFormat struct {
+	// Prefix of the numbers; defaults to "JE"
+	Prefix string
+	// Prefixes of transaction types numbered from their own sequence
+	TypePrefixes map[TransactionType]string
+	// Whether numbering restarts every calendar year, with the year in the
+	// number, e.g. JE-2025-000123
+	Yearly bool
+	// Digits the number is zero-padded to; defaults to 6
+	Width int
+}
+
+// key returns the sequence a transaction is numbered from and its prefix
+func (f NumberFormat) key(tx *Transaction) (SequenceKey, string) {
+	key := SequenceKey{EntityID: tx.EntityID, JournalID: tx.JournalID}
+	prefix := f.Prefix
+	if prefix == "" {
+		prefix = "JE"
+	}
+	if typePrefix, ok := f.TypePrefixes[tx.Type]; ok {
+		key.Type = tx.Type
+		prefix = typePrefix
+	}
+	if f.Yearly {
+		key.Year = tx.Date.Year()
+	}
+	return key, prefix
+}
+
+// Format renders a sequence number of a transaction
+func (f NumberFormat) Format(tx *Transaction, n int64) string {
+	key, prefix := f.key(tx)
+	width := f.Width
+	if width == 0 {
+		width = 6
+	}
+	if key.Year != 0 {
+		return fmt.Sprintf("%s-%d-%0*d", prefix, key.Year, width, n)
+	}
+	return fmt.Sprintf("%s-%0*d", prefix, width, n)
+}
+
+// MemorySequenceProvider keeps sequences in memory
+type MemorySequenceProvider struct {
+	mu        sync.Mutex
+	sequences map[SequenceKey]int64
+}
+
+// NewMemorySequenceProvider creates an empty in-memory provider
+func NewMemorySequenceProvider() *MemorySequenceProvider {
+	return &MemorySequenceProvider{sequences: make(map[SequenceKey]int64)}
+}
+
+// Next implements SequenceProvider.Next
+func (p *MemorySequenceProvider) Next(ctx context.Context, key SequenceKey) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sequences[key]++
+	return p.sequences[key], nil
+}
+
+// SetNext sets the next number a sequence will assign, e.g. when continuing
+// numbering carried over from another system
+func (p *MemorySequenceProvider) SetNext(key SequenceKey, next int64) error {
+	if next < 1 {
+		return fmt.Errorf("next number must be positive")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sequences[key] = next - 1
+	return nil
+}
+
+// Sequence is the stored state of a numbering sequence
+type Sequence struct {
+	ID    string `json:"id"`
+	Value int64  `json:"value"`
+	// Version the sequence was stored at, set by the repository
+	Version int64 `json:"version,omitempty"`
+}
+
+// GetID returns the sequence identifier
+func (s *Sequence) GetID() string { return s.ID }
+
+// GetVersion returns the version the sequence was stored at
+func (s *Sequence) GetVersion() int64 { return s.Version }
+
+// SetVersion records the version the sequence was stored at
+func (s *Sequence) SetVersion(version int64) { s.Version = version }
+
+// StoreSequenceProvider keeps sequences in a repository. Concurrent
+// reservations are detected through optimistic locking and retried, so no
+// number is handed out twice. Used with the repository the processor posts
+// to, a reservation is undone with a posting that fails.
+type StoreSequenceProvider struct {
+	repo storage.Repository
+	// Attempts made before giving up with ErrSequenceConflict
+	attempts int
+}
+
+// NewStoreSequenceProvider creates a provider storing sequences in a
+// repository
+func NewStoreSequenceProvider(repo storage.Repository) *StoreSequenceProvider {
+	return &StoreSequenceProvider{repo: repo, attempts: 100}
+}
+
+// Next implements SequenceProvider.Next
+func (p *StoreSequenceProvider) Next(ctx context.Context, key SequenceKey) (int64, error) {
+	id := key.String()
+	var last error
+	for attempt := 0; attempt < p.attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		var seq Sequence
+		if err := p.repo.Read(ctx, id, &seq); err != nil {
+			// Start the sequence; another posting may have started it first
+			seq = Sequence{ID: id, Value: 1}
+			if last = p.repo.Create(ctx, &seq); last == nil {
+				return 1, nil
+			}
+			continue
+		}
+
+		seq.Value++
+		last = p.repo.Update(ctx, &seq)
+		if last == nil {
+			return seq.Value, nil
+		}
+		var conflict *storage.OptimisticLockError
+		if !errors.As(last, &conflict) {
+			return 0, fmt.Errorf("failed to store sequence %s: %w", id, last)
+		}
+	}
+	return 0, fmt.Errorf("%w: %s after %d attempts: %v", ErrSequenceConflict, id, p.attempts, last)
+}
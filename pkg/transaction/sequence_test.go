@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTransactionProcessor_Numbering(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+	processor.SetSequenceProvider(NewMemorySequenceProvider(), NumberFormat{
+		TypePrefixes: map[TransactionType]string{Adjusting: "ADJ"},
+		Yearly:       true,
+	})
+
+	post := func(id string, txType TransactionType, date time.Time, entityID string) *Transaction {
+		tx := NewTestTransaction()
+		tx.ID = id
+		tx.Type = txType
+		tx.Date = date
+		tx.EntityID = entityID
+		require.NoError(t, store.Create(ctx, tx))
+		require.NoError(t, processor.ProcessTransaction(ctx, tx))
+		return tx
+	}
+	dec := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	jan := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "JE-2024-000001", post("T1", Journal, dec, "").Number)
+	assert.Equal(t, "JE-2024-000002", post("T2", Journal, dec, "").Number)
+	assert.Equal(t, "ADJ-2024-000001", post("T3", Adjusting, dec, "").Number)
+	assert.Equal(t, "JE-2025-000001", post("T4", Journal, jan, "").Number)
+	assert.Equal(t, "JE-2025-000001", post("T5", Journal, jan, "E2").Number, "each ledger has its own sequence")
+
+	stored, err := processor.GetTransaction(ctx, "T2")
+	require.NoError(t, err)
+	assert.Equal(t, "JE-2024-000002", stored.Number)
+
+	// A failed posting leaves the transaction unnumbered; only a
+	// transactional store would also give the number back
+	tx := NewTestTransaction()
+	tx.ID = "T6"
+	tx.Date = jan
+	assert.Error(t, processor.ProcessTransaction(ctx, tx))
+	assert.Empty(t, tx.Number)
+
+	batch := []*Transaction{NewTestTransaction(), NewTestTransaction()}
+	batch[0].ID, batch[1].ID = "T7", "T8"
+	for _, tx := range batch {
+		tx.Date = jan
+		require.NoError(t, store.Create(ctx, tx))
+	}
+	require.NoError(t, processor.ProcessTransactionBatch(ctx, batch))
+	assert.Equal(t, "JE-2025-000003", batch[0].Number)
+	assert.Equal(t, "JE-2025-000004", batch[1].Number)
+}
+
+func TestStoreSequenceProvider(t *testing.T) {
+	ctx := context.Background()
+	provider := NewStoreSequenceProvider(memory.NewMemoryStore())
+	key := SequenceKey{EntityID: "E1", Year: 2025}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	numbers := make([]int64, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			numbers[i], errs[i] = provider.Next(ctx, key)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for i, n := range numbers {
+		assert.Equal(t, int64(i+1), n, fmt.Sprintf("number %d", i))
+	}
+
+	n, err := provider.Next(ctx, SequenceKey{EntityID: "E2"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,316 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+)
+
+const (
+	// DefaultChunkSize is the number of transactions posted per chunk
+	DefaultChunkSize = 100
+	// DefaultStreamWorkers is the number of chunks posted concurrently
+	DefaultStreamWorkers = 4
+)
+
+// TransactionIterator yields transactions to post. Next returns io.EOF once
+// the iterator is exhausted. Sources of the ingest package satisfy it.
+type TransactionIterator interface {
+	Next(ctx context.Context) (*Transaction, error)
+}
+
+// channelIterator reads transactions from a channel until it is closed
+type channelIterator struct {
+	ch <-chan *Transaction
+}
+
+// ChannelIterator returns an iterator reading from a channel until it is
+// closed
+func ChannelIterator(ch <-chan *Transaction) TransactionIterator {
+	return &channelIterator{ch: ch}
+}
+
+func (it *channelIterator) Next(ctx context.Context) (*Transaction, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case tx, ok := <-it.ch:
+		if !ok {
+			return nil, io.EOF
+		}
+		return tx, nil
+	}
+}
+
+// sliceIterator yields the transactions of a slice in order
+type sliceIterator struct {
+	txs []*Transaction
+}
+
+// SliceIterator returns an iterator over a slice of transactions
+func SliceIterator(txs []*Transaction) TransactionIterator {
+	return &sliceIterator{txs: txs}
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (*Transaction, error) {
+	if len(it.txs) == 0 {
+		return nil, io.EOF
+	}
+	tx := it.txs[0]
+	it.txs = it.txs[1:]
+	return tx, nil
+}
+
+// StreamOptions controls asynchronous batch posting
+type StreamOptions struct {
+	// Transactions posted per chunk; defaults to DefaultChunkSize
+	ChunkSize int
+	// Chunks posted concurrently; defaults to DefaultStreamWorkers
+	Workers int
+	// Called with the progress so far after every chunk and once more when
+	// the stream is done. Calls are never made concurrently.
+	OnProgress func(StreamProgress)
+	// Publisher of TransactionBatchProgress and TransactionBatchCompleted
+	// events; optional
+	Publisher event.Publisher
+	// Identifies the stream in events
+	BatchID string
+	// Whether a chunk failing to post stops the stream; otherwise later
+	// chunks are still posted
+	StopOnError bool
+}
+
+// withDefaults fills unset fields with their defaults
+func (o StreamOptions) withDefaults() StreamOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = DefaultChunkSize
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultStreamWorkers
+	}
+	return o
+}
+
+// ChunkError records a chunk that failed to post
+type ChunkError struct {
+	TransactionIDs []string `json:"transaction_ids"`
+	Message        string   `json:"message"`
+}
+
+// StreamProgress reports how far a stream has got
+type StreamProgress struct {
+	BatchID string `json:"batch_id,omitempty"`
+	// Transactions read from the iterator, posted and in failed chunks
+	Received int64 `json:"received"`
+	Posted   int64 `json:"posted"`
+	Failed   int64 `json:"failed"`
+	// Chunks submitted to the processor
+	Chunks int64 `json:"chunks"`
+	// Chunks that failed to post
+	Errors []ChunkError `json:"errors,omitempty"`
+	// Whether the stream has finished
+	Done bool `json:"done"`
+}
+
+// copy returns the progress with its own copy of the errors
+func (p StreamProgress) copy() StreamProgress {
+	p.Errors = append([]ChunkError(nil), p.Errors...)
+	return p
+}
+
+// StreamJob is a stream of transactions being posted in the background
+type StreamJob struct {
+	mu       sync.Mutex
+	progress StreamProgress
+	err      error
+	done     chan struct{}
+}
+
+// Progress returns the progress of the stream so far
+func (j *StreamJob) Progress() StreamProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress.copy()
+}
+
+// Done returns a channel closed once the stream has finished
+func (j *StreamJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait blocks until the stream has finished and returns its final
+// progress. An error is returned when the iterator failed, the context was
+// cancelled or, with StopOnError, a chunk failed to post.
+func (j *StreamJob) Wait() (StreamProgress, error) {
+	<-j.done
+	return j.Progress(), j.err
+}
+
+// ProcessTransactionStream posts the transactions of an iterator in the
+// background. Transactions are collected into chunks that are posted
+// concurrently through ProcessTransactionBatch, so each chunk is posted
+// atomically but chunks may post out of order. Cancelling the context stops
+// reading and leaves chunks not yet submitted unposted; chunks already
+// being posted complete.
+func (p *BasicTransactionProcessor) ProcessTransactionStream(ctx context.Context, txs TransactionIterator, opts StreamOptions) *StreamJob {
+	opts = opts.withDefaults()
+	job := &StreamJob{
+		progress: StreamProgress{BatchID: opts.BatchID},
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(job.done)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		chunks := make(chan []*Transaction, opts.Workers)
+		var readErr error
+		go func() {
+			defer close(chunks)
+			readErr = readChunks(ctx, txs, opts.ChunkSize, job, chunks)
+		}()
+
+		var workers sync.WaitGroup
+		var chunkErr error
+		var errOnce sync.Once
+		for i := 0; i < opts.Workers; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for chunk := range chunks {
+					if ctx.Err() != nil {
+						continue
+					}
+					err := p.ProcessTransactionBatch(ctx, chunk)
+					job.chunkDone(ctx, opts, chunk, err)
+					if err != nil && opts.StopOnError {
+						errOnce.Do(func() {
+							chunkErr = err
+							cancel()
+						})
+					}
+				}
+			}()
+		}
+		workers.Wait()
+
+		err := chunkErr
+		if err == nil {
+			err = readErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		job.finish(ctx, opts, err)
+	}()
+	return job
+}
+
+// readChunks fills chunks from the iterator until it is exhausted
+func readChunks(ctx context.Context, txs TransactionIterator, size int, job *StreamJob, chunks chan<- []*Transaction) error {
+	chunk := make([]*Transaction, 0, size)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		chunk = make([]*Transaction, 0, size)
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tx, err := txs.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return flush()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error reading transactions: %w", err)
+		}
+		if tx == nil {
+			continue
+		}
+
+		job.mu.Lock()
+		job.progress.Received++
+		job.mu.Unlock()
+		chunk = append(chunk, tx)
+		if len(chunk) == size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// chunkDone records the outcome of posting a chunk and reports progress
+func (j *StreamJob) chunkDone(ctx context.Context, opts StreamOptions, chunk []*Transaction, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress.Chunks++
+	if err != nil {
+		ids := make([]string, len(chunk))
+		for i, tx := range chunk {
+			ids[i] = tx.ID
+		}
+		j.progress.Failed += int64(len(chunk))
+		j.progress.Errors = append(j.progress.Errors, ChunkError{TransactionIDs: ids, Message: err.Error()})
+	} else {
+		j.progress.Posted += int64(len(chunk))
+	}
+	j.report(ctx, opts, event.TransactionBatchProgress, err)
+}
+
+// finish records the end of the stream and reports the final progress
+func (j *StreamJob) finish(ctx context.Context, opts StreamOptions, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress.Done = true
+	j.err = err
+	// The stream context is cancelled by now; events are still published
+	j.report(context.WithoutCancel(ctx), opts, event.TransactionBatchCompleted, err)
+}
+
+// report passes the progress to the callback and publishes it. It is called
+// with the job locked, which keeps callbacks from running concurrently.
+// Publishing failures are not reported, so the stream result stands.
+func (j *StreamJob) report(ctx context.Context, opts StreamOptions, eventType string, err error) {
+	if opts.OnProgress != nil {
+		opts.OnProgress(j.progress.copy())
+	}
+	if opts.Publisher == nil {
+		return
+	}
+	data := event.TransactionBatchV1{
+		BatchID:  j.progress.BatchID,
+		Received: j.progress.Received,
+		Posted:   j.progress.Posted,
+		Failed:   j.progress.Failed,
+		Chunks:   j.progress.Chunks,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	_ = opts.Publisher.Publish(ctx, event.WithTenant(ctx, event.Event{
+		ID:        fmt.Sprintf("%s-%s-%d", eventType, j.progress.BatchID, j.progress.Chunks),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Source:    "transaction",
+		Data:      data,
+	}))
+}
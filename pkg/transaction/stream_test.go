@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/event"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher records published events
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e event.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+	return nil
+}
+
+func TestBasicTransactionProcessor_ProcessTransactionStream(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	txs := make([]*Transaction, 25)
+	for i := range txs {
+		txs[i] = NewTestTransaction()
+		txs[i].ID = fmt.Sprintf("S%02d", i)
+		require.NoError(t, store.Create(ctx, txs[i]))
+	}
+	// Unbalanced, failing the chunk it lands in
+	txs[12].Entries[1].Amount.Amount = txs[12].Entries[1].Amount.Amount.Add(txs[12].Entries[1].Amount.Amount)
+
+	ch := make(chan *Transaction)
+	go func() {
+		defer close(ch)
+		for _, tx := range txs {
+			ch <- tx
+		}
+	}()
+
+	var calls []StreamProgress
+	publisher := &recordingPublisher{}
+	job := processor.ProcessTransactionStream(ctx, ChannelIterator(ch), StreamOptions{
+		ChunkSize:  10,
+		Workers:    2,
+		BatchID:    "B1",
+		Publisher:  publisher,
+		OnProgress: func(p StreamProgress) { calls = append(calls, p) },
+	})
+	progress, err := job.Wait()
+	require.NoError(t, err)
+
+	assert.True(t, progress.Done)
+	assert.Equal(t, int64(25), progress.Received)
+	assert.Equal(t, int64(3), progress.Chunks)
+	assert.Equal(t, int64(15), progress.Posted)
+	assert.Equal(t, int64(10), progress.Failed)
+	require.Len(t, progress.Errors, 1)
+	assert.Contains(t, progress.Errors[0].TransactionIDs, "S12")
+
+	var posted Transaction
+	require.NoError(t, store.Read(ctx, "S24", &posted))
+	assert.Equal(t, Posted, posted.Status)
+	require.NoError(t, store.Read(ctx, "S13", &posted))
+	assert.Equal(t, Draft, posted.Status, "failed chunks are posted atomically")
+
+	require.Len(t, calls, 4)
+	assert.False(t, calls[2].Done)
+	assert.True(t, calls[3].Done)
+	require.Len(t, publisher.events, 4)
+	assert.Equal(t, event.TransactionBatchProgress, publisher.events[0].Type)
+	assert.Equal(t, event.TransactionBatchCompleted, publisher.events[3].Type)
+	assert.Equal(t, "B1", publisher.events[3].Data.(event.TransactionBatchV1).BatchID)
+}
+
+func TestBasicTransactionProcessor_ProcessTransactionStreamStops(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	processor := NewBasicTransactionProcessor(store)
+
+	txs := make([]*Transaction, 5)
+	for i := range txs {
+		txs[i] = NewTestTransaction()
+		txs[i].ID = fmt.Sprintf("F%d", i)
+	}
+	// Transactions never stored cannot be posted
+	job := processor.ProcessTransactionStream(ctx, SliceIterator(txs), StreamOptions{ChunkSize: 1, Workers: 1, StopOnError: true})
+	progress, err := job.Wait()
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), progress.Chunks)
+	assert.Zero(t, progress.Posted)
+
+	// Cancelling stops a stream waiting for more transactions
+	ctx, cancel := context.WithCancel(ctx)
+	job = processor.ProcessTransactionStream(ctx, ChannelIterator(make(chan *Transaction)), StreamOptions{})
+	cancel()
+	select {
+	case <-job.Done():
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop")
+	}
+	_, err = job.Wait()
+	assert.ErrorIs(t, err, context.Canceled)
+}
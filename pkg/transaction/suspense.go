@@ -0,0 +1,179 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// Code of the import warning flagging a transaction balanced to suspense
+const ErrCodeSuspenseBalanced = "SUSPENSE_BALANCED"
+
+// SuspenseItem is a transaction balanced to the suspense account whose
+// suspense entry has not been fully reclassified
+type SuspenseItem struct {
+	TransactionID string    `json:"transaction_id"`
+	Number        string    `json:"number,omitempty"`
+	Date          time.Time `json:"date"`
+	Description   string    `json:"description,omitempty"`
+	Reference     string    `json:"reference,omitempty"`
+	// Suspense entry as posted and the part still to be reclassified
+	Side        EntryType   `json:"side"`
+	Amount      money.Money `json:"amount"`
+	Outstanding money.Money `json:"outstanding"`
+	// Reclassifications posted against the item so far
+	ReclassificationIDs []string `json:"reclassification_ids,omitempty"`
+	// Days the item has been open at the report date
+	AgeDays int `json:"age_days"`
+}
+
+// SuspenseReport lists the open items of a suspense account
+type SuspenseReport struct {
+	AccountID string         `json:"account_id"`
+	AsOf      time.Time      `json:"as_of"`
+	Items     []SuspenseItem `json:"items"`
+	// Net outstanding debit balance per currency
+	Totals map[string]decimal.Decimal `json:"totals"`
+}
+
+// suspenseEntry returns the entry that balances an unbalanced transaction
+// on a suspense account, or false when the transaction has no entries to
+// balance
+func suspenseEntry(tx *Transaction, accountID string) (Entry, bool) {
+	if len(tx.Entries) == 0 {
+		return Entry{}, false
+	}
+	net := decimal.Zero
+	for _, entry := range tx.Entries {
+		if entry.Type == Debit {
+			net = net.Add(entry.Amount.Amount)
+		} else {
+			net = net.Sub(entry.Amount.Amount)
+		}
+	}
+	if net.IsZero() {
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		AccountID:   accountID,
+		Amount:      money.Money{Amount: net.Abs(), Currency: tx.Entries[0].Amount.Currency},
+		Type:        Credit,
+		Description: "Suspense: unbalanced import",
+	}
+	if net.IsNegative() {
+		entry.Type = Debit
+	}
+	return entry, true
+}
+
+// NewReclassification builds a draft transaction moving the outstanding
+// amount of a suspense item to the account it belongs on. Posting it clears
+// the item from the suspense report.
+func NewReclassification(item SuspenseItem, suspenseAccountID, accountID string, date time.Time) *Transaction {
+	now := time.Now()
+	description := fmt.Sprintf("Reclassification of suspense item %s", item.TransactionID)
+	amount := item.Outstanding
+	return &Transaction{
+		ID:           fmt.Sprintf("RCL-%s-%d", item.TransactionID, len(item.ReclassificationIDs)+1),
+		Type:         Journal,
+		Status:       Draft,
+		Date:         date,
+		Description:  description,
+		Reference:    item.TransactionID,
+		Reclassifies: item.TransactionID,
+		Entries: []Entry{
+			{AccountID: accountID, Amount: amount, Type: item.Side, Description: description},
+			{AccountID: suspenseAccountID, Amount: amount, Type: item.Side.Reverse(), Description: description},
+		},
+		Created:      now,
+		LastModified: now,
+	}
+}
+
+// SuspenseItems lists the transactions of the context entity balanced to a
+// suspense account, up to a date, whose suspense entries have not been
+// cleared by posted reclassifications, oldest first
+func (p *BasicTransactionProcessor) SuspenseItems(ctx context.Context, accountID string, asOf time.Time) (*SuspenseReport, error) {
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "entries.account_id", Operator: "=", Value: accountID},
+			{Field: "date", Operator: "<=", Value: asOf},
+			{Field: "status", Operator: "=", Value: Posted},
+		},
+		Sort: []storage.Sort{{Field: "date", Desc: false}},
+	}
+	var txs []*Transaction
+	if err := p.repo.Query(ctx, entity.ScopeQuery(ctx, query), &txs); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	items := make(map[string]*SuspenseItem)
+	order := make([]string, 0)
+	for _, tx := range txs {
+		if !tx.Suspense {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				continue
+			}
+			items[tx.ID] = &SuspenseItem{
+				TransactionID: tx.ID,
+				Number:        tx.Number,
+				Date:          tx.Date,
+				Description:   tx.Description,
+				Reference:     tx.Reference,
+				Side:          entry.Type,
+				Amount:        entry.Amount,
+				Outstanding:   entry.Amount,
+				AgeDays:       int(asOf.Sub(tx.Date).Hours() / 24),
+			}
+			order = append(order, tx.ID)
+			break
+		}
+	}
+	for _, tx := range txs {
+		item, ok := items[tx.Reclassifies]
+		if !ok {
+			continue
+		}
+		for _, entry := range tx.Entries {
+			if entry.AccountID != accountID {
+				continue
+			}
+			if entry.Type == item.Side {
+				item.Outstanding.Amount = item.Outstanding.Amount.Add(entry.Amount.Amount)
+			} else {
+				item.Outstanding.Amount = item.Outstanding.Amount.Sub(entry.Amount.Amount)
+			}
+		}
+		item.ReclassificationIDs = append(item.ReclassificationIDs, tx.ID)
+	}
+
+	report := &SuspenseReport{
+		AccountID: accountID,
+		AsOf:      asOf,
+		Items:     make([]SuspenseItem, 0),
+		Totals:    make(map[string]decimal.Decimal),
+	}
+	for _, id := range order {
+		item := items[id]
+		if item.Outstanding.IsZero() {
+			continue
+		}
+		report.Items = append(report.Items, *item)
+		outstanding := item.Outstanding.Amount
+		if item.Side == Credit {
+			outstanding = outstanding.Neg()
+		}
+		currency := item.Outstanding.Currency
+		report.Totals[currency] = report.Totals[currency].Add(outstanding)
+	}
+	return report, nil
+}
@@ -0,0 +1,300 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("transaction template not found")
+	ErrInvalidTemplate  = errors.New("invalid transaction template")
+	ErrMissingParameter = errors.New("missing template parameter")
+)
+
+// Parameters with a fixed meaning in every template
+const (
+	ParamID          = "id"
+	ParamDate        = "date" // YYYY-MM-DD
+	ParamCurrency    = "currency"
+	ParamDescription = "description"
+)
+
+// IDs of the standard templates
+const (
+	TemplateDepreciation = "depreciation"
+	TemplateAccrual      = "accrual"
+	TemplatePayroll      = "payroll"
+)
+
+// placeholder matches {name} in template fields
+var placeholder = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+// TemplateEntry is an entry whose account, amount and description may
+// contain {name} placeholders
+type TemplateEntry struct {
+	Account     string    `json:"account"`
+	Type        EntryType `json:"type"`
+	Amount      string    `json:"amount"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Template describes a recurring kind of transaction
+type Template struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        TransactionType `json:"type"`
+	Description string          `json:"description"`
+	Currency    string          `json:"currency,omitempty"`
+	Entries     []TemplateEntry `json:"entries"`
+	// Values used for parameters the caller does not supply
+	Defaults map[string]string `json:"defaults,omitempty"`
+}
+
+// Parameters returns the names of the placeholders used by the template
+func (t *Template) Parameters() []string {
+	seen := make(map[string]bool)
+	collect := func(s string) {
+		for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+			seen[m[1]] = true
+		}
+	}
+	collect(t.Description)
+	for _, e := range t.Entries {
+		collect(e.Account)
+		collect(e.Amount)
+		collect(e.Description)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateEngine stores templates and instantiates them as transactions
+type TemplateEngine struct {
+	mu        sync.RWMutex
+	templates map[string]*Template
+	validator Validator
+	sequence  int64
+}
+
+// NewTemplateEngine creates an engine holding the standard templates.
+// Instantiated transactions are checked with the validator, or with
+// BasicValidator when it is nil.
+func NewTemplateEngine(validator Validator) *TemplateEngine {
+	if validator == nil {
+		validator = &BasicValidator{}
+	}
+	e := &TemplateEngine{templates: make(map[string]*Template), validator: validator}
+	for _, t := range StandardTemplates() {
+		e.templates[t.ID] = t
+	}
+	return e
+}
+
+// Register adds or replaces a template
+func (e *TemplateEngine) Register(t *Template) error {
+	if t == nil || t.ID == "" {
+		return fmt.Errorf("%w: ID is required", ErrInvalidTemplate)
+	}
+	if len(t.Entries) < 2 {
+		return fmt.Errorf("%w: %s needs at least two entries", ErrInvalidTemplate, t.ID)
+	}
+	for i, entry := range t.Entries {
+		if entry.Account == "" || entry.Amount == "" || (entry.Type != Debit && entry.Type != Credit) {
+			return fmt.Errorf("%w: %s entry %d needs an account, amount and type", ErrInvalidTemplate, t.ID, i)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.templates[t.ID] = t
+	return nil
+}
+
+// Template returns a registered template
+func (e *TemplateEngine) Template(id string) (*Template, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	t, ok := e.templates[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+	}
+	return t, nil
+}
+
+// Templates returns the registered templates ordered by ID
+func (e *TemplateEngine) Templates() []*Template {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make([]*Template, 0, len(e.templates))
+	for _, t := range e.templates {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Instantiate fills a template's placeholders and returns a validated draft
+// transaction. Entries whose amount resolves to zero are left out.
+func (e *TemplateEngine) Instantiate(ctx context.Context, templateID string, params map[string]string) (*Transaction, error) {
+	t, err := e.Template(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(name string) (string, bool) {
+		if v, ok := params[name]; ok {
+			return v, true
+		}
+		v, ok := t.Defaults[name]
+		return v, ok
+	}
+	var missing []string
+	fill := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			name := m[1 : len(m)-1]
+			v, ok := lookup(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return v
+		})
+	}
+
+	currency, _ := lookup(ParamCurrency)
+	if currency == "" {
+		currency = t.Currency
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingParameter, ParamCurrency)
+	}
+
+	date := time.Now()
+	if v, ok := lookup(ParamDate); ok && v != "" {
+		if date, err = time.Parse("2006-01-02", v); err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q", ErrInvalidTemplate, v)
+		}
+	}
+
+	description := t.Description
+	if v, ok := params[ParamDescription]; ok {
+		description = v
+	}
+	description = fill(description)
+
+	entries := make([]Entry, 0, len(t.Entries))
+	for i, te := range t.Entries {
+		amount, err := decimal.NewFromString(fill(te.Amount))
+		if len(missing) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d amount: %v", ErrInvalidTemplate, i, err)
+		}
+		if amount.IsZero() {
+			continue
+		}
+		entryDescription := fill(te.Description)
+		if entryDescription == "" {
+			entryDescription = description
+		}
+		entries = append(entries, Entry{
+			AccountID:   fill(te.Account),
+			Amount:      money.Money{Amount: amount, Currency: currency},
+			Type:        te.Type,
+			Description: entryDescription,
+		})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingParameter, missing[0])
+	}
+
+	id, _ := lookup(ParamID)
+	if id == "" {
+		e.mu.Lock()
+		e.sequence++
+		id = fmt.Sprintf("%s-%d-%06d", t.ID, time.Now().Unix(), e.sequence)
+		e.mu.Unlock()
+	}
+
+	now := time.Now()
+	txType := t.Type
+	if txType == "" {
+		txType = Journal
+	}
+	tx := &Transaction{
+		ID:           id,
+		Type:         txType,
+		Status:       Draft,
+		Date:         date,
+		Description:  description,
+		Entries:      entries,
+		Created:      now,
+		LastModified: now,
+	}
+
+	result, err := e.validator.Validate(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate transaction: %w", err)
+	}
+	if !result.Valid {
+		return nil, fmt.Errorf("transaction validation failed: %v", result.Errors)
+	}
+	return tx, nil
+}
+
+// StandardTemplates returns templates for common recurring entries
+func StandardTemplates() []*Template {
+	return []*Template{
+		{
+			ID:          TemplateDepreciation,
+			Name:        "Depreciation",
+			Type:        Adjusting,
+			Description: "Depreciation for {period}",
+			Entries: []TemplateEntry{
+				{Account: "{expense_account}", Type: Debit, Amount: "{amount}"},
+				{Account: "{accumulated_account}", Type: Credit, Amount: "{amount}"},
+			},
+			Defaults: map[string]string{"period": "the period"},
+		},
+		{
+			ID:          TemplateAccrual,
+			Name:        "Accrued expense",
+			Type:        Adjusting,
+			Description: "Accrual of {item}",
+			Entries: []TemplateEntry{
+				{Account: "{expense_account}", Type: Debit, Amount: "{amount}"},
+				{Account: "{accrued_account}", Type: Credit, Amount: "{amount}"},
+			},
+			Defaults: map[string]string{"item": "expenses"},
+		},
+		{
+			ID:          TemplatePayroll,
+			Name:        "Payroll",
+			Type:        Journal,
+			Description: "Payroll for {period}",
+			Entries: []TemplateEntry{
+				{Account: "{wages_account}", Type: Debit, Amount: "{gross}", Description: "Gross wages"},
+				{Account: "{employer_tax_expense_account}", Type: Debit, Amount: "{employer_tax}", Description: "Employer payroll taxes"},
+				{Account: "{withholding_account}", Type: Credit, Amount: "{withholding}", Description: "Taxes withheld"},
+				{Account: "{employer_tax_payable_account}", Type: Credit, Amount: "{employer_tax}", Description: "Employer payroll taxes"},
+				{Account: "{cash_account}", Type: Credit, Amount: "{net}", Description: "Net pay"},
+			},
+			Defaults: map[string]string{"period": "the period", "employer_tax": "0"},
+		},
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/shopspring/decimal"
 )
 
 // EntryType represents the type of transaction entry (debit or credit)
@@ -29,6 +31,10 @@ const (
 	Journal  TransactionType = "JOURNAL"
 	Transfer TransactionType = "TRANSFER"
 	Reversal TransactionType = "REVERSAL"
+	// Adjusting entries may be posted into soft-closed periods
+	Adjusting TransactionType = "ADJUSTING"
+	// Closing entries roll income statement balances into retained earnings
+	Closing TransactionType = "CLOSING"
 )
 
 // TransactionStatus represents the current status of a transaction
@@ -46,7 +52,10 @@ type Entry struct {
 	AccountID   string      `json:"account_id"`
 	Amount      money.Money `json:"amount"`
 	Type        EntryType   `json:"type"`
-	Description string      `json:"description"`
+	Description string      `json:"description" encrypt:"true"`
+	PartyID     string      `json:"party_id,omitempty"`
+	// Analysis dimensions such as fund, project or department
+	Dimensions map[string]string `json:"dimensions,omitempty"`
 }
 
 // Transaction represents a financial transaction
@@ -55,9 +64,11 @@ type Transaction struct {
 	Type         TransactionType   `json:"type"`
 	Status       TransactionStatus `json:"status"`
 	Date         time.Time         `json:"date"`
-	Description  string            `json:"description"`
+	Description  string            `json:"description" encrypt:"true"`
+	Reference    string            `json:"reference,omitempty"`
 	Entries      []Entry           `json:"entries"`
 	CreatedBy    string            `json:"created_by"`
+	ModifiedBy   string            `json:"modified_by,omitempty"`
 	Created      time.Time         `json:"created"`
 	LastModified time.Time         `json:"last_modified"`
 	PostedAt     *time.Time        `json:"posted_at,omitempty"`
@@ -66,6 +77,83 @@ type Transaction struct {
 	ReversedAt   *time.Time        `json:"reversed_at,omitempty"`
 	ReversalID   string            `json:"reversal_id,omitempty"`
 	ReversedFrom string            `json:"reversed_from,omitempty"`
+	EntityID     string            `json:"entity_id,omitempty"`
+	JournalID    string            `json:"journal_id,omitempty"`
+	// Sequential number, assigned by the journal the transaction is recorded
+	// in or, failing that, when it is posted if the processor numbers
+	// transactions
+	Number string `json:"number,omitempty"`
+	// Client-supplied key; posting again with the same key returns the
+	// transaction posted the first time instead of posting twice
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// Supporting documents such as receipts and invoices
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Partial reversals posted against this transaction and the amount of
+	// each entry they have reversed so far
+	PartialReversalIDs []string          `json:"partial_reversal_ids,omitempty"`
+	ReversedAmounts    []decimal.Decimal `json:"reversed_amounts,omitempty"`
+	// Date the transaction takes economic effect when it differs from the
+	// date it was entered, e.g. for back-dated adjustments. Defaults to Date
+	// when the transaction is posted.
+	EffectiveDate *time.Time `json:"effective_date,omitempty"`
+	// When, by whom and why the transaction was locked, e.g. once reconciled
+	// or when its period closed. Locked transactions are only voided,
+	// reversed or updated under a lock override.
+	LockedAt   *time.Time `json:"locked_at,omitempty"`
+	LockedBy   string     `json:"locked_by,omitempty"`
+	LockReason string     `json:"lock_reason,omitempty"`
+	// Whether an entry to a suspense account was added on import to balance
+	// the transaction, leaving an item to reclassify
+	Suspense bool `json:"suspense,omitempty"`
+	// Suspense item whose entry this transaction reclassifies
+	Reclassifies string `json:"reclassifies,omitempty"`
+	// Changes made to the transaction while it was locked
+	LockOverrides []LockOverride `json:"lock_overrides,omitempty"`
+	// Version the transaction was stored at, set by the repository; updates
+	// made from an outdated version fail with storage.OptimisticLockError
+	Version int64 `json:"version,omitempty"`
+}
+
+// GetID returns the transaction identifier
+func (t *Transaction) GetID() string { return t.ID }
+
+// GetVersion returns the version the transaction was stored at
+func (t *Transaction) GetVersion() int64 { return t.Version }
+
+// SetVersion records the version the transaction was stored at
+func (t *Transaction) SetVersion(version int64) { t.Version = version }
+
+// GetTenantID returns the entity (tenant) that owns the transaction
+func (t *Transaction) GetTenantID() string { return t.EntityID }
+
+// SetTenantID sets the entity (tenant) that owns the transaction
+func (t *Transaction) SetTenantID(id string) { t.EntityID = id }
+
+// GetCreatedBy returns the user who created the transaction
+func (t *Transaction) GetCreatedBy() string { return t.CreatedBy }
+
+// SetCreatedBy records the user who created the transaction
+func (t *Transaction) SetCreatedBy(userID string) { t.CreatedBy = userID }
+
+// SetModifiedBy records the user who last modified the transaction
+func (t *Transaction) SetModifiedBy(userID string) { t.ModifiedBy = userID }
+
+// SearchFields names the fields full-text searches look in: matches in the
+// reference rank above the description, which ranks above entry descriptions
+func (t *Transaction) SearchFields() []storage.SearchField {
+	return []storage.SearchField{
+		{Path: "reference", Weight: 4},
+		{Path: "description", Weight: 2},
+		{Path: "entries.description", Weight: 1},
+	}
+}
+
+// EffectiveAt returns the date the transaction takes economic effect
+func (t *Transaction) EffectiveAt() time.Time {
+	if t.EffectiveDate != nil {
+		return *t.EffectiveDate
+	}
+	return t.Date
 }
 
 // ValidationError represents a single validation error
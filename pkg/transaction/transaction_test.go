@@ -141,6 +141,31 @@ func TestTransactionValidation(t *testing.T) {
 		assert.False(t, result.Valid)
 		assert.Equal(t, ErrCodeInvalidAmount, result.Errors[0].Code)
 	})
+
+	t.Run("Amount Finer Than Currency", func(t *testing.T) {
+		tx := &Transaction{
+			ID:     "TX001",
+			Type:   Journal,
+			Status: Draft,
+			Entries: []Entry{
+				{
+					AccountID: "ACC001",
+					Amount:    money.Money{Amount: decimal.RequireFromString("100.5"), Currency: "JPY"},
+					Type:      Debit,
+				},
+				{
+					AccountID: "ACC002",
+					Amount:    money.Money{Amount: decimal.RequireFromString("100.5"), Currency: "JPY"},
+					Type:      Credit,
+				},
+			},
+		}
+
+		result, err := validator.Validate(ctx, tx)
+		assert.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, ErrCodeInvalidScale, result.Errors[0].Code)
+	})
 }
 
 func createValidTransaction() *Transaction {
@@ -170,3 +195,101 @@ func createValidTransaction() *Transaction {
 		LastModified: now,
 	}
 }
+
+func TestDimensionRule(t *testing.T) {
+	usd := func(amount int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+	}
+	fund := func(name string) map[string]string { return map[string]string{"fund": name} }
+	rule := DimensionRule{Dimension: "fund", DueFromAccountID: "1900", DueToAccountID: "2900"}
+
+	// The general fund pays an expense of the restricted fund from shared cash
+	tx := &Transaction{
+		ID:          "TX-FUND",
+		Type:        Journal,
+		Status:      Draft,
+		Description: "Grant expense paid by general fund",
+		Entries: []Entry{
+			{AccountID: "6000", Amount: usd(400), Type: Debit, Dimensions: fund("restricted")},
+			{AccountID: "1000", Amount: usd(400), Type: Credit, Dimensions: fund("general")},
+		},
+	}
+	assert.ErrorIs(t, rule.Check(tx), ErrDimensionUnbalanced)
+
+	assert.NoError(t, rule.Balance(tx))
+	assert.NoError(t, rule.Check(tx))
+	assert.Len(t, tx.Entries, 4)
+	assert.Equal(t, Entry{
+		AccountID:   "1900",
+		Amount:      usd(400),
+		Type:        Debit,
+		Description: "Interfund balancing for fund general",
+		Dimensions:  fund("general"),
+	}, tx.Entries[2])
+	assert.Equal(t, "2900", tx.Entries[3].AccountID)
+	assert.Equal(t, Credit, tx.Entries[3].Type)
+
+	// The same account may appear once per fund
+	tx.Entries = append(tx.Entries, Entry{AccountID: "1000", Amount: usd(50), Type: Debit, Dimensions: fund("restricted")},
+		Entry{AccountID: "1010", Amount: usd(50), Type: Credit, Dimensions: fund("restricted")})
+	result, err := (&BasicValidator{}).Validate(context.Background(), tx)
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+
+	tx.Entries = append(tx.Entries, Entry{AccountID: "6100", Amount: usd(1), Type: Debit})
+	assert.ErrorIs(t, rule.Check(tx), ErrDimensionMissing)
+}
+
+func TestTemplateEngine(t *testing.T) {
+	ctx := context.Background()
+	engine := NewTemplateEngine(nil)
+
+	tx, err := engine.Instantiate(ctx, TemplateDepreciation, map[string]string{
+		"expense_account":     "6800",
+		"accumulated_account": "1590",
+		"amount":              "125.50",
+		"currency":            "USD",
+		"period":              "March 2024",
+		"date":                "2024-03-31",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Adjusting, tx.Type)
+	assert.Equal(t, Draft, tx.Status)
+	assert.Equal(t, "Depreciation for March 2024", tx.Description)
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), tx.Date)
+	assert.Equal(t, "6800", tx.Entries[0].AccountID)
+	assert.True(t, tx.Entries[1].Amount.Amount.Equal(decimal.RequireFromString("125.50")))
+
+	t.Run("Payroll", func(t *testing.T) {
+		params := map[string]string{
+			"wages_account":       "6100",
+			"withholding_account": "2400",
+			"cash_account":        "1110",
+			"gross":               "5000",
+			"withholding":         "1200",
+			"net":                 "3800",
+			"currency":            "USD",
+		}
+		tx, err := engine.Instantiate(ctx, TemplatePayroll, params)
+		assert.NoError(t, err)
+		// Employer taxes default to zero and are left out
+		assert.Len(t, tx.Entries, 3)
+		assert.Equal(t, "Net pay", tx.Entries[2].Description)
+
+		params["net"] = "3000"
+		_, err = engine.Instantiate(ctx, TemplatePayroll, params)
+		assert.Error(t, err, "unbalanced payroll is rejected")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := engine.Instantiate(ctx, "missing", nil)
+		assert.ErrorIs(t, err, ErrTemplateNotFound)
+		_, err = engine.Instantiate(ctx, TemplateAccrual, map[string]string{"currency": "USD", "amount": "10"})
+		assert.ErrorIs(t, err, ErrMissingParameter)
+		assert.ErrorIs(t, engine.Register(&Template{ID: "one-sided", Entries: []TemplateEntry{{Account: "1", Type: Debit, Amount: "1"}}}), ErrInvalidTemplate)
+	})
+
+	tpl, err := engine.Template(TemplateAccrual)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"accrued_account", "amount", "expense_account", "item"}, tpl.Parameters())
+}
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"context"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/tenant"
+)
+
+// Cache holds the results of expensive lookups made by validators, such as
+// reading an account, for the duration of a batch. Each key is loaded once
+// even when validators run concurrently, and failed lookups are cached too.
+// Cached values are shared and must not be modified.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// CacheStats counts the lookups answered by a cache
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// NewCache creates an empty cache
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]*cacheEntry)}
+}
+
+// Load returns the value cached under a key, calling load to fill it on
+// first use. Concurrent callers of a key being loaded wait for the result.
+func (c *Cache) Load(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.mu.Unlock()
+		<-e.done
+		return e.value, e.err
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.misses++
+	c.mu.Unlock()
+
+	e.value, e.err = load()
+	close(e.done)
+	return e.value, e.err
+}
+
+// Stats returns the number of lookups answered from the cache and loaded
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses}
+}
+
+type cacheKey struct{}
+
+// WithCache returns a context whose validators share a cache
+func WithCache(ctx context.Context, cache *Cache) context.Context {
+	return context.WithValue(ctx, cacheKey{}, cache)
+}
+
+// CacheFromContext returns the cache carried by a context, if any
+func CacheFromContext(ctx context.Context) (*Cache, bool) {
+	cache, ok := ctx.Value(cacheKey{}).(*Cache)
+	return cache, ok && cache != nil
+}
+
+// Cached loads a value through the context cache, keyed within the context
+// tenant. Without a cache the value is loaded on every call.
+func Cached(ctx context.Context, key string, load func() (interface{}, error)) (interface{}, error) {
+	cache, ok := CacheFromContext(ctx)
+	if !ok {
+		return load()
+	}
+	return cache.Load(tenant.ID(ctx)+"/"+key, load)
+}
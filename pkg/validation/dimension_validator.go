@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// DimensionBalanceValidator enforces that transactions balance within each
+// value of a dimension, as fund accounting requires
+type DimensionBalanceValidator struct {
+	rule transaction.DimensionRule
+}
+
+// NewDimensionBalanceValidator creates a validator for a dimension rule
+func NewDimensionBalanceValidator(rule transaction.DimensionRule) *DimensionBalanceValidator {
+	return &DimensionBalanceValidator{rule: rule}
+}
+
+// Validate performs validation on a transaction
+func (v *DimensionBalanceValidator) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	err := v.rule.Check(tx)
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.Is(err, transaction.ErrDimensionMissing):
+		return []ValidationResult{{
+			Code:     "DIM_MISSING",
+			Message:  err.Error(),
+			Severity: Error,
+			Field:    "Entries.Dimensions",
+		}}, nil
+	case errors.Is(err, transaction.ErrDimensionUnbalanced):
+		return []ValidationResult{{
+			Code:     "DIM_UNBALANCED",
+			Message:  err.Error(),
+			Severity: Error,
+			Field:    "Entries",
+			Metadata: map[string]interface{}{"dimension": v.rule.Dimension},
+		}}, nil
+	}
+	return nil, err
+}
+
+// GetRules returns the rules this validator checks
+func (v *DimensionBalanceValidator) GetRules() []ValidationRule {
+	return []ValidationRule{
+		{
+			ID:          "DIM_MISSING",
+			Description: fmt.Sprintf("Every entry must carry a %s", v.rule.Dimension),
+			Severity:    Error,
+			Category:    "TRANSACTION",
+		},
+		{
+			ID:          "DIM_UNBALANCED",
+			Description: fmt.Sprintf("Debits must equal credits within each %s", v.rule.Dimension),
+			Severity:    Error,
+			Category:    "TRANSACTION",
+		},
+	}
+}
+
+// Supports implements ObjectFilter; only transactions are checked
+func (v *DimensionBalanceValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// Priority returns the validator's priority; it runs after the basic
+// transaction checks
+func (v *DimensionBalanceValidator) Priority() int {
+	return 110
+}
@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnayoung/finlib/pkg/entity"
+	"github.com/johnayoung/finlib/pkg/similarity"
+	"github.com/johnayoung/finlib/pkg/storage"
+	"github.com/johnayoung/finlib/pkg/transaction"
+)
+
+// Default minimum description similarity of duplicates
+const DefaultDuplicateSimilarity = 0.8
+
+// DuplicateOptions configures a DuplicateValidator
+type DuplicateOptions struct {
+	// Days either side of the transaction date searched for duplicates;
+	// zero only searches the same date
+	WindowDays int
+	// Minimum description similarity, between 0 and 1, of duplicates;
+	// zero means DefaultDuplicateSimilarity
+	MinSimilarity float64
+}
+
+// DuplicateValidator warns about transactions that look like ones already
+// pending or posted: the same entries, with the same accounts, sides and
+// amounts, dated within a window and with a similar description. Its
+// results are warnings, so suspicious transactions are flagged for review
+// rather than rejected.
+type DuplicateValidator struct {
+	repo storage.Repository
+	opts DuplicateOptions
+}
+
+// NewDuplicateValidator creates a validator searching a transaction
+// repository
+func NewDuplicateValidator(repo storage.Repository, opts DuplicateOptions) *DuplicateValidator {
+	if opts.WindowDays < 0 {
+		opts.WindowDays = 0
+	}
+	if opts.MinSimilarity <= 0 {
+		opts.MinSimilarity = DefaultDuplicateSimilarity
+	}
+	return &DuplicateValidator{repo: repo, opts: opts}
+}
+
+// Validate implements Validator. Each likely duplicate gets its own result,
+// with the duplicate's ID, the description similarity and the days between
+// the transactions in its metadata.
+func (v *DuplicateValidator) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+	if len(tx.Entries) == 0 {
+		return nil, nil
+	}
+
+	window := time.Duration(v.opts.WindowDays) * 24 * time.Hour
+	query := storage.Query{
+		Filters: []storage.Filter{
+			{Field: "date", Operator: ">=", Value: tx.Date.Add(-window)},
+			{Field: "date", Operator: "<=", Value: tx.Date.Add(window)},
+			{Field: "status", Operator: "in", Value: []transaction.TransactionStatus{transaction.Pending, transaction.Posted}},
+			{Field: "entries.account_id", Operator: "=", Value: tx.Entries[0].AccountID},
+		},
+		Sort: []storage.Sort{{Field: "date", Desc: false}},
+	}
+	var candidates []*transaction.Transaction
+	if err := v.repo.Query(ctx, entity.ScopeQuery(ctx, query), &candidates); err != nil {
+		return nil, fmt.Errorf("error querying transactions: %w", err)
+	}
+
+	fingerprint := entryFingerprint(tx)
+	var results []ValidationResult
+	for _, other := range candidates {
+		if other.ID == tx.ID || other.EntityID != tx.EntityID || entryFingerprint(other) != fingerprint {
+			continue
+		}
+		similarity := descriptionSimilarity(tx.Description, other.Description)
+		if similarity < v.opts.MinSimilarity {
+			continue
+		}
+		days := int(tx.Date.Sub(other.Date).Hours() / 24)
+		if days < 0 {
+			days = -days
+		}
+		results = append(results, ValidationResult{
+			Code:     "TX_DUPLICATE",
+			Message:  fmt.Sprintf("transaction may duplicate %s of %s", other.ID, other.Date.Format("2006-01-02")),
+			Severity: Warning,
+			Metadata: map[string]interface{}{
+				"duplicate_id": other.ID,
+				"similarity":   similarity,
+				"days_apart":   days,
+			},
+		})
+	}
+	return results, nil
+}
+
+// entryFingerprint describes the entries of a transaction regardless of
+// their order
+func entryFingerprint(tx *transaction.Transaction) string {
+	entries := make([]string, len(tx.Entries))
+	for i, entry := range tx.Entries {
+		entries[i] = fmt.Sprintf("%s|%s|%s|%s", entry.AccountID, entry.Type, entry.Amount.Amount.String(), entry.Amount.Currency)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ";")
+}
+
+// descriptionSimilarity scores two descriptions, treating two blank
+// descriptions as the same
+func descriptionSimilarity(a, b string) float64 {
+	if strings.TrimSpace(a) == "" && strings.TrimSpace(b) == "" {
+		return 1
+	}
+	return similarity.Score(a, b)
+}
+
+// Supports implements ObjectFilter; only transactions are checked
+func (v *DuplicateValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements Validator
+func (v *DuplicateValidator) GetRules() []ValidationRule {
+	return []ValidationRule{
+		{
+			ID:          "TX_DUPLICATE",
+			Description: fmt.Sprintf("Transactions should not repeat the entries and description of one within %d days", v.opts.WindowDays),
+			Severity:    Warning,
+			Category:    "TRANSACTION",
+		},
+	}
+}
+
+// Priority implements Validator; duplicates are searched for once the
+// cheaper checks have run
+func (v *DuplicateValidator) Priority() int {
+	return 40
+}
@@ -2,14 +2,20 @@ package validation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
+
+	"github.com/johnayoung/finlib/pkg/tenant"
+	"github.com/johnayoung/finlib/pkg/transaction"
 )
 
 // BasicValidationEngine provides a simple implementation of ValidationEngine
 type BasicValidationEngine struct {
 	validators []Validator
+	tenants    map[string][]Validator
+	workers    int
 	mu        sync.RWMutex
 }
 
@@ -17,6 +23,7 @@ type BasicValidationEngine struct {
 func NewBasicValidationEngine() *BasicValidationEngine {
 	return &BasicValidationEngine{
 		validators: make([]Validator, 0),
+		tenants:    make(map[string][]Validator),
 	}
 }
 
@@ -38,7 +45,38 @@ func (e *BasicValidationEngine) RegisterValidator(validator Validator) error {
 	return nil
 }
 
-// Validate runs all applicable validators against an object
+// SetConcurrency sets how many validators may check an object at once, and
+// how many objects ValidateBatch checks at once. With more than one worker
+// the validators must be safe for concurrent use; their results are still
+// reported in priority order. The default of 1 runs them one at a time.
+func (e *BasicValidationEngine) SetConcurrency(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.workers = workers
+}
+
+// RegisterTenantValidator adds a validator that only runs for objects
+// validated in the scope of the given tenant
+func (e *BasicValidationEngine) RegisterTenantValidator(tenantID string, validator Validator) error {
+	if tenantID == "" {
+		return fmt.Errorf("tenant ID cannot be empty")
+	}
+	if validator == nil {
+		return fmt.Errorf("validator cannot be nil")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.tenants[tenantID] = append(e.tenants[tenantID], validator)
+	return nil
+}
+
+// Validate runs all applicable validators against an object. Validators
+// registered for the context tenant run alongside the global validators.
 func (e *BasicValidationEngine) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
 	if obj == nil {
 		return nil, fmt.Errorf("cannot validate nil object")
@@ -47,22 +85,51 @@ func (e *BasicValidationEngine) Validate(ctx context.Context, obj interface{}) (
 	e.mu.RLock()
 	validators := make([]Validator, len(e.validators))
 	copy(validators, e.validators)
+	if id, ok := tenant.FromContext(ctx); ok {
+		validators = append(validators, e.tenants[id]...)
+		sort.SliceStable(validators, func(i, j int) bool {
+			return validators[i].Priority() < validators[j].Priority()
+		})
+	}
+	workers := e.workers
 	e.mu.RUnlock()
 
+	applicable := validators[:0]
+	for _, validator := range validators {
+		if filter, ok := validator.(ObjectFilter); ok && !filter.Supports(obj) {
+			continue
+		}
+		applicable = append(applicable, validator)
+	}
+
+	// Each validator's results go to its own slot so they are reported in
+	// priority order however the validators are scheduled
+	outcomes := make([]struct {
+		results []ValidationResult
+		err     error
+	}, len(applicable))
+	run := func(i int) {
+		outcomes[i].results, outcomes[i].err = applicable[i].Validate(ctx, obj)
+	}
+	if workers > 1 && len(applicable) > 1 {
+		parallel(len(applicable), workers, run)
+	} else {
+		for i := range applicable {
+			run(i)
+		}
+	}
+
 	var allResults []ValidationResult
 	var hasErrors bool
-
-	// Run each validator in priority order
-	for _, validator := range validators {
-		results, err := validator.Validate(ctx, obj)
-		if err != nil {
-			return nil, fmt.Errorf("validator error: %w", err)
+	for _, outcome := range outcomes {
+		if outcome.err != nil {
+			return nil, fmt.Errorf("validator error: %w", outcome.err)
 		}
 
-		allResults = append(allResults, results...)
+		allResults = append(allResults, outcome.results...)
 
 		// Check for error severity results
-		for _, result := range results {
+		for _, result := range outcome.results {
 			if result.Severity == Error {
 				hasErrors = true
 			}
@@ -77,7 +144,63 @@ func (e *BasicValidationEngine) Validate(ctx context.Context, obj interface{}) (
 	return allResults, nil
 }
 
-// GetValidators returns all registered validators
+// BatchResult is the outcome of validating one object of a batch
+type BatchResult struct {
+	Results []ValidationResult
+	// Err is a *ValidationError when the object failed validation, or the
+	// error of a validator that could not run
+	Err error
+}
+
+// ValidateBatch validates objects concurrently, up to the engine's
+// concurrency, and returns their outcomes in the order of the objects.
+// Validators share a Cache for the batch, so lookups such as reading an
+// account are made once however many objects need them. A cache already
+// carried by the context is used instead.
+func (e *BasicValidationEngine) ValidateBatch(ctx context.Context, objs []interface{}) []BatchResult {
+	if _, ok := CacheFromContext(ctx); !ok {
+		ctx = WithCache(ctx, NewCache())
+	}
+
+	e.mu.RLock()
+	workers := e.workers
+	e.mu.RUnlock()
+
+	outcomes := make([]BatchResult, len(objs))
+	parallel(len(objs), workers, func(i int) {
+		outcomes[i].Results, outcomes[i].Err = e.Validate(ctx, objs[i])
+	})
+	return outcomes
+}
+
+// parallel calls fn for each index below n on at most workers goroutines
+// and waits for the calls to finish
+func parallel(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// GetValidators returns all registered global validators
 func (e *BasicValidationEngine) GetValidators() []Validator {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -86,3 +209,37 @@ func (e *BasicValidationEngine) GetValidators() []Validator {
 	copy(validators, e.validators)
 	return validators
 }
+
+// EngineValidator adapts a ValidationEngine to transaction.Validator, so
+// the engine's rules can check transactions wherever the transaction
+// package accepts a validator, such as a transaction.Importer. Error
+// results reject the transaction; other results are reported as warnings.
+type EngineValidator struct {
+	engine ValidationEngine
+}
+
+// NewEngineValidator creates a transaction.Validator running an engine
+func NewEngineValidator(engine ValidationEngine) *EngineValidator {
+	return &EngineValidator{engine: engine}
+}
+
+// Validate implements transaction.Validator
+func (v *EngineValidator) Validate(ctx context.Context, tx *transaction.Transaction) (*transaction.ValidationResult, error) {
+	results, err := v.engine.Validate(ctx, tx)
+	var failed *ValidationError
+	if err != nil && !errors.As(err, &failed) {
+		return nil, err
+	}
+
+	result := &transaction.ValidationResult{Valid: true}
+	for _, r := range results {
+		e := transaction.ValidationError{Code: r.Code, Message: r.Message, Field: r.Field, Details: r.Metadata}
+		if r.Severity == Error {
+			result.Valid = false
+			result.Errors = append(result.Errors, e)
+		} else {
+			result.Warnings = append(result.Warnings, e)
+		}
+	}
+	return result, nil
+}
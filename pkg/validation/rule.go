@@ -0,0 +1,318 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidRule is returned when a rule expression cannot be compiled
+var ErrInvalidRule = errors.New("invalid rule")
+
+// RuleAction is what a custom rule does when its condition holds
+type RuleAction string
+
+const (
+	// RejectAction fails validation with an error
+	RejectAction RuleAction = "reject"
+	// WarnAction reports a warning
+	WarnAction RuleAction = "warn"
+	// RequireAction reports a warning naming something the transaction
+	// needs before it is posted, such as approval
+	RequireAction RuleAction = "require"
+)
+
+// RequirementKey is the metadata key under which results of require rules
+// name the requirement
+const RequirementKey = "require"
+
+// RuleDefinition is a business rule kept as data rather than code. Its
+// expression is a condition, an arrow and an action:
+//
+//	amount > 10000 && account.type == "EXPENSE" => require approval
+//	tx.type == "JOURNAL" && tx.reference == "" => reject "journals need a reference"
+//	currency in ["EUR", "GBP"] => warn
+//
+// Conditions combine comparisons (==, !=, <, <=, >, >=, in) with &&, ||, !
+// and parentheses. They may refer to the transaction as tx.id, tx.type,
+// tx.status, tx.date (YYYY-MM-DD), tx.description, tx.reference,
+// tx.entity, tx.total (the sum of debits) and tx.entries (the number of
+// entries). Conditions referring to an entry, through amount, currency,
+// side, description, party, account, account.<field> or dim.<dimension>,
+// are checked for each entry.
+//
+// Actions are reject, warn or require followed by a word, each optionally
+// followed by a quoted message.
+type RuleDefinition struct {
+	ID          string `json:"id"`
+	Expression  string `json:"expression"`
+	Description string `json:"description,omitempty"`
+}
+
+// AccountLookup returns the fields of an account that rules refer to as
+// account.<field>, or nil when the account is unknown. Unknown accounts and
+// fields compare as empty strings.
+type AccountLookup func(ctx context.Context, accountID string) (map[string]string, error)
+
+// CustomRule is a compiled RuleDefinition
+type CustomRule struct {
+	def         RuleDefinition
+	cond        ruleNode
+	action      RuleAction
+	requirement string
+	message     string
+	// Whether the condition refers to entries and accounts
+	perEntry    bool
+	usesAccount bool
+}
+
+// CompileRule parses and type checks a rule definition
+func CompileRule(def RuleDefinition) (*CustomRule, error) {
+	if def.ID == "" {
+		return nil, fmt.Errorf("%w: rule ID cannot be empty", ErrInvalidRule)
+	}
+	rule, err := parseRule(def.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidRule, def.ID, err)
+	}
+	rule.def = def
+	return rule, nil
+}
+
+// Definition returns the definition the rule was compiled from
+func (r *CustomRule) Definition() RuleDefinition {
+	return r.def
+}
+
+// Action returns what the rule does when its condition holds
+func (r *CustomRule) Action() RuleAction {
+	return r.action
+}
+
+// result reports the rule matching a transaction, or one of its entries
+func (r *CustomRule) result(field string) ValidationResult {
+	message := r.message
+	if message == "" {
+		message = r.def.Description
+	}
+	if message == "" {
+		message = fmt.Sprintf("rule %s matched", r.def.ID)
+	}
+	result := ValidationResult{
+		Code:     r.def.ID,
+		Message:  message,
+		Severity: r.severity(),
+		Field:    field,
+	}
+	if r.action == RequireAction {
+		result.Metadata = map[string]interface{}{RequirementKey: r.requirement}
+	}
+	return result
+}
+
+// severity returns the severity of the rule's results
+func (r *CustomRule) severity() ValidationSeverity {
+	if r.action == RejectAction {
+		return Error
+	}
+	return Warning
+}
+
+// RuleValidator checks transactions against custom rules compiled at
+// runtime, so business rules can be added and changed without a release
+type RuleValidator struct {
+	mu     sync.RWMutex
+	lookup AccountLookup
+	rules  []*CustomRule
+}
+
+// NewRuleValidator creates a validator without rules. The lookup resolves
+// account fields and may be nil if no rule refers to them.
+func NewRuleValidator(lookup AccountLookup) *RuleValidator {
+	return &RuleValidator{lookup: lookup}
+}
+
+// AddRule compiles a rule and adds it, replacing any rule with the same ID
+func (v *RuleValidator) AddRule(def RuleDefinition) error {
+	return v.LoadRules([]RuleDefinition{def})
+}
+
+// LoadRules compiles rules and adds them, replacing rules with the same
+// IDs. No rule is added unless all of them compile.
+func (v *RuleValidator) LoadRules(defs []RuleDefinition) error {
+	compiled := make([]*CustomRule, 0, len(defs))
+	for _, def := range defs {
+		rule, err := CompileRule(def)
+		if err != nil {
+			return err
+		}
+		if rule.usesAccount && v.lookup == nil {
+			return fmt.Errorf("%w: %s: account fields need an account lookup", ErrInvalidRule, def.ID)
+		}
+		compiled = append(compiled, rule)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, rule := range compiled {
+		v.remove(rule.def.ID)
+		v.rules = append(v.rules, rule)
+	}
+	return nil
+}
+
+// RemoveRule removes a rule by ID
+func (v *RuleValidator) RemoveRule(id string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.remove(id)
+}
+
+// remove drops a rule by ID; callers hold the lock
+func (v *RuleValidator) remove(id string) {
+	for i, rule := range v.rules {
+		if rule.def.ID == id {
+			v.rules = append(v.rules[:i], v.rules[i+1:]...)
+			return
+		}
+	}
+}
+
+// Rules returns the definitions of the rules in the order they were added
+func (v *RuleValidator) Rules() []RuleDefinition {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	defs := make([]RuleDefinition, len(v.rules))
+	for i, rule := range v.rules {
+		defs[i] = rule.def
+	}
+	return defs
+}
+
+// Validate implements Validator. Rules on entries report each matching
+// entry; other rules report the transaction once.
+func (v *RuleValidator) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
+	tx, ok := obj.(*transaction.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("expected *transaction.Transaction, got %T", obj)
+	}
+
+	v.mu.RLock()
+	rules := make([]*CustomRule, len(v.rules))
+	copy(rules, v.rules)
+	v.mu.RUnlock()
+
+	env := &ruleEnv{ctx: ctx, tx: tx, lookup: v.lookup, accounts: make(map[string]map[string]string)}
+	var results []ValidationResult
+	for _, rule := range rules {
+		if !rule.perEntry {
+			env.entry = nil
+			matched, err := rule.cond.eval(env)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.def.ID, err)
+			}
+			if matched.(bool) {
+				results = append(results, rule.result(""))
+			}
+			continue
+		}
+		for i := range tx.Entries {
+			env.entry = &tx.Entries[i]
+			matched, err := rule.cond.eval(env)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.def.ID, err)
+			}
+			if matched.(bool) {
+				results = append(results, rule.result(fmt.Sprintf("Entries[%d]", i)))
+			}
+		}
+	}
+	return results, nil
+}
+
+// Supports implements ObjectFilter; only transactions are checked
+func (v *RuleValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
+// GetRules implements Validator
+func (v *RuleValidator) GetRules() []ValidationRule {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	rules := make([]ValidationRule, len(v.rules))
+	for i, rule := range v.rules {
+		description := rule.def.Description
+		if description == "" {
+			description = rule.def.Expression
+		}
+		rules[i] = ValidationRule{
+			ID:          rule.def.ID,
+			Description: description,
+			Severity:    rule.severity(),
+			Category:    "CUSTOM",
+		}
+	}
+	return rules
+}
+
+// Priority implements Validator; custom rules run after the built-in checks
+func (v *RuleValidator) Priority() int {
+	return 50
+}
+
+// Requirements returns the requirements named by the results of require
+// rules, such as "approval", sorted and without duplicates
+func Requirements(results []ValidationResult) []string {
+	seen := make(map[string]bool)
+	var requirements []string
+	for _, result := range results {
+		requirement, ok := result.Metadata[RequirementKey].(string)
+		if !ok || seen[requirement] {
+			continue
+		}
+		seen[requirement] = true
+		requirements = append(requirements, requirement)
+	}
+	sort.Strings(requirements)
+	return requirements
+}
+
+// ruleEnv is what a rule condition is evaluated against
+type ruleEnv struct {
+	ctx      context.Context
+	tx       *transaction.Transaction
+	entry    *transaction.Entry
+	lookup   AccountLookup
+	accounts map[string]map[string]string
+}
+
+// account returns the fields of the current entry's account
+func (e *ruleEnv) account() (map[string]string, error) {
+	id := e.entry.AccountID
+	if fields, ok := e.accounts[id]; ok {
+		return fields, nil
+	}
+	fields, err := e.lookup(e.ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading account %s: %w", id, err)
+	}
+	e.accounts[id] = fields
+	return fields, nil
+}
+
+// txTotal returns the sum of a transaction's debits
+func txTotal(tx *transaction.Transaction) decimal.Decimal {
+	total := decimal.Zero
+	for _, entry := range tx.Entries {
+		if entry.Type == transaction.Debit {
+			total = total.Add(entry.Amount.Amount)
+		}
+	}
+	return total
+}
@@ -0,0 +1,508 @@
+package validation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/johnayoung/finlib/pkg/transaction"
+	"github.com/shopspring/decimal"
+)
+
+// valueKind is the type of a rule expression
+type valueKind string
+
+const (
+	boolKind   valueKind = "bool"
+	numberKind valueKind = "number"
+	stringKind valueKind = "string"
+)
+
+// ruleNode is a type checked part of a rule condition. Evaluating a node
+// yields a bool, decimal.Decimal or string according to its kind.
+type ruleNode interface {
+	kind() valueKind
+	eval(env *ruleEnv) (interface{}, error)
+}
+
+type literalNode struct {
+	k     valueKind
+	value interface{}
+}
+
+func (n *literalNode) kind() valueKind                    { return n.k }
+func (n *literalNode) eval(*ruleEnv) (interface{}, error) { return n.value, nil }
+
+type fieldNode struct {
+	k   valueKind
+	get func(env *ruleEnv) (interface{}, error)
+}
+
+func (n *fieldNode) kind() valueKind                        { return n.k }
+func (n *fieldNode) eval(env *ruleEnv) (interface{}, error) { return n.get(env) }
+
+type notNode struct {
+	x ruleNode
+}
+
+func (n *notNode) kind() valueKind { return boolKind }
+
+func (n *notNode) eval(env *ruleEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type logicalNode struct {
+	and         bool
+	left, right ruleNode
+}
+
+func (n *logicalNode) kind() valueKind { return boolKind }
+
+func (n *logicalNode) eval(env *ruleEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit so account lookups are only made when needed
+	if left.(bool) != n.and {
+		return left, nil
+	}
+	return n.right.eval(env)
+}
+
+type compareNode struct {
+	op          string
+	left, right ruleNode
+}
+
+func (n *compareNode) kind() valueKind { return boolKind }
+
+func (n *compareNode) eval(env *ruleEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return compareValues(n.op, compare(left, right)), nil
+}
+
+type inNode struct {
+	x     ruleNode
+	items []interface{}
+}
+
+func (n *inNode) kind() valueKind { return boolKind }
+
+func (n *inNode) eval(env *ruleEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range n.items {
+		if compare(v, item) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compare orders two values of the same kind
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case decimal.Decimal:
+		return a.Cmp(b.(decimal.Decimal))
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// compareValues applies a comparison operator to the result of compare
+func compareValues(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// txFields are the transaction fields rules can refer to
+var txFields = map[string]struct {
+	k   valueKind
+	get func(tx *transaction.Transaction) interface{}
+}{
+	"tx.id":          {stringKind, func(tx *transaction.Transaction) interface{} { return tx.ID }},
+	"tx.type":        {stringKind, func(tx *transaction.Transaction) interface{} { return string(tx.Type) }},
+	"tx.status":      {stringKind, func(tx *transaction.Transaction) interface{} { return string(tx.Status) }},
+	"tx.date":        {stringKind, func(tx *transaction.Transaction) interface{} { return tx.Date.Format("2006-01-02") }},
+	"tx.description": {stringKind, func(tx *transaction.Transaction) interface{} { return tx.Description }},
+	"tx.reference":   {stringKind, func(tx *transaction.Transaction) interface{} { return tx.Reference }},
+	"tx.entity":      {stringKind, func(tx *transaction.Transaction) interface{} { return tx.EntityID }},
+	"tx.total":       {numberKind, func(tx *transaction.Transaction) interface{} { return txTotal(tx) }},
+	"tx.entries":     {numberKind, func(tx *transaction.Transaction) interface{} { return decimal.NewFromInt(int64(len(tx.Entries))) }},
+}
+
+// entryFields are the entry fields rules can refer to
+var entryFields = map[string]struct {
+	k   valueKind
+	get func(entry *transaction.Entry) interface{}
+}{
+	"amount":      {numberKind, func(e *transaction.Entry) interface{} { return e.Amount.Amount }},
+	"currency":    {stringKind, func(e *transaction.Entry) interface{} { return e.Amount.Currency }},
+	"side":        {stringKind, func(e *transaction.Entry) interface{} { return string(e.Type) }},
+	"description": {stringKind, func(e *transaction.Entry) interface{} { return e.Description }},
+	"party":       {stringKind, func(e *transaction.Entry) interface{} { return e.PartyID }},
+	"account":     {stringKind, func(e *transaction.Entry) interface{} { return e.AccountID }},
+}
+
+// ruleToken is a lexical token of a rule expression
+type ruleToken struct {
+	// One of "ident", "number", "string", "op" or "eof"
+	kind string
+	text string
+	pos  int
+}
+
+// ruleOperators are the operators and punctuation of the rule language,
+// longest first so that "=>" is not read as "="
+var ruleOperators = []string{"=>", "&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "-"}
+
+// lexRule splits a rule expression into tokens
+func lexRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, ruleToken{kind: "string", text: text, pos: i})
+			i += len(quoted)
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{kind: "number", text: src[start:i], pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, ruleToken{kind: "ident", text: src[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range ruleOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, ruleToken{kind: "op", text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, ruleToken{kind: "eof", pos: len(src)}), nil
+}
+
+// ruleParser builds a CustomRule from tokens by recursive descent
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	rule   *CustomRule
+}
+
+// parseRule parses a rule expression
+func parseRule(src string) (*CustomRule, error) {
+	tokens, err := lexRule(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens, rule: &CustomRule{}}
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if cond.kind() != boolKind {
+		return nil, fmt.Errorf("condition must be true or false, got %s", cond.kind())
+	}
+	p.rule.cond = cond
+	if err := p.expect("=>"); err != nil {
+		return nil, err
+	}
+	if err := p.parseAction(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return p.rule, nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is an operator
+func (p *ruleParser) accept(op string) bool {
+	if t := p.peek(); t.kind == "op" && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes an operator or fails
+func (p *ruleParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+// parseAction parses what follows the arrow
+func (p *ruleParser) parseAction() error {
+	t := p.next()
+	switch action := RuleAction(t.text); {
+	case t.kind == "ident" && (action == RejectAction || action == WarnAction):
+		p.rule.action = action
+	case t.kind == "ident" && action == RequireAction:
+		requirement := p.next()
+		if requirement.kind != "ident" {
+			return fmt.Errorf("expected what is required at %d", requirement.pos)
+		}
+		p.rule.action, p.rule.requirement = action, requirement.text
+	default:
+		return fmt.Errorf("expected reject, warn or require at %d", t.pos)
+	}
+	if t := p.peek(); t.kind == "string" {
+		p.rule.message = p.next().text
+	}
+	return nil
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	return p.parseLogical(false)
+}
+
+// parseLogical parses operands joined by || or, for and, by &&
+func (p *ruleParser) parseLogical(and bool) (ruleNode, error) {
+	op, operand := "||", func() (ruleNode, error) { return p.parseLogical(true) }
+	if and {
+		op, operand = "&&", p.parseNot
+	}
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.accept(op) {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != boolKind || right.kind() != boolKind {
+			return nil, fmt.Errorf("%s needs true or false operands at %d", op, pos)
+		}
+		left = &logicalNode{and: and, left: left, right: right}
+	}
+}
+
+func (p *ruleParser) parseNot() (ruleNode, error) {
+	pos := p.peek().pos
+	if !p.accept("!") {
+		return p.parseComparison()
+	}
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != boolKind {
+		return nil, fmt.Errorf("! needs a true or false operand at %d", pos)
+	}
+	return &notNode{x: x}, nil
+}
+
+func (p *ruleParser) parseComparison() (ruleNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind == "ident" && t.text == "in" {
+		p.next()
+		return p.parseIn(left)
+	}
+	if t.kind != "op" {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left.kind() != right.kind() {
+		return nil, fmt.Errorf("cannot compare %s with %s at %d", left.kind(), right.kind(), t.pos)
+	}
+	if left.kind() == boolKind && t.text != "==" && t.text != "!=" {
+		return nil, fmt.Errorf("cannot order true or false at %d", t.pos)
+	}
+	return &compareNode{op: t.text, left: left, right: right}, nil
+}
+
+// parseIn parses the list of literals after "in"
+func (p *ruleParser) parseIn(x ruleNode) (ruleNode, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	node := &inNode{x: x}
+	for !p.accept("]") {
+		if len(node.items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		pos := p.peek().pos
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := item.(*literalNode)
+		if !ok || literal.kind() != x.kind() {
+			return nil, fmt.Errorf("expected a %s literal at %d", x.kind(), pos)
+		}
+		node.items = append(node.items, literal.value)
+	}
+	return node, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleNode, error) {
+	t := p.next()
+	switch t.kind {
+	case "number":
+		return parseNumber(t)
+	case "string":
+		return &literalNode{k: stringKind, value: t.text}, nil
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			return &literalNode{k: boolKind, value: t.text == "true"}, nil
+		}
+		return p.field(t)
+	case "op":
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "-":
+			n := p.next()
+			if n.kind != "number" {
+				return nil, fmt.Errorf("expected a number at %d", n.pos)
+			}
+			literal, err := parseNumber(n)
+			if err != nil {
+				return nil, err
+			}
+			literal.value = literal.value.(decimal.Decimal).Neg()
+			return literal, nil
+		}
+	case "eof":
+		return nil, fmt.Errorf("unexpected end of rule")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// parseNumber parses a number literal
+func parseNumber(t ruleToken) (*literalNode, error) {
+	d, err := decimal.NewFromString(t.text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+	}
+	return &literalNode{k: numberKind, value: d}, nil
+}
+
+// field resolves a field name
+func (p *ruleParser) field(t ruleToken) (ruleNode, error) {
+	name := t.text
+	if f, ok := txFields[name]; ok {
+		get := f.get
+		return &fieldNode{k: f.k, get: func(env *ruleEnv) (interface{}, error) { return get(env.tx), nil }}, nil
+	}
+	if f, ok := entryFields[name]; ok {
+		get := f.get
+		p.rule.perEntry = true
+		return &fieldNode{k: f.k, get: func(env *ruleEnv) (interface{}, error) { return get(env.entry), nil }}, nil
+	}
+	if dimension := strings.TrimPrefix(name, "dim."); dimension != name && dimension != "" {
+		p.rule.perEntry = true
+		return &fieldNode{k: stringKind, get: func(env *ruleEnv) (interface{}, error) {
+			return env.entry.Dimensions[dimension], nil
+		}}, nil
+	}
+	if attr := strings.TrimPrefix(name, "account."); attr != name && attr != "" {
+		p.rule.perEntry, p.rule.usesAccount = true, true
+		return &fieldNode{k: stringKind, get: func(env *ruleEnv) (interface{}, error) {
+			fields, err := env.account()
+			if err != nil {
+				return nil, err
+			}
+			return fields[attr], nil
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q at %d", name, t.pos)
+}
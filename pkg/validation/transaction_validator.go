@@ -102,6 +102,12 @@ func (v *TransactionValidator) GetRules() []ValidationRule {
 	return v.rules
 }
 
+// Supports implements ObjectFilter; only transactions are checked
+func (v *TransactionValidator) Supports(obj interface{}) bool {
+	_, ok := obj.(*transaction.Transaction)
+	return ok
+}
+
 // Priority returns the validator priority (lower executes first)
 func (v *TransactionValidator) Priority() int {
 	return 100
@@ -43,6 +43,14 @@ type Validator interface {
 	Priority() int
 }
 
+// ObjectFilter is implemented by validators that only check some kinds of
+// object, so validators of transactions and of accounts can share an engine.
+// The engine skips a validator for objects it does not support.
+type ObjectFilter interface {
+	// Supports reports whether the validator checks an object
+	Supports(obj interface{}) bool
+}
+
 // ValidationEngine coordinates validation across the system
 type ValidationEngine interface {
 	// RegisterValidator adds a new validator to the engine
@@ -2,10 +2,14 @@ package validation
 
 import (
 	"context"
+	"fmt"
 	"github.com/shopspring/decimal"
 	"github.com/johnayoung/finlib/pkg/money"
+	"github.com/johnayoung/finlib/pkg/storage/memory"
+	"github.com/johnayoung/finlib/pkg/tenant"
 	"github.com/johnayoung/finlib/pkg/transaction"
 	"github.com/stretchr/testify/assert"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -146,4 +150,285 @@ func TestBasicValidationEngine(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, results)
 	})
+	t.Run("Tenant Validators", func(t *testing.T) {
+		warning := &staticValidator{result: ValidationResult{Code: "TENANT_RULE", Severity: Warning}}
+		assert.Error(t, engine.RegisterTenantValidator("", warning))
+		assert.NoError(t, engine.RegisterTenantValidator("E1", warning))
+
+		tx := &transaction.Transaction{ID: "TX002"}
+		results, _ := engine.Validate(tenant.WithTenant(ctx, "E1"), tx)
+		assert.Contains(t, results, warning.result)
+
+		results, _ = engine.Validate(tenant.WithTenant(ctx, "E2"), tx)
+		assert.NotContains(t, results, warning.result)
+	})
+}
+
+// staticValidator returns a fixed result
+type staticValidator struct {
+	result ValidationResult
+}
+
+func (v *staticValidator) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
+	return []ValidationResult{v.result}, nil
+}
+
+func (v *staticValidator) GetRules() []ValidationRule { return nil }
+
+func (v *staticValidator) Priority() int { return 0 }
+
+func TestDimensionBalanceValidator(t *testing.T) {
+	validator := NewDimensionBalanceValidator(transaction.DimensionRule{Dimension: "fund"})
+	amount := money.Money{Amount: decimal.NewFromInt(100), Currency: "USD"}
+	tx := &transaction.Transaction{
+		Entries: []transaction.Entry{
+			{AccountID: "6000", Amount: amount, Type: transaction.Debit, Dimensions: map[string]string{"fund": "restricted"}},
+			{AccountID: "1000", Amount: amount, Type: transaction.Credit, Dimensions: map[string]string{"fund": "general"}},
+		},
+	}
+
+	results, err := validator.Validate(context.Background(), tx)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "DIM_UNBALANCED", results[0].Code)
+
+	tx.Entries[1].Dimensions["fund"] = "restricted"
+	results, err = validator.Validate(context.Background(), tx)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	tx.Entries[1].Dimensions = nil
+	results, err = validator.Validate(context.Background(), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, "DIM_MISSING", results[0].Code)
+}
+
+// lookupValidator reports the account of each entry after a delay, reading
+// accounts through the validation cache
+type lookupValidator struct {
+	code     string
+	priority int
+	delay    time.Duration
+	loads    *int64
+}
+
+func (v *lookupValidator) Validate(ctx context.Context, obj interface{}) ([]ValidationResult, error) {
+	time.Sleep(v.delay)
+	var results []ValidationResult
+	for _, entry := range obj.(*transaction.Transaction).Entries {
+		value, err := Cached(ctx, "account:"+entry.AccountID, func() (interface{}, error) {
+			atomic.AddInt64(v.loads, 1)
+			return entry.AccountID, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, ValidationResult{Code: v.code, Message: value.(string), Severity: Warning})
+	}
+	return results, nil
+}
+
+func (v *lookupValidator) GetRules() []ValidationRule { return nil }
+
+func (v *lookupValidator) Priority() int { return v.priority }
+
+func TestBasicValidationEngine_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	var loads int64
+	engine := NewBasicValidationEngine()
+	engine.SetConcurrency(4)
+	// Slower validators run first so they finish last
+	for i, code := range []string{"FIRST", "SECOND", "THIRD"} {
+		assert.NoError(t, engine.RegisterValidator(&lookupValidator{
+			code:     code,
+			priority: i,
+			delay:    time.Duration(3-i) * 5 * time.Millisecond,
+			loads:    &loads,
+		}))
+	}
+
+	tx := func(id string, accounts ...string) *transaction.Transaction {
+		tx := &transaction.Transaction{ID: id}
+		for _, account := range accounts {
+			tx.Entries = append(tx.Entries, transaction.Entry{AccountID: account})
+		}
+		return tx
+	}
+	codes := func(results []ValidationResult) []string {
+		out := make([]string, 0, len(results))
+		for _, r := range results {
+			out = append(out, r.Code+" "+r.Message)
+		}
+		return out
+	}
+
+	// Results follow priority order, not completion order
+	results, err := engine.Validate(ctx, tx("T1", "1000"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"FIRST 1000", "SECOND 1000", "THIRD 1000"}, codes(results))
+	assert.Equal(t, int64(3), loads, "without a cache every lookup is made")
+
+	// A batch looks each account up once
+	loads = 0
+	batch := engine.ValidateBatch(ctx, []interface{}{
+		tx("T1", "1000", "4000"),
+		tx("T2", "1000", "2000"),
+		nil,
+		tx("T3", "4000"),
+	})
+	assert.Len(t, batch, 4)
+	assert.Equal(t, []string{
+		"FIRST 1000", "FIRST 2000", "SECOND 1000", "SECOND 2000", "THIRD 1000", "THIRD 2000",
+	}, codes(batch[1].Results))
+	assert.NoError(t, batch[1].Err)
+	assert.Error(t, batch[2].Err)
+	assert.Equal(t, []string{"FIRST 4000", "SECOND 4000", "THIRD 4000"}, codes(batch[3].Results))
+	assert.Equal(t, int64(3), loads)
+
+	// Lookups are cached per tenant
+	cache := NewCache()
+	scoped := WithCache(ctx, cache)
+	engine.ValidateBatch(tenant.WithTenant(scoped, "E1"), []interface{}{tx("T1", "1000")})
+	engine.ValidateBatch(tenant.WithTenant(scoped, "E2"), []interface{}{tx("T1", "1000")})
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 2}, cache.Stats())
+}
+
+func TestRuleValidator(t *testing.T) {
+	ctx := context.Background()
+	var lookups int
+	accounts := map[string]map[string]string{
+		"6000": {"type": "EXPENSE", "code": "6000"},
+		"1000": {"type": "ASSET", "code": "1000"},
+	}
+	validator := NewRuleValidator(func(ctx context.Context, id string) (map[string]string, error) {
+		lookups++
+		return accounts[id], nil
+	})
+
+	t.Run("Compile Errors", func(t *testing.T) {
+		for _, expr := range []string{
+			`amount > "10" => warn`,
+			`amount => warn`,
+			`amount > 10`,
+			`amount > 10 => approve`,
+			`amount > 10 => require`,
+			`amount > 10 && => warn`,
+			`amount > 10 => warn "unterminated`,
+			`colour == "red" => warn`,
+			`currency in ["USD", 1] => warn`,
+			`!(amount) => reject`,
+		} {
+			err := validator.AddRule(RuleDefinition{ID: "BAD", Expression: expr})
+			assert.ErrorIs(t, err, ErrInvalidRule, expr)
+		}
+		assert.Error(t, NewRuleValidator(nil).AddRule(RuleDefinition{ID: "ACC", Expression: `account.type == "EXPENSE" => warn`}))
+		assert.Empty(t, validator.Rules())
+	})
+
+	assert.NoError(t, validator.LoadRules([]RuleDefinition{
+		{ID: "LARGE_EXPENSE", Expression: `amount > 10000 && account.type == "EXPENSE" => require approval "large expense"`},
+		{ID: "JOURNAL_REF", Expression: `tx.type == "JOURNAL" && tx.reference == "" => reject`, Description: "Journals need a reference"},
+		{ID: "FOREIGN", Expression: `!(currency in ["USD"]) || dim.fund == "restricted" => warn`},
+		{ID: "BIG", Expression: `tx.total >= 50000 || tx.entries > 4 => warn "big transaction"`},
+	}))
+
+	amount := func(n int64) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(n), Currency: "USD"}
+	}
+	tx := &transaction.Transaction{
+		ID:   "TX001",
+		Type: transaction.Journal,
+		Entries: []transaction.Entry{
+			{AccountID: "6000", Amount: amount(12000), Type: transaction.Debit},
+			{AccountID: "1000", Amount: amount(12000), Type: transaction.Credit},
+		},
+	}
+	codes := func(results []ValidationResult) []string {
+		out := make([]string, 0, len(results))
+		for _, r := range results {
+			out = append(out, fmt.Sprintf("%s %s %s %s", r.Code, r.Field, r.Severity, r.Message))
+		}
+		return out
+	}
+
+	results, err := validator.Validate(ctx, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"LARGE_EXPENSE Entries[0] WARNING large expense",
+		"JOURNAL_REF  ERROR Journals need a reference",
+	}, codes(results))
+	assert.Equal(t, []string{"approval"}, Requirements(results))
+	assert.Equal(t, 2, lookups)
+
+	// Replacing a rule keeps the others
+	assert.NoError(t, validator.AddRule(RuleDefinition{ID: "LARGE_EXPENSE", Expression: `amount > 20000 && account.type == "EXPENSE" => require approval`}))
+	tx.Reference = "ADJ-1"
+	tx.Entries[1].Dimensions = map[string]string{"fund": "restricted"}
+	tx.Entries[0].Amount = amount(60000)
+	tx.Entries[1].Amount = amount(60000)
+	results, err = validator.Validate(ctx, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"FOREIGN Entries[1] WARNING rule FOREIGN matched",
+		"BIG  WARNING big transaction",
+		"LARGE_EXPENSE Entries[0] WARNING rule LARGE_EXPENSE matched",
+	}, codes(results))
+
+	validator.RemoveRule("BIG")
+	assert.Len(t, validator.GetRules(), 3)
+	assert.Equal(t, "Journals need a reference", validator.GetRules()[0].Description)
+}
+
+func TestDuplicateValidator(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStore()
+	jan := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	payment := func(id string, date time.Time, amount int64, description string, status transaction.TransactionStatus) *transaction.Transaction {
+		value := money.Money{Amount: decimal.NewFromInt(amount), Currency: "USD"}
+		return &transaction.Transaction{
+			ID:          id,
+			Date:        date,
+			Status:      status,
+			Description: description,
+			Entries: []transaction.Entry{
+				{AccountID: "2000", Amount: value, Type: transaction.Debit},
+				{AccountID: "1000", Amount: value, Type: transaction.Credit},
+			},
+		}
+	}
+	for _, tx := range []*transaction.Transaction{
+		payment("P1", jan, 500, "Acme invoice 1042", transaction.Posted),
+		payment("P2", jan.AddDate(0, 0, -2), 500, "ACME Invoice #1042", transaction.Pending),
+		payment("P3", jan.AddDate(0, 0, -10), 500, "Acme invoice 1042", transaction.Posted),
+		payment("P4", jan, 500, "Office rent", transaction.Posted),
+		payment("P5", jan, 450, "Acme invoice 1042", transaction.Posted),
+		payment("P6", jan, 500, "Acme invoice 1042", transaction.Voided),
+	} {
+		assert.NoError(t, store.Create(ctx, tx))
+	}
+
+	validator := NewDuplicateValidator(store, DuplicateOptions{WindowDays: 3})
+	results, err := validator.Validate(ctx, payment("NEW", jan.AddDate(0, 0, 1), 500, "Acme invoice 1042", transaction.Draft))
+	assert.NoError(t, err)
+	var ids []interface{}
+	for _, r := range results {
+		assert.Equal(t, "TX_DUPLICATE", r.Code)
+		assert.Equal(t, Warning, r.Severity)
+		ids = append(ids, r.Metadata["duplicate_id"])
+	}
+	assert.Equal(t, []interface{}{"P2", "P1"}, ids)
+	assert.Equal(t, 3, results[0].Metadata["days_apart"])
+
+	// Entry order does not matter, and a transaction is not its own duplicate
+	p1 := payment("P1", jan, 500, "Acme invoice 1042", transaction.Draft)
+	p1.Entries[0], p1.Entries[1] = p1.Entries[1], p1.Entries[0]
+	results, err = validator.Validate(ctx, p1)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "P2", results[0].Metadata["duplicate_id"])
+
+	// Outside the window nothing is reported
+	results, err = NewDuplicateValidator(store, DuplicateOptions{}).Validate(ctx, payment("NEW", jan.AddDate(0, 0, 1), 500, "Acme invoice 1042", transaction.Draft))
+	assert.NoError(t, err)
+	assert.Empty(t, results)
 }